@@ -5,24 +5,43 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 	"wameter/internal/agent/collector"
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
+	"wameter/internal/agent/daemon"
 	"wameter/internal/agent/handler"
+	"wameter/internal/agent/logship"
 	"wameter/internal/agent/notify"
 	"wameter/internal/agent/reporter"
+	"wameter/internal/agent/resources"
+	"wameter/internal/agent/tui"
+	commonCfg "wameter/internal/config"
 	"wameter/internal/logger"
+	"wameter/internal/profiling"
+	"wameter/internal/secrets"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+	"wameter/internal/utils"
 	"wameter/internal/version"
 
 	"go.uber.org/zap"
 )
 
 func main() {
+	// Manage the agent as a system service
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(runService(os.Args[2:]))
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "", "Path to config file")
 	showVersion := flag.Bool("version", false, "Show version information")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit")
+	overrides := commonCfg.Overrides{}
+	flag.Var(overrides, "set", "Override a config value as key=value, e.g. log.level=debug (repeatable)")
+	showTUI := flag.Bool("tui", false, "Show a live terminal dashboard")
+	refresh := flag.Duration("refresh", time.Second, "Terminal dashboard refresh interval")
 	flag.Parse()
 
 	// Show version if requested
@@ -33,12 +52,29 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.LoadConfig(*configPath)
+	cfg, err := config.LoadConfig(*configPath, overrides)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
+	// Print effective configuration if requested
+	if *printConfig {
+		if err := commonCfg.PrintConfig(os.Stdout, cfg); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// The dashboard owns the terminal, so logs only go to the log file
+	if *showTUI {
+		if cfg.Log == nil {
+			cfg.Log = logger.DefaultConfig()
+		}
+		cfg.Log.DisableConsole = true
+	}
+
 	// Initialize logger
 	logger, err := logger.New(cfg.Log)
 	if err != nil {
@@ -50,21 +86,54 @@ func main() {
 		_ = logger.Sync()
 	}(logger)
 
+	// Monitor the host network through its mounts when run in a container
+	if cfg.Agent.Container.Active() {
+		sys, proc := cfg.Agent.Container.HostPaths()
+		utils.SetHostPaths(sys, proc)
+		logger.Info("Running in container mode",
+			zap.String("hostname", cfg.Agent.Hostname),
+			zap.String("host_sys", sys),
+			zap.String("host_proc", proc))
+	}
+
+	// Disabled certificate verification must not go unnoticed
+	var notifyHTTP *commonCfg.HTTPClientConfig
+	if cfg.Notify != nil {
+		notifyHTTP = cfg.Notify.HTTP
+	}
+	for section, hc := range map[string]*commonCfg.HTTPClientConfig{
+		"http":                   cfg.HTTP,
+		"collector.network.http": cfg.Collector.Network.HTTP,
+		"notify.http":            notifyHTTP,
+	} {
+		// Sections inheriting the global settings share its config
+		if hc != nil && hc.InsecureSkipVerify && (hc != cfg.HTTP || section == "http") {
+			logger.Warn("TLS CERTIFICATE VERIFICATION IS DISABLED, outbound HTTPS connections can be intercepted, do not use insecure_skip_verify in production",
+				zap.String("section", section+".insecure_skip_verify"))
+		}
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Run agent
-	if err := run(ctx, cfg, logger); err != nil {
+	cm, err := run(ctx, cfg, logger)
+	if err != nil {
 		logger.Fatal("Failed to run agent", zap.Error(err))
 	}
 
-	// Handle signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Render live dashboard
+	if *showTUI {
+		go tui.NewDashboard(cm, os.Stdout, cfg.Agent.Hostname, *refresh).Run(ctx)
+	}
 
-	// Wait for signal
-	<-sigChan
+	// Report readiness to systemd and keep its watchdog fed
+	daemon.Ready(ctx, logger)
+
+	// Wait for a signal or the service manager to stop the agent
+	<-daemon.Stopped(defaultServiceName())
+	daemon.Stopping()
 
 	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -76,11 +145,52 @@ func main() {
 	logger.Info("Shutdown complete")
 }
 
-// run runs the agent
-func run(ctx context.Context, cfg *config.Config, logger *zap.Logger) (err error) {
-	// Initialize reporter
+// uploadCrashReports uploads the stored crash reports to the server as agent
+// logs, reports failing to upload are kept for the next start
+func uploadCrashReports(ctx context.Context, cfg *config.Config, logger *zap.Logger) {
+	client, err := cfg.ServerClient()
+	if err != nil {
+		logger.Error("Failed to create server client", zap.Error(err))
+		client = tracing.DefaultClient
+	}
+	sent, err := crash.Upload(ctx, func(ctx context.Context, entries []*types.AgentLogEntry) error {
+		return logship.Ship(ctx, cfg, client, entries)
+	})
+	if err != nil {
+		logger.Warn("Failed to upload crash reports", zap.Error(err))
+		return
+	}
+	if sent > 0 {
+		logger.Info("Uploaded crash reports", zap.Int("count", sent))
+	}
+}
+
+// run runs the agent and returns its collector manager
+func run(ctx context.Context, cfg *config.Config, logger *zap.Logger) (cm *collector.Manager, err error) {
+	// Keep the agent within its CPU and memory limits
+	var limits resources.Settings
+	if cfg.Agent.Resources.Enabled {
+		limits = resources.Apply(&cfg.Agent.Resources, logger)
+	}
+
+	// Store crash reports of components, which are restarted
+	crash.Setup(cfg, logger)
+
+	// Resolve secret references before anything uses them
+	resolver := secrets.NewResolver(cfg.Secrets, logger)
+	if err = resolver.Bind(ctx, cfg); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	// Export spans of the reporter and the other server calls
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize reporter, standalone agents may still write to influx
 	var r *reporter.Reporter
-	if !cfg.Agent.Standalone {
+	if !cfg.Agent.Standalone || cfg.Agent.Influx.Enabled {
 		r = reporter.NewReporter(cfg, logger)
 	}
 
@@ -88,33 +198,81 @@ func run(ctx context.Context, cfg *config.Config, logger *zap.Logger) (err error
 	var n *notify.Manager
 	if cfg.Agent.Standalone && cfg.Notify.Enabled {
 		if n, err = notify.NewManager(cfg.Notify, logger); err != nil {
-			return fmt.Errorf("failed to initialize notifier: %w", err)
+			return nil, fmt.Errorf("failed to initialize notifier: %w", err)
 		}
 	}
 
+	// Refresh rotated secrets
+	if cfg.Secrets != nil && cfg.Secrets.RefreshInterval > 0 {
+		apply := func(update func()) { update() }
+		if n != nil {
+			apply = n.UpdateConfig
+		}
+		go resolver.Watch(ctx, cfg.Secrets.RefreshInterval, apply)
+	}
+
 	// Initialize collector and handler
-	cm := collector.NewManager(cfg, r, n, logger)
+	cm = collector.NewManager(cfg, r, n, logger)
 	h := handler.NewHandler(cfg, logger, cm)
 
 	// Start components
 	if err = h.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start handler: %w", err)
+		return nil, fmt.Errorf("failed to start handler: %w", err)
+	}
+
+	// Upload the crash reports of previous runs, now the agent is registered
+	if !cfg.Agent.Standalone && cfg.Agent.CrashReports.Upload {
+		go uploadCrashReports(ctx, cfg, logger)
 	}
 
 	if err = cm.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start collector: %w", err)
+		return nil, fmt.Errorf("failed to start collector: %w", err)
 	}
 
 	if r != nil {
 		if err = r.Start(ctx); err != nil {
-			return fmt.Errorf("failed to start reporter: %w", err)
+			return nil, fmt.Errorf("failed to start reporter: %w", err)
 		}
 	}
 
+	// Profiling and runtime metrics endpoints
+	var prof *profiling.Server
+	if cfg.Debug != nil && cfg.Debug.Enabled {
+		prof = profiling.NewServer(cfg.Debug, logger)
+		if err = prof.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ship own logs to the server
+	var ls *logship.Shipper
+	if !cfg.Agent.Standalone && cfg.Agent.LogShipping.Enabled {
+		ls = logship.NewShipper(cfg, logger)
+		if err = ls.Start(ctx); err != nil {
+			return nil, fmt.Errorf("failed to start log shipper: %w", err)
+		}
+	}
+
+	// Relieve memory pressure above the resident memory limit
+	if limits.MaxRSS > 0 {
+		wd := resources.NewWatchdog(&cfg.Agent.Resources, limits.MaxRSS, logger)
+		wd.OnThrottle(cm.SetIntervalFactor)
+		if r != nil {
+			wd.OnDrop(r.DropBuffers)
+		}
+		if ls != nil {
+			wd.OnDrop(ls.DropPending)
+		}
+		go wd.Run(ctx)
+	}
+
 	// Handle cleanup in separate goroutine
 	go func() {
 		<-ctx.Done()
 		// Stop components in reverse order
+		if ls != nil {
+			_ = ls.Stop()
+		}
 		if r != nil {
 			_ = r.Stop()
 		}
@@ -123,7 +281,13 @@ func run(ctx context.Context, cfg *config.Config, logger *zap.Logger) (err error
 		if n != nil {
 			_ = n.Stop()
 		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if prof != nil {
+			_ = prof.Stop(stopCtx)
+		}
+		_ = shutdownTracing(stopCtx)
+		cancel()
 	}()
 
-	return nil
+	return cm, nil
 }
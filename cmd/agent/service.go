@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"wameter/internal/agent/config"
+	"wameter/internal/agent/daemon"
+	commonCfg "wameter/internal/config"
+)
+
+// serviceUsage is printed for missing or unknown service actions
+const serviceUsage = `Usage: agent service <install|uninstall|start|stop|status> [flags]
+
+Manages the agent as a systemd unit on Linux, a launchd daemon on macOS or
+a Windows service. Requires root or administrator privileges.
+`
+
+// defaultServiceName returns the service name used on the platform
+func defaultServiceName() string {
+	if runtime.GOOS == "darwin" {
+		return "com.wameter.agent"
+	}
+	return "wameter-agent"
+}
+
+// runService runs the service subcommand and returns the exit code
+func runService(args []string) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(os.Stderr, serviceUsage)
+		return 2
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	configPath := fs.String("config", "/etc/wameter/agent.yaml", "Path to the config file the service runs with")
+	name := fs.String("name", defaultServiceName(), "Service name")
+	user := fs.String("user", "", "User the service runs as, defaults to root or LocalSystem")
+	_ = fs.Parse(args[1:])
+
+	cfg := &daemon.Config{
+		Name:        *name,
+		DisplayName: "Wameter Agent",
+		Description: "Wameter network monitoring agent",
+		User:        *user,
+		DataDir:     "/var/lib/wameter/agent",
+		LogDir:      "/var/log/wameter",
+	}
+
+	if action == "install" {
+		path, err := filepath.Abs(*configPath)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		// Refuse to install a service that would fail to start
+		agentCfg, err := config.LoadConfig(path, commonCfg.Overrides{})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		cfg.Args = []string{"-config", path}
+		cfg.DataDir = agentCfg.Agent.DataDir
+		if agentCfg.Log != nil && agentCfg.Log.File != "" {
+			cfg.LogDir = filepath.Dir(agentCfg.Log.File)
+		}
+	}
+
+	m, err := daemon.New(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	switch action {
+	case "install":
+		err = m.Install()
+	case "uninstall":
+		err = m.Uninstall()
+	case "start":
+		err = m.Start()
+	case "stop":
+		err = m.Stop()
+	case "status":
+		var status daemon.Status
+		if status, err = m.Status(); err == nil {
+			fmt.Printf("%s: %s\n", cfg.Name, status)
+			if status != daemon.StatusRunning {
+				return 3
+			}
+		}
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown service action %q\n\n%s", action, serviceUsage)
+		return 2
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to %s service: %v\n", action, err)
+		return 1
+	}
+	if action != "status" {
+		fmt.Printf("%s: %s done\n", cfg.Name, action)
+	}
+	return 0
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"os"
+	"wameter/internal/loadgen"
+)
+
+func main() {
+	os.Exit(loadgen.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"os"
+	"wameter/internal/ctl"
+)
+
+func main() {
+	os.Exit(ctl.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
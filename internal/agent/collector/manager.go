@@ -3,10 +3,13 @@ package collector
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 	"wameter/internal/agent/collector/network"
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
 	"wameter/internal/agent/notify"
 	"wameter/internal/agent/reporter"
 	"wameter/internal/types"
@@ -19,10 +22,18 @@ type Manager struct {
 	reporter   *reporter.Reporter
 	notifier   *notify.Manager
 	collectors map[string]Collector
+	states     map[string]*collectorState
+	ctx        context.Context // Collectors are restarted with, set by Start
 	config     *config.Config
 	logger     *zap.Logger
 	mu         sync.RWMutex
 	startTime  time.Time
+	latest     *types.MetricsData
+	latestMu   sync.RWMutex
+	// Serializes scheduled and requested collections
+	collectMu sync.Mutex
+	// Stretch of the collection interval, set under memory pressure
+	intervalFactor atomic.Int64
 }
 
 // NewManager creates new collector manager
@@ -31,6 +42,7 @@ func NewManager(cfg *config.Config, reporter *reporter.Reporter, notifier *notif
 		reporter:   reporter,
 		notifier:   notifier,
 		collectors: make(map[string]Collector),
+		states:     make(map[string]*collectorState),
 		config:     cfg,
 		logger:     logger,
 		startTime:  time.Now(),
@@ -48,6 +60,7 @@ func (m *Manager) RegisterCollector(c Collector) error {
 	}
 
 	m.collectors[name] = c
+	m.states[name] = &collectorState{}
 	return nil
 }
 
@@ -59,9 +72,10 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 
 	// Start all collectors
+	m.ctx = ctx
 	for name, collector := range m.collectors {
 		m.mu.RLock()
-		err := collector.Start(ctx)
+		err := m.startCollector(ctx, collector, m.states[name])
 		m.mu.RUnlock()
 		if err != nil {
 			return fmt.Errorf("failed to start collector %s: %w", name, err)
@@ -70,7 +84,7 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 
 	// Start collection loop
-	go m.startCollectorLoop(ctx)
+	go crash.Supervise(ctx, "collector", m.startCollectorLoop)
 
 	return nil
 }
@@ -93,7 +107,9 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// Collect runs all collectors and aggregates their results
+// Collect runs all collectors and aggregates their results. Collections
+// running longer than the stall timeout are canceled and their collectors
+// restarted.
 func (m *Manager) Collect(ctx context.Context) (*types.MetricsData, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -106,14 +122,47 @@ func (m *Manager) Collect(ctx context.Context) (*types.MetricsData, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errs := make(map[string]error)
+	timeout := m.stallTimeout()
 
 	// Launch collectors
 	for name, collector := range m.collectors {
+		state := m.states[name]
+		if !state.running.CompareAndSwap(false, true) {
+			m.stalled(name, collector, state, "previous collection still blocked")
+			errs[name] = fmt.Errorf("collection blocked")
+			continue
+		}
+
 		wg.Add(1)
 		go func(name string, c Collector) {
 			defer wg.Done()
 
-			data, err := c.Collect(ctx)
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			var data *types.MetricsData
+			var err error
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer state.running.Store(false)
+				defer crash.Recover("collector." + name)
+				data, err = c.Collect(cctx)
+			}()
+
+			select {
+			case <-done:
+			case <-cctx.Done():
+				if ctx.Err() != nil {
+					return
+				}
+				m.stalled(name, c, state, "collection timed out")
+				mu.Lock()
+				errs[name] = fmt.Errorf("collection stalled after %s", timeout)
+				mu.Unlock()
+				return
+			}
+
 			mu.Lock()
 			defer mu.Unlock()
 
@@ -146,6 +195,26 @@ func (m *Manager) StartTime() time.Time {
 	return m.startTime
 }
 
+// Latest returns the most recently collected data
+func (m *Manager) Latest() *types.MetricsData {
+	m.latestMu.RLock()
+	defer m.latestMu.RUnlock()
+	return m.latest
+}
+
+// Names returns the names of the registered collectors, sorted
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.collectors))
+	for name := range m.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetReporter returns the current reporter
 func (m *Manager) GetReporter() *reporter.Reporter {
 	m.mu.RLock()
@@ -160,6 +229,7 @@ func (m *Manager) initCollectors() error {
 		networkCollector := network.NewCollector(
 			&m.config.Collector.Network,
 			m.config.Agent.ID,
+			m.config.Agent.Hostname,
 			m.reporter,
 			m.notifier,
 			m.config.Agent.Standalone,
@@ -175,40 +245,80 @@ func (m *Manager) initCollectors() error {
 	return nil
 }
 
-// startCollectorLoop starts the collector loop
+// startCollectorLoop starts the collector loop, collecting on the schedule
+// of the collector config
 func (m *Manager) startCollectorLoop(ctx context.Context) {
-	ticker := time.NewTicker(m.config.Collector.Interval)
-	defer ticker.Stop()
+	sched := newSchedule(&m.config.Collector, m.config.Agent.ID)
+	slot := sched.first(time.Now())
+	timer := time.NewTimer(time.Until(sched.due(slot)))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			data, err := m.Collect(ctx)
-			if err != nil {
-				m.logger.Error("Failed to collect metrics", zap.Error(err))
-				continue
-			}
+		case <-timer.C:
+			// Schedule the next collection first, so collections do not drift
+			slot = sched.next(slot, time.Now(), m.IntervalFactor())
+			timer.Reset(time.Until(sched.due(slot)))
 
-			if data == nil {
-				m.logger.Debug("No data collected")
-				continue
-			}
+			m.collectAndReport(ctx)
+		}
+	}
+}
 
-			// Ensure we have basic data fields
-			if data.Hostname == "" {
-				data.Hostname = m.config.Agent.Hostname
-			}
+// SetIntervalFactor stretches the collection interval by factor, from the
+// next collection on
+func (m *Manager) SetIntervalFactor(factor int) {
+	m.intervalFactor.Store(int64(max(factor, 1)))
+}
 
-			data.ReportedAt = time.Now()
+// IntervalFactor returns the stretch of the collection interval
+func (m *Manager) IntervalFactor() int {
+	return int(max(m.intervalFactor.Load(), 1))
+}
 
-			// Send data if we have any
-			if !m.config.Agent.Standalone && m.reporter != nil {
-				if err := m.reporter.Report(data); err != nil {
-					m.logger.Error("Failed to report metrics", zap.Error(err))
-				}
-			}
+// collectAndReport collects metrics and hands them to the reporter
+func (m *Manager) collectAndReport(ctx context.Context) {
+	if err := m.CollectNow(ctx, ""); err != nil {
+		m.logger.Error("Failed to collect and report metrics", zap.Error(err))
+	}
+}
+
+// CollectNow runs all collectors at once and hands the data to the reporter,
+// tagged with the ID of the command requesting it if any
+func (m *Manager) CollectNow(ctx context.Context, commandID string) error {
+	m.collectMu.Lock()
+	defer m.collectMu.Unlock()
+
+	data, err := m.Collect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	if data == nil {
+		m.logger.Debug("No data collected")
+		return nil
+	}
+
+	// Ensure we have basic data fields
+	if data.Hostname == "" {
+		data.Hostname = m.config.Agent.Hostname
+	}
+
+	data.ReportedAt = time.Now()
+	data.CollectorStalls = m.Stalls()
+	data.CommandID = commandID
+
+	m.latestMu.Lock()
+	m.latest = data
+	m.latestMu.Unlock()
+
+	// Send data if we have any
+	if m.reporter != nil {
+		if err := m.reporter.Report(data); err != nil {
+			return fmt.Errorf("failed to report metrics: %w", err)
 		}
 	}
+	return nil
 }
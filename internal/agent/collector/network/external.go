@@ -0,0 +1,342 @@
+package network
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
+	commonCfg "wameter/internal/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// latencyWeight is the weight of a new sample in the provider latency average
+const latencyWeight = 0.2
+
+// result represents the result of an external IP query
+type result struct {
+	provider string
+	ip       string
+	err      error
+}
+
+// externalProviders holds the sources of external IP providers and tracks
+// their health. Providers failing failure_threshold times in a row are skipped for demote_duration,
+// a failure after that demotes them again.
+type externalProviders struct {
+	version   types.IPVersion
+	config    *config.ExternalConsensusConfig
+	client    *http.Client
+	providers []string
+	sources   map[string]ipSource
+	stats     map[string]*providerStats
+	mu        sync.Mutex
+}
+
+// providerStats represents the health of a provider
+type providerStats struct {
+	checks       int64
+	failures     int64
+	consecutive  int // Failures since the last success
+	latency      float64
+	lastError    string
+	demotedUntil time.Time
+}
+
+// newExternalProviders creates the sources of providers detecting the
+// address of version and their health tracker, invalid providers are
+// logged and skipped
+func newExternalProviders(version types.IPVersion, providers []string, cfg *config.ExternalConsensusConfig, httpCfg *commonCfg.HTTPClientConfig, proxy *commonCfg.ProxyConfig, logger *zap.Logger) *externalProviders {
+	if httpCfg == nil {
+		httpCfg = commonCfg.HTTPClientDefaultConfig()
+	}
+	transport, err := httpCfg.Transport(proxy)
+	if err != nil {
+		logger.Error("Failed to apply http config to external IP providers, using defaults", zap.Error(err))
+		httpCfg = commonCfg.HTTPClientDefaultConfig()
+		transport, _ = httpCfg.Transport(proxy)
+	}
+
+	// Force the address family of the lookup
+	network := "tcp4"
+	if version == types.IPv6 {
+		network = "tcp6"
+	}
+	dialer := &net.Dialer{Timeout: httpCfg.ConnectTimeout}
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	transport.DisableCompression = true
+	transport.MaxIdleConnsPerHost = 10
+
+	e := &externalProviders{
+		version: version,
+		config:  cfg,
+		client:  &http.Client{Timeout: httpCfg.Timeout, Transport: transport},
+		sources: make(map[string]ipSource, len(providers)),
+		stats:   make(map[string]*providerStats, len(providers)),
+	}
+	for _, p := range providers {
+		source, err := newSource(p, version, e.client, logger)
+		if err != nil {
+			logger.Error("Ignoring external IP provider", zap.Error(err), zap.String("provider", p))
+			continue
+		}
+		e.providers = append(e.providers, p)
+		e.sources[p] = source
+		e.stats[p] = &providerStats{}
+	}
+	return e
+}
+
+// active returns the providers to query in the configured order. Demoted
+// providers are queried as well when fewer than need providers are left.
+func (e *externalProviders) active(need int) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	var active []string
+	for _, p := range e.providers {
+		if now.After(e.stats[p].demotedUntil) {
+			active = append(active, p)
+		}
+	}
+	if len(active) < need {
+		active = slices.Clone(e.providers)
+	}
+
+	if e.config.Order == config.ProviderOrderFastest {
+		// Providers never queried go first to measure them, those that
+		// never succeeded have no latency and go last
+		rank := func(s *providerStats) int {
+			switch {
+			case s.checks == 0:
+				return 0
+			case s.checks > s.failures:
+				return 1
+			default:
+				return 2
+			}
+		}
+		slices.SortStableFunc(active, func(a, b string) int {
+			sa, sb := e.stats[a], e.stats[b]
+			if c := cmp.Compare(rank(sa), rank(sb)); c != 0 || rank(sa) != 1 {
+				return c
+			}
+			return cmp.Compare(sa.latency, sb.latency)
+		})
+	}
+	return active
+}
+
+// record records the outcome of a query
+func (e *externalProviders) record(provider string, latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := e.stats[provider]
+	s.checks++
+	if err == nil {
+		ms := float64(latency) / float64(time.Millisecond)
+		if s.checks-s.failures == 1 {
+			s.latency = ms
+		} else {
+			s.latency += latencyWeight * (ms - s.latency)
+		}
+		s.consecutive = 0
+		s.demotedUntil = time.Time{}
+		return
+	}
+
+	s.failures++
+	s.consecutive++
+	s.lastError = err.Error()
+	if e.config.FailureThreshold > 0 && s.consecutive >= e.config.FailureThreshold {
+		s.demotedUntil = time.Now().Add(e.config.DemoteDuration)
+	}
+}
+
+// Stats returns the health of all providers
+func (e *externalProviders) Stats() []types.ExternalProviderStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]types.ExternalProviderStats, 0, len(e.providers))
+	for _, p := range e.providers {
+		s := e.stats[p]
+		ps := types.ExternalProviderStats{
+			URL:       p,
+			Version:   e.version,
+			Checks:    s.checks,
+			Failures:  s.failures,
+			Latency:   s.latency,
+			LastError: s.lastError,
+		}
+		if s.checks > 0 {
+			ps.SuccessRate = float64(s.checks-s.failures) / float64(s.checks)
+		}
+		if s.demotedUntil.After(now) {
+			until := s.demotedUntil
+			ps.DemotedUntil = &until
+		}
+		stats = append(stats, ps)
+	}
+	return stats
+}
+
+// Close releases idle connections of the providers
+func (e *externalProviders) Close() {
+	if transport, ok := e.client.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// collectExternalIPs looks up the external address of each version
+// concurrently, failed lookups are empty
+func (c *networkCollector) collectExternalIPs(ctx context.Context) map[types.IPVersion]string {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ips := make(map[types.IPVersion]string, len(c.external))
+
+	for version, e := range c.external {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer crash.Recover("network.external")
+			ip, err := e.getExternalIP(ctx)
+			if err != nil {
+				// Hosts without IPv6 connectivity are common
+				log := c.logger.Warn
+				if version == types.IPv6 {
+					log = c.logger.Debug
+				}
+				log("Failed to get external IP", zap.Error(err), zap.String("version", string(version)))
+			}
+			mu.Lock()
+			ips[version] = ip
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return ips
+}
+
+// getExternalIP queries the providers concurrently and returns the IP they
+// agree on according to the consensus strategy
+func (e *externalProviders) getExternalIP(ctx context.Context) (string, error) {
+	if len(e.providers) == 0 {
+		return "", fmt.Errorf("no external %s providers configured", e.version)
+	}
+
+	consensus := e.config
+	need := 1
+	if consensus.Strategy == config.ConsensusQuorum {
+		need = consensus.Quorum
+	}
+	providers := e.active(need)
+
+	// The deadline bounds the whole lookup
+	ctx, cancel := context.WithTimeout(ctx, consensus.Deadline)
+	defer cancel()
+
+	results := make(chan result, len(providers))
+	var wg sync.WaitGroup
+
+	// At most max_concurrent queries are in flight
+	var slots chan struct{}
+	if consensus.MaxConcurrent > 0 {
+		slots = make(chan struct{}, consensus.MaxConcurrent)
+	}
+
+	// Query providers concurrently, queries canceled after the consensus
+	// was reached, or never started, are not recorded
+	for _, provider := range providers {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			defer crash.Recover("network.external " + p)
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			qctx, qcancel := context.WithTimeout(ctx, consensus.ProviderTimeout)
+			defer qcancel()
+
+			start := time.Now()
+			ip, err := e.sources[p].Lookup(qctx)
+			if err == nil && ipVersion(ip) != e.version {
+				err = fmt.Errorf("provider returned %s for an %s lookup", ip, e.version)
+			}
+			if !errors.Is(err, context.Canceled) {
+				e.record(p, time.Since(start), err)
+			}
+			select {
+			case results <- result{p, ip, err}:
+			case <-ctx.Done():
+			}
+		}(provider)
+	}
+
+	// Close results channel after all goroutines finish
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Use map to track IP consensus
+	ips := make(map[string]int)
+	var lastErr error
+
+	for r := range results {
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		ips[r.ip]++
+		count := ips[r.ip]
+
+		switch consensus.Strategy {
+		case config.ConsensusFirstSuccess:
+			return r.ip, nil
+		case config.ConsensusMajority:
+			if count*2 > len(providers) {
+				return r.ip, nil
+			}
+		default:
+			if count >= consensus.Quorum {
+				return r.ip, nil
+			}
+		}
+	}
+
+	if len(ips) > 0 {
+		return "", fmt.Errorf("no %s consensus on external %s among %d providers: %v",
+			consensus.Strategy, e.version, len(providers), ips)
+	}
+
+	return "", fmt.Errorf("failed to get external %s: %v", e.version, lastErr)
+}
+
+// ipVersion returns the version of a valid IP address
+func ipVersion(ip string) types.IPVersion {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return types.IPv6
+	}
+	return types.IPv4
+}
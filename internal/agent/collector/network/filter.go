@@ -0,0 +1,122 @@
+package network
+
+import (
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"wameter/internal/agent/config"
+	"wameter/internal/utils"
+)
+
+// interfaceFilter decides which interfaces are monitored and which of their
+// data is collected, built once from the network configuration
+type interfaceFilter struct {
+	config       *config.NetworkConfig
+	includeRegex []*regexp.Regexp
+	excludeRegex []*regexp.Regexp
+}
+
+// newInterfaceFilter compiles the filters of cfg, invalid expressions are
+// rejected by the config validation and skipped here
+func newInterfaceFilter(cfg *config.NetworkConfig) *interfaceFilter {
+	return &interfaceFilter{
+		config:       cfg,
+		includeRegex: compileRegex(cfg.IncludeRegex),
+		excludeRegex: compileRegex(cfg.ExcludeRegex),
+	}
+}
+
+// compileRegex compiles the valid expressions of exprs
+func compileRegex(exprs []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		if re, err := regexp.Compile(expr); err == nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+// monitors returns true if the interface should be monitored. Includes
+// narrow the interfaces down, excludes win over includes.
+func (f *interfaceFilter) monitors(iface net.Interface) bool {
+	// Skip interfaces that are not up
+	if iface.Flags&net.FlagUp == 0 {
+		return false
+	}
+
+	// Skip loopback interfaces
+	if iface.Flags&net.FlagLoopback != 0 {
+		return false
+	}
+
+	// If specific interfaces are configured, only monitor those
+	if len(f.config.Interfaces) > 0 && !slices.Contains(f.config.Interfaces, iface.Name) {
+		return false
+	}
+
+	if len(f.includeRegex) > 0 && !matchesAny(f.includeRegex, iface.Name) {
+		return false
+	}
+
+	ifaceType := string(utils.GetInterfaceType(iface.Name))
+	if len(f.config.IncludeTypes) > 0 && !slices.Contains(f.config.IncludeTypes, ifaceType) {
+		return false
+	}
+
+	// Check exclusion patterns
+	for _, pattern := range f.config.ExcludePatterns {
+		if matched, _ := filepath.Match(pattern, iface.Name); matched {
+			return false
+		}
+	}
+
+	if matchesAny(f.excludeRegex, iface.Name) || slices.Contains(f.config.ExcludeTypes, ifaceType) {
+		return false
+	}
+
+	// Skip virtual interfaces unless enabled or their type is included
+	if !f.config.IncludeVirtual && utils.IsVirtualInterface(iface.Name) &&
+		!slices.Contains(f.config.IncludeTypes, ifaceType) {
+		return false
+	}
+
+	return true
+}
+
+// collectsStats returns true if statistics of the interface are collected
+func (f *interfaceFilter) collectsStats(name string) bool {
+	if s := f.settings(name); s != nil && s.Stats != nil {
+		return *s.Stats
+	}
+	return true
+}
+
+// tracksIPs returns true if IP changes of the interface are tracked
+func (f *interfaceFilter) tracksIPs(name string) bool {
+	if s := f.settings(name); s != nil && s.IPTracking != nil {
+		return *s.IPTracking
+	}
+	return true
+}
+
+// settings returns the first interface settings matching name
+func (f *interfaceFilter) settings(name string) *config.InterfaceConfig {
+	for i, s := range f.config.InterfaceSettings {
+		if matched, _ := filepath.Match(s.Name, name); matched {
+			return &f.config.InterfaceSettings[i]
+		}
+	}
+	return nil
+}
+
+// matchesAny returns true if name matches one of res
+func matchesAny(res []*regexp.Regexp, name string) bool {
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
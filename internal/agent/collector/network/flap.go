@@ -0,0 +1,128 @@
+package network
+
+import (
+	"fmt"
+	"time"
+	"wameter/internal/agent/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// flapDetector detects interfaces and external IPs that change too frequently
+type flapDetector struct {
+	config   *config.FlapDetectionConfig
+	logger   *zap.Logger
+	events   map[string][]time.Time // key -> recent change times
+	flapping map[string]*flapState  // key -> flapping state
+}
+
+// flapState represents the state of a flapping interface or external IP
+type flapState struct {
+	change     types.IPChange // last change seen while flapping
+	since      time.Time
+	lastChange time.Time
+	suppressed int
+}
+
+// newFlapDetector creates new flap detector
+func newFlapDetector(cfg *config.FlapDetectionConfig, logger *zap.Logger) *flapDetector {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.Window == 0 {
+		cfg.Window = 10 * time.Minute
+	}
+	if cfg.StableAfter == 0 {
+		cfg.StableAfter = 15 * time.Minute
+	}
+
+	return &flapDetector{
+		config:   cfg,
+		logger:   logger,
+		events:   make(map[string][]time.Time),
+		flapping: make(map[string]*flapState),
+	}
+}
+
+// filter suppresses changes of flapping interfaces, emitting a single flapping
+// change when flapping starts and a stable change when it stops
+func (d *flapDetector) filter(changes []types.IPChange, now time.Time) []types.IPChange {
+	var result []types.IPChange
+
+	for _, change := range changes {
+		key := flapKey(change)
+
+		// Record change and drop events outside the window
+		events := append(d.events[key], now)
+		cutoff := now.Add(-d.config.Window)
+		for len(events) > 0 && events[0].Before(cutoff) {
+			events = events[1:]
+		}
+		d.events[key] = events
+
+		// Suppress changes while flapping
+		if state, ok := d.flapping[key]; ok {
+			state.change = change
+			state.lastChange = now
+			state.suppressed++
+			continue
+		}
+
+		if len(events) < d.config.Threshold {
+			result = append(result, change)
+			continue
+		}
+
+		d.flapping[key] = &flapState{
+			change:     change,
+			since:      now,
+			lastChange: now,
+		}
+
+		d.logger.Warn("Flapping detected",
+			zap.String("interface", change.InterfaceName),
+			zap.Bool("is_external", change.IsExternal),
+			zap.Int("changes", len(events)),
+			zap.Duration("window", d.config.Window))
+
+		flap := change
+		flap.Action = types.IPChangeActionFlapping
+		flap.Reason = fmt.Sprintf("flapping_%d_changes_in_%s", len(events), d.config.Window)
+		flap.Timestamp = now
+		result = append(result, flap)
+	}
+
+	// Emit recovery summary for interfaces that have stabilized
+	for key, state := range d.flapping {
+		if now.Sub(state.lastChange) < d.config.StableAfter {
+			continue
+		}
+
+		d.logger.Info("Flapping stopped",
+			zap.String("interface", state.change.InterfaceName),
+			zap.Bool("is_external", state.change.IsExternal),
+			zap.Int("suppressed", state.suppressed),
+			zap.Duration("duration", state.lastChange.Sub(state.since)))
+
+		stable := state.change
+		stable.OldAddrs = nil
+		stable.Action = types.IPChangeActionStable
+		stable.Reason = fmt.Sprintf("stable_after_%d_suppressed", state.suppressed)
+		stable.Timestamp = now
+		result = append(result, stable)
+
+		delete(d.flapping, key)
+		delete(d.events, key)
+	}
+
+	return result
+}
+
+// flapKey returns the flap tracking key for a change
+func flapKey(change types.IPChange) string {
+	if change.IsExternal {
+		return "external/" + string(change.Version)
+	}
+	return change.InterfaceName
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// stateSaveInterval is the longest time the state file is left unwritten
+// while nothing changes, keeping last seen times fresh
+const stateSaveInterval = 5 * time.Minute
+
+// trackerState represents the IP tracker state persisted across restarts
+type trackerState struct {
+	Interfaces map[string]*interfaceState `json:"interfaces"`
+	External   map[types.IPVersion]string `json:"external"`
+	SavedAt    time.Time                  `json:"saved_at"`
+}
+
+// interfaceState represents the persisted state of an interface
+type interfaceState struct {
+	IPv4Addrs []string  `json:"ipv4_addrs"`
+	IPv6Addrs []string  `json:"ipv6_addrs"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// loadState restores the state saved by a previous run, so that restarts
+// do not report known addresses as new. Interfaces not seen within the
+// retention period are dropped.
+func (t *IPTracker) loadState() {
+	if t.config.StateFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(t.config.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		t.logger.Warn("Failed to read IP tracker state", zap.Error(err))
+		return
+	}
+
+	var state trackerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.logger.Warn("Ignoring invalid IP tracker state",
+			zap.String("file", t.config.StateFile),
+			zap.Error(err))
+		return
+	}
+
+	threshold := t.clock.Now().Add(-t.config.RetentionPeriod)
+	for name, iface := range state.Interfaces {
+		if iface == nil || iface.LastSeen.Before(threshold) {
+			continue
+		}
+		t.lastState[name] = &types.IPState{
+			IPv4Addrs: iface.IPv4Addrs,
+			IPv6Addrs: iface.IPv6Addrs,
+			UpdatedAt: iface.UpdatedAt,
+		}
+		t.lastSeen[name] = iface.LastSeen
+	}
+	if state.SavedAt.After(threshold) {
+		for version, ip := range state.External {
+			if ip != "" {
+				t.lastExternal[version] = ip
+			}
+		}
+	}
+
+	t.logger.Info("Restored IP tracker state",
+		zap.Int("interfaces", len(t.lastState)),
+		zap.Int("external", len(t.lastExternal)),
+		zap.Time("saved_at", state.SavedAt))
+}
+
+// saveState writes the state when it changed or was not saved for a
+// while, failures are logged as tracking goes on in memory
+func (t *IPTracker) saveState(changed bool, now time.Time) {
+	if t.config.StateFile == "" || (!changed && now.Sub(t.savedAt) < stateSaveInterval) {
+		return
+	}
+
+	state := trackerState{
+		Interfaces: make(map[string]*interfaceState, len(t.lastState)),
+		External:   t.lastExternal,
+		SavedAt:    now,
+	}
+	for name, s := range t.lastState {
+		state.Interfaces[name] = &interfaceState{
+			IPv4Addrs: s.IPv4Addrs,
+			IPv6Addrs: s.IPv6Addrs,
+			UpdatedAt: s.UpdatedAt,
+			LastSeen:  t.lastSeen[name],
+		}
+	}
+
+	if err := writeState(t.config.StateFile, &state); err != nil {
+		t.logger.Warn("Failed to save IP tracker state", zap.Error(err))
+		return
+	}
+	t.savedAt = now
+}
+
+// writeState writes state to path, replacing it atomically
+func writeState(path string, state *trackerState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
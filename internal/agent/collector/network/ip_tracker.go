@@ -1,24 +1,37 @@
 package network
 
 import (
+	"context"
+	"maps"
 	"sort"
 	"sync"
 	"time"
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
+	"wameter/internal/clock"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
 )
 
+// externalRemoveAfter is how many lookups of an external address have to
+// fail in a row before it is considered removed, single failures are
+// usually provider outages
+const externalRemoveAfter = 3
+
 // IPTracker tracks IP address changes
 type IPTracker struct {
 	mu           sync.RWMutex
 	lastState    map[string]*types.IPState  // interface -> IP state
 	lastExternal map[types.IPVersion]string // version -> external IP
+	missedChecks map[types.IPVersion]int    // version -> failed external lookups in a row
 	lastSeen     map[string]time.Time       // interface -> last seen time
 	config       *config.IPTrackerConfig
 	logger       *zap.Logger
 	metrics      *IPTrackerMetrics
+	flaps        *flapDetector
+	savedAt      time.Time // last write of the state file
+	clock        clock.Clock
 }
 
 // IPTrackerMetrics represents tracking metrics
@@ -33,13 +46,17 @@ type IPTrackerMetrics struct {
 	DroppedChanges   int64
 	ExternalChecks   int64
 	ExternalFailures int64
+	FlappingCount    int
 }
 
-// NewIPTracker creates new IP tracker
-func NewIPTracker(cfg *config.IPTrackerConfig, logger *zap.Logger) *IPTracker {
+// NewIPTracker creates new IP tracker, clk is the system clock when nil
+func NewIPTracker(cfg *config.IPTrackerConfig, logger *zap.Logger, clk clock.Clock) *IPTracker {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	if clk == nil {
+		clk = clock.Real
+	}
 
 	// Set defaults if not specified
 	if cfg.CleanupInterval == 0 {
@@ -58,24 +75,38 @@ func NewIPTracker(cfg *config.IPTrackerConfig, logger *zap.Logger) *IPTracker {
 		cfg.ExternalCheckTTL = 5 * time.Minute
 	}
 
+	if cfg.FlapDetection == nil {
+		cfg.FlapDetection = &config.FlapDetectionConfig{}
+	}
+
 	t := &IPTracker{
 		lastState:    make(map[string]*types.IPState),
 		lastExternal: make(map[types.IPVersion]string),
+		missedChecks: make(map[types.IPVersion]int),
 		lastSeen:     make(map[string]time.Time),
 		config:       cfg,
 		logger:       logger,
 		metrics: &IPTrackerMetrics{
-			WindowStartTime: time.Now(),
+			WindowStartTime: clk.Now(),
 		},
+		clock: clk,
+	}
+	if cfg.FlapDetection.Enabled {
+		t.flaps = newFlapDetector(cfg.FlapDetection, logger)
 	}
 
+	t.loadState()
+
 	// Start cleanup goroutine
-	go t.cleanupLoop()
+	go crash.Supervise(context.Background(), "network.ip_tracker", func(context.Context) {
+		t.cleanupLoop()
+	})
 
 	return t
 }
 
-// Track checks for and returns IP changes
+// Track checks for and returns IP changes. externalIPs holds the external
+// address of each version looked up, empty when the lookup failed.
 func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs map[types.IPVersion]string) []types.IPChange {
 	if interfaceState == nil {
 		t.logger.Error("Received nil interface state")
@@ -86,7 +117,8 @@ func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs
 	defer t.mu.Unlock()
 
 	var changes []types.IPChange
-	now := time.Now()
+	changed := false
+	now := t.clock.Now()
 
 	// Check rate limit
 	if t.isRateLimited() {
@@ -98,6 +130,7 @@ func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs
 	}
 
 	// Track external IP changes
+	prevExternal := maps.Clone(t.lastExternal)
 	if len(externalIPs) > 0 {
 		changes = append(changes, t.trackExternalChanges(externalIPs, now)...)
 	}
@@ -109,6 +142,9 @@ func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs
 
 		// Get or create last state
 		lastState, exists := t.lastState[ifaceName]
+		if !exists || !equalIPs(lastState.IPv4Addrs, state.IPv4Addrs) || !equalIPs(lastState.IPv6Addrs, state.IPv6Addrs) {
+			changed = true
+		}
 		if !exists {
 			if t.config.NotifyOnFirstSeen {
 				if t.config.EnableIPv4 && len(state.IPv4Addrs) > 0 {
@@ -200,6 +236,7 @@ func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs
 				}
 				delete(t.lastState, name)
 				delete(t.lastSeen, name)
+				changed = true
 			}
 		}
 	}
@@ -209,23 +246,53 @@ func (t *IPTracker) Track(interfaceState map[string]*types.IPState, externalIPs
 		t.lastState[ifaceName] = state
 	}
 
+	// Persist the state so that restarts do not report it again
+	t.saveState(changed || !maps.Equal(prevExternal, t.lastExternal), now)
+
 	// Update metrics
 	if len(changes) > 0 {
-		t.updateMetrics(func(m *IPTrackerMetrics) {
-			m.TotalChanges += int64(len(changes))
-			m.LastChangeTime = now
-			m.ChangesInWindow++
-		})
+		t.metrics.TotalChanges += int64(len(changes))
+		t.metrics.LastChangeTime = now
+		t.metrics.ChangesInWindow++
+	}
+
+	// Suppress changes of flapping interfaces
+	if t.flaps != nil {
+		changes = t.flaps.filter(changes, now)
+		t.metrics.FlappingCount = len(t.flaps.flapping)
 	}
 
 	return changes
 }
 
-// trackExternalChanges checks for external IP changes
+// trackExternalChanges checks for external IP changes, an address is
+// removed once its lookups failed externalRemoveAfter times in a row
 func (t *IPTracker) trackExternalChanges(externalIPs map[types.IPVersion]string, now time.Time) []types.IPChange {
 	var changes []types.IPChange
 
 	for version, ip := range externalIPs {
+		t.metrics.ExternalChecks++
+		if ip == "" {
+			t.metrics.ExternalFailures++
+			t.missedChecks[version]++
+
+			lastIP, exists := t.lastExternal[version]
+			if exists && t.missedChecks[version] >= externalRemoveAfter && t.config.NotifyOnRemoval {
+				changes = append(changes, types.IPChange{
+					Version:    version,
+					OldAddrs:   []string{lastIP},
+					NewAddrs:   nil,
+					IsExternal: true,
+					Timestamp:  now,
+					Action:     types.IPChangeActionRemove,
+					Reason:     "external_ip_removed",
+				})
+				delete(t.lastExternal, version)
+			}
+			continue
+		}
+		t.missedChecks[version] = 0
+
 		if lastIP, exists := t.lastExternal[version]; !exists {
 			// First time seeing external IP
 			if t.config.NotifyOnFirstSeen {
@@ -255,30 +322,12 @@ func (t *IPTracker) trackExternalChanges(externalIPs map[types.IPVersion]string,
 		t.lastExternal[version] = ip
 	}
 
-	// Check for removed external IPs
-	if t.config.NotifyOnRemoval {
-		for version, lastIP := range t.lastExternal {
-			if _, exists := externalIPs[version]; !exists {
-				changes = append(changes, types.IPChange{
-					Version:    version,
-					OldAddrs:   []string{lastIP},
-					NewAddrs:   nil,
-					IsExternal: true,
-					Timestamp:  now,
-					Action:     types.IPChangeActionRemove,
-					Reason:     "external_ip_removed",
-				})
-				delete(t.lastExternal, version)
-			}
-		}
-	}
-
 	return changes
 }
 
 // isRateLimited checks if change tracking is currently rate limited
 func (t *IPTracker) isRateLimited() bool {
-	now := time.Now()
+	now := t.clock.Now()
 
 	// Reset window if needed
 	if now.Sub(t.metrics.WindowStartTime) > t.config.ThresholdWindow {
@@ -291,7 +340,7 @@ func (t *IPTracker) isRateLimited() bool {
 
 // cleanupLoop periodically cleans up old state
 func (t *IPTracker) cleanupLoop() {
-	ticker := time.NewTicker(t.config.CleanupInterval)
+	ticker := t.clock.NewTicker(t.config.CleanupInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -304,7 +353,7 @@ func (t *IPTracker) cleanup() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	threshold := time.Now().Add(-t.config.RetentionPeriod)
+	threshold := t.clock.Now().Add(-t.config.RetentionPeriod)
 
 	for ifaceName, lastSeen := range t.lastSeen {
 		if lastSeen.Before(threshold) {
@@ -341,13 +390,6 @@ func equalIPs(a, b []string) bool {
 	return true
 }
 
-// Update metrics safely
-func (t *IPTracker) updateMetrics(fn func(*IPTrackerMetrics)) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	fn(t.metrics)
-}
-
 // GetMetrics returns current metrics
 func (t *IPTracker) GetMetrics() *IPTrackerMetrics {
 	t.mu.RLock()
@@ -365,8 +407,12 @@ func (t *IPTracker) Reset() {
 
 	t.lastState = make(map[string]*types.IPState)
 	t.lastExternal = make(map[types.IPVersion]string)
+	t.missedChecks = make(map[types.IPVersion]int)
 	t.lastSeen = make(map[string]time.Time)
 	t.metrics = &IPTrackerMetrics{
-		WindowStartTime: time.Now(),
+		WindowStartTime: t.clock.Now(),
+	}
+	if t.flaps != nil {
+		t.flaps = newFlapDetector(t.config.FlapDetection, t.logger)
 	}
 }
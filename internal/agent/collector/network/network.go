@@ -3,13 +3,8 @@ package network
 import (
 	"context"
 	"fmt"
-	"io"
 	"net"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 	"wameter/internal/agent/notify"
@@ -17,6 +12,7 @@ import (
 	"wameter/internal/version"
 
 	"wameter/internal/agent/config"
+	"wameter/internal/clock"
 	"wameter/internal/types"
 	"wameter/internal/utils"
 
@@ -28,44 +24,53 @@ type networkCollector struct {
 	standalone bool
 	config     *config.NetworkConfig
 	agentID    string
+	hostname   string
 	logger     *zap.Logger
 	stats      *statsCollector
 	ipTracker  *IPTracker
+	routes     *routeTracker
+	external   map[types.IPVersion]*externalProviders
+	filter     *interfaceFilter
 	reporter   *reporter.Reporter
 	notifier   *notify.Manager
 	lastState  *types.NetworkState
 	mu         sync.RWMutex
-	client     *http.Client
 	wg         sync.WaitGroup
 }
 
 // NewCollector creates new network collector
-func NewCollector(cfg *config.NetworkConfig, agentID string, reporter *reporter.Reporter, notifier *notify.Manager, standalone bool, logger *zap.Logger) *networkCollector {
+func NewCollector(cfg *config.NetworkConfig, agentID, hostname string, reporter *reporter.Reporter, notifier *notify.Manager, standalone bool, logger *zap.Logger) *networkCollector {
 	if cfg.IPTracker == nil {
 		cfg.IPTracker = config.IPtrackerDefaultConfig()
 	}
+	if cfg.ExternalConsensus == nil {
+		cfg.ExternalConsensus = config.ExternalConsensusDefaultConfig(len(cfg.ExternalProviders))
+	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  true,
-			DisableKeepAlives:   false,
-			MaxIdleConnsPerHost: 10,
-		},
+	// IPv4 and IPv6 external addresses are looked up independently
+	external := make(map[types.IPVersion]*externalProviders)
+	if len(cfg.ExternalProviders) > 0 {
+		external[types.IPv4] = newExternalProviders(types.IPv4, cfg.ExternalProviders, cfg.ExternalConsensus, cfg.HTTP, cfg.Proxy, logger)
+	}
+	if len(cfg.ExternalIPv6) > 0 {
+		external[types.IPv6] = newExternalProviders(types.IPv6, cfg.ExternalIPv6, cfg.ExternalConsensus, cfg.HTTP, cfg.Proxy, logger)
 	}
 
+	filter := newInterfaceFilter(cfg)
+
 	return &networkCollector{
 		config:     cfg,
 		agentID:    agentID,
+		hostname:   hostname,
 		logger:     logger,
-		ipTracker:  NewIPTracker(cfg.IPTracker, logger),
+		ipTracker:  NewIPTracker(cfg.IPTracker, logger, clock.Real),
+		routes:     newRouteTracker(cfg.IPTracker.FlapDetection, logger, clock.Real),
+		external:   external,
+		filter:     filter,
 		reporter:   reporter,
 		notifier:   notifier,
 		standalone: standalone,
-		stats:      newStatsCollector(cfg, logger),
-		client:     client,
+		stats:      newStatsCollector(cfg, filter, logger),
 	}
 }
 
@@ -109,8 +114,8 @@ func (c *networkCollector) Stop() error {
 	}
 
 	// Cleanup HTTP client resources
-	if transport, ok := c.client.Transport.(*http.Transport); ok {
-		transport.CloseIdleConnections()
+	for _, e := range c.external {
+		e.Close()
 	}
 
 	return nil
@@ -122,10 +127,7 @@ func (c *networkCollector) Collect(ctx context.Context) (*types.MetricsData, err
 		return nil, nil
 	}
 
-	hostname, err := os.Hostname()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get hostname: %w", err)
-	}
+	hostname := c.hostname
 
 	state := &types.NetworkState{
 		Interfaces: make(map[string]*types.InterfaceInfo),
@@ -136,12 +138,23 @@ func (c *networkCollector) Collect(ctx context.Context) (*types.MetricsData, err
 		return nil, fmt.Errorf("failed to collect interface info: %w", err)
 	}
 
-	// Collect external IP if enabled
+	// Collect external IPs if enabled
+	var externalIPs map[types.IPVersion]string
 	if c.config.CheckExternalIP {
-		if ip, err := c.getExternalIP(ctx); err == nil {
-			state.ExternalIP = ip
-		} else {
-			c.logger.Warn("Failed to get external IP", zap.Error(err))
+		externalIPs = c.collectExternalIPs(ctx)
+		for _, version := range []types.IPVersion{types.IPv4, types.IPv6} {
+			if ip := externalIPs[version]; ip != "" {
+				if state.ExternalIPs == nil {
+					state.ExternalIPs = make(map[types.IPVersion]string)
+				}
+				state.ExternalIPs[version] = ip
+				if state.ExternalIP == "" {
+					state.ExternalIP = ip
+				}
+			}
+			if e, ok := c.external[version]; ok {
+				state.ExternalProviders = append(state.ExternalProviders, e.Stats()...)
+			}
 		}
 	}
 
@@ -157,6 +170,9 @@ func (c *networkCollector) Collect(ctx context.Context) (*types.MetricsData, err
 	if c.ipTracker != nil && len(state.Interfaces) > 0 {
 		ifaceStates := make(map[string]*types.IPState)
 		for name, iface := range state.Interfaces {
+			if !c.filter.tracksIPs(name) {
+				continue
+			}
 			ipState := &types.IPState{
 				IPv4Addrs: iface.IPv4,
 				IPv6Addrs: iface.IPv6,
@@ -165,23 +181,22 @@ func (c *networkCollector) Collect(ctx context.Context) (*types.MetricsData, err
 			ifaceStates[name] = ipState
 		}
 
-		externalIPs := make(map[types.IPVersion]string)
-		if state.ExternalIP != "" {
-			if ip := net.ParseIP(state.ExternalIP); ip != nil {
-				if ip.To4() != nil {
-					externalIPs[types.IPv4] = state.ExternalIP
-				} else {
-					externalIPs[types.IPv6] = state.ExternalIP
-				}
-			}
-		}
-
 		if changes := c.ipTracker.Track(ifaceStates, externalIPs); len(changes) > 0 {
 			state.IPChanges = changes
-			c.handleIPChanges(changes)
 		}
 	}
 
+	// Collect routes and gateways if enabled
+	if c.config.MonitorRoutes {
+		if changes := c.collectRoutes(state); len(changes) > 0 {
+			state.IPChanges = append(state.IPChanges, changes...)
+		}
+	}
+
+	if len(state.IPChanges) > 0 {
+		c.handleIPChanges(state.IPChanges)
+	}
+
 	c.mu.Lock()
 	c.lastState = state
 	c.mu.Unlock()
@@ -211,7 +226,7 @@ func (c *networkCollector) collectInterfaces(state *types.NetworkState) error {
 
 	for _, iface := range interfaces {
 		// Skip interfaces based on configuration
-		if !c.shouldMonitorInterface(iface) {
+		if !c.filter.monitors(iface) {
 			continue
 		}
 
@@ -283,162 +298,34 @@ func (c *networkCollector) collectInterfaces(state *types.NetworkState) error {
 	return nil
 }
 
-// shouldMonitorInterface returns true if the interface should be monitored
-func (c *networkCollector) shouldMonitorInterface(iface net.Interface) bool {
-	// Skip interfaces that are not up
-	if iface.Flags&net.FlagUp == 0 {
-		return false
-	}
-
-	// Skip loopback interfaces
-	if iface.Flags&net.FlagLoopback != 0 {
-		return false
-	}
-
-	// If specific interfaces are configured, only monitor those
-	if len(c.config.Interfaces) > 0 {
-		found := false
-		for _, name := range c.config.Interfaces {
-			if name == iface.Name {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
-	}
-
-	// Check exclusion patterns
-	for _, pattern := range c.config.ExcludePatterns {
-		if matched, _ := filepath.Match(pattern, iface.Name); matched {
-			return false
-		}
-	}
-
-	// Skip virtual interfaces unless explicitly enabled
-	if !c.config.IncludeVirtual && utils.IsVirtualInterface(iface.Name) {
-		return false
-	}
-
-	return true
-}
-
-// result represents the result of an external IP query
-type result struct {
-	provider string
-	ip       string
-	err      error
-}
-
-// getExternalIP attempts to get the external IP using configured providers
-func (c *networkCollector) getExternalIP(ctx context.Context) (string, error) {
-	if len(c.config.ExternalProviders) == 0 {
-		return "", fmt.Errorf("no external IP providers configured")
-	}
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	results := make(chan result, len(c.config.ExternalProviders))
-	var wg sync.WaitGroup
-
-	// Query all providers concurrently
-	for _, provider := range c.config.ExternalProviders {
-		wg.Add(1)
-		go func(p string) {
-			defer wg.Done()
-			ip, err := c.queryExternalProvider(ctx, p)
-			select {
-			case results <- result{p, ip, err}:
-			case <-ctx.Done():
-			}
-		}(provider)
-	}
-
-	// Close results channel after all goroutines finish
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Use map to track IP consensus
-	ips := make(map[string]int)
-	var lastErr error
-
-	for r := range results {
-		if r.err != nil {
-			lastErr = r.err
-			continue
-		}
-		ips[r.ip]++
-		if count := ips[r.ip]; count >= 2 {
-			return r.ip, nil
-		}
-	}
-
-	// Return most reported IP if no consensus
-	if len(ips) > 0 {
-		var mostReportedIP string
-		maxCount := 0
-		for ip, count := range ips {
-			if count > maxCount {
-				mostReportedIP = ip
-				maxCount = count
-			}
-		}
-		return mostReportedIP, nil
-	}
-
-	return "", fmt.Errorf("failed to get external IP: %v", lastErr)
-}
-
-// queryExternalProvider queries single external IP provider
-func (c *networkCollector) queryExternalProvider(ctx context.Context, provider string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
-	req.Header.Set("Accept", "text/plain")
-
-	resp, err := c.client.Do(req)
+// collectRoutes collects routes of monitored interfaces and returns gateway and route changes
+func (c *networkCollector) collectRoutes(state *types.NetworkState) []types.IPChange {
+	routes, err := readRoutes()
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		c.logger.Warn("Failed to read route table", zap.Error(err))
+		return nil
 	}
 
-	defer func(Body io.ReadCloser) {
-		if err := Body.Close(); err != nil {
-			c.logger.Error("Failed to close response body", zap.Error(err))
+	for _, route := range routes {
+		if _, ok := state.Interfaces[route.Interface]; ok {
+			state.Routes = append(state.Routes, route)
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("provider returned status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
-	ip := strings.TrimSpace(string(body))
-	if !utils.IsValidIP(ip) {
-		return "", fmt.Errorf("invalid IP address: %s", ip)
+	// The gateway of the default route tracked for changes
+	state.Gateways = make(map[types.IPVersion]string)
+	for version, route := range defaultRoutes(state.Routes) {
+		if route.Gateway != "" {
+			state.Gateways[version] = route.Gateway
+		}
 	}
 
-	return ip, nil
+	return c.routes.Track(state.Routes)
 }
 
 // handleIPChanges handles IP address changes
 func (c *networkCollector) handleIPChanges(changes []types.IPChange) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		c.logger.Error("Failed to get hostname", zap.Error(err))
-		hostname = "unknown"
-	}
+	hostname := c.hostname
 
 	agent := &types.AgentInfo{
 		ID:       c.agentID,
@@ -0,0 +1,326 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"wameter/internal/agent/config"
+	"wameter/internal/clock"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// routeTracker tracks default gateway and route table changes
+type routeTracker struct {
+	lastGateways map[types.IPVersion]types.RouteInfo
+	lastRoutes   map[string]types.RouteInfo // route key -> route
+	initialized  bool
+	flaps        *flapDetector
+	clock        clock.Clock
+}
+
+// newRouteTracker creates new route tracker, gateway changes are filtered
+// for flapping as configured for IP changes
+func newRouteTracker(cfg *config.FlapDetectionConfig, logger *zap.Logger, clk clock.Clock) *routeTracker {
+	t := &routeTracker{
+		lastGateways: make(map[types.IPVersion]types.RouteInfo),
+		lastRoutes:   make(map[string]types.RouteInfo),
+		clock:        clk,
+	}
+	if cfg != nil && cfg.Enabled {
+		t.flaps = newFlapDetector(cfg, logger)
+	}
+	return t
+}
+
+// defaultRoutes returns the default route of each IP version, the one with
+// the lowest metric, the first in table order among equal metrics
+func defaultRoutes(routes []types.RouteInfo) map[types.IPVersion]types.RouteInfo {
+	gateways := make(map[types.IPVersion]types.RouteInfo)
+	for _, route := range routes {
+		if !route.IsDefault() {
+			continue
+		}
+		if gw, ok := gateways[route.Version]; !ok || route.Metric < gw.Metric {
+			gateways[route.Version] = route
+		}
+	}
+	return gateways
+}
+
+// Track checks for and returns gateway changes and route removals
+func (t *routeTracker) Track(routes []types.RouteInfo) []types.IPChange {
+	gateways := defaultRoutes(routes)
+	current := make(map[string]types.RouteInfo)
+	for _, route := range routes {
+		if !route.IsDefault() {
+			current[routeKey(route)] = route
+		}
+	}
+
+	defer func() {
+		t.lastGateways = gateways
+		t.lastRoutes = current
+		t.initialized = true
+	}()
+
+	if !t.initialized {
+		return nil
+	}
+
+	var changes []types.IPChange
+	now := t.clock.Now()
+
+	// Check default gateway changes
+	for _, version := range []types.IPVersion{types.IPv4, types.IPv6} {
+		old, hadOld := t.lastGateways[version]
+		gw, hasNew := gateways[version]
+
+		switch {
+		case hadOld && hasNew && (old.Gateway != gw.Gateway || old.Interface != gw.Interface):
+			changes = append(changes, types.IPChange{
+				InterfaceName: gw.Interface,
+				Version:       version,
+				OldAddrs:      []string{old.Gateway},
+				NewAddrs:      []string{gw.Gateway},
+				Timestamp:     now,
+				Action:        types.IPChangeActionUpdate,
+				Reason:        "gateway_changed",
+			})
+		case hadOld && !hasNew:
+			changes = append(changes, types.IPChange{
+				InterfaceName: old.Interface,
+				Version:       version,
+				OldAddrs:      []string{old.Gateway},
+				Timestamp:     now,
+				Action:        types.IPChangeActionRemove,
+				Reason:        "gateway_removed",
+			})
+		case !hadOld && hasNew:
+			changes = append(changes, types.IPChange{
+				InterfaceName: gw.Interface,
+				Version:       version,
+				NewAddrs:      []string{gw.Gateway},
+				Timestamp:     now,
+				Action:        types.IPChangeActionAdd,
+				Reason:        "gateway_added",
+			})
+		}
+	}
+
+	// Suppress gateway changes of flapping interfaces
+	if t.flaps != nil {
+		changes = t.flaps.filter(changes, now)
+	}
+
+	// Check removed routes, not filtered as an interface going down removes
+	// all its routes at once
+	for key, route := range t.lastRoutes {
+		if _, exists := current[key]; exists {
+			continue
+		}
+		changes = append(changes, types.IPChange{
+			InterfaceName: route.Interface,
+			Version:       route.Version,
+			OldAddrs:      []string{formatRoute(route)},
+			Timestamp:     now,
+			Action:        types.IPChangeActionRemove,
+			Reason:        "route_removed",
+		})
+	}
+
+	return changes
+}
+
+// routeKey returns the tracking key for a route
+func routeKey(route types.RouteInfo) string {
+	return fmt.Sprintf("%s|%s|%s", route.Interface, route.Destination, route.Gateway)
+}
+
+// formatRoute returns a human-readable route description
+func formatRoute(route types.RouteInfo) string {
+	if route.Gateway == "" {
+		return route.Destination
+	}
+	return route.Destination + " via " + route.Gateway
+}
+
+// readRoutes reads the system route table
+func readRoutes() ([]types.RouteInfo, error) {
+	if !utils.IsLinux() {
+		return nil, fmt.Errorf("route monitoring is only supported on Linux")
+	}
+
+	routes, err := readIPv4Routes(utils.ProcNetPath("route"))
+	if err != nil {
+		return nil, err
+	}
+
+	// IPv6 may be disabled on the host
+	if v6, err := readIPv6Routes(utils.ProcNetPath("ipv6_route")); err == nil {
+		routes = append(routes, v6...)
+	}
+
+	return routes, nil
+}
+
+// defaultGateway returns the IPv4 default gateway with the lowest metric
+func defaultGateway() (string, error) {
+	routes, err := readRoutes()
+	if err != nil {
+		return "", err
+	}
+
+	var gateway *types.RouteInfo
+	for i, route := range routes {
+		if route.Version == types.IPv4 && route.IsDefault() && route.Gateway != "" &&
+			(gateway == nil || route.Metric < gateway.Metric) {
+			gateway = &routes[i]
+		}
+	}
+	if gateway == nil {
+		return "", fmt.Errorf("no IPv4 default gateway")
+	}
+	return gateway.Gateway, nil
+}
+
+// readIPv4Routes parses /proc/net/route
+func readIPv4Routes(path string) ([]types.RouteInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open route table: %w", err)
+	}
+	defer file.Close()
+
+	var routes []types.RouteInfo
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // Skip header
+
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+
+		dest, err := parseHexIPv4(fields[1])
+		if err != nil {
+			continue
+		}
+		gateway, err := parseHexIPv4(fields[2])
+		if err != nil {
+			continue
+		}
+		mask, err := parseHexIPv4(fields[7])
+		if err != nil {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+
+		ones, _ := net.IPMask(mask.To4()).Size()
+		route := types.RouteInfo{
+			Interface:   fields[0],
+			Version:     types.IPv4,
+			Destination: fmt.Sprintf("%s/%d", dest.String(), ones),
+			Metric:      metric,
+		}
+		if !gateway.IsUnspecified() {
+			route.Gateway = gateway.String()
+		}
+		routes = append(routes, route)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read route table: %w", err)
+	}
+
+	return routes, nil
+}
+
+// readIPv6Routes parses /proc/net/ipv6_route
+func readIPv6Routes(path string) ([]types.RouteInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open route table: %w", err)
+	}
+	defer file.Close()
+
+	var routes []types.RouteInfo
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// dest dest_len src src_len next_hop metric refcnt use flags iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		iface := fields[9]
+		if iface == "lo" {
+			continue
+		}
+
+		dest, err := parseHexIPv6(fields[0])
+		if err != nil {
+			continue
+		}
+		destLen, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			continue
+		}
+		nextHop, err := parseHexIPv6(fields[4])
+		if err != nil {
+			continue
+		}
+		metric, _ := strconv.ParseUint(fields[5], 16, 32)
+
+		// Skip link-local and multicast routes
+		if dest.IsLinkLocalUnicast() || dest.IsMulticast() {
+			continue
+		}
+
+		route := types.RouteInfo{
+			Interface:   iface,
+			Version:     types.IPv6,
+			Destination: fmt.Sprintf("%s/%d", dest.String(), destLen),
+			Metric:      int(metric),
+		}
+		if !nextHop.IsUnspecified() {
+			route.Gateway = nextHop.String()
+		}
+		routes = append(routes, route)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read route table: %w", err)
+	}
+
+	return routes, nil
+}
+
+// parseHexIPv4 parses a little-endian hex encoded IPv4 address
+func parseHexIPv4(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return nil, fmt.Errorf("invalid IPv4 hex address: %s", s)
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	return ip, nil
+}
+
+// parseHexIPv6 parses a hex encoded IPv6 address
+func parseHexIPv6(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IPv6 hex address: %s", s)
+	}
+	return net.IP(b), nil
+}
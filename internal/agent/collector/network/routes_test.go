@@ -0,0 +1,62 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"wameter/internal/agent/config"
+	"wameter/internal/clock"
+	"wameter/internal/types"
+)
+
+// TestRouteFlapDetection tests that gateway changes are filtered for
+// flapping like IP changes, and route removals are not
+func TestRouteFlapDetection(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	tracker := newRouteTracker(&config.FlapDetectionConfig{
+		Enabled:     true,
+		Threshold:   3,
+		Window:      10 * time.Minute,
+		StableAfter: 15 * time.Minute,
+	}, zap.NewNop(), fake)
+
+	routes := func(gateway string) []types.RouteInfo {
+		return []types.RouteInfo{
+			{Interface: "eth0", Version: types.IPv4, Destination: "0.0.0.0/0", Gateway: gateway},
+			{Interface: "eth0", Version: types.IPv4, Destination: "10.0.0.0/8", Gateway: gateway},
+		}
+	}
+	track := func(gateway string) []types.IPChange {
+		fake.Advance(time.Minute)
+		return tracker.Track(routes(gateway))
+	}
+
+	assert.Empty(t, track("192.0.2.1"))
+
+	// The gateway changes with the route via it
+	changes := track("192.0.2.254")
+	require.Len(t, changes, 2)
+	assert.Equal(t, "gateway_changed", changes[0].Reason)
+	assert.Equal(t, "route_removed", changes[1].Reason)
+	assert.Len(t, track("192.0.2.1"), 2)
+
+	// The third change in the window starts flapping
+	changes = track("192.0.2.254")
+	require.Len(t, changes, 2)
+	assert.Equal(t, types.IPChangeActionFlapping, changes[0].Action)
+	assert.Equal(t, "route_removed", changes[1].Reason)
+
+	changes = track("192.0.2.1")
+	require.Len(t, changes, 1, "gateway change suppressed while flapping")
+	assert.Equal(t, "route_removed", changes[0].Reason)
+
+	fake.Advance(15 * time.Minute)
+	changes = track("192.0.2.1")
+	require.Len(t, changes, 1)
+	assert.Equal(t, types.IPChangeActionStable, changes[0].Action)
+	assert.Equal(t, []string{"192.0.2.1"}, changes[0].NewAddrs)
+}
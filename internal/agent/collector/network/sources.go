@@ -0,0 +1,548 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// ipSource represents a method of detecting the external IP
+type ipSource interface {
+	Lookup(ctx context.Context) (string, error)
+}
+
+// Well known DNS sources of the external IP, "dns:<name>@<server>" queries
+// other servers
+var dnsSources = map[string]dnsSource{
+	"opendns": {name: "myip.opendns.com", server: "resolver1.opendns.com:53"},
+	"google":  {name: "o-o.myaddr.l.google.com", server: "ns1.google.com:53", txt: true},
+}
+
+const (
+	stunDefaultPort = "3478"
+	stunMagicCookie = 0x2112A442
+	natpmpPort      = "5351"
+	ssdpAddr        = "239.255.255.250:1900"
+)
+
+// newSource creates the source of a provider detecting the address of
+// version, all queries go over that version. Providers are HTTP(S) URLs
+// answering with the IP as text, or one of
+//
+//	stun:<host>[:port]     STUN binding request
+//	dns:opendns            DNS query of myip.opendns.com
+//	dns:google             DNS TXT query of o-o.myaddr.l.google.com
+//	dns:<name>@<server>    DNS A, then TXT, query of name at server
+//	upnp:                  UPnP IGD discovered on the local network, IPv4 only
+//	natpmp:[gateway]       NAT-PMP, the default gateway when omitted, IPv4 only
+func newSource(provider string, version types.IPVersion, client *http.Client, logger *zap.Logger) (ipSource, error) {
+	scheme, rest, _ := strings.Cut(provider, ":")
+	rest = strings.TrimPrefix(rest, "//")
+
+	// Suffix of networks restricted to version
+	family := "4"
+	if version == types.IPv6 {
+		family = "6"
+		if scheme == "upnp" || scheme == "natpmp" {
+			return nil, fmt.Errorf("%s only detects IPv4 addresses", scheme)
+		}
+	}
+
+	switch scheme {
+	case "http", "https":
+		return &httpSource{url: provider, client: client, logger: logger}, nil
+	case "stun":
+		if rest == "" {
+			return nil, fmt.Errorf("stun server is required")
+		}
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			rest = net.JoinHostPort(rest, stunDefaultPort)
+		}
+		return &stunSource{server: rest, family: family}, nil
+	case "dns":
+		if src, ok := dnsSources[rest]; ok {
+			src.family = family
+			return &src, nil
+		}
+		name, server, ok := strings.Cut(rest, "@")
+		if !ok || name == "" || server == "" {
+			return nil, fmt.Errorf("dns source must be opendns, google or <name>@<server>")
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		return &dnsSource{name: name, server: server, family: family, fallbackTXT: true}, nil
+	case "upnp":
+		// The gateway is on the local network, never behind the proxy
+		direct := &http.Client{Timeout: client.Timeout, Transport: &http.Transport{}}
+		return &upnpSource{client: direct}, nil
+	case "natpmp":
+		return &natpmpSource{gateway: rest}, nil
+	default:
+		return nil, fmt.Errorf("unsupported external IP source: %s", provider)
+	}
+}
+
+// httpSource queries an HTTP endpoint answering with the IP as text
+type httpSource struct {
+	url    string
+	client *http.Client
+	logger *zap.Logger
+}
+
+// Lookup implements ipSource
+func (s *httpSource) Lookup(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			s.logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	ip := strings.TrimSpace(string(body))
+	if !utils.IsValidIP(ip) && !utils.IsValidIP(ip, true) {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	return ip, nil
+}
+
+// stunSource sends a STUN binding request (RFC 5389) and reads the mapped
+// address of the response
+type stunSource struct {
+	server string
+	family string
+}
+
+// Lookup implements ipSource
+func (s *stunSource) Lookup(ctx context.Context) (string, error) {
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:], 0x0001) // Binding request
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return "", fmt.Errorf("failed to create transaction id: %w", err)
+	}
+
+	resp, err := udpExchange(ctx, "udp"+s.family, s.server, req, func(resp []byte) bool {
+		return len(resp) >= 20 && bytes.Equal(resp[8:20], req[8:20])
+	})
+	if err != nil {
+		return "", fmt.Errorf("stun request failed: %w", err)
+	}
+
+	if msgType := binary.BigEndian.Uint16(resp[0:]); msgType != 0x0101 {
+		return "", fmt.Errorf("stun server returned message type %#04x", msgType)
+	}
+	ip := parseSTUNAddress(resp)
+	if ip == nil {
+		return "", fmt.Errorf("stun response has no mapped address")
+	}
+	return ip.String(), nil
+}
+
+// parseSTUNAddress returns the XOR-MAPPED-ADDRESS of a binding response, or
+// MAPPED-ADDRESS of servers predating RFC 5389
+func parseSTUNAddress(resp []byte) net.IP {
+	var mapped net.IP
+	attrs := resp[20:]
+	if n := int(binary.BigEndian.Uint16(resp[2:])); n < len(attrs) {
+		attrs = attrs[:n]
+	}
+
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:])
+		length := int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+length {
+			break
+		}
+		value := attrs[4 : 4+length]
+
+		if len(value) >= 8 {
+			var ip net.IP
+			switch value[1] {
+			case 0x01:
+				ip = net.IP(append([]byte(nil), value[4:8]...))
+			case 0x02:
+				if len(value) >= 20 {
+					ip = net.IP(append([]byte(nil), value[4:20]...))
+				}
+			}
+			switch {
+			case ip == nil:
+			case attrType == 0x0020:
+				// XOR with the magic cookie and transaction id
+				for i := range ip {
+					ip[i] ^= resp[4+i]
+				}
+				return ip
+			case attrType == 0x0001:
+				mapped = ip
+			}
+		}
+
+		// Attributes are padded to 4 bytes
+		attrs = attrs[min(len(attrs), 4+(length+3)&^3):]
+	}
+
+	return mapped
+}
+
+// dnsSource queries a name that resolves to the address of the client at
+// a server answering for it directly
+type dnsSource struct {
+	name        string
+	server      string
+	family      string
+	txt         bool // Answered with a TXT record
+	fallbackTXT bool // Try TXT when there is no address record
+}
+
+// Lookup implements ipSource
+func (s *dnsSource) Lookup(ctx context.Context) (string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network+s.family, s.server)
+		},
+	}
+
+	// Fully qualified, so no search domains are appended
+	name := s.name
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+
+	var answers []string
+	var err error
+	if !s.txt {
+		var ips []net.IP
+		ips, err = resolver.LookupIP(ctx, "ip"+s.family, name)
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	}
+	if s.txt || (err != nil && s.fallbackTXT && ctx.Err() == nil) {
+		answers, err = resolver.LookupTXT(ctx, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("dns query of %s failed: %w", s.name, err)
+	}
+
+	for _, answer := range answers {
+		if ip := strings.Trim(answer, "\" "); utils.IsValidIP(ip) || utils.IsValidIP(ip, true) {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("dns query of %s returned no IP address", s.name)
+}
+
+// upnpSource asks the UPnP internet gateway device of the local network
+type upnpSource struct {
+	client *http.Client
+
+	// Control endpoint of the discovered gateway, reset when it fails
+	controlURL  string
+	serviceType string
+	mu          sync.Mutex
+}
+
+// upnpService represents a service of a UPnP device description
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// Lookup implements ipSource
+func (s *upnpSource) Lookup(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.controlURL == "" {
+		if err := s.discover(ctx); err != nil {
+			return "", fmt.Errorf("upnp discovery failed: %w", err)
+		}
+	}
+
+	ip, err := s.externalIP(ctx)
+	if err != nil {
+		s.controlURL = ""
+		return "", fmt.Errorf("upnp request failed: %w", err)
+	}
+	if !isPublicIP(ip) {
+		return "", fmt.Errorf("upnp gateway reports non-public address %s", ip)
+	}
+	return ip.String(), nil
+}
+
+// discover finds the WAN connection service of the gateway
+func (s *upnpSource) discover(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return err
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		if err := s.describe(ctx, location); err == nil {
+			return nil
+		}
+	}
+}
+
+// describe reads the device description at location
+func (s *upnpSource) describe(ctx context.Context, location string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("no WAN connection service at %s", location)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "service" {
+			continue
+		}
+		var svc upnpService
+		if err := dec.DecodeElement(&svc, &start); err != nil {
+			return err
+		}
+		if !strings.Contains(svc.ServiceType, "WANIPConnection") &&
+			!strings.Contains(svc.ServiceType, "WANPPPConnection") {
+			continue
+		}
+		control, err := base.Parse(svc.ControlURL)
+		if err != nil {
+			return err
+		}
+		s.controlURL = control.String()
+		s.serviceType = svc.ServiceType
+		return nil
+	}
+}
+
+// externalIP calls GetExternalIPAddress of the WAN connection service
+func (s *upnpSource) externalIP(ctx context.Context) (net.IP, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + s.serviceType + `"></u:GetExternalIPAddress></s:Body>` +
+		`</s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.controlURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+s.serviceType+`#GetExternalIPAddress"`)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway returned status %d", resp.StatusCode)
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("response has no external IP address")
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "NewExternalIPAddress" {
+			continue
+		}
+		var value string
+		if err := dec.DecodeElement(&value, &start); err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %s", value)
+		}
+		return ip, nil
+	}
+}
+
+// natpmpSource asks the gateway for its public address with NAT-PMP (RFC 6886)
+type natpmpSource struct {
+	gateway string // Default gateway when empty
+}
+
+// Lookup implements ipSource
+func (s *natpmpSource) Lookup(ctx context.Context) (string, error) {
+	gateway := s.gateway
+	if gateway == "" {
+		gw, err := defaultGateway()
+		if err != nil {
+			return "", err
+		}
+		gateway = gw
+	}
+	if _, _, err := net.SplitHostPort(gateway); err != nil {
+		gateway = net.JoinHostPort(gateway, natpmpPort)
+	}
+
+	// Version 0, public address request
+	resp, err := udpExchange(ctx, "udp4", gateway, []byte{0, 0}, func(resp []byte) bool {
+		return len(resp) >= 12 && resp[0] == 0 && resp[1] == 128
+	})
+	if err != nil {
+		return "", fmt.Errorf("nat-pmp request failed: %w", err)
+	}
+	if code := binary.BigEndian.Uint16(resp[2:]); code != 0 {
+		return "", fmt.Errorf("nat-pmp gateway returned result code %d", code)
+	}
+
+	ip := net.IP(append([]byte(nil), resp[8:12]...))
+	if !isPublicIP(ip) {
+		return "", fmt.Errorf("nat-pmp gateway reports non-public address %s", ip)
+	}
+	return ip.String(), nil
+}
+
+// udpExchange sends req to addr until a response accepted by valid arrives,
+// retransmitting with doubling timeouts until ctx is done
+func udpExchange(ctx context.Context, network, addr string, req []byte, valid func([]byte) bool) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1500)
+	timeout := 250 * time.Millisecond
+	for {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		_ = conn.SetReadDeadline(deadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					return nil, err
+				}
+				break
+			}
+			if valid(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+			return nil, context.DeadlineExceeded
+		}
+		timeout = min(2*timeout, 2*time.Second)
+	}
+}
+
+// isPublicIP reports whether ip is routable on the internet, gateways behind
+// another NAT report private or shared addresses
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	// Carrier grade NAT, RFC 6598
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
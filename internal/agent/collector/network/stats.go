@@ -3,11 +3,11 @@ package network
 import (
 	"context"
 	"net"
-	"path/filepath"
 	"sync"
 	"time"
 
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
 	"wameter/internal/types"
 	"wameter/internal/utils"
 
@@ -17,6 +17,7 @@ import (
 // statsCollector represents stats collector implementation
 type statsCollector struct {
 	config    *config.NetworkConfig
+	filter    *interfaceFilter
 	logger    *zap.Logger
 	stats     map[string]*types.InterfaceStats
 	prevStats map[string]*types.InterfaceStats
@@ -24,9 +25,10 @@ type statsCollector struct {
 }
 
 // newStatsCollector creates new stats collector
-func newStatsCollector(cfg *config.NetworkConfig, logger *zap.Logger) *statsCollector {
+func newStatsCollector(cfg *config.NetworkConfig, filter *interfaceFilter, logger *zap.Logger) *statsCollector {
 	return &statsCollector{
 		config:    cfg,
+		filter:    filter,
 		logger:    logger,
 		stats:     make(map[string]*types.InterfaceStats),
 		prevStats: make(map[string]*types.InterfaceStats),
@@ -41,7 +43,7 @@ func (s *statsCollector) Start(ctx context.Context) error {
 	}
 
 	// Start collection loop
-	go func() {
+	go crash.Supervise(ctx, "network.stats", func(ctx context.Context) {
 		ticker := time.NewTicker(s.config.StatInterval)
 		defer ticker.Stop()
 
@@ -55,7 +57,7 @@ func (s *statsCollector) Start(ctx context.Context) error {
 				return
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -97,7 +99,7 @@ func (s *statsCollector) collect() error {
 
 	for _, iface := range interfaces {
 		// Skip interfaces based on configuration
-		if !shouldMonitorInterface(iface.Name, iface.Flags, s.config) {
+		if !s.filter.monitors(iface) || !s.filter.collectsStats(iface.Name) {
 			continue
 		}
 
@@ -115,12 +117,10 @@ func (s *statsCollector) collect() error {
 
 		// Calculate rates if we have previous stats
 		if prevStats, exists := s.prevStats[iface.Name]; exists {
-			duration := stats.CollectedAt.Sub(prevStats.CollectedAt).Seconds()
-			if duration > 0 {
-				stats.RxBytesRate = float64(stats.RxBytes-prevStats.RxBytes) / duration
-				stats.TxBytesRate = float64(stats.TxBytes-prevStats.TxBytes) / duration
-				stats.RxPacketsRate = float64(stats.RxPackets-prevStats.RxPackets) / duration
-				stats.TxPacketsRate = float64(stats.TxPackets-prevStats.TxPackets) / duration
+			utils.SetInterfaceRates(stats, prevStats)
+			if stats.CounterReset {
+				s.logger.Debug("Interface counters were reset",
+					zap.String("interface", iface.Name))
 			}
 		}
 
@@ -129,25 +129,3 @@ func (s *statsCollector) collect() error {
 
 	return nil
 }
-
-// shouldMonitorInterface returns true if the interface should be monitored
-func shouldMonitorInterface(name string, flags net.Flags, config *config.NetworkConfig) bool {
-	// Check exclusion patterns
-	for _, pattern := range config.ExcludePatterns {
-		if matched, _ := filepath.Match(pattern, name); matched {
-			return false
-		}
-	}
-
-	// Skip virtual interfaces unless explicitly enabled
-	if !config.IncludeVirtual && utils.IsVirtualInterface(name) {
-		return false
-	}
-
-	// Check if interface is up
-	if flags&net.FlagUp == 0 {
-		return false
-	}
-
-	return true
-}
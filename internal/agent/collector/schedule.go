@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"math/rand/v2"
+	"time"
+	"wameter/internal/agent/config"
+)
+
+// schedule represents when collections are due. Each collection has a slot,
+// every interval or at multiples of the interval offset by a phase when
+// aligned, and is delayed by a random jitter from its slot.
+type schedule struct {
+	interval time.Duration
+	jitter   time.Duration
+	align    bool
+	phase    time.Duration
+}
+
+// newSchedule creates the collection schedule of an agent
+func newSchedule(cfg *config.CollectorConfig, agentID string) *schedule {
+	s := &schedule{
+		interval: cfg.Interval,
+		jitter:   cfg.Jitter,
+		align:    cfg.Align,
+	}
+	if s.align {
+		s.phase = cfg.PhaseOf(agentID)
+	}
+	return s
+}
+
+// first returns the slot of the first collection after now
+func (s *schedule) first(now time.Time) time.Time {
+	if !s.align {
+		return now.Add(s.interval)
+	}
+	slot := now.Truncate(s.interval).Add(s.phase)
+	if slot.After(now) {
+		return slot
+	}
+	return s.next(slot, now, 1)
+}
+
+// next returns the slot following slot, with the interval stretched by
+// factor. Slots missed by now, e.g. while the host was suspended, are
+// skipped.
+func (s *schedule) next(slot, now time.Time, factor int) time.Time {
+	step := s.interval * time.Duration(factor)
+	slot = slot.Add(step)
+	if slot.After(now) {
+		return slot
+	}
+	return slot.Add((now.Sub(slot)/step + 1) * step)
+}
+
+// due returns when the collection of a slot is due
+func (s *schedule) due(slot time.Time) time.Time {
+	if s.jitter <= 0 {
+		return slot
+	}
+	return slot.Add(rand.N(s.jitter))
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// collectorState represents the run of a registered collector, restarted
+// when its collections stall
+type collectorState struct {
+	running atomic.Bool // A collection is in progress
+	stalls  atomic.Int64
+	mu      sync.Mutex
+	cancel  context.CancelFunc // Of the context the collector was started with
+}
+
+// startCollector starts a collector with a context of its own, canceled
+// when it is restarted
+func (m *Manager) startCollector(ctx context.Context, c Collector, state *collectorState) error {
+	ctx, cancel := context.WithCancel(ctx)
+	state.mu.Lock()
+	state.cancel = cancel
+	state.mu.Unlock()
+
+	if err := c.Start(ctx); err != nil {
+		cancel()
+		return err
+	}
+	return nil
+}
+
+// stallTimeout returns how long a collection may run before the collector
+// is considered stalled
+func (m *Manager) stallTimeout() time.Duration {
+	return m.config.Collector.Interval * time.Duration(m.IntervalFactor()*m.config.Collector.StallIntervals)
+}
+
+// stalled records a stall of a collector and restarts it. A collection still
+// blocked after it was canceled is abandoned, the collector is skipped
+// until it returns.
+func (m *Manager) stalled(name string, c Collector, state *collectorState, reason string) {
+	stalls := state.stalls.Add(1)
+	m.logger.Warn("Collector stalled, restarting it",
+		zap.String("name", name),
+		zap.String("reason", reason),
+		zap.Int64("stalls", stalls))
+
+	state.mu.Lock()
+	if state.cancel != nil {
+		state.cancel()
+	}
+	state.mu.Unlock()
+
+	if err := c.Stop(); err != nil {
+		m.logger.Error("Failed to stop stalled collector", zap.String("name", name), zap.Error(err))
+	}
+	if m.ctx == nil || m.ctx.Err() != nil {
+		return
+	}
+	if err := m.startCollector(m.ctx, c, state); err != nil {
+		m.logger.Error("Failed to restart stalled collector", zap.String("name", name), zap.Error(err))
+	}
+}
+
+// Stalls returns the number of stalls of each collector since the agent
+// started, collectors that never stalled are omitted
+func (m *Manager) Stalls() map[string]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stalls map[string]int64
+	for name, state := range m.states {
+		if n := state.stalls.Load(); n > 0 {
+			if stalls == nil {
+				stalls = make(map[string]int64)
+			}
+			stalls[name] = n
+		}
+	}
+	return stalls
+}
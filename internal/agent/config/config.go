@@ -1,12 +1,22 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"hash/fnv"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"wameter/internal/compress"
 	"wameter/internal/config"
 	"wameter/internal/retry"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
 	"wameter/internal/utils"
 
 	"github.com/spf13/viper"
@@ -14,31 +24,205 @@ import (
 
 // Config represents agent configuration
 type Config struct {
-	Agent     AgentConfig          `mapstructure:"agent"`
-	Collector CollectorConfig      `mapstructure:"collector"`
-	Notify    *config.NotifyConfig `mapstructure:"notify"`
-	Log       *config.LogConfig    `mapstructure:"log"`
-	Retry     *retry.Config        `mapstructure:"retry"`
+	Agent     AgentConfig           `mapstructure:"agent"`
+	Collector CollectorConfig       `mapstructure:"collector"`
+	Notify    *config.NotifyConfig  `mapstructure:"notify"`
+	Log       *config.LogConfig     `mapstructure:"log"`
+	Retry     *retry.Config         `mapstructure:"retry"`
+	Secrets   *config.SecretsConfig `mapstructure:"secrets"`
+	Debug     *config.DebugConfig   `mapstructure:"debug"`
+	Tracing   *config.TracingConfig `mapstructure:"tracing"`
+	// Proxy of outbound HTTP requests, overridden by the proxy of the server,
+	// network collector and notify sections
+	Proxy *config.ProxyConfig `mapstructure:"proxy"`
+	// Timeouts and TLS trust of outbound HTTP requests, the notify section
+	// may override them
+	HTTP *config.HTTPClientConfig `mapstructure:"http"`
 }
 
 // AgentConfig represents agent configuration
 type AgentConfig struct {
-	ID         string       `mapstructure:"id"`
-	Hostname   string       `mapstructure:"hostname"`
-	Port       int          `mapstructure:"port"`
-	Server     ServerConfig `mapstructure:"server"`
-	Standalone bool         `mapstructure:"standalone"`
-	Heartbeat  struct {
+	ID            string       `mapstructure:"id"`
+	Hostname      string       `mapstructure:"hostname"`
+	Port          int          `mapstructure:"port"`
+	Server        ServerConfig `mapstructure:"server"`
+	Standalone    bool         `mapstructure:"standalone"`
+	DataDir       string       `mapstructure:"data_dir"`        // State such as the persisted identity
+	AllowIDChange bool         `mapstructure:"allow_id_change"` // Accept an ID differing from the persisted one
+	Heartbeat     struct {
 		Interval    time.Duration `mapstructure:"interval"`
 		MaxFailures int           `mapstructure:"max_failures"`
 	} `mapstructure:"heartbeat"`
+	LogShipping  LogShippingConfig  `mapstructure:"log_shipping"`
+	Container    ContainerConfig    `mapstructure:"container"`
+	Influx       InfluxConfig       `mapstructure:"influx"`
+	LowBandwidth LowBandwidthConfig `mapstructure:"low_bandwidth"`
+	Reporting    ReportingConfig    `mapstructure:"reporting"`
+	Resources    ResourcesConfig    `mapstructure:"resources"`
+	CrashReports CrashReportsConfig `mapstructure:"crash_reports"`
+}
+
+// CrashReportsConfig represents the reports of crashed agent components,
+// stored locally and uploaded to the server as panic log entries at start
+type CrashReportsConfig struct {
+	Dir        string `mapstructure:"dir"`         // Defaults to crashes in the data directory
+	MaxReports int    `mapstructure:"max_reports"` // Kept locally, the oldest are removed
+	Upload     bool   `mapstructure:"upload"`
+}
+
+// Reporting modes
+const (
+	ReportingFailover = "failover" // First healthy server, in order
+	ReportingFanout   = "fanout"   // Every server
+)
+
+// ReportingConfig represents the servers metrics are reported to besides
+// agent.server. Registration and heartbeats go to agent.server alone, the
+// agent registers with the others when they do not know it.
+type ReportingConfig struct {
+	Mode                string         `mapstructure:"mode"`                  // failover or fanout
+	Servers             []ServerConfig `mapstructure:"servers"`               // After agent.server, in order of preference
+	HealthCheckInterval time.Duration  `mapstructure:"health_check_interval"` // Of servers marked down
+	QueueSize           int            `mapstructure:"queue_size"`            // Reports held per server, the oldest are dropped
+	// Jitter delays sending each report, and the reports held for a server
+	// that is back up, by a random duration up to it
+	Jitter time.Duration `mapstructure:"jitter"`
+}
+
+// SetDefaults sets the defaults of reporting
+func (cfg *ReportingConfig) SetDefaults() {
+	if cfg.Mode == "" {
+		cfg.Mode = ReportingFailover
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = 30 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	for i := range cfg.Servers {
+		server := &cfg.Servers[i]
+		if server.Timeout == 0 {
+			server.Timeout = 30 * time.Second
+		}
+		if server.Compression == "" {
+			server.Compression = compress.None
+		}
+		if server.CompressionThreshold == 0 {
+			server.CompressionThreshold = 1024
+		}
+	}
+}
+
+// Validate validates reporting configuration
+func (cfg *ReportingConfig) Validate() error {
+	switch cfg.Mode {
+	case ReportingFailover, ReportingFanout:
+	default:
+		return fmt.Errorf("invalid mode: %s", cfg.Mode)
+	}
+	if cfg.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	for i := range cfg.Servers {
+		if err := cfg.Servers[i].Validate(); err != nil {
+			return fmt.Errorf("server %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// LowBandwidthConfig represents reporting over metered or slow links, e.g.
+// cellular or satellite. Reports are sent less often, compressed and as
+// deltas of the previous report, within a daily upload budget. Deltas need
+// a server of metrics schema version 3.
+type LowBandwidthConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	Interval          time.Duration `mapstructure:"interval"`           // Shortest collection interval
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // Shortest heartbeat interval
+	FullEvery         int           `mapstructure:"full_every"`         // Reports per full report, the others are deltas
+	DailyBudget       int64         `mapstructure:"daily_budget"`       // Report bytes uploaded per day, 0 for no limit
+	StateFile         string        `mapstructure:"state_file"`         // Budget used today, kept across restarts
+}
+
+// SetDefaults sets the defaults of low-bandwidth mode
+func (cfg *LowBandwidthConfig) SetDefaults(dataDir string) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 5 * time.Minute
+	}
+	if cfg.FullEvery <= 0 {
+		cfg.FullEvery = 12
+	}
+	if cfg.StateFile == "" {
+		cfg.StateFile = filepath.Join(dataDir, "upload_budget.json")
+	}
+}
+
+// Validate validates low-bandwidth mode configuration
+func (cfg *LowBandwidthConfig) Validate() error {
+	if cfg.DailyBudget < 0 {
+		return fmt.Errorf("daily_budget must not be negative")
+	}
+	return nil
+}
+
+// LogShippingConfig represents forwarding of the agent's own logs to the server
+type LogShippingConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Level         string        `mapstructure:"level"`          // Minimum level shipped
+	BatchSize     int           `mapstructure:"batch_size"`     // Entries per request
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // Longest wait before a partial batch is sent
+	BufferSize    int           `mapstructure:"buffer_size"`    // Entries held while the server is unreachable
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
-	Address string        `mapstructure:"address"`
-	Timeout time.Duration `mapstructure:"timeout"`
-	TLS     TLSConfig     `mapstructure:"tls"`
+	Address   string              `mapstructure:"address"`
+	Timeout   time.Duration       `mapstructure:"timeout"`
+	AuthToken string              `mapstructure:"auth_token"`
+	TLS       TLSConfig           `mapstructure:"tls"`
+	Proxy     *config.ProxyConfig `mapstructure:"proxy"` // Reporting and log shipping
+	// Content encoding of reports, none, gzip or zstd. Servers before
+	// compressed ingestion only accept none.
+	Compression string `mapstructure:"compression"`
+	// Smallest report compressed, in bytes
+	CompressionThreshold int `mapstructure:"compression_threshold"`
+}
+
+// Validate validates server configuration
+func (cfg *ServerConfig) Validate() error {
+	if cfg.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	switch cfg.Compression {
+	case compress.None, compress.Gzip, compress.Zstd:
+	default:
+		return fmt.Errorf("invalid compression: %s", cfg.Compression)
+	}
+	if cfg.CompressionThreshold < 0 {
+		return fmt.Errorf("compression_threshold must not be negative")
+	}
+	if cfg.TLS.Enabled && (cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS cert and key files are required when TLS is enabled")
+	}
+	return nil
+}
+
+// Capabilities returns the API version and protocol features the agent
+// offers a server on registration, preferring the encoding configured
+func (cfg *ServerConfig) Capabilities() *types.AgentCapabilities {
+	encodings := []string{compress.Zstd, compress.Gzip}
+	if cfg.Compression == compress.Gzip {
+		encodings = []string{compress.Gzip, compress.Zstd}
+	}
+	return &types.AgentCapabilities{
+		APIVersion: types.AgentAPIVersion,
+		Features:   []string{types.FeatureCompression, types.FeatureDelta},
+		Encodings:  encodings,
+	}
 }
 
 // TLSConfig represents TLS configuration
@@ -49,25 +233,256 @@ type TLSConfig struct {
 	CAFile   string `mapstructure:"ca_file"`
 }
 
+// ServerTransport returns the transport of requests to the server, the
+// http settings with the client certificate and CA of the server TLS config
+func (cfg *Config) ServerTransport() (*http.Transport, error) {
+	return cfg.TargetTransport(&cfg.Agent.Server)
+}
+
+// TargetTransport returns the transport of requests to a server, the http
+// settings with the client certificate and CA of its TLS config
+func (cfg *Config) TargetTransport(server *ServerConfig) (*http.Transport, error) {
+	transport, err := cfg.HTTP.Transport(server.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := server.TLS
+	if !tlsCfg.Enabled {
+		return transport, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+
+	if tlsCfg.CAFile != "" {
+		pem, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server CA file: %w", err)
+		}
+		pool := transport.TLSClientConfig.RootCAs
+		if pool == nil {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in server CA file %s", tlsCfg.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return transport, nil
+}
+
+// ServerClient returns the traced client of requests to the server
+func (cfg *Config) ServerClient() (*http.Client, error) {
+	return cfg.TargetClient(&cfg.Agent.Server)
+}
+
+// TargetClient returns the traced client of requests to a server
+func (cfg *Config) TargetClient(server *ServerConfig) (*http.Client, error) {
+	transport, err := cfg.TargetTransport(server)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: tracing.Transport(transport),
+		Timeout:   server.Timeout,
+	}, nil
+}
+
 // CollectorConfig represents collector configuration
 type CollectorConfig struct {
-	Interval time.Duration     `mapstructure:"interval"`
-	Network  NetworkConfig     `mapstructure:"network"`
-	Metrics  MetricsConfig     `mapstructure:"metrics"`
-	Filters  []FilterConfig    `mapstructure:"filters"`
-	Tags     map[string]string `mapstructure:"tags"`
+	Interval time.Duration `mapstructure:"interval"`
+	// Jitter delays each collection by a random duration up to it, so agents
+	// started together do not report at once
+	Jitter time.Duration `mapstructure:"jitter"`
+	// Align collects at multiples of the interval on the clock, offset by
+	// Phase. An empty phase is derived from the agent ID, which spreads the
+	// agents evenly over the interval.
+	Align   bool              `mapstructure:"align"`
+	Phase   string            `mapstructure:"phase"`
+	Network NetworkConfig     `mapstructure:"network"`
+	Metrics MetricsConfig     `mapstructure:"metrics"`
+	Filters []FilterConfig    `mapstructure:"filters"`
+	Tags    map[string]string `mapstructure:"tags"`
+	// StallIntervals is the number of intervals a collection may run before
+	// the collector is considered stalled, canceled and restarted
+	StallIntervals int `mapstructure:"stall_intervals"`
+}
+
+// Validate collection schedule configuration
+func (cfg *CollectorConfig) Validate() error {
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if cfg.Jitter < 0 || cfg.Jitter >= cfg.Interval {
+		return fmt.Errorf("jitter must be at least 0 and less than the interval")
+	}
+	if cfg.StallIntervals < 1 {
+		return fmt.Errorf("stall_intervals must be at least 1")
+	}
+	if cfg.Phase != "" {
+		phase, err := time.ParseDuration(cfg.Phase)
+		if err != nil {
+			return fmt.Errorf("invalid phase: %w", err)
+		}
+		if phase < 0 || phase >= cfg.Interval {
+			return fmt.Errorf("phase must be at least 0 and less than the interval")
+		}
+	}
+	return nil
+}
+
+// PhaseOf returns the offset of aligned collections of an agent within the
+// interval
+func (cfg *CollectorConfig) PhaseOf(agentID string) time.Duration {
+	if cfg.Phase != "" {
+		if phase, err := time.ParseDuration(cfg.Phase); err == nil {
+			return phase
+		}
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(agentID))
+	return time.Duration(h.Sum64() % uint64(cfg.Interval))
 }
 
 // NetworkConfig represents network configuration
 type NetworkConfig struct {
-	Enabled           bool             `mapstructure:"enabled"`
-	Interfaces        []string         `mapstructure:"interfaces"`
-	ExcludePatterns   []string         `mapstructure:"exclude_patterns"`
-	IncludeVirtual    bool             `mapstructure:"include_virtual"`
-	CheckExternalIP   bool             `mapstructure:"check_external_ip"`
-	StatInterval      time.Duration    `mapstructure:"stat_interval"`
-	ExternalProviders []string         `mapstructure:"external_providers"`
-	IPTracker         *IPTrackerConfig `mapstructure:"ip_tracking"`
+	Enabled           bool                     `mapstructure:"enabled"`
+	Interfaces        []string                 `mapstructure:"interfaces"`
+	ExcludePatterns   []string                 `mapstructure:"exclude_patterns"`
+	IncludeRegex      []string                 `mapstructure:"include_regex"` // Only interfaces matching one of them
+	ExcludeRegex      []string                 `mapstructure:"exclude_regex"`
+	IncludeTypes      []string                 `mapstructure:"include_types"` // Only these types, virtual ones included
+	ExcludeTypes      []string                 `mapstructure:"exclude_types"`
+	IncludeVirtual    bool                     `mapstructure:"include_virtual"`
+	InterfaceSettings []InterfaceConfig        `mapstructure:"interface_settings"` // First matching entry applies
+	CheckExternalIP   bool                     `mapstructure:"check_external_ip"`
+	StatInterval      time.Duration            `mapstructure:"stat_interval"`
+	ExternalProviders []string                 `mapstructure:"external_providers"`      // IPv4 sources
+	ExternalIPv6      []string                 `mapstructure:"external_providers_ipv6"` // IPv6 sources
+	ExternalConsensus *ExternalConsensusConfig `mapstructure:"external_consensus"`
+	MonitorRoutes     bool                     `mapstructure:"monitor_routes"`
+	IPTracker         *IPTrackerConfig         `mapstructure:"ip_tracking"`
+	Proxy             *config.ProxyConfig      `mapstructure:"proxy"` // HTTP external IP providers
+	HTTP              *config.HTTPClientConfig `mapstructure:"http"`  // HTTP external IP providers
+}
+
+// InterfaceConfig represents the settings of interfaces matching name, an
+// exact name or glob pattern
+type InterfaceConfig struct {
+	Name       string `mapstructure:"name"`
+	Stats      *bool  `mapstructure:"stats"`       // Collect statistics, default true
+	IPTracking *bool  `mapstructure:"ip_tracking"` // Track IP changes, default true
+}
+
+// externalSchemes are the schemes of external IP providers, HTTP(S) URLs
+// answer with the IP as text, the others are detected by the agent itself,
+// e.g. "stun:stun.l.google.com:19302", "dns:opendns", "upnp:", "natpmp:"
+var externalSchemes = []string{"http", "https", "stun", "dns", "upnp", "natpmp"}
+
+// External IP consensus strategies
+const (
+	ConsensusFirstSuccess = "first_success" // First valid answer
+	ConsensusMajority     = "majority"      // More than half of the queried providers agree
+	ConsensusQuorum       = "quorum"        // At least quorum providers agree
+)
+
+// External IP provider query orders
+const (
+	ProviderOrderConfigured = "configured" // As configured
+	ProviderOrderFastest    = "fastest"    // By rolling latency, new providers first
+)
+
+// ExternalConsensusConfig represents how external IP providers are queried,
+// how their answers are combined and when failing providers are demoted
+type ExternalConsensusConfig struct {
+	Strategy         string        `mapstructure:"strategy"`          // first_success, majority or quorum
+	Quorum           int           `mapstructure:"quorum"`            // Providers that must agree with the quorum strategy
+	FailureThreshold int           `mapstructure:"failure_threshold"` // Consecutive failures before a provider is demoted
+	DemoteDuration   time.Duration `mapstructure:"demote_duration"`   // How long a demoted provider is skipped
+	ProviderTimeout  time.Duration `mapstructure:"provider_timeout"`  // Of a single query
+	Deadline         time.Duration `mapstructure:"deadline"`          // Of a whole lookup
+	MaxConcurrent    int           `mapstructure:"max_concurrent"`    // Queries in flight, 0 for all providers at once
+	Order            string        `mapstructure:"order"`             // configured or fastest
+}
+
+// ExternalConsensusDefaultConfig returns the default consensus configuration,
+// two of the providers have to agree
+func ExternalConsensusDefaultConfig(providers int) *ExternalConsensusConfig {
+	return &ExternalConsensusConfig{
+		Strategy:         ConsensusQuorum,
+		Quorum:           min(2, max(providers, 1)),
+		FailureThreshold: 3,
+		DemoteDuration:   15 * time.Minute,
+		ProviderTimeout:  5 * time.Second,
+		Deadline:         10 * time.Second,
+		Order:            ProviderOrderConfigured,
+	}
+}
+
+// SetDefaults sets unset values of cfg from the defaults
+func (cfg *ExternalConsensusConfig) SetDefaults(providers int) {
+	def := ExternalConsensusDefaultConfig(providers)
+	if cfg.Strategy == "" {
+		cfg.Strategy = def.Strategy
+	}
+	if cfg.Quorum == 0 {
+		cfg.Quorum = def.Quorum
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = def.FailureThreshold
+	}
+	if cfg.DemoteDuration == 0 {
+		cfg.DemoteDuration = def.DemoteDuration
+	}
+	if cfg.ProviderTimeout == 0 {
+		cfg.ProviderTimeout = def.ProviderTimeout
+	}
+	if cfg.Deadline == 0 {
+		cfg.Deadline = def.Deadline
+	}
+	if cfg.Order == "" {
+		cfg.Order = def.Order
+	}
+}
+
+// Validate validates the consensus configuration
+func (cfg *ExternalConsensusConfig) Validate(providers int) error {
+	switch cfg.Strategy {
+	case ConsensusFirstSuccess, ConsensusMajority:
+	case ConsensusQuorum:
+		if cfg.Quorum < 1 || cfg.Quorum > providers {
+			return fmt.Errorf("quorum must be between 1 and the %d configured providers", providers)
+		}
+	default:
+		return fmt.Errorf("unsupported strategy: %s", cfg.Strategy)
+	}
+	if cfg.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold must not be negative")
+	}
+	if cfg.DemoteDuration < 0 {
+		return fmt.Errorf("demote_duration must not be negative")
+	}
+	if cfg.ProviderTimeout < 0 || cfg.Deadline < 0 {
+		return fmt.Errorf("provider_timeout and deadline must not be negative")
+	}
+	if cfg.ProviderTimeout > cfg.Deadline {
+		return fmt.Errorf("provider_timeout must not exceed the deadline")
+	}
+	if cfg.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent must not be negative")
+	}
+	switch cfg.Order {
+	case ProviderOrderConfigured, ProviderOrderFastest:
+	default:
+		return fmt.Errorf("unsupported order: %s", cfg.Order)
+	}
+	return nil
 }
 
 // MetricsConfig represents metrics configuration
@@ -86,15 +501,26 @@ type FilterConfig struct {
 
 // IPTrackerConfig represents IP tracking configuration
 type IPTrackerConfig struct {
-	EnableIPv4        bool          `json:"enable_ipv4"`
-	EnableIPv6        bool          `json:"enable_ipv6"`
-	CleanupInterval   time.Duration `json:"cleanup_interval"`     // Cleanup interval
-	RetentionPeriod   time.Duration `json:"retention_period"`     // Retention period
-	ChangeThreshold   int           `json:"change_threshold"`     // Max changes in window
-	ThresholdWindow   time.Duration `json:"threshold_window"`     // Time window for changes
-	ExternalCheckTTL  time.Duration `json:"external_check_ttl"`   // External IP check frequency
-	NotifyOnFirstSeen bool          `json:"notify_on_first_seen"` // Notify on first seen
-	NotifyOnRemoval   bool          `json:"notify_on_removal"`    // Notify on removal
+	EnableIPv4        bool          `mapstructure:"enable_ipv4"`
+	EnableIPv6        bool          `mapstructure:"enable_ipv6"`
+	CleanupInterval   time.Duration `mapstructure:"cleanup_interval"`     // Cleanup interval
+	RetentionPeriod   time.Duration `mapstructure:"retention_period"`     // Retention period
+	ChangeThreshold   int           `mapstructure:"change_threshold"`     // Max changes in window
+	ThresholdWindow   time.Duration `mapstructure:"threshold_window"`     // Time window for changes
+	ExternalCheckTTL  time.Duration `mapstructure:"external_check_ttl"`   // External IP check frequency
+	NotifyOnFirstSeen bool          `mapstructure:"notify_on_first_seen"` // Notify on first seen
+	NotifyOnRemoval   bool          `mapstructure:"notify_on_removal"`    // Notify on removal
+	StateFile         string        `mapstructure:"state_file"`           // Last known state kept across restarts, "-" for none
+
+	FlapDetection *FlapDetectionConfig `mapstructure:"flap_detection"` // Flap detection
+}
+
+// FlapDetectionConfig represents interface flap detection configuration
+type FlapDetectionConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Threshold   int           `mapstructure:"threshold"`    // Changes in window to consider flapping
+	Window      time.Duration `mapstructure:"window"`       // Time window for counting changes
+	StableAfter time.Duration `mapstructure:"stable_after"` // Quiet period before recovery
 }
 
 // IPtrackerDefaultConfig returns the default IP tracker configuration
@@ -109,11 +535,18 @@ func IPtrackerDefaultConfig() *IPTrackerConfig {
 		ExternalCheckTTL:  5 * time.Minute,
 		NotifyOnFirstSeen: true,
 		NotifyOnRemoval:   true,
+		FlapDetection: &FlapDetectionConfig{
+			Enabled:     true,
+			Threshold:   5,
+			Window:      10 * time.Minute,
+			StableAfter: 15 * time.Minute,
+		},
 	}
 }
 
-// LoadConfig loads the agent configuration from file
-func LoadConfig(path string) (*Config, error) {
+// LoadConfig loads the agent configuration from file, overlaid by WAMETER_
+// environment variables and then by command line overrides
+func LoadConfig(path string, overrides config.Overrides) (*Config, error) {
 	v := viper.New()
 	v.SetConfigFile(path)
 	// Add search paths
@@ -135,13 +568,18 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	if err := config.Load(v, &cfg, overrides); err != nil {
+		return nil, err
 	}
 
 	// Set defaults if not specified
 	setDefaults(&cfg)
 
+	// Settle the agent ID against the persisted one
+	if err := cfg.Agent.ResolveID(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -152,17 +590,22 @@ func LoadConfig(path string) (*Config, error) {
 
 // setDefaults sets default values if not specified
 func setDefaults(cfg *Config) {
+	// Report the node rather than the pod as the host
+	cfg.Agent.Container.SetDefaults()
+	if cfg.Agent.Hostname == "" && cfg.Agent.Container.Active() {
+		cfg.Agent.Hostname = cfg.Agent.Container.NodeName()
+	}
+
 	if cfg.Agent.Hostname == "" {
 		hostname, err := os.Hostname()
 		if err != nil {
-			hostname = "unknown-" + cfg.Agent.ID[:8]
+			hostname = "unknown"
 		}
 		cfg.Agent.Hostname = hostname
 	}
 
-	if cfg.Agent.ID == "" {
-		// Generate a short hash of the hostname
-		cfg.Agent.ID = utils.ShortHash(cfg.Agent.Hostname)
+	if cfg.Agent.DataDir == "" {
+		cfg.Agent.DataDir = "/var/lib/wameter/agent"
 	}
 
 	if cfg.Agent.Port == 0 {
@@ -172,6 +615,9 @@ func setDefaults(cfg *Config) {
 	if cfg.Collector.Interval == 0 {
 		cfg.Collector.Interval = 60 * time.Second
 	}
+	if cfg.Collector.StallIntervals == 0 {
+		cfg.Collector.StallIntervals = 3
+	}
 
 	if cfg.Agent.Port == 0 {
 		cfg.Agent.Port = 8081
@@ -181,6 +627,34 @@ func setDefaults(cfg *Config) {
 		cfg.Agent.Server.Timeout = 30 * time.Second
 	}
 
+	// Low-bandwidth mode stretches the intervals and compresses reports
+	if cfg.Agent.LowBandwidth.Enabled {
+		lb := &cfg.Agent.LowBandwidth
+		lb.SetDefaults(cfg.Agent.DataDir)
+		cfg.Collector.Interval = max(cfg.Collector.Interval, lb.Interval)
+		cfg.Agent.Heartbeat.Interval = max(cfg.Agent.Heartbeat.Interval, lb.HeartbeatInterval)
+		if cfg.Agent.Server.Compression == "" {
+			cfg.Agent.Server.Compression = compress.Gzip
+		}
+	}
+
+	if cfg.Agent.Server.Compression == "" {
+		cfg.Agent.Server.Compression = compress.None
+	}
+
+	cfg.Agent.Reporting.SetDefaults()
+	cfg.Agent.Resources.SetDefaults()
+	if cfg.Agent.CrashReports.Dir == "" {
+		cfg.Agent.CrashReports.Dir = filepath.Join(cfg.Agent.DataDir, "crashes")
+	}
+	if cfg.Agent.CrashReports.MaxReports <= 0 {
+		cfg.Agent.CrashReports.MaxReports = 20
+	}
+
+	if cfg.Agent.Server.CompressionThreshold == 0 {
+		cfg.Agent.Server.CompressionThreshold = 1024
+	}
+
 	if len(cfg.Collector.Network.ExternalProviders) == 0 {
 		cfg.Collector.Network.ExternalProviders = []string{
 			"https://api.ipify.org",
@@ -189,6 +663,87 @@ func setDefaults(cfg *Config) {
 		}
 	}
 
+	if len(cfg.Collector.Network.ExternalIPv6) == 0 {
+		cfg.Collector.Network.ExternalIPv6 = []string{
+			"https://api6.ipify.org",
+			"https://ipv6.icanhazip.com",
+			"https://v6.ident.me",
+		}
+	}
+
+	if cfg.Collector.Network.ExternalConsensus == nil {
+		cfg.Collector.Network.ExternalConsensus = &ExternalConsensusConfig{}
+	}
+	cfg.Collector.Network.ExternalConsensus.SetDefaults(len(cfg.Collector.Network.ExternalProviders))
+
+	if cfg.Collector.Network.IPTracker == nil {
+		cfg.Collector.Network.IPTracker = IPtrackerDefaultConfig()
+	}
+	switch cfg.Collector.Network.IPTracker.StateFile {
+	case "":
+		cfg.Collector.Network.IPTracker.StateFile = filepath.Join(cfg.Agent.DataDir, "network_state.json")
+	case "-":
+		// Not persisted
+		cfg.Collector.Network.IPTracker.StateFile = ""
+	}
+
+	if cfg.Agent.LogShipping.Level == "" {
+		cfg.Agent.LogShipping.Level = "info"
+	}
+
+	if cfg.Agent.LogShipping.BatchSize <= 0 {
+		cfg.Agent.LogShipping.BatchSize = 100
+	}
+
+	if cfg.Agent.LogShipping.FlushInterval <= 0 {
+		cfg.Agent.LogShipping.FlushInterval = 5 * time.Second
+	}
+
+	if cfg.Agent.LogShipping.BufferSize <= 0 {
+		cfg.Agent.LogShipping.BufferSize = 5000
+	}
+
+	cfg.Agent.Influx.SetDefaults()
+
+	// Components without their own proxy use the global one
+	if cfg.Agent.Server.Proxy == nil {
+		cfg.Agent.Server.Proxy = cfg.Proxy
+	}
+	if cfg.Agent.Influx.Proxy == nil {
+		cfg.Agent.Influx.Proxy = cfg.Proxy
+	}
+	if cfg.Collector.Network.Proxy == nil {
+		cfg.Collector.Network.Proxy = cfg.Proxy
+	}
+	if cfg.Notify != nil && cfg.Notify.Proxy == nil {
+		cfg.Notify.Proxy = cfg.Proxy
+	}
+
+	if cfg.HTTP == nil {
+		cfg.HTTP = config.HTTPClientDefaultConfig()
+	}
+	cfg.HTTP.SetDefaults()
+	if cfg.Collector.Network.HTTP == nil {
+		cfg.Collector.Network.HTTP = cfg.HTTP
+	}
+	cfg.Collector.Network.HTTP.SetDefaults()
+	if cfg.Notify != nil {
+		if cfg.Notify.HTTP == nil {
+			cfg.Notify.HTTP = cfg.HTTP
+		}
+		cfg.Notify.HTTP.SetDefaults()
+	}
+
+	if cfg.Debug == nil {
+		cfg.Debug = &config.DebugConfig{}
+	}
+	cfg.Debug.SetDefaults("localhost:6061")
+
+	if cfg.Tracing == nil {
+		cfg.Tracing = &config.TracingConfig{}
+	}
+	cfg.Tracing.SetDefaults("wameter-agent")
+
 	// Set defaults for retry
 	cfg.Retry = cfg.Retry.SetDefaults()
 }
@@ -199,18 +754,111 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("agent.id is required")
 	}
 
+	if err := cfg.Agent.Container.Validate(); err != nil {
+		return fmt.Errorf("invalid agent.container config: %w", err)
+	}
+
+	if err := cfg.Collector.Validate(); err != nil {
+		return fmt.Errorf("invalid collector config: %w", err)
+	}
+
+	if err := cfg.Agent.Resources.Validate(); err != nil {
+		return fmt.Errorf("invalid agent.resources config: %w", err)
+	}
+
 	if !cfg.Agent.Standalone {
 		if cfg.Agent.Server.Address == "" {
 			return fmt.Errorf("server address is required when not in standalone mode")
 		}
 	}
 
+	switch cfg.Agent.Server.Compression {
+	case compress.None, compress.Gzip, compress.Zstd:
+	default:
+		return fmt.Errorf("invalid server compression: %s", cfg.Agent.Server.Compression)
+	}
+	if cfg.Agent.Server.CompressionThreshold < 0 {
+		return fmt.Errorf("server compression_threshold must not be negative")
+	}
+
+	if cfg.Agent.LogShipping.Enabled {
+		if cfg.Agent.Standalone {
+			return fmt.Errorf("log shipping requires a server, it is not available in standalone mode")
+		}
+		switch cfg.Agent.LogShipping.Level {
+		case "debug", "info", "warn", "error":
+		default:
+			return fmt.Errorf("invalid log shipping level: %s", cfg.Agent.LogShipping.Level)
+		}
+		if cfg.Agent.LogShipping.BatchSize > 1000 {
+			return fmt.Errorf("log shipping batch_size must be at most 1000")
+		}
+	}
+
+	if err := cfg.Agent.Influx.Validate(); err != nil {
+		return fmt.Errorf("invalid agent.influx config: %w", err)
+	}
+
+	if cfg.Agent.LowBandwidth.Enabled {
+		if err := cfg.Agent.LowBandwidth.Validate(); err != nil {
+			return fmt.Errorf("invalid agent.low_bandwidth config: %w", err)
+		}
+	}
+
+	if cfg.Debug != nil {
+		if err := cfg.Debug.Validate(); err != nil {
+			return fmt.Errorf("invalid debug config: %w", err)
+		}
+	}
+
+	if cfg.Tracing != nil {
+		if err := cfg.Tracing.Validate(); err != nil {
+			return fmt.Errorf("invalid tracing config: %w", err)
+		}
+	}
+
+	for name, proxy := range map[string]*config.ProxyConfig{
+		"proxy":                   cfg.Proxy,
+		"agent.server.proxy":      cfg.Agent.Server.Proxy,
+		"agent.influx.proxy":      cfg.Agent.Influx.Proxy,
+		"collector.network.proxy": cfg.Collector.Network.Proxy,
+	} {
+		if err := proxy.Validate(); err != nil {
+			return fmt.Errorf("invalid %s config: %w", name, err)
+		}
+	}
+
+	if err := cfg.HTTP.Validate(); err != nil {
+		return fmt.Errorf("invalid http config: %w", err)
+	}
+	if err := cfg.Collector.Network.HTTP.Validate(); err != nil {
+		return fmt.Errorf("invalid collector.network.http config: %w", err)
+	}
+
 	if cfg.Agent.Server.TLS.Enabled {
 		if cfg.Agent.Server.TLS.CertFile == "" || cfg.Agent.Server.TLS.KeyFile == "" {
 			return fmt.Errorf("TLS cert and key files are required when TLS is enabled")
 		}
 	}
 
+	// Load certificates now so that broken files fail at startup
+	if !cfg.Agent.Standalone {
+		if _, err := cfg.ServerClient(); err != nil {
+			return fmt.Errorf("invalid server TLS config: %w", err)
+		}
+		if err := cfg.Agent.Reporting.Validate(); err != nil {
+			return fmt.Errorf("invalid agent.reporting config: %w", err)
+		}
+		if cfg.Agent.Reporting.Jitter >= cfg.Collector.Interval {
+			return fmt.Errorf("invalid agent.reporting config: jitter must be less than the collector interval")
+		}
+		for i := range cfg.Agent.Reporting.Servers {
+			if _, err := cfg.TargetClient(&cfg.Agent.Reporting.Servers[i]); err != nil {
+				return fmt.Errorf("invalid agent.reporting server %d TLS config: %w", i, err)
+			}
+		}
+	}
+
 	if cfg.Collector.Network.Enabled {
 		if len(cfg.Collector.Network.Interfaces) > 0 {
 			hasValidInterface := false
@@ -224,6 +872,31 @@ func (cfg *Config) Validate() error {
 				return fmt.Errorf("if interfaces list is provided, at least one valid interface must be specified")
 			}
 		}
+
+		if err := validateInterfaceFilters(&cfg.Collector.Network); err != nil {
+			return err
+		}
+
+		if cfg.Collector.Network.CheckExternalIP {
+			for _, p := range cfg.Collector.Network.ExternalProviders {
+				scheme, _, _ := strings.Cut(p, ":")
+				if !slices.Contains(externalSchemes, scheme) {
+					return fmt.Errorf("unsupported external provider %q, expected one of %v", p, externalSchemes)
+				}
+			}
+			for _, p := range cfg.Collector.Network.ExternalIPv6 {
+				scheme, _, _ := strings.Cut(p, ":")
+				if !slices.Contains(externalSchemes, scheme) || scheme == "upnp" || scheme == "natpmp" {
+					return fmt.Errorf("unsupported external IPv6 provider %q, upnp and natpmp only detect IPv4", p)
+				}
+			}
+			if err := cfg.Collector.Network.ExternalConsensus.Validate(len(cfg.Collector.Network.ExternalProviders)); err != nil {
+				return fmt.Errorf("invalid external consensus config: %w", err)
+			}
+			if err := cfg.Collector.Network.ExternalConsensus.Validate(len(cfg.Collector.Network.ExternalIPv6)); err != nil {
+				return fmt.Errorf("invalid external consensus config for IPv6: %w", err)
+			}
+		}
 	}
 
 	if cfg.Agent.Standalone && cfg.Notify.Enabled {
@@ -234,3 +907,27 @@ func (cfg *Config) Validate() error {
 
 	return nil
 }
+
+// validateInterfaceFilters validates the regular expressions, types and
+// interface settings of the network collector
+func validateInterfaceFilters(cfg *NetworkConfig) error {
+	for _, expr := range slices.Concat(cfg.IncludeRegex, cfg.ExcludeRegex) {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid interface regex %q: %w", expr, err)
+		}
+	}
+	for _, t := range slices.Concat(cfg.IncludeTypes, cfg.ExcludeTypes) {
+		if !slices.Contains(utils.InterfaceTypes, utils.InterfaceType(t)) {
+			return fmt.Errorf("unknown interface type %q, expected one of %v", t, utils.InterfaceTypes)
+		}
+	}
+	for _, s := range cfg.InterfaceSettings {
+		if s.Name == "" {
+			return fmt.Errorf("interface settings require a name")
+		}
+		if _, err := filepath.Match(s.Name, ""); err != nil {
+			return fmt.Errorf("invalid interface settings name %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
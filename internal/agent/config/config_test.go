@@ -0,0 +1,75 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/config"
+)
+
+// loadExample loads the example agent configuration with the data directory
+// in a temporary one
+func loadExample(t *testing.T, overrides config.Overrides) *Config {
+	t.Helper()
+	if overrides == nil {
+		overrides = config.Overrides{}
+	}
+	overrides["agent.data_dir"] = t.TempDir()
+
+	cfg, err := LoadConfig("../../../examples/agent.example.yaml", overrides)
+	require.NoError(t, err)
+	return cfg
+}
+
+// TestLoadIPTrackerConfig tests that the IP tracking settings of the
+// example configuration are decoded
+func TestLoadIPTrackerConfig(t *testing.T) {
+	cfg := loadExample(t, nil)
+
+	tracker := cfg.Collector.Network.IPTracker
+	require.NotNil(t, tracker)
+	assert.True(t, tracker.EnableIPv4)
+	assert.Equal(t, 10, tracker.ChangeThreshold)
+	assert.Equal(t, time.Hour, tracker.ThresholdWindow)
+	assert.Equal(t, 5*time.Minute, tracker.ExternalCheckTTL)
+
+	require.NotNil(t, tracker.FlapDetection)
+	assert.Equal(t, FlapDetectionConfig{
+		Enabled:     true,
+		Threshold:   5,
+		Window:      10 * time.Minute,
+		StableAfter: 15 * time.Minute,
+	}, *tracker.FlapDetection)
+
+	cfg = loadExample(t, config.Overrides{"collector.network.ip_tracking.flap_detection.stable_after": "30m"})
+	assert.Equal(t, 30*time.Minute, cfg.Collector.Network.IPTracker.FlapDetection.StableAfter)
+}
+
+// TestLoadIPStateFile tests that the IP state file defaults to the data
+// directory, can be moved and can be turned off
+func TestLoadIPStateFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected func(dataDir string) string
+	}{
+		{name: "Default", expected: func(dataDir string) string { return filepath.Join(dataDir, "network_state.json") }},
+		{name: "Moved", value: "/tmp/wameter-state.json", expected: func(string) string { return "/tmp/wameter-state.json" }},
+		{name: "Disabled", value: "-", expected: func(string) string { return "" }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides := config.Overrides{}
+			if tc.value != "" {
+				overrides["collector.network.ip_tracking.state_file"] = tc.value
+			}
+			cfg := loadExample(t, overrides)
+			assert.Equal(t, tc.expected(cfg.Agent.DataDir), cfg.Collector.Network.IPTracker.StateFile)
+		})
+	}
+}
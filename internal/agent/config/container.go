@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"wameter/internal/utils"
+)
+
+// Container modes
+const (
+	ContainerModeAuto     = "auto"     // Detect the container runtime
+	ContainerModeEnabled  = "enabled"  // Always run in container mode
+	ContainerModeDisabled = "disabled" // Never run in container mode
+)
+
+// ContainerConfig represents running the agent in a container, e.g. as a
+// Kubernetes DaemonSet monitoring its node
+type ContainerConfig struct {
+	Mode        string `mapstructure:"mode"`          // auto, enabled or disabled
+	HostSys     string `mapstructure:"host_sys"`      // Host sysfs mount, used when present
+	HostProc    string `mapstructure:"host_proc"`     // Host procfs mount, used when present
+	HostEtc     string `mapstructure:"host_etc"`      // Host /etc mount for the machine ID, used when present
+	NodeNameEnv string `mapstructure:"node_name_env"` // Variable holding the node name, e.g. from the downward API
+}
+
+// ContainerDefaultConfig returns the default container configuration
+func ContainerDefaultConfig() *ContainerConfig {
+	return &ContainerConfig{
+		Mode:        ContainerModeAuto,
+		HostSys:     "/host/sys",
+		HostProc:    "/host/proc",
+		HostEtc:     "/host/etc",
+		NodeNameEnv: "NODE_NAME",
+	}
+}
+
+// SetDefaults sets unset values of cfg from the defaults
+func (cfg *ContainerConfig) SetDefaults() {
+	def := ContainerDefaultConfig()
+	if cfg.Mode == "" {
+		cfg.Mode = def.Mode
+	}
+	if cfg.HostSys == "" {
+		cfg.HostSys = def.HostSys
+	}
+	if cfg.HostProc == "" {
+		cfg.HostProc = def.HostProc
+	}
+	if cfg.HostEtc == "" {
+		cfg.HostEtc = def.HostEtc
+	}
+	if cfg.NodeNameEnv == "" {
+		cfg.NodeNameEnv = def.NodeNameEnv
+	}
+}
+
+// Validate validates the container configuration
+func (cfg *ContainerConfig) Validate() error {
+	switch cfg.Mode {
+	case ContainerModeAuto, ContainerModeEnabled, ContainerModeDisabled:
+		return nil
+	default:
+		return fmt.Errorf("invalid container mode: %s", cfg.Mode)
+	}
+}
+
+// Active returns true if the agent runs in container mode
+func (cfg *ContainerConfig) Active() bool {
+	switch cfg.Mode {
+	case ContainerModeEnabled:
+		return true
+	case ContainerModeDisabled:
+		return false
+	default:
+		return utils.InContainer()
+	}
+}
+
+// HostPaths returns the host sysfs and procfs mounts that exist, empty
+// for those not mounted into the container
+func (cfg *ContainerConfig) HostPaths() (sys, proc string) {
+	if exists(filepath.Join(cfg.HostSys, "class", "net")) {
+		sys = cfg.HostSys
+	}
+	if exists(filepath.Join(cfg.HostProc, "1", "net")) {
+		proc = cfg.HostProc
+	}
+	return sys, proc
+}
+
+// NodeName returns the node name from the environment, empty when unset
+func (cfg *ContainerConfig) NodeName() string {
+	return os.Getenv(cfg.NodeNameEnv)
+}
+
+// MachineIDFile returns the machine ID file of the host mount
+func (cfg *ContainerConfig) MachineIDFile() string {
+	return filepath.Join(cfg.HostEtc, "machine-id")
+}
+
+// exists returns true if path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// identityFile is the name of the state file persisting the agent ID in
+// the data directory
+const identityFile = "identity.json"
+
+// machineIDFiles are the files holding the machine ID, the first existing
+// one is used
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// invalidIDChars are the characters replaced in generated IDs
+var invalidIDChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// identity represents the persisted identity of the agent
+type identity struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResolveID settles the agent ID against the one persisted in the data
+// directory. Without a configured ID the persisted one is used, or one is
+// generated from the machine ID and persisted. A configured ID differing
+// from the persisted one is rejected unless allow_id_change is set, as the
+// server would register the host as another agent.
+func (cfg *AgentConfig) ResolveID() error {
+	path := filepath.Join(cfg.DataDir, identityFile)
+
+	persisted, err := readIdentity(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case persisted != nil && cfg.ID == "":
+		cfg.ID = persisted.ID
+		return nil
+	case persisted != nil && cfg.ID == persisted.ID:
+		return nil
+	case persisted != nil && !cfg.AllowIDChange:
+		return fmt.Errorf("agent.id %q differs from the ID %q persisted in %s, set agent.allow_id_change to register the host as a new agent",
+			cfg.ID, persisted.ID, path)
+	case cfg.ID == "":
+		files := machineIDFiles
+		if cfg.Container.Active() {
+			// The container has no machine ID of its own
+			files = append([]string{cfg.Container.MachineIDFile()}, files...)
+		}
+		cfg.ID = generateID(cfg.Hostname, files)
+	}
+
+	if err := writeIdentity(path, &identity{ID: cfg.ID, CreatedAt: time.Now().UTC()}); err != nil {
+		// Without persisted state there is nothing to protect yet, and a
+		// generated ID stays stable as it is derived from the machine ID
+		if persisted == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// generateID returns an ID derived from the hostname and the first machine
+// ID found in files, the hostname is the machine ID where none exists
+func generateID(hostname string, files []string) string {
+	machineID := hostname
+	for _, file := range files {
+		if data, err := os.ReadFile(file); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+			machineID = strings.TrimSpace(string(data))
+			break
+		}
+	}
+
+	name := strings.Trim(invalidIDChars.ReplaceAllString(strings.ToLower(hostname), "-"), "-")
+	if name == "" {
+		name = "agent"
+	}
+	sum := sha256.Sum256([]byte(machineID))
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
+
+// readIdentity reads the identity at path, nil when it does not exist
+func readIdentity(path string) (*identity, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent identity: %w", err)
+	}
+
+	var id identity
+	if err := json.Unmarshal(data, &id); err != nil || id.ID == "" {
+		return nil, fmt.Errorf("invalid agent identity file %s", path)
+	}
+	return &id, nil
+}
+
+// writeIdentity writes id to path, replacing it atomically
+func writeIdentity(path string, id *identity) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(id, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write agent identity: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write agent identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"wameter/internal/config"
+	"wameter/internal/tracing"
+)
+
+// InfluxConfig represents writing metrics in InfluxDB line protocol to an
+// InfluxDB or VictoriaMetrics endpoint, besides reporting to the server or
+// instead of it in standalone mode
+type InfluxConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Write endpoint with its query, e.g. http://influx:8086/api/v2/write?org=ops&bucket=net
+	// or http://victoria:8428/write
+	URL         string              `mapstructure:"url"`
+	AuthToken   string              `mapstructure:"auth_token"`
+	AuthScheme  string              `mapstructure:"auth_scheme"` // Of the Authorization header, Token or Bearer
+	Measurement string              `mapstructure:"measurement"` // Of the interface points
+	Timeout     time.Duration       `mapstructure:"timeout"`
+	Proxy       *config.ProxyConfig `mapstructure:"proxy"`
+}
+
+// InfluxDefaultConfig returns the default line protocol output configuration
+func InfluxDefaultConfig() *InfluxConfig {
+	return &InfluxConfig{
+		AuthScheme:  "Token",
+		Measurement: "wameter_interface",
+		Timeout:     10 * time.Second,
+	}
+}
+
+// SetDefaults sets unset values of cfg from the defaults
+func (cfg *InfluxConfig) SetDefaults() {
+	def := InfluxDefaultConfig()
+	if cfg.AuthScheme == "" {
+		cfg.AuthScheme = def.AuthScheme
+	}
+	if cfg.Measurement == "" {
+		cfg.Measurement = def.Measurement
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = def.Timeout
+	}
+}
+
+// Validate validates the line protocol output configuration
+func (cfg *InfluxConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url: %s", cfg.URL)
+	}
+	if cfg.Measurement == "" {
+		return fmt.Errorf("measurement is required")
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// InfluxClient returns the traced client of writes to the line protocol endpoint
+func (cfg *Config) InfluxClient() (*http.Client, error) {
+	transport, err := cfg.HTTP.Transport(cfg.Agent.Influx.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: tracing.Transport(transport),
+		Timeout:   cfg.Agent.Influx.Timeout,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResourcesConfig represents limits of the CPU and memory the agent uses, so
+// it does not compete with the workloads of its host. Unset limits are
+// derived from the cgroup of the agent when it has limits.
+type ResourcesConfig struct {
+	Enabled     bool  `mapstructure:"enabled"`
+	MaxProcs    int   `mapstructure:"max_procs"`    // GOMAXPROCS, 0 for the cgroup CPU limit
+	GCPercent   int   `mapstructure:"gc_percent"`   // GOGC, 0 keeps the default
+	MemoryLimit int64 `mapstructure:"memory_limit"` // Soft limit of the Go runtime in bytes, 0 for a share of the cgroup limit
+	// MaxRSS is the resident memory in bytes above which the watchdog drops
+	// queued reports and stretches the collection interval, 0 for a share
+	// of the cgroup limit
+	MaxRSS            int64         `mapstructure:"max_rss"`
+	CheckInterval     time.Duration `mapstructure:"check_interval"`      // Of the memory watchdog
+	MaxIntervalFactor int           `mapstructure:"max_interval_factor"` // Stretch of the collection interval under memory pressure
+}
+
+// SetDefaults sets the defaults of resource limits
+func (cfg *ResourcesConfig) SetDefaults() {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 10 * time.Second
+	}
+	if cfg.MaxIntervalFactor <= 0 {
+		cfg.MaxIntervalFactor = 4
+	}
+}
+
+// Validate validates resource limits
+func (cfg *ResourcesConfig) Validate() error {
+	if cfg.MaxProcs < 0 || cfg.GCPercent < 0 || cfg.MemoryLimit < 0 || cfg.MaxRSS < 0 {
+		return fmt.Errorf("max_procs, gc_percent, memory_limit and max_rss must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package crash recovers panics of agent components. A crash is logged and
+// stored as a report in the crash directory, and the component is restarted
+// instead of the whole agent going down. Stored reports are uploaded to the
+// server at the next start.
+package crash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/agent/config"
+	commonCfg "wameter/internal/config"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// minRestartDelay is the first delay before restarting a crashed component
+	minRestartDelay = time.Second
+	// maxRestartDelay caps the delay between restarts of a crashing component,
+	// a component running longer than it starts over at minRestartDelay
+	maxRestartDelay = time.Minute
+)
+
+// Report represents the crash of an agent component
+type Report struct {
+	Time       time.Time `json:"time"`
+	Component  string    `json:"component"`
+	Panic      string    `json:"panic"`
+	Stack      string    `json:"stack"`
+	Version    string    `json:"version"`
+	GoVersion  string    `json:"go_version"`
+	Platform   string    `json:"platform"`
+	ConfigHash string    `json:"config_hash"` // Of the redacted configuration
+}
+
+// recorder stores the reports of crashes
+type recorder struct {
+	dir        string
+	maxReports int
+	configHash string
+	logger     *zap.Logger
+	mu         sync.Mutex
+}
+
+var (
+	current   *recorder
+	currentMu sync.RWMutex
+)
+
+// Setup sets where crashes are logged and stored, crashes before it are only
+// recovered
+func Setup(cfg *config.Config, logger *zap.Logger) {
+	r := &recorder{
+		dir:        cfg.Agent.CrashReports.Dir,
+		maxReports: cfg.Agent.CrashReports.MaxReports,
+		configHash: configHash(cfg),
+		logger:     logger,
+	}
+
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = r
+}
+
+// configHash returns a short hash of the redacted configuration, crashes of
+// agents of the same hash ran with the same settings
+func configHash(cfg *config.Config) string {
+	data, err := json.Marshal(commonCfg.Flatten(cfg, true))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Supervise runs fn until it returns or ctx is done, restarting it with a
+// backoff when it panics
+func Supervise(ctx context.Context, component string, fn func(ctx context.Context)) {
+	delay := minRestartDelay
+	for {
+		start := time.Now()
+		if !run(ctx, component, fn) || ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		logger().Warn("Restarting crashed component",
+			zap.String("component", component),
+			zap.Duration("delay", delay))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// run runs fn and reports whether it panicked
+func run(ctx context.Context, component string, fn func(ctx context.Context)) (crashed bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			crashed = true
+			record(component, p, debug.Stack())
+		}
+	}()
+	fn(ctx)
+	return false
+}
+
+// Recover records a panic of a component without restarting it, call it
+// deferred, e.g. in goroutines running a single task
+func Recover(component string) {
+	if p := recover(); p != nil {
+		record(component, p, debug.Stack())
+	}
+}
+
+// record logs and stores the report of a crash
+func record(component string, p any, stack []byte) {
+	report := &Report{
+		Time:      time.Now().UTC(),
+		Component: component,
+		Panic:     fmt.Sprint(p),
+		Stack:     string(stack),
+		Version:   version.GetInfo().Version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	currentMu.RLock()
+	r := current
+	currentMu.RUnlock()
+
+	logger().Error("Component crashed",
+		zap.String("component", component),
+		zap.String("panic", report.Panic),
+		zap.String("stack", report.Stack))
+
+	if r == nil {
+		return
+	}
+	report.ConfigHash = r.configHash
+	if err := r.store(report); err != nil {
+		r.logger.Error("Failed to store crash report", zap.Error(err))
+	}
+}
+
+// logger returns the logger of crashes
+func logger() *zap.Logger {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	if current == nil {
+		return zap.L()
+	}
+	return current.logger
+}
+
+// store writes a report to the crash directory, removing the oldest reports
+// beyond the max
+func (r *recorder) store(report *Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create crash directory: %w", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal crash report: %w", err)
+	}
+	name := fmt.Sprintf("crash-%s-%s.json", report.Time.Format("20060102T150405.000000000Z"), sanitize(report.Component))
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write crash report: %w", err)
+	}
+
+	files, err := reportFiles(r.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxReports {
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// reportFiles returns the report files of a crash directory, oldest first
+func reportFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list crash reports: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// sanitize makes a component name safe for file names
+func sanitize(component string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, component)
+}
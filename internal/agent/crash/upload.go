@@ -0,0 +1,78 @@
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// Upload sends the stored crash reports as panic log entries, with the
+// report in their fields, and removes them once sent. It returns the number
+// of reports sent.
+func Upload(ctx context.Context, send func(ctx context.Context, entries []*types.AgentLogEntry) error) (int, error) {
+	currentMu.RLock()
+	r := current
+	currentMu.RUnlock()
+	if r == nil {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files, err := reportFiles(r.dir)
+	if err != nil || len(files) == 0 {
+		return 0, err
+	}
+
+	entries := make([]*types.AgentLogEntry, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read crash report: %w", err)
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			// Reports cut short by the agent going down are dropped
+			r.logger.Warn("Removing unreadable crash report", zap.String("file", file), zap.Error(err))
+			_ = os.Remove(file)
+			continue
+		}
+		entries = append(entries, report.entry())
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	if err := send(ctx, entries); err != nil {
+		return 0, fmt.Errorf("failed to upload crash reports: %w", err)
+	}
+	for _, file := range files {
+		_ = os.Remove(file)
+	}
+	return len(entries), nil
+}
+
+// entry returns the report as a log entry
+func (r *Report) entry() *types.AgentLogEntry {
+	return &types.AgentLogEntry{
+		Timestamp: r.Time,
+		Level:     "panic",
+		Caller:    r.Component,
+		Message:   "Component crashed: " + r.Panic,
+		Fields: map[string]any{
+			"crash_report": true,
+			"component":    r.Component,
+			"panic":        r.Panic,
+			"stack":        r.Stack,
+			"version":      r.Version,
+			"go_version":   r.GoVersion,
+			"platform":     r.Platform,
+			"config_hash":  r.ConfigHash,
+		},
+	}
+}
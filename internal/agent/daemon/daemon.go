@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Status represents the state of the installed service
+type Status string
+
+const (
+	StatusRunning      Status = "running"
+	StatusStopped      Status = "stopped"
+	StatusNotInstalled Status = "not installed"
+	StatusUnknown      Status = "unknown"
+)
+
+// ErrUnsupported is returned on platforms without a supported service manager
+var ErrUnsupported = errors.New("service management is not supported on this platform")
+
+// Config represents the service to register with the service manager
+type Config struct {
+	Name        string   // Unit, label or service name
+	DisplayName string   // Human-readable name
+	Description string   // Service description
+	Executable  string   // Absolute path of the binary
+	Args        []string // Arguments passed to the binary
+	User        string   // User the service runs as, empty for root
+	DataDir     string   // Writable state directory
+	LogDir      string   // Writable log directory
+}
+
+// Manager installs and controls the service with the service manager of
+// the platform: systemd on Linux, launchd on macOS and the service control
+// manager on Windows
+type Manager interface {
+	Install() error
+	Uninstall() error
+	Start() error
+	Stop() error
+	Status() (Status, error)
+}
+
+// New returns the service manager of the platform
+func New(cfg *Config) (Manager, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("service name is required")
+	}
+	if cfg.Executable == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate executable: %w", err)
+		}
+		cfg.Executable = exe
+	}
+	exe, err := filepath.Abs(cfg.Executable)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Executable = exe
+	return newManager(cfg)
+}
+
+// command runs a service manager command, its output is part of the error
+func command(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// writeFile writes a service definition, refusing to replace an existing one
+func writeFile(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("service already installed at %s", path)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// signalled returns a channel closed on SIGINT or SIGTERM
+func signalled() <-chan struct{} {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	go func() {
+		<-sigChan
+		close(stop)
+	}()
+	return stop
+}
@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// launchdDaemonDir is where system daemons are placed
+const launchdDaemonDir = "/Library/LaunchDaemons"
+
+// launchdPlist is the property list template
+var launchdPlist = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{{.Name}}</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>{{.Executable}}</string>
+{{- range .Args}}
+        <string>{{.}}</string>
+{{- end}}
+    </array>
+{{- if .User}}
+    <key>UserName</key>
+    <string>{{.User}}</string>
+{{- end}}
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <true/>
+    <key>StandardErrorPath</key>
+    <string>{{.LogDir}}/agent-error.log</string>
+    <key>StandardOutPath</key>
+    <string>{{.LogDir}}/agent.log</string>
+    <key>ThrottleInterval</key>
+    <integer>10</integer>
+    <key>HardResourceLimits</key>
+    <dict>
+        <key>NumberOfFiles</key>
+        <integer>4096</integer>
+    </dict>
+    <key>EnvironmentVariables</key>
+    <dict>
+        <key>PATH</key>
+        <string>/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin</string>
+    </dict>
+</dict>
+</plist>
+`))
+
+// launchd manages the service as a launchd system daemon
+type launchd struct {
+	config *Config
+	path   string
+}
+
+// newManager returns the launchd manager
+func newManager(cfg *Config) (Manager, error) {
+	return &launchd{config: cfg, path: filepath.Join(launchdDaemonDir, cfg.Name+".plist")}, nil
+}
+
+// Install writes the property list, the daemon is loaded by Start and at boot
+func (l *launchd) Install() error {
+	var buf bytes.Buffer
+	if err := launchdPlist.Execute(&buf, l.config); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.config.LogDir, 0o755); err != nil {
+		return err
+	}
+	return writeFile(l.path, buf.Bytes())
+}
+
+// Uninstall unloads the daemon and removes the property list
+func (l *launchd) Uninstall() error {
+	if _, err := os.Stat(l.path); errors.Is(err, os.ErrNotExist) {
+		return errors.New("service is not installed")
+	}
+	_, _ = command("launchctl", "bootout", "system/"+l.config.Name)
+	return os.Remove(l.path)
+}
+
+// Start loads and starts the daemon
+func (l *launchd) Start() error {
+	if _, err := command("launchctl", "bootstrap", "system", l.path); err != nil {
+		// Already loaded
+		_, err = command("launchctl", "kickstart", "system/"+l.config.Name)
+		return err
+	}
+	return nil
+}
+
+// Stop unloads the daemon, as KeepAlive would restart a killed one
+func (l *launchd) Stop() error {
+	_, err := command("launchctl", "bootout", "system/"+l.config.Name)
+	return err
+}
+
+// Status returns the state of the daemon
+func (l *launchd) Status() (Status, error) {
+	if _, err := os.Stat(l.path); errors.Is(err, os.ErrNotExist) {
+		return StatusNotInstalled, nil
+	}
+	out, err := command("launchctl", "print", "system/"+l.config.Name)
+	if err != nil {
+		// Not loaded
+		return StatusStopped, nil
+	}
+	if strings.Contains(out, "state = running") {
+		return StatusRunning, nil
+	}
+	return StatusStopped, nil
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Notify sends a state to systemd through sd_notify, a no-op when not
+// started by systemd with Type=notify
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract namespace sockets start with @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the interval systemd expects keep-alive pings
+// at, zero when the watchdog is disabled for this process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Ready reports the agent as started and pings the watchdog at half its
+// interval until ctx is done
+func Ready(ctx context.Context, logger *zap.Logger) {
+	if err := Notify("READY=1"); err != nil {
+		logger.Warn("Failed to notify systemd", zap.Error(err))
+	}
+
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := Notify("WATCHDOG=1"); err != nil {
+					logger.Warn("Failed to ping systemd watchdog", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stopping reports the agent as shutting down
+func Stopping() {
+	_ = Notify("STOPPING=1")
+}
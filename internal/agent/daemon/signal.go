@@ -0,0 +1,9 @@
+//go:build !windows
+
+package daemon
+
+// Stopped returns a channel closed when the agent is asked to stop by a
+// signal
+func Stopped(string) <-chan struct{} {
+	return signalled()
+}
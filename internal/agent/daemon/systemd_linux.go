@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// systemdUnitDir is where units of installed services are placed
+const systemdUnitDir = "/etc/systemd/system"
+
+// systemdUnit is the unit template, readiness and liveness are reported
+// through sd_notify
+var systemdUnit = template.Must(template.New("unit").Funcs(template.FuncMap{
+	"quote": systemdQuote,
+}).Parse(`[Unit]
+Description={{.DisplayName}}
+After=network-online.target
+Wants=network-online.target
+Documentation=https://github.com/haiyon/wameter
+
+[Service]
+Type=notify
+NotifyAccess=main
+{{- if .User}}
+User={{.User}}
+{{- end}}
+ExecStart={{quote .Executable}}{{range .Args}} {{quote .}}{{end}}
+Restart=always
+RestartSec=10
+TimeoutStartSec=30
+TimeoutStopSec=30
+WatchdogSec=60
+
+# Resource limits
+LimitNOFILE=4096
+MemoryMax=256M
+TasksMax=100
+
+# Security options
+NoNewPrivileges=yes
+ProtectSystem=full
+ProtectHome=true
+ReadWritePaths={{.LogDir}} {{.DataDir}}
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// systemd manages the service as a systemd unit
+type systemd struct {
+	config *Config
+	path   string
+}
+
+// newManager returns the systemd manager
+func newManager(cfg *Config) (Manager, error) {
+	return &systemd{config: cfg, path: filepath.Join(systemdUnitDir, cfg.Name+".service")}, nil
+}
+
+// Install writes the unit and enables it at boot
+func (s *systemd) Install() error {
+	var buf bytes.Buffer
+	if err := systemdUnit.Execute(&buf, s.config); err != nil {
+		return err
+	}
+	if err := writeFile(s.path, buf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := command("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	_, err := command("systemctl", "enable", s.config.Name)
+	return err
+}
+
+// Uninstall stops and disables the unit and removes it
+func (s *systemd) Uninstall() error {
+	if _, err := os.Stat(s.path); errors.Is(err, os.ErrNotExist) {
+		return errors.New("service is not installed")
+	}
+	_, _ = command("systemctl", "disable", "--now", s.config.Name)
+	if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	_, err := command("systemctl", "daemon-reload")
+	return err
+}
+
+// Start starts the unit
+func (s *systemd) Start() error {
+	_, err := command("systemctl", "start", s.config.Name)
+	return err
+}
+
+// Stop stops the unit
+func (s *systemd) Stop() error {
+	_, err := command("systemctl", "stop", s.config.Name)
+	return err
+}
+
+// Status returns the state of the unit
+func (s *systemd) Status() (Status, error) {
+	if _, err := os.Stat(s.path); errors.Is(err, os.ErrNotExist) {
+		return StatusNotInstalled, nil
+	}
+	// is-active exits non-zero for inactive units, the output tells
+	out, _ := command("systemctl", "is-active", s.config.Name)
+	switch strings.TrimSpace(out) {
+	case "active", "reloading", "activating":
+		return StatusRunning, nil
+	case "inactive", "failed", "deactivating":
+		return StatusStopped, nil
+	default:
+		return StatusUnknown, nil
+	}
+}
+
+// systemdQuote quotes an ExecStart argument when needed
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
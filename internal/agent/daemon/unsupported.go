@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package daemon
+
+// newManager returns ErrUnsupported
+func newManager(*Config) (Manager, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsService manages the service with the service control manager
+type windowsService struct {
+	config *Config
+}
+
+// newManager returns the service control manager
+func newManager(cfg *Config) (Manager, error) {
+	return &windowsService{config: cfg}, nil
+}
+
+// open opens the installed service
+func (w *windowsService) open() (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	s, err := m.OpenService(w.config.Name)
+	if err != nil {
+		_ = m.Disconnect()
+		return nil, nil, err
+	}
+	return m, s, nil
+}
+
+// Install registers the service to start automatically
+func (w *windowsService) Install() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	if s, err := m.OpenService(w.config.Name); err == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s already installed", w.config.Name)
+	}
+
+	s, err := m.CreateService(w.config.Name, w.config.Executable, mgr.Config{
+		DisplayName:      w.config.DisplayName,
+		Description:      w.config.Description,
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: w.config.User,
+	}, w.config.Args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Restart after crashes like the other platforms
+	return s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+}
+
+// Uninstall stops and removes the service
+func (w *windowsService) Uninstall() error {
+	m, s, err := w.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Disconnect() }()
+	defer func() { _ = s.Close() }()
+
+	_, _ = s.Control(svc.Stop)
+	return s.Delete()
+}
+
+// Start starts the service
+func (w *windowsService) Start() error {
+	m, s, err := w.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Disconnect() }()
+	defer func() { _ = s.Close() }()
+
+	return s.Start()
+}
+
+// Stop stops the service
+func (w *windowsService) Stop() error {
+	m, s, err := w.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Disconnect() }()
+	defer func() { _ = s.Close() }()
+
+	_, err = s.Control(svc.Stop)
+	return err
+}
+
+// Status returns the state of the service
+func (w *windowsService) Status() (Status, error) {
+	m, s, err := w.open()
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return StatusNotInstalled, nil
+	}
+	if err != nil {
+		return StatusUnknown, err
+	}
+	defer func() { _ = m.Disconnect() }()
+	defer func() { _ = s.Close() }()
+
+	st, err := s.Query()
+	if err != nil {
+		return StatusUnknown, err
+	}
+	switch st.State {
+	case svc.Running, svc.StartPending, svc.ContinuePending:
+		return StatusRunning, nil
+	case svc.Stopped, svc.StopPending, svc.Paused, svc.PausePending:
+		return StatusStopped, nil
+	default:
+		return StatusUnknown, nil
+	}
+}
+
+// serviceHandler reports the agent as running and relays stop requests
+type serviceHandler struct {
+	stop chan struct{}
+}
+
+// Execute implements svc.Handler
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			close(h.stop)
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// Stopped returns a channel closed when the agent is asked to stop, by a
+// signal or, when running as a service, by the service control manager
+func Stopped(name string) <-chan struct{} {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return signalled()
+	}
+
+	h := &serviceHandler{stop: make(chan struct{})}
+	go func() { _ = svc.Run(name, h) }()
+	return h.stop
+}
@@ -54,7 +54,7 @@ func (h *Handler) handleConfigReload(ctx context.Context, cmd Command) error {
 	}
 
 	// Load new configuration
-	newConfig, err := config.LoadConfig(configPath)
+	newConfig, err := config.LoadConfig(configPath, nil)
 	if err != nil {
 		return fmt.Errorf("failed to load new config: %w", err)
 	}
@@ -189,3 +189,22 @@ func (h *Handler) applyUpdate(pkg []byte) error {
 	// Add update application logic here
 	return fmt.Errorf("not implemented")
 }
+
+// handleCollect runs all collectors at once and reports the data, tagged
+// with the command ID so the server completes the command on receipt
+func (h *Handler) handleCollect(ctx context.Context, cmd Command) error {
+	var payload CommandPayload
+	if len(cmd.Payload) > 0 {
+		if err := json.Unmarshal(cmd.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid command payload: %w", err)
+		}
+	}
+	commandID, _ := payload.Args["command_id"].(string)
+
+	if err := h.manager.CollectNow(ctx, commandID); err != nil {
+		return err
+	}
+
+	h.logger.Info("Metrics collected on request", zap.String("command_id", commandID))
+	return nil
+}
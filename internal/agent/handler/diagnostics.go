@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+	"wameter/internal/logger"
+	"wameter/internal/version"
+
+	commonCfg "wameter/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// maxDiagnosticsLogTail is the size of the log file tail added to a bundle
+const maxDiagnosticsLogTail = 1 << 20
+
+// handleDiagnostics collects a diagnostics bundle and uploads it to the server
+func (h *Handler) handleDiagnostics(ctx context.Context, cmd Command) error {
+	var payload CommandPayload
+	if len(cmd.Payload) > 0 {
+		if err := json.Unmarshal(cmd.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid command payload: %w", err)
+		}
+	}
+	commandID, _ := payload.Args["command_id"].(string)
+
+	bundle, err := h.buildDiagnostics()
+	if err != nil {
+		return fmt.Errorf("failed to build diagnostics: %w", err)
+	}
+
+	if err := h.uploadDiagnostics(ctx, commandID, bundle); err != nil {
+		return err
+	}
+
+	h.logger.Info("Diagnostics uploaded",
+		zap.String("command_id", commandID),
+		zap.Int("size", len(bundle)))
+	return nil
+}
+
+// buildDiagnostics returns a gzipped tar archive with version information,
+// the redacted configuration, collector states, a goroutine dump and the
+// recent logs
+func (h *Handler) buildDiagnostics() ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	add := func(name string, content []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+
+	info, err := json.MarshalIndent(struct {
+		version.Info
+		AgentID    string    `json:"agent_id"`
+		Hostname   string    `json:"hostname"`
+		Goroutines int       `json:"goroutines"`
+		NumCPU     int       `json:"num_cpu"`
+		Timestamp  time.Time `json:"timestamp"`
+	}{
+		Info:       version.GetInfo(),
+		AgentID:    h.config.Agent.ID,
+		Hostname:   h.config.Agent.Hostname,
+		Goroutines: runtime.NumGoroutine(),
+		NumCPU:     runtime.NumCPU(),
+		Timestamp:  now,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add("version.json", info); err != nil {
+		return nil, err
+	}
+
+	var cfg bytes.Buffer
+	if err := commonCfg.PrintConfig(&cfg, h.config); err != nil {
+		return nil, err
+	}
+	if err := add("config.yaml", cfg.Bytes()); err != nil {
+		return nil, err
+	}
+
+	collectors, err := json.MarshalIndent(struct {
+		StartedAt  time.Time        `json:"started_at"`
+		Collectors []string         `json:"collectors"`
+		Stalls     map[string]int64 `json:"stalls,omitempty"`
+		Latest     any              `json:"latest"`
+	}{
+		StartedAt:  h.manager.StartTime(),
+		Collectors: h.manager.Names(),
+		Stalls:     h.manager.Stalls(),
+		Latest:     h.manager.Latest(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add("collectors.json", collectors); err != nil {
+		return nil, err
+	}
+
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 2); err != nil {
+		return nil, err
+	}
+	if err := add("goroutines.txt", goroutines.Bytes()); err != nil {
+		return nil, err
+	}
+
+	logs := strings.Join(logger.Recent(), "\n")
+	if err := add("logs/recent.log", []byte(logs)); err != nil {
+		return nil, err
+	}
+
+	if h.config.Log != nil && h.config.Log.File != "" {
+		tail, err := tailFile(h.config.Log.File, maxDiagnosticsLogTail)
+		if err != nil {
+			h.logger.Warn("Failed to read log file for diagnostics", zap.Error(err))
+		} else if err := add("logs/agent.log", tail); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// uploadDiagnostics uploads a diagnostics bundle to the server
+func (h *Handler) uploadDiagnostics(ctx context.Context, commandID string, bundle []byte) error {
+	endpoint := fmt.Sprintf("%s/v1/agents/%s/diagnostics",
+		h.config.Agent.Server.Address,
+		h.config.Agent.ID)
+	if commandID != "" {
+		endpoint += "?command_id=" + url.QueryEscape(commandID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(bundle))
+	if err != nil {
+		return fmt.Errorf("failed to create diagnostics request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if token := h.config.Agent.Server.AuthToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload diagnostics: %w", err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			h.logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("diagnostics upload failed: status=%d body=%s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// tailFile returns up to the last n bytes of a file
+func tailFile(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset := stat.Size() - n; offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	return io.ReadAll(f)
+}
@@ -11,11 +11,13 @@ import (
 	"sync"
 	"time"
 	"wameter/internal/retry"
+	"wameter/internal/tracing"
 	"wameter/internal/types"
 	"wameter/internal/version"
 
 	"wameter/internal/agent/collector"
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
 
 	"go.uber.org/zap"
 )
@@ -30,6 +32,7 @@ type Handler struct {
 	config     *config.Config
 	logger     *zap.Logger
 	server     *http.Server
+	client     *http.Client
 	commands   chan Command
 	wg         sync.WaitGroup
 	collectors map[string]collector.Collector
@@ -48,6 +51,14 @@ func NewHandler(cfg *config.Config, logger *zap.Logger, cm *collector.Manager) *
 		manager:    cm,
 	}
 
+	// Client of registration, heartbeats and diagnostics uploads
+	client, err := cfg.ServerClient()
+	if err != nil {
+		logger.Error("Failed to create server client", zap.Error(err))
+		client = tracing.DefaultClient
+	}
+	h.client = client
+
 	// Create HTTP server for receiving commands
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/command", h.handleCommand)
@@ -81,7 +92,10 @@ func (h *Handler) Start(ctx context.Context) error {
 
 	// Start command processor
 	h.wg.Add(1)
-	go h.processCommands(ctx)
+	go func() {
+		defer h.wg.Done()
+		crash.Supervise(ctx, "handler.commands", h.processCommands)
+	}()
 
 	// Start HTTP server
 	h.wg.Add(1)
@@ -95,7 +109,10 @@ func (h *Handler) Start(ctx context.Context) error {
 	// Start heartbeat
 	if !h.config.Agent.Standalone {
 		h.wg.Add(1)
-		go h.heartbeat(ctx)
+		go func() {
+			defer h.wg.Done()
+			crash.Supervise(ctx, "handler.heartbeat", h.heartbeat)
+		}()
 	}
 
 	return nil
@@ -169,11 +186,12 @@ func (h *Handler) registerAgentWithRetry(ctx context.Context) error {
 // registerAgent registers the agent with the server
 func (h *Handler) registerAgent(ctx context.Context) error {
 	agent := &types.AgentInfo{
-		ID:       h.config.Agent.ID,
-		Hostname: h.config.Agent.Hostname,
-		Version:  version.GetInfo().Version,
-		Port:     h.config.Agent.Port,
-		Status:   types.AgentStatusOnline,
+		ID:           h.config.Agent.ID,
+		Hostname:     h.config.Agent.Hostname,
+		Version:      version.GetInfo().Version,
+		Port:         h.config.Agent.Port,
+		Status:       types.AgentStatusOnline,
+		Capabilities: h.config.Agent.Server.Capabilities(),
 	}
 
 	// Build request
@@ -190,8 +208,11 @@ func (h *Handler) registerAgent(ctx context.Context) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if token := h.config.Agent.Server.AuthToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to register agent: %w", err)
 	}
@@ -206,6 +227,17 @@ func (h *Handler) registerAgent(ctx context.Context) error {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("failed to register agent: status=%d body=%s", resp.StatusCode, string(body))
 	}
+
+	// Servers before version negotiation return no protocol
+	var registered struct {
+		Data types.AgentInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err == nil && registered.Data.Protocol != nil {
+		h.logger.Info("Negotiated protocol with server",
+			zap.Int("api_version", registered.Data.Protocol.APIVersion),
+			zap.Strings("features", registered.Data.Protocol.Features),
+			zap.String("encoding", registered.Data.Protocol.Encoding))
+	}
 	return nil
 }
 
@@ -216,8 +248,6 @@ func (h *Handler) heartbeat(ctx context.Context) {
 		return
 	}
 
-	defer h.wg.Done()
-
 	interval := h.config.Agent.Heartbeat.Interval
 	if interval == 0 {
 		interval = 30 * time.Second
@@ -256,8 +286,11 @@ func (h *Handler) sendHeartbeat(ctx context.Context) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if token := h.config.Agent.Server.AuthToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send heartbeat: %w", err)
 	}
@@ -318,7 +351,7 @@ func (h *Handler) handleCommand(w http.ResponseWriter, r *http.Request) {
 // validateCommand validates the incoming command
 func (h *Handler) validateCommand(cmd Command) error {
 	switch cmd.Type {
-	case "config_reload", "collector_restart", "update_agent":
+	case "config_reload", "collector_restart", "update_agent", "diagnostics", "collect":
 		return nil
 	default:
 		return fmt.Errorf("unknown command type: %s", cmd.Type)
@@ -336,6 +369,10 @@ func (h *Handler) executeCommand(ctx context.Context, cmd Command) error {
 		return h.handleCollectorRestart(ctx, cmd)
 	case "update_agent":
 		return h.handleUpdateAgent(ctx, cmd)
+	case "diagnostics":
+		return h.handleDiagnostics(ctx, cmd)
+	case "collect":
+		return h.handleCollect(ctx, cmd)
 	default:
 		return fmt.Errorf("unknown command type: %s", cmd.Type)
 	}
@@ -343,8 +380,6 @@ func (h *Handler) executeCommand(ctx context.Context, cmd Command) error {
 
 // processCommands processes commands from the command channel
 func (h *Handler) processCommands(ctx context.Context) {
-	defer h.wg.Done()
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -0,0 +1,248 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/agent/config"
+	"wameter/internal/logger"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// name is the logger name of the shipper, its own entries are not shipped so
+// delivery problems do not feed back into the batches
+const name = "logship"
+
+// timeLayout is the time format of zapcore.ISO8601TimeEncoder
+const timeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// Shipper forwards the agent's own log entries to the server in batches
+type Shipper struct {
+	config  *config.Config
+	logger  *zap.Logger
+	client  *http.Client
+	levels  map[string]bool
+	pending []*types.AgentLogEntry
+	dropped chan chan int // Requests to drop the pending entries
+	failing bool
+	stop    func()
+	wg      sync.WaitGroup
+}
+
+// NewShipper creates new log shipper
+func NewShipper(cfg *config.Config, log *zap.Logger) *Shipper {
+	levels := make(map[string]bool)
+	for _, l := range types.AgentLogLevelsFrom(cfg.Agent.LogShipping.Level) {
+		levels[l] = true
+	}
+
+	s := &Shipper{
+		config:  cfg,
+		logger:  log.Named(name),
+		levels:  levels,
+		dropped: make(chan chan int),
+	}
+
+	client, err := cfg.ServerClient()
+	if err != nil {
+		s.logger.Error("Failed to create server client", zap.Error(err))
+		client = tracing.DefaultClient
+	}
+	s.client = client
+	return s
+}
+
+// Start starts following the log and shipping entries
+func (s *Shipper) Start(ctx context.Context) error {
+	lines, stop := logger.Follow(s.config.Agent.LogShipping.BufferSize)
+	s.stop = stop
+
+	s.wg.Add(1)
+	go s.shipLoop(ctx, lines)
+	return nil
+}
+
+// Stop stops the shipper after it sent the pending entries, or gave up
+func (s *Shipper) Stop() error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("log shipper stop timed out")
+	}
+}
+
+// DropPending drops the entries waiting to be shipped, to free memory, and
+// returns the number dropped
+func (s *Shipper) DropPending() int {
+	reply := make(chan int, 1)
+	select {
+	case s.dropped <- reply:
+		return <-reply
+	case <-time.After(time.Second):
+		// The loop is busy sending or stopped
+		return 0
+	}
+}
+
+// shipLoop collects entries and sends them when a batch is full or the flush
+// interval passed
+func (s *Shipper) shipLoop(ctx context.Context, lines <-chan string) {
+	defer s.wg.Done()
+	defer s.stop()
+
+	cfg := s.config.Agent.LogShipping
+	ticker := time.NewTicker(cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Last attempt for what is left
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.flush(flushCtx)
+			cancel()
+			return
+		case line := <-lines:
+			entry, ok := s.parse(line)
+			if !ok {
+				continue
+			}
+			s.pending = append(s.pending, entry)
+			if len(s.pending) > cfg.BufferSize {
+				s.pending = s.pending[len(s.pending)-cfg.BufferSize:]
+			}
+			// While the server is unreachable only the ticker retries
+			if len(s.pending) >= cfg.BatchSize && !s.failing {
+				s.flush(ctx)
+			}
+		case <-ticker.C:
+			s.flush(ctx)
+		case reply := <-s.dropped:
+			reply <- len(s.pending)
+			s.pending = nil
+		}
+	}
+}
+
+// flush sends the pending entries in batches, on failure they are kept for
+// the next flush
+func (s *Shipper) flush(ctx context.Context) {
+	batchSize := s.config.Agent.LogShipping.BatchSize
+
+	for len(s.pending) > 0 {
+		n := min(len(s.pending), batchSize)
+		if err := s.send(ctx, s.pending[:n]); err != nil {
+			if !s.failing {
+				s.logger.Warn("Failed to ship logs, keeping entries for retry", zap.Error(err))
+				s.failing = true
+			}
+			return
+		}
+
+		if s.failing {
+			s.logger.Info("Log shipping recovered")
+			s.failing = false
+		}
+		s.pending = s.pending[n:]
+	}
+	s.pending = nil
+}
+
+// send posts a batch of entries to the server
+func (s *Shipper) send(ctx context.Context, entries []*types.AgentLogEntry) error {
+	return Ship(ctx, s.config, s.client, entries)
+}
+
+// Ship posts a batch of log entries of the agent to the server
+func Ship(ctx context.Context, cfg *config.Config, client *http.Client, entries []*types.AgentLogEntry) error {
+	payload, err := json.Marshal(&types.AgentLogBatch{Entries: entries})
+	if err != nil {
+		return fmt.Errorf("failed to marshal log batch: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/logs",
+		cfg.Agent.Server.Address,
+		cfg.Agent.ID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if token := cfg.Agent.Server.AuthToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// parse converts a JSON log line to an entry, it reports false for lines that
+// are not shipped
+func (s *Shipper) parse(line string) (*types.AgentLogEntry, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, false
+	}
+	if fields["logger"] == name {
+		return nil, false
+	}
+
+	entry := &types.AgentLogEntry{
+		AgentID: s.config.Agent.ID,
+	}
+	entry.Level, _ = fields["level"].(string)
+	entry.Level = strings.ToLower(entry.Level)
+	if !s.levels[entry.Level] {
+		return nil, false
+	}
+	entry.Message, _ = fields["msg"].(string)
+	entry.Caller, _ = fields["caller"].(string)
+	if ts, ok := fields["time"].(string); ok {
+		entry.Timestamp, _ = time.Parse(timeLayout, ts)
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	for _, key := range []string{"level", "msg", "caller", "time"} {
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+
+	return entry, true
+}
@@ -40,6 +40,15 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
+// UpdateConfig runs update while no notification is being sent
+func (m *Manager) UpdateConfig(update func()) {
+	if m.notifier != nil {
+		m.notifier.UpdateConfig(update)
+		return
+	}
+	update()
+}
+
 // NotifyIPChange sends IP change notification
 func (m *Manager) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) {
 	m.notifier.NotifyIPChange(agent, change)
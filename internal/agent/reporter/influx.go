@@ -0,0 +1,165 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"wameter/internal/agent/config"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+	"wameter/internal/version"
+)
+
+// Line protocol escaping of measurements, tag keys and values, and string field values
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxWriter writes metrics in InfluxDB line protocol, one point per
+// interface with statistics
+type influxWriter struct {
+	config *config.InfluxConfig
+	tags   map[string]string // Collector tags added to each point
+	client *http.Client
+}
+
+// newInfluxWriter creates new line protocol writer
+func newInfluxWriter(cfg *config.Config) (*influxWriter, error) {
+	client, err := cfg.InfluxClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create influx client: %w", err)
+	}
+	return &influxWriter{
+		config: &cfg.Agent.Influx,
+		tags:   cfg.Collector.Tags,
+		client: client,
+	}, nil
+}
+
+// write sends the points of data, it returns nil when there are none
+func (w *influxWriter) write(ctx context.Context, data *types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "reporter.writeInflux")
+	defer func() { tracing.End(span, err) }()
+
+	payload := w.encode(data)
+	if len(payload) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if w.config.AuthToken != "" {
+		req.Header.Set("Authorization", w.config.AuthScheme+" "+w.config.AuthToken)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("influx returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// encode returns the line protocol points of the interfaces of data,
+// counters are integers and rates floats, timestamps in nanoseconds
+func (w *influxWriter) encode(data *types.MetricsData) []byte {
+	network := data.Metrics.Network
+	if network == nil {
+		return nil
+	}
+
+	// Point tags, sorted by key as recommended for write performance
+	tags := maps.Clone(w.tags)
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	tags["agent_id"] = data.AgentID
+	tags["host"] = data.Hostname
+
+	var buf bytes.Buffer
+	for _, name := range slices.Sorted(maps.Keys(network.Interfaces)) {
+		iface := network.Interfaces[name]
+		stats := iface.Statistics
+		if stats == nil {
+			continue
+		}
+		tags["interface"] = iface.Name
+		tags["type"] = iface.Type
+
+		buf.WriteString(measurementEscaper.Replace(w.config.Measurement))
+		for _, k := range slices.Sorted(maps.Keys(tags)) {
+			if tags[k] == "" {
+				continue
+			}
+			buf.WriteByte(',')
+			buf.WriteString(tagEscaper.Replace(k))
+			buf.WriteByte('=')
+			buf.WriteString(tagEscaper.Replace(tags[k]))
+		}
+
+		fields := []string{
+			"rx_bytes=" + integer(stats.RxBytes),
+			"tx_bytes=" + integer(stats.TxBytes),
+			"rx_packets=" + integer(stats.RxPackets),
+			"tx_packets=" + integer(stats.TxPackets),
+			"rx_errors=" + integer(stats.RxErrors),
+			"tx_errors=" + integer(stats.TxErrors),
+			"rx_dropped=" + integer(stats.RxDropped),
+			"tx_dropped=" + integer(stats.TxDropped),
+			"rx_bytes_rate=" + float(stats.RxBytesRate),
+			"tx_bytes_rate=" + float(stats.TxBytesRate),
+			"rx_packets_rate=" + float(stats.RxPacketsRate),
+			"tx_packets_rate=" + float(stats.TxPacketsRate),
+			"is_up=" + strconv.FormatBool(stats.IsUp),
+		}
+		if stats.Speed > 0 {
+			fields = append(fields,
+				"speed_mbps="+strconv.FormatInt(stats.Speed, 10)+"i",
+				"utilization="+float(stats.Utilization()))
+		}
+		if stats.OperState != "" {
+			fields = append(fields, `oper_state="`+stringEscaper.Replace(stats.OperState)+`"`)
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strings.Join(fields, ","))
+
+		timestamp := stats.CollectedAt
+		if timestamp.IsZero() {
+			timestamp = data.Timestamp
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// integer formats a counter as an integer field, InfluxDB 1.x lacks unsigned fields
+func integer(v uint64) string {
+	return strconv.FormatInt(int64(min(v, 1<<63-1)), 10) + "i"
+}
+
+// float formats a float field
+func float(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
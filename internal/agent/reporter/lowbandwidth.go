@@ -0,0 +1,233 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"wameter/internal/agent/config"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// maxAggregatedChanges bounds the IP changes held while reports are
+// aggregated, the latest are kept
+const maxAggregatedChanges = 256
+
+// budgetState represents the upload budget used on a day
+type budgetState struct {
+	Day  string `json:"day"` // Local date
+	Used int64  `json:"used"`
+}
+
+// uploadBudget holds the report bytes uploaded today to all servers
+type uploadBudget struct {
+	config    *config.LowBandwidthConfig
+	logger    *zap.Logger
+	state     budgetState
+	exhausted bool // Exhaustion of today's budget is logged
+	mu        sync.Mutex
+}
+
+// newUploadBudget creates the upload budget, continuing the budget used
+// today before a restart
+func newUploadBudget(cfg *config.LowBandwidthConfig, logger *zap.Logger) *uploadBudget {
+	b := &uploadBudget{config: cfg, logger: logger}
+
+	if data, err := os.ReadFile(cfg.StateFile); err == nil {
+		if err := json.Unmarshal(data, &b.state); err != nil {
+			logger.Warn("Ignoring invalid upload budget state",
+				zap.String("path", cfg.StateFile),
+				zap.Error(err))
+			b.state = budgetState{}
+		}
+	}
+	return b
+}
+
+// lowBandwidth holds the reporting state of low-bandwidth mode for a
+// server, it is only used by the worker of the server
+type lowBandwidth struct {
+	config    *config.LowBandwidthConfig
+	logger    *zap.Logger
+	budget    *uploadBudget
+	base      json.RawMessage    // Network state of the last report accepted, deltas apply to it
+	baseTime  time.Time          // Timestamp of that report
+	sinceFull int                // Deltas sent since the last full report
+	pending   *types.MetricsData // Reports aggregated while over budget
+}
+
+// newLowBandwidth creates the low-bandwidth state of a server
+func newLowBandwidth(cfg *config.LowBandwidthConfig, budget *uploadBudget, logger *zap.Logger) *lowBandwidth {
+	return &lowBandwidth{config: cfg, logger: logger, budget: budget}
+}
+
+// sendLowBandwidth sends metrics data to a server as a delta of the
+// previous report within the daily upload budget. Reports over budget are
+// aggregated into the next one.
+func (r *Reporter) sendLowBandwidth(ctx context.Context, t *target, data *types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "reporter.sendLowBandwidth")
+	defer func() { tracing.End(span, err) }()
+
+	lb := t.lowBandwidth
+	data = lb.aggregate(data)
+
+	for {
+		report, full, err := lb.encode(data)
+		if err != nil {
+			return err
+		}
+		body, encoding, err := r.encode(t, report)
+		if err != nil {
+			return err
+		}
+		if !lb.budget.spend(int64(len(body)), time.Now()) {
+			lb.pending = data
+			return nil
+		}
+
+		err = r.post(ctx, t, "/v1/metrics", body, encoding)
+		var serr *statusError
+		if errors.As(err, &serr) && serr.code == http.StatusConflict && !full {
+			r.logger.Info("Server does not hold the delta base, sending a full report",
+				zap.String("server", t.server.Address),
+				zap.Time("base", lb.baseTime))
+			lb.base = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		lb.accepted(data, full)
+		return nil
+	}
+}
+
+// encode returns the report sent for data, a delta of the base unless a
+// full report is due. Reports without network state, e.g. of IP changes,
+// are sent as they are.
+func (lb *lowBandwidth) encode(data *types.MetricsData) (*types.MetricsData, bool, error) {
+	if !data.HasState() || lb.base == nil || lb.sinceFull+1 >= lb.config.FullEvery {
+		return data, true, nil
+	}
+
+	patch, err := types.NetworkDelta(lb.base, data.Metrics.Network)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode metrics delta: %w", err)
+	}
+	report := *data
+	report.Metrics.Network = nil
+	report.Delta = &types.MetricsDelta{Base: lb.baseTime, Network: patch}
+	return &report, false, nil
+}
+
+// accepted makes the state of a report the server accepted the base of
+// the next delta
+func (lb *lowBandwidth) accepted(data *types.MetricsData, full bool) {
+	if !data.HasState() {
+		return
+	}
+
+	state, err := data.Metrics.Network.State()
+	if err != nil {
+		lb.logger.Warn("Failed to encode delta base", zap.Error(err))
+		lb.base = nil
+		return
+	}
+	lb.base, lb.baseTime = state, data.Timestamp
+	if full {
+		lb.sinceFull = 0
+	} else {
+		lb.sinceFull++
+	}
+}
+
+// aggregate merges data into the reports held back, the latest network
+// state is kept with the IP changes of all of them
+func (lb *lowBandwidth) aggregate(data *types.MetricsData) *types.MetricsData {
+	held := lb.pending
+	lb.pending = nil
+	if held == nil {
+		return data
+	}
+
+	latest := data
+	if !data.HasState() && held.HasState() {
+		latest = held
+	}
+	merged := *latest
+	if latest.Metrics.Network != nil {
+		network := *latest.Metrics.Network
+		merged.Metrics.Network = &network
+	} else {
+		merged.Metrics.Network = &types.NetworkState{Interfaces: make(map[string]*types.InterfaceInfo)}
+	}
+
+	var changes []types.IPChange
+	for _, report := range []*types.MetricsData{held, data} {
+		if report.Metrics.Network != nil {
+			changes = append(changes, report.Metrics.Network.IPChanges...)
+		}
+	}
+	if len(changes) > maxAggregatedChanges {
+		changes = changes[len(changes)-maxAggregatedChanges:]
+	}
+	merged.Metrics.Network.IPChanges = changes
+	return &merged
+}
+
+// spend takes n bytes from the budget of the day of now, false when they
+// do not fit
+func (b *uploadBudget) spend(n int64, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if day := now.Format(time.DateOnly); day != b.state.Day {
+		b.state = budgetState{Day: day}
+		b.exhausted = false
+	}
+
+	budget := b.config.DailyBudget
+	if budget > 0 && b.state.Used+n > budget {
+		if !b.exhausted {
+			b.logger.Warn("Daily upload budget exhausted, aggregating reports until tomorrow",
+				zap.String("used", utils.FormatBytes(uint64(b.state.Used))),
+				zap.String("budget", utils.FormatBytes(uint64(budget))))
+			b.exhausted = true
+		}
+		return false
+	}
+
+	b.state.Used += n
+	b.save()
+	return true
+}
+
+// save persists the budget used today
+func (b *uploadBudget) save() {
+	path := b.config.StateFile
+	data, err := json.Marshal(&b.state)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o750)
+	}
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		b.logger.Warn("Failed to persist upload budget",
+			zap.String("path", path),
+			zap.Error(err))
+	}
+}
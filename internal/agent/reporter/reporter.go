@@ -3,65 +3,91 @@ package reporter
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"sync"
 	"time"
 	"wameter/internal/agent/config"
+	"wameter/internal/agent/crash"
+	"wameter/internal/compress"
+	"wameter/internal/tracing"
 	"wameter/internal/types"
 	"wameter/internal/version"
 
 	"go.uber.org/zap"
 )
 
-// Reporter implements Reporter interface
+// Reporter sends metrics to the server unless in standalone mode, and in
+// line protocol to an InfluxDB endpoint when enabled
 type Reporter struct {
 	config *config.Config
 	logger *zap.Logger
-	client *http.Client
+	influx *influxWriter
 	buffer chan *types.MetricsData
 	wg     sync.WaitGroup
+
+	// Servers reported to, agent.server first, none in standalone mode
+	targets []*target
+	health  *targetHealth
 }
 
 // NewReporter creates new reporter
 func NewReporter(cfg *config.Config, logger *zap.Logger) *Reporter {
-	// Create HTTP client with TLS config if needed
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		IdleConnTimeout:     90 * time.Second,
-		DisableCompression:  true,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
-
-	if cfg.Agent.Server.TLS.Enabled {
-		tlsConfig, err := createTLSConfig(cfg.Agent.Server.TLS)
-		if err != nil {
-			logger.Error("Failed to create TLS config", zap.Error(err))
-		} else {
-			transport.TLSClientConfig = tlsConfig
-		}
+	r := &Reporter{
+		config: cfg,
+		logger: logger,
+		buffer: make(chan *types.MetricsData, 1000),
+		health: newTargetHealth(),
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   cfg.Agent.Server.Timeout,
+	if !cfg.Agent.Standalone {
+		var budget *uploadBudget
+		if cfg.Agent.LowBandwidth.Enabled {
+			budget = newUploadBudget(&cfg.Agent.LowBandwidth, logger)
+		}
+		servers := append([]config.ServerConfig{cfg.Agent.Server}, cfg.Agent.Reporting.Servers...)
+		for i := range servers {
+			r.targets = append(r.targets, newTarget(cfg, &servers[i], i == 0, budget, logger))
+		}
 	}
 
-	return &Reporter{
-		config: cfg,
-		logger: logger,
-		client: client,
-		buffer: make(chan *types.MetricsData, 1000),
+	if cfg.Agent.Influx.Enabled {
+		var err error
+		if r.influx, err = newInfluxWriter(cfg); err != nil {
+			logger.Error("Failed to create influx writer", zap.Error(err))
+		}
 	}
+
+	return r
 }
 
 // Start starts the reporter
 func (r *Reporter) Start(ctx context.Context) error {
 	r.wg.Add(1)
-	go r.processLoop(ctx)
+	go func() {
+		defer r.wg.Done()
+		crash.Supervise(ctx, "reporter", r.processLoop)
+	}()
+
+	for _, t := range r.targets {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			crash.Supervise(ctx, "reporter.target "+t.server.Address, func(ctx context.Context) {
+				r.runTarget(ctx, t)
+			})
+		}()
+	}
+	if len(r.targets) > 0 {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			crash.Supervise(ctx, "reporter.health", r.checkHealth)
+		}()
+	}
 	return nil
 }
 
@@ -100,31 +126,77 @@ func (r *Reporter) Report(data *types.MetricsData) error {
 	}
 }
 
+// DropBuffers drops the reports waiting to be sent, to free memory, and
+// returns the number dropped
+func (r *Reporter) DropBuffers() int {
+	dropped := drain(r.buffer)
+	for _, t := range r.targets {
+		dropped += drain(t.queue)
+	}
+	return dropped
+}
+
+// drain empties a report queue and returns the number of reports it held
+func drain(queue chan *types.MetricsData) int {
+	for n := 0; ; n++ {
+		select {
+		case <-queue:
+		default:
+			return n
+		}
+	}
+}
+
 // processLoop processes metrics data
 func (r *Reporter) processLoop(ctx context.Context) {
-	defer r.wg.Done()
-
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case data := <-r.buffer:
-			if err := r.sendData(ctx, data); err != nil {
-				r.logger.Error("Failed to send metrics",
-					zap.Error(err),
-					zap.Time("timestamp", data.Timestamp))
+			// Spread the reports of agents collecting at the same time,
+			// reports requested by a command are sent at once
+			if data.CommandID == "" && !r.jitter(ctx) {
+				return
+			}
+			r.prepare(data)
+			r.dispatch(data)
+			if r.influx != nil {
+				if err := r.influx.write(ctx, data); err != nil {
+					r.logger.Error("Failed to write metrics to influx",
+						zap.Error(err),
+						zap.Time("timestamp", data.Timestamp))
+				}
 			}
 		}
 	}
 }
 
-// sendData sends metrics data
-func (r *Reporter) sendData(ctx context.Context, data *types.MetricsData) error {
+// jitter waits a random duration up to the reporting jitter, it returns
+// false when ctx is done first
+func (r *Reporter) jitter(ctx context.Context) bool {
+	jitter := r.config.Agent.Reporting.Jitter
+	if jitter <= 0 {
+		return true
+	}
+	timer := time.NewTimer(rand.N(jitter))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// prepare sets the agent fields of metrics data before it is sent
+func (r *Reporter) prepare(data *types.MetricsData) {
 	// Set agent ID
 	data.AgentID = r.config.Agent.ID
 
 	// Set version
 	data.Version = version.GetInfo().Version
+	data.SchemaVersion = types.MetricsSchemaVersion
 
 	// Set hostname if not set
 	if data.Hostname == "" {
@@ -133,30 +205,65 @@ func (r *Reporter) sendData(ctx context.Context, data *types.MetricsData) error
 
 	// Set reported at
 	data.ReportedAt = time.Now()
+}
+
+// sendData sends metrics data to a server
+func (r *Reporter) sendData(ctx context.Context, t *target, data *types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "reporter.sendData")
+	defer func() { tracing.End(span, err) }()
 
 	r.logger.Debug("Sending metrics data",
+		zap.String("server", t.server.Address),
 		zap.String("agent_id", data.AgentID),
 		zap.String("hostname", data.Hostname),
 		zap.Time("timestamp", data.Timestamp))
 
-	// Convert to JSON
+	body, encoding, err := r.encode(t, data)
+	if err != nil {
+		return err
+	}
+	return r.post(ctx, t, "/v1/metrics", body, encoding)
+}
+
+// encode encodes metrics data as the request body, compressing large
+// reports, e.g. of hosts with many interfaces
+func (r *Reporter) encode(t *target, data *types.MetricsData) ([]byte, string, error) {
 	payload, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metrics data: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal metrics data: %w", err)
 	}
 
-	// Create request
-	url := fmt.Sprintf("%s/v1/metrics", r.config.Agent.Server.Address)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	server := t.server
+	if server.Compression == compress.None || len(payload) < server.CompressionThreshold {
+		return payload, compress.None, nil
+	}
+	encoded, err := compress.Encode(payload, server.Compression)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to compress metrics data: %w", err)
+	}
+	return encoded, server.Compression, nil
+}
+
+// post sends an encoded body to a server, responses other than 200 and 201
+// are returned as *statusError
+func (r *Reporter) post(ctx context.Context, t *target, path string, body []byte, encoding string) error {
+	url := t.server.Address + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if encoding != compress.None {
+		req.Header.Set("Content-Encoding", encoding)
+	}
 	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+	if token := t.server.AuthToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
 	// Send request
-	resp, err := r.client.Do(req)
+	resp, err := t.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -167,24 +274,10 @@ func (r *Reporter) sendData(ctx context.Context, data *types.MetricsData) error
 		}
 	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	return nil
 }
-
-// createTLSConfig creates TLS config
-func createTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
-	// Load client certificate
-	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load client certificate: %w", err)
-	}
-
-	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
-	}, nil
-}
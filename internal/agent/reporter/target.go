@@ -0,0 +1,299 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wameter/internal/agent/config"
+	"wameter/internal/compress"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// maxDeliveryAttempts bounds the attempts to send a report to healthy
+// servers, reports are held without attempts while servers are down
+const maxDeliveryAttempts = 5
+
+// target represents a server metrics are reported to, reports are queued
+// per server so a server that is down holds back no other
+type target struct {
+	server       *config.ServerConfig
+	primary      bool // agent.server, which the agent registers with
+	client       *http.Client
+	queue        chan *types.MetricsData
+	healthy      atomic.Bool
+	lowBandwidth *lowBandwidth // Nil unless in low-bandwidth mode
+}
+
+// newTarget creates the target of a server
+func newTarget(cfg *config.Config, server *config.ServerConfig, primary bool, budget *uploadBudget, logger *zap.Logger) *target {
+	client, err := cfg.TargetClient(server)
+	if err != nil {
+		logger.Error("Failed to create server client",
+			zap.String("server", server.Address),
+			zap.Error(err))
+		client = tracing.DefaultClient
+	}
+
+	t := &target{
+		server:  server,
+		primary: primary,
+		client:  client,
+		queue:   make(chan *types.MetricsData, cfg.Agent.Reporting.QueueSize),
+	}
+	t.healthy.Store(true)
+	if budget != nil {
+		t.lowBandwidth = newLowBandwidth(&cfg.Agent.LowBandwidth, budget, logger)
+	}
+	return t
+}
+
+// statusError represents a server response other than success
+type statusError struct {
+	code int
+	body string
+}
+
+// Error implements error
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether a report failing with err may succeed later,
+// requests the server rejects as such are not
+func retryable(err error) bool {
+	var serr *statusError
+	if !errors.As(err, &serr) {
+		return true
+	}
+	return serr.code >= http.StatusInternalServerError || serr.code == http.StatusTooManyRequests
+}
+
+// targetHealth signals servers coming back up
+type targetHealth struct {
+	recovered chan struct{} // Closed and replaced when a server is back up
+	mu        sync.Mutex
+}
+
+// newTargetHealth creates new target health
+func newTargetHealth() *targetHealth {
+	return &targetHealth{recovered: make(chan struct{})}
+}
+
+// wait returns a channel closed when a server is back up
+func (h *targetHealth) wait() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.recovered
+}
+
+// signal wakes the waiters for a server back up
+func (h *targetHealth) signal() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	close(h.recovered)
+	h.recovered = make(chan struct{})
+}
+
+// dispatch queues a report for the servers of the reporting mode, the
+// first healthy server in failover mode or every server in fanout mode
+func (r *Reporter) dispatch(data *types.MetricsData) {
+	if len(r.targets) == 0 {
+		return
+	}
+	if r.config.Agent.Reporting.Mode == config.ReportingFanout {
+		for _, t := range r.targets {
+			r.enqueue(t, data)
+		}
+		return
+	}
+	r.enqueue(r.active(), data)
+}
+
+// active returns the first healthy server, agent.server when all are down
+func (r *Reporter) active() *target {
+	for _, t := range r.targets {
+		if t.healthy.Load() {
+			return t
+		}
+	}
+	return r.targets[0]
+}
+
+// enqueue queues a report for a server, dropping its oldest report when
+// the queue is full
+func (r *Reporter) enqueue(t *target, data *types.MetricsData) {
+	for {
+		select {
+		case t.queue <- data:
+			return
+		default:
+		}
+		select {
+		case dropped := <-t.queue:
+			r.logger.Warn("Report queue of server full, dropping the oldest report",
+				zap.String("server", t.server.Address),
+				zap.Time("timestamp", dropped.Timestamp))
+		default:
+		}
+	}
+}
+
+// runTarget sends the reports queued for a server
+func (r *Reporter) runTarget(ctx context.Context, t *target) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-t.queue:
+			r.deliver(ctx, t, data)
+		}
+	}
+}
+
+// deliver sends a report to a server. While the server is down the report
+// is held until it is back up, or passed on to the next healthy server in
+// failover mode.
+func (r *Reporter) deliver(ctx context.Context, t *target, data *types.MetricsData) {
+	for attempt := 1; ; attempt++ {
+		if !r.await(ctx, t, data) {
+			return
+		}
+
+		err := r.send(ctx, t, data)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if !retryable(err) || attempt >= maxDeliveryAttempts {
+			r.logger.Error("Failed to send metrics",
+				zap.String("server", t.server.Address),
+				zap.Error(err),
+				zap.Time("timestamp", data.Timestamp))
+			return
+		}
+
+		if t.healthy.Swap(false) {
+			r.logger.Warn("Server down, holding reports until it is back up",
+				zap.String("server", t.server.Address),
+				zap.Error(err))
+		}
+	}
+}
+
+// await waits until a server is up. In failover mode a report for a server
+// that is down is passed on to the next healthy one instead. It returns
+// false when the report was passed on or ctx is done.
+func (r *Reporter) await(ctx context.Context, t *target, data *types.MetricsData) bool {
+	failover := r.config.Agent.Reporting.Mode == config.ReportingFailover
+	for {
+		recovered := r.health.wait()
+		if t.healthy.Load() {
+			return true
+		}
+		if failover {
+			if next := r.active(); next != t {
+				r.enqueue(next, data)
+				return false
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-recovered:
+			// Agents holding reports for the server do not flush them at once
+			if !r.jitter(ctx) {
+				return false
+			}
+		}
+	}
+}
+
+// send sends a report to a server, registering the agent with servers other
+// than agent.server that do not know it yet
+func (r *Reporter) send(ctx context.Context, t *target, data *types.MetricsData) error {
+	err := r.sendOnce(ctx, t, data)
+
+	var serr *statusError
+	if !t.primary && errors.As(err, &serr) && serr.code == http.StatusNotFound {
+		if err := r.register(ctx, t); err != nil {
+			return err
+		}
+		r.logger.Info("Registered with server", zap.String("server", t.server.Address))
+		err = r.sendOnce(ctx, t, data)
+	}
+	return err
+}
+
+// sendOnce sends a report to a server
+func (r *Reporter) sendOnce(ctx context.Context, t *target, data *types.MetricsData) error {
+	if t.lowBandwidth != nil {
+		return r.sendLowBandwidth(ctx, t, data)
+	}
+	return r.sendData(ctx, t, data)
+}
+
+// register registers the agent with a server
+func (r *Reporter) register(ctx context.Context, t *target) error {
+	agent := &types.AgentInfo{
+		ID:           r.config.Agent.ID,
+		Hostname:     r.config.Agent.Hostname,
+		Version:      version.GetInfo().Version,
+		Port:         r.config.Agent.Port,
+		Status:       types.AgentStatusOnline,
+		Capabilities: t.server.Capabilities(),
+	}
+	payload, err := json.Marshal(agent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent info: %w", err)
+	}
+	if err := r.post(ctx, t, "/v1/agents", payload, compress.None); err != nil {
+		return fmt.Errorf("failed to register agent: %w", err)
+	}
+	return nil
+}
+
+// checkHealth probes the servers marked down, signaling those back up
+func (r *Reporter) checkHealth(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Agent.Reporting.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, t := range r.targets {
+				if t.healthy.Load() || !r.ready(ctx, t) {
+					continue
+				}
+				t.healthy.Store(true)
+				r.logger.Info("Server back up", zap.String("server", t.server.Address))
+				r.health.signal()
+			}
+		}
+	}
+}
+
+// ready reports whether a server is ready to accept reports
+func (r *Reporter) ready(ctx context.Context, t *target) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.server.Address+"/readyz", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", "wameter-agent/"+version.GetInfo().Version)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,131 @@
+package resources
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cgroupRoot is where the cgroup hierarchies are mounted
+	cgroupRoot = "/sys/fs/cgroup"
+	// unlimitedV1 is the smallest memory limit of cgroup v1 read as unlimited
+	unlimitedV1 = 1 << 62
+)
+
+// Detect returns the limits of the cgroup of the agent, of cgroup v2 or v1.
+// Hybrid hosts mount cgroup v2 without controllers, their limits are of v1.
+func Detect() Limits {
+	paths := cgroupPaths()
+	if path, ok := paths[""]; ok {
+		if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+			return detectV2(path)
+		}
+	}
+	return detectV1(paths)
+}
+
+// cgroupPaths returns the cgroup paths of the agent by controller, the path
+// of cgroup v2 by the empty controller
+func cgroupPaths() map[string]string {
+	paths := make(map[string]string)
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return paths
+	}
+	defer func() { _ = f.Close() }()
+
+	// Lines are hierarchy-ID:controllers:path
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// readCgroupFile reads a file of a cgroup, in the hierarchy mounted at mount.
+// Within a cgroup namespace the cgroup of the agent is mounted as the root.
+func readCgroupFile(mount, path, name string) (string, bool) {
+	for _, dir := range []string{filepath.Join(mount, path), mount} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// detectV2 reads the limits of a cgroup v2
+func detectV2(path string) Limits {
+	var limits Limits
+	// cpu.max is "quota period" or "max period"
+	if value, ok := readCgroupFile(cgroupRoot, path, "cpu.max"); ok {
+		fields := strings.Fields(value)
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseFloat(fields[0], 64)
+			period, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 == nil && err2 == nil && period > 0 {
+				limits.CPU = quota / period
+			}
+		}
+	}
+	if value, ok := readCgroupFile(cgroupRoot, path, "memory.max"); ok && value != "max" {
+		limits.Memory, _ = strconv.ParseInt(value, 10, 64)
+	}
+	return limits
+}
+
+// detectV1 reads the limits of the cgroups v1 of the controllers
+func detectV1(paths map[string]string) Limits {
+	var limits Limits
+	if path, ok := paths["cpu"]; ok {
+		mount := filepath.Join(cgroupRoot, "cpu")
+		quotaValue, ok1 := readCgroupFile(mount, path, "cpu.cfs_quota_us")
+		periodValue, ok2 := readCgroupFile(mount, path, "cpu.cfs_period_us")
+		if ok1 && ok2 {
+			quota, err1 := strconv.ParseFloat(quotaValue, 64)
+			period, err2 := strconv.ParseFloat(periodValue, 64)
+			// The quota is -1 without a limit
+			if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+				limits.CPU = quota / period
+			}
+		}
+	}
+	if path, ok := paths["memory"]; ok {
+		if value, ok := readCgroupFile(filepath.Join(cgroupRoot, "memory"), path, "memory.limit_in_bytes"); ok {
+			if limit, err := strconv.ParseInt(value, 10, 64); err == nil && limit < unlimitedV1 {
+				limits.Memory = limit
+			}
+		}
+	}
+	return limits
+}
+
+// RSS returns the resident memory of the agent in bytes
+func RSS() (int64, error) {
+	// statm holds sizes in pages, the resident size second
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, os.ErrInvalid
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package resources
+
+import "runtime/metrics"
+
+// Detect returns no limits, cgroups exist on Linux only
+func Detect() Limits {
+	return Limits{}
+}
+
+// RSS returns the memory of the Go runtime held from the OS in bytes, which
+// approximates the resident memory of the agent
+func RSS() (int64, error) {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64()), nil
+}
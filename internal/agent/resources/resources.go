@@ -0,0 +1,80 @@
+// Package resources limits the CPU and memory the agent uses, from its
+// configuration and the limits of its cgroup
+package resources
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"wameter/internal/agent/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// memoryLimitShare is the share of the cgroup memory limit set as the
+	// soft limit of the Go runtime
+	memoryLimitShare = 0.7
+	// maxRSSShare is the share of the cgroup memory limit above which the
+	// watchdog relieves memory
+	maxRSSShare = 0.85
+)
+
+// Limits represents the limits of the cgroup of the agent, zero values are
+// unlimited
+type Limits struct {
+	CPU    float64 // Cores
+	Memory int64   // Bytes
+}
+
+// Settings represents the limits applied to the agent, zero values are
+// unlimited
+type Settings struct {
+	MaxProcs    int
+	MemoryLimit int64
+	MaxRSS      int64
+}
+
+// Apply sets GOMAXPROCS, the GC percent and the soft memory limit of the Go
+// runtime and returns the settings applied
+func Apply(cfg *config.ResourcesConfig, logger *zap.Logger) Settings {
+	limits := Detect()
+	settings := Settings{
+		MaxProcs:    cfg.MaxProcs,
+		MemoryLimit: cfg.MemoryLimit,
+		MaxRSS:      cfg.MaxRSS,
+	}
+
+	if settings.MaxProcs == 0 && limits.CPU > 0 {
+		settings.MaxProcs = min(runtime.NumCPU(), max(1, int(math.Ceil(limits.CPU))))
+	}
+	if settings.MaxProcs > 0 {
+		runtime.GOMAXPROCS(settings.MaxProcs)
+	}
+
+	if cfg.GCPercent > 0 {
+		debug.SetGCPercent(cfg.GCPercent)
+	}
+
+	if limits.Memory > 0 {
+		if settings.MemoryLimit == 0 {
+			settings.MemoryLimit = int64(float64(limits.Memory) * memoryLimitShare)
+		}
+		if settings.MaxRSS == 0 {
+			settings.MaxRSS = int64(float64(limits.Memory) * maxRSSShare)
+		}
+	}
+	if settings.MemoryLimit > 0 {
+		debug.SetMemoryLimit(settings.MemoryLimit)
+	}
+
+	logger.Info("Resource limits applied",
+		zap.Float64("cgroup_cpu", limits.CPU),
+		zap.Int64("cgroup_memory", limits.Memory),
+		zap.Int("max_procs", runtime.GOMAXPROCS(0)),
+		zap.Int("gc_percent", cfg.GCPercent),
+		zap.Int64("memory_limit", settings.MemoryLimit),
+		zap.Int64("max_rss", settings.MaxRSS))
+
+	return settings
+}
@@ -0,0 +1,103 @@
+package resources
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+	"wameter/internal/agent/config"
+
+	"go.uber.org/zap"
+)
+
+// recoverShare is the share of the max RSS below which the watchdog halves
+// the stretch of the collection interval again
+const recoverShare = 0.7
+
+// Watchdog watches the resident memory of the agent. Above the max RSS it
+// drops in-memory buffers, returns memory to the OS and doubles the
+// collection interval, up to the max interval factor, at each check.
+type Watchdog struct {
+	config   *config.ResourcesConfig
+	maxRSS   int64
+	logger   *zap.Logger
+	drops    []func() int
+	throttle func(factor int)
+	factor   int
+}
+
+// NewWatchdog creates a new memory watchdog of the max RSS
+func NewWatchdog(cfg *config.ResourcesConfig, maxRSS int64, logger *zap.Logger) *Watchdog {
+	return &Watchdog{
+		config: cfg,
+		maxRSS: maxRSS,
+		logger: logger,
+		factor: 1,
+	}
+}
+
+// OnDrop registers fn to drop a buffer under memory pressure, it returns the
+// number of entries dropped
+func (w *Watchdog) OnDrop(fn func() int) {
+	w.drops = append(w.drops, fn)
+}
+
+// OnThrottle registers fn to stretch the collection interval by a factor
+func (w *Watchdog) OnThrottle(fn func(factor int)) {
+	w.throttle = fn
+}
+
+// Run checks the resident memory until ctx is done
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rss, err := RSS()
+			if err != nil {
+				w.logger.Debug("Failed to read resident memory", zap.Error(err))
+				continue
+			}
+			w.check(rss)
+		}
+	}
+}
+
+// check relieves memory when rss is above the max, and lifts the throttling
+// step by step once it is well below
+func (w *Watchdog) check(rss int64) {
+	switch {
+	case rss > w.maxRSS:
+		dropped := 0
+		for _, drop := range w.drops {
+			dropped += drop()
+		}
+		debug.FreeOSMemory()
+		w.setFactor(min(w.factor*2, w.config.MaxIntervalFactor))
+
+		w.logger.Warn("Memory above limit, dropped buffers",
+			zap.Int64("rss", rss),
+			zap.Int64("max_rss", w.maxRSS),
+			zap.Int("dropped", dropped),
+			zap.Int("interval_factor", w.factor))
+	case w.factor > 1 && float64(rss) < float64(w.maxRSS)*recoverShare:
+		w.setFactor(w.factor / 2)
+		w.logger.Info("Memory back below limit",
+			zap.Int64("rss", rss),
+			zap.Int("interval_factor", w.factor))
+	}
+}
+
+// setFactor stretches the collection interval by factor
+func (w *Watchdog) setFactor(factor int) {
+	if factor == w.factor {
+		return
+	}
+	w.factor = factor
+	if w.throttle != nil {
+		w.throttle(factor)
+	}
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+)
+
+const (
+	// maxRecentChanges limits the number of IP changes shown
+	maxRecentChanges = 10
+
+	clearScreen = "\033[H\033[2J"
+	hideCursor  = "\033[?25l"
+	showCursor  = "\033[?25h"
+	bold        = "\033[1m"
+	dim         = "\033[2m"
+	green       = "\033[32m"
+	yellow      = "\033[33m"
+	red         = "\033[31m"
+	reset       = "\033[0m"
+)
+
+// Source provides the latest collected data
+type Source interface {
+	Latest() *types.MetricsData
+}
+
+// Dashboard renders a live terminal view of the collector state
+type Dashboard struct {
+	source   Source
+	out      io.Writer
+	hostname string
+	refresh  time.Duration
+	started  time.Time
+
+	lastSeen time.Time
+	changes  []types.IPChange
+}
+
+// NewDashboard creates new terminal dashboard
+func NewDashboard(source Source, out io.Writer, hostname string, refresh time.Duration) *Dashboard {
+	if refresh <= 0 {
+		refresh = time.Second
+	}
+
+	return &Dashboard{
+		source:   source,
+		out:      out,
+		hostname: hostname,
+		refresh:  refresh,
+		started:  time.Now(),
+	}
+}
+
+// Run redraws the dashboard until ctx is canceled
+func (d *Dashboard) Run(ctx context.Context) {
+	_, _ = io.WriteString(d.out, hideCursor)
+	defer func() {
+		_, _ = io.WriteString(d.out, showCursor+"\n")
+	}()
+
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+
+	for {
+		d.draw()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draw renders one frame
+func (d *Dashboard) draw() {
+	data := d.source.Latest()
+	d.trackChanges(data)
+
+	var buf bytes.Buffer
+	buf.WriteString(clearScreen)
+
+	fmt.Fprintf(&buf, "%swameter%s  %s  up %s  %s\n\n",
+		bold, reset, d.hostname,
+		time.Since(d.started).Round(time.Second),
+		time.Now().Format("2006-01-02 15:04:05"))
+
+	if data == nil || data.Metrics.Network == nil {
+		fmt.Fprintf(&buf, "%sWaiting for first collection...%s\n", dim, reset)
+		_, _ = d.out.Write(buf.Bytes())
+		return
+	}
+
+	network := data.Metrics.Network
+	fmt.Fprintf(&buf, "External IP: %s%s%s", bold, orDash(network.ExternalIP), reset)
+	if v6 := network.ExternalIPs[types.IPv6]; v6 != "" && v6 != network.ExternalIP {
+		fmt.Fprintf(&buf, "   External IPv6: %s%s%s", bold, v6, reset)
+	}
+	for _, version := range []types.IPVersion{types.IPv4, types.IPv6} {
+		if gw := network.Gateways[version]; gw != "" {
+			fmt.Fprintf(&buf, "   Gateway (%s): %s", version, gw)
+		}
+	}
+	fmt.Fprintf(&buf, "\n%sCollected %s ago%s\n\n", dim, time.Since(data.CollectedAt).Round(time.Second), reset)
+
+	d.writeInterfaces(&buf, network)
+	d.writeChanges(&buf)
+
+	fmt.Fprintf(&buf, "\n%sPress Ctrl+C to exit%s\n", dim, reset)
+	_, _ = d.out.Write(buf.Bytes())
+}
+
+// writeInterfaces renders the interface table
+func (d *Dashboard) writeInterfaces(buf *bytes.Buffer, network *types.NetworkState) {
+	fmt.Fprintf(buf, "%sInterfaces%s\n", bold, reset)
+
+	names := make([]string, 0, len(network.Interfaces))
+	for name := range network.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	// Status is the last column since color codes would skew the alignment
+	_, _ = fmt.Fprintln(tw, "NAME\tIPV4\tIPV6\tRX/s\tTX/s\tERRORS\tDROPPED\tSTATUS")
+	for _, name := range names {
+		iface := network.Interfaces[name]
+		status := colorStatus(iface.Status)
+		rx, tx, errs, dropped := "-", "-", "-", "-"
+		if stats := iface.Statistics; stats != nil {
+			rx = utils.FormatBytesRate(stats.RxBytesRate)
+			tx = utils.FormatBytesRate(stats.TxBytesRate)
+			errs = fmt.Sprint(stats.RxErrors + stats.TxErrors)
+			dropped = fmt.Sprint(stats.RxDropped + stats.TxDropped)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			name,
+			orDash(strings.Join(iface.IPv4, ",")),
+			orDash(strings.Join(iface.IPv6, ",")),
+			rx, tx, errs, dropped, status)
+	}
+	_ = tw.Flush()
+	buf.WriteString("\n")
+}
+
+// writeChanges renders the recent IP changes
+func (d *Dashboard) writeChanges(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "%sRecent changes%s\n", bold, reset)
+	if len(d.changes) == 0 {
+		fmt.Fprintf(buf, "%sNo changes since start%s\n", dim, reset)
+		return
+	}
+
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TIME\tINTERFACE\tVERSION\tACTION\tOLD\tNEW")
+	for i := len(d.changes) - 1; i >= 0; i-- {
+		change := d.changes[i]
+		iface := change.InterfaceName
+		if change.IsExternal {
+			iface = "external"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			change.Timestamp.Format("15:04:05"),
+			iface,
+			change.Version,
+			change.Action,
+			orDash(strings.Join(change.OldAddrs, ",")),
+			orDash(strings.Join(change.NewAddrs, ",")))
+	}
+	_ = tw.Flush()
+}
+
+// trackChanges remembers the IP changes of each new collection
+func (d *Dashboard) trackChanges(data *types.MetricsData) {
+	if data == nil || data.Metrics.Network == nil || !data.CollectedAt.After(d.lastSeen) {
+		return
+	}
+	d.lastSeen = data.CollectedAt
+
+	d.changes = append(d.changes, data.Metrics.Network.IPChanges...)
+	if len(d.changes) > maxRecentChanges {
+		d.changes = d.changes[len(d.changes)-maxRecentChanges:]
+	}
+}
+
+// colorStatus colors an interface status
+func colorStatus(status string) string {
+	switch status {
+	case "up":
+		return green + status + reset
+	case "down":
+		return red + status + reset
+	case "":
+		return "-"
+	default:
+		return yellow + status + reset
+	}
+}
+
+// orDash returns s, or a dash if s is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
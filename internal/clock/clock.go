@@ -0,0 +1,84 @@
+// Package clock tells the time of time-based behaviour, such as offline
+// detection, rate limiting and pruning, so tests can replace the system
+// clock with a fake one they advance deterministically.
+package clock
+
+import "time"
+
+// Clock tells the time and creates tickers and timers
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTicker(d time.Duration) *Ticker
+	NewTimer(d time.Duration) *Timer
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the system clock
+var Real Clock = realClock{}
+
+// Ticker delivers ticks at intervals, like time.Ticker
+type Ticker struct {
+	C     <-chan time.Time
+	stop  func()
+	reset func(d time.Duration)
+}
+
+// Stop turns off the ticker, no more ticks are sent
+func (t *Ticker) Stop() {
+	t.stop()
+}
+
+// Reset stops the ticker and resets its period to d
+func (t *Ticker) Reset(d time.Duration) {
+	t.reset(d)
+}
+
+// Timer delivers a single tick after a duration, like time.Timer
+type Timer struct {
+	C     <-chan time.Time
+	stop  func() bool
+	reset func(d time.Duration) bool
+}
+
+// Stop prevents the timer from firing, false if it already fired or was
+// stopped
+func (t *Timer) Stop() bool {
+	return t.stop()
+}
+
+// Reset changes the timer to fire after d, false if it had fired or been
+// stopped
+func (t *Timer) Reset(d time.Duration) bool {
+	return t.reset(d)
+}
+
+// realClock represents the system clock
+type realClock struct{}
+
+// Now implements Clock
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Since implements Clock
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+// NewTicker implements Clock
+func (realClock) NewTicker(d time.Duration) *Ticker {
+	t := time.NewTicker(d)
+	return &Ticker{C: t.C, stop: t.Stop, reset: t.Reset}
+}
+
+// NewTimer implements Clock
+func (realClock) NewTimer(d time.Duration) *Timer {
+	t := time.NewTimer(d)
+	return &Timer{C: t.C, stop: t.Stop, reset: t.Reset}
+}
+
+// After implements Clock
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
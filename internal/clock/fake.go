@@ -0,0 +1,160 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a clock whose time only moves when advanced, firing the tickers
+// and timers due on the way in order
+type Fake struct {
+	now     time.Time
+	waiters []*waiter
+	cond    *sync.Cond
+	mu      sync.Mutex
+}
+
+// waiter represents a pending ticker or timer of a fake clock
+type waiter struct {
+	at     time.Time
+	period time.Duration // Zero for timers
+	c      chan time.Time
+}
+
+// NewFake creates a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now implements Clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since implements Clock
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// NewTicker implements Clock
+func (f *Fake) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := f.add(d, d)
+	return &Ticker{
+		C:     w.c,
+		stop:  func() { f.remove(w) },
+		reset: func(d time.Duration) { f.schedule(w, d, d) },
+	}
+}
+
+// NewTimer implements Clock
+func (f *Fake) NewTimer(d time.Duration) *Timer {
+	w := f.add(d, 0)
+	return &Timer{
+		C:     w.c,
+		stop:  func() bool { return f.remove(w) },
+		reset: func(d time.Duration) bool { return f.schedule(w, d, 0) },
+	}
+}
+
+// After implements Clock
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.add(d, 0).c
+}
+
+// Advance moves the time forward by d, firing the tickers and timers due.
+// Like real tickers, a ticker whose tick was not received drops the next.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := f.now.Add(d)
+	for len(f.waiters) > 0 && !f.waiters[0].at.After(end) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+			f.insertLocked(w)
+		}
+	}
+	f.now = end
+}
+
+// Set moves the time to t, firing the tickers and timers due when t is
+// ahead of the current time
+func (f *Fake) Set(t time.Time) {
+	if d := t.Sub(f.Now()); d > 0 {
+		f.Advance(d)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
+
+// BlockUntil waits until n tickers and timers are pending, so a test can
+// advance the time once the goroutines under test wait on the clock
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// add creates a waiter firing after d
+func (f *Fake) add(d, period time.Duration) *waiter {
+	w := &waiter{c: make(chan time.Time, 1)}
+	f.schedule(w, d, period)
+	return w
+}
+
+// schedule makes a waiter fire after d and wakes BlockUntil, false if it
+// was not pending
+func (f *Fake) schedule(w *waiter, d, period time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	active := f.removeLocked(w)
+	w.at, w.period = f.now.Add(d), period
+	f.insertLocked(w)
+	f.cond.Broadcast()
+	return active
+}
+
+// insertLocked adds a waiter keeping them in firing order
+func (f *Fake) insertLocked(w *waiter) {
+	i := sort.Search(len(f.waiters), func(i int) bool { return f.waiters[i].at.After(w.at) })
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+}
+
+// remove drops a waiter, false if it was not pending
+func (f *Fake) remove(w *waiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.removeLocked(w)
+}
+
+// removeLocked drops a waiter, false if it was not pending
+func (f *Fake) removeLocked(w *waiter) bool {
+	for i, pending := range f.waiters {
+		if pending == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Package compress encodes and decodes request bodies by their HTTP
+// Content-Encoding.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// maxZstdWindow bounds the memory a zstd stream may make the decoder use
+const maxZstdWindow = 8 << 20
+
+// ErrUnsupported indicates a content encoding that is not supported
+var ErrUnsupported = errors.New("unsupported content encoding")
+
+// Supported reports whether an encoding is supported, an empty encoding
+// is the identity
+func Supported(encoding string) bool {
+	switch normalize(encoding) {
+	case "", "identity", None, Gzip, Zstd:
+		return true
+	}
+	return false
+}
+
+// Identity reports whether an encoding leaves the content as is
+func Identity(encoding string) bool {
+	switch normalize(encoding) {
+	case "", "identity", None:
+		return true
+	}
+	return false
+}
+
+// NewReader returns a reader of the content r encoded with encoding
+func NewReader(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch normalize(encoding) {
+	case "", "identity", None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip content: %w", err)
+		}
+		return zr, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd content: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, encoding)
+}
+
+// Encode returns data encoded with encoding
+func Encode(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch normalize(encoding) {
+	case "", "identity", None:
+		return data, nil
+	case Gzip:
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case Zstd:
+		zw, err := zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, encoding)
+	}
+	return buf.Bytes(), nil
+}
+
+// normalize returns an encoding in lower case without surrounding space
+func normalize(encoding string) string {
+	return strings.ToLower(strings.TrimSpace(encoding))
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables overriding config values,
+// e.g. WAMETER_SERVER_ADDRESS overrides server.address
+const EnvPrefix = "WAMETER"
+
+// redacted replaces secret values in printed configuration
+const redacted = "******"
+
+// secretKeys are config key names holding secrets, these are never accepted from flags
+var secretKeys = map[string]bool{
+	"password":     true,
+	"secret":       true,
+	"jwt_secret":   true,
+	"bot_token":    true,
+	"access_token": true,
+	"dsn":          true,
+	"webhook_url":  true,
+	"headers":      true,
+}
+
+// IsSecretKey reports whether a dotted config key holds a secret
+func IsSecretKey(key string) bool {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	return secretKeys[name] ||
+		strings.HasSuffix(name, "_password") ||
+		strings.HasSuffix(name, "_secret") ||
+		strings.HasSuffix(name, "_token")
+}
+
+// EnvKey returns the environment variable overriding a dotted config key
+func EnvKey(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Overrides holds key=value config overrides from command line flags, it
+// implements flag.Value so it can be used with a repeatable -set flag
+type Overrides map[string]string
+
+// String returns the overrides as comma separated key=value pairs
+func (o Overrides) String() string {
+	pairs := make([]string, 0, len(o))
+	for k, v := range o {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses a key=value override, secrets must come from the environment
+func (o Overrides) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || key == "" {
+		return fmt.Errorf("invalid override %q, expected key=value", s)
+	}
+	if IsSecretKey(key) {
+		return fmt.Errorf("%s is a secret, set it with %s instead", key, EnvKey(key))
+	}
+	o[key] = value
+	return nil
+}
+
+// Load unmarshals the config read by v into target, layered as
+// file < environment variables < command line overrides
+func Load(v *viper.Viper, target any, overrides Overrides) error {
+	keys := Keys(target)
+
+	// Environment variables, bound explicitly so keys missing from the file are picked up
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range keys {
+		if err := v.BindEnv(key); err != nil {
+			return fmt.Errorf("failed to bind env for %s: %w", key, err)
+		}
+	}
+
+	// Command line overrides
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	for key, value := range overrides {
+		if !known[key] {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		v.Set(key, value)
+	}
+
+	if err := v.Unmarshal(target); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	return nil
+}
+
+// Keys returns the dotted keys of all leaf values of a config struct
+func Keys(cfg any) []string {
+	var keys []string
+	walkKeys(reflect.TypeOf(cfg), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// walkKeys collects the leaf keys of t under prefix
+func walkKeys(t reflect.Type, prefix string, keys *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		*keys = append(*keys, prefix)
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		walkKeys(f.Type, name, keys)
+	}
+}
+
+// fieldKey returns the config key of a struct field
+func fieldKey(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		// mapstructure matches field names case-insensitively
+		name = strings.ToLower(f.Name)
+	}
+	return name, true
+}
+
+// PrintConfig writes cfg as YAML with secret values redacted
+func PrintConfig(w io.Writer, cfg any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(settingsOf(reflect.ValueOf(cfg), "", true)); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// Flatten returns the values of cfg keyed by dotted config key, secret values
+// are redacted if redact is set
+func Flatten(cfg any, redact bool) map[string]any {
+	out := make(map[string]any)
+	flatten(settingsOf(reflect.ValueOf(cfg), "", redact), "", out)
+	return out
+}
+
+// flatten collects the leaf values of settings under prefix
+func flatten(settings any, prefix string, out map[string]any) {
+	m, ok := settings.(map[string]any)
+	if !ok {
+		out[prefix] = settings
+		return
+	}
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flatten(v, key, out)
+	}
+}
+
+// settingsOf converts a config value to plain YAML values keyed like the config file
+func settingsOf(v reflect.Value, key string, redact bool) any {
+	if redact && key != "" && IsSecretKey(key) {
+		if v.IsZero() {
+			return ""
+		}
+		return redacted
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Duration:
+		return val.String()
+	case time.Time:
+		return val
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldKey(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			child := name
+			if key != "" {
+				child = key + "." + name
+			}
+			out[name] = settingsOf(v.Field(i), child, redact)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = settingsOf(iter.Value(), "", redact)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = settingsOf(v.Index(i), "", redact)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// WalkSecrets calls fn for every settable string field of cfg holding a secret
+func WalkSecrets(cfg any, fn func(key string, field reflect.Value)) {
+	walkSecrets(reflect.ValueOf(cfg), "", fn)
+}
+
+// walkSecrets visits the secret string fields of v under prefix
+func walkSecrets(v reflect.Value, prefix string, fn func(key string, field reflect.Value)) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldKey(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			walkSecrets(v.Field(i), name, fn)
+		}
+	case reflect.String:
+		if v.CanSet() && IsSecretKey(prefix) {
+			fn(prefix, v)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -19,41 +20,215 @@ type NotifyConfig struct {
 	DingTalk DingTalkConfig `mapstructure:"dingtalk"`
 	Discord  DiscordConfig  `mapstructure:"discord"`
 	Feishu   FeishuConfig   `mapstructure:"feishu"`
+	Exec     ExecConfig     `mapstructure:"exec"`
 
 	// Global notification settings
 	RetryAttempts int                   `mapstructure:"retry_attempts"`
 	RetryDelay    time.Duration         `mapstructure:"retry_delay"`
 	MaxBatchSize  int                   `mapstructure:"max_batch_size"`
 	RateLimit     NotifyRateLimitConfig `mapstructure:"rate_limit"`
+	Failover      NotifyFailoverConfig  `mapstructure:"failover"`
+
+	// Language of the templated channels, en by default, each channel can
+	// override it. Timezone of the times in messages, server.timezone on the
+	// server and local on the agent by default.
+	Locale   string `mapstructure:"locale"`
+	Timezone string `mapstructure:"timezone"`
+
+	// Proxy, timeouts and TLS trust of the HTTP channels, email connects
+	// directly and only fetches its OAuth2 tokens through them
+	Proxy *ProxyConfig      `mapstructure:"proxy"`
+	HTTP  *HTTPClientConfig `mapstructure:"http"`
 }
 
-// NotifyRateLimitConfig represents rate limiting configuration
+// NotifyRateLimitConfig represents rate limiting configuration, a token
+// bucket of max_events per interval is kept by event type, and by channel
+// when per_channel is set
 type NotifyRateLimitConfig struct {
 	Enabled    bool          `mapstructure:"enabled"`
 	Interval   time.Duration `mapstructure:"interval"`
 	MaxEvents  int           `mapstructure:"max_events"`
+	Burst      int           `mapstructure:"burst"` // Size of the buckets, max_events by default
 	PerChannel bool          `mapstructure:"per_channel"`
+	// Queue delays notifications over the limit until a token is available
+	// instead of dropping them, those that would wait longer than max_delay
+	// are still dropped
+	Queue    bool          `mapstructure:"queue"`
+	MaxDelay time.Duration `mapstructure:"max_delay"` // Interval by default
 }
 
+// BurstSize returns the size of the token buckets
+func (cfg *NotifyRateLimitConfig) BurstSize() int {
+	if cfg.Burst > 0 {
+		return cfg.Burst
+	}
+	return cfg.MaxEvents
+}
+
+// QueueDelay returns the longest a queued notification waits for a token
+func (cfg *NotifyRateLimitConfig) QueueDelay() time.Duration {
+	if cfg.MaxDelay > 0 {
+		return cfg.MaxDelay
+	}
+	return cfg.Interval
+}
+
+// Validate rate limiting configuration
+func (cfg *NotifyRateLimitConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if cfg.MaxEvents <= 0 {
+		return fmt.Errorf("max_events must be positive")
+	}
+	if cfg.Burst < 0 || cfg.MaxDelay < 0 {
+		return fmt.Errorf("burst and max_delay cannot be negative")
+	}
+	return nil
+}
+
+// NotifyFailoverConfig represents the failover of notification channels,
+// notifications of a channel that keeps failing are sent through its
+// fallback until it is healthy again
+type NotifyFailoverConfig struct {
+	Enabled       bool              `mapstructure:"enabled"`
+	Failures      int               `mapstructure:"failures"`       // Consecutive failed deliveries before failing over, 3 by default
+	CheckInterval time.Duration     `mapstructure:"check_interval"` // Of the health checks of failed over channels, 1m by default
+	Fallbacks     map[string]string `mapstructure:"fallbacks"`      // Fallback channel by channel, e.g. slack: email
+}
+
+// FailureThreshold returns the consecutive failed deliveries before failing over
+func (cfg *NotifyFailoverConfig) FailureThreshold() int {
+	if cfg.Failures > 0 {
+		return cfg.Failures
+	}
+	return 3
+}
+
+// Interval returns the interval of the health checks of failed over channels
+func (cfg *NotifyFailoverConfig) Interval() time.Duration {
+	if cfg.CheckInterval > 0 {
+		return cfg.CheckInterval
+	}
+	return time.Minute
+}
+
+// NotifyChannels are the names of the notification channels
+var NotifyChannels = []string{"email", "telegram", "webhook", "slack", "wechat", "dingtalk", "discord", "feishu", "exec"}
+
+// Email connection security modes
+const (
+	EmailSecurityStartTLS = "starttls" // Upgrade with STARTTLS when offered
+	EmailSecurityTLS      = "tls"      // Implicit TLS, usually port 465
+	EmailSecurityNone     = "none"     // Never upgrade
+)
+
+// Email SMTP authentication mechanisms
+const (
+	EmailAuthPlain   = "plain"
+	EmailAuthLogin   = "login"
+	EmailAuthCRAMMD5 = "cram-md5"
+	EmailAuthXOAuth2 = "xoauth2" // Gmail and Office 365 OAuth2 bearer tokens
+	EmailAuthNone    = "none"
+)
+
 // EmailConfig represents the email notification configuration
 type EmailConfig struct {
-	Enabled    bool              `mapstructure:"enabled"`
-	SMTPServer string            `mapstructure:"smtp_server"`
-	SMTPPort   int               `mapstructure:"smtp_port"`
-	Username   string            `mapstructure:"username"`
-	Password   string            `mapstructure:"password"`
-	From       string            `mapstructure:"from"`
+	Enabled    bool               `mapstructure:"enabled"`
+	SMTPServer string             `mapstructure:"smtp_server"`
+	SMTPPort   int                `mapstructure:"smtp_port"`
+	Username   string             `mapstructure:"username"`
+	Password   string             `mapstructure:"password"`
+	From       string             `mapstructure:"from"`
+	To         []string           `mapstructure:"to"`
+	UseTLS     bool               `mapstructure:"use_tls"`  // Deprecated: same as security tls
+	Security   string             `mapstructure:"security"` // starttls, tls or none
+	Auth       string             `mapstructure:"auth"`     // plain, login, cram-md5, xoauth2 or none
+	OAuth2     *EmailOAuth2Config `mapstructure:"oauth2"`   // Token source of xoauth2
+	Timeout    time.Duration      `mapstructure:"timeout"`  // Connect and each SMTP exchange
+
+	// Authenticated connections are kept for reuse across notifications
+	MaxIdleConns      int           `mapstructure:"max_idle_conns"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	DisableKeepAlives bool          `mapstructure:"disable_keep_alives"`
+
+	// Recipient groups by notification, To receives the unrouted ones
+	Routes []EmailRouteConfig `mapstructure:"routes"`
+	Batch  EmailBatchConfig   `mapstructure:"batch"`
+
+	Locale    string            `mapstructure:"locale"` // Overrides the global locale
+	Templates map[string]string `mapstructure:"templates"`
+}
+
+// Notification types matched by email routes, named like their templates
+var NotificationTypes = []string{
+	"agent_offline", "agent_online", "agent_missing",
+	"network_error", "high_utilization", "ip_change", "report", "rule_alert",
+	"heartbeat",
+}
+
+// Notification severities matched by email routes
+var NotificationSeverities = []string{"info", "warning", "critical"}
+
+// Locales of the notification templates, the first is the default
+var NotifyLocales = []string{"en", "zh-CN"}
+
+// EmailRouteConfig represents a recipient group and the notifications routed
+// to it, a notification is sent to every matching group
+type EmailRouteConfig struct {
+	Name       string            `mapstructure:"name"`
 	To         []string          `mapstructure:"to"`
-	UseTLS     bool              `mapstructure:"use_tls"`
-	Templates  map[string]string `mapstructure:"templates"`
+	Types      []string          `mapstructure:"types"`      // Any type when empty
+	Severities []string          `mapstructure:"severities"` // Any severity when empty
+	AgentTags  map[string]string `mapstructure:"agent_tags"` // Required tags, an empty value matches any value
 }
 
+// EmailBatchConfig represents the batching of notifications arriving within a
+// window into a single email per recipient group, reports are never batched
+type EmailBatchConfig struct {
+	Window  time.Duration `mapstructure:"window"`   // 0 disables batching
+	MaxSize int           `mapstructure:"max_size"` // Sends a batch early once reached
+}
+
+// EmailOAuth2Config represents the OAuth2 token source of XOAUTH2, tokens are
+// refreshed with the refresh token, or client credentials without one
+type EmailOAuth2Config struct {
+	TokenURL     string   `mapstructure:"token_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RefreshToken string   `mapstructure:"refresh_token"`
+	Scopes       []string `mapstructure:"scopes"`
+	AccessToken  string   `mapstructure:"access_token"` // Static token, used without a token URL
+}
+
+// Telegram update delivery modes of button presses
+const (
+	TelegramUpdatesPoll    = "poll"
+	TelegramUpdatesWebhook = "webhook"
+)
+
 // TelegramConfig represents the telegram notification configuration
 type TelegramConfig struct {
-	Enabled  bool     `mapstructure:"enabled"`
-	BotToken string   `mapstructure:"bot_token"`
-	ChatIDs  []string `mapstructure:"chat_ids"`
-	Format   string   `mapstructure:"format"` // text, html, markdown
+	Enabled  bool                  `mapstructure:"enabled"`
+	BotToken string                `mapstructure:"bot_token"`
+	ChatIDs  []string              `mapstructure:"chat_ids"`
+	Format   string                `mapstructure:"format"` // text, html, markdown
+	Buttons  TelegramButtonsConfig `mapstructure:"buttons"`
+}
+
+// TelegramButtonsConfig represents the Acknowledge, Silence and View buttons
+// of alerts, presses are only handled by the server
+type TelegramButtonsConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	SilenceFor    time.Duration `mapstructure:"silence_for"`    // Maintenance started by Silence, 1h by default
+	ViewURL       string        `mapstructure:"view_url"`       // Link of View, {agent_id} is replaced
+	Updates       string        `mapstructure:"updates"`        // poll or webhook
+	WebhookURL    string        `mapstructure:"webhook_url"`    // Public URL of /v1/telegram/webhook, registered on start
+	WebhookSecret string        `mapstructure:"webhook_secret"` // Secret token Telegram sends with webhook updates
+	AllowedUsers  []string      `mapstructure:"allowed_users"`  // Usernames or user IDs, any member of the chats when empty
 }
 
 // WebhookConfig represents the webhook notification configuration
@@ -68,7 +243,20 @@ type WebhookConfig struct {
 	CommonData map[string]any    `mapstructure:"common_data"`
 }
 
-// SlackConfig represents Slack notification configuration
+// ExecConfig represents the configuration of a notifier running an external
+// command, which receives the webhook payload as JSON on stdin
+type ExecConfig struct {
+	Enabled    bool              `mapstructure:"enabled"`
+	Command    string            `mapstructure:"command"`     // Executable path or name in PATH
+	Args       []string          `mapstructure:"args"`        // Arguments, not expanded by a shell
+	Env        map[string]string `mapstructure:"env"`         // Added to the server environment, names are uppercased
+	Dir        string            `mapstructure:"dir"`         // Working directory
+	Timeout    time.Duration     `mapstructure:"timeout"`     // Per run, the command is killed after it
+	MaxRetries int               `mapstructure:"max_retries"` // Reruns after a failed run
+}
+
+// SlackConfig represents Slack notification configuration, messages are
+// posted with the bot token when set and to the incoming webhook otherwise
 type SlackConfig struct {
 	Enabled    bool              `mapstructure:"enabled"`
 	WebhookURL string            `mapstructure:"webhook_url"`
@@ -77,7 +265,13 @@ type SlackConfig struct {
 	IconEmoji  string            `mapstructure:"icon_emoji"`
 	IconURL    string            `mapstructure:"icon_url"`
 	BotToken   string            `mapstructure:"bot_token"`
+	Locale     string            `mapstructure:"locale"` // Overrides the global locale
 	Templates  map[string]string `mapstructure:"templates"`
+
+	// Follow-ups of an alert within the TTL, e.g. its recovery, are replies
+	// in the thread of the alert, this requires the bot token
+	ThreadTTL      time.Duration `mapstructure:"thread_ttl"`
+	DisableThreads bool          `mapstructure:"disable_threads"`
 }
 
 // WeChatConfig represents WeChat Work notification configuration
@@ -89,6 +283,7 @@ type WeChatConfig struct {
 	ToUser    string            `mapstructure:"to_user"`
 	ToParty   string            `mapstructure:"to_party"`
 	ToTag     string            `mapstructure:"to_tag"`
+	Locale    string            `mapstructure:"locale"` // Overrides the global locale
 	Templates map[string]string `mapstructure:"templates"`
 }
 
@@ -100,6 +295,7 @@ type DingTalkConfig struct {
 	AtMobiles   []string          `mapstructure:"at_mobiles"`
 	AtUserIds   []string          `mapstructure:"at_user_ids"`
 	AtAll       bool              `mapstructure:"at_all"`
+	Locale      string            `mapstructure:"locale"` // Overrides the global locale
 	Templates   map[string]string `mapstructure:"templates"`
 }
 
@@ -109,6 +305,7 @@ type DiscordConfig struct {
 	WebhookURL string            `mapstructure:"webhook_url"`
 	Username   string            `mapstructure:"username"`
 	AvatarURL  string            `mapstructure:"avatar_url"`
+	Locale     string            `mapstructure:"locale"` // Overrides the global locale
 	Templates  map[string]string `mapstructure:"templates"`
 }
 
@@ -117,9 +314,34 @@ type FeishuConfig struct {
 	Enabled    bool              `mapstructure:"enabled"`
 	WebhookURL string            `mapstructure:"webhook_url"`
 	Secret     string            `mapstructure:"secret"`
+	Locale     string            `mapstructure:"locale"` // Overrides the global locale
 	Templates  map[string]string `mapstructure:"templates"`
 }
 
+// ChannelLocale returns the locale of a channel, the channel locale when set
+// and the global locale otherwise
+func (cfg *NotifyConfig) ChannelLocale(locale string) string {
+	if locale != "" {
+		return locale
+	}
+	if cfg.Locale != "" {
+		return cfg.Locale
+	}
+	return NotifyLocales[0]
+}
+
+// Location returns the timezone of the times in messages
+func (cfg *NotifyConfig) Location() *time.Location {
+	if cfg.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
 // Validate notification configuration
 func (cfg *NotifyConfig) Validate() error {
 	if !cfg.Enabled {
@@ -133,6 +355,36 @@ func (cfg *NotifyConfig) Validate() error {
 	if cfg.RetryDelay <= 0 {
 		return fmt.Errorf("retry_delay must be positive")
 	}
+	if err := cfg.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("invalid rate limit config: %w", err)
+	}
+	if err := cfg.validateFailover(); err != nil {
+		return fmt.Errorf("invalid failover config: %w", err)
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy config: %w", err)
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return fmt.Errorf("invalid http config: %w", err)
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	for name, locale := range map[string]string{
+		"locale":          cfg.Locale,
+		"email.locale":    cfg.Email.Locale,
+		"slack.locale":    cfg.Slack.Locale,
+		"wechat.locale":   cfg.WeChat.Locale,
+		"dingtalk.locale": cfg.DingTalk.Locale,
+		"discord.locale":  cfg.Discord.Locale,
+		"feishu.locale":   cfg.Feishu.Locale,
+	} {
+		if locale != "" && !slices.Contains(NotifyLocales, locale) {
+			return fmt.Errorf("invalid %s: %s, must be one of %s", name, locale, strings.Join(NotifyLocales, ", "))
+		}
+	}
 
 	if cfg.Email.Enabled {
 		if err := cfg.Email.Validate(); err != nil {
@@ -182,6 +434,34 @@ func (cfg *NotifyConfig) Validate() error {
 		}
 	}
 
+	if cfg.Exec.Enabled {
+		if err := cfg.Exec.Validate(); err != nil {
+			return fmt.Errorf("invalid exec config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateFailover validates the failover of notification channels
+func (cfg *NotifyConfig) validateFailover() error {
+	if !cfg.Failover.Enabled {
+		return nil
+	}
+	if cfg.Failover.Failures < 0 || cfg.Failover.CheckInterval < 0 {
+		return fmt.Errorf("failures and check_interval cannot be negative")
+	}
+	for channel, fallback := range cfg.Failover.Fallbacks {
+		if !slices.Contains(NotifyChannels, channel) {
+			return fmt.Errorf("unknown channel: %s", channel)
+		}
+		if !slices.Contains(NotifyChannels, fallback) {
+			return fmt.Errorf("unknown fallback of %s: %s", channel, fallback)
+		}
+		if channel == fallback {
+			return fmt.Errorf("channel %s cannot be its own fallback", channel)
+		}
+	}
 	return nil
 }
 
@@ -193,7 +473,7 @@ func (cfg *EmailConfig) Validate() error {
 	if cfg.From == "" {
 		return fmt.Errorf("sender email is required")
 	}
-	if len(cfg.To) == 0 {
+	if len(cfg.To) == 0 && len(cfg.Routes) == 0 {
 		return fmt.Errorf("at least one recipient is required")
 	}
 
@@ -205,6 +485,118 @@ func (cfg *EmailConfig) Validate() error {
 			return fmt.Errorf("invalid recipient email address: %s", to)
 		}
 	}
+
+	if cfg.Security == "" {
+		cfg.Security = EmailSecurityStartTLS
+		if cfg.UseTLS || cfg.SMTPPort == 465 {
+			cfg.Security = EmailSecurityTLS
+		}
+	}
+	switch cfg.Security {
+	case EmailSecurityStartTLS, EmailSecurityTLS, EmailSecurityNone:
+	default:
+		return fmt.Errorf("invalid security: %s", cfg.Security)
+	}
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+		switch cfg.Security {
+		case EmailSecurityTLS:
+			cfg.SMTPPort = 465
+		case EmailSecurityNone:
+			cfg.SMTPPort = 25
+		}
+	}
+
+	if cfg.Auth == "" {
+		cfg.Auth = EmailAuthNone
+		if cfg.Username != "" {
+			cfg.Auth = EmailAuthPlain
+		}
+	}
+	switch cfg.Auth {
+	case EmailAuthPlain, EmailAuthLogin, EmailAuthCRAMMD5:
+		if cfg.Username == "" {
+			return fmt.Errorf("username is required for %s auth", cfg.Auth)
+		}
+	case EmailAuthXOAuth2:
+		if cfg.Username == "" {
+			return fmt.Errorf("username is required for xoauth2 auth")
+		}
+		if err := cfg.OAuth2.Validate(); err != nil {
+			return fmt.Errorf("invalid oauth2 config: %w", err)
+		}
+	case EmailAuthNone:
+	default:
+		return fmt.Errorf("invalid auth: %s", cfg.Auth)
+	}
+
+	for i := range cfg.Routes {
+		if err := cfg.Routes[i].Validate(); err != nil {
+			return fmt.Errorf("invalid route %d: %w", i, err)
+		}
+	}
+	if cfg.Batch.Window < 0 {
+		return fmt.Errorf("batch window must not be negative")
+	}
+	if cfg.Batch.MaxSize <= 0 {
+		cfg.Batch.MaxSize = 50
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = 2
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = time.Minute
+	}
+	return nil
+}
+
+// Validate validates an email route
+func (cfg *EmailRouteConfig) Validate() error {
+	if len(cfg.To) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+	for _, to := range cfg.To {
+		if !strings.Contains(to, "@") {
+			return fmt.Errorf("invalid recipient email address: %s", to)
+		}
+	}
+	for _, t := range cfg.Types {
+		if !slices.Contains(NotificationTypes, t) {
+			return fmt.Errorf("invalid notification type: %s", t)
+		}
+	}
+	for _, s := range cfg.Severities {
+		if !slices.Contains(NotificationSeverities, s) {
+			return fmt.Errorf("invalid severity: %s", s)
+		}
+	}
+	return nil
+}
+
+// Validate validates the OAuth2 token source
+func (cfg *EmailOAuth2Config) Validate() error {
+	if cfg == nil {
+		return fmt.Errorf("oauth2 is required")
+	}
+	if cfg.TokenURL == "" {
+		if cfg.AccessToken == "" {
+			return fmt.Errorf("token URL or access token is required")
+		}
+		return nil
+	}
+	if !strings.HasPrefix(cfg.TokenURL, "https://") && !strings.HasPrefix(cfg.TokenURL, "http://") {
+		return fmt.Errorf("invalid token URL: %s", cfg.TokenURL)
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("client ID is required")
+	}
+	if cfg.RefreshToken == "" && cfg.ClientSecret == "" {
+		return fmt.Errorf("refresh token or client secret is required")
+	}
 	return nil
 }
 
@@ -216,13 +608,57 @@ func (cfg *TelegramConfig) Validate() error {
 	if len(cfg.ChatIDs) == 0 {
 		return fmt.Errorf("at least one chat ID is required")
 	}
+	if cfg.Buttons.Enabled {
+		if err := cfg.Buttons.Validate(); err != nil {
+			return fmt.Errorf("invalid buttons config: %w", err)
+		}
+	}
+	return nil
+}
+
+// Validate validates the telegram buttons configuration
+func (cfg *TelegramButtonsConfig) Validate() error {
+	if cfg.SilenceFor <= 0 {
+		cfg.SilenceFor = time.Hour
+	}
+	if cfg.ViewURL != "" && !strings.HasPrefix(cfg.ViewURL, "https://") && !strings.HasPrefix(cfg.ViewURL, "http://") {
+		return fmt.Errorf("invalid view URL: %s", cfg.ViewURL)
+	}
+
+	if cfg.Updates == "" {
+		cfg.Updates = TelegramUpdatesPoll
+	}
+	switch cfg.Updates {
+	case TelegramUpdatesPoll:
+	case TelegramUpdatesWebhook:
+		// Telegram only accepts A-Z, a-z, 0-9, _ and - in secret tokens
+		if cfg.WebhookSecret == "" {
+			return fmt.Errorf("webhook secret is required")
+		}
+		for _, r := range cfg.WebhookSecret {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return fmt.Errorf("webhook secret may only contain letters, digits, _ and -")
+			}
+		}
+		if cfg.WebhookURL != "" && !strings.HasPrefix(cfg.WebhookURL, "https://") {
+			return fmt.Errorf("webhook URL must use https: %s", cfg.WebhookURL)
+		}
+	default:
+		return fmt.Errorf("invalid updates mode: %s", cfg.Updates)
+	}
 	return nil
 }
 
 // Validate validates slack configuration
 func (cfg *SlackConfig) Validate() error {
-	if cfg.WebhookURL == "" {
-		return fmt.Errorf("slack webhook URL is required")
+	if cfg.WebhookURL == "" && cfg.BotToken == "" {
+		return fmt.Errorf("slack webhook URL or bot token is required")
+	}
+	if cfg.BotToken != "" && cfg.Channel == "" {
+		return fmt.Errorf("slack channel is required with a bot token")
+	}
+	if cfg.ThreadTTL <= 0 {
+		cfg.ThreadTTL = 24 * time.Hour
 	}
 	return nil
 }
@@ -274,6 +710,20 @@ func (cfg *WebhookConfig) Validate() error {
 	return nil
 }
 
+// Validate validates exec configuration
+func (cfg *ExecConfig) Validate() error {
+	if cfg.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("max_retries cannot be negative")
+	}
+	return nil
+}
+
 // Validate validates Feishu configuration
 func (cfg *FeishuConfig) Validate() error {
 	if !cfg.Enabled {
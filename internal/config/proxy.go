@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyDirect is the proxy URL connecting directly, e.g. to override a
+// global proxy for one component
+const ProxyDirect = "direct"
+
+// ProxyConfig represents the proxy of outbound HTTP requests. Without a URL
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+type ProxyConfig struct {
+	URL     string   `mapstructure:"url"`      // http(s)://, socks5:// or socks5h:// URL, or "direct"
+	NoProxy []string `mapstructure:"no_proxy"` // Hosts, .domains, IPs and CIDRs reached directly
+}
+
+// Validate validates the proxy configuration
+func (cfg *ProxyConfig) Validate() error {
+	if cfg == nil || cfg.URL == "" || cfg.URL == ProxyDirect {
+		return nil
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy url has no host")
+	}
+	return nil
+}
+
+// ProxyFunc returns the proxy selection of http.Transport, a nil config
+// uses the environment
+func (cfg *ProxyConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	var pc *httpproxy.Config
+	switch {
+	case cfg == nil || cfg.URL == "":
+		pc = httpproxy.FromEnvironment()
+	case cfg.URL == ProxyDirect:
+		return nil
+	default:
+		pc = &httpproxy.Config{HTTPProxy: cfg.URL, HTTPSProxy: cfg.URL}
+	}
+
+	if cfg != nil && len(cfg.NoProxy) > 0 {
+		noProxy := cfg.NoProxy
+		if pc.NoProxy != "" {
+			noProxy = append([]string{pc.NoProxy}, noProxy...)
+		}
+		pc.NoProxy = strings.Join(noProxy, ",")
+	}
+
+	proxy := pc.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// SecretsConfig represents secret reference resolution configuration
+type SecretsConfig struct {
+	// RefreshInterval re-resolves secret references periodically, zero disables refreshing
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	// Timeout bounds resolving a single reference
+	Timeout time.Duration `mapstructure:"timeout"`
+}
@@ -1,11 +1,23 @@
 package config
 
-import "wameter/internal/logger"
+import (
+	"wameter/internal/logger"
+	"wameter/internal/profiling"
+	"wameter/internal/tracing"
+)
 
 // LogConfig represents logging configuration
 // This is a copy of the logger.Config
 type LogConfig = logger.Config
 
+// DebugConfig represents the profiling endpoints configuration
+// This is a copy of the profiling.Config
+type DebugConfig = profiling.Config
+
+// TracingConfig represents the OpenTelemetry tracing configuration
+// This is a copy of the tracing.Config
+type TracingConfig = tracing.Config
+
 var (
 	// AppName is the name of the application
 	AppName = "wameter"
@@ -0,0 +1,283 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule represents a parsed cron schedule
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Days match on either field when both are restricted, as in Vixie cron
+	domStar, dowStar bool
+	// Neither the minute nor the hour is a wildcard, such times are matched
+	// on the wall clock across DST changes
+	fixed bool
+	// Fixed interval of @every schedules, the fields are unused
+	every time.Duration
+}
+
+// field represents the bounds and names of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors are the supported shorthand schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a five field cron expression, minute hour day-of-month month
+// day-of-week, or one of the @yearly, @monthly, @weekly, @daily and @hourly
+// descriptors. Fields accept lists, ranges, steps and month or day names.
+// As in Vixie cron, a day field starting with * such as */2 leaves the days
+// to the other field, and restricted days match on either field.
+// "@every <duration>", e.g. "@every 30s", runs at a fixed interval of at
+// least a second.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("interval must be at least 1s: %s", interval)
+		}
+		return &Schedule{every: every}, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		expr, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported descriptor: %s", spec)
+		}
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d: %q", len(fields), spec)
+	}
+
+	s := &Schedule{
+		domStar: isWildcard(fields[2]),
+		dowStar: isWildcard(fields[4]),
+		fixed:   !isWildcard(fields[0]) && !isWildcard(fields[1]),
+	}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// Next returns the first time after t matching the schedule, in the location
+// of t, or the zero time if there is none within five years. As in Vixie
+// cron, schedules with a wildcard minute or hour run on every matching
+// minute the clock shows, while fixed times run once a day across DST
+// changes: once in a repeated hour, and when the clock jumps for a time it
+// skips.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	if !s.fixed {
+		return s.next(t)
+	}
+
+	// Find the next wall clock time, then the instant showing it
+	loc := t.Location()
+	wall := wallClock(t)
+	for {
+		if wall = s.next(wall); wall.IsZero() {
+			return wall
+		}
+		next := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+		if shown := wallClock(next); !shown.Equal(wall) {
+			// Skipped by the clock, run as it jumps
+			start, end := next.ZoneBounds()
+			if next = start; shown.Before(wall) {
+				next = end
+			}
+		}
+		// The first instant of a repeated hour may already have passed
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// next returns the first minute after t matching the fields, in the
+// location of t
+func (s *Schedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = nextHour(t)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// forward returns next if it is after t, otherwise the start of the next
+// hour after t, as a midnight skipped by the clock may resolve before t
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return nextHour(t)
+}
+
+// nextHour returns the start of the hour after t, t being on a minute. It
+// counts minutes rather than setting the hour, which may resolve to the
+// same hour when the clock skips the next one.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// wallClock returns the time shown by the clock at t, as a UTC time
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// dayMatches reports whether the day of t matches the schedule
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// isWildcard reports whether a field starts with a wildcard, e.g. * or */2
+func isWildcard(expr string) bool {
+	return strings.HasPrefix(expr, "*") || strings.HasPrefix(expr, "?")
+}
+
+// has reports whether bit n is set
+func has(bits uint64, n int) bool {
+	return bits&(1<<uint(n)) != 0
+}
+
+// parseField parses a comma separated list of values, ranges and steps
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step: %s", f.name, item)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangeExpr, "-"):
+			a, b, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid %s range: %s", f.name, rangeExpr)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rangeExpr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// A single value with a step runs to the end of the field
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name of the field
+func (f field) value(s string) (int, error) {
+	if n, ok := f.names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s: %s", f.name, s)
+	}
+	return n, nil
+}
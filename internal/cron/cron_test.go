@@ -0,0 +1,122 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseInvalid tests that invalid specs are rejected
+func TestParseInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		spec string
+	}{
+		{name: "Empty", spec: ""},
+		{name: "Four fields", spec: "* * * *"},
+		{name: "Six fields", spec: "0 * * * * *"},
+		{name: "Minute out of range", spec: "60 * * * *"},
+		{name: "Hour out of range", spec: "* 24 * * *"},
+		{name: "Day of month zero", spec: "* * 0 * *"},
+		{name: "Month out of range", spec: "* * * 13 *"},
+		{name: "Day of week out of range", spec: "* * * * 8"},
+		{name: "Unknown month name", spec: "* * * foo *"},
+		{name: "Day name in month", spec: "* * * mon *"},
+		{name: "Zero step", spec: "*/0 * * * *"},
+		{name: "Negative step", spec: "*/-1 * * * *"},
+		{name: "Reversed range", spec: "5-1 * * * *"},
+		{name: "Unknown descriptor", spec: "@often"},
+		{name: "Interval below a second", spec: "@every 500ms"},
+		{name: "Invalid interval", spec: "@every soon"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestNext tests the next run of schedules, 2025-01-01 is a Wednesday
+func TestNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{name: "Every minute", spec: "* * * * *", from: at(2025, 1, 1, 0, 0).Add(30 * time.Second), want: at(2025, 1, 1, 0, 1)},
+		{name: "Minute step", spec: "*/15 * * * *", from: at(2025, 1, 1, 0, 7), want: at(2025, 1, 1, 0, 15)},
+		{name: "Minute step at match", spec: "*/15 * * * *", from: at(2025, 1, 1, 0, 15), want: at(2025, 1, 1, 0, 30)},
+		{name: "Value step", spec: "5/20 * * * *", from: at(2025, 1, 1, 0, 30), want: at(2025, 1, 1, 0, 45)},
+		{name: "Range step", spec: "0 9-17/4 * * *", from: at(2025, 1, 1, 13, 0), want: at(2025, 1, 1, 17, 0)},
+		{name: "Range step wraps to next day", spec: "0 9-17/4 * * *", from: at(2025, 1, 1, 17, 0), want: at(2025, 1, 2, 9, 0)},
+		{name: "List", spec: "0 6,18 * * *", from: at(2025, 1, 1, 7, 0), want: at(2025, 1, 1, 18, 0)},
+		{name: "Month names", spec: "0 0 1 feb,MAR *", from: at(2025, 1, 1, 0, 0), want: at(2025, 2, 1, 0, 0)},
+		{name: "Weekday name range", spec: "0 0 * * mon-fri", from: at(2025, 1, 4, 12, 0), want: at(2025, 1, 6, 0, 0)},
+		{name: "Sunday as 7", spec: "0 0 * * 7", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 5, 0, 0)},
+		{name: "Day of month or week", spec: "0 0 13 * fri", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 3, 0, 0)},
+		{name: "Day of month or week, month day", spec: "0 0 13 * fri", from: at(2025, 1, 10, 0, 0), want: at(2025, 1, 13, 0, 0)},
+		{name: "Day of month step and week", spec: "0 0 */2 * mon", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 13, 0, 0)},
+		{name: "Day of month and week step", spec: "0 0 1 * */2", from: at(2025, 1, 1, 0, 0), want: at(2025, 2, 1, 0, 0)},
+		{name: "Day of week step", spec: "0 0 * * */2", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 2, 0, 0)},
+		{name: "Leap day", spec: "0 0 29 2 *", from: at(2025, 1, 1, 0, 0), want: at(2028, 2, 29, 0, 0)},
+		{name: "Never", spec: "0 0 30 2 *", from: at(2025, 1, 1, 0, 0)},
+		{name: "Descriptor", spec: "@weekly", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 5, 0, 0)},
+		{name: "Interval", spec: "@every 90s", from: at(2025, 1, 1, 0, 0), want: at(2025, 1, 1, 0, 1).Add(30 * time.Second)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, s.Next(tc.from))
+		})
+	}
+}
+
+// TestNextDST tests the runs of schedules across DST changes, New York
+// skips from 02:00 to 03:00 on 2025-03-09 and repeats 01:00 to 02:00 on
+// 2025-11-02
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{name: "Skipped time runs as the clock jumps", spec: "30 2 * * *", from: utc(3, 9, 5, 0), want: utc(3, 9, 7, 0)},
+		{name: "Skipped time runs the next day", spec: "30 2 * * *", from: utc(3, 9, 7, 0), want: utc(3, 10, 6, 30)},
+		{name: "Time after the skipped hour", spec: "30 3 * * *", from: utc(3, 9, 5, 0), want: utc(3, 9, 7, 30)},
+		{name: "Wildcard hours across the skipped hour", spec: "0 */4 * * *", from: utc(3, 9, 5, 0), want: utc(3, 9, 8, 0)},
+		{name: "Skipped hour of wildcard minutes", spec: "*/30 2 * * *", from: utc(3, 9, 5, 0), want: utc(3, 10, 6, 0)},
+		{name: "Repeated time runs once", spec: "30 1 * * *", from: utc(11, 2, 4, 0), want: utc(11, 2, 5, 30)},
+		{name: "Repeated time after the first run", spec: "30 1 * * *", from: utc(11, 2, 5, 30), want: utc(11, 3, 6, 30)},
+		{name: "Repeated time from the repeated hour", spec: "30 1 * * *", from: utc(11, 2, 6, 10), want: utc(11, 3, 6, 30)},
+		{name: "Repeated hour of wildcard hours", spec: "30 * * * *", from: utc(11, 2, 5, 30), want: utc(11, 2, 6, 30)},
+		{name: "Repeated hour of wildcard minutes", spec: "*/20 1 * * *", from: utc(11, 2, 5, 40), want: utc(11, 2, 6, 0)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+			require.NoError(t, err)
+			got := s.Next(tc.from.In(loc))
+			assert.True(t, tc.want.Equal(got), "want %s, got %s", tc.want.In(loc), got)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// rules handles alert rule commands
+func (c *ctl) rules(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		rules, err := c.client.ListAlertRules(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(rules))
+		for _, r := range rules {
+			rows = append(rows, []string{r.Name, r.Severity, r.Source, r.Condition})
+		}
+		return c.out.print(rules, []string{"NAME", "SEVERITY", "SOURCE", "CONDITION"}, rows)
+
+	case "get", "inspect":
+		if len(args) < 2 {
+			return errors.New("rule name is required")
+		}
+		rule, err := c.client.GetAlertRule(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return c.printAlertRule(rule)
+
+	case "apply":
+		return c.applyAlertRule(ctx, args[1:])
+
+	case "delete", "rm":
+		if len(args) < 2 {
+			return errors.New("rule name is required")
+		}
+		if err := c.client.DeleteAlertRule(ctx, args[1]); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(c.stdout, "Deleted alert rule %s\n", args[1])
+		return err
+
+	default:
+		return fmt.Errorf("unknown rules command: %s", args[0])
+	}
+}
+
+// applyAlertRule creates or replaces an alert rule read from a file or flags
+func (c *ctl) applyAlertRule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rules apply", flag.ContinueOnError)
+	file := fs.String("f", "", "JSON or YAML file of the rule, - for stdin")
+	condition := fs.String("condition", "", "Condition expression")
+	message := fs.String("message", "", "Message template")
+	severity := fs.String("severity", "", "Severity: info, warning, critical")
+	var labels stringList
+	fs.Var(&labels, "label", "Label as key=value, repeatable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var rule types.AlertRule
+	if *file != "" {
+		if err := readAlertRule(*file, &rule); err != nil {
+			return err
+		}
+	}
+	if fs.NArg() > 0 {
+		rule.Name = fs.Arg(0)
+	}
+	if rule.Name == "" {
+		return errors.New("rule name is required")
+	}
+	if *condition != "" {
+		rule.Condition = *condition
+	}
+	if *message != "" {
+		rule.Message = *message
+	}
+	if *severity != "" {
+		rule.Severity = *severity
+	}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		if rule.Labels == nil {
+			rule.Labels = make(map[string]string)
+		}
+		rule.Labels[key] = value
+	}
+
+	result, err := c.client.ApplyAlertRule(ctx, &rule)
+	if err != nil {
+		return err
+	}
+	return c.printAlertRule(result)
+}
+
+// readAlertRule reads a rule from a JSON or YAML file
+func readAlertRule(path string, rule *types.AlertRule) error {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read rule file: %w", err)
+	}
+
+	// JSON is YAML, decoding through JSON keeps the field names of the API
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("failed to parse rule file: %w", err)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to parse rule file: %w", err)
+	}
+	if err := json.Unmarshal(raw, rule); err != nil {
+		return fmt.Errorf("failed to parse rule file: %w", err)
+	}
+	return nil
+}
+
+// printAlertRule writes an alert rule
+func (c *ctl) printAlertRule(rule *types.AlertRule) error {
+	labels := make([]string, 0, len(rule.Labels))
+	for key, value := range rule.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+
+	updated := "-"
+	if rule.UpdatedAt != nil {
+		updated = formatTime(*rule.UpdatedAt)
+	}
+	return c.out.printFields(rule, [][2]string{
+		{"Name", rule.Name},
+		{"Severity", rule.Severity},
+		{"Source", orDash(rule.Source)},
+		{"Condition", rule.Condition},
+		{"Message", orDash(rule.Message)},
+		{"Labels", orDash(strings.Join(labels, ", "))},
+		{"Updated", updated},
+	})
+}
+
+// silences handles silence commands, a silence puts an agent into
+// maintenance, which suppresses its notifications
+func (c *ctl) silences(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return c.listSilences(ctx)
+
+	case "create", "add":
+		return c.createSilence(ctx, args[1:])
+
+	case "expire", "rm":
+		if len(args) < 2 {
+			return errors.New("agent id is required")
+		}
+		if err := c.client.EndMaintenance(ctx, args[1]); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(c.stdout, "Expired silence of %s\n", args[1])
+		return err
+
+	default:
+		return fmt.Errorf("unknown silences command: %s", args[0])
+	}
+}
+
+// listSilences lists the agents in maintenance
+func (c *ctl) listSilences(ctx context.Context) error {
+	const limit = 100
+
+	now := time.Now()
+	var silenced []*types.AgentInfo
+	for offset := 0; ; offset += limit {
+		page, err := c.client.ListAgents(ctx, url.Values{
+			"limit":  {fmt.Sprint(limit)},
+			"offset": {fmt.Sprint(offset)},
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range page.Agents {
+			if a.Maintenance.Active(now) {
+				silenced = append(silenced, a)
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+
+	rows := make([][]string, 0, len(silenced))
+	for _, a := range silenced {
+		until := "-"
+		if a.Maintenance.Until != nil {
+			until = formatTime(*a.Maintenance.Until)
+		}
+		rows = append(rows, []string{
+			a.ID, a.Hostname, formatTime(a.Maintenance.Since), until, orDash(a.Maintenance.Reason),
+		})
+	}
+	return c.out.print(silenced, []string{"AGENT", "HOSTNAME", "SINCE", "UNTIL", "REASON"}, rows)
+}
+
+// createSilence puts an agent into maintenance
+func (c *ctl) createSilence(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("silences create", flag.ContinueOnError)
+	duration := fs.Duration("duration", 0, "Duration of the silence, until expired when neither it nor until is set")
+	until := fs.String("until", "", "End time of the silence")
+	reason := fs.String("reason", "", "Reason of the silence")
+	agentID, err := parseWithArg(fs, args, "agent id")
+	if err != nil {
+		return err
+	}
+
+	req := &types.MaintenanceRequest{Reason: *reason, Duration: *duration}
+	if *until != "" {
+		t, err := utils.ParseTime(*until)
+		if err != nil {
+			return fmt.Errorf("invalid until time: %w", err)
+		}
+		req.Until = &t
+	}
+
+	m, err := c.client.SetMaintenance(ctx, agentID, req)
+	if err != nil {
+		return err
+	}
+	return c.out.printFields(m, [][2]string{
+		{"Agent", agentID},
+		{"Maintenance", formatMaintenance(m)},
+	})
+}
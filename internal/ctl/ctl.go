@@ -0,0 +1,718 @@
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+	"wameter/internal/version"
+	"wameter/pkg/client"
+)
+
+const usage = `Usage: wameterctl [global flags] <command> [args]
+
+Commands:
+  agents list                      List agents
+  agents get <id>                  Show agent details
+  agents diagnostics <id>          Request or download a diagnostics bundle
+  agents collect <id>              Ask an agent to collect and report at once
+  agents logs <id>                 Show logs shipped by an agent
+  metrics latest <agent>           Show latest metrics of an agent
+  metrics tail <agent>             Follow metrics of an agent
+  metrics export                   Export metrics as JSON or CSV
+  ip-changes list [agent]          List IP changes
+  ip-changes stats <agent>         Show IP change patterns of an agent
+  rules list                       List alert rules
+  rules get <name>                 Show an alert rule
+  rules apply [name]               Create or replace an alert rule from -f or flags
+  rules delete <name>              Delete an alert rule declared through the API
+  silences list                    List agents whose notifications are silenced
+  silences create <agent>          Silence an agent by putting it into maintenance
+  silences expire <agent>          End the silence of an agent
+  command <agent> <type>           Send a command to an agent
+  backup                           Download a backup archive of the server data
+  profiles list                    List server profiles
+  profiles use <name>              Set the current server profile
+  health                           Show server health
+  version                          Show version information
+
+Global flags:
+`
+
+// ctl holds the state of a wameterctl invocation
+type ctl struct {
+	client   *client.Client
+	profiles *Profiles
+	out      *printer
+	stdout   io.Writer
+}
+
+// Run runs wameterctl with the given arguments and returns the exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("wameterctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", DefaultProfilesPath(), "Path to profiles file")
+	profile := fs.String("profile", os.Getenv("WAMETER_PROFILE"), "Server profile to use")
+	server := fs.String("server", os.Getenv("WAMETER_SERVER"), "Server address, overrides the profile")
+	token := fs.String("token", os.Getenv("WAMETER_TOKEN"), "API token, overrides the profile")
+	timeout := fs.Duration("timeout", 0, "Request timeout")
+	output := fs.String("o", OutputTable, "Output format: table, json")
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *output != OutputTable && *output != OutputJSON {
+		_, _ = fmt.Fprintf(stderr, "unsupported output format: %s\n", *output)
+		return 2
+	}
+
+	profiles, err := LoadProfiles(*configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	c := &ctl{
+		profiles: profiles,
+		out:      &printer{format: *output, w: stdout},
+		stdout:   stdout,
+	}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	if cmd != "version" && cmd != "profiles" {
+		cfg, err := profiles.Resolve(*profile)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		if *server != "" {
+			cfg.Server = *server
+		}
+		if *token != "" {
+			cfg.Token = *token
+		}
+		if *timeout > 0 {
+			cfg.Timeout = *timeout
+		}
+		if cfg.Server == "" {
+			cfg.Server = "http://localhost:8080"
+		}
+		if c.client, err = client.NewClient(cfg); err != nil {
+			_, _ = fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := c.run(ctx, cmd, cmdArgs); err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// run dispatches a command
+func (c *ctl) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "agents", "agent":
+		return c.agents(ctx, args)
+	case "metrics":
+		return c.metrics(ctx, args)
+	case "ip-changes":
+		return c.ipChanges(ctx, args)
+	case "rules", "rule":
+		return c.rules(ctx, args)
+	case "silences", "silence":
+		return c.silences(ctx, args)
+	case "command":
+		return c.command(ctx, args)
+	case "backup":
+		return c.backup(ctx, args)
+	case "profiles", "profile":
+		return c.profileCmd(args)
+	case "health":
+		return c.health(ctx)
+	case "version":
+		info := version.GetInfo()
+		return c.out.printFields(info, [][2]string{
+			{"Version", info.Version},
+			{"Git Commit", info.GitCommit},
+			{"Build Date", info.BuildDate},
+			{"Go Version", info.GoVersion},
+		})
+	default:
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+// agents handles agent commands
+func (c *ctl) agents(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return c.listAgents(ctx, args[1:])
+
+	case "get", "inspect":
+		if len(args) < 2 {
+			return errors.New("agent id is required")
+		}
+		agent, err := c.client.GetAgent(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return c.out.printFields(agent, [][2]string{
+			{"ID", agent.ID},
+			{"Hostname", agent.Hostname},
+			{"Port", fmt.Sprint(agent.Port)},
+			{"Version", orDash(agent.Version)},
+			{"Status", string(agent.Status)},
+			{"Maintenance", formatMaintenance(agent.Maintenance)},
+			{"Collector Stalls", formatStalls(agent.CollectorStalls)},
+			{"Last Seen", formatTime(agent.LastSeen)},
+			{"Registered", formatTime(agent.RegisteredAt)},
+		})
+
+	case "diagnostics":
+		return c.diagnostics(ctx, args[1:])
+
+	case "collect":
+		if len(args) < 2 {
+			return errors.New("agent id is required")
+		}
+		id, err := c.client.Collect(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		result := map[string]string{"command_id": id, "status": "requested"}
+		return c.out.printFields(result, [][2]string{{"Command ID", id}, {"Status", "requested"}})
+
+	case "logs":
+		return c.agentLogs(ctx, args[1:])
+
+	default:
+		return fmt.Errorf("unknown agents command: %s", args[0])
+	}
+}
+
+// diagnostics requests a diagnostics bundle from an agent, or downloads one
+func (c *ctl) diagnostics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("agents diagnostics", flag.ContinueOnError)
+	download := fs.Bool("download", false, "Download a bundle instead of requesting one")
+	bundle := fs.String("bundle", "", "Bundle ID to download, defaults to the newest")
+	outFile := fs.String("f", "", "Output file, defaults to diagnostics-<agent>.tar.gz")
+	agentID, err := parseWithArg(fs, args, "agent id")
+	if err != nil {
+		return err
+	}
+
+	if !*download {
+		id, err := c.client.RequestDiagnostics(ctx, agentID)
+		if err != nil {
+			return err
+		}
+		result := map[string]string{"command_id": id, "status": "requested"}
+		return c.out.printFields(result, [][2]string{{"Command ID", id}, {"Status", "requested"}})
+	}
+
+	path := *outFile
+	if path == "" {
+		path = "diagnostics-" + agentID + ".tar.gz"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	if err := c.client.DownloadDiagnostics(ctx, f, agentID, *bundle); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	_, err = fmt.Fprintf(c.stdout, "Saved %s\n", path)
+	return err
+}
+
+// metrics handles metrics commands
+func (c *ctl) metrics(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("metrics command is required: latest, tail, export")
+	}
+
+	switch args[0] {
+	case "latest":
+		if len(args) < 2 {
+			return errors.New("agent id is required")
+		}
+		data, err := c.client.GetLatestMetrics(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return c.printMetrics(data)
+
+	case "tail":
+		return c.tailMetrics(ctx, args[1:])
+
+	case "export":
+		return c.exportMetrics(ctx, args[1:])
+
+	default:
+		return fmt.Errorf("unknown metrics command: %s", args[0])
+	}
+}
+
+// tailMetrics polls the latest metrics of an agent until interrupted
+func (c *ctl) tailMetrics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("metrics tail", flag.ContinueOnError)
+	interval := fs.Duration("interval", 10*time.Second, "Polling interval")
+	agentID, err := parseWithArg(fs, args, "agent id")
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		data, err := c.client.GetLatestMetrics(ctx, agentID)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if data != nil && data.Timestamp.After(last) {
+			last = data.Timestamp
+			if err := c.printMetrics(data); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printMetrics writes interface metrics of a report
+func (c *ctl) printMetrics(data *types.MetricsData) error {
+	var rows [][]string
+	if network := data.Metrics.Network; network != nil {
+		names := make([]string, 0, len(network.Interfaces))
+		for name := range network.Interfaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			iface := network.Interfaces[name]
+			row := []string{formatTime(data.Timestamp), name, orDash(iface.Status), orDash(strings.Join(iface.IPv4, ","))}
+			if stats := iface.Statistics; stats != nil {
+				row = append(row,
+					utils.FormatBytesRate(stats.RxBytesRate),
+					utils.FormatBytesRate(stats.TxBytesRate),
+					fmt.Sprint(stats.RxErrors+stats.TxErrors))
+			} else {
+				row = append(row, "-", "-", "-")
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return c.out.print(data, []string{"TIME", "INTERFACE", "STATUS", "IPV4", "RX", "TX", "ERRORS"}, rows)
+}
+
+// exportMetrics writes exported metrics to a file or stdout
+func (c *ctl) exportMetrics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("metrics export", flag.ContinueOnError)
+	format := fs.String("format", "json", "Export format: json, csv")
+	start := fs.String("start", "", "Start time, defaults to end minus since")
+	end := fs.String("end", "", "End time, defaults to now")
+	since := fs.Duration("since", 24*time.Hour, "Time range when start is not set")
+	var agentIDs stringList
+	fs.Var(&agentIDs, "agent", "Agent ID, may be repeated")
+	outFile := fs.String("f", "", "Output file, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	startTime, endTime, err := parseRange(*start, *end, *since)
+	if err != nil {
+		return err
+	}
+
+	w := c.stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		w = f
+	}
+
+	return c.client.ExportMetrics(ctx, w, *format, agentIDs, startTime, endTime)
+}
+
+// backup downloads a backup archive of the server data to a file
+func (c *ctl) backup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	start := fs.String("start", "", "Start time of metrics and IP changes, defaults to all")
+	end := fs.String("end", "", "End time of metrics and IP changes, defaults to now")
+	since := fs.Duration("since", 0, "Time range of metrics and IP changes when start is not set")
+	outFile := fs.String("f", "", "Output file, defaults to wameter-backup-<time>.jsonl.gz")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var startTime, endTime time.Time
+	if *start != "" || *since > 0 {
+		var err error
+		if startTime, endTime, err = parseRange(*start, *end, *since); err != nil {
+			return err
+		}
+	} else if *end != "" {
+		t, err := utils.ParseTime(*end)
+		if err != nil {
+			return fmt.Errorf("invalid end time: %w", err)
+		}
+		endTime = t
+	}
+
+	path := *outFile
+	if path == "" {
+		path = "wameter-backup-" + time.Now().Format("20060102-150405") + ".jsonl.gz"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	if err := c.client.Backup(ctx, f, startTime, endTime); err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	_, err = fmt.Fprintf(c.stdout, "Saved %s\n", path)
+	return err
+}
+
+// ipChanges handles IP change commands
+func (c *ctl) ipChanges(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return c.listIPChanges(ctx, args[1:])
+
+	case "stats":
+		if len(args) < 2 {
+			return errors.New("agent id is required")
+		}
+		stats, err := c.client.GetIPChangeStats(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fields := [][2]string{
+			{"Total Changes", fmt.Sprint(stats.TotalChanges)},
+			{"Changes/Day", fmt.Sprintf("%.2f", stats.ChangesPerDay)},
+			{"Most Active Hour", fmt.Sprintf("%02d:00 UTC", stats.MostActiveHour)},
+			{"Most Active Day", time.Weekday(stats.MostActiveDay).String()},
+			{"Average Interval", fmt.Sprintf("%.1fh", stats.AverageInterval)},
+		}
+		if a := stats.Anomaly; a != nil {
+			fields = append(fields,
+				[2]string{"Anomaly Score", fmt.Sprintf("%.2f (threshold %.2f)", a.Score, a.Threshold)},
+				[2]string{"Anomalous", fmt.Sprint(a.Anomalous)})
+		}
+		return c.out.printFields(stats, fields)
+
+	default:
+		return fmt.Errorf("unknown ip-changes command: %s", args[0])
+	}
+}
+
+// listAgents lists agents matching the given filters
+func (c *ctl) listAgents(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("agents list", flag.ContinueOnError)
+	var statuses, tags stringList
+	fs.Var(&statuses, "status", "Only agents with this status, repeatable")
+	fs.Var(&tags, "tag", "Only agents with this tag, key=value or key, repeatable")
+	hostname := fs.String("hostname", "", "Only agents whose hostname contains this")
+	sortBy := fs.String("sort", "id", "Sort field")
+	desc := fs.Bool("desc", false, "Sort in descending order")
+	limit := fs.Int("limit", 100, "Maximum number of agents")
+	offset := fs.Int("offset", 0, "Number of agents to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"status": statuses,
+		"tag":    tags,
+		"sort":   {*sortBy},
+		"limit":  {fmt.Sprint(*limit)},
+		"offset": {fmt.Sprint(*offset)},
+	}
+	if *hostname != "" {
+		query.Set("hostname", *hostname)
+	}
+	if *desc {
+		query.Set("order", "desc")
+	}
+
+	page, err := c.client.ListAgents(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(page.Agents))
+	for _, a := range page.Agents {
+		rows = append(rows, []string{
+			a.ID, a.Hostname, agentStatus(a), a.Version, formatAge(a.LastSeen),
+		})
+	}
+	return c.out.print(page, []string{"ID", "HOSTNAME", "STATUS", "VERSION", "LAST SEEN"}, rows)
+}
+
+// agentLogs lists the logs shipped by an agent
+func (c *ctl) agentLogs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("agents logs", flag.ContinueOnError)
+	since := fs.Duration("since", time.Hour, "Time range")
+	level := fs.String("level", "", "Minimum level: debug, info, warn, error")
+	search := fs.String("search", "", "Only entries whose message contains this")
+	limit := fs.Int("limit", 100, "Maximum number of entries")
+	agentID, err := parseWithArg(fs, args, "agent id")
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"start_time": {time.Now().Add(-*since).Format(time.RFC3339)},
+		"limit":      {fmt.Sprint(*limit)},
+	}
+	if *level != "" {
+		query.Set("level", *level)
+	}
+	if *search != "" {
+		query.Set("search", *search)
+	}
+
+	page, err := c.client.ListAgentLogs(ctx, agentID, query)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		rows = append(rows, []string{
+			formatTime(entry.Timestamp),
+			strings.ToUpper(entry.Level),
+			orDash(entry.Caller),
+			entry.Message,
+		})
+	}
+	return c.out.print(page, []string{"TIME", "LEVEL", "CALLER", "MESSAGE"}, rows)
+}
+
+// listIPChanges lists IP changes of one or all agents
+func (c *ctl) listIPChanges(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ip-changes list", flag.ContinueOnError)
+	since := fs.Duration("since", 24*time.Hour, "Time range")
+	external := fs.Bool("external", false, "Only external IP changes")
+	limit := fs.Int("limit", 100, "Maximum number of changes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"start_time": {time.Now().Add(-*since).Format(time.RFC3339)},
+		"limit":      {fmt.Sprint(*limit)},
+	}
+	if *external {
+		query.Set("external", "true")
+	}
+
+	page, err := c.client.ListIPChanges(ctx, fs.Arg(0), query)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(page.Changes))
+	for _, change := range page.Changes {
+		iface := change.InterfaceName
+		if change.IsExternal {
+			iface = "external"
+		}
+		rows = append(rows, []string{
+			formatTime(change.Timestamp),
+			change.AgentID,
+			iface,
+			string(change.Version),
+			string(change.Action),
+			orDash(strings.Join(change.OldAddrs, ",")),
+			orDash(strings.Join(change.NewAddrs, ",")),
+		})
+	}
+	return c.out.print(page, []string{"TIME", "AGENT", "INTERFACE", "VERSION", "ACTION", "OLD", "NEW"}, rows)
+}
+
+// command sends a command to an agent
+func (c *ctl) command(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("command", flag.ContinueOnError)
+	payload := fs.String("payload", "", "Command payload as JSON")
+	timeout := fs.Duration("timeout", 0, "Command timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: command [flags] <agent> <type>")
+	}
+
+	var raw json.RawMessage
+	if *payload != "" {
+		if !json.Valid([]byte(*payload)) {
+			return errors.New("payload must be valid JSON")
+		}
+		raw = json.RawMessage(*payload)
+	}
+
+	id, err := c.client.SendCommand(ctx, fs.Arg(0), fs.Arg(1), raw, *timeout)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]string{"command_id": id, "status": "sent"}
+	return c.out.printFields(result, [][2]string{{"Command ID", id}, {"Status", "sent"}})
+}
+
+// profileCmd handles profile commands
+func (c *ctl) profileCmd(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		var rows [][]string
+		for _, name := range c.profiles.Names() {
+			current := ""
+			if name == c.profiles.Current {
+				current = "*"
+			}
+			rows = append(rows, []string{current, name, c.profiles.Profiles[name].Server})
+		}
+		return c.out.print(c.profiles.Profiles, []string{"CURRENT", "NAME", "SERVER"}, rows)
+
+	case "use":
+		if len(args) < 2 {
+			return errors.New("profile name is required")
+		}
+		return c.profiles.Use(args[1])
+
+	default:
+		return fmt.Errorf("unknown profiles command: %s", args[0])
+	}
+}
+
+// health shows the server health
+func (c *ctl) health(ctx context.Context) error {
+	health, err := c.client.Health(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(health))
+	for key := range health {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, [2]string{key, fmt.Sprint(health[key])})
+	}
+	return c.out.printFields(health, fields)
+}
+
+// parseWithArg parses flags and returns the single required positional argument
+func parseWithArg(fs *flag.FlagSet, args []string, name string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() < 1 {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return fs.Arg(0), nil
+}
+
+// parseRange parses a time range, defaulting to the last since duration
+func parseRange(start, end string, since time.Duration) (time.Time, time.Time, error) {
+	endTime := time.Now()
+	if end != "" {
+		t, err := utils.ParseTime(end)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end time: %w", err)
+		}
+		endTime = t
+	}
+
+	startTime := endTime.Add(-since)
+	if start != "" {
+		t, err := utils.ParseTime(start)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start time: %w", err)
+		}
+		startTime = t
+	}
+
+	if !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, errors.New("end time must be after start time")
+	}
+	return startTime, endTime, nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+// String implements flag.Value
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set implements flag.Value
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
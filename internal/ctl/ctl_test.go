@@ -0,0 +1,189 @@
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/types"
+)
+
+// fakeServer records the requests of wameterctl and answers them with data
+type fakeServer struct {
+	t        *testing.T
+	url      string
+	data     map[string]any
+	requests []string
+	bodies   map[string]json.RawMessage
+}
+
+// newFakeServer starts a server answering requests by "METHOD path" with
+// the given data, or with 204 when there is none
+func newFakeServer(t *testing.T, data map[string]any) *fakeServer {
+	s := &fakeServer{t: t, data: data, bodies: make(map[string]json.RawMessage)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := r.Method + " " + r.URL.Path
+		s.requests = append(s.requests, req)
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			s.bodies[req] = body
+		}
+
+		v, ok := s.data[req]
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "message": "ok", "data": v})
+	}))
+	t.Cleanup(srv.Close)
+	s.url = srv.URL
+	return s
+}
+
+// run runs wameterctl against the server and returns its output
+func (s *fakeServer) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{
+		"-config", filepath.Join(s.t.TempDir(), "wameterctl.yaml"),
+		"-server", s.url,
+	}, args...)
+	if code := Run(args, &stdout, &stderr); code != 0 {
+		return stdout.String(), &exitError{code: code, stderr: stderr.String()}
+	}
+	return stdout.String(), nil
+}
+
+// exitError represents a failed wameterctl run
+type exitError struct {
+	code   int
+	stderr string
+}
+
+// Error implements error
+func (e *exitError) Error() string {
+	return e.stderr
+}
+
+// TestRules tests the alert rule commands
+func TestRules(t *testing.T) {
+	rule := &types.AlertRule{
+		Name:      "high-rx",
+		Condition: `network.interfaces["eth0"].stats.rx_rate > 50MB`,
+		Severity:  "critical",
+		Labels:    map[string]string{"team": "net"},
+		Source:    types.AlertRuleSourceAPI,
+	}
+	srv := newFakeServer(t, map[string]any{
+		"GET /v1/admin/alert-rules": []*types.AlertRule{
+			{Name: "offline", Condition: "agent.status == \"offline\"", Severity: "warning", Source: types.AlertRuleSourceConfig},
+			rule,
+		},
+		"GET /v1/admin/alert-rules/high-rx": rule,
+		"PUT /v1/admin/alert-rules/high-rx": rule,
+	})
+
+	t.Run("List", func(t *testing.T) {
+		out, err := srv.run("rules", "list")
+		require.NoError(t, err)
+		assert.Contains(t, out, "offline")
+		assert.Contains(t, out, "config")
+		assert.Contains(t, out, "high-rx")
+		assert.Contains(t, out, "critical")
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		out, err := srv.run("-o", "json", "rules", "get", "high-rx")
+		require.NoError(t, err)
+		var got types.AlertRule
+		require.NoError(t, json.Unmarshal([]byte(out), &got))
+		assert.Equal(t, rule, &got)
+	})
+
+	t.Run("Apply from flags", func(t *testing.T) {
+		_, err := srv.run("rules", "apply", "-condition", rule.Condition, "-severity", "critical", "-label", "team=net", "high-rx")
+		require.NoError(t, err)
+
+		var sent types.AlertRule
+		require.NoError(t, json.Unmarshal(srv.bodies["PUT /v1/admin/alert-rules/high-rx"], &sent))
+		assert.Equal(t, "high-rx", sent.Name)
+		assert.Equal(t, rule.Condition, sent.Condition)
+		assert.Equal(t, "critical", sent.Severity)
+		assert.Equal(t, map[string]string{"team": "net"}, sent.Labels)
+	})
+
+	t.Run("Apply from file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rule.yaml")
+		yaml := "name: high-rx\ncondition: 'network.interfaces[\"eth0\"].stats.rx_rate > 50MB'\nseverity: critical\nlabels:\n  team: net\n"
+		require.NoError(t, os.WriteFile(path, []byte(yaml), 0o600))
+
+		_, err := srv.run("rules", "apply", "-f", path)
+		require.NoError(t, err)
+
+		var sent types.AlertRule
+		require.NoError(t, json.Unmarshal(srv.bodies["PUT /v1/admin/alert-rules/high-rx"], &sent))
+		assert.Equal(t, rule.Condition, sent.Condition)
+		assert.Equal(t, map[string]string{"team": "net"}, sent.Labels)
+	})
+
+	t.Run("Apply without name", func(t *testing.T) {
+		_, err := srv.run("rules", "apply", "-condition", "true")
+		assert.ErrorContains(t, err, "rule name is required")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		out, err := srv.run("rules", "delete", "high-rx")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Deleted alert rule high-rx")
+		assert.Contains(t, srv.requests, "DELETE /v1/admin/alert-rules/high-rx")
+	})
+}
+
+// TestSilences tests the silence commands
+func TestSilences(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	maintenance := &types.AgentMaintenance{Reason: "upgrade", Since: time.Now(), Until: &until}
+	srv := newFakeServer(t, map[string]any{
+		"GET /v1/agents": map[string]any{
+			"agents": []*types.AgentInfo{
+				{ID: "agent-1", Hostname: "host-1", Maintenance: maintenance},
+				{ID: "agent-2", Hostname: "host-2"},
+			},
+		},
+		"PUT /v1/agents/agent-2/maintenance": maintenance,
+	})
+
+	t.Run("List", func(t *testing.T) {
+		out, err := srv.run("silences", "list")
+		require.NoError(t, err)
+		assert.Contains(t, out, "agent-1")
+		assert.Contains(t, out, "upgrade")
+		assert.NotContains(t, out, "agent-2")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		_, err := srv.run("silences", "create", "-duration", "1h", "-reason", "upgrade", "agent-2")
+		require.NoError(t, err)
+
+		var sent types.MaintenanceRequest
+		require.NoError(t, json.Unmarshal(srv.bodies["PUT /v1/agents/agent-2/maintenance"], &sent))
+		assert.Equal(t, time.Hour, sent.Duration)
+		assert.Equal(t, "upgrade", sent.Reason)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		out, err := srv.run("silences", "expire", "agent-1")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Expired silence of agent-1")
+		assert.Contains(t, srv.requests, "DELETE /v1/agents/agent-1/maintenance")
+	})
+}
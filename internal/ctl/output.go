@@ -0,0 +1,111 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"wameter/internal/types"
+)
+
+// Output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// printer writes results as table or JSON
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// print writes v as JSON, or as a table with the given header and rows
+func (p *printer) print(v any, header []string, rows [][]string) error {
+	if p.format == OutputJSON {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	if len(header) > 0 {
+		_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printFields writes v as JSON, or as a two column key/value table
+func (p *printer) printFields(v any, fields [][2]string) error {
+	rows := make([][]string, 0, len(fields))
+	for _, f := range fields {
+		rows = append(rows, []string{f[0] + ":", f[1]})
+	}
+	return p.print(v, nil, rows)
+}
+
+// formatTime formats a time for table output
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatAge formats the time elapsed since t
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return time.Since(t).Round(time.Second).String()
+}
+
+// agentStatus returns the status of an agent, marked while in maintenance
+func agentStatus(agent *types.AgentInfo) string {
+	if agent.Maintenance.Active(time.Now()) {
+		return string(agent.Status) + " (maintenance)"
+	}
+	return string(agent.Status)
+}
+
+// formatMaintenance formats the maintenance of an agent
+func formatMaintenance(m *types.AgentMaintenance) string {
+	if !m.Active(time.Now()) {
+		return "-"
+	}
+	s := "since " + formatTime(m.Since)
+	if m.Until != nil {
+		s += ", until " + formatTime(*m.Until)
+	}
+	if m.Reason != "" {
+		s += ": " + m.Reason
+	}
+	return s
+}
+
+// formatStalls formats the stalls of the collectors of an agent
+func formatStalls(stalls map[string]int64) string {
+	if len(stalls) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(stalls))
+	for name, n := range stalls {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// orDash returns s, or a dash if s is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
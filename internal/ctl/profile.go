@@ -0,0 +1,109 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"wameter/pkg/client"
+
+	"github.com/spf13/viper"
+)
+
+// Profiles represents the server profiles of wameterctl
+type Profiles struct {
+	Current  string                    `mapstructure:"current"`
+	Profiles map[string]*client.Config `mapstructure:"profiles"`
+
+	path string
+}
+
+// DefaultProfilesPath returns the default profiles file path
+func DefaultProfilesPath() string {
+	if path := os.Getenv("WAMETERCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "wameterctl.yaml"
+	}
+	return filepath.Join(dir, "wameter", "wameterctl.yaml")
+}
+
+// LoadProfiles loads profiles from file, a missing file yields no profiles
+func LoadProfiles(path string) (*Profiles, error) {
+	p := &Profiles{
+		Profiles: make(map[string]*client.Config),
+		path:     path,
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	if err := v.Unmarshal(p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profiles: %w", err)
+	}
+	if p.Profiles == nil {
+		p.Profiles = make(map[string]*client.Config)
+	}
+
+	return p, nil
+}
+
+// Resolve returns the named profile, or the current one if name is empty
+func (p *Profiles) Resolve(name string) (*client.Config, error) {
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		return &client.Config{}, nil
+	}
+
+	cfg, ok := p.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile not found: %s", name)
+	}
+
+	resolved := *cfg
+	return &resolved, nil
+}
+
+// Names returns the sorted profile names
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Use sets the current profile and saves the profiles file
+func (p *Profiles) Use(name string) error {
+	if _, ok := p.Profiles[name]; !ok {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+	p.Current = name
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.Set("current", p.Current)
+	v.Set("profiles", p.Profiles)
+	if err := v.WriteConfigAs(p.path); err != nil {
+		return fmt.Errorf("failed to write profiles: %w", err)
+	}
+
+	return nil
+}
@@ -7,7 +7,11 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"wameter/internal/clock"
+	"wameter/internal/tracing"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +22,8 @@ type Database struct {
 	logger      *zap.Logger
 	opts        Options
 	metrics     *metrics
+	clock       clock.Clock
+	prune       func(ctx context.Context, before time.Time) error // Cleanup of the driver
 	pruneCtx    context.Context
 	pruneCancel context.CancelFunc
 	stmtCache   sync.Map
@@ -51,8 +57,12 @@ func newDatabase(driver, dsn string, opts Options, logger *zap.Logger) (*Databas
 	if opts.QueryTimeout <= 0 {
 		opts.QueryTimeout = 60 * time.Second
 	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
 
-	db, err := sql.Open(driver, dsn)
+	// Times are stored in UTC
+	db, err := openUTC(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -71,16 +81,12 @@ func newDatabase(driver, dsn string, opts Options, logger *zap.Logger) (*Databas
 		driver:      driver,
 		logger:      logger,
 		opts:        opts,
+		clock:       opts.Clock,
 		metrics:     &metrics{},
 		pruneCtx:    pruneCtx,
 		pruneCancel: pruneCancel,
 	}
 
-	// Start pruning if enabled
-	if opts.EnablePruning {
-		go d.pruneLoop()
-	}
-
 	// Health check
 	go d.healthCheck()
 
@@ -96,41 +102,51 @@ func (d *Database) ExecContext(ctx context.Context, query string, args ...any) (
 		defer cancel()
 	}
 
+	ctx, span := d.startSpan(ctx, "exec", query)
 	start := time.Now()
 	result, err := d.db.ExecContext(ctx, query, args...)
 	d.recordMetrics(start, err)
+	tracing.End(span, err)
 
 	return result, err
 }
 
 // QueryContext executes query and returns rows
 func (d *Database) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	// Add timeout if not set
+	// Add timeout if not set.
+	// The rows outlive this call, so release the context once the timeout expires
+	// instead of canceling it on return
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, d.opts.QueryTimeout)
-		defer cancel()
+		time.AfterFunc(d.opts.QueryTimeout, cancel)
 	}
 
+	ctx, span := d.startSpan(ctx, "query", query)
 	start := time.Now()
 	rows, err := d.db.QueryContext(ctx, query, args...)
 	d.recordMetrics(start, err)
+	tracing.End(span, err)
 
 	return rows, err
 }
 
 // QueryRowContext executes query and returns row
 func (d *Database) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
-	// Add timeout if not set
+	// Add timeout if not set.
+	// The rows outlive this call, so release the context once the timeout expires
+	// instead of canceling it on return
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, d.opts.QueryTimeout)
-		defer cancel()
+		time.AfterFunc(d.opts.QueryTimeout, cancel)
 	}
 
+	ctx, span := d.startSpan(ctx, "query", query)
 	start := time.Now()
 	row := d.db.QueryRowContext(ctx, query, args...)
 	d.recordMetrics(start, nil)
+	tracing.End(span, row.Err())
 	return row
 }
 
@@ -171,14 +187,17 @@ func (d *Database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, e
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, d.opts.QueryTimeout)
-		defer cancel()
+		time.AfterFunc(d.opts.QueryTimeout, cancel)
 	}
 
 	return d.db.BeginTx(ctx, opts)
 }
 
 // WithTransaction executes a transaction
-func (d *Database) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
+func (d *Database) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, span := d.startSpan(ctx, "transaction", "")
+	defer func() { tracing.End(span, err) }()
+
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
@@ -355,6 +374,36 @@ func (d *Database) Driver() string {
 	return d.driver
 }
 
+// cleanupProgressKey is the context key of the progress callback of a cleanup
+type cleanupProgressKey struct{}
+
+// WithCleanupProgress returns a context whose cleanup calls fn with the
+// number of rows deleted so far after each batch
+func WithCleanupProgress(ctx context.Context, fn func(deleted int64)) context.Context {
+	return context.WithValue(ctx, cleanupProgressKey{}, fn)
+}
+
+// reportCleanupProgress calls the progress callback of ctx, if any
+func reportCleanupProgress(ctx context.Context, deleted int64) {
+	if fn, ok := ctx.Value(cleanupProgressKey{}).(func(int64)); ok {
+		fn(deleted)
+	}
+}
+
+// CountCleanup returns the number of rows a cleanup before the time would delete
+func (d *Database) CountCleanup(ctx context.Context, before time.Time) (int64, error) {
+	query := "SELECT COUNT(*) FROM metrics WHERE timestamp < ?"
+	if d.driver == "postgres" {
+		query = ConvertPlaceholders(query)
+	}
+
+	var count int64
+	if err := d.QueryRowContext(ctx, query, before).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows to cleanup: %w", err)
+	}
+	return count, nil
+}
+
 // Cleanup performs data cleanup
 func (d *Database) Cleanup(ctx context.Context, before time.Time) error {
 	// Batch deletion to avoid long transactions
@@ -362,6 +411,7 @@ func (d *Database) Cleanup(ctx context.Context, before time.Time) error {
 	if batchSize <= 0 {
 		batchSize = 1000
 	}
+	var totalDeleted int64
 
 	for {
 		query := "DELETE FROM metrics WHERE timestamp < ? LIMIT ?"
@@ -380,6 +430,8 @@ func (d *Database) Cleanup(ctx context.Context, before time.Time) error {
 			return fmt.Errorf("failed to get affected rows: %w", err)
 		}
 
+		totalDeleted += affected
+		reportCleanupProgress(ctx, totalDeleted)
 		if affected < int64(batchSize) {
 			break
 		}
@@ -398,7 +450,7 @@ func (d *Database) RunPruning(ctx context.Context) error {
 	}
 
 	d.pruneCtx, d.pruneCancel = context.WithCancel(ctx)
-	go d.pruneLoop()
+	go d.pruneLoop(d.pruneCtx)
 	return nil
 }
 
@@ -417,6 +469,16 @@ func (d *Database) Unwrap() *sql.DB {
 	return d.db
 }
 
+// startSpan starts a span for a database operation, the statement is recorded
+// without its arguments
+func (d *Database) startSpan(ctx context.Context, op, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", d.driver)}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.statement", query))
+	}
+	return tracing.Start(ctx, "db."+op, attrs...)
+}
+
 // recordMetrics safely records operation metrics
 func (d *Database) recordMetrics(start time.Time, err error) {
 	duration := time.Since(start)
@@ -437,18 +499,31 @@ func (d *Database) recordMetrics(start time.Time, err error) {
 	}
 }
 
+// startPruning starts pruning with the cleanup of the driver if enabled
+func (d *Database) startPruning(cleanup func(ctx context.Context, before time.Time) error) {
+	d.prune = cleanup
+	if d.opts.EnablePruning {
+		go d.pruneLoop(d.pruneCtx)
+	}
+}
+
 // pruneLoop handles periodic data pruning
-func (d *Database) pruneLoop() {
-	ticker := time.NewTicker(d.opts.PruneInterval)
+func (d *Database) pruneLoop(ctx context.Context) {
+	ticker := d.clock.NewTicker(d.opts.PruneInterval)
 	defer ticker.Stop()
 
+	prune := d.prune
+	if prune == nil {
+		prune = d.Cleanup
+	}
+
 	for {
 		select {
-		case <-d.pruneCtx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			pruneBefore := time.Now().Add(-d.opts.RetentionPeriod)
-			if err := d.Cleanup(context.Background(), pruneBefore); err != nil {
+			pruneBefore := d.clock.Now().Add(-d.opts.RetentionPeriod)
+			if err := prune(context.Background(), pruneBefore); err != nil {
 				d.logger.Error("Failed to prune old data",
 					zap.Error(err),
 					zap.Time("before", pruneBefore))
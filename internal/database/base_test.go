@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"wameter/internal/clock"
+)
+
+// TestPruneLoop tests that metrics past the retention period are pruned at
+// each prune interval
+func TestPruneLoop(t *testing.T) {
+	const (
+		interval  = time.Hour
+		retention = 24 * time.Hour
+	)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		ages    []time.Duration // Of the metrics at the start
+		advance time.Duration
+		pruned  bool // Whether a prune runs
+		remain  int
+	}{
+		{
+			name:    "Not due",
+			ages:    []time.Duration{48 * time.Hour},
+			advance: interval - time.Second,
+			remain:  1,
+		},
+		{
+			name:    "Within retention",
+			ages:    []time.Duration{0, 12 * time.Hour},
+			advance: interval,
+			pruned:  true,
+			remain:  2,
+		},
+		{
+			name:    "Past retention",
+			ages:    []time.Duration{0, 12 * time.Hour, 48 * time.Hour},
+			advance: interval,
+			pruned:  true,
+			remain:  2,
+		},
+		{
+			name:    "Aged past retention",
+			ages:    []time.Duration{0, 12 * time.Hour, 48 * time.Hour},
+			advance: 13 * time.Hour,
+			pruned:  true,
+			remain:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := clock.NewFake(start)
+			db, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "data.db"), Options{
+				EnablePruning:   true,
+				PruneInterval:   interval,
+				RetentionPeriod: retention,
+				Clock:           fake,
+			}, zaptest.NewLogger(t))
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = db.Close() })
+
+			ctx := context.Background()
+			_, err = db.ExecContext(ctx, "CREATE TABLE metrics (timestamp DATETIME NOT NULL)")
+			require.NoError(t, err)
+			for _, age := range tc.ages {
+				_, err = db.ExecContext(ctx, "INSERT INTO metrics (timestamp) VALUES (?)", start.Add(-age))
+				require.NoError(t, err)
+			}
+
+			fake.BlockUntil(1)
+			queries := db.Stats().QueryCount
+			fake.Advance(tc.advance)
+			if tc.pruned {
+				require.Eventually(t, func() bool {
+					return db.Stats().QueryCount > queries
+				}, 5*time.Second, 10*time.Millisecond)
+			}
+
+			var remain int
+			require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics").Scan(&remain))
+			assert.Equal(t, tc.remain, remain)
+		})
+	}
+}
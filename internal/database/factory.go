@@ -68,40 +68,11 @@ func newInstance(cfg *config.DatabaseConfig, logger *zap.Logger) (Interface, err
 
 // runMigrations runs database migrations based on the configuration
 func runMigrations(cfg *config.DatabaseConfig, logger *zap.Logger) error {
-	// Create a new database connection for migrations
-	db, err := newInstance(cfg, logger)
+	migrator, closeMigrator, err := openMigrator(cfg, logger)
 	if err != nil {
-		return fmt.Errorf("failed to create database connection for migrations: %w", err)
-	}
-
-	defer func() {
-		_ = db.Close()
-	}()
-
-	// Verify migrations path
-	migrationsPath := cfg.MigrationsPath
-	if _, err := os.Stat(migrationsPath); err != nil {
-		return fmt.Errorf("migrations path %s does not exist: %w", migrationsPath, err)
+		return err
 	}
-
-	// Ensure driver-specific migrations exist
-	driverPath := filepath.Join(migrationsPath, cfg.Driver)
-	if _, err := os.Stat(driverPath); err != nil {
-		return fmt.Errorf("driver-specific migrations path %s does not exist: %w", driverPath, err)
-	}
-	cfg.MigrationsPath = driverPath
-
-	// Create migrator instance
-	migrator, err := migration.NewMigrator(db.Unwrap(), cfg, logger)
-	if err != nil {
-		return fmt.Errorf("failed to create migrator: %w", err)
-	}
-
-	defer func() {
-		if err := migrator.Close(); err != nil {
-			logger.Error("Failed to close migrator", zap.Error(err))
-		}
-	}()
+	defer closeMigrator()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
@@ -121,5 +92,91 @@ func runMigrations(cfg *config.DatabaseConfig, logger *zap.Logger) error {
 		}
 	}
 
+	if version, _, err := migrator.GetVersion(); err == nil {
+		logger.Info("Database schema is up to date", zap.Uint("version", version))
+	}
+
 	return nil
 }
+
+// Migrate runs a single migration command and returns the resulting schema
+// version, it backs the server's -migrate mode. Commands are up, down with
+// the number of steps, goto and force with a version, and version.
+func Migrate(ctx context.Context, cfg *config.DatabaseConfig, logger *zap.Logger, command string, arg int) (uint, bool, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, false, fmt.Errorf("invalid database config: %w", err)
+	}
+
+	migrator, closeMigrator, err := openMigrator(cfg, logger)
+	if err != nil {
+		return 0, false, err
+	}
+	defer closeMigrator()
+
+	switch command {
+	case "up":
+		err = migrator.RunMigrations(ctx)
+	case "down":
+		if arg <= 0 {
+			return 0, false, fmt.Errorf("down requires the number of migrations to roll back")
+		}
+		err = migrator.RollbackMigrations(ctx, arg)
+	case "goto":
+		if arg <= 0 {
+			return 0, false, fmt.Errorf("goto requires a version")
+		}
+		err = migrator.MigrateToVersion(ctx, uint(arg))
+	case "force":
+		if arg <= 0 {
+			return 0, false, fmt.Errorf("force requires a version")
+		}
+		err = migrator.Force(arg)
+	case "version":
+	default:
+		return 0, false, fmt.Errorf("unknown migrate command %q, expected up, down, goto, force or version", command)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return migrator.GetVersion()
+}
+
+// openMigrator creates a migrator for the driver-specific migrations, the
+// returned function closes it and its database connection
+func openMigrator(cfg *config.DatabaseConfig, logger *zap.Logger) (*migration.Migrator, func(), error) {
+	// Verify migrations path
+	if _, err := os.Stat(cfg.MigrationsPath); err != nil {
+		return nil, nil, fmt.Errorf("migrations path %s does not exist: %w", cfg.MigrationsPath, err)
+	}
+
+	// Ensure driver-specific migrations exist
+	driverPath := filepath.Join(cfg.MigrationsPath, cfg.Driver)
+	if _, err := os.Stat(driverPath); err != nil {
+		return nil, nil, fmt.Errorf("driver-specific migrations path %s does not exist: %w", driverPath, err)
+	}
+
+	// Create a new database connection for migrations
+	db, err := newInstance(cfg, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create database connection for migrations: %w", err)
+	}
+
+	// The migrator reads the driver directory, the configuration is left as is
+	// so migrations can run again after a reload
+	migrationCfg := *cfg
+	migrationCfg.MigrationsPath = driverPath
+
+	migrator, err := migration.NewMigrator(db.Unwrap(), &migrationCfg, logger)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	return migrator, func() {
+		if err := migrator.Close(); err != nil {
+			logger.Error("Failed to close migrator", zap.Error(err))
+		}
+		_ = db.Close()
+	}, nil
+}
@@ -41,6 +41,7 @@ type Interface interface {
 	// Data maintenance
 
 	Cleanup(ctx context.Context, before time.Time) error
+	CountCleanup(ctx context.Context, before time.Time) (int64, error)
 	RunPruning(ctx context.Context) error
 	StopPruning() error
 	Unwrap() *sql.DB
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"wameter/internal/server/config"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -17,7 +19,13 @@ import (
 	"go.uber.org/zap"
 )
 
-// Migrator handles database migrations
+// ErrDirty is returned while a failed migration left the schema in an
+// unknown state
+var ErrDirty = errors.New("database schema is dirty")
+
+// Migrator handles database migrations. Drivers lock the database while
+// migrating, with postgres and mysql other server instances wait for the
+// lock instead of migrating concurrently.
 type Migrator struct {
 	config  *config.DatabaseConfig
 	migrate *migrate.Migrate
@@ -66,6 +74,11 @@ func NewMigrator(db *sql.DB, cfg *config.DatabaseConfig, logger *zap.Logger) (*M
 		return nil, fmt.Errorf("failed to create migrator instance: %w", err)
 	}
 
+	if cfg.MigrationLockTimeout > 0 {
+		instance.LockTimeout = cfg.MigrationLockTimeout
+	}
+	instance.Log = &migrateLogger{logger: logger}
+
 	return &Migrator{
 		config:  cfg,
 		migrate: instance,
@@ -79,6 +92,10 @@ func (m *Migrator) RunMigrations(ctx context.Context) error {
 		return errors.New("migrator not properly initialized")
 	}
 
+	if err := m.checkState(); err != nil {
+		return err
+	}
+
 	errChan := make(chan error, 1)
 
 	go func() {
@@ -108,6 +125,12 @@ func (m *Migrator) RollbackMigrations(ctx context.Context, steps int) error {
 		return errors.New("migrator not properly initialized")
 	}
 
+	if _, dirty, err := m.GetVersion(); err != nil {
+		return err
+	} else if dirty {
+		return m.dirtyError()
+	}
+
 	errChan := make(chan error, 1)
 
 	go func() {
@@ -136,6 +159,12 @@ func (m *Migrator) MigrateToVersion(ctx context.Context, version uint) error {
 		return errors.New("migrator not properly initialized")
 	}
 
+	if _, dirty, err := m.GetVersion(); err != nil {
+		return err
+	} else if dirty {
+		return m.dirtyError()
+	}
+
 	errChan := make(chan error, 1)
 
 	go func() {
@@ -158,15 +187,79 @@ func (m *Migrator) MigrateToVersion(ctx context.Context, version uint) error {
 	}
 }
 
-// GetVersion returns the current migration version
+// Force sets the schema version without running migrations and clears the
+// dirty flag, after a failed migration was fixed or rolled back by hand
+func (m *Migrator) Force(version int) error {
+	if err := m.migrate.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	return nil
+}
+
+// GetVersion returns the current migration version, 0 before the first
+// migration
 func (m *Migrator) GetVersion() (uint, bool, error) {
 	version, dirty, err := m.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
 	}
 	return version, dirty, nil
 }
 
+// LatestVersion returns the version of the newest migration available
+func (m *Migrator) LatestVersion() (uint, error) {
+	entries, err := os.ReadDir(m.config.MigrationsPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
+
+// checkState rejects migrating a dirty schema, or one written by a newer
+// build whose migrations are unknown here
+func (m *Migrator) checkState() error {
+	version, dirty, err := m.GetVersion()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return m.dirtyError()
+	}
+
+	latest, err := m.LatestVersion()
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest migration %d of this build", version, latest)
+	}
+
+	return nil
+}
+
+// dirtyError describes how to recover from a failed migration
+func (m *Migrator) dirtyError() error {
+	version, _, _ := m.GetVersion()
+	return fmt.Errorf("%w at version %d, fix the schema and run the server with -migrate force <version>", ErrDirty, version)
+}
+
 // Close releases resources
 func (m *Migrator) Close() error {
 	sourceErr, dbErr := m.migrate.Close()
@@ -187,3 +280,18 @@ func (m *Migrator) Close() error {
 
 	return fmt.Errorf("failed to close migrator: %s", errMsg)
 }
+
+// migrateLogger writes migration progress to the server log
+type migrateLogger struct {
+	logger *zap.Logger
+}
+
+// Printf implements migrate.Logger
+func (l *migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+// Verbose implements migrate.Logger
+func (l *migrateLogger) Verbose() bool {
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"wameter/internal/tracing"
 
 	_ "github.com/go-sql-driver/mysql"
 	"go.uber.org/zap"
@@ -22,6 +23,8 @@ func NewMySQLDatabase(dsn string, opts Options, logger *zap.Logger) (Interface,
 	params := []string{
 		"charset=utf8mb4",
 		"interpolateParams=true",
+		"loc=UTC",
+		"time_zone=%27%2B00%3A00%27", // Of every connection, not only the first
 	}
 
 	if !strings.Contains(dsn, "parseTime=true") {
@@ -48,6 +51,7 @@ func NewMySQLDatabase(dsn string, opts Options, logger *zap.Logger) (Interface,
 		_ = base.Close()
 		return nil, fmt.Errorf("failed to initialize MySQL: %w", err)
 	}
+	base.startPruning(d.Cleanup)
 
 	return d, nil
 }
@@ -117,7 +121,10 @@ func (d *MySQLDatabase) batchInsert(ctx context.Context, query string, args [][]
 }
 
 // WithTransaction overrides default implementation for MySQL
-func (d *MySQLDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
+func (d *MySQLDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, span := d.startSpan(ctx, "transaction", "")
+	defer func() { tracing.End(span, err) }()
+
 	tx, err := d.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelRepeatableRead, // Use default isolation
 	})
@@ -161,6 +168,7 @@ func (d *MySQLDatabase) Cleanup(ctx context.Context, before time.Time) error {
 		}
 
 		totalDeleted += affected
+		reportCleanupProgress(ctx, totalDeleted)
 		if affected < int64(batchSize) {
 			break
 		}
@@ -1,6 +1,9 @@
 package database
 
-import "time"
+import (
+	"time"
+	"wameter/internal/clock"
+)
 
 // Options defines database options
 type Options struct {
@@ -23,6 +26,9 @@ type Options struct {
 	EnablePruning   bool          `json:"enable_pruning"`
 	PruneInterval   time.Duration `json:"prune_interval"`
 	RetentionPeriod time.Duration `json:"retention_period"`
+
+	// Clock of pruning, the system clock when nil
+	Clock clock.Clock `json:"-"`
 }
 
 // Stats represents database statistics
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"wameter/internal/tracing"
 
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
@@ -20,7 +21,11 @@ type PostgresDatabase struct {
 func NewPostgresDatabase(dsn string, opts Options, logger *zap.Logger) (Interface, error) {
 	// Add parameters
 	if !strings.Contains(dsn, "sslmode=") {
-		dsn += "?sslmode=disable"
+		dsn = addPostgresParam(dsn, "sslmode=disable")
+	}
+	if !strings.Contains(dsn, "timezone=") {
+		// Of every connection, not only the first
+		dsn = addPostgresParam(dsn, "timezone=UTC")
 	}
 
 	base, err := newDatabase("postgres", dsn, opts, logger)
@@ -36,10 +41,23 @@ func NewPostgresDatabase(dsn string, opts Options, logger *zap.Logger) (Interfac
 		_ = base.Close()
 		return nil, fmt.Errorf("failed to initialize PostgreSQL: %w", err)
 	}
+	base.startPruning(d.Cleanup)
 
 	return d, nil
 }
 
+// addPostgresParam appends a parameter to a URL or a key/value DSN
+func addPostgresParam(dsn, param string) string {
+	switch {
+	case !strings.Contains(dsn, "://"):
+		return strings.TrimSpace(dsn + " " + param)
+	case strings.Contains(dsn, "?"):
+		return dsn + "&" + param
+	default:
+		return dsn + "?" + param
+	}
+}
+
 // init initializes PostgreSQL specific settings
 func (d *PostgresDatabase) init() error {
 	// Set session variables
@@ -130,7 +148,10 @@ func (d *PostgresDatabase) batchInsert(ctx context.Context, query string, args [
 }
 
 // WithTransaction overrides default implementation for PostgreSQL
-func (d *PostgresDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
+func (d *PostgresDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, span := d.startSpan(ctx, "transaction", "")
+	defer func() { tracing.End(span, err) }()
+
 	tx, err := d.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -171,6 +192,7 @@ func (d *PostgresDatabase) Cleanup(ctx context.Context, before time.Time) error
 	if err != nil {
 		return fmt.Errorf("cleanup failed: %w", err)
 	}
+	reportCleanupProgress(ctx, count)
 
 	if count > 0 {
 		if _, err := d.ExecContext(ctx, "VACUUM ANALYZE metrics"); err != nil {
@@ -1,160 +1,272 @@
 package database
 
 import (
+	"database/sql/driver"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
-// QueryBuilder provides SQL query building functionality
+// QueryBuilder provides SQL query building functionality. Clauses are
+// rendered in SQL order whatever order they are added in, and "?"
+// placeholders are converted to the style of the driver. A slice argument
+// expands its placeholder to one per element, so "agent_id IN (?)" takes a
+// []string; an empty slice matches nothing.
 type QueryBuilder struct {
-	sql      strings.Builder
-	args     []any
-	argIndex int
-	driver   string
+	driver  string
+	prefix  []clause // Raw text added before any other clause
+	columns []string
+	from    string
+	joins   []clause
+	where   []clause
+	groupBy []string
+	having  []clause
+	orderBy []string
+	limit   int
+	offset  int
+	suffix  []clause // Raw, SubQuery and Union text added after other clauses
+}
+
+// clause represents a part of a query with its arguments
+type clause struct {
+	sql  string
+	args []any
 }
 
 // NewQueryBuilder creates new query builder
 func NewQueryBuilder(driver string) *QueryBuilder {
 	return &QueryBuilder{
 		driver: driver,
-		args:   make([]any, 0),
 	}
 }
 
 // Reset resets the builder state
 func (qb *QueryBuilder) Reset() {
-	qb.sql.Reset()
-	qb.args = qb.args[:0]
-	qb.argIndex = 0
+	*qb = QueryBuilder{driver: qb.driver}
 }
 
 // SQL returns the built query string
 func (qb *QueryBuilder) SQL() string {
-	return qb.sql.String()
+	query, _ := qb.build()
+	return query
 }
 
-// Args returns query arguments
+// Args returns query arguments, in the order of the placeholders of SQL
 func (qb *QueryBuilder) Args() []any {
-	return qb.args
+	_, args := qb.build()
+	return args
 }
 
 // Select adds SELECT clause
 func (qb *QueryBuilder) Select(cols ...string) *QueryBuilder {
-	qb.sql.WriteString("SELECT ")
-	qb.sql.WriteString(strings.Join(cols, ", "))
+	qb.columns = append(qb.columns, cols...)
 	return qb
 }
 
 // From adds FROM clause
 func (qb *QueryBuilder) From(table string) *QueryBuilder {
-	qb.sql.WriteString(" FROM ")
-	qb.sql.WriteString(table)
+	qb.from = table
 	return qb
 }
 
-// Where adds WHERE condition
-func (qb *QueryBuilder) Where(cond string, args ...any) *QueryBuilder {
-	if !strings.Contains(qb.sql.String(), "WHERE") {
-		qb.sql.WriteString(" WHERE ")
-	} else {
-		qb.sql.WriteString(" AND ")
-	}
-
-	// Convert placeholders based on driver
-	switch qb.driver {
-	case "postgres":
-		for range args {
-			qb.argIndex++
-			cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", qb.argIndex), 1)
-		}
-	}
-
-	qb.sql.WriteString(cond)
-	qb.args = append(qb.args, args...)
+// Join adds JOIN clause, joinType is e.g. "INNER" or "LEFT"
+func (qb *QueryBuilder) Join(joinType, table, cond string, args ...any) *QueryBuilder {
+	qb.joins = append(qb.joins, clause{
+		sql:  fmt.Sprintf("%s JOIN %s ON %s", joinType, table, cond),
+		args: args,
+	})
 	return qb
 }
 
-// OrderBy adds ORDER BY clause
-func (qb *QueryBuilder) OrderBy(cols ...string) *QueryBuilder {
-	qb.sql.WriteString(" ORDER BY ")
-	qb.sql.WriteString(strings.Join(cols, ", "))
+// Where adds WHERE condition, conditions are joined with AND
+func (qb *QueryBuilder) Where(cond string, args ...any) *QueryBuilder {
+	qb.where = append(qb.where, clause{sql: cond, args: args})
 	return qb
 }
 
 // GroupBy adds GROUP BY clause
 func (qb *QueryBuilder) GroupBy(cols ...string) *QueryBuilder {
-	qb.sql.WriteString(" GROUP BY ")
-	qb.sql.WriteString(strings.Join(cols, ", "))
+	qb.groupBy = append(qb.groupBy, cols...)
 	return qb
 }
 
-// Having adds HAVING clause
+// Having adds HAVING condition, conditions are joined with AND
 func (qb *QueryBuilder) Having(cond string, args ...any) *QueryBuilder {
-	qb.sql.WriteString(" HAVING ")
-
-	// Convert placeholders for postgres
-	if qb.driver == "postgres" {
-		for range args {
-			qb.argIndex++
-			cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", qb.argIndex), 1)
-		}
-	}
+	qb.having = append(qb.having, clause{sql: cond, args: args})
+	return qb
+}
 
-	qb.sql.WriteString(cond)
-	qb.args = append(qb.args, args...)
+// OrderBy adds ORDER BY clause
+func (qb *QueryBuilder) OrderBy(cols ...string) *QueryBuilder {
+	qb.orderBy = append(qb.orderBy, cols...)
 	return qb
 }
 
 // Limit adds LIMIT clause
 func (qb *QueryBuilder) Limit(limit int) *QueryBuilder {
-	if limit > 0 {
-		qb.sql.WriteString(fmt.Sprintf(" LIMIT %d", limit))
-	}
+	qb.limit = limit
 	return qb
 }
 
 // Offset adds OFFSET clause
 func (qb *QueryBuilder) Offset(offset int) *QueryBuilder {
-	if offset > 0 {
-		qb.sql.WriteString(fmt.Sprintf(" OFFSET %d", offset))
-	}
-	return qb
-}
-
-// Join adds JOIN clause
-func (qb *QueryBuilder) Join(joinType, table, cond string) *QueryBuilder {
-	qb.sql.WriteString(fmt.Sprintf(" %s JOIN %s ON %s", joinType, table, cond))
+	qb.offset = offset
 	return qb
 }
 
 // SubQuery adds a subquery
-func (qb *QueryBuilder) SubQuery(subQuery string) *QueryBuilder {
-	qb.sql.WriteString("(")
-	qb.sql.WriteString(subQuery)
-	qb.sql.WriteString(")")
-	return qb
+func (qb *QueryBuilder) SubQuery(subQuery string, args ...any) *QueryBuilder {
+	return qb.Raw("("+subQuery+")", args...)
 }
 
 // Union adds UNION clause
-func (qb *QueryBuilder) Union(all bool, subQuery string) *QueryBuilder {
+func (qb *QueryBuilder) Union(all bool, subQuery string, args ...any) *QueryBuilder {
 	if all {
-		qb.sql.WriteString(" UNION ALL ")
+		return qb.Raw(" UNION ALL "+subQuery, args...)
+	}
+	return qb.Raw(" UNION "+subQuery, args...)
+}
+
+// Raw adds raw SQL. Raw SQL added first starts the query, e.g. a DELETE
+// followed by Where, otherwise it is written after the other clauses.
+func (qb *QueryBuilder) Raw(sql string, args ...any) *QueryBuilder {
+	c := clause{sql: sql, args: args}
+	if qb.empty() {
+		qb.prefix = append(qb.prefix, c)
 	} else {
-		qb.sql.WriteString(" UNION ")
+		qb.suffix = append(qb.suffix, c)
 	}
-	qb.sql.WriteString(subQuery)
 	return qb
 }
 
-// Raw adds raw SQL
-func (qb *QueryBuilder) Raw(sql string, args ...any) *QueryBuilder {
-	if qb.driver == "postgres" {
-		for range args {
-			qb.argIndex++
-			sql = strings.Replace(sql, "?", fmt.Sprintf("$%d", qb.argIndex), 1)
+// empty reports whether nothing but raw prefix SQL was added
+func (qb *QueryBuilder) empty() bool {
+	return len(qb.columns) == 0 && qb.from == "" && len(qb.joins) == 0 &&
+		len(qb.where) == 0 && len(qb.groupBy) == 0 && len(qb.having) == 0 &&
+		len(qb.orderBy) == 0 && qb.limit <= 0 && qb.offset <= 0 && len(qb.suffix) == 0
+}
+
+// build renders the query and collects its arguments
+func (qb *QueryBuilder) build() (string, []any) {
+	w := &queryWriter{driver: qb.driver, args: make([]any, 0)}
+
+	for _, c := range qb.prefix {
+		w.write(c.sql, c.args)
+	}
+	if len(qb.columns) > 0 {
+		w.space()
+		w.sql.WriteString("SELECT " + strings.Join(qb.columns, ", "))
+	}
+	if qb.from != "" {
+		w.space()
+		w.sql.WriteString("FROM " + qb.from)
+	}
+	for _, c := range qb.joins {
+		w.space()
+		w.write(c.sql, c.args)
+	}
+	w.conditions("WHERE", qb.where)
+	if len(qb.groupBy) > 0 {
+		w.space()
+		w.sql.WriteString("GROUP BY " + strings.Join(qb.groupBy, ", "))
+	}
+	w.conditions("HAVING", qb.having)
+	if len(qb.orderBy) > 0 {
+		w.space()
+		w.sql.WriteString("ORDER BY " + strings.Join(qb.orderBy, ", "))
+	}
+	if qb.limit > 0 {
+		w.space()
+		fmt.Fprintf(&w.sql, "LIMIT %d", qb.limit)
+	}
+	if qb.offset > 0 {
+		w.space()
+		fmt.Fprintf(&w.sql, "OFFSET %d", qb.offset)
+	}
+	for _, c := range qb.suffix {
+		w.write(c.sql, c.args)
+	}
+
+	return w.sql.String(), w.args
+}
+
+// queryWriter renders clauses, numbering placeholders across the query
+type queryWriter struct {
+	driver string
+	sql    strings.Builder
+	args   []any
+}
+
+// space separates the next clause from the previous one
+func (w *queryWriter) space() {
+	if s := w.sql.String(); s != "" && !strings.HasSuffix(s, " ") {
+		w.sql.WriteByte(' ')
+	}
+}
+
+// conditions writes conditions joined with AND after keyword
+func (w *queryWriter) conditions(keyword string, conds []clause) {
+	for i, c := range conds {
+		if i == 0 {
+			w.space()
+			w.sql.WriteString(keyword + " ")
+		} else {
+			w.sql.WriteString(" AND ")
 		}
+		w.write(c.sql, c.args)
 	}
-	qb.sql.WriteString(sql)
-	qb.args = append(qb.args, args...)
-	return qb
+}
+
+// write writes sql, replacing each "?" outside quoted text with the
+// placeholders of the next argument. Placeholders without an argument are
+// kept, arguments without a placeholder are still passed.
+func (w *queryWriter) write(sql string, args []any) {
+	quoted := false
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'':
+			quoted = !quoted
+		case ch == '?' && !quoted && len(args) > 0:
+			w.expand(args[0])
+			args = args[1:]
+			continue
+		}
+		w.sql.WriteByte(ch)
+	}
+	w.args = append(w.args, args...)
+}
+
+// expand writes the placeholders of arg, one per element of slices. Byte
+// slices and slices with their own driver value, like arrays, are single
+// arguments.
+func (w *queryWriter) expand(arg any) {
+	v := reflect.ValueOf(arg)
+	_, valuer := arg.(driver.Valuer)
+	if valuer || v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		w.placeholder(arg)
+		return
+	}
+
+	// IN (NULL) is valid on all drivers and matches no row
+	if v.Len() == 0 {
+		w.sql.WriteString("NULL")
+		return
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			w.sql.WriteString(", ")
+		}
+		w.placeholder(v.Index(i).Interface())
+	}
+}
+
+// placeholder writes the placeholder of a single argument
+func (w *queryWriter) placeholder(arg any) {
+	w.args = append(w.args, arg)
+	if w.driver == "postgres" {
+		fmt.Fprintf(&w.sql, "$%d", len(w.args))
+		return
+	}
+	w.sql.WriteByte('?')
 }
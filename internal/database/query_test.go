@@ -0,0 +1,154 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestQueryBuilder tests query generation for each driver
+func TestQueryBuilder(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	testCases := []struct {
+		name  string
+		build func(qb *QueryBuilder)
+		sql   map[string]string // By driver, "" for sqlite and mysql
+		args  []any
+	}{
+		{
+			name: "Select with conditions",
+			build: func(qb *QueryBuilder) {
+				qb.Select("id", "data").From("metrics").
+					Where("timestamp BETWEEN ? AND ?", start, end).
+					Where("agent_id = ?", "a1").
+					OrderBy("timestamp DESC").Limit(10).Offset(20)
+			},
+			sql: map[string]string{
+				"":         "SELECT id, data FROM metrics WHERE timestamp BETWEEN ? AND ? AND agent_id = ? ORDER BY timestamp DESC LIMIT 10 OFFSET 20",
+				"postgres": "SELECT id, data FROM metrics WHERE timestamp BETWEEN $1 AND $2 AND agent_id = $3 ORDER BY timestamp DESC LIMIT 10 OFFSET 20",
+			},
+			args: []any{start, end, "a1"},
+		},
+		{
+			name: "IN list from slice",
+			build: func(qb *QueryBuilder) {
+				qb.Select("id").From("agents").
+					Where("status = ?", "online").
+					Where("id IN (?)", []string{"a1", "a2", "a3"})
+			},
+			sql: map[string]string{
+				"":         "SELECT id FROM agents WHERE status = ? AND id IN (?, ?, ?)",
+				"postgres": "SELECT id FROM agents WHERE status = $1 AND id IN ($2, $3, $4)",
+			},
+			args: []any{"online", "a1", "a2", "a3"},
+		},
+		{
+			name: "Empty IN list matches nothing",
+			build: func(qb *QueryBuilder) {
+				qb.Select("id").From("agents").Where("id IN (?)", []string{}).Where("status = ?", "online")
+			},
+			sql: map[string]string{
+				"":         "SELECT id FROM agents WHERE id IN (NULL) AND status = ?",
+				"postgres": "SELECT id FROM agents WHERE id IN (NULL) AND status = $1",
+			},
+			args: []any{"online"},
+		},
+		{
+			name: "Byte slices and array values are single arguments",
+			build: func(qb *QueryBuilder) {
+				qb.Select("id").From("metrics").
+					Where("data = ?", []byte(`{}`)).
+					Where("tags && ?", pq.StringArray{"a", "b"})
+			},
+			sql: map[string]string{
+				"":         "SELECT id FROM metrics WHERE data = ? AND tags && ?",
+				"postgres": "SELECT id FROM metrics WHERE data = $1 AND tags && $2",
+			},
+			args: []any{[]byte(`{}`), pq.StringArray{"a", "b"}},
+		},
+		{
+			name: "Clauses in SQL order",
+			build: func(qb *QueryBuilder) {
+				qb.Select("a.id", "COUNT(*)").
+					OrderBy("a.id").
+					Having("COUNT(*) > ?", 5).
+					GroupBy("a.id").
+					Where("m.timestamp > ?", start).
+					Join("INNER", "metrics m", "m.agent_id = a.id AND m.tenant_id = ?", "t1").
+					From("agents a")
+			},
+			sql: map[string]string{
+				"":         "SELECT a.id, COUNT(*) FROM agents a INNER JOIN metrics m ON m.agent_id = a.id AND m.tenant_id = ? WHERE m.timestamp > ? GROUP BY a.id HAVING COUNT(*) > ? ORDER BY a.id",
+				"postgres": "SELECT a.id, COUNT(*) FROM agents a INNER JOIN metrics m ON m.agent_id = a.id AND m.tenant_id = $1 WHERE m.timestamp > $2 GROUP BY a.id HAVING COUNT(*) > $3 ORDER BY a.id",
+			},
+			args: []any{"t1", start, 5},
+		},
+		{
+			name: "Quoted question marks are kept",
+			build: func(qb *QueryBuilder) {
+				qb.Select("id").From("audit_logs").Where("resource LIKE '%?%' AND actor = ?", "admin")
+			},
+			sql: map[string]string{
+				"":         "SELECT id FROM audit_logs WHERE resource LIKE '%?%' AND actor = ?",
+				"postgres": "SELECT id FROM audit_logs WHERE resource LIKE '%?%' AND actor = $1",
+			},
+			args: []any{"admin"},
+		},
+		{
+			name: "Raw statement with conditions",
+			build: func(qb *QueryBuilder) {
+				qb.Raw("DELETE FROM metrics").
+					Where("timestamp < ?", start).
+					Where("agent_id IN (?)", []string{"a1", "a2"})
+			},
+			sql: map[string]string{
+				"":         "DELETE FROM metrics WHERE timestamp < ? AND agent_id IN (?, ?)",
+				"postgres": "DELETE FROM metrics WHERE timestamp < $1 AND agent_id IN ($2, $3)",
+			},
+			args: []any{start, "a1", "a2"},
+		},
+		{
+			name: "Union after statement",
+			build: func(qb *QueryBuilder) {
+				qb.Select("agent_id").From("metrics").Where("timestamp > ?", start).
+					Union(false, "SELECT agent_id FROM ip_changes WHERE timestamp > ?", start)
+			},
+			sql: map[string]string{
+				"":         "SELECT agent_id FROM metrics WHERE timestamp > ? UNION SELECT agent_id FROM ip_changes WHERE timestamp > ?",
+				"postgres": "SELECT agent_id FROM metrics WHERE timestamp > $1 UNION SELECT agent_id FROM ip_changes WHERE timestamp > $2",
+			},
+			args: []any{start, start},
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, driver := range []string{"sqlite", "mysql", "postgres"} {
+			t.Run(tc.name+"/"+driver, func(t *testing.T) {
+				qb := NewQueryBuilder(driver)
+				tc.build(qb)
+
+				want, ok := tc.sql[driver]
+				if !ok {
+					want = tc.sql[""]
+				}
+				assert.Equal(t, want, qb.SQL())
+				assert.Equal(t, tc.args, qb.Args())
+			})
+		}
+	}
+}
+
+// TestQueryBuilderReset tests that a reset builder starts a new query
+func TestQueryBuilderReset(t *testing.T) {
+	qb := NewQueryBuilder("postgres")
+	qb.Select("id").From("agents").Where("id = ?", "a1")
+	qb.Reset()
+	qb.Select("id").From("users").Where("name = ?", "admin")
+
+	assert.Equal(t, "SELECT id FROM users WHERE name = $1", qb.SQL())
+	assert.Equal(t, []any{"admin"}, qb.Args())
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"wameter/internal/tracing"
 
 	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
@@ -43,6 +44,7 @@ func NewSQLiteDatabase(dsn string, opts Options, logger *zap.Logger) (Interface,
 		_ = base.Close()
 		return nil, fmt.Errorf("failed to initialize SQLite: %w", err)
 	}
+	base.startPruning(d.Cleanup)
 
 	return d, nil
 }
@@ -106,7 +108,10 @@ func (d *SQLiteDatabase) BatchExec(ctx context.Context, query string, args [][]a
 }
 
 // WithTransaction overrides default implementation with SQLite specific optimizations
-func (d *SQLiteDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
+func (d *SQLiteDatabase) WithTransaction(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, span := d.startSpan(ctx, "transaction", "")
+	defer func() { tracing.End(span, err) }()
+
 	tx, err := d.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
@@ -167,8 +172,9 @@ func (d *SQLiteDatabase) Cleanup(ctx context.Context, before time.Time) error {
 	var totalDeleted int64
 
 	for {
+		// SQLite is built without DELETE ... LIMIT
 		result, err := d.ExecContext(ctx,
-			"DELETE FROM metrics WHERE timestamp < ? LIMIT ?",
+			"DELETE FROM metrics WHERE rowid IN (SELECT rowid FROM metrics WHERE timestamp < ? LIMIT ?)",
 			before, batchSize)
 		if err != nil {
 			return fmt.Errorf("cleanup failed: %w", err)
@@ -180,6 +186,7 @@ func (d *SQLiteDatabase) Cleanup(ctx context.Context, before time.Time) error {
 		}
 
 		totalDeleted += affected
+		reportCleanupProgress(ctx, totalDeleted)
 		if affected < int64(batchSize) {
 			break
 		}
@@ -232,6 +239,7 @@ func addSQLiteParams(dsn string, opts Options) string {
 		fmt.Sprintf("_cache_size=-%d", opts.MaxOpenConns*200),
 		"_foreign_keys=1",
 		"_temp_store=MEMORY",
+		"_loc=UTC",
 	}
 
 	query := "?" + strings.Join(params, "&")
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// openUTC opens a database whose connections bind every time argument in
+// UTC, so timestamps are stored the same whatever the timezone of the server
+// or of the agent that reported them
+func openUTC(driverName, dsn string) (*sql.DB, error) {
+	// Resolve the registered driver
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+
+	var connector driver.Connector = dsnConnector{driver: drv, dsn: dsn}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, fmt.Errorf("failed to parse dsn: %w", err)
+		}
+	}
+
+	return sql.OpenDB(utcConnector{connector}), nil
+}
+
+// dsnConnector connects with a driver that has no connector of its own
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+// Connect opens a connection
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying driver
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// utcConnector wraps the connections of a connector in utcConn
+type utcConnector struct {
+	driver.Connector
+}
+
+// Connect opens a connection
+func (c utcConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &utcConn{Conn: conn}, nil
+}
+
+// utcConn converts time arguments to UTC before handing them to the driver.
+// The optional interfaces of the driver connection are forwarded
+type utcConn struct {
+	driver.Conn
+}
+
+// CheckNamedValue converts time arguments to UTC
+func (c *utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	nv.Value = toUTC(nv.Value)
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ExecContext executes a query without preparing it, if the driver can
+func (c *utcConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext runs a query without preparing it, if the driver can
+func (c *utcConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// PrepareContext prepares a statement
+func (c *utcConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx starts a transaction
+func (c *utcConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // Fallback of drivers without BeginTx
+}
+
+// Ping checks the connection
+func (c *utcConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the connection before it is reused
+func (c *utcConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection can be reused
+func (c *utcConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// toUTC returns a time argument in UTC, other arguments unchanged
+func toUTC(v any) any {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case *time.Time:
+		if t != nil {
+			return t.UTC()
+		}
+	case sql.NullTime:
+		if t.Valid {
+			t.Time = t.Time.UTC()
+		}
+		return t
+	}
+	return v
+}
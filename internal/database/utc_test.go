@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestUTCTimestamps tests that times of any timezone are stored and read
+// back in UTC
+func TestUTCTimestamps(t *testing.T) {
+	db, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "data.db"), Options{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE events (id INTEGER, timestamp DATETIME)")
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 1, 2, 11, 4, 5, 0, time.FixedZone("UTC+8", 8*60*60))
+	args := []any{ts, &ts, sql.NullTime{Time: ts, Valid: true}, sql.NullTime{}}
+	for i, arg := range args {
+		_, err = db.ExecContext(ctx, "INSERT INTO events (id, timestamp) VALUES (?, ?)", i, arg)
+		require.NoError(t, err)
+	}
+
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range args[:3] {
+		var stored string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT CAST(timestamp AS TEXT) FROM events WHERE id = ?", i).Scan(&stored))
+		assert.Equal(t, "2024-01-02 03:04:05+00:00", stored)
+
+		var got time.Time
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT timestamp FROM events WHERE id = ?", i).Scan(&got))
+		assert.Equal(t, want, got)
+	}
+
+	// Range queries compare the stored text
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events WHERE timestamp >= ?",
+		want.In(time.FixedZone("UTC-5", -5*60*60))).Scan(&count))
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,208 @@
+package ipinfo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultWhoisServer is used to discover the authoritative WHOIS server
+	defaultWhoisServer = "whois.iana.org"
+	// maxWhoisResponse limits the size of WHOIS responses
+	maxWhoisResponse = 64 * 1024
+)
+
+// Config represents IP context lookup configuration
+type Config struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	ReverseDNS bool          `mapstructure:"reverse_dns"`
+	Whois      bool          `mapstructure:"whois"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	CacheTTL   time.Duration `mapstructure:"cache_ttl"`
+}
+
+// cacheEntry represents a cached lookup result
+type cacheEntry struct {
+	context   *types.IPContext
+	expiresAt time.Time
+}
+
+// Resolver looks up reverse DNS and WHOIS context of IP addresses
+type Resolver struct {
+	config   *Config
+	logger   *zap.Logger
+	resolver *net.Resolver
+	dialer   *net.Dialer
+	cache    map[string]*cacheEntry
+	mu       sync.Mutex
+}
+
+// NewResolver creates new IP context resolver
+func NewResolver(cfg *Config, logger *zap.Logger) *Resolver {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 24 * time.Hour
+	}
+
+	return &Resolver{
+		config:   cfg,
+		logger:   logger,
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: cfg.Timeout},
+		cache:    make(map[string]*cacheEntry),
+	}
+}
+
+// Lookup returns the context of an IP address, using cached results when available
+func (r *Resolver) Lookup(ctx context.Context, ip string) *types.IPContext {
+	addr := stripPrefix(ip)
+	if net.ParseIP(addr) == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if entry, ok := r.cache[addr]; ok && time.Now().Before(entry.expiresAt) {
+		r.mu.Unlock()
+		return entry.context
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	result := &types.IPContext{
+		IP:         addr,
+		LookedUpAt: time.Now(),
+	}
+
+	if r.config.ReverseDNS {
+		names, err := r.resolver.LookupAddr(ctx, addr)
+		if err != nil {
+			r.logger.Debug("Reverse DNS lookup failed",
+				zap.String("ip", addr),
+				zap.Error(err))
+		}
+		for _, name := range names {
+			result.ReverseDNS = append(result.ReverseDNS, strings.TrimSuffix(name, "."))
+		}
+	}
+
+	if r.config.Whois {
+		if err := r.whois(ctx, addr, result); err != nil {
+			r.logger.Debug("WHOIS lookup failed",
+				zap.String("ip", addr),
+				zap.Error(err))
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[addr] = &cacheEntry{
+		context:   result,
+		expiresAt: time.Now().Add(r.config.CacheTTL),
+	}
+	// Drop expired entries
+	for key, entry := range r.cache {
+		if time.Now().After(entry.expiresAt) {
+			delete(r.cache, key)
+		}
+	}
+	r.mu.Unlock()
+
+	return result
+}
+
+// whois queries IANA for the authoritative server and then the server itself
+func (r *Resolver) whois(ctx context.Context, ip string, result *types.IPContext) error {
+	fields, err := r.queryWhois(ctx, defaultWhoisServer, ip)
+	if err != nil {
+		return err
+	}
+
+	server := defaultWhoisServer
+	if refer := fields["refer"]; refer != "" {
+		server = refer
+		if fields, err = r.queryWhois(ctx, server, ip); err != nil {
+			return err
+		}
+	}
+
+	result.Source = server
+	result.NetName = firstOf(fields, "netname", "network-name")
+	result.Org = firstOf(fields, "orgname", "org-name", "organization", "owner", "descr")
+	result.Country = strings.ToUpper(firstOf(fields, "country"))
+	result.ASN = firstOf(fields, "originas", "origin", "aut-num")
+
+	return nil
+}
+
+// queryWhois sends a WHOIS query and returns the first value of each key
+func (r *Resolver) queryWhois(ctx context.Context, server, query string) (map[string]string, error) {
+	conn, err := r.dialer.DialContext(ctx, "tcp", net.JoinHostPort(server, "43"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", server, err)
+	}
+	defer func(conn net.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", query); err != nil {
+		return nil, fmt.Errorf("failed to send query to %s: %w", server, err)
+	}
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(io.LimitReader(conn, maxWhoisResponse))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if _, exists := fields[key]; !exists && value != "" {
+			fields[key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", server, err)
+	}
+
+	return fields, nil
+}
+
+// firstOf returns the first non-empty value of the given keys
+func firstOf(fields map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := fields[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// stripPrefix removes the prefix length from an address in CIDR notation
+func stripPrefix(addr string) string {
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		return addr[:i]
+	}
+	return addr
+}
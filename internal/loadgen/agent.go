@@ -0,0 +1,138 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/version"
+)
+
+// agent represents a simulated agent, its interface counters grow between
+// reports like those of a busy host
+type agent struct {
+	id         string
+	hostname   string
+	interfaces []*simInterface
+	rand       *rand.Rand
+	last       time.Time
+}
+
+// simInterface represents an interface of a simulated agent
+type simInterface struct {
+	info   types.InterfaceInfo
+	rxRate float64 // Mean bytes per second
+	txRate float64
+	stats  types.InterfaceStats
+}
+
+// newAgent creates the n-th simulated agent with the given number of
+// interfaces
+func newAgent(prefix string, n, interfaces int) *agent {
+	a := &agent{
+		id:       fmt.Sprintf("%s-%05d", prefix, n),
+		hostname: fmt.Sprintf("%s-host-%05d", prefix, n),
+		rand:     rand.New(rand.NewPCG(uint64(n), 0x77616d65746572)),
+	}
+	for i := 0; i < interfaces; i++ {
+		a.interfaces = append(a.interfaces, &simInterface{
+			info: types.InterfaceInfo{
+				Name:   fmt.Sprintf("eth%d", i),
+				Type:   "ethernet",
+				MAC:    fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", byte(n>>16), byte(n>>8), byte(n), byte(i)),
+				MTU:    1500,
+				Flags:  "up|broadcast|multicast",
+				IPv4:   []string{fmt.Sprintf("10.%d.%d.%d", i, byte(n>>8), byte(n))},
+				Status: "up",
+			},
+			// Up to 10 MB/s, most interfaces far less
+			rxRate: 10 << 20 * a.rand.Float64() * a.rand.Float64(),
+			txRate: 10 << 20 * a.rand.Float64() * a.rand.Float64(),
+			stats: types.InterfaceStats{
+				IsUp:       true,
+				OperState:  "up",
+				Speed:      1000,
+				HasCarrier: true,
+			},
+		})
+	}
+	return a
+}
+
+// info returns the registration of the agent
+func (a *agent) info() *types.AgentInfo {
+	return &types.AgentInfo{
+		ID:       a.id,
+		Hostname: a.hostname,
+		Version:  version.GetInfo().Version,
+		Status:   types.AgentStatusOnline,
+		Capabilities: &types.AgentCapabilities{
+			APIVersion: types.AgentAPIVersion,
+		},
+	}
+}
+
+// report returns the next report of the agent at now
+func (a *agent) report(now time.Time) *types.MetricsData {
+	elapsed := 0.0
+	if !a.last.IsZero() {
+		elapsed = now.Sub(a.last).Seconds()
+	}
+	a.last = now
+
+	network := &types.NetworkState{
+		Interfaces: make(map[string]*types.InterfaceInfo, len(a.interfaces)),
+		ExternalIP: fmt.Sprintf("203.0.113.%d", a.rand.IntN(254)+1),
+	}
+	for _, iface := range a.interfaces {
+		iface.advance(a.rand, elapsed)
+		info := iface.info
+		stats := iface.stats
+		info.Statistics = &stats
+		info.UpdatedAt = now
+		network.Interfaces[info.Name] = &info
+	}
+
+	data := &types.MetricsData{
+		SchemaVersion: types.MetricsSchemaVersion,
+		AgentID:       a.id,
+		Hostname:      a.hostname,
+		Version:       version.GetInfo().Version,
+		Timestamp:     now,
+		CollectedAt:   now,
+		ReportedAt:    now,
+	}
+	data.Metrics.Network = network
+	return data
+}
+
+// advance grows the counters of an interface by elapsed seconds of
+// traffic, varying around its mean rates
+func (i *simInterface) advance(r *rand.Rand, elapsed float64) {
+	s := &i.stats
+	if elapsed <= 0 {
+		s.RxBytesRate, s.TxBytesRate, s.RxPacketsRate, s.TxPacketsRate = 0, 0, 0, 0
+		return
+	}
+
+	rx := uint64(i.rxRate * (0.5 + r.Float64()) * elapsed)
+	tx := uint64(i.txRate * (0.5 + r.Float64()) * elapsed)
+	rxPackets, txPackets := rx/800, tx/800 // Mean packet size
+
+	s.RxBytes += rx
+	s.TxBytes += tx
+	s.RxPackets += rxPackets
+	s.TxPackets += txPackets
+	if r.IntN(100) == 0 {
+		s.RxErrors++
+		s.RxDropped += uint64(r.IntN(10))
+	}
+
+	s.RxBytesDelta, s.TxBytesDelta = rx, tx
+	s.RxPacketsDelta, s.TxPacketsDelta = rxPackets, txPackets
+	s.SampleInterval = elapsed
+	s.RxBytesRate = float64(rx) / elapsed
+	s.TxBytesRate = float64(tx) / elapsed
+	s.RxPacketsRate = float64(rxPackets) / elapsed
+	s.TxPacketsRate = float64(txPackets) / elapsed
+}
@@ -0,0 +1,323 @@
+// Package loadgen simulates agents reporting metrics to a server, measuring
+// the throughput, latency and errors of the ingest path for capacity
+// planning.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"wameter/internal/compress"
+	"wameter/internal/version"
+)
+
+// Output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// registerConcurrency bounds the registrations in flight
+const registerConcurrency = 32
+
+const usage = `Usage: wameter-loadgen [flags]
+
+Simulates agents registering with a server and reporting metrics at a fixed
+interval each, then prints the achieved throughput, latency percentiles and
+error rate. The agents are named <prefix>-NNNNN and stay registered.
+
+Flags:
+`
+
+// Config represents the configuration of a load test
+type Config struct {
+	Server      string
+	Token       string
+	Agents      int
+	Interfaces  int           // Per agent
+	Interval    time.Duration // Between the reports of an agent
+	Duration    time.Duration
+	Compression string
+	Prefix      string // Of the agent IDs
+	Timeout     time.Duration
+	Progress    time.Duration // Between progress lines, zero for none
+}
+
+// Run runs wameter-loadgen with the given arguments and returns the exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	cfg := &Config{}
+	fs := flag.NewFlagSet("wameter-loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.Server, "server", envOr("WAMETER_SERVER", "http://localhost:8080"), "Server address")
+	fs.StringVar(&cfg.Token, "token", os.Getenv("WAMETER_TOKEN"), "API token")
+	fs.IntVar(&cfg.Agents, "agents", 100, "Number of simulated agents")
+	fs.IntVar(&cfg.Interfaces, "interfaces", 4, "Interfaces per agent")
+	fs.DurationVar(&cfg.Interval, "interval", 10*time.Second, "Report interval of each agent")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "Duration of the test")
+	fs.StringVar(&cfg.Compression, "compression", compress.None, "Report encoding: none, gzip, zstd")
+	fs.StringVar(&cfg.Prefix, "prefix", "loadgen", "Prefix of the agent IDs")
+	fs.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "Request timeout")
+	fs.DurationVar(&cfg.Progress, "progress", 10*time.Second, "Interval of progress lines on stderr, 0 to disable")
+	output := fs.String("o", OutputTable, "Output format: table, json")
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output != OutputTable && *output != OutputJSON {
+		_, _ = fmt.Fprintf(stderr, "unsupported output format: %s\n", *output)
+		return 2
+	}
+	if err := cfg.Validate(); err != nil {
+		_, _ = fmt.Fprintf(stderr, "%v\n", err)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	summary, err := New(cfg).Run(ctx, stderr)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *output == OutputJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(summary)
+	} else {
+		err = summary.printTable(stdout)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Validate validates the configuration of a load test
+func (cfg *Config) Validate() error {
+	cfg.Server = strings.TrimRight(cfg.Server, "/")
+	if cfg.Server == "" {
+		return errors.New("server address is required")
+	}
+	if cfg.Agents <= 0 {
+		return errors.New("agents must be positive")
+	}
+	if cfg.Interfaces <= 0 {
+		return errors.New("interfaces must be positive")
+	}
+	if cfg.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	if !compress.Supported(cfg.Compression) {
+		return fmt.Errorf("%w: %s", compress.ErrUnsupported, cfg.Compression)
+	}
+	if cfg.Prefix == "" {
+		return errors.New("prefix is required")
+	}
+	return nil
+}
+
+// LoadGen represents a load test
+type LoadGen struct {
+	config   *Config
+	client   *http.Client
+	recorder *recorder
+}
+
+// New creates a load test
+func New(cfg *Config) *LoadGen {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.Agents
+	return &LoadGen{
+		config: cfg,
+		client: &http.Client{Transport: transport, Timeout: cfg.Timeout},
+	}
+}
+
+// Run registers the agents and reports their metrics until the duration
+// elapsed or ctx is done, printing progress to w
+func (l *LoadGen) Run(ctx context.Context, w io.Writer) (*Summary, error) {
+	agents, err := l.registerAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintf(w, "Registered %d agents, reporting every %s for %s\n",
+		len(agents), l.config.Interval, l.config.Duration)
+
+	ctx, cancel := context.WithTimeout(ctx, l.config.Duration)
+	defer cancel()
+
+	l.recorder = newRecorder()
+	var wg sync.WaitGroup
+	for _, a := range agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.runAgent(ctx, a)
+		}()
+	}
+	if l.config.Progress > 0 {
+		go l.progress(ctx, w)
+	}
+	wg.Wait()
+
+	return l.recorder.summary(len(agents)), nil
+}
+
+// runAgent sends the reports of an agent, the agents start spread over the
+// first interval so their reports do not arrive in bursts
+func (l *LoadGen) runAgent(ctx context.Context, a *agent) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(rand.N(l.config.Interval)):
+	}
+
+	ticker := time.NewTicker(l.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		l.send(ctx, a)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// send sends the next report of an agent and records the outcome
+func (l *LoadGen) send(ctx context.Context, a *agent) {
+	payload, err := json.Marshal(a.report(time.Now()))
+	if err == nil && !compress.Identity(l.config.Compression) {
+		payload, err = compress.Encode(payload, l.config.Compression)
+	}
+	if err != nil {
+		l.recorder.record(0, 0, 0, err)
+		return
+	}
+
+	start := time.Now()
+	status, err := l.post(ctx, "/v1/metrics", payload, l.config.Compression)
+	if ctx.Err() != nil {
+		return // Interrupted by the end of the test
+	}
+	l.recorder.record(time.Since(start), len(payload), status, err)
+}
+
+// registerAgents creates the simulated agents and registers them with the
+// server, registerConcurrency at a time
+func (l *LoadGen) registerAgents(ctx context.Context) ([]*agent, error) {
+	agents := make([]*agent, l.config.Agents)
+	errs := make([]error, len(agents))
+	sem := make(chan struct{}, registerConcurrency)
+	var wg sync.WaitGroup
+	for i := range agents {
+		agents[i] = newAgent(l.config.Prefix, i, l.config.Interfaces)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := l.register(ctx, agents[i]); err != nil {
+				errs[i] = fmt.Errorf("failed to register agent %s: %w", agents[i].id, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return agents, nil
+}
+
+// register registers an agent with the server
+func (l *LoadGen) register(ctx context.Context, a *agent) error {
+	payload, err := json.Marshal(a.info())
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent info: %w", err)
+	}
+	status, err := l.post(ctx, "/v1/agents", payload, compress.None)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		return fmt.Errorf("server returned status %d", status)
+	}
+	return nil
+}
+
+// post sends an encoded body to the server and returns the response status
+func (l *LoadGen) post(ctx context.Context, path string, body []byte, encoding string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.config.Server+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !compress.Identity(encoding) {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.Header.Set("User-Agent", "wameter-loadgen/"+version.GetInfo().Version)
+	if l.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.config.Token)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// progress prints the results so far at the progress interval
+func (l *LoadGen) progress(ctx context.Context, w io.Writer) {
+	ticker := time.NewTicker(l.config.Progress)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := l.recorder.summary(l.config.Agents)
+			_, _ = fmt.Fprintf(w, "%s: %d requests, %.1f reports/s, p99 %s, %.2f%% errors\n",
+				s.Duration.Round(time.Second), s.Requests, s.Throughput, s.Latency.P99, s.ErrorRate*100)
+		}
+	}
+}
+
+// envOr returns the value of an environment variable, def when unset
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
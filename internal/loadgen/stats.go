@@ -0,0 +1,177 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"wameter/internal/utils"
+)
+
+// recorder collects the outcome of the requests sent
+type recorder struct {
+	start     time.Time
+	latencies []time.Duration // Of successful requests
+	failed    int
+	bytes     int64
+	statuses  map[string]int // Responses by status code, failed requests by error
+	mu        sync.Mutex
+}
+
+// newRecorder creates a recorder started now
+func newRecorder() *recorder {
+	return &recorder{start: time.Now(), statuses: make(map[string]int)}
+}
+
+// record records a request sending bytes, answered with status or failed
+// with err
+func (r *recorder) record(latency time.Duration, bytes int, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bytes += int64(bytes)
+	switch {
+	case err != nil:
+		r.failed++
+		r.statuses[errorKind(err)]++
+	case status != http.StatusOK && status != http.StatusCreated:
+		r.failed++
+		r.statuses[strconv.Itoa(status)]++
+	default:
+		r.latencies = append(r.latencies, latency)
+		r.statuses[strconv.Itoa(status)]++
+	}
+}
+
+// Summary represents the results of a load test
+type Summary struct {
+	Agents     int            `json:"agents"`
+	Duration   time.Duration  `json:"duration"`
+	Requests   int            `json:"requests"`
+	Succeeded  int            `json:"succeeded"`
+	Failed     int            `json:"failed"`
+	ErrorRate  float64        `json:"error_rate"` // Failed requests per request
+	Throughput float64        `json:"throughput"` // Successful reports per second
+	BytesRate  float64        `json:"bytes_rate"` // Request body bytes per second
+	Latency    LatencySummary `json:"latency"`    // Of successful requests
+	Statuses   map[string]int `json:"statuses"`   // Responses by status code, failed requests by error
+}
+
+// LatencySummary represents the latency distribution of requests
+type LatencySummary struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// summary summarizes the requests recorded so far
+func (r *recorder) summary(agents int) *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.start)
+	s := &Summary{
+		Agents:    agents,
+		Duration:  elapsed.Round(time.Millisecond),
+		Succeeded: len(r.latencies),
+		Failed:    r.failed,
+		Statuses:  make(map[string]int, len(r.statuses)),
+	}
+	s.Requests = s.Succeeded + s.Failed
+	for k, v := range r.statuses {
+		s.Statuses[k] = v
+	}
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Failed) / float64(s.Requests)
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		s.Throughput = float64(s.Succeeded) / seconds
+		s.BytesRate = float64(r.bytes) / seconds
+	}
+
+	if len(r.latencies) > 0 {
+		latencies := slices.Clone(r.latencies)
+		slices.Sort(latencies)
+		var total time.Duration
+		for _, l := range latencies {
+			total += l
+		}
+		s.Latency = LatencySummary{
+			Min:  latencies[0],
+			Mean: total / time.Duration(len(latencies)),
+			P50:  percentile(latencies, 50),
+			P90:  percentile(latencies, 90),
+			P95:  percentile(latencies, 95),
+			P99:  percentile(latencies, 99),
+			Max:  latencies[len(latencies)-1],
+		}
+	}
+	return s
+}
+
+// percentile returns the p-th percentile of sorted latencies, by the
+// nearest rank
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// errorKind names the error of a failed request for the summary
+func errorKind(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Client.Timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(msg, "connection refused"):
+		return "connection refused"
+	case strings.Contains(msg, "connection reset"):
+		return "connection reset"
+	default:
+		return "error"
+	}
+}
+
+// printTable writes a summary as a table
+func (s *Summary) printTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	rows := [][2]string{
+		{"Agents", strconv.Itoa(s.Agents)},
+		{"Duration", s.Duration.String()},
+		{"Requests", strconv.Itoa(s.Requests)},
+		{"Succeeded", strconv.Itoa(s.Succeeded)},
+		{"Failed", strconv.Itoa(s.Failed)},
+		{"Error Rate", fmt.Sprintf("%.2f%%", s.ErrorRate*100)},
+		{"Throughput", fmt.Sprintf("%.1f reports/s", s.Throughput)},
+		{"Upload", utils.FormatBytesRate(s.BytesRate) + "/s"},
+		{"Latency Min", s.Latency.Min.String()},
+		{"Latency Mean", s.Latency.Mean.String()},
+		{"Latency P50", s.Latency.P50.String()},
+		{"Latency P90", s.Latency.P90.String()},
+		{"Latency P95", s.Latency.P95.String()},
+		{"Latency P99", s.Latency.P99.String()},
+		{"Latency Max", s.Latency.Max.String()},
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+
+	statuses := make([]string, 0, len(s.Statuses))
+	for status := range s.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		_, _ = fmt.Fprintf(tw, "Status %s:\t%d\n", status, s.Statuses[status])
+	}
+	return tw.Flush()
+}
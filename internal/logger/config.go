@@ -10,6 +10,8 @@ type Config struct {
 	MaxAge     int    `mapstructure:"max_age"` // days
 	Compress   bool   `mapstructure:"compress"`
 	Level      string `mapstructure:"level"` // debug, info, warn, error
+	// DisableConsole disables console output, e.g. while a terminal UI owns stdout
+	DisableConsole bool `mapstructure:"disable_console"`
 }
 
 // Validate validates logging configuration
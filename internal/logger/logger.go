@@ -10,6 +10,14 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// level is shared by loggers created with New so it can be changed at runtime
+var level = zap.NewAtomicLevel()
+
+// SetLevel changes the level of loggers created with New
+func SetLevel(l string) {
+	level.SetLevel(getZapLevel(l))
+}
+
 // New creates a new logger instance with the provided configuration
 func New(cfg *Config) (*zap.Logger, error) {
 	if cfg == nil {
@@ -36,7 +44,7 @@ func New(cfg *Config) (*zap.Logger, error) {
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 
 	// Set log level
-	level := getZapLevel(cfg.Level)
+	SetLevel(cfg.Level)
 
 	var cores []zapcore.Core
 
@@ -58,9 +66,18 @@ func New(cfg *Config) (*zap.Logger, error) {
 	}
 
 	// Add console output
+	if !cfg.DisableConsole {
+		cores = append(cores, zapcore.NewCore(
+			zapcore.NewConsoleEncoder(encoderConfig),
+			zapcore.AddSync(os.Stdout),
+			level,
+		))
+	}
+
+	// Keep the latest entries in memory for diagnostics bundles
 	cores = append(cores, zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
+		zapcore.NewJSONEncoder(encoderConfig),
+		recent,
 		level,
 	))
 
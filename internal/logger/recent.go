@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+)
+
+// recentLines is the number of log lines kept in memory for diagnostics
+const recentLines = 1000
+
+// recent keeps the latest lines written by loggers created with New
+var recent = &ringBuffer{
+	lines:     make([]string, recentLines),
+	followers: make(map[chan string]struct{}),
+}
+
+// Recent returns the latest log lines, oldest first
+func Recent() []string {
+	return recent.snapshot()
+}
+
+// Follow returns a channel receiving the JSON lines written by loggers created
+// with New from now on. Lines are dropped while the channel is full, stop ends
+// the subscription and closes the channel.
+func Follow(buffer int) (lines <-chan string, stop func()) {
+	ch := make(chan string, buffer)
+
+	recent.mu.Lock()
+	recent.followers[ch] = struct{}{}
+	recent.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			recent.mu.Lock()
+			delete(recent.followers, ch)
+			recent.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// ringBuffer is a WriteSyncer keeping the last lines written to it and
+// passing them on to followers
+type ringBuffer struct {
+	mu        sync.Mutex
+	lines     []string
+	next      int
+	full      bool
+	followers map[chan string]struct{}
+}
+
+// Write stores each line of p, zap writes one entry per call
+func (b *ringBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		if b.next == 0 {
+			b.full = true
+		}
+
+		for ch := range b.followers {
+			select {
+			case ch <- line:
+			default:
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer
+func (b *ringBuffer) Sync() error {
+	return nil
+}
+
+// snapshot copies the stored lines, oldest first
+func (b *ringBuffer) snapshot() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+
+	lines := make([]string, 0, len(b.lines))
+	lines = append(lines, b.lines[b.next:]...)
+	return append(lines, b.lines[:b.next]...)
+}
@@ -25,10 +25,11 @@ type FeishuNotifier struct {
 	logger    *zap.Logger
 	client    *http.Client
 	tplLoader *ntpl.Loader
+	locale    string // Set by the manager
 }
 
 // NewFeishuNotifier creates new Feishu notifier
-func NewFeishuNotifier(cfg *config.FeishuConfig, loader *ntpl.Loader, logger *zap.Logger) (*FeishuNotifier, error) {
+func NewFeishuNotifier(cfg *config.FeishuConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*FeishuNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("feishu notifier is disabled")
 	}
@@ -38,18 +39,9 @@ func NewFeishuNotifier(cfg *config.FeishuConfig, loader *ntpl.Loader, logger *za
 	}
 
 	return &FeishuNotifier{
-		config: cfg,
-		logger: logger,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     30 * time.Second,
-				DisableCompression:  true,
-				DisableKeepAlives:   false,
-				MaxIdleConnsPerHost: 5,
-			},
-		},
+		config:    cfg,
+		logger:    logger,
+		client:    client,
 		tplLoader: loader,
 	}, nil
 }
@@ -63,6 +55,34 @@ func (n *FeishuNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 	return n.sendTemplate("agent_offline", data)
 }
 
+// NotifyAgentOnline sends agent recovery notification
+func (n *FeishuNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_online", data)
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *FeishuNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_missing", data)
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *FeishuNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("rule_alert", data)
+}
+
 // NotifyNetworkErrors sends network errors notification
 func (n *FeishuNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
 	data := map[string]any{
@@ -101,14 +121,32 @@ func (n *FeishuNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IP
 		"OldAddrs":      change.OldAddrs,
 		"NewAddrs":      change.NewAddrs,
 		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
 		"Timestamp":     time.Now(),
 	}
 	return n.sendTemplate("ip_change", data)
 }
 
+// NotifyReport sends a summary report
+func (n *FeishuNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
+	}
+	return n.sendTemplate("report", data)
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *FeishuNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("heartbeat", data)
+}
+
 // sendTemplate sends notification using template
 func (n *FeishuNotifier) sendTemplate(templateName string, data map[string]any) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.Feishu, templateName)
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.Feishu, n.locale, templateName)
 	if err != nil {
 		return fmt.Errorf("failed to get template: %w", err)
 	}
@@ -25,6 +25,7 @@ type DingTalkNotifier struct {
 	logger    *zap.Logger
 	client    *http.Client
 	tplLoader *ntpl.Loader
+	locale    string // Set by the manager
 }
 
 // DingMessage represents DingTalk message
@@ -48,7 +49,7 @@ type DingAt struct {
 }
 
 // NewDingTalkNotifier creates a new DingTalk notifier
-func NewDingTalkNotifier(cfg *config.DingTalkConfig, loader *ntpl.Loader, logger *zap.Logger) (*DingTalkNotifier, error) {
+func NewDingTalkNotifier(cfg *config.DingTalkConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*DingTalkNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("dingtalk notifier is disabled")
 	}
@@ -58,11 +59,9 @@ func NewDingTalkNotifier(cfg *config.DingTalkConfig, loader *ntpl.Loader, logger
 	}
 
 	return &DingTalkNotifier{
-		config: cfg,
-		logger: logger,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		config:    cfg,
+		logger:    logger,
+		client:    client,
 		tplLoader: loader,
 	}, nil
 }
@@ -74,7 +73,38 @@ func (n *DingTalkNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 		"Agent":     agent,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("agent_offline", data, "Agent Offline Alert")
+	return n.sendTemplate("agent_offline", data, n.tplLoader.Message(n.locale, "title.agent_offline"))
+}
+
+// NotifyAgentOnline sends agent recovery notification
+func (n *DingTalkNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	// Prepare data
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_online", data, n.tplLoader.Message(n.locale, "title.agent_online"))
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *DingTalkNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_missing", data, n.tplLoader.Message(n.locale, "title.agent_missing"))
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *DingTalkNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("rule_alert", data, n.tplLoader.Message(n.locale, "title.rule_alert", alert.Rule))
 }
 
 // NotifyNetworkErrors sends network errors notification
@@ -85,7 +115,7 @@ func (n *DingTalkNotifier) NotifyNetworkErrors(agentID string, iface *types.Inte
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("network_error", data, "Network Errors Alert")
+	return n.sendTemplate("network_error", data, n.tplLoader.Message(n.locale, "title.network_error"))
 }
 
 // NotifyHighNetworkUtilization sends high network utilization notification
@@ -96,7 +126,7 @@ func (n *DingTalkNotifier) NotifyHighNetworkUtilization(agentID string, iface *t
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("high_utilization", data, "High Network Utilization Alert")
+	return n.sendTemplate("high_utilization", data, n.tplLoader.Message(n.locale, "title.high_utilization"))
 }
 
 // NotifyIPChange sends IP change notification
@@ -105,18 +135,38 @@ func (n *DingTalkNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.
 		"Agent":         agent,
 		"Change":        change,
 		"Timestamp":     time.Now(),
+		"Action":        change.Action,
+		"Reason":        change.Reason,
 		"IsExternal":    change.IsExternal,
 		"Version":       change.Version,
 		"OldAddrs":      change.OldAddrs,
 		"NewAddrs":      change.NewAddrs,
 		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
+	}
+	return n.sendTemplate("ip_change", data, n.tplLoader.Message(n.locale, "title.ip_change"))
+}
+
+// NotifyReport sends a summary report
+func (n *DingTalkNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
+	}
+	return n.sendTemplate("report", data, n.tplLoader.Message(n.locale, "title.report"))
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *DingTalkNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
+		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("ip_change", data, "markdown")
+	return n.sendTemplate("heartbeat", data, n.tplLoader.Message(n.locale, "title.heartbeat"))
 }
 
 // sendTemplate sends DingTalk message
 func (n *DingTalkNotifier) sendTemplate(templateName string, data map[string]any, title string) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.DingTalk, templateName)
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.DingTalk, n.locale, templateName)
 	if err != nil {
 		return fmt.Errorf("failed to get template: %w", err)
 	}
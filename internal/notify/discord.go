@@ -21,6 +21,7 @@ type DiscordNotifier struct {
 	logger    *zap.Logger
 	client    *http.Client
 	tplLoader *ntpl.Loader
+	locale    string // Set by the manager
 }
 
 // DiscordMessage represents Discord message
@@ -52,7 +53,7 @@ type DiscordField struct {
 }
 
 // NewDiscordNotifier creates new Discord notifier
-func NewDiscordNotifier(cfg *config.DiscordConfig, loader *ntpl.Loader, logger *zap.Logger) (*DiscordNotifier, error) {
+func NewDiscordNotifier(cfg *config.DiscordConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*DiscordNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("discord notifier is disabled")
 	}
@@ -62,18 +63,9 @@ func NewDiscordNotifier(cfg *config.DiscordConfig, loader *ntpl.Loader, logger *
 	}
 
 	return &DiscordNotifier{
-		config: cfg,
-		logger: logger,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     30 * time.Second,
-				DisableCompression:  true,
-				DisableKeepAlives:   false,
-				MaxIdleConnsPerHost: 5,
-			},
-		},
+		config:    cfg,
+		logger:    logger,
+		client:    client,
 		tplLoader: loader,
 	}, nil
 }
@@ -88,6 +80,37 @@ func (n *DiscordNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 	return n.sendTemplate("agent_offline", data)
 }
 
+// NotifyAgentOnline sends agent recovery notification
+func (n *DiscordNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	// Prepare data
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_online", data)
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *DiscordNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_missing", data)
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *DiscordNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("rule_alert", data)
+}
+
 // NotifyNetworkErrors sends network errors notification
 func (n *DiscordNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
 	// Prepare data
@@ -116,18 +139,38 @@ func (n *DiscordNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.I
 		"Agent":         agent,
 		"Change":        change,
 		"Timestamp":     time.Now(),
+		"Action":        change.Action,
+		"Reason":        change.Reason,
 		"IsExternal":    change.IsExternal,
 		"Version":       change.Version,
 		"OldAddrs":      change.OldAddrs,
 		"NewAddrs":      change.NewAddrs,
 		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
 	}
 	return n.sendTemplate("ip_change", data)
 }
 
+// NotifyReport sends a summary report
+func (n *DiscordNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
+	}
+	return n.sendTemplate("report", data)
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *DiscordNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("heartbeat", data)
+}
+
 // sendTemplate sends Discord message
 func (n *DiscordNotifier) sendTemplate(templateName string, data map[string]any) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.Discord, templateName)
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.Discord, n.locale, templateName)
 	if err != nil {
 		return fmt.Errorf("failed to get template: %w", err)
 	}
@@ -3,10 +3,11 @@ package notify
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net/http"
 	"net/smtp"
 	"strings"
+	"sync"
 	"time"
 	"wameter/internal/config"
 	ntpl "wameter/internal/notify/template"
@@ -19,11 +20,21 @@ import (
 type EmailNotifier struct {
 	config    *config.EmailConfig
 	logger    *zap.Logger
+	client    *http.Client // OAuth2 token requests
 	tplLoader *ntpl.Loader
+	locale    string // Set by the manager
+	pool      smtpPool
+	token     oauth2Token
+	batches   map[string]*emailBatch // Pending batches by recipients
+	batchMu   sync.Mutex
+	// Set by the manager, configLock is held while batches are sent and
+	// agentTags returns the tags of agents only known by ID
+	configLock sync.Locker
+	agentTags  func(agentID string) map[string]string
 }
 
 // NewEmailNotifier creates new Email notifier
-func NewEmailNotifier(cfg *config.EmailConfig, loader *ntpl.Loader, logger *zap.Logger) (*EmailNotifier, error) {
+func NewEmailNotifier(cfg *config.EmailConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*EmailNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("email notifier is disabled")
 	}
@@ -31,7 +42,9 @@ func NewEmailNotifier(cfg *config.EmailConfig, loader *ntpl.Loader, logger *zap.
 	return &EmailNotifier{
 		config:    cfg,
 		logger:    logger,
+		client:    client,
 		tplLoader: loader,
+		batches:   make(map[string]*emailBatch),
 	}, nil
 }
 
@@ -41,8 +54,49 @@ func (n *EmailNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 		"Agent":     agent,
 		"Timestamp": time.Now(),
 	}
-	subject := fmt.Sprintf("Agent Offline Alert - %s", agent.Hostname)
-	return n.sendTemplateEmail("agent_offline", data, subject)
+	subject := n.tplLoader.Message(n.locale, "agent_offline", agent.Hostname)
+	return n.sendTemplateEmail("agent_offline", data, subject, agent.Tags)
+}
+
+// NotifyAgentOnline sends agent recovery notification
+func (n *EmailNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	subject := n.tplLoader.Message(n.locale, "agent_online", agent.Hostname)
+	return n.sendTemplateEmail("agent_online", data, subject, agent.Tags)
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *EmailNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	subject := n.tplLoader.Message(n.locale, "agent_missing", alert.Subject())
+	return n.sendTemplateEmail("agent_missing", data, subject, alert.Tags)
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *EmailNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	content, err := n.render("rule_alert", data)
+	if err != nil {
+		return err
+	}
+	return n.route(&emailEvent{
+		Type:      "rule_alert",
+		Severity:  alert.Severity,
+		AgentTags: n.tagsOf(alert.AgentID),
+		Subject:   n.tplLoader.Message(n.locale, "rule_alert", alert.Rule, alert.AgentID),
+		Content:   content,
+		Timestamp: time.Now(),
+	})
 }
 
 // NotifyNetworkErrors sends network errors notification
@@ -52,8 +106,8 @@ func (n *EmailNotifier) NotifyNetworkErrors(agentID string, iface *types.Interfa
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	subject := fmt.Sprintf("Network Errors Alert - %s - %s", agentID, iface.Name)
-	return n.sendTemplateEmail("network_error", data, subject)
+	subject := n.tplLoader.Message(n.locale, "network_error", agentID, iface.Name)
+	return n.sendTemplateEmail("network_error", data, subject, n.tagsOf(agentID))
 }
 
 // NotifyHighNetworkUtilization sends high network utilization notification
@@ -63,86 +117,208 @@ func (n *EmailNotifier) NotifyHighNetworkUtilization(agentID string, iface *type
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	subject := fmt.Sprintf("High Network Utilization - %s - %s", agentID, iface.Name)
-	return n.sendTemplateEmail("high_utilization", data, subject)
+	subject := n.tplLoader.Message(n.locale, "high_utilization", agentID, iface.Name)
+	return n.sendTemplateEmail("high_utilization", data, subject, n.tagsOf(agentID))
 }
 
 // NotifyIPChange sends IP change notification
 func (n *EmailNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) error {
 	data := map[string]any{
-		"Agent":     agent,
-		"Change":    change,
+		"Agent":         agent,
+		"Change":        change,
+		"Timestamp":     time.Now(),
+		"Action":        change.Action,
+		"Reason":        change.Reason,
+		"IsExternal":    change.IsExternal,
+		"Version":       change.Version,
+		"OldAddrs":      change.OldAddrs,
+		"NewAddrs":      change.NewAddrs,
+		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
+	}
+	subject := n.tplLoader.Message(n.locale, "ip_change", agent.Hostname)
+	if change.Severity == "" {
+		return n.sendTemplateEmail("ip_change", data, subject, agent.Tags)
+	}
+
+	// Changes tracked by the server carry the severity of their expected window
+	content, err := n.render("ip_change", data)
+	if err != nil {
+		return err
+	}
+	return n.route(&emailEvent{
+		Type:      "ip_change",
+		Severity:  change.Severity,
+		AgentTags: agent.Tags,
+		Subject:   subject,
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
+// NotifyReport sends a summary report
+func (n *EmailNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
+	}
+	subject := n.tplLoader.Message(n.locale, "report", ntpl.Value(n.locale, report.Period), report.Name)
+	return n.sendTemplateEmail("report", data, subject, nil)
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *EmailNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
 		"Timestamp": time.Now(),
 	}
-	subject := fmt.Sprintf("IP Change Alert - %s", agent.Hostname)
-	return n.sendTemplateEmail("ip_change", data, subject)
+	subject := n.tplLoader.Message(n.locale, "heartbeat", hb.NodeID)
+	return n.sendTemplateEmail("heartbeat", data, subject, nil)
 }
 
-// sendTemplateEmail sends an email
-func (n *EmailNotifier) sendTemplateEmail(templateName string, data map[string]any, subject string) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.Email, templateName)
+// sendTemplateEmail renders an email and routes it to its recipients
+func (n *EmailNotifier) sendTemplateEmail(templateName string, data map[string]any, subject string, agentTags map[string]string) error {
+	content, err := n.render(templateName, data)
 	if err != nil {
-		return fmt.Errorf("failed to get template: %w", err)
+		return err
+	}
+
+	return n.route(&emailEvent{
+		Type:      templateName,
+		Severity:  notificationSeverities[templateName],
+		AgentTags: agentTags,
+		Subject:   subject,
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
+// render renders an email template
+func (n *EmailNotifier) render(templateName string, data map[string]any) (string, error) {
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.Email, n.locale, templateName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get template: %w", err)
 	}
 
 	var content bytes.Buffer
 	if err := tmpl.Execute(&content, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
-
-	return n.sendMail(subject, content.String())
+	return content.String(), nil
 }
 
-// sendMail sends an email
-func (n *EmailNotifier) sendMail(subject, content string) error {
-	auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.SMTPServer)
+// tagsOf returns the tags of an agent known only by ID
+func (n *EmailNotifier) tagsOf(agentID string) map[string]string {
+	if n.agentTags == nil {
+		return nil
+	}
+	return n.agentTags(agentID)
+}
 
-	msg := buildEmailMessage(n.config.From, n.config.To, subject, content)
+// sendMail sends an email, over an idle connection when one can be reused
+func (n *EmailNotifier) sendMail(to []string, subject, content string) error {
+	msg := buildEmailMessage(n.config.From, to, subject, content)
 
-	var err error
-	if n.config.UseTLS {
-		err = n.sendTLSEmail(auth, msg)
-	} else {
-		addr := fmt.Sprintf("%s:%d", n.config.SMTPServer, n.config.SMTPPort)
-		err = smtp.SendMail(addr, auth, n.config.From, n.config.To, msg)
+	// A reused connection may have been dropped by the server, so a failed
+	// send on one is retried once on a new connection
+	if c := n.pool.get(n.connKey(), n.config.IdleTimeout); c != nil {
+		err := n.send(c, to, msg)
+		if err == nil {
+			return nil
+		}
+		n.logger.Debug("Failed to send email on reused connection, reconnecting", zap.Error(err))
 	}
 
+	c, err := n.connect()
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
+	if err := n.send(c, to, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
 
 	return nil
 }
 
-// sendTLSEmail sends email with explicit connection handling
-func (n *EmailNotifier) sendTLSEmail(auth smtp.Auth, msg []byte) error {
-	addr := fmt.Sprintf("%s:%d", n.config.SMTPServer, n.config.SMTPPort)
-
-	tlsConfig := &tls.Config{
-		ServerName: n.config.SMTPServer,
-		MinVersion: tls.VersionTLS12,
+// connect opens and authenticates a connection
+func (n *EmailNotifier) connect() (*smtpConn, error) {
+	c, err := dialSMTP(n.config)
+	if err != nil {
+		return nil, err
 	}
+	c.key = n.connKey()
 
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
+	auth, err := n.auth()
 	if err != nil {
-		return fmt.Errorf("failed to create TLS connection: %w", err)
+		c.close(false)
+		return nil, err
+	}
+	if auth != nil {
+		if err := c.client.Auth(auth); err != nil {
+			if _, ok := auth.(*xoauth2Auth); ok {
+				n.token.invalidate()
+			}
+			c.close(false)
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
 	}
 
-	defer func(conn *tls.Conn) {
-		_ = conn.Close()
-	}(conn)
+	return c, nil
+}
 
-	client, err := smtp.NewClient(conn, n.config.SMTPServer)
-	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %w", err)
+// auth returns the configured authentication mechanism, nil for none
+func (n *EmailNotifier) auth() (smtp.Auth, error) {
+	cfg := n.config
+	switch cfg.Auth {
+	case config.EmailAuthNone:
+		return nil, nil
+	case "":
+		if cfg.Username == "" {
+			return nil, nil
+		}
+		return smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPServer), nil
+	case config.EmailAuthPlain:
+		return smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPServer), nil
+	case config.EmailAuthLogin:
+		return &loginAuth{username: cfg.Username, password: cfg.Password, host: cfg.SMTPServer}, nil
+	case config.EmailAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(cfg.Username, cfg.Password), nil
+	case config.EmailAuthXOAuth2:
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+		token, err := n.token.token(ctx, n.client, cfg.OAuth2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+		}
+		return &xoauth2Auth{username: cfg.Username, token: token, host: cfg.SMTPServer}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth mechanism: %s", cfg.Auth)
+	}
+}
+
+// send sends a message on a connection, which is then returned to the pool
+// or closed
+func (n *EmailNotifier) send(c *smtpConn, to []string, msg []byte) error {
+	if n.config.Timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(n.config.Timeout))
+	}
+
+	if err := n.transmit(c.client, to, msg); err != nil {
+		c.close(false)
+		return err
 	}
 
-	defer func(client *smtp.Client) {
-		_ = client.Close()
-	}(client)
+	if n.config.DisableKeepAlives {
+		c.close(true)
+	} else {
+		n.pool.put(c, n.config.MaxIdleConns)
+	}
+	return nil
+}
 
-	if err = client.Auth(auth); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+// transmit runs a mail transaction, resetting any previous one
+func (n *EmailNotifier) transmit(client *smtp.Client, to []string, msg []byte) error {
+	if err := client.Reset(); err != nil {
+		return fmt.Errorf("RSET failed: %w", err)
 	}
 
 	// Validate and clean the from address
@@ -153,14 +329,14 @@ func (n *EmailNotifier) sendTLSEmail(auth smtp.Auth, msg []byte) error {
 	from = cleanEmailAddress(from)
 
 	// Set sender
-	if err = client.Mail(from); err != nil {
+	if err := client.Mail(from); err != nil {
 		return fmt.Errorf("MAIL FROM failed for %s: %w", from, err)
 	}
 
 	// Add recipients
-	cleanTo := cleanEmailAddresses(n.config.To)
+	cleanTo := cleanEmailAddresses(to)
 	for _, addr := range cleanTo {
-		if err = client.Rcpt(addr); err != nil {
+		if err := client.Rcpt(addr); err != nil {
 			return fmt.Errorf("RCPT TO failed for %s: %w", addr, err)
 		}
 	}
@@ -178,7 +354,15 @@ func (n *EmailNotifier) sendTLSEmail(auth smtp.Auth, msg []byte) error {
 	if err = w.Close(); err != nil {
 		return fmt.Errorf("failed to close message writer: %w", err)
 	}
-	return client.Quit()
+	return nil
+}
+
+// connKey identifies the settings of pooled connections, so connections
+// are not reused after the server or credentials change
+func (n *EmailNotifier) connKey() string {
+	cfg := n.config
+	return strings.Join([]string{cfg.SMTPServer, fmt.Sprint(cfg.SMTPPort), cfg.Security,
+		cfg.Auth, cfg.Username, cfg.Password}, "\x00")
 }
 
 // buildEmailMessage builds email message
@@ -233,3 +417,10 @@ func (n *EmailNotifier) Health(_ context.Context) error {
 	// Note: Add health check logic here
 	return nil
 }
+
+// Close sends the pending batches and closes the idle connections
+func (n *EmailNotifier) Close() error {
+	n.flushAll()
+	n.pool.closeAll()
+	return nil
+}
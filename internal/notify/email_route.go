@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	ntpl "wameter/internal/notify/template"
+
+	"go.uber.org/zap"
+)
+
+// notificationSeverities are the severities of notification types matched
+// by email routes
+var notificationSeverities = map[string]string{
+	"agent_offline":    "critical",
+	"agent_missing":    "critical",
+	"network_error":    "warning",
+	"high_utilization": "warning",
+	"agent_online":     "info",
+	"rule_alert":       "warning", // Rules set their own severity
+	"ip_change":        "info",    // Unless changes set their own severity
+	"report":           "info",
+	"heartbeat":        "info",
+}
+
+// emailEvent represents a rendered notification to be routed
+type emailEvent struct {
+	Type      string
+	Severity  string
+	AgentTags map[string]string
+	Subject   string
+	Content   string
+	Timestamp time.Time
+}
+
+// emailBatch represents the events collected for a recipient group
+type emailBatch struct {
+	to     []string
+	events []*emailEvent
+	timer  *time.Timer
+}
+
+// route sends an event to its recipient groups, batched when enabled
+func (n *EmailNotifier) route(ev *emailEvent) error {
+	groups := n.recipients(ev)
+	if len(groups) == 0 {
+		n.logger.Debug("No email recipients for notification",
+			zap.String("type", ev.Type))
+		return nil
+	}
+
+	var errs []error
+	for _, to := range groups {
+		if n.config.Batch.Window <= 0 || ev.Type == "report" || ev.Type == "heartbeat" {
+			if err := n.sendMail(to, ev.Subject, ev.Content); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := n.enqueue(to, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recipients returns the recipient groups of an event, the configured
+// recipients receive events no route matches
+func (n *EmailNotifier) recipients(ev *emailEvent) [][]string {
+	var groups [][]string
+	for _, r := range n.config.Routes {
+		if len(r.Types) > 0 && !slices.Contains(r.Types, ev.Type) {
+			continue
+		}
+		if len(r.Severities) > 0 && !slices.Contains(r.Severities, ev.Severity) {
+			continue
+		}
+		if !matchAgentTags(r.AgentTags, ev.AgentTags) {
+			continue
+		}
+		groups = append(groups, r.To)
+	}
+
+	if len(groups) == 0 && len(n.config.To) > 0 {
+		groups = append(groups, n.config.To)
+	}
+	return groups
+}
+
+// matchAgentTags reports whether tags has the required tags, an empty
+// required value matches any value. Keys are compared case-insensitively
+// as config keys are lowercased.
+func matchAgentTags(required, tags map[string]string) bool {
+	for key, want := range required {
+		found := false
+		for k, v := range tags {
+			if strings.EqualFold(k, key) && (want == "" || v == want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueue adds an event to the batch of its recipients, the batch is sent
+// when its window ends or it is full
+func (n *EmailNotifier) enqueue(to []string, ev *emailEvent) error {
+	key := strings.Join(to, ",")
+
+	n.batchMu.Lock()
+	b, ok := n.batches[key]
+	if !ok {
+		b = &emailBatch{to: to}
+		b.timer = time.AfterFunc(n.config.Batch.Window, func() {
+			n.flushBatch(key)
+		})
+		n.batches[key] = b
+	}
+	b.events = append(b.events, ev)
+
+	if len(b.events) < n.config.Batch.MaxSize {
+		n.batchMu.Unlock()
+		return nil
+	}
+	b.timer.Stop()
+	delete(n.batches, key)
+	n.batchMu.Unlock()
+
+	return n.sendBatch(b)
+}
+
+// flushBatch sends the batch of a recipient group once its window ends
+func (n *EmailNotifier) flushBatch(key string) {
+	n.batchMu.Lock()
+	b, ok := n.batches[key]
+	delete(n.batches, key)
+	n.batchMu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Config updates must not run while the batch is sent
+	if n.configLock != nil {
+		n.configLock.Lock()
+		defer n.configLock.Unlock()
+	}
+	if err := n.sendBatch(b); err != nil {
+		n.logger.Error("Failed to send batched email",
+			zap.Int("notifications", len(b.events)),
+			zap.Error(err))
+	}
+}
+
+// flushAll sends all pending batches
+func (n *EmailNotifier) flushAll() {
+	n.batchMu.Lock()
+	keys := make([]string, 0, len(n.batches))
+	for key, b := range n.batches {
+		b.timer.Stop()
+		keys = append(keys, key)
+	}
+	n.batchMu.Unlock()
+
+	for _, key := range keys {
+		n.flushBatch(key)
+	}
+}
+
+// sendBatch sends the events of a batch, combined unless there is only one
+func (n *EmailNotifier) sendBatch(b *emailBatch) error {
+	if len(b.events) == 1 {
+		ev := b.events[0]
+		return n.sendMail(b.to, ev.Subject, ev.Content)
+	}
+
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.Email, n.locale, "batch")
+	if err != nil {
+		return fmt.Errorf("failed to get template: %w", err)
+	}
+
+	critical := 0
+	for _, ev := range b.events {
+		if ev.Severity == "critical" {
+			critical++
+		}
+	}
+	data := map[string]any{
+		"Events":    b.events,
+		"Count":     len(b.events),
+		"Critical":  critical,
+		"Timestamp": time.Now(),
+	}
+
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	subject := n.tplLoader.Message(n.locale, "batch", len(b.events))
+	if critical > 0 {
+		subject += n.tplLoader.Message(n.locale, "batch_critical", critical)
+	}
+	return n.sendMail(b.to, subject, content.String())
+}
@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"wameter/internal/config"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// execOutputLimit caps the command output kept for errors and logs
+const execOutputLimit = 4096
+
+// ExecNotifier represents a notifier running an external command, the
+// command gets the webhook payload on stdin and the event in its environment
+type ExecNotifier struct {
+	config *config.ExecConfig
+	logger *zap.Logger
+	path   string
+}
+
+// NewExecNotifier creates new exec notifier
+func NewExecNotifier(cfg *config.ExecConfig, logger *zap.Logger) (*ExecNotifier, error) {
+	path, err := exec.LookPath(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find command: %w", err)
+	}
+
+	return &ExecNotifier{
+		config: cfg,
+		logger: logger,
+		path:   path,
+	}, nil
+}
+
+// NotifyAgentOffline sends an agent offline notification
+func (n *ExecNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
+	return n.run(agentOfflinePayload(agent))
+}
+
+// NotifyAgentOnline sends an agent recovery notification
+func (n *ExecNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	return n.run(agentOnlinePayload(agent, downtime))
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *ExecNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	return n.run(agentMissingPayload(alert))
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *ExecNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	return n.run(ruleAlertPayload(alert))
+}
+
+// NotifyNetworkErrors sends a network errors notification
+func (n *ExecNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
+	return n.run(networkErrorsPayload(agentID, iface))
+}
+
+// NotifyHighNetworkUtilization sends a high network utilization notification
+func (n *ExecNotifier) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) error {
+	return n.run(highUtilizationPayload(agentID, iface))
+}
+
+// NotifyIPChange sends an IP change notification
+func (n *ExecNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) error {
+	return n.run(ipChangePayload(agent, change))
+}
+
+// NotifyReport sends a summary report
+func (n *ExecNotifier) NotifyReport(report *types.Report) error {
+	return n.run(reportPayload(report))
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *ExecNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	return n.run(heartbeatPayload(hb))
+}
+
+// run runs the command with the payload, failed runs are retried with backoff
+func (n *ExecNotifier) run(payload WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	attempts := n.config.MaxRetries + 1
+	for attempt := 1; ; attempt++ {
+		err = n.runOnce(payload, data)
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("failed to run command after %d attempts: %w", attempts, err)
+		}
+
+		n.logger.Warn("Notification command failed, retrying",
+			zap.String("event", payload.EventType),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+		time.Sleep(calculateBackoff(attempt))
+	}
+}
+
+// runOnce runs the command once, it fails on a non-zero exit status or when
+// the timeout expires
+func (n *ExecNotifier) runOnce(payload WebhookPayload, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.path, n.config.Args...)
+	cmd.Dir = n.config.Dir
+	cmd.Env = n.environ(payload)
+	cmd.Stdin = bytes.NewReader(data)
+	// Children left holding the output must not block the run
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr limitedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if out := strings.TrimSpace(stdout.String()); out != "" {
+		n.logger.Debug("Notification command output",
+			zap.String("event", payload.EventType),
+			zap.String("output", out))
+	}
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("command timed out after %s", n.config.Timeout)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// environ returns the command environment, the server environment with the
+// event metadata and the configured variables
+func (n *ExecNotifier) environ(payload WebhookPayload) []string {
+	env := append(os.Environ(),
+		"WAMETER_EVENT="+payload.EventType,
+		"WAMETER_EVENT_ID="+payload.EventID,
+		"WAMETER_TIMESTAMP="+payload.Timestamp.Format(time.RFC3339),
+		"WAMETER_AGENT_ID="+payload.AgentID,
+		"WAMETER_HOSTNAME="+payload.Hostname,
+		"WAMETER_VERSION="+version.GetInfo().Version,
+	)
+	// Config keys are lowercased when loaded, so names are uppercased back
+	for k, v := range n.config.Env {
+		env = append(env, strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
+
+// Health checks the health of the notifier
+func (n *ExecNotifier) Health(_ context.Context) error {
+	if _, err := os.Stat(n.path); err != nil {
+		return fmt.Errorf("command unavailable: %w", err)
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first execOutputLimit bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+// Write writes p up to the limit, the rest is discarded
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := execOutputLimit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
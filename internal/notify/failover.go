@@ -0,0 +1,272 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// failover tracks the consecutive failed deliveries of the channels with a
+// fallback. Notifications of a failed over channel are sent through its
+// fallback until a health check passes and the summary of the missed
+// notifications is delivered through the channel.
+type failover struct {
+	mu        sync.Mutex
+	config    config.NotifyFailoverConfig
+	fallbacks map[NotifierType]NotifierType // Of enabled channels with enabled fallbacks
+	channels  map[NotifierType]*channelState
+}
+
+// channelState represents the delivery state of a channel with a fallback
+type channelState struct {
+	failures int
+	since    time.Time      // When it failed over, zero while healthy
+	missed   map[string]int // Notifications sent through the fallback by event type
+	probing  bool           // A summary is queued
+}
+
+// failoverEvent is the event type of the notifications of failovers
+const failoverEvent = "failover"
+
+// newFailover creates new failover tracker of the enabled channels
+func newFailover(cfg config.NotifyFailoverConfig, enabled func(NotifierType) bool) *failover {
+	f := &failover{
+		config:    cfg,
+		fallbacks: make(map[NotifierType]NotifierType),
+		channels:  make(map[NotifierType]*channelState),
+	}
+	if !cfg.Enabled {
+		return f
+	}
+	for channel, fallback := range cfg.Fallbacks {
+		if enabled(NotifierType(channel)) && enabled(NotifierType(fallback)) {
+			f.fallbacks[NotifierType(channel)] = NotifierType(fallback)
+		}
+	}
+	return f
+}
+
+// fallback returns the fallback of a channel
+func (f *failover) fallback(t NotifierType) (NotifierType, bool) {
+	fallback, ok := f.fallbacks[t]
+	return fallback, ok
+}
+
+// redirect returns the fallback a notification of a failed over channel is
+// sent through and counts it as missed
+func (f *failover) redirect(t NotifierType, eventType string) (NotifierType, bool) {
+	fallback, ok := f.fallback(t)
+	if !ok {
+		return "", false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.channels[t]
+	if state == nil || state.since.IsZero() {
+		return "", false
+	}
+	state.missed[eventType]++
+	return fallback, true
+}
+
+// record counts a delivery of a channel. It reports whether the channel
+// failed over, once its consecutive failures reach the threshold, or
+// recovered with the delivery of the summary of its missed notifications.
+func (f *failover) record(t NotifierType, eventType string, err error, now time.Time) (failedOver, recovered bool) {
+	if _, ok := f.fallback(t); !ok {
+		return false, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.channels[t]
+	if state == nil {
+		state = &channelState{}
+		f.channels[t] = state
+	}
+
+	if err == nil {
+		if !state.since.IsZero() && eventType != failoverEvent {
+			// Direct deliveries such as tests keep it failed over until
+			// the summary is delivered
+			state.failures = 0
+			return false, false
+		}
+		recovered = !state.since.IsZero()
+		*state = channelState{}
+		return false, recovered
+	}
+
+	state.failures++
+	state.probing = false
+	if state.since.IsZero() && state.failures >= f.config.FailureThreshold() {
+		state.since = now
+		state.missed = make(map[string]int)
+		return true, false
+	}
+	return false, false
+}
+
+// probe returns the failed over channels whose summary is not queued yet,
+// and marks them so
+func (f *failover) probe() []NotifierType {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var channels []NotifierType
+	for t, state := range f.channels {
+		if !state.since.IsZero() && !state.probing {
+			state.probing = true
+			channels = append(channels, t)
+		}
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// unprobe clears the mark of a channel whose health check failed
+func (f *failover) unprobe(t NotifierType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if state := f.channels[t]; state != nil {
+		state.probing = false
+	}
+}
+
+// summary returns the alert summarizing the notifications a failed over
+// channel missed
+func (f *failover) summary(t NotifierType, now time.Time) *types.RuleAlert {
+	fallback, _ := f.fallback(t)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var since time.Time
+	var missed []string
+	total := 0
+	if state := f.channels[t]; state != nil {
+		since = state.since
+		for _, event := range slices.Sorted(maps.Keys(state.missed)) {
+			missed = append(missed, fmt.Sprintf("%d %s", state.missed[event], event))
+			total += state.missed[event]
+		}
+	}
+
+	message := fmt.Sprintf("%s recovered after failing since %s, %d notifications were sent through %s",
+		t, since.Format(time.RFC3339), total, fallback)
+	if len(missed) > 0 {
+		message += ": " + strings.Join(missed, ", ")
+	}
+	return &types.RuleAlert{
+		Rule:     "notifier_failover",
+		Severity: "info",
+		AgentID:  "server",
+		Message:  message,
+		Labels:   map[string]string{"channel": string(t), "fallback": string(fallback)},
+		Time:     now,
+	}
+}
+
+// failoverNotice returns the alert sent through the fallback of a channel
+// that failed over
+func failoverNotice(t, fallback NotifierType, failures int, now time.Time) *types.RuleAlert {
+	return &types.RuleAlert{
+		Rule:     "notifier_failover",
+		Severity: "warning",
+		AgentID:  "server",
+		Message: fmt.Sprintf("%s failed %d times in a row, notifications are sent through %s until it recovers",
+			t, failures, fallback),
+		Labels: map[string]string{"channel": string(t), "fallback": string(fallback)},
+		Time:   now,
+	}
+}
+
+// failOver sends the notice of a channel that failed over through its fallback
+func (m *Manager) failOver(t NotifierType) {
+	fallback, _ := m.failover.fallback(t)
+	failures := m.failover.config.FailureThreshold()
+	m.logger.Warn("Notifier failed over",
+		zap.String("type", string(t)),
+		zap.String("fallback", string(fallback)),
+		zap.Int("failures", failures))
+
+	// Queued from the sender, which cannot wait for the queue
+	notice := failoverNotice(t, fallback, failures, time.Now())
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		select {
+		case m.notifyChan <- notification{
+			notifierType: fallback,
+			eventType:    failoverEvent,
+			direct:       true,
+			notifyFunc: func(n Notifier) error {
+				return n.NotifyRuleAlert(notice)
+			},
+		}:
+		case <-m.ctx.Done():
+		}
+	}()
+}
+
+// checkFailover runs the health checks of the failed over channels until
+// the manager stops
+func (m *Manager) checkFailover() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.failover.config.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.probeFailedOver(m.ctx)
+		}
+	}
+}
+
+// probeFailedOver checks the health of the failed over channels, the summary
+// of the missed notifications is queued for those that pass. The channel
+// recovers once it is delivered.
+func (m *Manager) probeFailedOver(ctx context.Context) {
+	for _, t := range m.failover.probe() {
+		m.mu.RLock()
+		notifier, ok := m.notifiers[t]
+		m.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if err := notifier.Health(ctx); err != nil {
+			m.failover.unprobe(t)
+			m.logger.Debug("Failed over notifier still unhealthy",
+				zap.String("type", string(t)),
+				zap.Error(err))
+			continue
+		}
+
+		channel := t
+		select {
+		case m.notifyChan <- notification{
+			notifierType: channel,
+			eventType:    failoverEvent,
+			direct:       true,
+			notifyFunc: func(n Notifier) error {
+				return n.NotifyRuleAlert(m.failover.summary(channel, time.Now()))
+			},
+		}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,41 +1,115 @@
 package notify
 
 import (
+	"math"
 	"sync"
 	"time"
+	"wameter/internal/clock"
+	"wameter/internal/config"
 )
 
-// RateLimiter implements rate limiting for notifications
+// bucket represents the tokens left to a channel and event type, negative
+// while notifications wait for tokens reserved ahead
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter implements rate limiting for notifications with a token bucket
+// per channel and event type, or per event type shared by all channels. A
+// shared bucket is charged once per event, whatever the channels.
 type RateLimiter struct {
-	mu        sync.Mutex
-	events    map[NotifierType][]time.Time
-	interval  time.Duration
-	maxEvents int
+	mu      sync.Mutex
+	config  config.NotifyRateLimitConfig
+	buckets map[string]*bucket
+	clock   clock.Clock
 }
 
-// AllowNotification checks if a notification is allowed under rate limits
-func (r *RateLimiter) AllowNotification(notifierType NotifierType) bool {
+// NewRateLimiter creates new rate limiter
+func NewRateLimiter(cfg config.NotifyRateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:  cfg,
+		buckets: make(map[string]*bucket),
+		clock:   clock.Real,
+	}
+}
+
+// Reserve takes a token of the bucket of a notification. When the bucket is
+// empty and notifications are queued, it reserves the next token and returns
+// the wait until it, unless longer than the maximum delay. Otherwise the
+// notification is not allowed.
+func (r *RateLimiter) Reserve(notifierType NotifierType, eventType string) (bool, time.Duration) {
+	if !r.config.Enabled {
+		return true, 0
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := time.Now()
-	timestamps := r.events[notifierType]
+	key := eventType
+	if r.config.PerChannel {
+		key = string(notifierType) + "/" + eventType
+	}
+
+	now := r.clock.Now()
+	rate := float64(r.config.MaxEvents) / r.config.Interval.Seconds()
+	burst := float64(r.config.BurstSize())
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		r.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if !r.config.Queue {
+		return false, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	if wait > r.config.QueueDelay() {
+		return false, 0
+	}
+	b.tokens--
+	return true, wait
+}
 
-	// Clean expired timestamps
-	valid := make([]time.Time, 0)
-	for _, ts := range timestamps {
-		if now.Sub(ts) < r.interval {
-			valid = append(valid, ts)
-		}
+// reservation represents the token of an event taken once for all the
+// channels it is sent through
+type reservation struct {
+	allowed bool
+	until   time.Time // When the token is available
+}
+
+// reserveShared takes the token of an event for all its channels when they
+// share the buckets, it returns nil when each channel takes its own
+func (r *RateLimiter) reserveShared(eventType string) *reservation {
+	if r.config.PerChannel {
+		return nil
 	}
-	r.events[notifierType] = valid
+	allowed, wait := r.Reserve("", eventType)
 
-	// Check if limit exceeded
-	if len(valid) >= r.maxEvents {
-		return false
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &reservation{allowed: allowed, until: r.clock.Now().Add(wait)}
+}
+
+// reserveFor takes the token of a notification through a channel, unless
+// the event took it for all its channels
+func (r *RateLimiter) reserveFor(res *reservation, notifierType NotifierType, eventType string) (bool, time.Duration) {
+	if res == nil {
+		return r.Reserve(notifierType, eventType)
+	}
+	if !res.allowed {
+		return false, 0
 	}
 
-	// Add new timestamp
-	r.events[notifierType] = append(r.events[notifierType], now)
-	return true
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return true, max(res.until.Sub(r.clock.Now()), 0)
 }
@@ -2,9 +2,14 @@ package notify
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"slices"
 	"sync"
 	"time"
+	"wameter/internal/clock"
 	"wameter/internal/config"
 	"wameter/internal/notify/template"
 	"wameter/internal/types"
@@ -15,7 +20,12 @@ import (
 // notification represents a notification to be sent
 type notification struct {
 	notifierType NotifierType
+	eventType    string         // Rate limited separately, e.g. ip_change
+	targets      []NotifierType // Notifiers the notification is queued for
+	direct       bool           // Sent through its notifier even when failed over
 	notifyFunc   func(Notifier) error
+	result       chan<- *types.NotificationTestResult // Outcome of test notifications
+	reservation  *reservation                         // Token taken for all targets, nil when each takes its own
 }
 
 // Manager represents notifier manager
@@ -24,9 +34,15 @@ type Manager struct {
 	logger      *zap.Logger
 	notifiers   map[NotifierType]Notifier
 	mu          sync.RWMutex
+	configMu    sync.RWMutex // held by senders, locked to update notifier config in place
 	rateLimiter *RateLimiter
+	failover    *failover
 	tplLoader   *template.Loader
 	notifyChan  chan notification
+	stats       map[NotifierType]*types.NotificationStats
+	statsMu     sync.Mutex
+	agentTags   func(agentID string) map[string]string // Guarded by mu
+	tgActions   TelegramActions                        // Guarded by mu
 	wg          sync.WaitGroup
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -34,31 +50,37 @@ type Manager struct {
 
 // NewManager creates new notifier manager
 func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error) {
-	tplLoader, err := template.NewLoader(logger)
+	tplLoader, err := template.NewLoader(logger, cfg.Location())
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize template loader: %w", err)
 	}
 
+	// HTTP channels share the client of the http and proxy settings
+	client, err := cfg.HTTP.Client(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification HTTP client: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	m := &Manager{
-		config:    cfg,
-		logger:    logger,
-		notifiers: make(map[NotifierType]Notifier),
-		tplLoader: tplLoader,
-		rateLimiter: &RateLimiter{
-			events:    make(map[NotifierType][]time.Time),
-			interval:  cfg.RateLimit.Interval,
-			maxEvents: cfg.RateLimit.MaxEvents,
-		},
-		notifyChan: make(chan notification, 100),
-		ctx:        ctx,
-		cancel:     cancel,
+		config:      cfg,
+		logger:      logger,
+		notifiers:   make(map[NotifierType]Notifier),
+		tplLoader:   tplLoader,
+		rateLimiter: NewRateLimiter(cfg.RateLimit),
+		notifyChan:  make(chan notification, 100),
+		stats:       make(map[NotifierType]*types.NotificationStats),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 
 	// Initialize enabled notifiers
 	if cfg.Email.Enabled {
-		if n, err := NewEmailNotifier(&cfg.Email, m.tplLoader, logger); err == nil {
+		if n, err := NewEmailNotifier(&cfg.Email, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.Email.Locale)
+			n.configLock = m.configMu.RLocker()
+			n.agentTags = m.lookupAgentTags
 			m.notifiers[NotifierEmail] = n
 		} else {
 			logger.Error("Failed to initialize email notifier", zap.Error(err))
@@ -66,15 +88,19 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.Telegram.Enabled {
-		if n, err := NewTelegramNotifier(&cfg.Telegram, m.tplLoader, logger); err == nil {
+		if n, err := NewTelegramNotifier(&cfg.Telegram, client, m.tplLoader, logger); err == nil {
+			n.actions = m.lookupTelegramActions
+			n.configLock = m.configMu.RLocker()
 			m.notifiers[NotifierTelegram] = n
+			m.startTelegramUpdates(n)
 		} else {
 			logger.Error("Failed to initialize telegram notifier", zap.Error(err))
 		}
 	}
 
 	if cfg.Slack.Enabled {
-		if n, err := NewSlackNotifier(&cfg.Slack, m.tplLoader, logger); err == nil {
+		if n, err := NewSlackNotifier(&cfg.Slack, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.Slack.Locale)
 			m.notifiers[NotifierSlack] = n
 		} else {
 			logger.Error("Failed to initialize slack notifier", zap.Error(err))
@@ -82,7 +108,8 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.WeChat.Enabled {
-		if n, err := NewWeChatNotifier(&cfg.WeChat, m.tplLoader, logger); err == nil {
+		if n, err := NewWeChatNotifier(&cfg.WeChat, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.WeChat.Locale)
 			m.notifiers[NotifierWeChat] = n
 		} else {
 			logger.Error("Failed to initialize wechat notifier", zap.Error(err))
@@ -90,7 +117,8 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.DingTalk.Enabled {
-		if n, err := NewDingTalkNotifier(&cfg.DingTalk, m.tplLoader, logger); err == nil {
+		if n, err := NewDingTalkNotifier(&cfg.DingTalk, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.DingTalk.Locale)
 			m.notifiers[NotifierDingTalk] = n
 		} else {
 			logger.Error("Failed to initialize dingtalk notifier", zap.Error(err))
@@ -98,7 +126,8 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.Discord.Enabled {
-		if n, err := NewDiscordNotifier(&cfg.Discord, m.tplLoader, logger); err == nil {
+		if n, err := NewDiscordNotifier(&cfg.Discord, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.Discord.Locale)
 			m.notifiers[NotifierDiscord] = n
 		} else {
 			logger.Error("Failed to initialize discord notifier", zap.Error(err))
@@ -106,7 +135,7 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.Webhook.Enabled {
-		if n, err := NewWebhookNotifier(&cfg.Webhook, m.tplLoader, logger); err == nil {
+		if n, err := NewWebhookNotifier(&cfg.Webhook, client, m.tplLoader, logger); err == nil {
 			m.notifiers[NotifierWebhook] = n
 		} else {
 			logger.Error("Failed to initialize webhook notifier", zap.Error(err))
@@ -114,16 +143,35 @@ func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error)
 	}
 
 	if cfg.Feishu.Enabled {
-		if n, err := NewFeishuNotifier(&cfg.Feishu, m.tplLoader, logger); err == nil {
+		if n, err := NewFeishuNotifier(&cfg.Feishu, client, m.tplLoader, logger); err == nil {
+			n.locale = cfg.ChannelLocale(cfg.Feishu.Locale)
 			m.notifiers[NotifierFeishu] = n
 		} else {
 			logger.Error("Failed to initialize feishu notifier", zap.Error(err))
 		}
 	}
 
+	if cfg.Exec.Enabled {
+		if n, err := NewExecNotifier(&cfg.Exec, logger); err == nil {
+			m.notifiers[NotifierExec] = n
+		} else {
+			logger.Error("Failed to initialize exec notifier", zap.Error(err))
+		}
+	}
+
+	// Channels that keep failing fail over to their fallbacks
+	m.failover = newFailover(cfg.Failover, func(t NotifierType) bool {
+		_, ok := m.notifiers[t]
+		return ok
+	})
+
 	// Start notification processor
 	m.wg.Add(1)
 	go m.processNotifications()
+	if len(m.failover.fallbacks) > 0 {
+		m.wg.Add(1)
+		go m.checkFailover()
+	}
 
 	return m, nil
 }
@@ -135,93 +183,387 @@ func (m *Manager) processNotifications() {
 	for {
 		select {
 		case <-m.ctx.Done():
-			return
+			// Send the notifications queued before stopping
+			for {
+				select {
+				case n := <-m.notifyChan:
+					m.send(n)
+				default:
+					return
+				}
+			}
 		case n := <-m.notifyChan:
-			m.mu.RLock()
-			notifier, ok := m.notifiers[n.notifierType]
-			m.mu.RUnlock()
+			m.send(n)
+		}
+	}
+}
 
-			if !ok {
-				continue
+// send sends a notification under the rate limits, a notification queued
+// for a token is sent once it is available, or when the manager stops
+func (m *Manager) send(n notification) {
+	if !n.direct {
+		if fallback, ok := m.failover.redirect(n.notifierType, n.eventType); ok {
+			m.recordDelivery(n.notifierType, func(s *types.NotificationStats) { s.Redirected++ })
+			if slices.Contains(n.targets, fallback) {
+				// Sent through the fallback already
+				return
 			}
+			n.notifierType = fallback
+			n.direct = true
+		}
+	}
 
-			if !m.rateLimiter.AllowNotification(n.notifierType) {
-				m.logger.Warn("Rate limit exceeded for notifier",
-					zap.String("type", string(n.notifierType)))
-				continue
-			}
+	m.mu.RLock()
+	notifier, ok := m.notifiers[n.notifierType]
+	m.mu.RUnlock()
+	if !ok {
+		m.report(n, &types.NotificationTestResult{
+			Status: types.NotificationTestFailed,
+			Error:  types.ErrChannelNotEnabled.Error(),
+		})
+		return
+	}
 
-			if err := n.notifyFunc(notifier); err != nil {
-				m.logger.Error("Failed to send notification",
-					zap.String("type", string(n.notifierType)),
-					zap.Error(err))
+	allowed, wait := m.rateLimiter.reserveFor(n.reservation, n.notifierType, n.eventType)
+	if !allowed {
+		m.logger.Warn("Rate limit exceeded for notifier",
+			zap.String("type", string(n.notifierType)),
+			zap.String("event", n.eventType))
+		m.recordDelivery(n.notifierType, func(s *types.NotificationStats) {
+			s.RateLimited++
+			if s.Suppressed == nil {
+				s.Suppressed = make(map[string]int64)
 			}
+			s.Suppressed[n.eventType]++
+		})
+		m.report(n, &types.NotificationTestResult{Status: types.NotificationTestRateLimited})
+		if n.eventType == failoverEvent {
+			m.failover.unprobe(n.notifierType)
 		}
+		return
+	}
+	if wait == 0 {
+		m.deliver(n, notifier)
+		return
 	}
-}
 
-// NotifyAgentOffline sends an agent offline notification
-func (m *Manager) NotifyAgentOffline(agent *types.AgentInfo) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.logger.Debug("Notification delayed by rate limit",
+		zap.String("type", string(n.notifierType)),
+		zap.String("event", n.eventType),
+		zap.Duration("wait", wait))
+	m.recordDelivery(n.notifierType, func(s *types.NotificationStats) { s.Delayed++ })
 
-	for t := range m.notifiers {
-		notifyType := t // Capture for closure
-		m.notifyChan <- notification{
-			notifierType: notifyType,
-			notifyFunc: func(n Notifier) error {
-				return n.NotifyAgentOffline(agent)
-			},
+	m.rateLimiter.mu.Lock()
+	timer := m.rateLimiter.clock.NewTimer(wait)
+	m.rateLimiter.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-m.ctx.Done():
 		}
+		m.deliver(n, notifier)
+	}()
+}
+
+// deliver sends a notification through its notifier, config updates wait
+// for it to complete
+func (m *Manager) deliver(n notification, notifier Notifier) {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+
+	start := time.Now()
+	err := n.notifyFunc(notifier)
+	result := &types.NotificationTestResult{Duration: time.Since(start)}
+	if err != nil {
+		m.logger.Error("Failed to send notification",
+			zap.String("type", string(n.notifierType)),
+			zap.Error(err))
+		m.recordDelivery(n.notifierType, func(s *types.NotificationStats) { s.Failed++ })
+		result.Status = types.NotificationTestFailed
+		result.Error = err.Error()
+	} else {
+		m.recordDelivery(n.notifierType, func(s *types.NotificationStats) { s.Sent++ })
+		result.Status = types.NotificationTestSent
+	}
+	m.report(n, result)
+
+	switch failedOver, recovered := m.failover.record(n.notifierType, n.eventType, err, time.Now()); {
+	case failedOver:
+		m.failOver(n.notifierType)
+	case recovered:
+		m.logger.Info("Failed over notifier recovered", zap.String("type", string(n.notifierType)))
 	}
 }
 
-// NotifyNetworkErrors sends a network errors notification
-func (m *Manager) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) {
+// report returns the outcome of a test notification
+func (m *Manager) report(n notification, result *types.NotificationTestResult) {
+	if n.result != nil {
+		result.Channel = string(n.notifierType)
+		n.result <- result
+	}
+}
+
+// recordDelivery updates the delivery counts of a notifier
+func (m *Manager) recordDelivery(notifierType NotifierType, fn func(*types.NotificationStats)) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	stats, ok := m.stats[notifierType]
+	if !ok {
+		stats = &types.NotificationStats{}
+		m.stats[notifierType] = stats
+	}
+	fn(stats)
+}
+
+// Stats returns the delivery counts by notifier type
+func (m *Manager) Stats() map[NotifierType]types.NotificationStats {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	stats := make(map[NotifierType]types.NotificationStats, len(m.stats))
+	for t, s := range m.stats {
+		c := *s
+		c.Suppressed = maps.Clone(s.Suppressed)
+		stats[t] = c
+	}
+	return stats
+}
+
+// UpdateConfig runs update while no notification is being sent, notifiers
+// read their config on each send so in-place changes such as rotated
+// credentials take effect immediately
+func (m *Manager) UpdateConfig(update func()) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	update()
+}
+
+// SetAgentTags sets the lookup of agent tags, routing rules use it for
+// notifications of agents known only by ID
+func (m *Manager) SetAgentTags(fn func(agentID string) map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.agentTags = fn
+}
+
+// SetClock sets the clock of notification rate limits
+func (m *Manager) SetClock(c clock.Clock) {
+	m.rateLimiter.mu.Lock()
+	defer m.rateLimiter.mu.Unlock()
+	m.rateLimiter.clock = c
+}
+
+// lookupAgentTags returns the tags of an agent, nil without a lookup
+func (m *Manager) lookupAgentTags(agentID string) map[string]string {
+	m.mu.RLock()
+	fn := m.agentTags
+	m.mu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+	return fn(agentID)
+}
+
+// SetTelegramActions sets the actions of Telegram alert buttons
+func (m *Manager) SetTelegramActions(actions TelegramActions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tgActions = actions
+}
+
+// lookupTelegramActions returns the actions of Telegram alert buttons
+func (m *Manager) lookupTelegramActions() TelegramActions {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.tgActions
+}
 
-	for t := range m.notifiers {
-		notifyType := t // Capture for closure
-		m.notifyChan <- notification{
-			notifierType: notifyType,
-			notifyFunc: func(n Notifier) error {
-				return n.NotifyNetworkErrors(agentID, iface)
-			},
+// startTelegramUpdates starts receiving the button presses of Telegram
+// alerts, by long polling or by registering the webhook
+func (m *Manager) startTelegramUpdates(n *TelegramNotifier) {
+	buttons := n.config.Buttons
+	if !buttons.Enabled {
+		return
+	}
+
+	switch buttons.Updates {
+	case config.TelegramUpdatesWebhook:
+		if buttons.WebhookURL != "" {
+			go n.registerWebhook(m.ctx)
 		}
+	default:
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			n.poll(m.ctx)
+		}()
 	}
 }
 
-// NotifyHighNetworkUtilization sends a high network utilization notification
-func (m *Manager) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) {
+// HandleTelegramUpdate handles a Telegram update received by webhook
+func (m *Manager) HandleTelegramUpdate(ctx context.Context, secret string, body []byte) error {
+	m.mu.RLock()
+	n, ok := m.notifiers[NotifierTelegram].(*TelegramNotifier)
+	m.mu.RUnlock()
+	if !ok {
+		return types.ErrTelegramUpdates
+	}
+
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return n.HandleUpdate(ctx, secret, body)
+}
+
+// dispatch queues a notification for the given notifiers, or for all
+// enabled notifiers when none are given
+func (m *Manager) dispatch(eventType string, notifiers []NotifierType, notifyFunc func(Notifier) error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	targets := make([]NotifierType, 0, len(m.notifiers))
 	for t := range m.notifiers {
-		notifyType := t // Capture for closure
+		if len(notifiers) == 0 || slices.Contains(notifiers, t) {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	// Channels sharing the buckets take one token for the event
+	res := m.rateLimiter.reserveShared(eventType)
+	for _, t := range targets {
 		m.notifyChan <- notification{
-			notifierType: notifyType,
-			notifyFunc: func(n Notifier) error {
-				return n.NotifyHighNetworkUtilization(agentID, iface)
-			},
+			notifierType: t,
+			eventType:    eventType,
+			targets:      targets,
+			notifyFunc:   notifyFunc,
+			reservation:  res,
 		}
 	}
 }
 
+// NotifyAgentOffline sends an agent offline notification
+func (m *Manager) NotifyAgentOffline(agent *types.AgentInfo) {
+	m.dispatch("agent_offline", nil, func(n Notifier) error {
+		return n.NotifyAgentOffline(agent)
+	})
+}
+
+// NotifyAgentOnline sends an agent recovery notification
+func (m *Manager) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) {
+	m.dispatch("agent_online", nil, func(n Notifier) error {
+		return n.NotifyAgentOnline(agent, downtime)
+	})
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (m *Manager) NotifyAgentMissing(alert *types.MissingAgentAlert) {
+	m.dispatch("agent_missing", nil, func(n Notifier) error {
+		return n.NotifyAgentMissing(alert)
+	})
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (m *Manager) NotifyRuleAlert(alert *types.RuleAlert) {
+	m.dispatch("rule_alert", nil, func(n Notifier) error {
+		return n.NotifyRuleAlert(alert)
+	})
+}
+
+// NotifyNetworkErrors sends a network errors notification
+func (m *Manager) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) {
+	m.dispatch("network_error", nil, func(n Notifier) error {
+		return n.NotifyNetworkErrors(agentID, iface)
+	})
+}
+
+// NotifyHighNetworkUtilization sends a high network utilization notification
+func (m *Manager) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) {
+	m.dispatch("high_utilization", nil, func(n Notifier) error {
+		return n.NotifyHighNetworkUtilization(agentID, iface)
+	})
+}
+
 // NotifyIPChange sends an IP change notification
 func (m *Manager) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.dispatch("ip_change", nil, func(n Notifier) error {
+		return n.NotifyIPChange(agent, change)
+	})
+}
 
+// NotifyReport sends a summary report through the given notifiers, or
+// through all enabled notifiers when none are given
+func (m *Manager) NotifyReport(report *types.Report, notifiers ...NotifierType) {
+	m.dispatch("report", notifiers, func(n Notifier) error {
+		return n.NotifyReport(report)
+	})
+}
+
+// NotifyHeartbeat sends a liveness event of the server through the given
+// notifiers, or through all enabled notifiers when none are given
+func (m *Manager) NotifyHeartbeat(hb *types.Heartbeat, notifiers ...NotifierType) {
+	m.dispatch("heartbeat", notifiers, func(n Notifier) error {
+		return n.NotifyHeartbeat(hb)
+	})
+}
+
+// Test sends a test alert through the given notifiers, or through all
+// enabled notifiers when none are given, and waits for their deliveries.
+// Test alerts are queued and rate limited like other notifications,
+// deliveries not completed when ctx ends are reported as timed out.
+func (m *Manager) Test(ctx context.Context, alert *types.RuleAlert, notifiers ...NotifierType) []*types.NotificationTestResult {
+	m.mu.RLock()
+	var targets []NotifierType
 	for t := range m.notifiers {
-		notifyType := t
-		m.notifyChan <- notification{
-			notifierType: notifyType,
+		if len(notifiers) == 0 || slices.Contains(notifiers, t) {
+			targets = append(targets, t)
+		}
+	}
+	m.mu.RUnlock()
+	slices.Sort(targets)
+
+	var res *reservation
+	if len(targets) > 0 {
+		res = m.rateLimiter.reserveShared("test")
+	}
+
+	pending := make(map[NotifierType]chan *types.NotificationTestResult, len(targets))
+	for _, t := range targets {
+		ch := make(chan *types.NotificationTestResult, 1)
+		select {
+		case m.notifyChan <- notification{
+			notifierType: t,
+			eventType:    "test", // Not counted against alerts
+			direct:       true,
 			notifyFunc: func(n Notifier) error {
-				return n.NotifyIPChange(agent, change)
+				return n.NotifyRuleAlert(alert)
 			},
+			result:      ch,
+			reservation: res,
+		}:
+			pending[t] = ch
+		case <-ctx.Done():
+		}
+	}
+
+	results := make([]*types.NotificationTestResult, 0, len(targets))
+	for _, t := range targets {
+		result := &types.NotificationTestResult{Channel: string(t), Status: types.NotificationTestTimeout}
+		if ch, ok := pending[t]; ok {
+			select {
+			case result = <-ch:
+			case <-ctx.Done():
+			}
 		}
+		results = append(results, result)
 	}
+	return results
 }
 
 // Stop gracefully stops the notification manager
@@ -237,28 +579,45 @@ func (m *Manager) Stop() error {
 
 	select {
 	case <-done:
-		return nil
 	case <-time.After(30 * time.Second):
 		return fmt.Errorf("timeout waiting for notifications to complete")
 	}
+
+	// Release connections kept by notifiers, e.g. pooled SMTP connections
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, n := range m.notifiers {
+		if c, ok := n.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+	return nil
 }
 
-// Health checks the health of the notification manager
+// Health checks the health of the notification manager and its notifiers
 func (m *Manager) Health(ctx context.Context) error {
+	if m.ctx.Err() != nil {
+		return errors.New("notification manager stopped")
+	}
+
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	for t := range m.notifiers {
-		notifyType := t // Capture for closure
-		m.notifyChan <- notification{
-			notifierType: notifyType,
-			notifyFunc: func(n Notifier) error {
-				return n.Health(ctx)
-			},
+	var errs []error
+	for t, n := range m.notifiers {
+		if err := n.Health(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// QueueDepth returns the number of pending notifications and the queue capacity
+func (m *Manager) QueueDepth() (int, int) {
+	return len(m.notifyChan), cap(m.notifyChan)
 }
 
 // IsEnabled checks if a notifier is enabled
@@ -1,6 +1,14 @@
 package notify
 
 import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -8,7 +16,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	"wameter/internal/clock"
 	"wameter/internal/config"
+	"wameter/internal/notify/template"
 	"wameter/internal/types"
 )
 
@@ -247,3 +257,362 @@ func testWeChatWorkNotification(t *testing.T, manager *Manager) {
 func testWebhookNotification(t *testing.T, manager *Manager) {
 	testNotification(t, manager)
 }
+
+// TestLocalizedTemplates tests the templates of each locale and timezone
+func TestLocalizedTemplates(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	loader, err := template.NewLoader(zaptest.NewLogger(t), loc)
+	require.NoError(t, err)
+
+	agent := createTestAgent()
+	change := createTestIPChange()
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := map[string]any{
+		"Agent":         agent,
+		"Timestamp":     ts,
+		"Action":        change.Action,
+		"Reason":        "ipv4_changed",
+		"IsExternal":    change.IsExternal,
+		"Version":       change.Version,
+		"OldAddrs":      change.OldAddrs,
+		"NewAddrs":      change.NewAddrs,
+		"InterfaceName": change.InterfaceName,
+	}
+
+	testCases := []struct {
+		locale string
+		want   []string
+	}{
+		{locale: "en", want: []string{"Update - Ipv4 Changed", "2024-01-02T11:04:05"}},
+		{locale: "zh-CN", want: []string{"变更 - IPv4 地址变更", "2024-01-02T11:04:05"}},
+		{locale: "fr", want: []string{"Update - Ipv4 Changed"}}, // Falls back to en
+	}
+	for _, tc := range testCases {
+		for _, tplType := range []template.Type{
+			template.Email, template.Slack, template.WeChat, template.DingTalk, template.Discord, template.Feishu,
+		} {
+			t.Run(tc.locale+"/"+string(tplType), func(t *testing.T) {
+				tmpl, err := loader.GetTemplate(tplType, tc.locale, "ip_change")
+				require.NoError(t, err)
+
+				var content bytes.Buffer
+				require.NoError(t, tmpl.Execute(&content, data))
+				for _, want := range tc.want {
+					assert.Contains(t, content.String(), want)
+				}
+			})
+		}
+	}
+
+	assert.Equal(t, "IP 变更告警 - test-host", loader.Message("zh-CN", "ip_change", agent.Hostname))
+	assert.Equal(t, "IP Change Alert - test-host", loader.Message("", "ip_change", agent.Hostname))
+}
+
+// TestManagerTest tests that test notifications report the delivery of each
+// channel
+func TestManagerTest(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	m, err := NewManager(&config.NotifyConfig{
+		Enabled:   true,
+		RateLimit: config.NotifyRateLimitConfig{Enabled: true, Interval: time.Minute, MaxEvents: 2},
+		Webhook:   config.WebhookConfig{Enabled: true, URL: srv.URL, Timeout: time.Second, MaxRetries: 1},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = m.Stop() })
+
+	alert := &types.RuleAlert{Rule: "notification_test", Severity: "info", AgentID: "test", Time: time.Now()}
+	ctx := context.Background()
+
+	results := m.Test(ctx, alert)
+	require.Len(t, results, 1)
+	assert.Equal(t, string(NotifierWebhook), results[0].Channel)
+	assert.Equal(t, types.NotificationTestSent, results[0].Status)
+
+	status = http.StatusInternalServerError
+	results = m.Test(ctx, alert, NotifierWebhook)
+	require.Len(t, results, 1)
+	assert.Equal(t, types.NotificationTestFailed, results[0].Status)
+	assert.NotEmpty(t, results[0].Error)
+
+	results = m.Test(ctx, alert)
+	require.Len(t, results, 1)
+	assert.Equal(t, types.NotificationTestRateLimited, results[0].Status)
+
+	assert.Empty(t, m.Test(ctx, alert, NotifierSlack))
+}
+
+// TestRateLimiter tests that notifications are limited by channel and event
+// type, and queued for a token when configured
+func TestRateLimiter(t *testing.T) {
+	cfg := config.NotifyRateLimitConfig{Enabled: true, Interval: time.Minute, MaxEvents: 2}
+
+	testCases := []struct {
+		name  string
+		setup func(cfg *config.NotifyRateLimitConfig)
+		check func(t *testing.T, r *RateLimiter, fake *clock.Fake)
+	}{
+		{
+			name: "Shared by channels",
+			check: func(t *testing.T, r *RateLimiter, fake *clock.Fake) {
+				// An event takes one token for all its channels
+				res := r.reserveShared("ip_change")
+				for _, channel := range []NotifierType{NotifierSlack, NotifierEmail, NotifierWebhook} {
+					allowed, wait := r.reserveFor(res, channel, "ip_change")
+					assert.True(t, allowed)
+					assert.Zero(t, wait)
+				}
+				assert.True(t, r.reserveShared("ip_change").allowed)
+				res = r.reserveShared("ip_change")
+				allowed, _ := r.reserveFor(res, NotifierSlack, "ip_change")
+				assert.False(t, allowed)
+				// Other event types have buckets of their own
+				assert.True(t, r.reserveShared("agent_offline").allowed)
+
+				fake.Advance(30 * time.Second)
+				assert.True(t, r.reserveShared("ip_change").allowed)
+			},
+		},
+		{
+			name:  "Per channel",
+			setup: func(cfg *config.NotifyRateLimitConfig) { cfg.PerChannel = true },
+			check: func(t *testing.T, r *RateLimiter, fake *clock.Fake) {
+				assert.Nil(t, r.reserveShared("ip_change"))
+				assertReserve(t, r, NotifierSlack, "ip_change", true, 0)
+				assertReserve(t, r, NotifierSlack, "ip_change", true, 0)
+				assertReserve(t, r, NotifierSlack, "ip_change", false, 0)
+				assertReserve(t, r, NotifierEmail, "ip_change", true, 0)
+			},
+		},
+		{
+			name: "Queue",
+			setup: func(cfg *config.NotifyRateLimitConfig) {
+				cfg.Queue = true
+				cfg.MaxDelay = time.Minute
+			},
+			check: func(t *testing.T, r *RateLimiter, fake *clock.Fake) {
+				assertReserve(t, r, NotifierSlack, "ip_change", true, 0)
+				assertReserve(t, r, NotifierSlack, "ip_change", true, 0)
+				assertReserve(t, r, NotifierSlack, "ip_change", true, 30*time.Second)
+				assertReserve(t, r, NotifierSlack, "ip_change", true, time.Minute)
+				// Past the maximum delay
+				assertReserve(t, r, NotifierSlack, "ip_change", false, 0)
+			},
+		},
+		{
+			name:  "Disabled",
+			setup: func(cfg *config.NotifyRateLimitConfig) { cfg.Enabled = false },
+			check: func(t *testing.T, r *RateLimiter, fake *clock.Fake) {
+				for i := 0; i < 10; i++ {
+					assertReserve(t, r, NotifierSlack, "ip_change", true, 0)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := cfg
+			if tc.setup != nil {
+				tc.setup(&cfg)
+			}
+			fake := clock.NewFake(time.Now())
+			r := NewRateLimiter(cfg)
+			r.clock = fake
+			tc.check(t, r, fake)
+		})
+	}
+}
+
+// assertReserve asserts the outcome of a reservation
+func assertReserve(t *testing.T, r *RateLimiter, notifierType NotifierType, eventType string, allowed bool, wait time.Duration) {
+	t.Helper()
+	ok, w := r.Reserve(notifierType, eventType)
+	assert.Equal(t, allowed, ok)
+	assert.Equal(t, wait, w)
+}
+
+// TestSharedRateLimit tests that an event sent through several channels
+// sharing the buckets takes a single token
+func TestSharedRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	received := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	m, err := NewManager(&config.NotifyConfig{
+		Enabled:   true,
+		RateLimit: config.NotifyRateLimitConfig{Enabled: true, Interval: time.Minute, MaxEvents: 2},
+		Webhook:   config.WebhookConfig{Enabled: true, URL: srv.URL, Timeout: time.Second, MaxRetries: 1},
+		Exec: config.ExecConfig{
+			Enabled: true,
+			Command: "true",
+			Timeout: 5 * time.Second,
+		},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = m.Stop() })
+
+	agent := createTestAgent()
+	for i := 0; i < 3; i++ {
+		m.NotifyAgentOffline(agent)
+	}
+
+	require.Eventually(t, func() bool {
+		stats := m.Stats()
+		return stats[NotifierWebhook].Sent+stats[NotifierWebhook].RateLimited == 3 &&
+			stats[NotifierExec].Sent+stats[NotifierExec].RateLimited == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Both channels get the first two events and drop the third
+	for _, channel := range []NotifierType{NotifierWebhook, NotifierExec} {
+		stats := m.Stats()[channel]
+		assert.Equal(t, int64(2), stats.Sent, channel)
+		assert.Equal(t, int64(1), stats.RateLimited, channel)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, received)
+}
+
+// TestQueuedNotifications tests that notifications over the rate limit are
+// sent once a token is available when queued
+func TestQueuedNotifications(t *testing.T) {
+	var mu sync.Mutex
+	received := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	m, err := NewManager(&config.NotifyConfig{
+		Enabled:   true,
+		RateLimit: config.NotifyRateLimitConfig{Enabled: true, Interval: time.Minute, MaxEvents: 1, Queue: true},
+		Webhook:   config.WebhookConfig{Enabled: true, URL: srv.URL, Timeout: time.Second, MaxRetries: 1},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	fake := clock.NewFake(time.Now())
+	m.SetClock(fake)
+
+	agent := createTestAgent()
+	m.NotifyAgentOffline(agent)
+	m.NotifyAgentOffline(agent)
+
+	// The second waits for a token
+	fake.BlockUntil(1)
+	stats := m.Stats()[NotifierWebhook]
+	assert.Equal(t, int64(1), stats.Sent)
+	assert.Equal(t, int64(1), stats.Delayed)
+
+	fake.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return m.Stats()[NotifierWebhook].Sent == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The third waits for a token and is sent when the manager stops, the
+	// others would wait longer than the maximum delay of one interval
+	m.NotifyAgentOffline(agent)
+	m.NotifyAgentOffline(agent)
+	m.NotifyAgentOffline(agent)
+	require.NoError(t, m.Stop())
+
+	stats = m.Stats()[NotifierWebhook]
+	assert.Equal(t, int64(3), stats.Sent)
+	assert.Equal(t, int64(2), stats.RateLimited)
+	assert.Equal(t, map[string]int64{"agent_offline": 2}, stats.Suppressed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, received)
+}
+
+// TestFailover tests that notifications of a failing channel are sent
+// through its fallback and summarized once it recovers
+func TestFailover(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if status == http.StatusOK {
+			received = append(received, r.Header.Get("X-Wameter-Event"))
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	// The fallback appends the events it runs for to a file
+	events := filepath.Join(t.TempDir(), "events")
+	m, err := NewManager(&config.NotifyConfig{
+		Enabled: true,
+		Webhook: config.WebhookConfig{Enabled: true, URL: srv.URL, Timeout: time.Second, MaxRetries: 1},
+		Exec: config.ExecConfig{
+			Enabled: true,
+			Command: "sh",
+			Args:    []string{"-c", `echo "$WAMETER_EVENT" >> ` + events},
+			Timeout: 5 * time.Second,
+		},
+		Failover: config.NotifyFailoverConfig{
+			Enabled:   true,
+			Failures:  2,
+			Fallbacks: map[string]string{"webhook": "exec"},
+		},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	agent := createTestAgent()
+	m.NotifyAgentOffline(agent)
+	m.NotifyAgentOffline(agent)
+	require.Eventually(t, func() bool {
+		return m.Stats()[NotifierWebhook].Failed == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Sent through the fallback only when it does not receive them already
+	m.NotifyIPChange(agent, createTestIPChange())
+	m.NotifyReport(&types.Report{Name: "daily"}, NotifierWebhook)
+	require.Eventually(t, func() bool {
+		return m.Stats()[NotifierWebhook].Redirected == 2 && m.Stats()[NotifierExec].Sent == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), m.Stats()[NotifierWebhook].Failed)
+
+	// Still failing, the summary is not delivered
+	m.probeFailedOver(context.Background())
+	require.Eventually(t, func() bool {
+		return m.Stats()[NotifierWebhook].Failed == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	m.probeFailedOver(context.Background())
+	require.Eventually(t, func() bool {
+		return m.Stats()[NotifierWebhook].Sent == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	m.NotifyIPChange(agent, createTestIPChange())
+	require.NoError(t, m.Stop())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"alert.rule", "ip.change"}, received)
+
+	data, err := os.ReadFile(events)
+	require.NoError(t, err)
+	lines := strings.Fields(string(data))
+	assert.ElementsMatch(t, []string{
+		"agent.offline", "agent.offline", "alert.rule", "ip.change", "report.summary", "ip.change",
+	}, lines)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 	"wameter/internal/config"
 	ntpl "wameter/internal/notify/template"
@@ -15,32 +16,44 @@ import (
 	"go.uber.org/zap"
 )
 
+// slackAPIURL is the Web API method used with a bot token
+const slackAPIURL = "https://slack.com/api/chat.postMessage"
+
 // SlackNotifier represents Slack notifier
 type SlackNotifier struct {
 	config    *config.SlackConfig
 	logger    *zap.Logger
 	client    *http.Client
 	tplLoader *ntpl.Loader
+	locale    string                  // Set by the manager
+	threads   map[string]*slackThread // Alert threads by alert key
+	threadsMu sync.Mutex
 }
 
-// SlackMessage represents Slack message
+// SlackMessage represents Slack message, laid out with Block Kit blocks in
+// colored attachments and Text as the notification fallback
 type SlackMessage struct {
-	Channel     string            `json:"channel,omitempty"`
-	Username    string            `json:"username,omitempty"`
-	IconEmoji   string            `json:"icon_emoji,omitempty"`
-	IconURL     string            `json:"icon_url,omitempty"`
-	Text        string            `json:"text,omitempty"`
-	Attachments []SlackAttachment `json:"attachments,omitempty"`
+	Channel        string            `json:"channel,omitempty"`
+	Username       string            `json:"username,omitempty"`
+	IconEmoji      string            `json:"icon_emoji,omitempty"`
+	IconURL        string            `json:"icon_url,omitempty"`
+	Text           string            `json:"text,omitempty"`
+	Blocks         json.RawMessage   `json:"blocks,omitempty"`
+	Attachments    []SlackAttachment `json:"attachments,omitempty"`
+	ThreadTS       string            `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool              `json:"reply_broadcast,omitempty"`
 }
 
-// SlackAttachment represents Slack attachment
+// SlackAttachment represents Slack attachment, the legacy fields are kept
+// for custom templates
 type SlackAttachment struct {
-	Color     string       `json:"color"`
-	Title     string       `json:"title"`
-	Text      string       `json:"text"`
-	Fields    []SlackField `json:"fields,omitempty"`
-	Footer    string       `json:"footer"`
-	Timestamp int64        `json:"ts"`
+	Color     string          `json:"color,omitempty"`
+	Blocks    json.RawMessage `json:"blocks,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Text      string          `json:"text,omitempty"`
+	Fields    []SlackField    `json:"fields,omitempty"`
+	Footer    string          `json:"footer,omitempty"`
+	Timestamp json.Number     `json:"ts,omitempty"`
 }
 
 // SlackField represents Slack field
@@ -50,22 +63,31 @@ type SlackField struct {
 	Short bool   `json:"short"`
 }
 
+// slackAlertState is the state of an alert a message reports
+type slackAlertState int
+
+const (
+	slackEvent    slackAlertState = iota // Not part of an alert, never threaded
+	slackFiring                          // Alert firing, starts a thread
+	slackResolved                        // Alert resolved, replies in its thread
+)
+
+// slackThread represents the thread of an alert message
+type slackThread struct {
+	channel string
+	ts      string
+	firing  bool
+	updated time.Time
+}
+
 // NewSlackNotifier creates new SlackNotifier
-func NewSlackNotifier(cfg *config.SlackConfig, loader *ntpl.Loader, logger *zap.Logger) (*SlackNotifier, error) {
+func NewSlackNotifier(cfg *config.SlackConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*SlackNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("slack notifier is disabled")
 	}
 
-	if cfg.WebhookURL == "" {
-		return nil, fmt.Errorf("slack webhook URL is required")
-	}
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:    10,
-			IdleConnTimeout: 30 * time.Second,
-		},
+	if cfg.WebhookURL == "" && cfg.BotToken == "" {
+		return nil, fmt.Errorf("slack webhook URL or bot token is required")
 	}
 
 	return &SlackNotifier{
@@ -73,6 +95,7 @@ func NewSlackNotifier(cfg *config.SlackConfig, loader *ntpl.Loader, logger *zap.
 		logger:    logger,
 		client:    client,
 		tplLoader: loader,
+		threads:   make(map[string]*slackThread),
 	}, nil
 }
 
@@ -83,7 +106,37 @@ func (n *SlackNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 		"Agent":     agent,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("agent_offline", data)
+	return n.sendTemplate("agent_offline", data, "agent:"+agent.ID, slackFiring)
+}
+
+// NotifyAgentOnline sends agent recovery notification
+func (n *SlackNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	// Prepare data
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_online", data, "agent:"+agent.ID, slackResolved)
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *SlackNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_missing", data, "agent_missing:"+alert.Subject(), slackFiring)
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *SlackNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("rule_alert", data, "rule_alert:"+alert.Rule+"/"+alert.AgentID, slackFiring)
 }
 
 // NotifyNetworkErrors sends a network errors notification
@@ -94,7 +147,7 @@ func (n *SlackNotifier) NotifyNetworkErrors(agentID string, iface *types.Interfa
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("network_error", data)
+	return n.sendTemplate("network_error", data, "network_error:"+agentID+"/"+iface.Name, slackFiring)
 }
 
 // NotifyHighNetworkUtilization sends a high network utilization notification
@@ -105,7 +158,7 @@ func (n *SlackNotifier) NotifyHighNetworkUtilization(agentID string, iface *type
 		"Interface": iface,
 		"Timestamp": time.Now(),
 	}
-	return n.sendTemplate("high_utilization", data)
+	return n.sendTemplate("high_utilization", data, "high_utilization:"+agentID+"/"+iface.Name, slackFiring)
 }
 
 // NotifyIPChange sends IP change notification
@@ -114,18 +167,39 @@ func (n *SlackNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IPC
 		"Agent":         agent,
 		"Change":        change,
 		"Timestamp":     time.Now(),
+		"Action":        change.Action,
+		"Reason":        change.Reason,
 		"IsExternal":    change.IsExternal,
 		"Version":       change.Version,
 		"OldAddrs":      change.OldAddrs,
 		"NewAddrs":      change.NewAddrs,
 		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
+	}
+	return n.sendTemplate("ip_change", data, "", slackEvent)
+}
+
+// NotifyReport sends a summary report
+func (n *SlackNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
 	}
-	return n.sendTemplate("ip_change", data)
+	return n.sendTemplate("report", data, "", slackEvent)
 }
 
-// sendTemplate sends Slack message
-func (n *SlackNotifier) sendTemplate(templateName string, data map[string]any) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.Slack, templateName)
+// NotifyHeartbeat sends a liveness event of the server
+func (n *SlackNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("heartbeat", data, "", slackEvent)
+}
+
+// sendTemplate sends Slack message, follow-ups of the alert of key are
+// posted in its thread
+func (n *SlackNotifier) sendTemplate(templateName string, data map[string]any, key string, state slackAlertState) error {
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.Slack, n.locale, templateName)
 	if err != nil {
 		return fmt.Errorf("failed to get template: %w", err)
 	}
@@ -145,29 +219,83 @@ func (n *SlackNotifier) sendTemplate(templateName string, data map[string]any) e
 	msg.IconEmoji = n.config.IconEmoji
 	msg.IconURL = n.config.IconURL
 
-	return n.send(msg)
+	if !n.threaded() || state == slackEvent {
+		_, _, err := n.send(msg)
+		return err
+	}
+	return n.sendThreaded(msg, key, state)
+}
+
+// threaded reports whether alert follow-ups are posted in threads, which
+// needs the timestamps returned by chat.postMessage
+func (n *SlackNotifier) threaded() bool {
+	return n.config.BotToken != "" && !n.config.DisableThreads
+}
+
+// sendThreaded sends an alert message, as a reply when the alert has a
+// thread. Alerts firing again after resolving are broadcast to the channel.
+func (n *SlackNotifier) sendThreaded(msg SlackMessage, key string, state slackAlertState) error {
+	n.threadsMu.Lock()
+	defer n.threadsMu.Unlock()
+
+	now := time.Now()
+	for k, t := range n.threads {
+		if now.Sub(t.updated) > n.config.ThreadTTL {
+			delete(n.threads, k)
+		}
+	}
+
+	thread := n.threads[key]
+	if thread != nil {
+		msg.Channel = thread.channel
+		msg.ThreadTS = thread.ts
+		msg.ReplyBroadcast = state == slackFiring && !thread.firing
+	}
+
+	channel, ts, err := n.send(msg)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case thread != nil:
+		thread.firing = state == slackFiring
+		thread.updated = now
+	case state == slackFiring && ts != "":
+		n.threads[key] = &slackThread{channel: channel, ts: ts, firing: true, updated: now}
+	}
+	return nil
 }
 
-// send sends a slack message
-func (n *SlackNotifier) send(msg SlackMessage) error {
+// send sends a slack message, it returns the channel and timestamp of
+// messages posted with the bot token
+func (n *SlackNotifier) send(msg SlackMessage) (string, string, error) {
 	payload, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal slack message: %w", err)
+		return "", "", fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+
+	url := n.config.WebhookURL
+	if n.config.BotToken != "" {
+		url = slackAPIURL
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.WebhookURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payload))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if n.config.BotToken != "" {
+		req.Header.Set("Authorization", "Bearer "+n.config.BotToken)
+	}
 
 	resp, err := n.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", "", fmt.Errorf("failed to send request: %w", err)
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -177,10 +305,27 @@ func (n *SlackNotifier) send(msg SlackMessage) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("slack api error: status code %d", resp.StatusCode)
+		return "", "", fmt.Errorf("slack api error: status code %d", resp.StatusCode)
+	}
+	if n.config.BotToken == "" {
+		return "", "", nil
 	}
 
-	return nil
+	// The Web API reports errors in the body with status 200
+	var apiResp struct {
+		OK      bool   `json:"ok"`
+		Error   string `json:"error"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return "", "", fmt.Errorf("failed to decode slack response: %w", err)
+	}
+	if !apiResp.OK {
+		return "", "", fmt.Errorf("slack api error: %s", apiResp.Error)
+	}
+
+	return apiResp.Channel, apiResp.TS, nil
 }
 
 // Health checks the health of the notifier
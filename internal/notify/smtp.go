@@ -0,0 +1,290 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/config"
+)
+
+// tokenExpiryMargin is how long before expiry OAuth2 tokens are refreshed
+const tokenExpiryMargin = time.Minute
+
+// smtpConn represents an authenticated SMTP connection
+type smtpConn struct {
+	conn     net.Conn
+	client   *smtp.Client
+	key      string // Settings the connection was opened with
+	lastUsed time.Time
+}
+
+// close closes the connection, politely when it is still usable
+func (c *smtpConn) close(quit bool) {
+	if quit {
+		_ = c.client.Quit()
+	}
+	_ = c.client.Close()
+}
+
+// smtpPool keeps idle authenticated connections for reuse
+type smtpPool struct {
+	mu   sync.Mutex
+	idle []*smtpConn
+}
+
+// get returns an idle connection opened with key, stale connections are closed
+func (p *smtpPool) get(key string, idleTimeout time.Duration) *smtpConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if c.key == key && time.Since(c.lastUsed) < idleTimeout {
+			return c
+		}
+		go c.close(true)
+	}
+	return nil
+}
+
+// put returns a connection to the pool, it is closed when the pool is full
+func (p *smtpPool) put(c *smtpConn, maxIdle int) {
+	c.lastUsed = time.Now()
+
+	p.mu.Lock()
+	if len(p.idle) < maxIdle {
+		p.idle = append(p.idle, c)
+		c = nil
+	}
+	p.mu.Unlock()
+
+	if c != nil {
+		c.close(true)
+	}
+}
+
+// closeAll closes all idle connections
+func (p *smtpPool) closeAll() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.close(true)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks and some
+// servers such as Office 365 still prefer
+type loginAuth struct {
+	username, password, host string
+}
+
+// Start begins the LOGIN exchange
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := requireTLS(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the username and password prompts
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch prompt := strings.ToLower(strings.TrimSpace(string(fromServer))); {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN prompt: %s", fromServer)
+	}
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism of Gmail and Office 365
+type xoauth2Auth struct {
+	username, token, host string
+}
+
+// Start sends the bearer token
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := requireTLS(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next acknowledges the error challenge of a rejected token, the server then
+// fails the exchange with its error
+func (a *xoauth2Auth) Next(_ []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// requireTLS refuses to send credentials over an unencrypted connection,
+// except to localhost like smtp.PlainAuth
+func requireTLS(server *smtp.ServerInfo, host string) error {
+	if server.Name != host {
+		return errors.New("wrong host name")
+	}
+	if !server.TLS && host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		return errors.New("unencrypted connection")
+	}
+	return nil
+}
+
+// oauth2TokenResponse represents an OAuth2 token endpoint response
+type oauth2TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauth2Token caches the access token of the XOAUTH2 mechanism
+type oauth2Token struct {
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+	// Refresh token rotated by the provider, and the configured one it replaced
+	refreshToken string
+	rotatedFrom  string
+}
+
+// token returns a valid access token, fetching a new one when needed
+func (t *oauth2Token) token(ctx context.Context, client *http.Client, cfg *config.EmailOAuth2Config) (string, error) {
+	if cfg.TokenURL == "" {
+		return cfg.AccessToken, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.accessToken != "" && time.Now().Before(t.expiry) {
+		return t.accessToken, nil
+	}
+
+	form := url.Values{"client_id": {cfg.ClientID}}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.RefreshToken != "" {
+		refreshToken := cfg.RefreshToken
+		if t.refreshToken != "" && t.rotatedFrom == cfg.RefreshToken {
+			refreshToken = t.refreshToken
+		}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	var tokenResp oauth2TokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode token response (status %d): %w", resp.StatusCode, err)
+	}
+	if tokenResp.Error != "" {
+		return "", fmt.Errorf("token request failed: %s: %s", tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	t.accessToken = tokenResp.AccessToken
+	t.expiry = time.Now().Add(time.Hour - tokenExpiryMargin)
+	if tokenResp.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - tokenExpiryMargin)
+	}
+	if tokenResp.RefreshToken != "" && cfg.RefreshToken != "" {
+		t.refreshToken = tokenResp.RefreshToken
+		t.rotatedFrom = cfg.RefreshToken
+	}
+
+	return t.accessToken, nil
+}
+
+// invalidate drops the cached access token, e.g. after it was rejected
+func (t *oauth2Token) invalidate() {
+	t.mu.Lock()
+	t.accessToken = ""
+	t.mu.Unlock()
+}
+
+// dialSMTP opens a connection with the configured security, it is not
+// authenticated yet
+func dialSMTP(cfg *config.EmailConfig) (*smtpConn, error) {
+	addr := net.JoinHostPort(cfg.SMTPServer, fmt.Sprint(cfg.SMTPPort))
+	tlsConfig := &tls.Config{
+		ServerName: cfg.SMTPServer,
+		MinVersion: tls.VersionTLS12,
+	}
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if cfg.Security == config.EmailSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if cfg.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	}
+
+	client, err := smtp.NewClient(conn, cfg.SMTPServer)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+
+	if cfg.Security != config.EmailSecurityNone && cfg.Security != config.EmailSecurityTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				_ = client.Close()
+				return nil, fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+	}
+
+	return &smtpConn{conn: conn, client: client}, nil
+}
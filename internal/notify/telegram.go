@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 	"wameter/internal/config"
 	ntpl "wameter/internal/notify/template"
@@ -15,6 +16,8 @@ import (
 	"wameter/internal/utils"
 
 	"go.uber.org/zap"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // TelegramNotifier represents Telegram notifier
@@ -23,18 +26,24 @@ type TelegramNotifier struct {
 	logger    *zap.Logger
 	client    *http.Client
 	tplLoader *ntpl.Loader
+	// Set by the manager, actions performs button presses and configLock
+	// is held while they are handled
+	actions    func() TelegramActions
+	configLock sync.Locker
 }
 
 // TelegramMessage represents Telegram message
 type TelegramMessage struct {
-	ChatID              string `json:"chat_id"`
-	Text                string `json:"text"`
-	ParseMode           string `json:"parse_mode"`
-	DisableNotification bool   `json:"disable_notification,omitempty"`
+	ChatID              string            `json:"chat_id"`
+	Text                string            `json:"text"`
+	ParseMode           string            `json:"parse_mode,omitempty"`
+	DisableNotification bool              `json:"disable_notification,omitempty"`
+	ReplyToMessageID    int64             `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup         *telegramKeyboard `json:"reply_markup,omitempty"`
 }
 
 // NewTelegramNotifier creates new Telegram notifier
-func NewTelegramNotifier(cfg *config.TelegramConfig, loader *ntpl.Loader, logger *zap.Logger) (*TelegramNotifier, error) {
+func NewTelegramNotifier(cfg *config.TelegramConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*TelegramNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("telegram notifier is disabled")
 	}
@@ -43,17 +52,6 @@ func NewTelegramNotifier(cfg *config.TelegramConfig, loader *ntpl.Loader, logger
 		return nil, fmt.Errorf("telegram bot token and chat IDs are required")
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  true,
-			DisableKeepAlives:   false,
-			MaxIdleConnsPerHost: 5,
-		},
-	}
-
 	return &TelegramNotifier{
 		config:    cfg,
 		logger:    logger,
@@ -75,11 +73,74 @@ func (n *TelegramNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 			"_%s_",
 		agent.ID,
 		agent.Hostname,
-		agent.LastSeen.Format(time.RFC3339),
+		n.tplLoader.Time(agent.LastSeen, time.RFC3339),
+		agent.Status,
+		fmt.Sprintf("Alert generated at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
+
+	return n.sendToAll(message, n.alertButtons("agent_offline", agent.ID))
+}
+
+// NotifyAgentOnline sends agent recovery notification
+func (n *TelegramNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	message := fmt.Sprintf(
+		"✅ *Agent Recovered*\n\n"+
+			"Agent is back online.\n\n"+
+			"*Details:*\n"+
+			"• Agent ID: `%s`\n"+
+			"• Hostname: `%s`\n"+
+			"• Downtime: `%s`\n"+
+			"• Status: `%s`\n\n"+
+			"_%s_",
+		agent.ID,
+		agent.Hostname,
+		downtime.Round(time.Second),
 		agent.Status,
-		fmt.Sprintf("Alert generated at %s", time.Now().Format("2006-01-02 15:04:05")))
+		fmt.Sprintf("Recovery detected at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
+
+	return n.sendToAll(message, nil)
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *TelegramNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	message := fmt.Sprintf(
+		"❓ *Missing Agent Alert*\n\n"+
+			"%s.\n\n"+
+			"*Details:*\n"+
+			"• Expected: `%s`\n"+
+			"• State: `%s`\n"+
+			"• Since: `%s`\n\n"+
+			"_%s_",
+		alert.Message(),
+		alert.Subject(),
+		alert.State,
+		n.tplLoader.Time(alert.Since, time.RFC3339),
+		fmt.Sprintf("Alert generated at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
+
+	return n.sendToAll(message, n.alertButtons("agent_missing", alert.AgentID))
+}
 
-	return n.sendToAll(message)
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *TelegramNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	labels := ""
+	if len(alert.Labels) > 0 {
+		labels = fmt.Sprintf("• Labels: `%s`\n", alert.LabelList())
+	}
+	message := fmt.Sprintf(
+		"🔔 *Rule Alert* `%s`\n\n"+
+			"`%s`\n\n"+
+			"*Details:*\n"+
+			"• Agent ID: `%s`\n"+
+			"• Severity: `%s`\n"+
+			"%s\n"+
+			"_%s_",
+		alert.Rule,
+		alert.Message,
+		alert.AgentID,
+		alert.Severity,
+		labels,
+		fmt.Sprintf("Alert generated at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
+
+	return n.sendToAll(message, n.alertButtons("rule_alert", alert.AgentID))
 }
 
 // NotifyNetworkErrors sends network errors notification
@@ -104,9 +165,9 @@ func (n *TelegramNotifier) NotifyNetworkErrors(agentID string, iface *types.Inte
 		iface.Statistics.TxErrors,
 		iface.Statistics.RxDropped,
 		iface.Statistics.TxDropped,
-		fmt.Sprintf("Alert generated at %s", time.Now().Format("2006-01-02 15:04:05")))
+		fmt.Sprintf("Alert generated at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
 
-	return n.sendToAll(message)
+	return n.sendToAll(message, n.alertButtons("network_error", agentID))
 }
 
 // NotifyHighNetworkUtilization sends high network utilization notification
@@ -131,9 +192,9 @@ func (n *TelegramNotifier) NotifyHighNetworkUtilization(agentID string, iface *t
 		utils.FormatBytesRate(iface.Statistics.TxBytesRate),
 		utils.FormatBytes(iface.Statistics.RxBytes),
 		utils.FormatBytes(iface.Statistics.TxBytes),
-		fmt.Sprintf("Alert generated at %s", time.Now().Format("2006-01-02 15:04:05")))
+		fmt.Sprintf("Alert generated at %s", n.tplLoader.Time(time.Now(), "2006-01-02 15:04:05")))
 
-	return n.sendToAll(message)
+	return n.sendToAll(message, n.alertButtons("high_utilization", agentID))
 }
 
 // NotifyIPChange sends IP change notification
@@ -154,7 +215,7 @@ func (n *TelegramNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.
 			change.Version,
 			strings.Join(change.OldAddrs, ", "),
 			strings.Join(change.NewAddrs, ", "),
-			fmt.Sprintf("Changed at %s", change.Timestamp.Format("2006-01-02 15:04:05")))
+			fmt.Sprintf("Changed at %s", n.tplLoader.Time(change.Timestamp, "2006-01-02 15:04:05")))
 	} else {
 		description = fmt.Sprintf(
 			"🌐 *IP Change Detected*\n\n"+
@@ -172,14 +233,89 @@ func (n *TelegramNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.
 			change.Version,
 			strings.Join(change.OldAddrs, ", "),
 			strings.Join(change.NewAddrs, ", "),
-			fmt.Sprintf("Changed at %s", change.Timestamp.Format("2006-01-02 15:04:05")))
+			fmt.Sprintf("Changed at %s", n.tplLoader.Time(change.Timestamp, "2006-01-02 15:04:05")))
+	}
+
+	if ctx := change.Context; ctx != nil {
+		description += "\n\n*IP Context*\n"
+		if len(ctx.ReverseDNS) > 0 {
+			description += fmt.Sprintf("• Reverse DNS: `%s`\n", strings.Join(ctx.ReverseDNS, ", "))
+		}
+		if ctx.Org != "" {
+			description += fmt.Sprintf("• Organization: `%s`\n", ctx.Org)
+		}
+		if ctx.NetName != "" {
+			description += fmt.Sprintf("• Network: `%s`\n", ctx.NetName)
+		}
+		if ctx.ASN != "" {
+			description += fmt.Sprintf("• ASN: `%s`\n", ctx.ASN)
+		}
+		if ctx.Country != "" {
+			description += fmt.Sprintf("• Country: `%s`\n", ctx.Country)
+		}
+	}
+
+	return n.sendToAll(description, nil)
+}
+
+// NotifyReport sends a summary report
+func (n *TelegramNotifier) NotifyReport(report *types.Report) error {
+	message := fmt.Sprintf(
+		"📊 *%s Report - %s*\n\n"+
+			"`%s` to `%s`\n\n"+
+			"*Totals:*\n"+
+			"• Agents: `%d`\n"+
+			"• Availability: `%.2f%%`\n"+
+			"• Received: `%s`\n"+
+			"• Transmitted: `%s`\n"+
+			"• IP Changes: `%d`\n",
+		cases.Title(language.English).String(report.Period),
+		report.Name,
+		n.tplLoader.Time(report.StartTime, "2006-01-02 15:04:05"),
+		n.tplLoader.Time(report.EndTime, "2006-01-02 15:04:05"),
+		report.Totals.Agents,
+		report.Totals.Availability,
+		utils.FormatBytes(report.Totals.RxBytes),
+		utils.FormatBytes(report.Totals.TxBytes),
+		report.Totals.IPChanges)
+
+	if len(report.TopAlerts) > 0 {
+		message += "\n*Top Alerts:*\n"
+		for _, alert := range report.TopAlerts {
+			target := alert.Hostname
+			if alert.Interface != "" {
+				target += "/" + alert.Interface
+			}
+			message += fmt.Sprintf("• %s: `%s` x%d\n", alert.Type, target, alert.Count)
+		}
 	}
 
-	return n.sendToAll(description)
+	message += fmt.Sprintf("\n_Report generated at %s_", n.tplLoader.Time(report.GeneratedAt, "2006-01-02 15:04:05"))
+
+	return n.sendToAll(message, nil)
 }
 
-// sendToAll sends message to all chat IDs
-func (n *TelegramNotifier) sendToAll(text string) error {
+// NotifyHeartbeat sends a liveness event of the server
+func (n *TelegramNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	message := fmt.Sprintf(
+		"💓 *Heartbeat*\n\n"+
+			"Server `%s` is alive\n\n"+
+			"• Version: `%s`\n"+
+			"• Uptime: `%s`\n"+
+			"• Agents Online: `%d/%d`\n"+
+			"\n_Sent at %s_",
+		hb.NodeID,
+		hb.Version,
+		hb.Uptime.Round(time.Second),
+		hb.AgentsOnline,
+		hb.AgentsTotal,
+		n.tplLoader.Time(hb.Timestamp, "2006-01-02 15:04:05"))
+
+	return n.sendToAll(message, nil)
+}
+
+// sendToAll sends message to all chat IDs, with the buttons of markup
+func (n *TelegramNotifier) sendToAll(text string, markup *telegramKeyboard) error {
 	var errors []string
 
 	// Use proper format based on config
@@ -189,7 +325,13 @@ func (n *TelegramNotifier) sendToAll(text string) error {
 	}
 
 	for _, chatID := range n.config.ChatIDs {
-		if err := n.sendMessage(chatID, text, format); err != nil {
+		msg := &TelegramMessage{
+			ChatID:      chatID,
+			Text:        text,
+			ParseMode:   format,
+			ReplyMarkup: markup,
+		}
+		if err := n.sendMessage(msg); err != nil {
 			errors = append(errors, fmt.Sprintf("chat_id %s: %v", chatID, err))
 			n.logger.Error("Failed to send telegram message",
 				zap.Error(err),
@@ -204,14 +346,8 @@ func (n *TelegramNotifier) sendToAll(text string) error {
 	return nil
 }
 
-// sendMessage sends a message to a specific chat ID
-func (n *TelegramNotifier) sendMessage(chatID, text, format string) error {
-	msg := TelegramMessage{
-		ChatID:    chatID,
-		Text:      text,
-		ParseMode: format,
-	}
-
+// sendMessage sends a message to its chat
+func (n *TelegramNotifier) sendMessage(msg *TelegramMessage) error {
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
@@ -250,7 +386,7 @@ func (n *TelegramNotifier) sendMessage(chatID, text, format string) error {
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&rateLimitResp); err == nil {
 			time.Sleep(time.Duration(rateLimitResp.Parameters.RetryAfter) * time.Second)
-			return n.sendMessage(chatID, text, format) // Retry after waiting
+			return n.sendMessage(msg) // Retry after waiting
 		}
 		return fmt.Errorf("rate limit exceeded")
 	}
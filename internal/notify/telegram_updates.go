@@ -0,0 +1,394 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wameter/internal/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// Callback data of alert buttons, Telegram limits it to 64 bytes
+const (
+	telegramAckPrefix     = "ack:"     // ack:<alert>:<agent_id>
+	telegramSilencePrefix = "silence:" // silence:<agent_id>
+	telegramCallbackLimit = 64
+)
+
+// TelegramActions performs the actions of Telegram alert buttons
+type TelegramActions interface {
+	// AcknowledgeAlert records the acknowledgement of an alert
+	AcknowledgeAlert(ctx context.Context, ack *types.AlertAcknowledgement) error
+
+	// SilenceAgent suppresses the notifications of an agent for d
+	SilenceAgent(ctx context.Context, agentID string, d time.Duration, actor string) error
+}
+
+// telegramKeyboard represents an inline keyboard
+type telegramKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+// telegramButton represents an inline keyboard button, with callback data
+// or a link
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// telegramUpdate represents an update received by poll or webhook, only
+// button presses are handled
+type telegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+// telegramCallbackQuery represents a button press
+type telegramCallbackQuery struct {
+	ID      string       `json:"id"`
+	From    telegramUser `json:"from"`
+	Message *struct {
+		MessageID int64 `json:"message_id"`
+		Chat      struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+	Data string `json:"data"`
+}
+
+// telegramUser represents the user pressing a button
+type telegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// name returns the name of a user for logs and replies
+func (u *telegramUser) name() string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
+
+// alertButtons returns the buttons of an agent alert, nil when they are
+// disabled or the agent ID does not fit in the callback data
+func (n *TelegramNotifier) alertButtons(alert, agentID string) *telegramKeyboard {
+	buttons := n.config.Buttons
+	if !buttons.Enabled || agentID == "" {
+		return nil
+	}
+
+	ack := telegramAckPrefix + alert + ":" + agentID
+	silence := telegramSilencePrefix + agentID
+	if len(ack) > telegramCallbackLimit {
+		n.logger.Debug("Agent ID too long for telegram buttons", zap.String("agent_id", agentID))
+		return nil
+	}
+
+	row := []telegramButton{
+		{Text: "✅ Acknowledge", CallbackData: ack},
+		{Text: "🔕 Silence " + formatSilence(buttons.SilenceFor), CallbackData: silence},
+	}
+	if view := n.viewButton(agentID); view != nil {
+		row = append(row, *view)
+	}
+	return &telegramKeyboard{InlineKeyboard: [][]telegramButton{row}}
+}
+
+// viewButton returns the View link of an agent, nil without a view URL
+func (n *TelegramNotifier) viewButton(agentID string) *telegramButton {
+	if n.config.Buttons.ViewURL == "" {
+		return nil
+	}
+	return &telegramButton{
+		Text: "🔎 View",
+		URL:  strings.ReplaceAll(n.config.Buttons.ViewURL, "{agent_id}", agentID),
+	}
+}
+
+// formatSilence formats the silence duration of the button label, e.g. 1h
+func formatSilence(d time.Duration) string {
+	if d <= 0 {
+		d = time.Hour
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// HandleUpdate handles an update received by webhook, secret is the secret
+// token header of the request
+func (n *TelegramNotifier) HandleUpdate(ctx context.Context, secret string, body []byte) error {
+	buttons := n.config.Buttons
+	if !buttons.Enabled || buttons.Updates != config.TelegramUpdatesWebhook {
+		return types.ErrTelegramUpdates
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(buttons.WebhookSecret)) != 1 {
+		return types.ErrForbidden
+	}
+
+	var update telegramUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return fmt.Errorf("failed to decode update: %w", err)
+	}
+	n.handleUpdate(ctx, &update)
+	return nil
+}
+
+// poll receives updates by long polling until ctx is done
+func (n *TelegramNotifier) poll(ctx context.Context) {
+	// Updates are not delivered by getUpdates while a webhook is set
+	if err := n.call(ctx, "deleteWebhook", map[string]any{}, nil); err != nil {
+		n.logger.Warn("Failed to delete telegram webhook", zap.Error(err))
+	}
+
+	// The long poll must return before the HTTP client times out
+	timeout := 25 * time.Second
+	if n.client.Timeout > 0 && n.client.Timeout-5*time.Second < timeout {
+		timeout = max(n.client.Timeout-5*time.Second, 0)
+	}
+
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []telegramUpdate
+		err := n.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(timeout.Seconds()),
+			"allowed_updates": []string{"callback_query"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			n.logger.Warn("Failed to get telegram updates", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for i := range updates {
+			offset = updates[i].UpdateID + 1
+			n.lockConfig(func() { n.handleUpdate(ctx, &updates[i]) })
+		}
+	}
+}
+
+// registerWebhook registers the webhook URL and secret with Telegram
+func (n *TelegramNotifier) registerWebhook(ctx context.Context) {
+	buttons := n.config.Buttons
+	err := n.call(ctx, "setWebhook", map[string]any{
+		"url":             buttons.WebhookURL,
+		"secret_token":    buttons.WebhookSecret,
+		"allowed_updates": []string{"callback_query"},
+	}, nil)
+	if err != nil {
+		n.logger.Error("Failed to register telegram webhook", zap.Error(err))
+		return
+	}
+	n.logger.Info("Telegram webhook registered", zap.String("url", buttons.WebhookURL))
+}
+
+// handleUpdate performs the action of a button press and answers it
+func (n *TelegramNotifier) handleUpdate(ctx context.Context, update *telegramUpdate) {
+	q := update.CallbackQuery
+	if q == nil || q.Message == nil {
+		return
+	}
+
+	answer, err := n.performAction(ctx, q)
+	if err != nil {
+		n.logger.Warn("Failed to handle telegram button",
+			zap.Error(err),
+			zap.String("data", q.Data),
+			zap.String("user", q.From.name()))
+		answer = "Failed: " + err.Error()
+	}
+
+	if err := n.call(ctx, "answerCallbackQuery", map[string]any{
+		"callback_query_id": q.ID,
+		"text":              answer,
+	}, nil); err != nil {
+		n.logger.Warn("Failed to answer telegram callback", zap.Error(err))
+	}
+}
+
+// performAction performs a button press, it returns the answer shown to the user
+func (n *TelegramNotifier) performAction(ctx context.Context, q *telegramCallbackQuery) (string, error) {
+	if !n.allowedChat(q) {
+		return "", errors.New("chat is not allowed")
+	}
+	if !n.allowedUser(&q.From) {
+		return "", errors.New("user is not allowed")
+	}
+
+	var actions TelegramActions
+	if n.actions != nil {
+		actions = n.actions()
+	}
+	if actions == nil {
+		return "", errors.New("actions are not available")
+	}
+
+	var reply, answer, agentID string
+	switch {
+	case strings.HasPrefix(q.Data, telegramAckPrefix):
+		alert, id, ok := strings.Cut(strings.TrimPrefix(q.Data, telegramAckPrefix), ":")
+		if !ok {
+			return "", fmt.Errorf("invalid callback data: %s", q.Data)
+		}
+		agentID = id
+		err := actions.AcknowledgeAlert(ctx, &types.AlertAcknowledgement{
+			AgentID: agentID,
+			Alert:   alert,
+			Summary: q.Message.Text,
+			Actor:   "telegram:" + q.From.name(),
+			Time:    time.Now(),
+		})
+		if err != nil {
+			return "", err
+		}
+		answer = "Acknowledged"
+		reply = fmt.Sprintf("✅ Acknowledged by %s", q.From.name())
+
+	case strings.HasPrefix(q.Data, telegramSilencePrefix):
+		agentID = strings.TrimPrefix(q.Data, telegramSilencePrefix)
+		d := n.config.Buttons.SilenceFor
+		if d <= 0 {
+			d = time.Hour
+		}
+		if err := actions.SilenceAgent(ctx, agentID, d, "telegram:"+q.From.name()); err != nil {
+			return "", err
+		}
+		answer = "Silenced for " + formatSilence(d)
+		reply = fmt.Sprintf("🔕 %s silenced for %s by %s", agentID, formatSilence(d), q.From.name())
+
+	default:
+		return "", fmt.Errorf("unknown button: %s", q.Data)
+	}
+
+	n.logger.Info("Telegram button handled",
+		zap.String("data", q.Data),
+		zap.String("user", q.From.name()))
+
+	// Replace the buttons with View so an alert is acted on once, and
+	// tell the chat who acted
+	chatID := strconv.FormatInt(q.Message.Chat.ID, 10)
+	markup := &telegramKeyboard{InlineKeyboard: [][]telegramButton{}}
+	if view := n.viewButton(agentID); view != nil {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []telegramButton{*view})
+	}
+	if err := n.call(ctx, "editMessageReplyMarkup", map[string]any{
+		"chat_id":      chatID,
+		"message_id":   q.Message.MessageID,
+		"reply_markup": markup,
+	}, nil); err != nil {
+		n.logger.Warn("Failed to update telegram buttons", zap.Error(err))
+	}
+	if err := n.sendMessage(&TelegramMessage{
+		ChatID:              chatID,
+		Text:                reply,
+		DisableNotification: true,
+		ReplyToMessageID:    q.Message.MessageID,
+	}); err != nil {
+		n.logger.Warn("Failed to send telegram reply", zap.Error(err))
+	}
+
+	return answer, nil
+}
+
+// allowedChat reports whether a button was pressed in a configured chat
+func (n *TelegramNotifier) allowedChat(q *telegramCallbackQuery) bool {
+	chat := q.Message.Chat
+	for _, id := range n.config.ChatIDs {
+		if id == strconv.FormatInt(chat.ID, 10) ||
+			(chat.Username != "" && strings.EqualFold(strings.TrimPrefix(id, "@"), chat.Username)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedUser reports whether a user may press buttons, any user may when
+// no users are configured
+func (n *TelegramNotifier) allowedUser(u *telegramUser) bool {
+	allowed := n.config.Buttons.AllowedUsers
+	if len(allowed) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(allowed, func(a string) bool {
+		a = strings.TrimPrefix(a, "@")
+		return a == strconv.FormatInt(u.ID, 10) || (u.Username != "" && strings.EqualFold(a, u.Username))
+	})
+}
+
+// lockConfig runs fn while config updates are held off
+func (n *TelegramNotifier) lockConfig(fn func()) {
+	if n.configLock != nil {
+		n.configLock.Lock()
+		defer n.configLock.Unlock()
+	}
+	fn()
+}
+
+// call calls a Bot API method, decoding its result into result when set
+func (n *TelegramNotifier) call(ctx context.Context, method string, params any, result any) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s params: %w", method, err)
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", n.config.BotToken, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	var apiResp struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("failed to decode %s response: status %d", method, resp.StatusCode)
+	}
+	if !apiResp.OK {
+		return fmt.Errorf("telegram API error: %s", apiResp.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(apiResp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
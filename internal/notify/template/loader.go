@@ -4,7 +4,8 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
-	"path/filepath"
+	"maps"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -12,11 +13,17 @@ import (
 
 	"go.uber.org/zap"
 	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
-//go:embed email/* slack/* wechat/* dingtalk/* discord/* feishu/*
+//go:embed email slack wechat dingtalk discord feishu
 var templateFS embed.FS
 
+// DefaultLocale is the locale of the templates at the top of each type
+// directory, the templates of other locales are in subdirectories named
+// after them and fall back to the default ones
+const DefaultLocale = "en"
+
 // Type represents the type of notification template
 type Type string
 
@@ -32,16 +39,21 @@ const (
 // Loader manages notification templates
 type Loader struct {
 	logger     *zap.Logger
-	templates  map[Type]*template.Template
+	location   *time.Location
+	templates  map[Type]map[string]*template.Template // By type and locale
 	customTpls map[Type]map[string]string
 	mu         sync.RWMutex
 }
 
-// NewLoader creates new template loader
-func NewLoader(logger *zap.Logger) (*Loader, error) {
+// NewLoader creates new template loader, times are formatted in loc
+func NewLoader(logger *zap.Logger, loc *time.Location) (*Loader, error) {
+	if loc == nil {
+		loc = time.Local
+	}
 	loader := &Loader{
 		logger:     logger,
-		templates:  make(map[Type]*template.Template),
+		location:   loc,
+		templates:  make(map[Type]map[string]*template.Template),
 		customTpls: make(map[Type]map[string]string),
 	}
 
@@ -52,6 +64,11 @@ func NewLoader(logger *zap.Logger) (*Loader, error) {
 	return loader, nil
 }
 
+// Location returns the timezone times are formatted in
+func (t *Loader) Location() *time.Location {
+	return t.location
+}
+
 // loadDefaultTemplates loads templates from embedded filesystem
 func (t *Loader) loadDefaultTemplates() error {
 	for _, tplType := range []Type{
@@ -62,37 +79,59 @@ func (t *Loader) loadDefaultTemplates() error {
 		Discord,
 		Feishu,
 	} {
-		pattern := string(tplType)
-		tmpl := template.New("").Funcs(templateFuncs)
+		t.templates[tplType] = make(map[string]*template.Template)
 
-		entries, err := templateFS.ReadDir(pattern)
+		dir := string(tplType)
+		entries, err := templateFS.ReadDir(dir)
 		if err != nil {
 			return fmt.Errorf("failed to read template directory: %w", err)
 		}
 
+		if err := t.loadLocale(tplType, DefaultLocale, dir); err != nil {
+			return err
+		}
 		for _, entry := range entries {
 			if entry.IsDir() {
-				continue
+				if err := t.loadLocale(tplType, entry.Name(), path.Join(dir, entry.Name())); err != nil {
+					return err
+				}
 			}
+		}
+	}
 
-			content, err := templateFS.ReadFile(filepath.Join(pattern, entry.Name()))
-			if err != nil {
-				return fmt.Errorf("failed to read template file %s: %w", entry.Name(), err)
-			}
+	return nil
+}
 
-			name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
-			if _, err := tmpl.New(name).Parse(string(content)); err != nil {
-				return fmt.Errorf("failed to parse template %s: %w", entry.Name(), err)
-			}
+// loadLocale parses the templates of a type and locale in dir
+func (t *Loader) loadLocale(tplType Type, locale, dir string) error {
+	entries, err := templateFS.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read template directory: %w", err)
+	}
+
+	tmpl := template.New("").Funcs(t.funcs(locale))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
 		}
 
-		t.templates[tplType] = tmpl
+		content, err := templateFS.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read template file %s: %w", entry.Name(), err)
+		}
+
+		name := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		if _, err := tmpl.New(name).Parse(string(content)); err != nil {
+			return fmt.Errorf("failed to parse template %s/%s: %w", locale, entry.Name(), err)
+		}
 	}
 
+	t.templates[tplType][locale] = tmpl
 	return nil
 }
 
-// SetCustomTemplate sets a custom template for a notification type
+// SetCustomTemplate sets a custom template for a notification type, it is
+// used for every locale
 func (t *Loader) SetCustomTemplate(tplType Type, name, content string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -101,7 +140,7 @@ func (t *Loader) SetCustomTemplate(tplType Type, name, content string) error {
 		t.customTpls[tplType] = make(map[string]string)
 	}
 
-	tmpl := template.New(name).Funcs(templateFuncs)
+	tmpl := template.New(name).Funcs(t.funcs(DefaultLocale))
 	if _, err := tmpl.Parse(content); err != nil {
 		return fmt.Errorf("invalid template: %w", err)
 	}
@@ -110,14 +149,15 @@ func (t *Loader) SetCustomTemplate(tplType Type, name, content string) error {
 	return nil
 }
 
-// GetTemplate returns the template for given type and name
-func (t *Loader) GetTemplate(tplType Type, name string) (*template.Template, error) {
+// GetTemplate returns the template for given type, locale and name, the
+// template of the default locale when the locale has none
+func (t *Loader) GetTemplate(tplType Type, locale, name string) (*template.Template, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
 	// Check custom templates first
 	if customContent, ok := t.customTpls[tplType][name]; ok {
-		tmpl := template.New(name).Funcs(templateFuncs)
+		tmpl := template.New(name).Funcs(t.funcs(locale))
 		if _, err := tmpl.Parse(customContent); err != nil {
 			return nil, err
 		}
@@ -125,13 +165,37 @@ func (t *Loader) GetTemplate(tplType Type, name string) (*template.Template, err
 	}
 
 	// Fall back to default template
-	if tmpl, ok := t.templates[tplType]; ok {
-		if t := tmpl.Lookup(name); t != nil {
-			return t, nil
+	for _, l := range []string{locale, DefaultLocale} {
+		if tmpl, ok := t.templates[tplType][l]; ok {
+			if t := tmpl.Lookup(name); t != nil {
+				return t, nil
+			}
 		}
 	}
 
-	return nil, fmt.Errorf("template not found: %s/%s", tplType, name)
+	return nil, fmt.Errorf("template not found: %s/%s/%s", tplType, locale, name)
+}
+
+// Message returns a message of the catalog in a locale, formatted with args
+func (t *Loader) Message(locale, key string, args ...any) string {
+	return fmt.Sprintf(lookupMessage(locale, key), args...)
+}
+
+// Time formats a time in the timezone of the loader
+func (t *Loader) Time(v time.Time, layout string) string {
+	return v.In(t.location).Format(layout)
+}
+
+// funcs returns the template functions of a locale
+func (t *Loader) funcs(locale string) template.FuncMap {
+	funcs := maps.Clone(templateFuncs)
+	funcs["formatTime"] = func(v time.Time) string {
+		return t.Time(v, time.RFC3339)
+	}
+	funcs["tr"] = func(v any) string {
+		return Value(locale, v)
+	}
+	return funcs
 }
 
 // Template functions available in all templates
@@ -154,5 +218,7 @@ var templateFuncs = template.FuncMap{
 		}
 		return strings.Join(result, sep)
 	},
-	"toTitle": cases.Title,
+	"toTitle": func(v any) string {
+		return cases.Title(language.English).String(strings.ReplaceAll(fmt.Sprint(v), "_", " "))
+	},
 }
@@ -0,0 +1,136 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// messages holds the texts built outside of templates, email subjects and
+// message titles, by locale and key
+var messages = map[string]map[string]string{
+	DefaultLocale: {
+		"agent_offline":          "Agent Offline Alert - %s",
+		"agent_online":           "Agent Recovered - %s",
+		"agent_missing":          "Missing Agent Alert - %s",
+		"rule_alert":             "Alert %s - %s",
+		"network_error":          "Network Errors Alert - %s - %s",
+		"high_utilization":       "High Network Utilization - %s - %s",
+		"ip_change":              "IP Change Alert - %s",
+		"report":                 "%s Report - %s",
+		"heartbeat":              "Heartbeat - %s",
+		"batch":                  "Notification Digest - %d notifications",
+		"batch_critical":         " (%d critical)",
+		"title.agent_offline":    "Agent Offline Alert",
+		"title.agent_online":     "Agent Recovered",
+		"title.agent_missing":    "Missing Agent Alert",
+		"title.rule_alert":       "Alert %s",
+		"title.network_error":    "Network Errors Alert",
+		"title.high_utilization": "High Network Utilization Alert",
+		"title.ip_change":        "IP Change Alert",
+		"title.report":           "Summary Report",
+		"title.heartbeat":        "Heartbeat",
+	},
+	"zh-CN": {
+		"agent_offline":          "Agent 离线告警 - %s",
+		"agent_online":           "Agent 已恢复 - %s",
+		"agent_missing":          "Agent 缺失告警 - %s",
+		"rule_alert":             "告警 %s - %s",
+		"network_error":          "网络错误告警 - %s - %s",
+		"high_utilization":       "网络高负载告警 - %s - %s",
+		"ip_change":              "IP 变更告警 - %s",
+		"report":                 "%s报告 - %s",
+		"heartbeat":              "心跳 - %s",
+		"batch":                  "通知汇总 - %d 条通知",
+		"batch_critical":         "（%d 条严重）",
+		"title.agent_offline":    "Agent 离线告警",
+		"title.agent_online":     "Agent 已恢复",
+		"title.agent_missing":    "Agent 缺失告警",
+		"title.rule_alert":       "告警 %s",
+		"title.network_error":    "网络错误告警",
+		"title.high_utilization": "网络高负载告警",
+		"title.ip_change":        "IP 变更告警",
+		"title.report":           "汇总报告",
+		"title.heartbeat":        "心跳",
+	},
+}
+
+// values holds the names of actions, reasons, states and other values shown
+// in messages by locale, values without a name are shown in title case
+var values = map[string]map[string]string{
+	"zh-CN": {
+		// IP change actions and reasons
+		"add":                 "新增",
+		"update":              "变更",
+		"remove":              "移除",
+		"flapping":            "频繁变动",
+		"stable":              "恢复稳定",
+		"interface_added":     "新增接口",
+		"interface_removed":   "接口移除",
+		"ipv4_changed":        "IPv4 地址变更",
+		"ipv6_changed":        "IPv6 地址变更",
+		"gateway_added":       "新增网关",
+		"gateway_changed":     "网关变更",
+		"gateway_removed":     "网关移除",
+		"route_removed":       "路由移除",
+		"external_ip_added":   "新增外网 IP",
+		"external_ip_changed": "外网 IP 变更",
+		"external_ip_removed": "外网 IP 移除",
+
+		// Severities
+		"info":     "信息",
+		"warning":  "警告",
+		"critical": "严重",
+
+		// Inventory states and agent statuses
+		"ok":             "正常",
+		"pending":        "等待注册",
+		"not_registered": "未注册",
+		"disappeared":    "已消失",
+		"shortfall":      "数量不足",
+		"online":         "在线",
+		"offline":        "离线",
+		"error":          "错误",
+		"retired":        "已退役",
+		"expected":       "待注册",
+		"missing":        "缺失",
+
+		// Report periods
+		"daily":  "每日",
+		"weekly": "每周",
+
+		// Notification and report alert types
+		"agent_offline":    "Agent 离线",
+		"agent_online":     "Agent 恢复",
+		"agent_missing":    "Agent 缺失",
+		"network_error":    "网络错误",
+		"network_errors":   "网络错误",
+		"high_utilization": "网络高负载",
+		"ip_change":        "IP 变更",
+		"report":           "报告",
+		"rule_alert":       "规则告警",
+		"heartbeat":        "心跳",
+	},
+}
+
+// lookupMessage returns a message of a locale, or of the default locale
+func lookupMessage(locale, key string) string {
+	if msg, ok := messages[locale][key]; ok {
+		return msg
+	}
+	if msg, ok := messages[DefaultLocale][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// Value returns the name of a value in a locale, e.g. of an IP change action
+func Value(locale string, v any) string {
+	s := fmt.Sprint(v)
+	if name, ok := values[locale][s]; ok {
+		return name
+	}
+	return cases.Title(language.English).String(strings.ReplaceAll(s, "_", " "))
+}
@@ -2,6 +2,7 @@ package notify
 
 import (
 	"context"
+	"time"
 	"wameter/internal/types"
 )
 
@@ -17,6 +18,7 @@ const (
 	NotifierDiscord  NotifierType = "discord"
 	NotifierWebhook  NotifierType = "webhook"
 	NotifierFeishu   NotifierType = "feishu"
+	NotifierExec     NotifierType = "exec"
 )
 
 // Notifier represents notifier interface
@@ -24,6 +26,16 @@ type Notifier interface {
 	// NotifyAgentOffline sends agent offline notification
 	NotifyAgentOffline(agent *types.AgentInfo) error
 
+	// NotifyAgentOnline sends agent recovery notification after downtime
+	NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error
+
+	// NotifyAgentMissing sends an alert for an expected agent that never
+	// registered or disappeared, or a shortfall of expected agents
+	NotifyAgentMissing(alert *types.MissingAgentAlert) error
+
+	// NotifyRuleAlert sends an alert of a custom alert rule
+	NotifyRuleAlert(alert *types.RuleAlert) error
+
 	// NotifyNetworkErrors sends network errors notification
 	NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error
 
@@ -33,6 +45,12 @@ type Notifier interface {
 	// NotifyIPChange sends IP change notification
 	NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) error
 
+	// NotifyReport sends a summary report
+	NotifyReport(report *types.Report) error
+
+	// NotifyHeartbeat sends a liveness event of the server
+	NotifyHeartbeat(hb *types.Heartbeat) error
+
 	// Health checks the health of the notifier
 	Health(ctx context.Context) error
 }
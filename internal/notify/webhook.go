@@ -40,16 +40,12 @@ type WebhookPayload struct {
 }
 
 // NewWebhookNotifier creates new webhook notifier
-func NewWebhookNotifier(cfg *config.WebhookConfig, loader *ntpl.Loader, logger *zap.Logger) (*WebhookNotifier, error) {
-	client := &http.Client{
-		Timeout: cfg.Timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  true,
-			DisableKeepAlives:   false,
-			MaxIdleConnsPerHost: 10,
-		},
+func NewWebhookNotifier(cfg *config.WebhookConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*WebhookNotifier, error) {
+	// The webhook has its own request timeout
+	if cfg.Timeout > 0 {
+		c := *client
+		c.Timeout = cfg.Timeout
+		client = &c
 	}
 
 	return &WebhookNotifier{
@@ -62,7 +58,52 @@ func NewWebhookNotifier(cfg *config.WebhookConfig, loader *ntpl.Loader, logger *
 
 // NotifyAgentOffline sends an agent offline notification
 func (n *WebhookNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
-	payload := WebhookPayload{
+	return n.sendWebhook(agentOfflinePayload(agent))
+}
+
+// NotifyAgentOnline sends an agent recovery notification
+func (n *WebhookNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	return n.sendWebhook(agentOnlinePayload(agent, downtime))
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *WebhookNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	return n.sendWebhook(agentMissingPayload(alert))
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *WebhookNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	return n.sendWebhook(ruleAlertPayload(alert))
+}
+
+// NotifyNetworkErrors sends a network errors notification
+func (n *WebhookNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
+	return n.sendWebhook(networkErrorsPayload(agentID, iface))
+}
+
+// NotifyHighNetworkUtilization sends a high network utilization notification
+func (n *WebhookNotifier) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) error {
+	return n.sendWebhook(highUtilizationPayload(agentID, iface))
+}
+
+// NotifyIPChange sends IP change notification
+func (n *WebhookNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) error {
+	return n.sendWebhook(ipChangePayload(agent, change))
+}
+
+// NotifyReport sends a summary report
+func (n *WebhookNotifier) NotifyReport(report *types.Report) error {
+	return n.sendWebhook(reportPayload(report))
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *WebhookNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	return n.sendWebhook(heartbeatPayload(hb))
+}
+
+// agentOfflinePayload returns the payload of an agent offline notification
+func agentOfflinePayload(agent *types.AgentInfo) WebhookPayload {
+	return WebhookPayload{
 		EventType: "agent.offline",
 		EventID:   generateEventID(),
 		Timestamp: time.Now(),
@@ -75,13 +116,72 @@ func (n *WebhookNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 			"uptime":    agent.LastSeen.Sub(agent.RegisteredAt).String(),
 		},
 	}
+}
 
-	return n.sendWebhook(payload)
+// agentOnlinePayload returns the payload of an agent recovery notification
+func agentOnlinePayload(agent *types.AgentInfo, downtime time.Duration) WebhookPayload {
+	return WebhookPayload{
+		EventType: "agent.online",
+		EventID:   generateEventID(),
+		Timestamp: time.Now(),
+		AgentID:   agent.ID,
+		Hostname:  agent.Hostname,
+		Data: map[string]any{
+			"status":    agent.Status,
+			"last_seen": agent.LastSeen,
+			"version":   agent.Version,
+			"downtime":  downtime.Round(time.Second).String(),
+		},
+	}
 }
 
-// NotifyNetworkErrors sends a network errors notification
-func (n *WebhookNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
-	payload := WebhookPayload{
+// agentMissingPayload returns the payload of an expected inventory alert
+func agentMissingPayload(alert *types.MissingAgentAlert) WebhookPayload {
+	data := map[string]any{
+		"state":   alert.State,
+		"since":   alert.Since,
+		"message": alert.Message(),
+	}
+	if alert.State == types.InventoryShortfall {
+		data["count"] = alert.Count
+		data["tags"] = alert.Tags
+		data["expected"] = alert.Expected
+		data["actual"] = alert.Actual
+	} else if !alert.LastSeen.IsZero() {
+		data["last_seen"] = alert.LastSeen
+	}
+
+	return WebhookPayload{
+		EventType: "agent.missing",
+		EventID:   generateEventID(),
+		Timestamp: time.Now(),
+		AgentID:   alert.AgentID,
+		Hostname:  alert.Hostname,
+		Data:      data,
+	}
+}
+
+// ruleAlertPayload returns the payload of a custom alert rule alert
+func ruleAlertPayload(alert *types.RuleAlert) WebhookPayload {
+	return WebhookPayload{
+		EventType: "alert.rule",
+		EventID:   generateEventID(),
+		Timestamp: time.Now(),
+		AgentID:   alert.AgentID,
+		Hostname:  alert.Hostname,
+		Data: map[string]any{
+			"rule":     alert.Rule,
+			"severity": alert.Severity,
+			"message":  alert.Message,
+			"labels":   alert.Labels,
+			"fired_at": alert.Time,
+		},
+	}
+}
+
+// networkErrorsPayload returns the payload of a network errors notification
+func networkErrorsPayload(agentID string, iface *types.InterfaceInfo) WebhookPayload {
+	return WebhookPayload{
 		EventType: "network.errors",
 		EventID:   generateEventID(),
 		Timestamp: time.Now(),
@@ -97,13 +197,11 @@ func (n *WebhookNotifier) NotifyNetworkErrors(agentID string, iface *types.Inter
 			},
 		},
 	}
-
-	return n.sendWebhook(payload)
 }
 
-// NotifyHighNetworkUtilization sends a high network utilization notification
-func (n *WebhookNotifier) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) error {
-	payload := WebhookPayload{
+// highUtilizationPayload returns the payload of a high network utilization notification
+func highUtilizationPayload(agentID string, iface *types.InterfaceInfo) WebhookPayload {
+	return WebhookPayload{
 		EventType: "network.high_utilization",
 		EventID:   generateEventID(),
 		Timestamp: time.Now(),
@@ -116,17 +214,15 @@ func (n *WebhookNotifier) NotifyHighNetworkUtilization(agentID string, iface *ty
 				"tx_rate":     iface.Statistics.TxBytesRate,
 				"rx_total":    iface.Statistics.RxBytes,
 				"tx_total":    iface.Statistics.TxBytes,
-				"utilization": calculateUtilization(iface),
+				"utilization": iface.Statistics.Utilization(),
 			},
 		},
 	}
-
-	return n.sendWebhook(payload)
 }
 
-// NotifyIPChange sends IP change notification
-func (n *WebhookNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) error {
-	payload := WebhookPayload{
+// ipChangePayload returns the payload of an IP change notification
+func ipChangePayload(agent *types.AgentInfo, change *types.IPChange) WebhookPayload {
+	return WebhookPayload{
 		EventType: "ip.change",
 		EventID:   generateEventID(),
 		Timestamp: time.Now(),
@@ -143,10 +239,33 @@ func (n *WebhookNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.I
 			"action":         change.Action,
 			"reason":         change.Reason,
 			"changed_at":     change.Timestamp,
+			"context":        change.Context,
 		},
 	}
+}
 
-	return n.sendWebhook(payload)
+// reportPayload returns the payload of a summary report
+func reportPayload(report *types.Report) WebhookPayload {
+	return WebhookPayload{
+		EventType: "report.summary",
+		EventID:   generateEventID(),
+		Timestamp: time.Now(),
+		Data: map[string]any{
+			"report": report,
+		},
+	}
+}
+
+// heartbeatPayload returns the payload of a liveness event of the server
+func heartbeatPayload(hb *types.Heartbeat) WebhookPayload {
+	return WebhookPayload{
+		EventType: "server.heartbeat",
+		EventID:   generateEventID(),
+		Timestamp: time.Now(),
+		Data: map[string]any{
+			"heartbeat": hb,
+		},
+	}
 }
 
 // sendWebhook sends a webhook
@@ -245,18 +364,6 @@ func calculateBackoff(attempt int) time.Duration {
 	return backoff
 }
 
-// calculateUtilization calculates the utilization
-func calculateUtilization(iface *types.InterfaceInfo) float64 {
-	if iface.Statistics.Speed <= 0 {
-		return 0
-	}
-
-	totalRate := iface.Statistics.RxBytesRate + iface.Statistics.TxBytesRate
-	maxRate := float64(iface.Statistics.Speed * 1000000 / 8) // Convert Mbps to bytes/s
-
-	return (totalRate / maxRate) * 100
-}
-
 // randomBytes generates random bytes
 func randomBytes(n int) []byte {
 	b := make([]byte, n)
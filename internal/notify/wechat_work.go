@@ -25,6 +25,7 @@ type WeChatNotifier struct {
 	tokenMu    sync.RWMutex
 	tokenTimer *time.Timer
 	tplLoader  *ntpl.Loader
+	locale     string // Set by the manager
 }
 
 // WeChatMessage represents WeChat message
@@ -51,7 +52,7 @@ type WeChatTokenResponse struct {
 }
 
 // NewWeChatNotifier creates a new WeChat notifier
-func NewWeChatNotifier(cfg *config.WeChatConfig, loader *ntpl.Loader, logger *zap.Logger) (*WeChatNotifier, error) {
+func NewWeChatNotifier(cfg *config.WeChatConfig, client *http.Client, loader *ntpl.Loader, logger *zap.Logger) (*WeChatNotifier, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("wechat notifier is disabled")
 	}
@@ -61,11 +62,9 @@ func NewWeChatNotifier(cfg *config.WeChatConfig, loader *ntpl.Loader, logger *za
 	}
 
 	n := &WeChatNotifier{
-		config: cfg,
-		logger: logger,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		config:    cfg,
+		logger:    logger,
+		client:    client,
 		tplLoader: loader,
 	}
 
@@ -129,6 +128,37 @@ func (n *WeChatNotifier) NotifyAgentOffline(agent *types.AgentInfo) error {
 	return n.sendTemplate("agent_offline", data, "markdown")
 }
 
+// NotifyAgentOnline sends agent recovery notification
+func (n *WeChatNotifier) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) error {
+	// Prepare data
+	data := map[string]any{
+		"Agent":     agent,
+		"Downtime":  downtime.Round(time.Second).String(),
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_online", data, "markdown")
+}
+
+// NotifyAgentMissing sends an expected inventory alert
+func (n *WeChatNotifier) NotifyAgentMissing(alert *types.MissingAgentAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("agent_missing", data, "markdown")
+}
+
+// NotifyRuleAlert sends an alert of a custom alert rule
+func (n *WeChatNotifier) NotifyRuleAlert(alert *types.RuleAlert) error {
+	// Prepare data
+	data := map[string]any{
+		"Alert":     alert,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("rule_alert", data, "markdown")
+}
+
 // NotifyNetworkErrors sends network errors notification
 func (n *WeChatNotifier) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) error {
 	// Prepare data
@@ -157,18 +187,38 @@ func (n *WeChatNotifier) NotifyIPChange(agent *types.AgentInfo, change *types.IP
 		"Agent":         agent,
 		"Change":        change,
 		"Timestamp":     time.Now(),
+		"Action":        change.Action,
+		"Reason":        change.Reason,
 		"IsExternal":    change.IsExternal,
 		"Version":       change.Version,
 		"OldAddrs":      change.OldAddrs,
 		"NewAddrs":      change.NewAddrs,
 		"InterfaceName": change.InterfaceName,
+		"Context":       change.Context,
 	}
 	return n.sendTemplate("ip_change", data, "markdown")
 }
 
+// NotifyReport sends a summary report
+func (n *WeChatNotifier) NotifyReport(report *types.Report) error {
+	data := map[string]any{
+		"Report": report,
+	}
+	return n.sendTemplate("report", data, "markdown")
+}
+
+// NotifyHeartbeat sends a liveness event of the server
+func (n *WeChatNotifier) NotifyHeartbeat(hb *types.Heartbeat) error {
+	data := map[string]any{
+		"Heartbeat": hb,
+		"Timestamp": time.Now(),
+	}
+	return n.sendTemplate("heartbeat", data, "markdown")
+}
+
 // sendTemplate sends WeChat message
 func (n *WeChatNotifier) sendTemplate(templateName string, data map[string]any, format ...string) error {
-	tmpl, err := n.tplLoader.GetTemplate(ntpl.WeChat, templateName)
+	tmpl, err := n.tplLoader.GetTemplate(ntpl.WeChat, n.locale, templateName)
 	if err != nil {
 		return fmt.Errorf("failed to get template: %w", err)
 	}
@@ -0,0 +1,49 @@
+package profiling
+
+import (
+	"fmt"
+	"net"
+)
+
+// Config represents the pprof, expvar and runtime metrics endpoints
+// configuration. They are served on their own listener, bound to a loopback
+// address or protected by a bearer token.
+type Config struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Address   string `mapstructure:"address"`
+	AuthToken string `mapstructure:"auth_token"` // Required unless bound to a loopback address
+}
+
+// SetDefaults sets default values if not specified
+func (cfg *Config) SetDefaults(address string) *Config {
+	if cfg.Address == "" {
+		cfg.Address = address
+	}
+	return cfg
+}
+
+// Validate validates the endpoints configuration
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(cfg.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", cfg.Address, err)
+	}
+	if cfg.AuthToken == "" && !isLoopback(host) {
+		return fmt.Errorf("address %s is not a loopback address, auth_token is required", cfg.Address)
+	}
+
+	return nil
+}
+
+// isLoopback reports whether host only accepts local connections
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,148 @@
+package profiling
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// recentPauses is the number of recent GC pauses reported
+const recentPauses = 16
+
+func init() {
+	expvar.Publish("runtime", expvar.Func(func() any {
+		return ReadRuntimeStats()
+	}))
+}
+
+// Publish exposes the result of fn at /debug/vars under name, later calls
+// with the same name are ignored
+func Publish(name string, fn func() any) {
+	if expvar.Get(name) != nil {
+		return
+	}
+	expvar.Publish(name, expvar.Func(fn))
+}
+
+// ReadRuntimeStats returns goroutine, heap and garbage collection statistics
+// of the process
+func ReadRuntimeStats() *types.RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := &types.RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapSys:       m.HeapSys,
+		HeapObjects:   m.HeapObjects,
+		NextGC:        m.NextGC,
+		NumGC:         m.NumGC,
+		GCPauseTotal:  time.Duration(m.PauseTotalNs),
+		GCCPUFraction: m.GCCPUFraction,
+	}
+	if m.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC))
+	}
+
+	// PauseNs is a circular buffer, the most recent pause is at (NumGC+255)%256
+	n := min(int(m.NumGC), recentPauses)
+	stats.GCPauses = make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		pause := time.Duration(m.PauseNs[(int(m.NumGC)-1-i+len(m.PauseNs))%len(m.PauseNs)])
+		stats.GCPauses = append(stats.GCPauses, pause)
+		stats.GCPauseMax = max(stats.GCPauseMax, pause)
+	}
+
+	return stats
+}
+
+// Server serves the pprof, expvar and runtime metrics endpoints
+type Server struct {
+	config *Config
+	logger *zap.Logger
+	server *http.Server
+}
+
+// NewServer creates new profiling server
+func NewServer(cfg *Config, logger *zap.Logger) *Server {
+	s := &Server{
+		config: cfg,
+		logger: logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/runtime", handleRuntime)
+
+	s.server = &http.Server{
+		Addr:              cfg.Address,
+		Handler:           s.authorize(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start listens on the configured address and serves in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Address, err)
+	}
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Profiling server error", zap.Error(err))
+		}
+	}()
+
+	s.logger.Info("Profiling endpoints enabled", zap.String("address", s.config.Address))
+	return nil
+}
+
+// Stop shuts the server down
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// authorize requires the configured bearer token, if any
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.config.AuthToken == "" {
+		return next
+	}
+
+	want := []byte("Bearer " + s.config.AuthToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleRuntime writes the runtime statistics as JSON
+func handleRuntime(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(ReadRuntimeStats())
+}
@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"wameter/internal/awsv4"
+)
+
+// awsService is the Secrets Manager signing name
+const awsService = "secretsmanager"
+
+// awsProvider resolves aws-sm://secret-id#key references with the AWS Secrets
+// Manager API. Credentials come from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, the
+// region from the region param or AWS_REGION/AWS_DEFAULT_REGION.
+type awsProvider struct {
+	client *http.Client
+}
+
+// newAWSProvider creates new AWS Secrets Manager provider
+func newAWSProvider() *awsProvider {
+	return &awsProvider{client: &http.Client{}}
+}
+
+// Resolve fetches the referenced secret, a key selects a field of a JSON secret
+func (p *awsProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	creds, err := awsv4.CredentialsFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	region := ref.Params.Get("region")
+	if region == "" {
+		region = awsv4.RegionFromEnv()
+	}
+	if region == "" {
+		return "", errors.New("aws region is required")
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", awsService, region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid secrets manager endpoint: %w", err)
+	}
+
+	input := map[string]string{"SecretId": ref.Path}
+	if stage := ref.Params.Get("version_stage"); stage != "" {
+		input["VersionStage"] = stage
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsv4.Sign(req, body, creds, region, awsService, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query secrets manager: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("secrets manager returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary string `json:"SecretBinary"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+
+	value := result.SecretString
+	if value == "" && result.SecretBinary != "" {
+		decoded, err := base64.StdEncoding.DecodeString(result.SecretBinary)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode binary secret: %w", err)
+		}
+		value = string(decoded)
+	}
+
+	if ref.Key != "" {
+		return jsonField(value, ref.Key)
+	}
+	return value, nil
+}
@@ -0,0 +1,26 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fileProvider resolves file://path references, e.g. mounted Docker or
+// Kubernetes secrets, trailing newlines are trimmed
+type fileProvider struct{}
+
+// Resolve reads the referenced file
+func (p *fileProvider) Resolve(_ context.Context, ref Reference) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	value := strings.TrimRight(string(data), "\r\n")
+	if ref.Key != "" {
+		return jsonField(value, ref.Key)
+	}
+	return value, nil
+}
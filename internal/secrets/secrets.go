@@ -0,0 +1,236 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// defaultTimeout bounds resolving a single reference
+const defaultTimeout = 10 * time.Second
+
+// Provider resolves secret references of one scheme
+type Provider interface {
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// Reference represents a parsed secret reference, scheme://path?params#key
+type Reference struct {
+	Scheme string
+	Path   string
+	Key    string
+	Params url.Values
+}
+
+// ParseReference parses a secret reference, the path is kept verbatim so it
+// may contain colons such as in AWS ARNs
+func ParseReference(ref string) (Reference, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || scheme == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference: missing scheme")
+	}
+
+	rest, key, _ := strings.Cut(rest, "#")
+	path, query, _ := strings.Cut(rest, "?")
+	if path == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference: missing path")
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid secret reference params: %w", err)
+	}
+
+	return Reference{Scheme: scheme, Path: path, Key: key, Params: params}, nil
+}
+
+// binding is a config field whose value comes from a reference
+type binding struct {
+	key   string
+	ref   string
+	field reflect.Value
+	value string
+}
+
+// Resolver resolves secret references in config values
+type Resolver struct {
+	providers map[string]Provider
+	bindings  []*binding
+	timeout   time.Duration
+	mu        sync.Mutex
+	logger    *zap.Logger
+}
+
+// NewResolver creates new resolver with the file, vault and aws-sm providers
+func NewResolver(cfg *config.SecretsConfig, logger *zap.Logger) *Resolver {
+	timeout := defaultTimeout
+	if cfg != nil && cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
+	return &Resolver{
+		providers: map[string]Provider{
+			"file":   &fileProvider{},
+			"vault":  newVaultProvider(),
+			"aws-sm": newAWSProvider(),
+		},
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Register registers a provider for a reference scheme
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = p
+}
+
+// IsReference reports whether a config value is a secret reference
+func (r *Resolver) IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok = r.providers[scheme]
+	return ok
+}
+
+// Bind resolves the references held by secret fields of cfg in place and
+// remembers them for Refresh, replacing those of a previously bound config.
+// cfg must be a pointer to a config struct.
+func (r *Resolver) Bind(ctx context.Context, cfg any) error {
+	var bindings []*binding
+	config.WalkSecrets(cfg, func(key string, field reflect.Value) {
+		if r.IsReference(field.String()) {
+			bindings = append(bindings, &binding{key: key, ref: field.String(), field: field})
+		}
+	})
+
+	for _, b := range bindings {
+		value, err := r.resolve(ctx, b.ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", b.key, err)
+		}
+		b.value = value
+		b.field.SetString(value)
+	}
+
+	r.mu.Lock()
+	r.bindings = bindings
+	r.mu.Unlock()
+
+	if len(bindings) > 0 {
+		r.logger.Info("Resolved secret references", zap.Int("count", len(bindings)))
+	}
+
+	return nil
+}
+
+// Refresh re-resolves bound references and applies changed values through
+// apply, which must run update while no reader uses the config
+func (r *Resolver) Refresh(ctx context.Context, apply func(update func())) (int, error) {
+	r.mu.Lock()
+	bindings := make([]*binding, len(r.bindings))
+	copy(bindings, r.bindings)
+	r.mu.Unlock()
+
+	type change struct {
+		b     *binding
+		value string
+	}
+	var changes []change
+	for _, b := range bindings {
+		value, err := r.resolve(ctx, b.ref)
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve %s: %w", b.key, err)
+		}
+		if value != b.value {
+			changes = append(changes, change{b: b, value: value})
+		}
+	}
+
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	apply(func() {
+		for _, c := range changes {
+			c.b.value = c.value
+			c.b.field.SetString(c.value)
+		}
+	})
+
+	for _, c := range changes {
+		r.logger.Info("Secret rotated", zap.String("key", c.b.key))
+	}
+
+	return len(changes), nil
+}
+
+// Watch refreshes bound references every interval until ctx is done
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration, apply func(update func())) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Refresh(ctx, apply); err != nil {
+				r.logger.Warn("Failed to refresh secrets, keeping previous values", zap.Error(err))
+			}
+		}
+	}
+}
+
+// resolve resolves a single reference with the resolver timeout
+func (r *Resolver) resolve(ctx context.Context, ref string) (string, error) {
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	p, ok := r.providers[parsed.Scheme]
+	r.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unsupported secret scheme: %s", parsed.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return p.Resolve(ctx, parsed)
+}
+
+// jsonField returns a top-level field of a JSON object secret
+func jsonField(value, key string) (string, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret", key)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
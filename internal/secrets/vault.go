@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultProvider resolves vault://path#key references against the HashiCorp
+// Vault HTTP API, configured with the standard VAULT_ADDR, VAULT_TOKEN and
+// VAULT_NAMESPACE environment variables, both KV v1 and v2 are supported
+type vaultProvider struct {
+	client *http.Client
+}
+
+// newVaultProvider creates new Vault provider
+func newVaultProvider() *vaultProvider {
+	return &vaultProvider{client: &http.Client{}}
+}
+
+// Resolve reads the key of the referenced secret, key defaults to "value"
+func (p *vaultProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(ref.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	// KV v2 nests the secret under data.data
+	data := result.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, isMeta := data["metadata"]; isMeta {
+			data = nested
+		}
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = "value"
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in vault secret", key)
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,143 @@
+package agentstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// markOffline flips an agent to offline when it is still online and was not
+// seen after ARGV[2], agents without shared state yet count as online
+var markOffline = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], ARGV[1])
+if status and status ~= 'online' then
+  return 0
+end
+local seen = redis.call('HGET', KEYS[2], ARGV[1])
+if seen and tonumber(seen) > tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], 'offline')
+return 1
+`)
+
+// Redis represents a Redis backed agent state store, statuses and last seen
+// times are kept in two hashes keyed by agent ID
+type Redis struct {
+	rc        *redis.Client
+	statusKey string
+	seenKey   string
+}
+
+// _ implements Store
+var _ Store = (*Redis)(nil)
+
+// NewRedis connects to the Redis server of cfg
+func NewRedis(cfg *config.RedisConfig) (*Redis, error) {
+	rc := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	if err := rc.Ping(ctx).Err(); err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &Redis{
+		rc:        rc,
+		statusKey: cfg.KeyPrefix + "agents:status",
+		seenKey:   cfg.KeyPrefix + "agents:last_seen",
+	}, nil
+}
+
+// Update records the status and last seen time of an agent, reading the
+// previous state in the same transaction
+func (r *Redis) Update(ctx context.Context, agentID string, status types.AgentStatus, lastSeen time.Time) (State, error) {
+	var statusCmd, seenCmd *redis.StringCmd
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		statusCmd = pipe.HGet(ctx, r.statusKey, agentID)
+		seenCmd = pipe.HGet(ctx, r.seenKey, agentID)
+		pipe.HSet(ctx, r.statusKey, agentID, string(status))
+		pipe.HSet(ctx, r.seenKey, agentID, lastSeen.UnixMilli())
+		return nil
+	})
+	// A missing field fails the whole transaction result with redis.Nil
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return State{}, fmt.Errorf("failed to update agent state: %w", err)
+	}
+
+	prev := State{Status: types.AgentStatus(statusCmd.Val())}
+	if ms, err := seenCmd.Int64(); err == nil {
+		prev.LastSeen = time.UnixMilli(ms)
+	}
+	return prev, nil
+}
+
+// States returns the shared state of all agents
+func (r *Redis) States(ctx context.Context) (map[string]State, error) {
+	pipe := r.rc.Pipeline()
+	statusCmd := pipe.HGetAll(ctx, r.statusKey)
+	seenCmd := pipe.HGetAll(ctx, r.seenKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get agent states: %w", err)
+	}
+
+	states := make(map[string]State, len(statusCmd.Val()))
+	for id, status := range statusCmd.Val() {
+		state := State{Status: types.AgentStatus(status)}
+		if ms, err := strconv.ParseInt(seenCmd.Val()[id], 10, 64); err == nil {
+			state.LastSeen = time.UnixMilli(ms)
+		}
+		states[id] = state
+	}
+
+	return states, nil
+}
+
+// MarkOffline sets an online agent offline unless it was seen after
+// lastSeen, only the first replica to do so gets true
+func (r *Redis) MarkOffline(ctx context.Context, agentID string, lastSeen time.Time) (bool, error) {
+	marked, err := markOffline.Run(ctx, r.rc, []string{r.statusKey, r.seenKey},
+		agentID, lastSeen.UnixMilli()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark agent offline: %w", err)
+	}
+	return marked == 1, nil
+}
+
+// Delete removes the state of an agent
+func (r *Redis) Delete(ctx context.Context, agentID string) error {
+	_, err := r.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, r.statusKey, agentID)
+		pipe.HDel(ctx, r.seenKey, agentID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete agent state: %w", err)
+	}
+	return nil
+}
+
+// Ping checks the Redis server is reachable
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.rc.Ping(ctx).Err()
+}
+
+// Close closes the Redis client
+func (r *Redis) Close() error {
+	return r.rc.Close()
+}
@@ -0,0 +1,32 @@
+package agentstate
+
+import (
+	"context"
+	"time"
+	"wameter/internal/types"
+)
+
+// State represents the shared status of an agent
+type State struct {
+	Status   types.AgentStatus
+	LastSeen time.Time
+}
+
+// Store shares agent status and last seen time among server replicas, so
+// offline detection sees reports received by any of them
+type Store interface {
+	// Update records the status and last seen time of an agent and returns
+	// the previous state, zero when the agent had none
+	Update(ctx context.Context, agentID string, status types.AgentStatus, lastSeen time.Time) (State, error)
+	// States returns the shared state of all agents
+	States(ctx context.Context) (map[string]State, error)
+	// MarkOffline sets an online agent offline unless it was seen after
+	// lastSeen, only the first replica to do so gets true
+	MarkOffline(ctx context.Context, agentID string, lastSeen time.Time) (bool, error)
+	// Delete removes the state of an agent
+	Delete(ctx context.Context, agentID string) error
+	// Ping checks the store is reachable
+	Ping(ctx context.Context) error
+	// Close closes the store
+	Close() error
+}
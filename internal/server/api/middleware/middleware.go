@@ -1,181 +1,604 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
-	"runtime"
+	"path"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"wameter/internal/compress"
 	"wameter/internal/server/api/response"
 	"wameter/internal/server/config"
+	"wameter/internal/server/privacy"
+	"wameter/internal/server/rbac"
+	"wameter/internal/server/requestid"
+	"wameter/internal/server/tenant"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.uber.org/zap"
 )
 
 // Middleware represents middleware manager
 type Middleware struct {
-	logger *zap.Logger
-	config *config.Config
+	logger      *zap.Logger
+	config      *config.Config
+	privacy     *privacy.Redactor
+	rateLimit   atomic.Pointer[config.RateLimitConfig]
+	maxBodySize atomic.Int64
+	// Of metrics backfill
+	maxBackfillSize atomic.Int64
 }
 
 // New creates a new middleware manager
 func New(cfg *config.Config, logger *zap.Logger) *Middleware {
-	return &Middleware{
-		logger: logger,
-		config: cfg,
+	m := &Middleware{
+		logger:  logger,
+		config:  cfg,
+		privacy: privacy.New(&cfg.Privacy),
 	}
+	m.UpdateConfig(cfg)
+	return m
 }
 
-// RequestID adds request ID to context
+// UpdateConfig applies the live settings of a reloaded configuration
+func (m *Middleware) UpdateConfig(cfg *config.Config) {
+	rateLimit := cfg.API.RateLimit
+	m.rateLimit.Store(&rateLimit)
+	m.maxBodySize.Store(cfg.API.MaxBodySize)
+	m.maxBackfillSize.Store(cfg.API.MaxBackfillSize)
+}
+
+// bodyLimit returns the size limit of the request body of a route, zero
+// for none
+func (m *Middleware) bodyLimit(c *gin.Context) int64 {
+	if c.Request.Method != http.MethodPost {
+		return 0
+	}
+	path := "/v1" + m.config.Server.MetricsPath
+	switch c.FullPath() {
+	case path, path + "/remote_write":
+		return m.maxBodySize.Load()
+	case path + "/backfill":
+		return m.maxBackfillSize.Load()
+	default:
+		return 0
+	}
+}
+
+// RequestID propagates the X-Request-ID of the caller, or generates one,
+// returning it in the response and carrying it in the request context so
+// the service layer logs it
 func (m *Middleware) RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.GetHeader("X-Request-ID")
-		if requestID == "" {
+		requestID := c.GetHeader(requestid.Header)
+		if !requestid.Valid(requestID) {
 			requestID = uuid.New().String()
 		}
 		c.Set("request_id", requestID)
-		c.Header("X-Request-ID", requestID)
+		c.Header(requestid.Header, requestID)
+		c.Request = c.Request.WithContext(requestid.WithContext(c.Request.Context(), requestID))
 		c.Next()
 	}
 }
 
-// Logger logs request details
-func (m *Middleware) Logger() gin.HandlerFunc {
+// Tracing records a server span per request, continuing the trace of the
+// caller when it sent a trace context
+func (m *Middleware) Tracing() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		start := time.Now()
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
-		requestID := c.GetString("request_id")
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
 
+		ctx, span := tracing.StartServer(c.Request.Context(), c.Request.Header,
+			c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("request_id", c.GetString("request_id")))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// Logger logs each request with its status and latency at the access log
+// level. Server errors are logged as errors and slow requests as warnings.
+func (m *Middleware) Logger() gin.HandlerFunc {
+	cfg := m.config.API.AccessLog
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = true
+	}
+	log := m.logger.Info
+	if cfg.Level == config.AccessLogDebug {
+		log = m.logger.Debug
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Level == config.AccessLogNone || skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
 		latency := time.Since(start)
+
 		status := c.Writer.Status()
-		clientIP := c.ClientIP()
-		method := c.Request.Method
-		errorMessage := c.Errors.ByType(gin.ErrorTypePrivate).String()
-
-		m.logger.Debug("request completed",
-			zap.String("request_id", requestID),
-			zap.String("method", method),
-			zap.String("path", path),
-			zap.String("query", query),
-			zap.String("ip", clientIP),
+		fields := []zap.Field{
+			zap.String("request_id", c.GetString("request_id")),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.String("query", c.Request.URL.RawQuery),
+			zap.String("ip", c.ClientIP()),
+			zap.String("user_agent", c.Request.UserAgent()),
 			zap.Int("status", status),
 			zap.Duration("latency", latency),
-			zap.String("error", errorMessage))
+			zap.Int64("bytes_in", c.Request.ContentLength),
+			zap.Int("bytes_out", max(c.Writer.Size(), 0)),
+		}
+		if actor := c.GetString("actor"); actor != "" {
+			fields = append(fields, zap.String("actor", actor))
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("error", errs))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			m.logger.Error("Request failed", fields...)
+		case cfg.SlowThreshold > 0 && latency >= cfg.SlowThreshold:
+			m.logger.Warn("Slow request", fields...)
+		default:
+			log("Request completed", fields...)
+		}
 	}
 }
 
-// Recovery recovers from panics
+// Recovery recovers from panics of handlers, logging the panic with the
+// request and answering with a JSON error unless a response was written
 func (m *Middleware) Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				// Get stack trace
-				buf := make([]byte, 2048)
-				n := runtime.Stack(buf, false)
-				stackTrace := string(buf[:n])
-
-				var errMsg string
-				switch e := err.(type) {
-				case error:
-					errMsg = e.Error()
-				case string:
-					errMsg = e
-				default:
-					errMsg = fmt.Sprintf("%v", e)
-				}
+			err := recover()
+			if err == nil {
+				return
+			}
+			// Handlers abort responses by panicking with ErrAbortHandler,
+			// the server closes the connection without logging
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
 
-				m.logger.Error("panic recovered",
-					zap.String("error", errMsg),
-					zap.String("stack", stackTrace))
+			m.logger.Error("Panic recovered",
+				zap.String("request_id", c.GetString("request_id")),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Any("error", err),
+				zap.String("stack", string(debug.Stack())))
 
-				response.New(c, m.logger).Error(http.StatusInternalServerError,
-					errors.New("internal server error"))
+			if c.Writer.Written() {
 				c.Abort()
+				return
 			}
+			response.New(c, m.logger).Error(http.StatusInternalServerError,
+				types.ErrInternal)
+			c.Abort()
 		}()
 		c.Next()
 	}
 }
 
-// Cors handles CORS
+// Cors answers preflight requests and adds the CORS headers to requests of
+// allowed origins, requests of other origins get no CORS headers
 func (m *Middleware) Cors() gin.HandlerFunc {
+	cfg := m.config.API.CORS
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", strings.Join(m.config.API.CORS.AllowedOrigins, ","))
-		c.Header("Access-Control-Allow-Methods", strings.Join(m.config.API.CORS.AllowedMethods, ","))
-		c.Header("Access-Control-Allow-Headers", strings.Join(m.config.API.CORS.AllowedHeaders, ","))
-		c.Header("Access-Control-Max-Age", "86400")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+
+		preflight := c.Request.Method == http.MethodOptions &&
+			c.GetHeader("Access-Control-Request-Method") != ""
+		if !allowedOrigin(cfg.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
 
-		if c.Request.Method == http.MethodOptions {
+		if slices.Contains(cfg.AllowedOrigins, "*") {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
+		if exposed != "" {
+			c.Header("Access-Control-Expose-Headers", exposed)
+		}
 		c.Next()
 	}
 }
 
-// RateLimit implements rate limiting
-func (m *Middleware) RateLimit() gin.HandlerFunc {
-	type client struct {
-		count    int
-		lastSeen time.Time
+// allowedOrigin reports whether origin matches one of the allowed origins,
+// which may hold wildcards such as "https://*.example.com"
+func allowedOrigin(allowed []string, origin string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
 	}
+	return false
+}
 
-	clients := make(map[string]*client)
+// RateLimit implements token bucket rate limiting per client IP and per API
+// key, limits are read on each request so they follow configuration reloads.
+// Rejected requests get 429 with Retry-After.
+func (m *Middleware) RateLimit() gin.HandlerFunc {
+	clients := newLimiter()
+	keys := newLimiter()
 
 	return func(c *gin.Context) {
-		if !m.config.API.RateLimit.Enabled {
+		limit := m.rateLimit.Load()
+		if !limit.Enabled {
 			c.Next()
 			return
 		}
 
-		ip := c.ClientIP()
+		burst := limit.Burst
+		if limit.Strategy == "leaky" {
+			burst = 1
+		}
 		now := time.Now()
 
-		if cl, exists := clients[ip]; exists {
-			if now.Sub(cl.lastSeen) > m.config.API.RateLimit.Window {
-				cl.count = 0
-				cl.lastSeen = now
-			}
+		allowed, wait := clients.allow(c.ClientIP(), float64(limit.Requests)/limit.Window.Seconds(), burst, now)
+		if token := bearerToken(c.GetHeader("Authorization")); allowed && token != "" {
+			allowed, wait = keys.allow(auditActor(token), float64(limit.KeyRequests)/limit.Window.Seconds(), burst, now)
+		}
 
-			if cl.count >= m.config.API.RateLimit.Requests {
-				response.New(c, m.logger).Error(http.StatusTooManyRequests,
-					errors.New("rate limit exceeded"))
-				c.Abort()
-				return
-			}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.New(c, m.logger).Error(http.StatusTooManyRequests,
+				types.ErrRateLimited)
+			c.Abort()
+			return
+		}
 
-			cl.count++
-		} else {
-			clients[ip] = &client{count: 1, lastSeen: now}
+		c.Next()
+	}
+}
+
+// BodyLimit caps the request body of metric ingestion and remote write at
+// the configured size, and of backfill at its own size, larger bodies get
+// 413. A size of zero disables the limit.
+func (m *Middleware) BodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := m.bodyLimit(c)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			response.New(c, m.logger).Error(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("request body exceeds %d bytes", limit))
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// Decompress decodes the request body of metric ingestion and backfill by
+// its Content-Encoding, gzip and zstd are supported. Decoded bodies are
+// capped at the size limit of their route like uncompressed ones.
+func (m *Middleware) Decompress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := "/v1" + m.config.Server.MetricsPath
+		if c.Request.Method != http.MethodPost || (c.FullPath() != path && c.FullPath() != path+"/backfill") {
+			c.Next()
+			return
+		}
+
+		encoding := c.GetHeader("Content-Encoding")
+		if compress.Identity(encoding) {
+			c.Next()
+			return
+		}
+		if !compress.Supported(encoding) {
+			c.Header("Accept-Encoding", "gzip, zstd")
+			response.New(c, m.logger).Error(http.StatusUnsupportedMediaType,
+				fmt.Errorf("unsupported content encoding: %s", encoding))
+			c.Abort()
+			return
 		}
 
+		body, err := compress.NewReader(c.Request.Body, encoding)
+		if err != nil {
+			response.New(c, m.logger).BadRequest(err)
+			c.Abort()
+			return
+		}
+		defer func() {
+			_ = body.Close()
+		}()
+
+		var decoded io.ReadCloser = body
+		if limit := m.bodyLimit(c); limit > 0 {
+			decoded = http.MaxBytesReader(c.Writer, body, limit)
+		}
+		c.Request.Body = decoded
+		c.Request.ContentLength = -1
+		c.Request.Header.Del("Content-Encoding")
 		c.Next()
 	}
 }
 
-// Auth handles authentication
-func (m *Middleware) Auth() gin.HandlerFunc {
+// Authenticator resolves API keys to the principal they act as
+type Authenticator interface {
+	Authenticate(ctx context.Context, key string) (*types.Principal, error)
+}
+
+// Auth handles authentication. With apikey auth the allowed_users entries are
+// bootstrap admin keys of the default tenant, other keys are looked up with
+// authn. Requests are scoped to the tenant of their key.
+func (m *Middleware) Auth(authn Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
+		token := bearerToken(c.GetHeader("Authorization"))
 		if token == "" {
 			response.New(c, m.logger).Error(http.StatusUnauthorized,
-				errors.New("unauthorized"))
+				types.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		principal := &types.Principal{
+			Actor:    auditActor(token),
+			TenantID: tenant.Default,
+			Role:     types.RoleAdmin,
+		}
+		if m.config.API.Auth.Type == "apikey" && !m.isBootstrapKey(token) {
+			var err error
+			principal, err = authn.Authenticate(c.Request.Context(), token)
+			if err != nil {
+				if !errors.Is(err, types.ErrInvalidAPIKey) {
+					m.logger.Error("Failed to authenticate request", zap.Error(err))
+				}
+				response.New(c, m.logger).Error(http.StatusUnauthorized,
+					types.ErrUnauthorized)
+				c.Abort()
+				return
+			}
+		}
+
+		// TODO: Implement jwt and basic token validation
+
+		c.Set("tenant_id", principal.TenantID)
+		c.Set("actor", principal.Actor)
+		c.Set("role", string(principal.Role))
+
+		ctx := tenant.WithContext(c.Request.Context(), principal.TenantID)
+		c.Request = c.Request.WithContext(rbac.WithPrincipal(ctx, principal))
+
+		c.Next()
+	}
+}
+
+// Authorize enforces the role of the request principal and the agents it may
+// access, it runs after Auth
+func (m *Middleware) Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := rbac.FromContext(c.Request.Context())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		allowed := principal.Role.Includes(rbac.Required(c.Request.Method, route))
+		if id := c.Param("id"); allowed && id != "" && strings.HasPrefix(route, "/v1/agents/") {
+			allowed = rbac.AllowsAgent(c.Request.Context(), id)
+		}
+
+		if !allowed {
+			response.New(c, m.logger).Error(http.StatusForbidden, types.ErrForbidden)
 			c.Abort()
 			return
 		}
 
-		// TODO: Implement token validation
+		c.Next()
+	}
+}
+
+// isBootstrapKey reports whether token is one of the configured keys
+func (m *Middleware) isBootstrapKey(token string) bool {
+	for _, key := range m.config.API.Auth.AllowedUsers {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the credential of an Authorization header
+func bearerToken(authorization string) string {
+	token := strings.TrimSpace(authorization)
+	if scheme, rest, ok := strings.Cut(token, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		token = strings.TrimSpace(rest)
+	}
+	return token
+}
+
+// AuditRecorder stores audit entries
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, entry *types.AuditEntry) error
+}
+
+// auditActions names the audited routes, others are recorded as "method route"
+var auditActions = map[string]string{
+	"POST /v1/agents":                           "agent.register",
+	"PUT /v1/agents/:id":                        "agent.update",
+	"DELETE /v1/agents/:id":                     "agent.delete",
+	"POST /v1/agents/:id/command":               "command.send",
+	"POST /v1/agents/:id/decommission":          "agent.decommission",
+	"DELETE /v1/agents/:id/decommission":        "agent.reinstate",
+	"POST /v1/agents/:id/diagnostics":           "agent.diagnostics",
+	"POST /v1/agents/:id/collect":               "agent.collect",
+	"POST /v1/admin/reload":                     "config.reload",
+	"POST /v1/admin/erasures":                   "data.erase",
+	"POST /v1/metrics/backfill":                 "metrics.backfill",
+	"POST /v1/admin/tenants":                    "tenant.create",
+	"DELETE /v1/admin/tenants/:id":              "tenant.delete",
+	"POST /v1/admin/tenants/:id/keys":           "apikey.create",
+	"DELETE /v1/admin/tenants/:id/keys/:key_id": "apikey.revoke",
+	"POST /v1/admin/users":                      "user.create",
+	"PUT /v1/admin/users/:id":                   "user.update",
+	"DELETE /v1/admin/users/:id":                "user.delete",
+	"POST /v1/admin/users/:id/keys":             "apikey.create",
+	"POST /v1/annotations":                      "annotation.create",
+}
+
+// Audit records mutating requests with the calling API key and a digest of
+// the payload. Agent telemetry, metrics reports, heartbeats, diagnostics
+// uploads and shipped logs, and read-only Grafana queries are not recorded.
+func (m *Middleware) Audit(recorder AuditRecorder) gin.HandlerFunc {
+	skip := map[string]bool{
+		http.MethodPost + " /v1" + m.config.Server.MetricsPath:                   true,
+		http.MethodPost + " /v1" + m.config.Server.MetricsPath + "/remote_write": true,
+		http.MethodPost + " /v1/agents/:id/heartbeat":                            true,
+		http.MethodPut + " /v1/agents/:id/diagnostics":                           true,
+		http.MethodPost + " /v1/agents/:id/logs":                                 true,
+		http.MethodPost + " /v1/grafana/search":                                  true,
+		http.MethodPost + " /v1/grafana/query":                                   true,
+		http.MethodPost + " /v1/grafana/annotations":                             true,
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		if skip[route] {
+			c.Next()
+			return
+		}
+
+		// Digest the payload and restore it for the handler
+		var digest string
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				response.New(c, m.logger).BadRequest(errors.New("failed to read request body"))
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if len(body) > 0 {
+				sum := sha256.Sum256(body)
+				digest = hex.EncodeToString(sum[:])
+			}
+		}
 
 		c.Next()
+
+		action, ok := auditActions[route]
+		if !ok {
+			action = strings.ToLower(c.Request.Method) + " " + c.FullPath()
+		}
+
+		entry := &types.AuditEntry{
+			Timestamp:     time.Now(),
+			Actor:         c.GetString("actor"),
+			Action:        action,
+			Resource:      c.Param("id"),
+			Method:        c.Request.Method,
+			Path:          c.Request.URL.Path,
+			Status:        c.Writer.Status(),
+			ClientIP:      m.privacy.Addr(c.ClientIP()),
+			PayloadDigest: digest,
+		}
+
+		// Without auth the actor is derived from the presented key, if any
+		if entry.Actor == "" {
+			entry.Actor = auditActor(bearerToken(c.GetHeader("Authorization")))
+		}
+
+		// Record even when the client went away
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), 5*time.Second)
+		defer cancel()
+
+		if err := recorder.RecordAudit(ctx, entry); err != nil {
+			m.logger.Error("Failed to record audit entry",
+				zap.Error(err),
+				zap.String("request_id", c.GetString("request_id")),
+				zap.String("action", action),
+				zap.String("actor", entry.Actor))
+		}
+	}
+}
+
+// auditActor identifies the API key of a request without storing the key
+func auditActor(token string) string {
+	if token == "" {
+		return "anonymous"
 	}
+	sum := sha256.Sum256([]byte(token))
+	return "key:" + hex.EncodeToString(sum[:8])
 }
 
 // Metrics collects API metrics
@@ -208,6 +631,16 @@ func (m *Middleware) NoCache() gin.HandlerFunc {
 	}
 }
 
+// Timezone adds the display timezone of the server to responses, times in
+// responses are in UTC
+func (m *Middleware) Timezone() gin.HandlerFunc {
+	timezone := m.config.Server.Location().String()
+	return func(c *gin.Context) {
+		c.Header("X-Timezone", timezone)
+		c.Next()
+	}
+}
+
 // Secure adds security headers
 func (m *Middleware) Secure() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"wameter/internal/server/config"
+)
+
+// TestDecompressBodyLimit tests that compressed bodies of metric ingestion
+// and backfill are capped once decoded, by the limit of their route
+func TestDecompressBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Server.MetricsPath = "/metrics"
+	cfg.API.MaxBodySize = 1 << 10
+	cfg.API.MaxBackfillSize = 4 << 10
+	m := New(cfg, zaptest.NewLogger(t))
+
+	r := gin.New()
+	r.Use(m.BodyLimit(), m.Decompress())
+	read := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				c.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	r.POST("/v1/metrics", read)
+	r.POST("/v1/metrics/backfill", read)
+
+	testCases := []struct {
+		name     string
+		path     string
+		size     int
+		expected int
+	}{
+		{name: "Report within limit", path: "/v1/metrics", size: 1 << 10, expected: http.StatusOK},
+		{name: "Report over limit", path: "/v1/metrics", size: 1<<10 + 1, expected: http.StatusRequestEntityTooLarge},
+		{name: "Backfill within limit", path: "/v1/metrics/backfill", size: 4 << 10, expected: http.StatusOK},
+		{name: "Backfill over limit", path: "/v1/metrics/backfill", size: 1 << 20, expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Compresses to below the limits, only the decoded body exceeds them
+			var body bytes.Buffer
+			gz := gzip.NewWriter(&body)
+			_, err := gz.Write(bytes.Repeat([]byte{'a'}, tc.size))
+			require.NoError(t, err)
+			require.NoError(t, gz.Close())
+			require.Less(t, body.Len(), 4<<10)
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, &body)
+			req.Header.Set("Content-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket represents the tokens left to a client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter implements token bucket rate limiting per client key, rate and
+// burst are passed on each call so they follow configuration reloads
+type limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// newLimiter creates a new limiter
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token from the bucket of key, refilled at rate tokens per
+// second up to burst. When the bucket is empty it returns the time until the
+// next token.
+func (l *limiter) allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(rate, burst, now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, at most once per refill
+// period, so idle clients do not accumulate
+func (l *limiter) sweep(rate float64, burst int, now time.Time) {
+	refill := time.Duration(float64(burst) / rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
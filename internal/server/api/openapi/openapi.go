@@ -0,0 +1,391 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI specification version
+const Version = "3.0.3"
+
+// Document represents an OpenAPI document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security,omitempty"`
+}
+
+// Info represents the API information
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server represents an API server
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components represents reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme represents an authentication scheme
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// Operation represents an API operation
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter represents a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody represents a request body
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response represents an operation response
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType represents the schema of a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema represents a JSON schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Route describes an API route
+type Route struct {
+	Method  string
+	Path    string // gin style, e.g. /agents/:id
+	Tag     string
+	Summary string
+	Query   []Param
+	// Body is a value of the request body type
+	Body any
+	// Response is a value of the type in the data field of the response
+	Response any
+	// Status is the success status, defaults to 200
+	Status int
+	// ContentTypes lists raw content types for responses without the JSON envelope
+	ContentTypes []string
+	// Raw documents a JSON Response without the envelope
+	Raw bool
+}
+
+// Param describes a query parameter
+type Param struct {
+	Name        string
+	Type        string // string, integer, boolean
+	Format      string
+	Description string
+	Required    bool
+	Array       bool
+	Enum        []string
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// NewDocument creates new OpenAPI document
+func NewDocument(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+	}
+}
+
+// UseBearerAuth declares bearer token authentication for all operations
+func (d *Document) UseBearerAuth() {
+	d.Components.SecuritySchemes = map[string]*SecurityScheme{
+		"bearerAuth": {Type: "http", Scheme: "bearer"},
+	}
+	d.Security = []map[string][]string{{"bearerAuth": {}}}
+}
+
+// Add adds a route to the document
+func (d *Document) Add(prefix string, r Route) {
+	path := prefix + pathParam.ReplaceAllString(r.Path, "{$1}")
+	method := strings.ToLower(r.Method)
+
+	op := &Operation{
+		OperationID: operationID(method, r.Path),
+		Summary:     r.Summary,
+		Responses:   make(map[string]*Response),
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	for _, p := range r.Query {
+		schema := &Schema{Type: p.Type, Format: p.Format, Enum: p.Enum}
+		if schema.Type == "" {
+			schema.Type = "string"
+		}
+		if p.Array {
+			schema = &Schema{Type: "array", Items: schema}
+		}
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        p.Name,
+			In:          "query",
+			Description: p.Description,
+			Required:    p.Required,
+			Schema:      schema,
+		})
+	}
+
+	if r.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"application/json": {Schema: d.SchemaOf(r.Body)},
+			},
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := &Response{Description: http.StatusText(status)}
+	switch {
+	case status == http.StatusNoContent:
+		// No body
+	case len(r.ContentTypes) > 0:
+		success.Content = make(map[string]*MediaType)
+		for _, ct := range r.ContentTypes {
+			success.Content[ct] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		}
+	default:
+		data := &Schema{}
+		if r.Response != nil {
+			data = d.SchemaOf(r.Response)
+		}
+		if !r.Raw {
+			data = envelope(data)
+		}
+		success.Content = map[string]*MediaType{
+			"application/json": {Schema: data},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content: map[string]*MediaType{
+			"application/problem+json": {Schema: problem()},
+		},
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]*Operation)
+	}
+	d.Paths[path][method] = op
+}
+
+// JSON returns the document as JSON
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// SchemaOf returns the schema of a Go value, registering named structs as components
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schemaOfType(reflect.TypeOf(v))
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOfType returns the schema of a Go type
+func (d *Document) schemaOfType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		format := "int32"
+		if t.Size() == 8 {
+			format = "int64"
+		}
+		return &Schema{Type: "integer", Format: format}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Reserve the name first to support recursive types
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema returns the inline schema of a struct
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a name are flattened
+		if field.Anonymous && name == "" {
+			embedded := d.schemaOfType(field.Type)
+			if embedded.Ref != "" {
+				embedded = d.Components.Schemas[strings.TrimPrefix(embedded.Ref, "#/components/schemas/")]
+			}
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = d.schemaOfType(field.Type)
+	}
+
+	return schema
+}
+
+// envelope wraps data in the standard API response schema
+func envelope(data *Schema) *Schema {
+	props := map[string]*Schema{
+		"code":       {Type: "integer"},
+		"message":    {Type: "string"},
+		"error":      {Type: "string"},
+		"request_id": {Type: "string"},
+		"timestamp":  {Type: "string", Format: "date-time"},
+	}
+	if data != nil {
+		props["data"] = data
+	}
+	return &Schema{Type: "object", Properties: props}
+}
+
+// problem returns the schema of error responses, RFC 7807 problem details
+// with the code of the error and the request ID
+func problem() *Schema {
+	return &Schema{Type: "object", Properties: map[string]*Schema{
+		"type":       {Type: "string"},
+		"title":      {Type: "string"},
+		"status":     {Type: "integer"},
+		"detail":     {Type: "string"},
+		"instance":   {Type: "string"},
+		"code":       {Type: "string"},
+		"request_id": {Type: "string"},
+		"error":      {Type: "string"},
+		"timestamp":  {Type: "string", Format: "date-time"},
+	}}
+}
+
+// operationID derives an operation ID from method and path
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_'
+	}) {
+		if strings.HasPrefix(part, ":") {
+			b.WriteString("By")
+			part = part[1:]
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
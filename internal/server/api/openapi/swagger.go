@@ -0,0 +1,40 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// swaggerUIVersion is the Swagger UI release loaded from the CDN
+const swaggerUIVersion = "5.17.14"
+
+var swaggerUITemplate = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`))
+
+// SwaggerUIHandler returns a handler serving Swagger UI for the given spec URL
+func SwaggerUIHandler(specURL, title string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = swaggerUITemplate.Execute(w, map[string]string{
+			"Title":   title,
+			"Version": swaggerUIVersion,
+			"SpecURL": specURL,
+		})
+	}
+}
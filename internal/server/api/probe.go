@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"wameter/internal/server/service"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerProbeRoutes registers unauthenticated liveness and readiness probes
+func (r *Router) registerProbeRoutes(svc *service.Service) {
+	r.engine.GET("/healthz", probeHandler(svc.Liveness))
+	r.engine.GET("/readyz", probeHandler(svc.Readiness))
+}
+
+// probeHandler writes a probe result, with 503 if a critical check failed
+func probeHandler(probe func(ctx context.Context) *types.ProbeStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := probe(c.Request.Context())
+		code := http.StatusOK
+		if !status.OK() {
+			code = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(code, status)
+	}
+}
@@ -0,0 +1,56 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NotModified sets the ETag and Last-Modified of the response from a
+// version and a modification time, either may be empty, and answers 304 Not
+// Modified when the conditional headers of the request match them.
+// If-None-Match takes precedence over If-Modified-Since.
+func (h *Handler) NotModified(version string, modified time.Time) bool {
+	etag := ""
+	if version != "" {
+		// Weak, the envelope of responses varies by its timestamp
+		etag = `W/"` + version + `"`
+		h.ctx.Header("ETag", etag)
+	}
+	if !modified.IsZero() {
+		h.ctx.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	// Clients revalidate before using a cached response
+	h.ctx.Header("Cache-Control", "private, no-cache")
+
+	if !notModified(h.ctx.Request, etag, modified) {
+		return false
+	}
+	h.ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// notModified reports whether the conditional headers of r match etag or
+// modified
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Weak comparison, the W/ prefix is ignored
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !modified.IsZero() {
+		t, err := http.ParseTime(since)
+		// Last-Modified has a resolution of seconds
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
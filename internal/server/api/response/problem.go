@@ -0,0 +1,147 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"wameter/internal/types"
+)
+
+// ProblemContentType is the content type of error responses
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the code of a problem to its type URI
+const problemTypePrefix = "urn:wameter:problem:"
+
+// Problem represents an error response as an RFC 7807 problem details
+// object, with the machine-readable code of the error and the request ID as
+// extension members
+type Problem struct {
+	Type      string    `json:"type"`               // urn:wameter:problem:<code>
+	Title     string    `json:"title"`              // Text of the status
+	Status    int       `json:"status"`             // HTTP status code
+	Detail    string    `json:"detail,omitempty"`   // Message of the error
+	Instance  string    `json:"instance,omitempty"` // Path of the request
+	Code      string    `json:"code"`               // Machine-readable error code
+	RequestID string    `json:"request_id"`
+	Error     string    `json:"error,omitempty"` // Same as detail, for clients of the response envelope
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// problemRender renders a problem as problem+json
+type problemRender struct {
+	problem Problem
+}
+
+// Render implements render.Render
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+// WriteContentType implements render.Render
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
+
+// problemCode maps an error to its status and code
+type problemCode struct {
+	err    error
+	status int
+	code   string
+}
+
+// problemCodes lists the errors with codes of their own, errors not listed
+// get the code of their status
+var problemCodes = []problemCode{
+	{types.ErrAgentNotFound, http.StatusNotFound, "agent_not_found"},
+	{types.ErrAgentOnline, http.StatusConflict, "agent_online"},
+	{types.ErrAgentOffline, http.StatusConflict, "agent_offline"},
+	{types.ErrAgentExists, http.StatusConflict, "agent_exists"},
+	{types.ErrAgentRetired, http.StatusGone, "agent_retired"},
+	{types.ErrAgentNotRetired, http.StatusNotFound, "agent_not_retired"},
+	{types.ErrAgentUnsupported, http.StatusUpgradeRequired, "agent_unsupported"},
+	{types.ErrAgentErased, http.StatusGone, "agent_erased"},
+	{types.ErrErasurePending, http.StatusConflict, "erasure_pending"},
+	{types.ErrErasureNotFound, http.StatusNotFound, "erasure_not_found"},
+	{types.ErrInvalidErasure, http.StatusBadRequest, "invalid_erasure"},
+	{types.ErrTenantNotFound, http.StatusNotFound, "tenant_not_found"},
+	{types.ErrTenantExists, http.StatusConflict, "tenant_exists"},
+	{types.ErrTenantInUse, http.StatusConflict, "tenant_in_use"},
+	{types.ErrInvalidAPIKey, http.StatusUnauthorized, "invalid_api_key"},
+	{types.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{types.ErrUserExists, http.StatusConflict, "user_exists"},
+	{types.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{types.ErrInvalidMetrics, http.StatusBadRequest, "invalid_metrics"},
+	{types.ErrDeltaBaseMismatch, http.StatusConflict, "delta_base_mismatch"},
+	{types.ErrIngestQueueFull, http.StatusServiceUnavailable, "ingest_queue_full"},
+	{types.ErrIngestClosed, http.StatusServiceUnavailable, "ingest_closed"},
+	{types.ErrIngestThrottled, http.StatusTooManyRequests, "ingest_throttled"},
+	{types.ErrQuotaExceeded, http.StatusInsufficientStorage, "quota_exceeded"},
+	{types.ErrRemoteWriteDisabled, http.StatusNotFound, "remote_write_disabled"},
+	{types.ErrNoDiagnostics, http.StatusNotFound, "diagnostics_not_found"},
+	{types.ErrInvalidInventory, http.StatusBadRequest, "invalid_inventory"},
+	{types.ErrInvalidMaintenance, http.StatusBadRequest, "invalid_maintenance"},
+	{types.ErrNotInMaintenance, http.StatusNotFound, "not_in_maintenance"},
+	{types.ErrInvalidGrafanaQuery, http.StatusBadRequest, "invalid_grafana_query"},
+	{types.ErrInvalidAnnotation, http.StatusBadRequest, "invalid_annotation"},
+	{types.ErrTelegramUpdates, http.StatusNotFound, "telegram_updates_disabled"},
+	{types.ErrCommandNotFound, http.StatusNotFound, "command_not_found"},
+	{types.ErrJobNotFound, http.StatusNotFound, "job_not_found"},
+	{types.ErrJobRunning, http.StatusConflict, "job_running"},
+	{types.ErrJobDisabled, http.StatusConflict, "job_disabled"},
+	{types.ErrNotLeader, http.StatusConflict, "not_leader"},
+	{types.ErrPruneRunning, http.StatusConflict, "prune_running"},
+	{types.ErrPruneNotFound, http.StatusNotFound, "prune_not_found"},
+	{types.ErrInvalidPrune, http.StatusBadRequest, "invalid_prune"},
+	{types.ErrInvalidIPWindow, http.StatusBadRequest, "invalid_ip_change_window"},
+	{types.ErrAlertRuleNotFound, http.StatusNotFound, "alert_rule_not_found"},
+	{types.ErrInvalidAlertRule, http.StatusBadRequest, "invalid_alert_rule"},
+	{types.ErrAlertRuleConfigured, http.StatusConflict, "alert_rule_configured"},
+	{types.ErrNotifyDisabled, http.StatusConflict, "notify_disabled"},
+	{types.ErrChannelNotEnabled, http.StatusNotFound, "channel_not_enabled"},
+	{types.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{types.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{types.ErrNotFound, http.StatusNotFound, "not_found"},
+	{types.ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+	{types.ErrPayloadTooLarge, http.StatusRequestEntityTooLarge, "payload_too_large"},
+	{types.ErrTimeout, http.StatusGatewayTimeout, "timeout"},
+	{types.ErrUnavailable, http.StatusServiceUnavailable, "unavailable"},
+	{types.ErrInternal, http.StatusInternalServerError, "internal_error"},
+}
+
+// statusCodes holds the codes of statuses whose code is not derived from
+// their text
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "validation_failed",
+	http.StatusUnprocessableEntity:   "validation_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// lookupProblem returns the status and code of err, if it has a code of its
+// own
+func lookupProblem(err error) (problemCode, bool) {
+	for _, p := range problemCodes {
+		if errors.Is(err, p.err) {
+			return p, true
+		}
+	}
+	return problemCode{}, false
+}
+
+// codeOf returns the code of err answered with status
+func codeOf(err error, status int) string {
+	if p, ok := lookupProblem(err); ok {
+		return p.code
+	}
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -58,20 +58,37 @@ func (h *Handler) Created(data any) {
 	})
 }
 
+// Accepted sends accepted response for work completed later
+func (h *Handler) Accepted(data any) {
+	h.ctx.JSON(http.StatusAccepted, Response{
+		Code:      http.StatusAccepted,
+		Message:   "accepted",
+		Data:      data,
+		RequestID: h.ctx.GetString("request_id"),
+		Timestamp: time.Now(),
+	})
+}
+
 // NoContent sends no content response
 func (h *Handler) NoContent() {
 	h.ctx.JSON(http.StatusNoContent, nil)
 }
 
-// Error sends an error response
+// Error sends an error response as problem+json, with the code of err, or
+// of the status when err has none
 func (h *Handler) Error(status int, err error) {
-	h.ctx.JSON(status, Response{
-		Code:      status,
-		Message:   "error",
-		Error:     err.Error(),
+	problem := Problem{
+		Type:      problemTypePrefix + codeOf(err, status),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Error(),
+		Instance:  h.ctx.Request.URL.Path,
+		Code:      codeOf(err, status),
 		RequestID: h.ctx.GetString("request_id"),
+		Error:     err.Error(),
 		Timestamp: time.Now(),
-	})
+	}
+	h.ctx.Render(status, problemRender{problem})
 }
 
 // BadRequest sends bad request error response
@@ -3,9 +3,12 @@ package api
 import (
 	"net/http"
 	"wameter/internal/server/api/middleware"
+	"wameter/internal/server/api/openapi"
+	"wameter/internal/server/api/response"
 	av1 "wameter/internal/server/api/v1"
 	"wameter/internal/server/config"
 	"wameter/internal/server/service"
+	"wameter/internal/types"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -32,11 +35,19 @@ func NewRouter(cfg *config.Config, svc *service.Service, logger *zap.Logger) *Ro
 	}
 
 	// Initialize middleware
-	r.setupMiddleware()
+	r.setupMiddleware(svc)
+
+	// Initialize probes
+	r.registerProbeRoutes(svc)
 
 	// Initialize API versions
 	r.setupAPIV1(svc)
 
+	// Unknown routes are answered like API errors
+	r.engine.NoRoute(func(c *gin.Context) {
+		response.New(c, r.logger).Error(http.StatusNotFound, types.ErrNotFound)
+	})
+
 	return r
 }
 
@@ -46,13 +57,16 @@ func (r *Router) Handler() http.Handler {
 }
 
 // setupMiddleware configures all middleware
-func (r *Router) setupMiddleware() {
+func (r *Router) setupMiddleware(svc *service.Service) {
 	m := middleware.New(r.config, r.logger)
+	svc.OnConfigReload(m.UpdateConfig)
 
 	// Basic middleware
 	r.engine.Use(m.RequestID())
+	r.engine.Use(m.Tracing())
 	r.engine.Use(m.Logger())
 	r.engine.Use(m.Recovery())
+	r.engine.Use(m.Timezone())
 
 	// Security middleware
 	r.engine.Use(m.Secure())
@@ -62,23 +76,41 @@ func (r *Router) setupMiddleware() {
 		r.engine.Use(m.Cors())
 	}
 
-	// Rate limiting if enabled
-	if r.config.API.RateLimit.Enabled {
-		r.engine.Use(m.RateLimit())
-	}
+	// Rate limiting, always installed so it can be enabled by a reload
+	r.engine.Use(m.RateLimit())
 }
 
 // setupAPIV1 configures v1 API routes
 func (r *Router) setupAPIV1(svc *service.Service) {
 	api := av1.NewAPI(r.config, svc, r.logger)
+	m := middleware.New(r.config, r.logger)
+
+	// OpenAPI specification is served without authentication
+	api.RegisterOpenAPIRoutes(r.engine.Group("/v1"))
+	// Telegram updates are authenticated by their secret token
+	api.RegisterTelegramRoutes(r.engine.Group("/v1"))
+	if r.config.API.Docs.Enabled {
+		r.engine.GET(r.config.API.Docs.Path, gin.WrapF(openapi.SwaggerUIHandler("/v1/openapi.json", r.config.API.Docs.Title)))
+	}
 
 	// Create v1 route group
 	v1Router := r.engine.Group("/v1")
 
 	// Add authentication for protected routes
 	if r.config.API.Auth.Enabled {
-		m := middleware.New(r.config, r.logger)
-		v1Router.Use(m.Auth())
+		v1Router.Use(m.Auth(svc))
+	}
+
+	// Cap the size of metrics reports, compressed and decoded
+	v1Router.Use(m.BodyLimit())
+	v1Router.Use(m.Decompress())
+
+	// Record mutating calls in the audit log
+	v1Router.Use(m.Audit(svc))
+
+	// Enforce roles after auditing so denied calls are recorded too
+	if r.config.API.Auth.Enabled {
+		v1Router.Use(m.Authorize())
 	}
 
 	// Register routes
@@ -0,0 +1,313 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"wameter/internal/server/api/response"
+	"wameter/internal/server/backup"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminAPI represents server administration API
+type AdminAPI interface {
+	RegisterAdminRoutes(r *gin.RouterGroup)
+}
+
+// _ implements AdminAPI
+var _ AdminAPI = (*API)(nil)
+
+// RegisterAdminRoutes registers administration routes
+func (api *API) RegisterAdminRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.POST("/reload", api.reloadConfig)
+	admin.GET("/backup", api.backup)
+	admin.GET("/config/history", api.getConfigHistory)
+	admin.GET("/ingest", api.getIngestStats)
+	admin.GET("/jobs", api.getJobs)
+	admin.POST("/jobs/:name/run", api.runJob)
+	admin.GET("/metrics", api.getServiceMetrics)
+	admin.POST("/notify/test", api.testNotification)
+	admin.POST("/prune", api.prune)
+	admin.GET("/prune", api.getPrune)
+	admin.GET("/quotas", api.getQuotas)
+	admin.GET("/stats", api.getServiceStats)
+}
+
+// requireAdmin restricts routes to credentials of the default tenant
+func (api *API) requireAdmin(c *gin.Context) {
+	if id, ok := tenant.FromContext(c.Request.Context()); ok && id != tenant.Default {
+		response.New(c, api.logger).Error(http.StatusForbidden, errors.New("forbidden"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// reloadConfig handles reloading the server configuration
+func (api *API) reloadConfig(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	change, err := api.service.ReloadConfig(ctx)
+	if err != nil {
+		api.logger.Error("Failed to reload configuration", zap.Error(err))
+		resp.InternalError(err)
+		return
+	}
+
+	resp.Success(change)
+}
+
+// getConfigHistory handles retrieving configuration change history
+func (api *API) getConfigHistory(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	history, err := api.service.GetConfigHistory(ctx)
+	if err != nil {
+		resp.InternalError(err)
+		return
+	}
+
+	resp.Success(history)
+}
+
+// getIngestStats handles retrieving the metrics ingest queue depth and lag
+func (api *API) getIngestStats(c *gin.Context) {
+	resp := response.New(c, api.logger)
+
+	stats := api.service.GetIngestStats()
+	if stats == nil {
+		resp.NotFound(errors.New("ingest queue is disabled"))
+		return
+	}
+
+	resp.Success(stats)
+}
+
+// getJobs handles retrieving the schedules and last runs of the periodic
+// tasks of the server
+func (api *API) getJobs(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	resp.Success(api.service.GetJobs(ctx))
+}
+
+// runJob handles starting a run of a periodic task now, answered 202 as the
+// run continues in the background
+func (api *API) runJob(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	status, err := api.service.RunJob(ctx, c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrJobNotFound):
+			resp.NotFound(types.ErrJobNotFound)
+		case errors.Is(err, types.ErrJobRunning), errors.Is(err, types.ErrJobDisabled), errors.Is(err, types.ErrNotLeader):
+			resp.Error(http.StatusConflict, err)
+		default:
+			api.logger.Error("Failed to run job", zap.Error(err), zap.String("job", c.Param("name")))
+			resp.InternalError(errors.New("failed to run job"))
+		}
+		return
+	}
+
+	resp.Accepted(status)
+}
+
+// getServiceMetrics handles retrieving the server's own metrics, including
+// goroutine, heap and garbage collection statistics
+func (api *API) getServiceMetrics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	resp.Success(api.service.GetServiceMetrics(ctx))
+}
+
+// prune handles deleting the metrics older than a cutoff, a dry run is
+// answered with the rows that would be deleted
+func (api *API) prune(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req types.PruneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid prune request: %w", err))
+		return
+	}
+	req.RequestedBy = c.GetString("actor")
+
+	p, err := api.service.Prune(ctx, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrInvalidPrune):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrPruneRunning):
+			resp.Error(http.StatusConflict, err)
+		default:
+			api.logger.Error("Failed to prune", zap.Error(err), zap.Time("before", req.Before))
+			resp.InternalError(errors.New("failed to prune"))
+		}
+		return
+	}
+
+	if p.DryRun {
+		resp.Success(p)
+		return
+	}
+	resp.Accepted(p)
+}
+
+// getPrune handles retrieving the progress of the last prune
+func (api *API) getPrune(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	p, err := api.service.GetPrune(ctx)
+	if err != nil {
+		resp.NotFound(err)
+		return
+	}
+
+	resp.Success(p)
+}
+
+// testNotification handles sending a test notification through a channel,
+// or through all enabled channels
+func (api *API) testNotification(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req types.NotificationTestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid notification test request: %w", err))
+			return
+		}
+	}
+
+	results, err := api.service.TestNotification(ctx, req.Channel, c.GetString("actor"))
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrNotifyDisabled):
+			resp.Error(http.StatusConflict, err)
+		case errors.Is(err, types.ErrChannelNotEnabled):
+			resp.NotFound(err)
+		default:
+			api.logger.Error("Failed to send test notification", zap.Error(err))
+			resp.InternalError(errors.New("failed to send test notification"))
+		}
+		return
+	}
+
+	resp.Success(results)
+}
+
+// getQuotas handles retrieving the quota usage of the agents
+func (api *API) getQuotas(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	resp.Success(api.service.GetQuotas(ctx))
+}
+
+// getServiceStats handles retrieving the service counters since the last restart
+func (api *API) getServiceStats(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	resp.Success(api.service.GetServiceStats(ctx))
+}
+
+// backup handles streaming an archive of the server data, metrics and IP
+// changes are limited to start_time and end_time when given
+func (api *API) backup(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query struct {
+		StartTime string `form:"start_time"`
+		EndTime   string `form:"end_time"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid backup parameters: %w", err))
+		return
+	}
+
+	opts := backup.Options{EndTime: time.Now()}
+	if query.StartTime != "" {
+		t, err := utils.ParseTime(query.StartTime)
+		if err != nil {
+			resp.BadRequest(fmt.Errorf("invalid start_time format: %v", err))
+			return
+		}
+		opts.StartTime = t
+	}
+	if query.EndTime != "" {
+		t, err := utils.ParseTime(query.EndTime)
+		if err != nil {
+			resp.BadRequest(fmt.Errorf("invalid end_time format: %v", err))
+			return
+		}
+		opts.EndTime = t
+	}
+	if opts.EndTime.Before(opts.StartTime) {
+		resp.BadRequest(errors.New("end_time must be after start_time"))
+		return
+	}
+
+	reader, err := api.service.Backup(ctx, opts)
+	if err != nil {
+		resp.InternalError(errors.New("failed to back up server data"))
+		return
+	}
+
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=wameter-backup-%s.jsonl.gz",
+		time.Now().Format("20060102-150405")))
+
+	// Stream response, the archive is written in one step
+	c.Stream(func(w io.Writer) bool {
+		if _, err := io.Copy(w, reader); err != nil {
+			api.logger.Error("Failed to write backup", zap.Error(err))
+		}
+		return false
+	})
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 	"wameter/internal/server/api/response"
+	"wameter/internal/server/service"
 	"wameter/internal/types"
 
 	"github.com/gin-gonic/gin"
@@ -22,36 +25,105 @@ type AgentAPI interface {
 // _ implements AgentAPI
 var _ AgentAPI = (*API)(nil)
 
+// agentUpdateRequest represents an agent update request
+type agentUpdateRequest struct {
+	Hostname string            `json:"hostname"`
+	Version  string            `json:"version"`
+	Status   types.AgentStatus `json:"status"`
+	Port     int               `json:"port"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// agentQuery represents agent list query parameters
+type agentQuery struct {
+	Statuses []string `form:"status"`
+	Hostname string   `form:"hostname"`
+	Tags     []string `form:"tag"` // key=value, or key to match any value
+	Sort     string   `form:"sort"`
+	Order    string   `form:"order"`
+	Limit    int      `form:"limit"`
+	Offset   int      `form:"offset"`
+}
+
+// agentPage represents a page of agents
+type agentPage struct {
+	Agents  []*types.AgentInfo `json:"agents"`
+	Total   int64              `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	HasMore bool               `json:"has_more"`
+}
+
+// commandRequest represents an agent command request
+type commandRequest struct {
+	Type    string          `json:"type" binding:"required"`
+	Timeout time.Duration   `json:"timeout"`
+	Payload json.RawMessage `json:"payload"`
+}
+
 // RegisterAgentRoutes registers agent routes
 func (api *API) RegisterAgentRoutes(r *gin.RouterGroup) {
 	// Agents endpoints
 	agents := r.Group("/agents")
 	{
 		agents.GET("", api.getAgents)
+		agents.GET("/status", api.getAgentStatuses)
 		agents.GET("/:id", api.getAgent)
 		agents.POST("", api.registerAgent)
 		agents.PUT("/:id", api.updateAgent)
+		agents.DELETE("/:id", api.deleteAgent)
 		agents.GET("/:id/metrics", api.getAgentMetrics)
 		agents.POST("/:id/command", api.sendCommand)
+		agents.GET("/:id/commands", api.pullCommands)
 		agents.POST("/:id/heartbeat", api.handleAgentHeartbeat)
+		agents.POST("/:id/decommission", api.decommissionAgent)
+		agents.GET("/:id/decommission", api.getDecommission)
+		agents.DELETE("/:id/decommission", api.cancelDecommission)
+		agents.PUT("/:id/maintenance", api.setMaintenance)
+		agents.DELETE("/:id/maintenance", api.endMaintenance)
+		agents.POST("/:id/diagnostics", api.requestDiagnostics)
+		agents.PUT("/:id/diagnostics", api.uploadDiagnostics)
+		agents.GET("/:id/diagnostics", api.downloadDiagnostics)
+		agents.GET("/:id/diagnostics/bundles", api.listDiagnostics)
+		agents.POST("/:id/collect", api.requestCollection)
+		agents.POST("/:id/logs", api.shipAgentLogs)
+		agents.GET("/:id/logs", api.getAgentLogs)
 	}
 }
 
-// getAgents handles retrieving all agents
+// getAgents handles retrieving a filtered and sorted page of agents
 func (api *API) getAgents(c *gin.Context) {
 	ctx, cancel := context.WithCancel(c.Request.Context())
 	defer cancel()
 
 	resp := response.New(c, api.logger)
 
-	agents, err := api.service.GetAgents(ctx)
+	var query agentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	// Unchanged agents are answered without querying them
+	version, modified, known := api.service.AgentsVersion(ctx, filter)
+	if known && resp.NotModified(version, modified) {
+		return
+	}
+
+	agents, total, err := api.service.GetAgents(ctx, filter)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			api.logger.Info("Client canceled agents request")
 			return
 		}
 		if errors.Is(err, context.DeadlineExceeded) {
-			resp.Error(http.StatusGatewayTimeout, errors.New("request timeout"))
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
 			return
 		}
 
@@ -62,7 +134,91 @@ func (api *API) getAgents(c *gin.Context) {
 		return
 	}
 
-	resp.Success(agents)
+	if agents == nil {
+		agents = []*types.AgentInfo{}
+	}
+
+	page := agentPage{
+		Agents:  agents,
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+		HasMore: int64(filter.Offset+len(agents)) < total,
+	}
+	if !known {
+		version, modified = pageVersion(page)
+		if resp.NotModified(version, modified) {
+			return
+		}
+	}
+	resp.Success(page)
+}
+
+// pageVersion returns the version of a page of agents and when its agents
+// last changed
+func pageVersion(page agentPage) (string, time.Time) {
+	version, _ := service.Version(page)
+	var modified time.Time
+	for _, agent := range page.Agents {
+		if t := service.AgentModified(agent); t.After(modified) {
+			modified = t
+		}
+	}
+	return version, modified
+}
+
+// getAgentStatuses handles retrieving the status of all agents, or of those
+// matching the filter, for dashboards refreshing the fleet
+func (api *API) getAgentStatuses(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query agentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	statuses, err := api.service.GetAgentStatuses(ctx, filter)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled agent statuses request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+
+		api.logger.Error("Failed to get agent statuses",
+			zap.Error(err),
+			zap.String("client_ip", c.ClientIP()))
+		resp.InternalError(errors.New("failed to get agent statuses"))
+		return
+	}
+
+	version, _ := service.Version(statuses)
+	var modified time.Time
+	for _, status := range statuses {
+		if status.LastSeen.After(modified) {
+			modified = status.LastSeen
+		}
+		if status.LatestSample != nil && status.LatestSample.After(modified) {
+			modified = *status.LatestSample
+		}
+	}
+	if resp.NotModified(version, modified) {
+		return
+	}
+	resp.Success(statuses)
 }
 
 // getAgent handles retrieving a specific agent
@@ -78,6 +234,12 @@ func (api *API) getAgent(c *gin.Context) {
 		return
 	}
 
+	// An unchanged agent is answered without querying it
+	version, modified, known := api.service.AgentVersion(ctx, agentID)
+	if known && resp.NotModified(version, modified) {
+		return
+	}
+
 	agent, err := api.service.GetAgent(ctx, agentID)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
@@ -91,7 +253,7 @@ func (api *API) getAgent(c *gin.Context) {
 			zap.String("agent_id", agentID))
 
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
 			return
 		}
 
@@ -99,6 +261,17 @@ func (api *API) getAgent(c *gin.Context) {
 		return
 	}
 
+	// Stalled collectors are reported with the metrics
+	if latest, err := api.service.GetLatestMetrics(ctx, agentID); err == nil && latest != nil {
+		agent.CollectorStalls = latest.CollectorStalls
+	}
+
+	if !known {
+		version, _ = service.Version(agent)
+		if resp.NotModified(version, service.AgentModified(agent)) {
+			return
+		}
+	}
 	resp.Success(agent)
 }
 
@@ -116,6 +289,26 @@ func (api *API) registerAgent(c *gin.Context) {
 	}
 
 	if err := api.service.RegisterAgent(ctx, &agent); err != nil {
+		if errors.Is(err, types.ErrAgentExists) {
+			resp.Error(http.StatusConflict, errors.New("agent id is registered to another tenant"))
+			return
+		}
+		if errors.Is(err, types.ErrForbidden) {
+			resp.Error(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, types.ErrAgentRetired) {
+			resp.Error(http.StatusGone, err)
+			return
+		}
+		if errors.Is(err, types.ErrAgentUnsupported) {
+			resp.Error(http.StatusUpgradeRequired, err)
+			return
+		}
+		if errors.Is(err, types.ErrErasurePending) {
+			resp.Error(http.StatusConflict, err)
+			return
+		}
 		api.logger.Error("Failed to register agent",
 			zap.Error(err),
 			zap.String("agent_id", agent.ID))
@@ -141,24 +334,28 @@ func (api *API) updateAgent(c *gin.Context) {
 	}
 
 	// Parse request body
-	var update struct {
-		Hostname string            `json:"hostname"`
-		Version  string            `json:"version"`
-		Status   types.AgentStatus `json:"status"`
-		Port     int               `json:"port"`
-		Tags     map[string]string `json:"tags"`
-	}
+	var update agentUpdateRequest
 
 	if err := c.ShouldBindJSON(&update); err != nil {
 		resp.BadRequest(fmt.Errorf("invalid update data: %w", err))
 		return
 	}
+	if update.Status == types.AgentStatusRetired {
+		resp.BadRequest(errors.New("agents are retired through decommission"))
+		return
+	}
+	for key := range update.Tags {
+		if key == "" || strings.Contains(key, "=") {
+			resp.BadRequest(fmt.Errorf("invalid tag key: %q", key))
+			return
+		}
+	}
 
 	// Get existing agent
 	agent, err := api.service.GetAgent(ctx, agentID)
 	if err != nil {
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
 			return
 		}
 		resp.InternalError(errors.New("failed to get agent"))
@@ -178,9 +375,16 @@ func (api *API) updateAgent(c *gin.Context) {
 	if update.Port > 0 {
 		agent.Port = update.Port
 	}
+	if update.Tags != nil {
+		agent.Tags = update.Tags
+	}
 
 	// Update agent
 	if err := api.service.UpdateAgent(ctx, agent); err != nil {
+		if errors.Is(err, types.ErrAgentRetired) {
+			resp.Error(http.StatusConflict, err)
+			return
+		}
 		api.logger.Error("Failed to update agent",
 			zap.Error(err),
 			zap.String("agent_id", agentID))
@@ -191,6 +395,199 @@ func (api *API) updateAgent(c *gin.Context) {
 	resp.Success(agent)
 }
 
+// deleteAgent handles agent deletion, online agents are refused
+func (api *API) deleteAgent(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if agentID == "" {
+		resp.BadRequest(errors.New("agent id is required"))
+		return
+	}
+
+	if err := api.service.DeleteAgent(ctx, agentID); err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentOnline) {
+			resp.Error(http.StatusConflict, errors.New("cannot delete online agent"))
+			return
+		}
+		api.logger.Error("Failed to delete agent",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to delete agent"))
+		return
+	}
+
+	resp.NoContent()
+}
+
+// decommissionAgent handles retiring an agent
+func (api *API) decommissionAgent(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if agentID == "" {
+		resp.BadRequest(errors.New("agent id is required"))
+		return
+	}
+
+	var req types.DecommissionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid decommission request: %w", err))
+			return
+		}
+	}
+
+	d, err := api.service.DecommissionAgent(ctx, agentID, &req)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentRetired) {
+			resp.Error(http.StatusConflict, errors.New("agent is already retired"))
+			return
+		}
+		api.logger.Error("Failed to decommission agent",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to decommission agent"))
+		return
+	}
+
+	resp.Created(d)
+}
+
+// getDecommission handles retrieving the decommission of an agent
+func (api *API) getDecommission(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	d, err := api.service.GetDecommission(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotRetired) {
+			resp.NotFound(errors.New("agent is not decommissioned"))
+			return
+		}
+		api.logger.Error("Failed to get decommission",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to get decommission"))
+		return
+	}
+
+	resp.Success(d)
+}
+
+// cancelDecommission handles reinstating a retired agent before it is purged
+func (api *API) cancelDecommission(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if err := api.service.CancelDecommission(ctx, agentID); err != nil {
+		if errors.Is(err, types.ErrAgentNotRetired) {
+			resp.NotFound(errors.New("agent is not decommissioned"))
+			return
+		}
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.Error(http.StatusGone, errors.New("agent was already purged"))
+			return
+		}
+		api.logger.Error("Failed to cancel decommission",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to cancel decommission"))
+		return
+	}
+
+	resp.NoContent()
+}
+
+// setMaintenance handles putting an agent into maintenance
+func (api *API) setMaintenance(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	var req types.MaintenanceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid maintenance request: %w", err))
+			return
+		}
+	}
+
+	m, err := api.service.SetMaintenance(ctx, agentID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrAgentNotFound):
+			resp.NotFound(types.ErrAgentNotFound)
+		case errors.Is(err, types.ErrForbidden):
+			resp.Error(http.StatusForbidden, err)
+		case errors.Is(err, types.ErrAgentRetired):
+			resp.Error(http.StatusConflict, types.ErrAgentRetired)
+		case errors.Is(err, types.ErrInvalidMaintenance):
+			resp.BadRequest(err)
+		default:
+			api.logger.Error("Failed to set agent maintenance",
+				zap.Error(err),
+				zap.String("agent_id", agentID))
+			resp.InternalError(errors.New("failed to set agent maintenance"))
+		}
+		return
+	}
+
+	resp.Success(m)
+}
+
+// endMaintenance handles ending the maintenance of an agent
+func (api *API) endMaintenance(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if err := api.service.EndMaintenance(ctx, agentID); err != nil {
+		switch {
+		case errors.Is(err, types.ErrAgentNotFound):
+			resp.NotFound(types.ErrAgentNotFound)
+		case errors.Is(err, types.ErrNotInMaintenance):
+			resp.NotFound(err)
+		case errors.Is(err, types.ErrForbidden):
+			resp.Error(http.StatusForbidden, err)
+		case errors.Is(err, types.ErrAgentRetired):
+			resp.Error(http.StatusConflict, types.ErrAgentRetired)
+		default:
+			api.logger.Error("Failed to end agent maintenance",
+				zap.Error(err),
+				zap.String("agent_id", agentID))
+			resp.InternalError(errors.New("failed to end agent maintenance"))
+		}
+		return
+	}
+
+	resp.NoContent()
+}
+
 // handleAgentHeartbeat handles agent heartbeat
 func (api *API) handleAgentHeartbeat(c *gin.Context) {
 	ctx, cancel := context.WithCancel(c.Request.Context())
@@ -201,7 +598,11 @@ func (api *API) handleAgentHeartbeat(c *gin.Context) {
 
 	if err := api.service.UpdateAgentStatus(ctx, agentID, types.AgentStatusOnline); err != nil {
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentRetired) {
+			resp.Error(http.StatusGone, types.ErrAgentRetired)
 			return
 		}
 		api.logger.Error("Failed to update agent status",
@@ -235,7 +636,7 @@ func (api *API) getAgentMetrics(c *gin.Context) {
 	metrics, err := api.service.GetAgentMetrics(ctx, agentID)
 	if err != nil {
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
 			return
 		}
 		api.logger.Error("Failed to get agent metrics",
@@ -264,11 +665,7 @@ func (api *API) sendCommand(c *gin.Context) {
 	}
 
 	// Parse command
-	var cmd struct {
-		Type    string          `json:"type" binding:"required"`
-		Timeout time.Duration   `json:"timeout"`
-		Payload json.RawMessage `json:"payload"`
-	}
+	var cmd commandRequest
 
 	if err := c.ShouldBindJSON(&cmd); err != nil {
 		resp.BadRequest(fmt.Errorf("invalid command format: %w", err))
@@ -301,7 +698,11 @@ func (api *API) sendCommand(c *gin.Context) {
 	// Send command
 	if err := api.service.SendCommand(ctx, agentID, command); err != nil {
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentOffline) {
+			resp.Error(http.StatusConflict, types.ErrAgentOffline)
 			return
 		}
 		api.logger.Error("Failed to send command",
@@ -317,3 +718,121 @@ func (api *API) sendCommand(c *gin.Context) {
 		"status":     "sent",
 	})
 }
+
+// requestCollection handles asking an agent to collect and report metrics
+// at once
+func (api *API) requestCollection(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	commandID, err := api.service.RequestCollection(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentOffline) {
+			resp.Error(http.StatusConflict, types.ErrAgentOffline)
+			return
+		}
+		api.logger.Error("Failed to request collection",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to request collection"))
+		return
+	}
+
+	resp.Accepted(gin.H{
+		"command_id": commandID,
+	})
+}
+
+// pullCommands handles agents polling their queued commands
+func (api *API) pullCommands(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	queued, err := api.service.PullCommands(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrForbidden) {
+			resp.Error(http.StatusForbidden, err)
+			return
+		}
+		api.logger.Error("Failed to pull commands",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to pull commands"))
+		return
+	}
+
+	if queued == nil {
+		queued = []types.QueuedCommand{}
+	}
+	resp.Success(queued)
+}
+
+// toFilter converts query parameters to agent filter
+func (q *agentQuery) toFilter() (*types.AgentFilter, error) {
+	filter := &types.AgentFilter{
+		Hostname: q.Hostname,
+		Limit:    q.Limit,
+		Offset:   q.Offset,
+	}
+
+	for _, status := range q.Statuses {
+		switch types.AgentStatus(status) {
+		case types.AgentStatusOnline, types.AgentStatusOffline, types.AgentStatusError, types.AgentStatusRetired,
+			types.AgentStatusExpected, types.AgentStatusMissing:
+			filter.Statuses = append(filter.Statuses, types.AgentStatus(status))
+		default:
+			return nil, fmt.Errorf("invalid status: %s", status)
+		}
+	}
+
+	for _, tag := range q.Tags {
+		key, value, _ := strings.Cut(tag, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag selector: %s", tag)
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		filter.Tags[key] = value
+	}
+
+	if q.Sort != "" {
+		if !slices.Contains(types.AgentSortFields, q.Sort) {
+			return nil, fmt.Errorf("invalid sort field: %s", q.Sort)
+		}
+		filter.SortBy = q.Sort
+	}
+
+	switch q.Order {
+	case "", "asc":
+	case "desc":
+		filter.SortDesc = true
+	default:
+		return nil, fmt.Errorf("invalid order: %s", q.Order)
+	}
+
+	if filter.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	} else if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	return filter, nil
+}
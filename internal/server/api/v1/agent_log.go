@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxAgentLogBatch is the largest number of log entries accepted per request
+const maxAgentLogBatch = 1000
+
+// agentLogQuery represents agent log query parameters
+type agentLogQuery struct {
+	Level     string `form:"level"`
+	Search    string `form:"search"`
+	StartTime string `form:"start_time"`
+	EndTime   string `form:"end_time"`
+	Limit     int    `form:"limit"`
+	Offset    int    `form:"offset"`
+}
+
+// agentLogPage represents a page of agent log entries
+type agentLogPage struct {
+	Entries []*types.AgentLogEntry `json:"entries"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// shipAgentLogs handles a batch of log entries shipped by an agent
+func (api *API) shipAgentLogs(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	var batch types.AgentLogBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid log batch: %w", err))
+		return
+	}
+	if len(batch.Entries) > maxAgentLogBatch {
+		resp.Error(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("log batch exceeds %d entries", maxAgentLogBatch))
+		return
+	}
+
+	if err := api.service.SaveAgentLogs(ctx, agentID, batch.Entries); err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		api.logger.Error("Failed to save agent logs",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to save agent logs"))
+		return
+	}
+
+	resp.Success(gin.H{
+		"accepted": len(batch.Entries),
+	})
+}
+
+// getAgentLogs handles querying the shipped logs of an agent
+func (api *API) getAgentLogs(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query agentLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+	filter.AgentID = c.Param("id")
+
+	// Fetch one extra row to detect further pages
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := api.service.QueryAgentLogs(ctx, filter)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled agent log request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+
+		api.logger.Error("Failed to query agent logs",
+			zap.Error(err),
+			zap.String("agent_id", filter.AgentID))
+		resp.InternalError(errors.New("failed to query agent logs"))
+		return
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	if entries == nil {
+		entries = []*types.AgentLogEntry{}
+	}
+
+	resp.Success(agentLogPage{
+		Entries: entries,
+		Limit:   limit,
+		Offset:  filter.Offset,
+		HasMore: hasMore,
+	})
+}
+
+// toFilter converts query parameters to agent log filter, level selects
+// entries at least as severe
+func (q *agentLogQuery) toFilter() (*types.AgentLogFilter, error) {
+	filter := &types.AgentLogFilter{
+		Search: q.Search,
+		Offset: q.Offset,
+		Limit:  q.Limit,
+	}
+
+	if q.Level != "" {
+		filter.Levels = types.AgentLogLevelsFrom(strings.ToLower(q.Level))
+		if filter.Levels == nil {
+			return nil, fmt.Errorf("invalid level: %s", q.Level)
+		}
+	}
+
+	if q.StartTime != "" {
+		t, err := utils.ParseTime(q.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_time format: %v", err)
+		}
+		filter.StartTime = t
+	}
+
+	if q.EndTime != "" {
+		t, err := utils.ParseTime(q.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time format: %v", err)
+		}
+		filter.EndTime = t
+	}
+
+	if !filter.StartTime.IsZero() && !filter.EndTime.IsZero() && filter.EndTime.Before(filter.StartTime) {
+		return nil, errors.New("end_time must be after start_time")
+	}
+
+	if filter.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	} else if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	return filter, nil
+}
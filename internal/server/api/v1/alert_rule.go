@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AlertRuleAPI represents alert rule API
+type AlertRuleAPI interface {
+	RegisterAlertRuleRoutes(r *gin.RouterGroup)
+}
+
+// _ implements AlertRuleAPI
+var _ AlertRuleAPI = (*API)(nil)
+
+// RegisterAlertRuleRoutes registers alert rule routes
+func (api *API) RegisterAlertRuleRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.GET("/alert-rules", api.getAlertRules)
+	admin.GET("/alert-rules/:name", api.getAlertRule)
+	admin.PUT("/alert-rules/:name", api.applyAlertRule)
+	admin.DELETE("/alert-rules/:name", api.deleteAlertRule)
+}
+
+// getAlertRules handles retrieving the configured and declared alert rules
+func (api *API) getAlertRules(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	rules, err := api.service.GetAlertRules(ctx)
+	if err != nil {
+		api.logger.Error("Failed to get alert rules", zap.Error(err))
+		resp.InternalError(errors.New("failed to get alert rules"))
+		return
+	}
+
+	resp.Success(rules)
+}
+
+// getAlertRule handles retrieving an alert rule
+func (api *API) getAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	rule, err := api.service.GetAlertRule(ctx, c.Param("name"))
+	if err != nil {
+		if errors.Is(err, types.ErrAlertRuleNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to get alert rule", zap.Error(err))
+		resp.InternalError(errors.New("failed to get alert rule"))
+		return
+	}
+
+	resp.Success(rule)
+}
+
+// applyAlertRule handles creating or replacing an alert rule declared
+// through the API
+func (api *API) applyAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var rule types.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid alert rule data: %w", err))
+		return
+	}
+	name := c.Param("name")
+	if rule.Name != "" && rule.Name != name {
+		resp.BadRequest(fmt.Errorf("%w: name %q does not match the path", types.ErrInvalidAlertRule, rule.Name))
+		return
+	}
+	rule.Name = name
+
+	result, err := api.service.ApplyAlertRule(ctx, &rule)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrInvalidAlertRule):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrAlertRuleConfigured):
+			resp.Error(http.StatusConflict, err)
+		default:
+			api.logger.Error("Failed to apply alert rule",
+				zap.Error(err),
+				zap.String("rule", name))
+			resp.InternalError(errors.New("failed to apply alert rule"))
+		}
+		return
+	}
+
+	resp.Success(result)
+}
+
+// deleteAlertRule handles deleting an alert rule declared through the API
+func (api *API) deleteAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	name := c.Param("name")
+	if err := api.service.DeleteAlertRule(ctx, name); err != nil {
+		switch {
+		case errors.Is(err, types.ErrAlertRuleNotFound):
+			resp.NotFound(err)
+		case errors.Is(err, types.ErrAlertRuleConfigured):
+			resp.Error(http.StatusConflict, err)
+		default:
+			api.logger.Error("Failed to delete alert rule",
+				zap.Error(err),
+				zap.String("rule", name))
+			resp.InternalError(errors.New("failed to delete alert rule"))
+		}
+		return
+	}
+
+	resp.NoContent()
+}
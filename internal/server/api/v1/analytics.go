@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AnalyticsAPI represents analytics API
+type AnalyticsAPI interface {
+	RegisterAnalyticsRoutes(r *gin.RouterGroup)
+}
+
+// _ implements AnalyticsAPI
+var _ AnalyticsAPI = (*API)(nil)
+
+// topQuery represents top-N analytics query parameters
+type topQuery struct {
+	Metric    string `form:"metric"`
+	Window    string `form:"window"`
+	StartTime string `form:"start_time"`
+	EndTime   string `form:"end_time"`
+	Limit     int    `form:"limit"`
+}
+
+// RegisterAnalyticsRoutes registers analytics routes
+func (api *API) RegisterAnalyticsRoutes(r *gin.RouterGroup) {
+	analytics := r.Group("/analytics")
+	{
+		analytics.GET("/top/agents", api.getTopAgents)
+		analytics.GET("/top/interfaces", api.getTopInterfaces)
+	}
+}
+
+// getTopAgents handles ranking agents by a metric
+func (api *API) getTopAgents(c *gin.Context) {
+	api.queryTop(c, types.TopScopeAgents)
+}
+
+// getTopInterfaces handles ranking interfaces by a metric
+func (api *API) getTopInterfaces(c *gin.Context) {
+	api.queryTop(c, types.TopScopeInterfaces)
+}
+
+// queryTop parses the query and writes the top agents or interfaces
+func (api *API) queryTop(c *gin.Context, scope types.TopScope) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var params topQuery
+	if err := c.ShouldBindQuery(&params); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	query, err := params.toQuery(scope)
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	result, err := api.service.GetTop(ctx, query)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled analytics request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+
+		api.logger.Error("Failed to get top analytics",
+			zap.Error(err),
+			zap.String("scope", string(scope)),
+			zap.String("metric", string(query.Metric)))
+		resp.InternalError(errors.New("failed to get analytics"))
+		return
+	}
+
+	resp.Success(result)
+}
+
+// toQuery converts query parameters to a top-N query, the range defaults to
+// the window, or the last 24 hours, before end_time or now
+func (q *topQuery) toQuery(scope types.TopScope) (*types.TopQuery, error) {
+	query := &types.TopQuery{
+		Scope:  scope,
+		Metric: types.TopMetric(q.Metric),
+		Limit:  q.Limit,
+	}
+
+	switch query.Metric {
+	case "":
+		query.Metric = types.TopMetricBandwidth
+	case types.TopMetricBandwidth, types.TopMetricErrorRate, types.TopMetricIPChanges:
+	default:
+		return nil, fmt.Errorf("invalid metric: %s", q.Metric)
+	}
+
+	query.EndTime = time.Now()
+	if q.EndTime != "" {
+		t, err := utils.ParseTime(q.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time format: %v", err)
+		}
+		query.EndTime = t
+	}
+
+	if q.StartTime != "" {
+		if q.Window != "" {
+			return nil, errors.New("window and start_time are mutually exclusive")
+		}
+		t, err := utils.ParseTime(q.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_time format: %v", err)
+		}
+		query.StartTime = t
+	} else {
+		window := 24 * time.Hour
+		if q.Window != "" {
+			d, err := time.ParseDuration(q.Window)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid window: %s", q.Window)
+			}
+			window = d
+		}
+		query.StartTime = query.EndTime.Add(-window)
+	}
+
+	if query.EndTime.Before(query.StartTime) {
+		return nil, errors.New("end_time must be after start_time")
+	}
+	if query.EndTime.Sub(query.StartTime) > 90*24*time.Hour {
+		return nil, errors.New("time range cannot exceed 90 days")
+	}
+
+	if query.Limit < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if query.Limit == 0 {
+		query.Limit = 10
+	} else if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	return query, nil
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AnnotationAPI represents annotation API
+type AnnotationAPI interface {
+	RegisterAnnotationRoutes(r *gin.RouterGroup)
+}
+
+// _ implements AnnotationAPI
+var _ AnnotationAPI = (*API)(nil)
+
+// annotationQuery represents annotation query parameters
+type annotationQuery struct {
+	Types     []string `form:"type"`
+	AgentIDs  []string `form:"agent_id"`
+	Tags      []string `form:"tag"`
+	StartTime string   `form:"start_time"`
+	EndTime   string   `form:"end_time"`
+	Limit     int      `form:"limit"`
+	Offset    int      `form:"offset"`
+}
+
+// annotationPage represents a page of annotations
+type annotationPage struct {
+	Annotations []*types.Annotation `json:"annotations"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+	HasMore     bool                `json:"has_more"`
+}
+
+// RegisterAnnotationRoutes registers annotation routes
+func (api *API) RegisterAnnotationRoutes(r *gin.RouterGroup) {
+	annotations := r.Group("/annotations")
+	{
+		annotations.GET("", api.getAnnotations)
+		annotations.POST("", api.createAnnotation)
+	}
+}
+
+// getAnnotations handles querying annotations
+func (api *API) getAnnotations(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query annotationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	// Fetch one extra row to detect further pages
+	limit := filter.Limit
+	filter.Limit++
+
+	annotations, err := api.service.GetAnnotations(ctx, filter)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled annotations request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+		if errors.Is(err, types.ErrInvalidAnnotation) {
+			resp.BadRequest(err)
+			return
+		}
+
+		api.logger.Error("Failed to query annotations", zap.Error(err))
+		resp.InternalError(errors.New("failed to query annotations"))
+		return
+	}
+
+	hasMore := len(annotations) > limit
+	if hasMore {
+		annotations = annotations[:limit]
+	}
+	if annotations == nil {
+		annotations = []*types.Annotation{}
+	}
+
+	resp.Success(annotationPage{
+		Annotations: annotations,
+		Limit:       limit,
+		Offset:      filter.Offset,
+		HasMore:     hasMore,
+	})
+}
+
+// createAnnotation handles creating an annotation, e.g. of a deployment
+func (api *API) createAnnotation(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var a types.Annotation
+	if err := c.ShouldBindJSON(&a); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid annotation: %w", err))
+		return
+	}
+
+	if err := api.service.CreateAnnotation(ctx, &a); err != nil {
+		switch {
+		case errors.Is(err, types.ErrInvalidAnnotation):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrForbidden):
+			resp.Error(http.StatusForbidden, err)
+		case errors.Is(err, types.ErrAgentNotFound):
+			resp.NotFound(types.ErrAgentNotFound)
+		default:
+			api.logger.Error("Failed to create annotation",
+				zap.Error(err),
+				zap.String("agent_id", a.AgentID))
+			resp.InternalError(errors.New("failed to create annotation"))
+		}
+		return
+	}
+
+	resp.Created(a)
+}
+
+// toFilter converts query parameters to annotation filter
+func (q *annotationQuery) toFilter() (*types.AnnotationFilter, error) {
+	filter := &types.AnnotationFilter{
+		AgentIDs: q.AgentIDs,
+		Tags:     q.Tags,
+		Offset:   q.Offset,
+		Limit:    q.Limit,
+	}
+	for _, t := range q.Types {
+		filter.Types = append(filter.Types, types.AnnotationType(t))
+	}
+
+	if q.StartTime != "" {
+		t, err := utils.ParseTime(q.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_time format: %v", err)
+		}
+		filter.StartTime = t
+	}
+
+	if q.EndTime != "" {
+		t, err := utils.ParseTime(q.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time format: %v", err)
+		}
+		filter.EndTime = t
+	}
+
+	if !filter.StartTime.IsZero() && !filter.EndTime.IsZero() && filter.EndTime.Before(filter.StartTime) {
+		return nil, errors.New("end_time must be after start_time")
+	}
+
+	if filter.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	} else if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	return filter, nil
+}
@@ -32,8 +32,32 @@ func NewAPI(cfg *config.Config, svc *service.Service, logger *zap.Logger) *API {
 func (api *API) RegisterRoutes(r *gin.RouterGroup) {
 	// Agents endpoints
 	api.RegisterAgentRoutes(r)
+	// Command endpoints
+	api.RegisterCommandRoutes(r)
 	// Metrics endpoints
 	api.RegisterMetricsRoutes(r)
+	// IP change endpoints
+	api.RegisterIPChangeRoutes(r)
+	// Alert rule endpoints
+	api.RegisterAlertRuleRoutes(r)
+	// Analytics endpoints
+	api.RegisterAnalyticsRoutes(r)
+	// Grafana JSON datasource endpoints
+	api.RegisterGrafanaRoutes(r)
+	// Administration endpoints
+	api.RegisterAdminRoutes(r)
+	// Expected inventory endpoints
+	api.RegisterInventoryRoutes(r)
+	// Data erasure endpoints
+	api.RegisterErasureRoutes(r)
+	// Tenant endpoints
+	api.RegisterTenantRoutes(r)
+	// User and role endpoints
+	api.RegisterUserRoutes(r)
+	// Audit log endpoints
+	api.RegisterAuditRoutes(r)
+	// Annotation endpoints
+	api.RegisterAnnotationRoutes(r)
 	// Health check
 	r.GET("/health", api.healthCheck)
 }
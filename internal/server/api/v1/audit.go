@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AuditAPI represents audit log API
+type AuditAPI interface {
+	RegisterAuditRoutes(r *gin.RouterGroup)
+}
+
+// _ implements AuditAPI
+var _ AuditAPI = (*API)(nil)
+
+// auditQuery represents audit log query parameters
+type auditQuery struct {
+	Actor     string   `form:"actor"`
+	Actions   []string `form:"action"`
+	Resource  string   `form:"resource"`
+	StartTime string   `form:"start_time"`
+	EndTime   string   `form:"end_time"`
+	Limit     int      `form:"limit"`
+	Offset    int      `form:"offset"`
+}
+
+// auditPage represents a page of audit entries
+type auditPage struct {
+	Entries []*types.AuditEntry `json:"entries"`
+	Limit   int                 `json:"limit"`
+	Offset  int                 `json:"offset"`
+	HasMore bool                `json:"has_more"`
+}
+
+// RegisterAuditRoutes registers audit log routes
+func (api *API) RegisterAuditRoutes(r *gin.RouterGroup) {
+	r.GET("/audit", api.getAuditEntries)
+}
+
+// getAuditEntries handles querying the audit log
+func (api *API) getAuditEntries(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query auditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	// Fetch one extra row to detect further pages
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := api.service.QueryAudit(ctx, filter)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled audit request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+
+		api.logger.Error("Failed to query audit log", zap.Error(err))
+		resp.InternalError(errors.New("failed to query audit log"))
+		return
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	if entries == nil {
+		entries = []*types.AuditEntry{}
+	}
+
+	resp.Success(auditPage{
+		Entries: entries,
+		Limit:   limit,
+		Offset:  filter.Offset,
+		HasMore: hasMore,
+	})
+}
+
+// toFilter converts query parameters to audit filter
+func (q *auditQuery) toFilter() (*types.AuditFilter, error) {
+	filter := &types.AuditFilter{
+		Actor:    q.Actor,
+		Actions:  q.Actions,
+		Resource: q.Resource,
+		Offset:   q.Offset,
+		Limit:    q.Limit,
+	}
+
+	if q.StartTime != "" {
+		t, err := utils.ParseTime(q.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_time format: %v", err)
+		}
+		filter.StartTime = t
+	}
+
+	if q.EndTime != "" {
+		t, err := utils.ParseTime(q.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time format: %v", err)
+		}
+		filter.EndTime = t
+	}
+
+	if !filter.StartTime.IsZero() && !filter.EndTime.IsZero() && filter.EndTime.Before(filter.StartTime) {
+		return nil, errors.New("end_time must be after start_time")
+	}
+
+	if filter.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	} else if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	return filter, nil
+}
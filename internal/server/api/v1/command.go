@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxCommandWait bounds how long a request waits for the result of a command
+const maxCommandWait = time.Minute
+
+// CommandAPI represents command API
+type CommandAPI interface {
+	RegisterCommandRoutes(r *gin.RouterGroup)
+}
+
+// _ implements CommandAPI
+var _ CommandAPI = (*API)(nil)
+
+// RegisterCommandRoutes registers command routes
+func (api *API) RegisterCommandRoutes(r *gin.RouterGroup) {
+	commands := r.Group("/commands")
+	{
+		commands.GET("/:id/result", api.getCommandResult)
+	}
+}
+
+// getCommandResult handles retrieving the result of a command. With wait,
+// the request blocks until the command ended or the wait passed, commands
+// still in progress are answered 202 with their current status.
+func (api *API) getCommandResult(c *gin.Context) {
+	resp := response.New(c, api.logger)
+
+	var wait time.Duration
+	if v := c.Query("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			resp.BadRequest(fmt.Errorf("invalid wait: %s", v))
+			return
+		}
+		wait = min(d, maxCommandWait)
+	}
+
+	// The wait may outlast the write timeout of the server
+	if wait > 0 {
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	commandID := c.Param("id")
+	result, err := api.service.GetCommandResult(ctx, commandID, wait)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled command result request")
+			return
+		}
+		if errors.Is(err, types.ErrCommandNotFound) {
+			resp.NotFound(types.ErrCommandNotFound)
+			return
+		}
+		api.logger.Error("Failed to get command result",
+			zap.Error(err),
+			zap.String("command_id", commandID))
+		resp.InternalError(errors.New("failed to get command result"))
+		return
+	}
+
+	if !result.Status.Finished() {
+		resp.Accepted(result)
+		return
+	}
+	resp.Success(result)
+}
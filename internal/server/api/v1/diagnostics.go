@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxDiagnosticsSize is the largest diagnostics bundle accepted from an agent
+const maxDiagnosticsSize = 32 << 20
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// requestDiagnostics handles asking an agent for a diagnostics bundle
+func (api *API) requestDiagnostics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	commandID, err := api.service.RequestDiagnostics(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrAgentOffline) {
+			resp.Error(http.StatusConflict, types.ErrAgentOffline)
+			return
+		}
+		api.logger.Error("Failed to request diagnostics",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to request diagnostics"))
+		return
+	}
+
+	resp.Accepted(gin.H{
+		"command_id": commandID,
+	})
+}
+
+// uploadDiagnostics handles a diagnostics bundle uploaded by an agent, the
+// body is the gzipped tar archive
+func (api *API) uploadDiagnostics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if c.Request.ContentLength > maxDiagnosticsSize {
+		resp.Error(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("diagnostics bundle exceeds %d bytes", maxDiagnosticsSize))
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxDiagnosticsSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("diagnostics bundle exceeds %d bytes", maxDiagnosticsSize))
+			return
+		}
+		resp.BadRequest(errors.New("failed to read diagnostics bundle"))
+		return
+	}
+	if !bytes.HasPrefix(content, gzipMagic) {
+		resp.BadRequest(errors.New("diagnostics bundle must be a gzipped archive"))
+		return
+	}
+
+	d, err := api.service.SaveDiagnostics(ctx, agentID, c.Query("command_id"), content)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		api.logger.Error("Failed to save diagnostics",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to save diagnostics"))
+		return
+	}
+
+	resp.Created(d)
+}
+
+// listDiagnostics handles listing the diagnostics bundles of an agent
+func (api *API) listDiagnostics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	bundles, err := api.service.ListDiagnostics(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		api.logger.Error("Failed to list diagnostics",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to list diagnostics"))
+		return
+	}
+
+	resp.Success(bundles)
+}
+
+// downloadDiagnostics handles downloading the newest diagnostics bundle of an
+// agent, or the one given by the bundle query parameter
+func (api *API) downloadDiagnostics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	d, content, err := api.service.GetDiagnostics(ctx, agentID, c.Query("bundle"))
+	if err != nil {
+		if errors.Is(err, types.ErrNoDiagnostics) {
+			resp.NotFound(errors.New("diagnostics not found"))
+			return
+		}
+		api.logger.Error("Failed to get diagnostics",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to get diagnostics"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=diagnostics-%s-%s.tar.gz",
+		d.AgentID, d.CreatedAt.UTC().Format("20060102T150405Z")))
+	c.Data(http.StatusOK, "application/gzip", content)
+}
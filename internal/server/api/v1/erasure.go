@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ErasureAPI represents data erasure API
+type ErasureAPI interface {
+	RegisterErasureRoutes(r *gin.RouterGroup)
+}
+
+// _ implements ErasureAPI
+var _ ErasureAPI = (*API)(nil)
+
+// RegisterErasureRoutes registers data erasure routes
+func (api *API) RegisterErasureRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.POST("/erasures", api.eraseData)
+	admin.GET("/erasures", api.getErasures)
+	admin.GET("/erasures/:id", api.getErasure)
+}
+
+// eraseData handles erasing all data of an agent or hostname
+func (api *API) eraseData(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req types.ErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid erasure request: %w", err))
+		return
+	}
+	req.RequestedBy = c.GetString("actor")
+
+	e, err := api.service.EraseData(ctx, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrInvalidErasure):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrAgentNotFound):
+			resp.NotFound(types.ErrAgentNotFound)
+		case errors.Is(err, types.ErrErasurePending):
+			resp.Error(http.StatusConflict, err)
+		default:
+			api.logger.Error("Failed to erase data",
+				zap.Error(err),
+				zap.String("agent_id", req.AgentID),
+				zap.String("hostname", req.Hostname))
+			resp.InternalError(errors.New("failed to erase data"))
+		}
+		return
+	}
+
+	resp.Accepted(e)
+}
+
+// getErasures handles listing erasures
+func (api *API) getErasures(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	erasures, err := api.service.ListErasures(ctx)
+	if err != nil {
+		api.logger.Error("Failed to list erasures", zap.Error(err))
+		resp.InternalError(errors.New("failed to list erasures"))
+		return
+	}
+
+	resp.Success(erasures)
+}
+
+// getErasure handles retrieving an erasure and its report
+func (api *API) getErasure(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	e, err := api.service.GetErasure(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, types.ErrErasureNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to get erasure",
+			zap.Error(err),
+			zap.String("erasure_id", c.Param("id")))
+		resp.InternalError(errors.New("failed to get erasure"))
+		return
+	}
+
+	resp.Success(e)
+}
@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GrafanaAPI represents Grafana JSON datasource API
+type GrafanaAPI interface {
+	RegisterGrafanaRoutes(r *gin.RouterGroup)
+}
+
+// _ implements GrafanaAPI
+var _ GrafanaAPI = (*API)(nil)
+
+// RegisterGrafanaRoutes registers the endpoints of the Grafana SimpleJSON
+// and Infinity datasources, the responses are not enveloped
+func (api *API) RegisterGrafanaRoutes(r *gin.RouterGroup) {
+	grafana := r.Group("/grafana")
+	{
+		grafana.GET("", api.testGrafana)
+		grafana.POST("/search", api.searchGrafana)
+		grafana.POST("/query", api.queryGrafana)
+		grafana.POST("/annotations", api.grafanaAnnotations)
+	}
+}
+
+// testGrafana handles the datasource connection test
+func (api *API) testGrafana(c *gin.Context) {
+	response.New(c, api.logger).Custom(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// searchGrafana handles listing the queryable targets
+func (api *API) searchGrafana(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var search types.GrafanaSearch
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&search); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid search: %v", err))
+			return
+		}
+	}
+
+	targets, err := api.service.SearchGrafanaTargets(ctx, &search)
+	if err != nil {
+		api.grafanaError(resp, err, "Failed to search grafana targets")
+		return
+	}
+
+	if targets == nil {
+		targets = []string{}
+	}
+	resp.Custom(http.StatusOK, targets)
+}
+
+// queryGrafana handles querying time series
+func (api *API) queryGrafana(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query types.GrafanaQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query: %v", err))
+		return
+	}
+	if err := validateGrafanaRange(query.Range); err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	series, err := api.service.QueryGrafana(ctx, &query)
+	if err != nil {
+		api.grafanaError(resp, err, "Failed to query grafana series")
+		return
+	}
+
+	resp.Custom(http.StatusOK, series)
+}
+
+// grafanaAnnotations handles querying annotations
+func (api *API) grafanaAnnotations(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query types.GrafanaAnnotationQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid annotation query: %v", err))
+		return
+	}
+	if query.Annotation == nil {
+		resp.BadRequest(errors.New("annotation is required"))
+		return
+	}
+	if err := validateGrafanaRange(query.Range); err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	annotations, err := api.service.GetGrafanaAnnotations(ctx, &query)
+	if err != nil {
+		api.grafanaError(resp, err, "Failed to get grafana annotations")
+		return
+	}
+
+	resp.Custom(http.StatusOK, annotations)
+}
+
+// grafanaError writes the response of a failed datasource request
+func (api *API) grafanaError(resp *response.Handler, err error, msg string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		api.logger.Info("Client canceled grafana request")
+	case errors.Is(err, context.DeadlineExceeded):
+		resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+	case errors.Is(err, types.ErrInvalidGrafanaQuery):
+		resp.BadRequest(err)
+	default:
+		api.logger.Error(msg, zap.Error(err))
+		resp.InternalError(errors.New("failed to query grafana datasource"))
+	}
+}
+
+// validateGrafanaRange checks the range of a datasource query, it is bounded
+// like metric queries
+func validateGrafanaRange(r types.GrafanaRange) error {
+	if r.From.IsZero() || r.To.IsZero() {
+		return errors.New("range is required")
+	}
+	if r.From.After(r.To) {
+		return errors.New("range from must be before to")
+	}
+	if r.To.Sub(r.From) > 30*24*time.Hour {
+		return errors.New("time range cannot exceed 30 days")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// InventoryAPI represents expected agent inventory API
+type InventoryAPI interface {
+	RegisterInventoryRoutes(r *gin.RouterGroup)
+}
+
+// _ implements InventoryAPI
+var _ InventoryAPI = (*API)(nil)
+
+// RegisterInventoryRoutes registers expected agent inventory routes
+func (api *API) RegisterInventoryRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.GET("/inventory", api.getInventory)
+	admin.PUT("/inventory", api.setInventory)
+	admin.GET("/inventory/status", api.getInventoryStatus)
+}
+
+// getInventory handles retrieving the inventory declared through the API
+func (api *API) getInventory(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	inv, err := api.service.GetInventory(ctx)
+	if err != nil {
+		api.logger.Error("Failed to get inventory", zap.Error(err))
+		resp.InternalError(errors.New("failed to get inventory"))
+		return
+	}
+
+	resp.Success(inv)
+}
+
+// setInventory handles replacing the inventory declared through the API
+func (api *API) setInventory(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var inv types.Inventory
+	if err := c.ShouldBindJSON(&inv); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid inventory data: %w", err))
+		return
+	}
+
+	if err := api.service.SetInventory(ctx, &inv); err != nil {
+		if errors.Is(err, types.ErrInvalidInventory) {
+			resp.BadRequest(err)
+			return
+		}
+		api.logger.Error("Failed to set inventory", zap.Error(err))
+		resp.InternalError(errors.New("failed to set inventory"))
+		return
+	}
+
+	resp.Success(inv)
+}
+
+// getInventoryStatus handles retrieving the state of the expected agents
+// and counts
+func (api *API) getInventoryStatus(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	resp.Success(api.service.GetInventoryStatus(ctx))
+}
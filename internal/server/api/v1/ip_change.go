@@ -0,0 +1,292 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// IPChangeAPI represents IP change API
+type IPChangeAPI interface {
+	RegisterIPChangeRoutes(r *gin.RouterGroup)
+}
+
+// _ implements IPChangeAPI
+var _ IPChangeAPI = (*API)(nil)
+
+// ipChangeQuery represents IP change query parameters
+type ipChangeQuery struct {
+	AgentIDs   []string `form:"agent_ids"`
+	StartTime  string   `form:"start_time"`
+	EndTime    string   `form:"end_time"`
+	Interfaces []string `form:"interface"`
+	Versions   []string `form:"version"`
+	Actions    []string `form:"action"`
+	External   string   `form:"external"`
+	Limit      int      `form:"limit"`
+	Offset     int      `form:"offset"`
+}
+
+// RegisterIPChangeRoutes registers IP change routes
+func (api *API) RegisterIPChangeRoutes(r *gin.RouterGroup) {
+	r.GET("/ip-changes", api.getIPChanges)
+	r.GET("/agents/:id/ip-changes", api.getAgentIPChanges)
+	r.GET("/agents/:id/ip-changes/summary", api.getIPChangeSummary)
+	r.GET("/agents/:id/ip-changes/stats", api.getIPChangeStats)
+
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.GET("/ip-change-windows", api.getIPChangeWindows)
+	admin.PUT("/ip-change-windows", api.setIPChangeWindows)
+}
+
+// getIPChanges handles retrieving IP changes across agents
+func (api *API) getIPChanges(c *gin.Context) {
+	api.queryIPChanges(c, "")
+}
+
+// getAgentIPChanges handles retrieving IP changes of an agent
+func (api *API) getAgentIPChanges(c *gin.Context) {
+	agentID := c.Param("id")
+	if agentID == "" {
+		response.New(c, api.logger).BadRequest(errors.New("agent id is required"))
+		return
+	}
+	api.queryIPChanges(c, agentID)
+}
+
+// queryIPChanges parses filters and writes a page of IP changes
+func (api *API) queryIPChanges(c *gin.Context, agentID string) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var query ipChangeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid query parameters: %w", err))
+		return
+	}
+
+	filter, err := query.toFilter()
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	// Fetch one extra row to detect further pages
+	limit := filter.Limit
+	filter.Limit++
+
+	changes, err := api.service.GetIPChanges(ctx, agentID, filter)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			api.logger.Info("Client canceled IP changes request")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
+			return
+		}
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+
+		api.logger.Error("Failed to get IP changes",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to get IP changes"))
+		return
+	}
+
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	if changes == nil {
+		changes = []*types.IPChange{}
+	}
+
+	resp.Success(gin.H{
+		"changes":  changes,
+		"limit":    limit,
+		"offset":   filter.Offset,
+		"has_more": hasMore,
+	})
+}
+
+// getIPChangeSummary handles retrieving the IP change summary of an agent
+func (api *API) getIPChangeSummary(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if agentID == "" {
+		resp.BadRequest(errors.New("agent id is required"))
+		return
+	}
+
+	summary, err := api.service.GetIPChangeSummary(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		api.logger.Error("Failed to get IP change summary",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to get IP change summary"))
+		return
+	}
+
+	resp.Success(summary)
+}
+
+// getIPChangeStats handles retrieving the IP change patterns of an agent
+func (api *API) getIPChangeStats(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	agentID := c.Param("id")
+	if agentID == "" {
+		resp.BadRequest(errors.New("agent id is required"))
+		return
+	}
+
+	stats, err := api.service.AnalyzeChangePatterns(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		api.logger.Error("Failed to analyze IP changes",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+		resp.InternalError(errors.New("failed to analyze IP changes"))
+		return
+	}
+
+	resp.Success(stats)
+}
+
+// toFilter converts query parameters to IP change filter
+func (q *ipChangeQuery) toFilter() (*types.IPChangeFilter, error) {
+	filter := &types.IPChangeFilter{
+		AgentIDs:   q.AgentIDs,
+		Interfaces: q.Interfaces,
+		Actions:    q.Actions,
+		Offset:     q.Offset,
+		Limit:      q.Limit,
+	}
+
+	if q.StartTime != "" {
+		t, err := utils.ParseTime(q.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_time format: %v", err)
+		}
+		filter.StartTime = t
+	}
+
+	if q.EndTime != "" {
+		t, err := utils.ParseTime(q.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time format: %v", err)
+		}
+		filter.EndTime = t
+	}
+
+	if !filter.StartTime.IsZero() && !filter.EndTime.IsZero() {
+		if filter.EndTime.Before(filter.StartTime) {
+			return nil, errors.New("end_time must be after start_time")
+		}
+		if filter.EndTime.Sub(filter.StartTime) > 90*24*time.Hour {
+			return nil, errors.New("time range cannot exceed 90 days")
+		}
+	}
+
+	for _, v := range q.Versions {
+		switch types.IPVersion(v) {
+		case types.IPv4, types.IPv6:
+			filter.Versions = append(filter.Versions, types.IPVersion(v))
+		default:
+			return nil, fmt.Errorf("invalid version: %s", v)
+		}
+	}
+
+	if q.External != "" {
+		external, err := strconv.ParseBool(q.External)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external value: %s", q.External)
+		}
+		filter.IsExternal = &external
+	}
+
+	if filter.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	} else if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	return filter, nil
+}
+
+// getIPChangeWindows handles retrieving the expected IP change windows
+// declared through the API
+func (api *API) getIPChangeWindows(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	windows, err := api.service.GetIPChangeWindows(ctx)
+	if err != nil {
+		api.logger.Error("Failed to get IP change windows", zap.Error(err))
+		resp.InternalError(errors.New("failed to get ip change windows"))
+		return
+	}
+
+	resp.Success(windows)
+}
+
+// setIPChangeWindows handles replacing the expected IP change windows
+// declared through the API
+func (api *API) setIPChangeWindows(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var windows []types.IPChangeWindow
+	if err := c.ShouldBindJSON(&windows); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid ip change windows data: %w", err))
+		return
+	}
+
+	if err := api.service.SetIPChangeWindows(ctx, windows); err != nil {
+		if errors.Is(err, types.ErrInvalidIPWindow) {
+			resp.BadRequest(err)
+			return
+		}
+		api.logger.Error("Failed to set IP change windows", zap.Error(err))
+		resp.InternalError(errors.New("failed to set ip change windows"))
+		return
+	}
+
+	resp.Success(windows)
+}
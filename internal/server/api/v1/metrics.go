@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
 	"time"
 	"wameter/internal/server/api/response"
+	"wameter/internal/server/remotewrite"
 	"wameter/internal/server/service"
 	"wameter/internal/types"
 	"wameter/internal/utils"
@@ -30,6 +34,8 @@ func (api *API) RegisterMetricsRoutes(r *gin.RouterGroup) {
 	metrics := r.Group(api.config.Server.MetricsPath)
 	{
 		metrics.POST("", api.saveMetrics)
+		metrics.POST("/backfill", api.backfillMetrics)
+		metrics.POST("/remote_write", api.remoteWrite)
 		metrics.GET("", api.getMetrics)
 		metrics.GET("/latest", api.getLatestMetrics)
 		metrics.GET("/export", api.exportMetrics)
@@ -45,6 +51,12 @@ func (api *API) saveMetrics(c *gin.Context) {
 
 	var data types.MetricsData
 	if err := c.ShouldBindJSON(&data); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("request body exceeds %d bytes", maxErr.Limit))
+			return
+		}
 		api.logger.Error("Invalid metrics data",
 			zap.Error(err),
 			zap.String("client_ip", c.ClientIP()))
@@ -71,6 +83,37 @@ func (api *API) saveMetrics(c *gin.Context) {
 				zap.String("agent_id", data.AgentID))
 			return
 		}
+		if errors.Is(err, types.ErrAgentNotFound) {
+			resp.NotFound(types.ErrAgentNotFound)
+			return
+		}
+		if errors.Is(err, types.ErrForbidden) {
+			resp.Error(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, types.ErrAgentRetired) || errors.Is(err, types.ErrAgentErased) {
+			resp.Error(http.StatusGone, err)
+			return
+		}
+		if errors.Is(err, types.ErrInvalidMetrics) {
+			resp.BadRequest(err)
+			return
+		}
+		if errors.Is(err, types.ErrDeltaBaseMismatch) {
+			// The agent resends the report in full
+			resp.Error(http.StatusConflict, err)
+			return
+		}
+		if quotaError(c, resp, err) {
+			return
+		}
+		if errors.Is(err, types.ErrIngestQueueFull) || errors.Is(err, types.ErrIngestClosed) {
+			// Tell clients when to retry, the queue drains at least once per flush interval
+			retry := max(1, int(math.Ceil(api.config.Ingest.FlushInterval.Seconds())))
+			c.Header("Retry-After", strconv.Itoa(retry))
+			resp.Error(http.StatusServiceUnavailable, err)
+			return
+		}
 
 		api.logger.Error("Failed to save metrics",
 			zap.Error(err),
@@ -83,6 +126,148 @@ func (api *API) saveMetrics(c *gin.Context) {
 	resp.Success(gin.H{"status": "success"})
 }
 
+// quotaError responds to ingestion refused by an agent quota and reports
+// whether err was one. Throttled agents get 429 with Retry-After, agents
+// over their storage quota 507.
+func quotaError(c *gin.Context, resp *response.Handler, err error) bool {
+	var qerr *types.QuotaError
+	if !errors.As(err, &qerr) {
+		return false
+	}
+	if errors.Is(err, types.ErrIngestThrottled) {
+		c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(qerr.RetryAfter.Seconds())))))
+		resp.Error(http.StatusTooManyRequests, err)
+		return true
+	}
+	resp.Error(http.StatusInsufficientStorage, err)
+	return true
+}
+
+// backfillRequest represents a historical metrics import request
+type backfillRequest struct {
+	Metrics []*types.MetricsData `json:"metrics" binding:"required"`
+}
+
+// backfillMetrics handles importing historical metrics
+func (api *API) backfillMetrics(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req backfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("request body exceeds %d bytes", maxErr.Limit))
+			return
+		}
+		resp.BadRequest(fmt.Errorf("invalid backfill data format: %v", err))
+		return
+	}
+
+	result, err := api.service.BackfillMetrics(ctx, req.Metrics)
+	if err != nil {
+		if quotaError(c, resp, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, types.ErrInvalidMetrics):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrAgentNotFound):
+			resp.NotFound(err)
+		case errors.Is(err, types.ErrForbidden):
+			resp.Error(http.StatusForbidden, err)
+		case errors.Is(err, types.ErrAgentRetired), errors.Is(err, types.ErrAgentErased):
+			resp.Error(http.StatusGone, err)
+		default:
+			api.logger.Error("Failed to backfill metrics",
+				zap.Error(err),
+				zap.Int("count", len(req.Metrics)))
+			resp.InternalError(errors.New("failed to backfill metrics"))
+		}
+		return
+	}
+
+	resp.Success(result)
+}
+
+// remoteWrite handles Prometheus remote_write requests, samples of the
+// selected node_exporter metrics are stored as reports of their agents.
+// Status codes follow the protocol, senders retry 5xx and drop 4xx.
+func (api *API) remoteWrite(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	// Remote write 2.0 requests name their message in the content type
+	if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Type")); err == nil {
+		if proto := params["proto"]; proto != "" && proto != "prometheus.WriteRequest" {
+			resp.Error(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported remote write message: %s", proto))
+			return
+		}
+	}
+	if enc := c.GetHeader("Content-Encoding"); enc != "" && enc != "snappy" {
+		resp.Error(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding: %s", enc))
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("request body exceeds %d bytes", maxErr.Limit))
+			return
+		}
+		resp.BadRequest(fmt.Errorf("failed to read request: %v", err))
+		return
+	}
+
+	series, err := remotewrite.Decode(body)
+	if err != nil {
+		resp.BadRequest(err)
+		return
+	}
+
+	stored, err := api.service.WriteRemoteMetrics(ctx, series)
+	if err != nil {
+		if quotaError(c, resp, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, context.Canceled):
+			return
+		case errors.Is(err, types.ErrRemoteWriteDisabled):
+			resp.NotFound(err)
+		case errors.Is(err, types.ErrForbidden):
+			resp.Error(http.StatusForbidden, err)
+		case errors.Is(err, types.ErrInvalidMetrics):
+			resp.BadRequest(err)
+		case errors.Is(err, types.ErrAgentNotFound), errors.Is(err, types.ErrAgentExists):
+			resp.Error(http.StatusConflict, err)
+		case errors.Is(err, types.ErrIngestQueueFull), errors.Is(err, types.ErrIngestClosed):
+			retry := max(1, int(math.Ceil(api.config.Ingest.FlushInterval.Seconds())))
+			c.Header("Retry-After", strconv.Itoa(retry))
+			resp.Error(http.StatusServiceUnavailable, err)
+		default:
+			api.logger.Error("Failed to store remote write samples",
+				zap.Error(err),
+				zap.Int("series", len(series)))
+			resp.InternalError(errors.New("failed to store remote write samples"))
+		}
+		return
+	}
+
+	api.logger.Debug("Stored remote write samples",
+		zap.Int("series", len(series)),
+		zap.Int("reports", stored))
+
+	resp.NoContent()
+}
+
 // getMetrics handles retrieving metrics data
 func (api *API) getMetrics(c *gin.Context) {
 
@@ -150,7 +335,7 @@ func (api *API) getMetrics(c *gin.Context) {
 			return
 		}
 		if errors.Is(err, context.DeadlineExceeded) {
-			resp.Error(http.StatusGatewayTimeout, errors.New("request timeout"))
+			resp.Error(http.StatusGatewayTimeout, types.ErrTimeout)
 			return
 		}
 
@@ -186,7 +371,7 @@ func (api *API) getLatestMetrics(c *gin.Context) {
 			zap.String("agent_id", agentID))
 
 		if errors.Is(err, types.ErrAgentNotFound) {
-			resp.NotFound(errors.New("agent not found"))
+			resp.NotFound(types.ErrAgentNotFound)
 			return
 		}
 
@@ -194,6 +379,13 @@ func (api *API) getLatestMetrics(c *gin.Context) {
 		return
 	}
 
+	// Reports are not changed once stored, pollers get 304 until the next
+	if metrics != nil {
+		version, _ := service.Version(metrics)
+		if resp.NotModified(version, service.MetricsModified(metrics)) {
+			return
+		}
+	}
 	resp.Success(metrics)
 }
 
@@ -250,18 +442,21 @@ func (api *API) exportMetrics(c *gin.Context) {
 		return
 	}
 
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
 	// Set response headers
 	c.Header("Content-Type", utils.GetContentType(filter.Format))
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=metrics-%s.%s",
 		time.Now().Format("2006-01-02"), filter.Format))
 
-	// Stream response
+	// Stream response, the export is written in one step
 	c.Stream(func(w io.Writer) bool {
-		_, err := io.Copy(w, reader)
-		if err != nil {
+		if _, err := io.Copy(w, reader); err != nil {
 			api.logger.Error("Failed to write export data",
 				zap.Error(err))
 		}
-		return err == nil
+		return false
 	})
 }
@@ -0,0 +1,364 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"wameter/internal/server/api/openapi"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OpenAPI represents OpenAPI specification API
+type OpenAPI interface {
+	RegisterOpenAPIRoutes(r *gin.RouterGroup)
+}
+
+// _ implements OpenAPI
+var _ OpenAPI = (*API)(nil)
+
+// Common query parameters
+var (
+	timeRangeParams = []openapi.Param{
+		{Name: "start_time", Format: "date-time", Description: "RFC3339, date or unix timestamp"},
+		{Name: "end_time", Format: "date-time", Description: "RFC3339, date or unix timestamp"},
+	}
+	ipChangeParams = append(timeRangeParams[:len(timeRangeParams):len(timeRangeParams)],
+		openapi.Param{Name: "interface", Array: true},
+		openapi.Param{Name: "version", Array: true, Enum: []string{string(types.IPv4), string(types.IPv6)}},
+		openapi.Param{Name: "action", Array: true},
+		openapi.Param{Name: "external", Type: "boolean"},
+		openapi.Param{Name: "limit", Type: "integer", Description: "Default 100, at most 1000"},
+		openapi.Param{Name: "offset", Type: "integer"},
+	)
+	topParams = append(timeRangeParams[:len(timeRangeParams):len(timeRangeParams)],
+		openapi.Param{Name: "metric", Enum: []string{
+			string(types.TopMetricBandwidth), string(types.TopMetricErrorRate), string(types.TopMetricIPChanges),
+		}, Description: "Default bandwidth"},
+		openapi.Param{Name: "window", Description: "Duration before end_time instead of start_time, default 24h"},
+		openapi.Param{Name: "limit", Type: "integer", Description: "Default 10, at most 100"},
+	)
+	agentParams = []openapi.Param{
+		{Name: "status", Array: true, Enum: []string{
+			string(types.AgentStatusOnline), string(types.AgentStatusOffline),
+			string(types.AgentStatusError), string(types.AgentStatusRetired),
+			string(types.AgentStatusExpected), string(types.AgentStatusMissing),
+		}},
+		{Name: "hostname", Description: "Case-insensitive hostname substring"},
+		{Name: "tag", Array: true, Description: "key=value, or key to match any value"},
+		{Name: "sort", Enum: types.AgentSortFields, Description: "Default hostname"},
+		{Name: "order", Enum: []string{"asc", "desc"}},
+		{Name: "limit", Type: "integer", Description: "Default 100, at most 1000"},
+		{Name: "offset", Type: "integer"},
+	}
+)
+
+// ipChangePage represents a page of IP changes
+type ipChangePage struct {
+	Changes []*types.IPChange `json:"changes"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+	HasMore bool              `json:"has_more"`
+}
+
+// RegisterOpenAPIRoutes registers OpenAPI specification routes
+func (api *API) RegisterOpenAPIRoutes(r *gin.RouterGroup) {
+	r.GET("/openapi.json", api.getOpenAPISpec)
+}
+
+// getOpenAPISpec handles retrieving the OpenAPI specification
+func (api *API) getOpenAPISpec(c *gin.Context) {
+	spec, err := api.OpenAPISpec().JSON()
+	if err != nil {
+		api.logger.Error("Failed to build OpenAPI spec", zap.Error(err))
+		response.New(c, api.logger).InternalError(errors.New("failed to build OpenAPI spec"))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// OpenAPISpec builds the OpenAPI specification of the v1 API
+func (api *API) OpenAPISpec() *openapi.Document {
+	title := api.config.API.Docs.Title
+	if title == "" {
+		title = "Wameter API"
+	}
+
+	doc := openapi.NewDocument(title, version.GetInfo().Version)
+	if api.config.API.Auth.Enabled {
+		doc.UseBearerAuth()
+	}
+
+	for _, route := range api.routeDocs() {
+		doc.Add("/v1", route)
+	}
+
+	return doc
+}
+
+// routeDocs describes the v1 routes, keep in sync with the Register*Routes methods
+func (api *API) routeDocs() []openapi.Route {
+	metricsPath := api.config.Server.MetricsPath
+
+	return []openapi.Route{
+		// Agents
+		{Method: http.MethodGet, Path: "/agents", Tag: "agents", Summary: "List agents",
+			Query: agentParams, Response: &agentPage{}},
+		{Method: http.MethodGet, Path: "/agents/status", Tag: "agents", Summary: "Get the status of agents by ID",
+			Query: agentParams[:3], Response: map[string]*types.AgentStatusInfo{}},
+		{Method: http.MethodGet, Path: "/agents/:id", Tag: "agents", Summary: "Get an agent",
+			Response: &types.AgentInfo{}},
+		{Method: http.MethodPost, Path: "/agents", Tag: "agents", Summary: "Register an agent",
+			Body: &types.AgentInfo{}, Response: &types.AgentInfo{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/agents/:id", Tag: "agents", Summary: "Update an agent",
+			Body: &agentUpdateRequest{}, Response: &types.AgentInfo{}},
+		{Method: http.MethodDelete, Path: "/agents/:id", Tag: "agents", Summary: "Delete an offline agent",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/agents/:id/metrics", Tag: "agents", Summary: "Get collection metrics of an agent",
+			Response: &types.AgentMetrics{}},
+		{Method: http.MethodPost, Path: "/agents/:id/heartbeat", Tag: "agents", Summary: "Record an agent heartbeat",
+			Response: &struct {
+				Status    string    `json:"status"`
+				Timestamp time.Time `json:"timestamp"`
+			}{}},
+		{Method: http.MethodPost, Path: "/agents/:id/decommission", Tag: "agents", Summary: "Retire an agent and schedule its purge",
+			Body: &types.DecommissionRequest{}, Response: &types.AgentDecommission{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/agents/:id/decommission", Tag: "agents", Summary: "Get the decommission of an agent",
+			Response: &types.AgentDecommission{}},
+		{Method: http.MethodDelete, Path: "/agents/:id/decommission", Tag: "agents", Summary: "Reinstate a retired agent before it is purged",
+			Status: http.StatusNoContent},
+		{Method: http.MethodPut, Path: "/agents/:id/maintenance", Tag: "agents", Summary: "Put an agent into maintenance, suppressing its offline and threshold notifications",
+			Body: &types.MaintenanceRequest{}, Response: &types.AgentMaintenance{}},
+		{Method: http.MethodDelete, Path: "/agents/:id/maintenance", Tag: "agents", Summary: "End the maintenance of an agent",
+			Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/agents/:id/diagnostics", Tag: "agents", Summary: "Ask an agent for a diagnostics bundle",
+			Response: &struct {
+				CommandID string `json:"command_id"`
+			}{}, Status: http.StatusAccepted},
+		{Method: http.MethodPut, Path: "/agents/:id/diagnostics", Tag: "agents", Summary: "Upload a diagnostics bundle from an agent",
+			Query: []openapi.Param{{Name: "command_id"}}, Response: &types.Diagnostics{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/agents/:id/diagnostics", Tag: "agents", Summary: "Download a diagnostics bundle of an agent",
+			Query: []openapi.Param{{Name: "bundle"}}, ContentTypes: []string{"application/gzip"}},
+		{Method: http.MethodGet, Path: "/agents/:id/diagnostics/bundles", Tag: "agents", Summary: "List the diagnostics bundles of an agent",
+			Response: []*types.Diagnostics{}},
+		{Method: http.MethodPost, Path: "/agents/:id/collect", Tag: "agents", Summary: "Ask an agent to collect and report metrics at once",
+			Response: &struct {
+				CommandID string `json:"command_id"`
+			}{}, Status: http.StatusAccepted},
+		{Method: http.MethodPost, Path: "/agents/:id/logs", Tag: "agents", Summary: "Ship log entries of an agent",
+			Body: &types.AgentLogBatch{}, Response: &struct {
+				Accepted int `json:"accepted"`
+			}{}},
+		{Method: http.MethodGet, Path: "/agents/:id/logs", Tag: "agents", Summary: "Query the shipped logs of an agent",
+			Query: append(timeRangeParams[:len(timeRangeParams):len(timeRangeParams)],
+				openapi.Param{Name: "level", Enum: types.AgentLogLevels, Description: "Minimum level"},
+				openapi.Param{Name: "search", Description: "Case-insensitive substring of the message"},
+				openapi.Param{Name: "limit", Type: "integer", Description: "Default 100, at most 1000"},
+				openapi.Param{Name: "offset", Type: "integer"},
+			),
+			Response: &agentLogPage{}},
+
+		// Commands
+		{Method: http.MethodPost, Path: "/agents/:id/command", Tag: "commands", Summary: "Send a command to an agent",
+			Body: &commandRequest{},
+			Response: &struct {
+				CommandID string `json:"command_id"`
+				Status    string `json:"status"`
+			}{}},
+		{Method: http.MethodGet, Path: "/agents/:id/commands", Tag: "commands", Summary: "Poll the commands queued for an agent that negotiated pull_commands",
+			Response: []types.QueuedCommand{}},
+		{Method: http.MethodGet, Path: "/commands/:id/result", Tag: "commands", Summary: "Get the result of a command, 202 with its status while in progress",
+			Query:    []openapi.Param{{Name: "wait", Description: "Duration to wait for the command to end, e.g. 30s, at most 1m"}},
+			Response: &types.CommandResult{}},
+
+		// Metrics
+		{Method: http.MethodPost, Path: metricsPath, Tag: "metrics", Summary: "Report metrics",
+			Body: &types.MetricsData{}},
+		{Method: http.MethodPost, Path: metricsPath + "/backfill", Tag: "metrics", Summary: "Import historical metrics, entries already stored are skipped",
+			Body: &backfillRequest{}, Response: &types.MetricsBackfillResult{}},
+		{Method: http.MethodPost, Path: metricsPath + "/remote_write", Tag: "metrics", Summary: "Store node_exporter network metrics sent with Prometheus remote_write, a snappy compressed protobuf body",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: metricsPath, Tag: "metrics", Summary: "Query metrics",
+			Query: append([]openapi.Param{
+				{Name: "agent_ids", Array: true},
+				{Name: "limit", Type: "integer", Description: "Default 1000, at most 10000"},
+			}, requiredParams(timeRangeParams)...),
+			Response: []*types.MetricsData{}},
+		{Method: http.MethodGet, Path: metricsPath + "/latest", Tag: "metrics", Summary: "Get latest metrics of an agent",
+			Query:    []openapi.Param{{Name: "agent_id", Required: true}},
+			Response: &types.MetricsData{}},
+		{Method: http.MethodGet, Path: metricsPath + "/export", Tag: "metrics", Summary: "Export metrics",
+			Query: append([]openapi.Param{
+				{Name: "format", Required: true, Enum: []string{"json", "csv"}},
+				{Name: "agent_ids", Array: true},
+				{Name: "metric_types", Array: true},
+				{Name: "compress", Type: "boolean"},
+				{Name: "include_raw", Type: "boolean"},
+			}, requiredParams(timeRangeParams)...),
+			ContentTypes: []string{"application/json", "text/csv"}},
+
+		// IP changes
+		{Method: http.MethodGet, Path: "/ip-changes", Tag: "ip-changes", Summary: "Query IP changes of all agents",
+			Query:    append([]openapi.Param{{Name: "agent_ids", Array: true}}, ipChangeParams...),
+			Response: &ipChangePage{}},
+		{Method: http.MethodGet, Path: "/agents/:id/ip-changes", Tag: "ip-changes", Summary: "Query IP changes of an agent",
+			Query: ipChangeParams, Response: &ipChangePage{}},
+		{Method: http.MethodGet, Path: "/agents/:id/ip-changes/summary", Tag: "ip-changes", Summary: "Get IP change summary of an agent",
+			Response: &types.IPChangeSummary{}},
+		{Method: http.MethodGet, Path: "/agents/:id/ip-changes/stats", Tag: "ip-changes", Summary: "Get IP change patterns of an agent",
+			Response: &types.IPChangeStats{}},
+		{Method: http.MethodGet, Path: "/admin/ip-change-windows", Tag: "ip-changes", Summary: "Get the expected IP change windows declared through the API",
+			Response: []types.IPChangeWindow{}},
+		{Method: http.MethodPut, Path: "/admin/ip-change-windows", Tag: "ip-changes", Summary: "Replace the expected IP change windows declared through the API",
+			Body: []types.IPChangeWindow{}, Response: []types.IPChangeWindow{}},
+
+		// Alert rules
+		{Method: http.MethodGet, Path: "/admin/alert-rules", Tag: "alert-rules", Summary: "List the alert rules of the configuration and the ones declared through the API",
+			Response: []*types.AlertRule{}},
+		{Method: http.MethodGet, Path: "/admin/alert-rules/:name", Tag: "alert-rules", Summary: "Get an alert rule",
+			Response: &types.AlertRule{}},
+		{Method: http.MethodPut, Path: "/admin/alert-rules/:name", Tag: "alert-rules", Summary: "Create or replace an alert rule declared through the API",
+			Body: &types.AlertRule{}, Response: &types.AlertRule{}},
+		{Method: http.MethodDelete, Path: "/admin/alert-rules/:name", Tag: "alert-rules", Summary: "Delete an alert rule declared through the API",
+			Status: http.StatusNoContent},
+
+		// Analytics
+		{Method: http.MethodGet, Path: "/analytics/top/agents", Tag: "analytics", Summary: "Rank agents by bandwidth, error rate or IP changes",
+			Query: topParams, Response: &types.TopResult{}},
+		{Method: http.MethodGet, Path: "/analytics/top/interfaces", Tag: "analytics", Summary: "Rank interfaces by bandwidth, error rate or IP changes",
+			Query: topParams, Response: &types.TopResult{}},
+
+		// Grafana JSON datasource
+		{Method: http.MethodGet, Path: "/grafana", Tag: "grafana", Summary: "Test the Grafana datasource connection",
+			Response: map[string]string{}, Raw: true},
+		{Method: http.MethodPost, Path: "/grafana/search", Tag: "grafana", Summary: "List agent/interface/metric targets containing the search target",
+			Body: &types.GrafanaSearch{}, Response: []string{}, Raw: true},
+		{Method: http.MethodPost, Path: "/grafana/query", Tag: "grafana", Summary: "Query interface metric series of glob targets, averaged to the interval",
+			Body: &types.GrafanaQuery{}, Response: []types.GrafanaSeries{}, Raw: true},
+		{Method: http.MethodPost, Path: "/grafana/annotations", Tag: "grafana", Summary: "Query IP change events or alert regions, the query is ip_changes or alerts and an optional agent glob, or events, type=<type> and tags",
+			Body: &types.GrafanaAnnotationQuery{}, Response: []types.GrafanaAnnotation{}, Raw: true},
+
+		// System
+		{Method: http.MethodGet, Path: "/health", Tag: "system", Summary: "Get server health",
+			Response: &types.HealthStatus{}},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "system", Summary: "Get the OpenAPI specification, served without authentication",
+			Response: map[string]any{}, Raw: true},
+
+		// Administration
+		{Method: http.MethodPost, Path: "/admin/reload", Tag: "admin", Summary: "Reload server configuration",
+			Response: &types.ConfigChange{}},
+		{Method: http.MethodGet, Path: "/admin/backup", Tag: "admin", Summary: "Stream a backup archive of tenants, agents, alert rules, configuration, metrics and IP changes",
+			Query: timeRangeParams, ContentTypes: []string{"application/gzip"}},
+		{Method: http.MethodGet, Path: "/admin/config/history", Tag: "admin", Summary: "Get configuration change history",
+			Response: []types.ConfigChange{}},
+		{Method: http.MethodGet, Path: "/admin/ingest", Tag: "admin", Summary: "Get metrics ingest queue depth and lag",
+			Response: &types.IngestStats{}},
+		{Method: http.MethodGet, Path: "/admin/jobs", Tag: "admin", Summary: "Get the schedules, next runs and last runs of the periodic server tasks",
+			Response: []*types.JobStatus{}},
+		{Method: http.MethodPost, Path: "/admin/jobs/:name/run", Tag: "admin", Summary: "Run a periodic server task now, by its overlap policy",
+			Response: &types.JobStatus{}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/admin/metrics", Tag: "admin", Summary: "Get server metrics with goroutine, heap and GC pause statistics",
+			Response: &types.ServiceMetrics{}},
+		{Method: http.MethodPost, Path: "/admin/notify/test", Tag: "admin", Summary: "Send a test notification through a channel, or all enabled channels, and get the delivery of each",
+			Body: &types.NotificationTestRequest{}, Response: []*types.NotificationTestResult{}},
+		{Method: http.MethodPost, Path: "/admin/prune", Tag: "admin", Summary: "Delete metrics older than a cutoff in the background, a dry run returns the rows that would be deleted",
+			Body: &types.PruneRequest{}, Response: &types.Prune{}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/admin/prune", Tag: "admin", Summary: "Get the status and progress of the last prune",
+			Response: &types.Prune{}},
+		{Method: http.MethodGet, Path: "/admin/quotas", Tag: "admin", Summary: "Get stored rows and bytes and hourly samples of agents against their quotas",
+			Response: []*types.AgentQuota{}},
+		{Method: http.MethodGet, Path: "/admin/stats", Tag: "admin", Summary: "Get service, notifier, command and database counters since the last restart",
+			Response: &types.ServiceStats{}},
+
+		// Inventory
+		{Method: http.MethodGet, Path: "/admin/inventory", Tag: "inventory", Summary: "Get the expected agents and counts declared through the API",
+			Response: &types.Inventory{}},
+		{Method: http.MethodPut, Path: "/admin/inventory", Tag: "inventory", Summary: "Replace the expected agents and counts declared through the API",
+			Body: &types.Inventory{}, Response: &types.Inventory{}},
+		{Method: http.MethodGet, Path: "/admin/inventory/status", Tag: "inventory", Summary: "Get the state of the expected agents and counts at the last check",
+			Response: &types.InventoryStatus{}},
+
+		// Erasures
+		{Method: http.MethodPost, Path: "/admin/erasures", Tag: "erasures", Summary: "Erase all data of an agent or of the agents of a hostname, completed in the background",
+			Body: &types.ErasureRequest{}, Response: &types.Erasure{}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/admin/erasures", Tag: "erasures", Summary: "List erasures, newest first",
+			Response: []*types.Erasure{}},
+		{Method: http.MethodGet, Path: "/admin/erasures/:id", Tag: "erasures", Summary: "Get an erasure and its report of deleted and remaining data",
+			Response: &types.Erasure{}},
+
+		// Tenants
+		{Method: http.MethodGet, Path: "/admin/tenants", Tag: "tenants", Summary: "List tenants",
+			Response: []*types.Tenant{}},
+		{Method: http.MethodPost, Path: "/admin/tenants", Tag: "tenants", Summary: "Create a tenant",
+			Body: &types.Tenant{}, Response: &types.Tenant{}, Status: http.StatusCreated},
+		{Method: http.MethodDelete, Path: "/admin/tenants/:id", Tag: "tenants", Summary: "Delete a tenant without agents",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/admin/tenants/:id/keys", Tag: "tenants", Summary: "List API keys of a tenant",
+			Response: []*types.APIKey{}},
+		{Method: http.MethodPost, Path: "/admin/tenants/:id/keys", Tag: "tenants", Summary: "Create an API key, the key is only returned once",
+			Body: &apiKeyRequest{}, Response: &types.APIKey{}, Status: http.StatusCreated},
+		{Method: http.MethodDelete, Path: "/admin/tenants/:id/keys/:key_id", Tag: "tenants", Summary: "Revoke an API key",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/admin/usage", Tag: "tenants", Summary: "Get resource usage per tenant",
+			Response: []*types.TenantUsage{}},
+
+		// Users
+		{Method: http.MethodGet, Path: "/admin/users", Tag: "users", Summary: "List users and their roles",
+			Query: []openapi.Param{{Name: "tenant_id"}}, Response: []*types.User{}},
+		{Method: http.MethodPost, Path: "/admin/users", Tag: "users", Summary: "Create a user with a role of viewer, operator or admin",
+			Body: &types.User{}, Response: &types.User{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/admin/users/:id", Tag: "users", Summary: "Get a user",
+			Response: &types.User{}},
+		{Method: http.MethodPut, Path: "/admin/users/:id", Tag: "users", Summary: "Change the role or agents of a user",
+			Body: &userUpdateRequest{}, Response: &types.User{}},
+		{Method: http.MethodDelete, Path: "/admin/users/:id", Tag: "users", Summary: "Delete a user and revoke its API keys",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/admin/users/:id/keys", Tag: "users", Summary: "List API keys of a user",
+			Response: []*types.APIKey{}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/keys", Tag: "users", Summary: "Create an API key acting as a user, the key is only returned once",
+			Body: &apiKeyRequest{}, Response: &types.APIKey{}, Status: http.StatusCreated},
+
+		// Audit
+		{Method: http.MethodGet, Path: "/audit", Tag: "audit", Summary: "Query the audit log of mutating calls",
+			Query: append(timeRangeParams[:len(timeRangeParams):len(timeRangeParams)],
+				openapi.Param{Name: "actor"},
+				openapi.Param{Name: "action", Array: true},
+				openapi.Param{Name: "resource"},
+				openapi.Param{Name: "limit", Type: "integer", Description: "Default 100, at most 1000"},
+				openapi.Param{Name: "offset", Type: "integer"},
+			),
+			Response: &auditPage{}},
+
+		// Annotations
+		{Method: http.MethodGet, Path: "/annotations", Tag: "annotations", Summary: "Query annotations overlapping the time range",
+			Query: append(timeRangeParams[:len(timeRangeParams):len(timeRangeParams)],
+				openapi.Param{Name: "type", Array: true, Description: "ip_change, agent_status, alert, acknowledgement, deployment, event or a custom type"},
+				openapi.Param{Name: "agent_id", Array: true},
+				openapi.Param{Name: "tag", Array: true, Description: "Required tags"},
+				openapi.Param{Name: "limit", Type: "integer", Description: "Default 100, at most 1000"},
+				openapi.Param{Name: "offset", Type: "integer"},
+			),
+			Response: &annotationPage{}},
+		{Method: http.MethodPost, Path: "/annotations", Tag: "annotations", Summary: "Create an annotation, e.g. of a deployment, global without agent_id",
+			Body: &types.Annotation{}, Response: &types.Annotation{}, Status: http.StatusCreated},
+
+		// Telegram
+		{Method: http.MethodPost, Path: "/telegram/webhook", Tag: "telegram", Summary: "Receive Telegram updates of alert buttons, authenticated by the X-Telegram-Bot-Api-Secret-Token header",
+			Status: http.StatusNoContent},
+	}
+}
+
+// requiredParams returns copies of params marked as required
+func requiredParams(params []openapi.Param) []openapi.Param {
+	required := make([]openapi.Param, len(params))
+	for i, p := range params {
+		p.Required = true
+		required[i] = p
+	}
+	return required
+}
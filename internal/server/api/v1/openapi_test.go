@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+
+	"wameter/internal/server/config"
+)
+
+// TestRouteDocs tests that every registered v1 route is documented
+// and every documented route is registered
+func TestRouteDocs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Server.MetricsPath = "/metrics"
+	api := NewAPI(cfg, nil, zaptest.NewLogger(t))
+
+	// Register the routes the way the router does
+	r := gin.New()
+	api.RegisterOpenAPIRoutes(r.Group("/v1"))
+	api.RegisterTelegramRoutes(r.Group("/v1"))
+	api.RegisterRoutes(r.Group("/v1"))
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+strings.TrimPrefix(route.Path, "/v1")] = true
+	}
+
+	documented := make(map[string]bool)
+	for _, route := range api.routeDocs() {
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	for route := range registered {
+		assert.True(t, documented[route], "route %s is not documented", route)
+	}
+	for route := range documented {
+		assert.True(t, registered[route], "route %s is documented but not registered", route)
+	}
+}
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// telegramSecretHeader carries the secret token of Telegram webhook updates
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// telegramUpdateLimit caps the size of Telegram updates
+const telegramUpdateLimit = 1 << 20
+
+// TelegramAPI represents Telegram webhook API
+type TelegramAPI interface {
+	RegisterTelegramRoutes(r *gin.RouterGroup)
+}
+
+// _ implements TelegramAPI
+var _ TelegramAPI = (*API)(nil)
+
+// RegisterTelegramRoutes registers the Telegram updates webhook, it is
+// authenticated by the secret token instead of API credentials
+func (api *API) RegisterTelegramRoutes(r *gin.RouterGroup) {
+	r.POST("/telegram/webhook", api.telegramWebhook)
+}
+
+// telegramWebhook handles an update pushed by Telegram, e.g. a button press
+// of an alert
+func (api *API) telegramWebhook(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, telegramUpdateLimit))
+	if err != nil {
+		resp.BadRequest(fmt.Errorf("failed to read update: %w", err))
+		return
+	}
+
+	err = api.service.HandleTelegramUpdate(ctx, c.GetHeader(telegramSecretHeader), body)
+	switch {
+	case err == nil:
+		resp.NoContent()
+	case errors.Is(err, types.ErrTelegramUpdates):
+		resp.NotFound(err)
+	case errors.Is(err, types.ErrForbidden):
+		resp.Error(http.StatusForbidden, errors.New("invalid secret token"))
+	default:
+		api.logger.Warn("Failed to handle telegram update", zap.Error(err))
+		resp.BadRequest(errors.New("invalid update"))
+	}
+}
@@ -0,0 +1,202 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TenantAPI represents tenant administration API
+type TenantAPI interface {
+	RegisterTenantRoutes(r *gin.RouterGroup)
+}
+
+// _ implements TenantAPI
+var _ TenantAPI = (*API)(nil)
+
+// apiKeyRequest represents an API key creation request
+type apiKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// RegisterTenantRoutes registers tenant administration routes
+func (api *API) RegisterTenantRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin", api.requireAdmin)
+	admin.GET("/tenants", api.getTenants)
+	admin.POST("/tenants", api.createTenant)
+	admin.DELETE("/tenants/:id", api.deleteTenant)
+	admin.GET("/tenants/:id/keys", api.getAPIKeys)
+	admin.POST("/tenants/:id/keys", api.createAPIKey)
+	admin.DELETE("/tenants/:id/keys/:key_id", api.deleteAPIKey)
+	admin.GET("/usage", api.getTenantUsage)
+}
+
+// getTenants handles listing tenants
+func (api *API) getTenants(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	tenants, err := api.service.GetTenants(ctx)
+	if err != nil {
+		api.logger.Error("Failed to get tenants", zap.Error(err))
+		resp.InternalError(errors.New("failed to get tenants"))
+		return
+	}
+
+	resp.Success(tenants)
+}
+
+// createTenant handles tenant creation
+func (api *API) createTenant(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var t types.Tenant
+	if err := c.ShouldBindJSON(&t); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid tenant data: %w", err))
+		return
+	}
+
+	if err := api.service.CreateTenant(ctx, &t); err != nil {
+		if errors.Is(err, types.ErrTenantExists) {
+			resp.Error(http.StatusConflict, err)
+			return
+		}
+		api.logger.Error("Failed to create tenant",
+			zap.Error(err),
+			zap.String("tenant_id", t.ID))
+		resp.BadRequest(err)
+		return
+	}
+
+	resp.Created(t)
+}
+
+// deleteTenant handles tenant deletion
+func (api *API) deleteTenant(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	tenantID := c.Param("id")
+	if err := api.service.DeleteTenant(ctx, tenantID); err != nil {
+		switch {
+		case errors.Is(err, types.ErrTenantNotFound):
+			resp.NotFound(err)
+		case errors.Is(err, types.ErrTenantInUse):
+			resp.Error(http.StatusConflict, err)
+		case tenantID == tenant.Default:
+			resp.BadRequest(err)
+		default:
+			api.logger.Error("Failed to delete tenant",
+				zap.Error(err),
+				zap.String("tenant_id", tenantID))
+			resp.InternalError(errors.New("failed to delete tenant"))
+		}
+		return
+	}
+
+	resp.NoContent()
+}
+
+// getAPIKeys handles listing the API keys of a tenant
+func (api *API) getAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	keys, err := api.service.GetAPIKeys(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, types.ErrTenantNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to get api keys", zap.Error(err))
+		resp.InternalError(errors.New("failed to get api keys"))
+		return
+	}
+	if keys == nil {
+		keys = []*types.APIKey{}
+	}
+
+	resp.Success(keys)
+}
+
+// createAPIKey handles API key creation, the key is only returned here
+func (api *API) createAPIKey(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req apiKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid api key data: %w", err))
+			return
+		}
+	}
+
+	key, err := api.service.CreateAPIKey(ctx, c.Param("id"), req.Name)
+	if err != nil {
+		if errors.Is(err, types.ErrTenantNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to create api key", zap.Error(err))
+		resp.InternalError(errors.New("failed to create api key"))
+		return
+	}
+
+	resp.Created(key)
+}
+
+// deleteAPIKey handles API key revocation
+func (api *API) deleteAPIKey(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	if err := api.service.DeleteAPIKey(ctx, c.Param("id"), c.Param("key_id")); err != nil {
+		if errors.Is(err, types.ErrInvalidAPIKey) {
+			resp.NotFound(errors.New("api key not found"))
+			return
+		}
+		api.logger.Error("Failed to delete api key", zap.Error(err))
+		resp.InternalError(errors.New("failed to delete api key"))
+		return
+	}
+
+	resp.NoContent()
+}
+
+// getTenantUsage handles retrieving per tenant usage
+func (api *API) getTenantUsage(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	usage, err := api.service.GetTenantUsage(ctx)
+	if err != nil {
+		api.logger.Error("Failed to get tenant usage", zap.Error(err))
+		resp.InternalError(errors.New("failed to get tenant usage"))
+		return
+	}
+
+	resp.Success(usage)
+}
@@ -0,0 +1,234 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"wameter/internal/server/api/response"
+	"wameter/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// UserAPI represents user and role administration API
+type UserAPI interface {
+	RegisterUserRoutes(r *gin.RouterGroup)
+}
+
+// _ implements UserAPI
+var _ UserAPI = (*API)(nil)
+
+// userUpdateRequest represents a user update request, omitted fields are kept
+type userUpdateRequest struct {
+	Name   string     `json:"name"`
+	Role   types.Role `json:"role"`
+	Agents *[]string  `json:"agents"`
+}
+
+// RegisterUserRoutes registers user administration routes
+func (api *API) RegisterUserRoutes(r *gin.RouterGroup) {
+	users := r.Group("/admin/users", api.requireAdmin)
+	users.GET("", api.getUsers)
+	users.POST("", api.createUser)
+	users.GET("/:id", api.getUser)
+	users.PUT("/:id", api.updateUser)
+	users.DELETE("/:id", api.deleteUser)
+	users.GET("/:id/keys", api.getUserAPIKeys)
+	users.POST("/:id/keys", api.createUserAPIKey)
+}
+
+// getUsers handles listing users, optionally of a single tenant
+func (api *API) getUsers(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	users, err := api.service.GetUsers(ctx, c.Query("tenant_id"))
+	if err != nil {
+		api.logger.Error("Failed to get users", zap.Error(err))
+		resp.InternalError(errors.New("failed to get users"))
+		return
+	}
+	if users == nil {
+		users = []*types.User{}
+	}
+
+	resp.Success(users)
+}
+
+// getUser handles retrieving a user
+func (api *API) getUser(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	user, err := api.service.GetUser(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to get user", zap.Error(err))
+		resp.InternalError(errors.New("failed to get user"))
+		return
+	}
+
+	resp.Success(user)
+}
+
+// createUser handles user creation
+func (api *API) createUser(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var user types.User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid user data: %w", err))
+		return
+	}
+
+	if err := api.service.CreateUser(ctx, &user); err != nil {
+		switch {
+		case errors.Is(err, types.ErrUserExists):
+			resp.Error(http.StatusConflict, err)
+		case errors.Is(err, types.ErrTenantNotFound):
+			resp.NotFound(err)
+		default:
+			api.logger.Error("Failed to create user",
+				zap.Error(err),
+				zap.String("user_id", user.ID))
+			resp.BadRequest(err)
+		}
+		return
+	}
+
+	resp.Created(user)
+}
+
+// updateUser handles changing the name, role or agents of a user
+func (api *API) updateUser(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var update userUpdateRequest
+	if err := c.ShouldBindJSON(&update); err != nil {
+		resp.BadRequest(fmt.Errorf("invalid update data: %w", err))
+		return
+	}
+
+	user, err := api.service.GetUser(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		resp.InternalError(errors.New("failed to get user"))
+		return
+	}
+
+	if update.Name != "" {
+		user.Name = update.Name
+	}
+	if update.Role != "" {
+		user.Role = update.Role
+	}
+	if update.Agents != nil {
+		user.Agents = *update.Agents
+	}
+
+	if err := api.service.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to update user",
+			zap.Error(err),
+			zap.String("user_id", user.ID))
+		resp.BadRequest(err)
+		return
+	}
+
+	resp.Success(user)
+}
+
+// deleteUser handles user deletion, the API keys of the user are revoked
+func (api *API) deleteUser(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	if err := api.service.DeleteUser(ctx, c.Param("id")); err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to delete user", zap.Error(err))
+		resp.InternalError(errors.New("failed to delete user"))
+		return
+	}
+
+	resp.NoContent()
+}
+
+// getUserAPIKeys handles listing the API keys of a user
+func (api *API) getUserAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	keys, err := api.service.GetUserAPIKeys(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to get api keys", zap.Error(err))
+		resp.InternalError(errors.New("failed to get api keys"))
+		return
+	}
+	if keys == nil {
+		keys = []*types.APIKey{}
+	}
+
+	resp.Success(keys)
+}
+
+// createUserAPIKey handles creating an API key acting as a user, the key is
+// only returned here
+func (api *API) createUserAPIKey(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	resp := response.New(c, api.logger)
+
+	var req apiKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.BadRequest(fmt.Errorf("invalid api key data: %w", err))
+			return
+		}
+	}
+
+	key, err := api.service.CreateUserAPIKey(ctx, c.Param("id"), req.Name)
+	if err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			resp.NotFound(err)
+			return
+		}
+		api.logger.Error("Failed to create api key", zap.Error(err))
+		resp.InternalError(errors.New("failed to create api key"))
+		return
+	}
+
+	resp.Created(key)
+}
@@ -0,0 +1,34 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"wameter/internal/server/config"
+)
+
+// Store represents a storage of archive objects
+type Store interface {
+	// Put stores the content of body under key and returns where it was
+	// stored, body is read from its current offset
+	Put(ctx context.Context, key string, body io.ReadSeeker) (string, error)
+
+	// Delete deletes the object at a location returned by Put, locations
+	// already deleted are not an error
+	Delete(ctx context.Context, location string) error
+}
+
+// New creates the store of a storage type, "file" or "s3"
+func New(storage string, cfg *config.ArchiveConfig) (Store, error) {
+	switch storage {
+	case "file":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("archive directory is not configured")
+		}
+		return NewFile(cfg.Dir), nil
+	case "s3":
+		return NewS3(&cfg.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", storage)
+	}
+}
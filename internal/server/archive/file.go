@@ -0,0 +1,62 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File represents an archive store on the local file system
+type File struct {
+	dir string
+}
+
+// NewFile creates new file store writing below dir
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+// Put writes body to the file of key, it is written to a temporary file
+// first so a partial archive is never left under the final name
+func (f *File) Put(_ context.Context, key string, body io.ReadSeeker) (string, error) {
+	path := filepath.Join(f.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := writeFile(tmp, body); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to rename archive file: %w", err)
+	}
+
+	return path, nil
+}
+
+// Delete removes the archive file at location
+func (f *File) Delete(_ context.Context, location string) error {
+	if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete archive file: %w", err)
+	}
+	return nil
+}
+
+// writeFile copies r to the file of path
+func writeFile(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"wameter/internal/awsv4"
+	"wameter/internal/server/config"
+)
+
+// S3 represents an archive store in an S3 bucket
+type S3 struct {
+	config *config.S3Config
+	region string
+	client *http.Client
+}
+
+// NewS3 creates new S3 store, the region falls back to AWS_REGION
+func NewS3(cfg *config.S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not configured")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = awsv4.RegionFromEnv()
+	}
+	if region == "" {
+		return nil, fmt.Errorf("s3 region is not configured")
+	}
+
+	return &S3{
+		config: cfg,
+		region: region,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Put uploads body to the object of key below the configured prefix, it is
+// read twice, once for the payload hash of the signature
+func (s *S3) Put(ctx context.Context, key string, body io.ReadSeeker) (string, error) {
+	creds, err := awsv4.CredentialsFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	key = strings.TrimPrefix(path.Join(s.config.Prefix, key), "/")
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), io.LimitReader(body, size))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	awsv4.SignHash(req, hex.EncodeToString(h.Sum(nil)), creds, s.region, "s3", time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return fmt.Sprintf("s3://%s/%s", s.config.Bucket, key), nil
+}
+
+// emptyPayloadHash is the payload hash of requests without a body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Delete deletes the object at an s3:// location of the configured bucket
+func (s *S3) Delete(ctx context.Context, location string) error {
+	key, ok := strings.CutPrefix(location, "s3://"+s.config.Bucket+"/")
+	if !ok {
+		return fmt.Errorf("archive %s is not in bucket %s", location, s.config.Bucket)
+	}
+
+	creds, err := awsv4.CredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	awsv4.SignHash(req, emptyPayloadHash, creds, s.region, "s3", time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Deleting a missing object succeeds too
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// objectURL returns the URL of an object, virtual hosted style on AWS and
+// path style on custom endpoints
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.config.Bucket, s.region)
+	objectPath := "/" + key
+	if s.config.Endpoint != "" {
+		endpoint = strings.TrimSuffix(s.config.Endpoint, "/")
+		objectPath = "/" + s.config.Bucket + objectPath
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	// S3 signs the path with every reserved character escaped
+	u.Path = objectPath
+	u.RawPath = escapePath(objectPath)
+	return u, nil
+}
+
+// escapePath escapes each segment of p as S3 expects in canonical requests
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
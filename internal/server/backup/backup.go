@@ -0,0 +1,223 @@
+// Package backup exports the data of a server to a portable archive and
+// restores it into a fresh database, whichever driver either side uses.
+//
+// An archive is a gzip compressed stream of JSON lines, a header followed by
+// one record per tenant, agent, alert rule, metrics report and IP change.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+	commonCfg "wameter/internal/config"
+	"wameter/internal/database"
+	"wameter/internal/server/config"
+	"wameter/internal/server/data/repository"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// FormatVersion is the version of the archive format written
+const FormatVersion = 1
+
+// ipChangePageSize is the number of IP changes read at once
+const ipChangePageSize = 1000
+
+// Record kinds
+const (
+	KindTenant    = "tenant"
+	KindAgent     = "agent"
+	KindAlertRule = "alert_rule"
+	KindConfig    = "config"
+	KindMetrics   = "metrics"
+	KindIPChange  = "ip_change"
+)
+
+// Header represents the first line of an archive
+type Header struct {
+	Format    int       `json:"format"`
+	Version   string    `json:"version"` // Server version
+	Driver    string    `json:"driver"`  // Database driver backed up
+	CreatedAt time.Time `json:"created_at"`
+	StartTime time.Time `json:"start_time,omitempty"` // Metrics range, zero for all
+	EndTime   time.Time `json:"end_time"`
+}
+
+// record represents a line of an archive after the header
+type record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Options represents the range of metrics and IP changes backed up, a zero
+// start time backs up everything and the end time defaults to now
+type Options struct {
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// Result represents the number of records backed up or restored
+type Result struct {
+	Tenants    int `json:"tenants"`
+	Agents     int `json:"agents"`
+	AlertRules int `json:"alert_rules"`
+	Metrics    int `json:"metrics"`
+	IPChanges  int `json:"ip_changes"`
+}
+
+// writer writes the records of an archive
+type writer struct {
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	written func(kind string) // Called after each record if set
+}
+
+// newWriter creates new archive writer on w
+func newWriter(w io.Writer) *writer {
+	gz := gzip.NewWriter(w)
+	return &writer{gz: gz, enc: json.NewEncoder(gz)}
+}
+
+// write writes a record of kind
+func (w *writer) write(kind string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+	if err := w.enc.Encode(record{Kind: kind, Data: data}); err != nil {
+		return err
+	}
+	if w.written != nil {
+		w.written(kind)
+	}
+	return nil
+}
+
+// Export writes an archive of the data in db to w. Everything is read up to
+// a single end time, so reports and IP changes stored while the backup runs
+// are left out, as are those of agents registered meanwhile. Alert rules and
+// the configuration, with secrets redacted, are included for reference when
+// cfg is set. ctx must not be scoped to a tenant.
+func Export(ctx context.Context, db database.Interface, cfg *config.Config, w io.Writer, opts Options, logger *zap.Logger) (*Result, error) {
+	return export(ctx, db, cfg, newWriter(w), opts, logger)
+}
+
+// export writes an archive of the data in db with aw
+func export(ctx context.Context, db database.Interface, cfg *config.Config, aw *writer, opts Options, logger *zap.Logger) (*Result, error) {
+	if opts.EndTime.IsZero() {
+		opts.EndTime = time.Now()
+	}
+	if opts.EndTime.Before(opts.StartTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	tenantRepo := repository.NewTenantRepository(db, logger)
+	agentRepo := repository.NewAgentRepository(db, logger)
+	metricsRepo := repository.NewMetricsRepository(db, logger)
+	ipChangeRepo := repository.NewIPChangeRepository(db, logger)
+
+	res := &Result{}
+
+	if err := aw.enc.Encode(&Header{
+		Format:    FormatVersion,
+		Version:   version.GetInfo().Version,
+		Driver:    db.Driver(),
+		CreatedAt: time.Now(),
+		StartTime: opts.StartTime,
+		EndTime:   opts.EndTime,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	// API keys are left out, their hashes are of no use to anyone else
+	tenants, err := tenantRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		if err := aw.write(KindTenant, t); err != nil {
+			return nil, err
+		}
+		res.Tenants++
+	}
+
+	agents, err := agentRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		if err := aw.write(KindAgent, agent); err != nil {
+			return nil, err
+		}
+		known[agent.ID] = true
+		res.Agents++
+	}
+
+	if cfg != nil {
+		for i := range cfg.AlertRules {
+			if err := aw.write(KindAlertRule, commonCfg.Flatten(&cfg.AlertRules[i], true)); err != nil {
+				return nil, err
+			}
+			res.AlertRules++
+		}
+
+		var buf bytes.Buffer
+		if err := commonCfg.PrintConfig(&buf, cfg); err != nil {
+			return nil, err
+		}
+		if err := aw.write(KindConfig, buf.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	err = metricsRepo.Stream(ctx, repository.QueryParams{
+		StartTime: opts.StartTime,
+		EndTime:   opts.EndTime,
+	}, func(m *types.MetricsData) error {
+		if !known[m.AgentID] {
+			return nil
+		}
+		res.Metrics++
+		return aw.write(KindMetrics, m)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up metrics: %w", err)
+	}
+
+	filter := &types.IPChangeFilter{
+		StartTime: opts.StartTime,
+		EndTime:   opts.EndTime,
+		Limit:     ipChangePageSize,
+	}
+	for {
+		changes, err := ipChangeRepo.Query(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up IP changes: %w", err)
+		}
+		for _, change := range changes {
+			if !known[change.AgentID] {
+				continue
+			}
+			if err := aw.write(KindIPChange, change); err != nil {
+				return nil, err
+			}
+			res.IPChanges++
+		}
+		if len(changes) < filter.Limit {
+			break
+		}
+		filter.Offset += len(changes)
+	}
+
+	if err := aw.gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"wameter/internal/database"
+
+	"go.uber.org/zap"
+)
+
+// progressEvery is the number of records copied between progress logs
+const progressEvery = 10000
+
+// Migrate copies the tenants, agents, metrics and IP changes of one database
+// into another, whichever driver either uses, by streaming an archive of the
+// source into the target. The target must not have any agents yet. Progress
+// is logged as records are copied.
+func Migrate(ctx context.Context, from, to database.Interface, logger *zap.Logger) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	copied := &Result{}
+	records := 0
+
+	exported := make(chan error, 1)
+	go func() {
+		bw := bufio.NewWriter(pw)
+		aw := newWriter(bw)
+		aw.written = func(kind string) {
+			switch kind {
+			case KindTenant:
+				copied.Tenants++
+			case KindAgent:
+				copied.Agents++
+			case KindMetrics:
+				copied.Metrics++
+			case KindIPChange:
+				copied.IPChanges++
+			}
+			if records++; records%progressEvery == 0 {
+				logger.Info("Migrating data",
+					zap.Int("tenants", copied.Tenants),
+					zap.Int("agents", copied.Agents),
+					zap.Int("metrics_count", copied.Metrics),
+					zap.Int("ip_changes", copied.IPChanges))
+			}
+		}
+
+		_, err := export(ctx, from, nil, aw, Options{}, logger)
+		if err == nil {
+			err = bw.Flush()
+		}
+		_ = pw.CloseWithError(err)
+		exported <- err
+	}()
+
+	// A failed export fails the import with its error, a failed import
+	// stops the export
+	res, err := Import(ctx, to, pr, logger)
+	_ = pr.CloseWithError(err)
+	if exportErr := <-exported; err == nil && exportErr != nil {
+		err = exportErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res.Result, nil
+}
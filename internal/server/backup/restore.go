@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"wameter/internal/database"
+	"wameter/internal/server/data/repository"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// metricsBatchSize is the number of reports restored at once
+const metricsBatchSize = 500
+
+// maxRecordSize is the size limit of a record of an archive
+const maxRecordSize = 64 << 20
+
+// Restored represents a restored archive
+type Restored struct {
+	Result
+	Header *Header `json:"header"`
+	Config string  `json:"-"` // Configuration backed up, secrets redacted
+}
+
+// Import restores an archive read from r into db, which must not have any
+// agents yet. The configuration and alert rules of the archive are returned
+// rather than applied, they belong in the configuration file.
+func Import(ctx context.Context, db database.Interface, r io.Reader, logger *zap.Logger) (*Restored, error) {
+	tenantRepo := repository.NewTenantRepository(db, logger)
+	agentRepo := repository.NewAgentRepository(db, logger)
+	metricsRepo := repository.NewMetricsRepository(db, logger)
+	ipChangeRepo := repository.NewIPChangeRepository(db, logger)
+
+	existing, err := agentRepo.Count(ctx, &types.AgentFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("database already has %d agents, backups are restored into an empty one", existing)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer func(gz *gzip.Reader) {
+		_ = gz.Close()
+	}(gz)
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxRecordSize)
+
+	res := &Restored{}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		return nil, errors.New("archive is empty")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &res.Header); err != nil {
+		return nil, fmt.Errorf("failed to decode archive header: %w", err)
+	}
+	if res.Header.Format < 1 || res.Header.Format > FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format: %d", res.Header.Format)
+	}
+
+	// Metrics and IP changes belong to the tenant of their agent
+	tenants := make(map[string]string)
+	pending := make(map[string][]*types.MetricsData)
+	flush := func(tenantID string) error {
+		batch := pending[tenantID]
+		if len(batch) == 0 {
+			return nil
+		}
+		saved, err := metricsRepo.BatchSave(tenant.WithContext(ctx, tenantID), batch)
+		if err != nil {
+			return fmt.Errorf("failed to restore metrics: %w", err)
+		}
+		res.Metrics += len(saved)
+		pending[tenantID] = batch[:0]
+		return nil
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("failed to decode record on line %d: %w", line, err)
+		}
+
+		switch rec.Kind {
+		case KindTenant:
+			var t types.Tenant
+			if err := json.Unmarshal(rec.Data, &t); err != nil {
+				return nil, fmt.Errorf("failed to decode tenant on line %d: %w", line, err)
+			}
+			// The default tenant is created by the migrations
+			if err := tenantRepo.Save(ctx, &t); err != nil && !errors.Is(err, types.ErrTenantExists) {
+				return nil, fmt.Errorf("failed to restore tenant %s: %w", t.ID, err)
+			}
+			res.Tenants++
+
+		case KindAgent:
+			var agent types.AgentInfo
+			if err := json.Unmarshal(rec.Data, &agent); err != nil {
+				return nil, fmt.Errorf("failed to decode agent on line %d: %w", line, err)
+			}
+			if agent.TenantID == "" {
+				agent.TenantID = tenant.Default
+			}
+			actx := tenant.WithContext(ctx, agent.TenantID)
+			if err := agentRepo.Save(actx, &agent); err != nil {
+				return nil, fmt.Errorf("failed to restore agent %s: %w", agent.ID, err)
+			}
+			if agent.Maintenance != nil {
+				if err := agentRepo.SetMaintenance(actx, agent.ID, agent.Maintenance); err != nil {
+					return nil, fmt.Errorf("failed to restore maintenance of agent %s: %w", agent.ID, err)
+				}
+			}
+			tenants[agent.ID] = agent.TenantID
+			res.Agents++
+
+		case KindAlertRule:
+			res.AlertRules++
+
+		case KindConfig:
+			if err := json.Unmarshal(rec.Data, &res.Config); err != nil {
+				return nil, fmt.Errorf("failed to decode config on line %d: %w", line, err)
+			}
+
+		case KindMetrics:
+			var m types.MetricsData
+			if err := json.Unmarshal(rec.Data, &m); err != nil {
+				return nil, fmt.Errorf("failed to decode metrics on line %d: %w", line, err)
+			}
+			tenantID, ok := tenants[m.AgentID]
+			if !ok {
+				return nil, fmt.Errorf("metrics on line %d belong to unknown agent %s", line, m.AgentID)
+			}
+			pending[tenantID] = append(pending[tenantID], &m)
+			if len(pending[tenantID]) >= metricsBatchSize {
+				if err := flush(tenantID); err != nil {
+					return nil, err
+				}
+			}
+
+		case KindIPChange:
+			var change types.IPChange
+			if err := json.Unmarshal(rec.Data, &change); err != nil {
+				return nil, fmt.Errorf("failed to decode IP change on line %d: %w", line, err)
+			}
+			tenantID, ok := tenants[change.AgentID]
+			if !ok {
+				return nil, fmt.Errorf("IP change on line %d belongs to unknown agent %s", line, change.AgentID)
+			}
+			if err := ipChangeRepo.Save(tenant.WithContext(ctx, tenantID), change.AgentID, &change); err != nil {
+				return nil, fmt.Errorf("failed to restore IP change: %w", err)
+			}
+			res.IPChanges++
+
+		default:
+			// Kinds of newer servers are skipped
+			logger.Warn("Skipping unknown backup record",
+				zap.String("kind", rec.Kind),
+				zap.Int("line", line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	for tenantID := range pending {
+		if err := flush(tenantID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/server/config"
+)
+
+// Store represents a cache of encoded values that expire after a TTL
+type Store interface {
+	// Get returns the value of key, false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys, missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+	// Close closes the store
+	Close() error
+}
+
+// New creates the store of the configured backend
+func New(cfg *config.CacheConfig, redisCfg *config.RedisConfig) (Store, error) {
+	switch cfg.Backend {
+	case "memory":
+		return NewMemory(), nil
+	case "redis":
+		return NewRedis(redisCfg)
+	default:
+		return nil, fmt.Errorf("unsupported cache backend: %s", cfg.Backend)
+	}
+}
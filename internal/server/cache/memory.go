@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory represents an in-process cache store
+type Memory struct {
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// memoryEntry represents a cached value with its expiry
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// _ implements Store
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates new in-process cache store
+func NewMemory() *Memory {
+	return &Memory{
+		entries:   make(map[string]memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get returns the value of key, false when it is missing or expired
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set stores value under key for ttl, expired entries of keys that are not
+// read again are swept once per ttl
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > ttl {
+		for k, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete removes keys
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// Close implements Store
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wameter/internal/server/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis represents a Redis backed cache store shared by all replicas, so
+// entries dropped by one replica are dropped for all
+type Redis struct {
+	rc     *redis.Client
+	prefix string
+}
+
+// _ implements Store
+var _ Store = (*Redis)(nil)
+
+// NewRedis connects to the Redis server of cfg
+func NewRedis(cfg *config.RedisConfig) (*Redis, error) {
+	rc := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	if err := rc.Ping(ctx).Err(); err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &Redis{
+		rc:     rc,
+		prefix: cfg.KeyPrefix + "cache:",
+	}, nil
+}
+
+// Get returns the value of key, false when it is missing or expired
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.rc.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key for ttl
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.rc.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set cache entry: %w", err)
+	}
+	return nil
+}
+
+// Delete removes keys
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	if err := r.rc.Del(ctx, prefixed...).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache entries: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection
+func (r *Redis) Close() error {
+	return r.rc.Close()
+}
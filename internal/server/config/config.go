@@ -2,19 +2,60 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
 	"time"
+	"wameter/internal/compress"
 	"wameter/internal/config"
+	"wameter/internal/cron"
+	"wameter/internal/ipinfo"
+	"wameter/internal/server/remotewrite"
+	"wameter/internal/server/rules"
+	"wameter/internal/types"
 
 	"github.com/spf13/viper"
 )
 
 // Config represents the complete server configuration
 type Config struct {
-	Server   ServerConfig         `mapstructure:"server"`
-	Database DatabaseConfig       `mapstructure:"database"`
-	Notify   *config.NotifyConfig `mapstructure:"notify"`
-	API      APIConfig            `mapstructure:"api"`
-	Log      *config.LogConfig    `mapstructure:"log"`
+	Server       ServerConfig          `mapstructure:"server"`
+	Database     DatabaseConfig        `mapstructure:"database"`
+	Ingest       IngestConfig          `mapstructure:"ingest"`
+	Quotas       QuotaConfig           `mapstructure:"quotas"`
+	RemoteWrite  RemoteWriteConfig     `mapstructure:"remote_write"`
+	Forward      ForwardConfig         `mapstructure:"forward"`
+	Cluster      ClusterConfig         `mapstructure:"cluster"`
+	Redis        RedisConfig           `mapstructure:"redis"`
+	Cache        CacheConfig           `mapstructure:"cache"`
+	Notify       *config.NotifyConfig  `mapstructure:"notify"`
+	API          APIConfig             `mapstructure:"api"`
+	Log          *config.LogConfig     `mapstructure:"log"`
+	IPInfo       *ipinfo.Config        `mapstructure:"ip_info"`
+	Analysis     AnalysisConfig        `mapstructure:"analysis"`
+	IPChanges    IPChangeConfig        `mapstructure:"ip_changes"`
+	Monitor      AgentMonitorConfig    `mapstructure:"agent_monitor"`
+	Utilization  UtilizationConfig     `mapstructure:"utilization"`
+	NetErrors    NetworkErrorsConfig   `mapstructure:"network_errors"`
+	Anomaly      AnomalyConfig         `mapstructure:"anomaly"`
+	Archive      ArchiveConfig         `mapstructure:"archive"`
+	Decommission DecommissionConfig    `mapstructure:"decommission"`
+	Discovery    DiscoveryConfig       `mapstructure:"discovery"`
+	Inventory    InventoryConfig       `mapstructure:"inventory"`
+	Privacy      PrivacyConfig         `mapstructure:"privacy"`
+	Reports      []ReportConfig        `mapstructure:"reports"`
+	Heartbeat    HeartbeatConfig       `mapstructure:"heartbeat"`
+	AlertRules   []AlertRuleConfig     `mapstructure:"alert_rules"`
+	Scheduler    SchedulerConfig       `mapstructure:"scheduler"`
+	Secrets      *config.SecretsConfig `mapstructure:"secrets"`
+	Debug        *config.DebugConfig   `mapstructure:"debug"`
+	Tracing      *config.TracingConfig `mapstructure:"tracing"`
+	// Timeouts and TLS trust of outbound HTTP requests, the notify section
+	// may override them
+	HTTP *config.HTTPClientConfig `mapstructure:"http"`
 }
 
 // Validate validates the configuration
@@ -31,16 +72,174 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	// Validate outbound HTTP configuration
+	if err := cfg.HTTP.Validate(); err != nil {
+		return fmt.Errorf("invalid http config: %w", err)
+	}
+
 	// Validate notification configuration
 	if err := cfg.Notify.Validate(); err != nil {
 		return fmt.Errorf("invalid notification config: %w", err)
 	}
 
+	// Validate ingest configuration
+	if cfg.Ingest.Enabled {
+		if err := cfg.Ingest.Validate(); err != nil {
+			return fmt.Errorf("invalid ingest config: %w", err)
+		}
+	}
+
+	// Validate quota configuration
+	if cfg.Quotas.Enabled {
+		if err := cfg.Quotas.Validate(); err != nil {
+			return fmt.Errorf("invalid quota config: %w", err)
+		}
+	}
+
+	// Validate remote write configuration
+	if cfg.RemoteWrite.Enabled {
+		if err := cfg.RemoteWrite.Validate(); err != nil {
+			return fmt.Errorf("invalid remote write config: %w", err)
+		}
+	}
+
+	// Validate forwarding configuration
+	if cfg.Forward.Enabled {
+		if err := cfg.Forward.Validate(); err != nil {
+			return fmt.Errorf("invalid forward config: %w", err)
+		}
+	}
+
+	// Validate cluster configuration
+	if cfg.Cluster.Enabled {
+		if err := cfg.Cluster.Validate(); err != nil {
+			return fmt.Errorf("invalid cluster config: %w", err)
+		}
+	}
+
+	// Validate redis configuration
+	if cfg.Redis.Enabled {
+		if err := cfg.Redis.Validate(); err != nil {
+			return fmt.Errorf("invalid redis config: %w", err)
+		}
+	}
+
+	// Validate cache configuration
+	if cfg.Cache.Enabled {
+		if err := cfg.Cache.Validate(); err != nil {
+			return fmt.Errorf("invalid cache config: %w", err)
+		}
+		if cfg.Cache.Backend == "redis" {
+			if err := cfg.Redis.Validate(); err != nil {
+				return fmt.Errorf("invalid redis config: %w", err)
+			}
+		}
+	}
+
+	// Validate agent monitoring configuration
+	if err := cfg.Monitor.Validate(); err != nil {
+		return fmt.Errorf("invalid agent monitor config: %w", err)
+	}
+
+	// Validate utilization alert configuration
+	if err := cfg.Utilization.Validate(); err != nil {
+		return fmt.Errorf("invalid utilization config: %w", err)
+	}
+
+	// Validate network error alert configuration
+	if err := cfg.NetErrors.Validate(); err != nil {
+		return fmt.Errorf("invalid network errors config: %w", err)
+	}
+
+	// Validate anomaly detection configuration
+	if err := cfg.Anomaly.Validate(); err != nil {
+		return fmt.Errorf("invalid anomaly config: %w", err)
+	}
+
+	// Validate archive configuration
+	if err := cfg.Archive.Validate(); err != nil {
+		return fmt.Errorf("invalid archive config: %w", err)
+	}
+
+	// Validate decommission configuration
+	if err := cfg.Decommission.Validate(); err != nil {
+		return fmt.Errorf("invalid decommission config: %w", err)
+	}
+
+	// Validate discovery configuration
+	if err := cfg.Discovery.Validate(); err != nil {
+		return fmt.Errorf("invalid discovery config: %w", err)
+	}
+
+	// Validate inventory configuration
+	if err := cfg.Inventory.Validate(); err != nil {
+		return fmt.Errorf("invalid inventory config: %w", err)
+	}
+
+	// Validate IP change configuration
+	if err := cfg.IPChanges.Validate(); err != nil {
+		return fmt.Errorf("invalid ip changes config: %w", err)
+	}
+
+	// Validate privacy configuration
+	if err := cfg.Privacy.Validate(); err != nil {
+		return fmt.Errorf("invalid privacy config: %w", err)
+	}
+
+	// Validate report configuration
+	names := make(map[string]bool)
+	for i := range cfg.Reports {
+		r := &cfg.Reports[i]
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("invalid report config %q: %w", r.Name, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate report name: %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	// Validate heartbeat configuration
+	if err := cfg.Heartbeat.Validate(); err != nil {
+		return fmt.Errorf("invalid heartbeat config: %w", err)
+	}
+
+	// Validate alert rules
+	names = make(map[string]bool)
+	for i := range cfg.AlertRules {
+		r := &cfg.AlertRules[i]
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("invalid alert rule %q: %w", r.Name, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate alert rule name: %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	// Validate scheduler configuration
+	if err := cfg.Scheduler.Validate(); err != nil {
+		return fmt.Errorf("invalid scheduler config: %w", err)
+	}
+
 	// Validate API configuration
 	if err := cfg.API.Validate(); err != nil {
 		return fmt.Errorf("invalid API config: %w", err)
 	}
 
+	// Validate profiling endpoints configuration
+	if cfg.Debug != nil {
+		if err := cfg.Debug.Validate(); err != nil {
+			return fmt.Errorf("invalid debug config: %w", err)
+		}
+	}
+
+	if cfg.Tracing != nil {
+		if err := cfg.Tracing.Validate(); err != nil {
+			return fmt.Errorf("invalid tracing config: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -51,7 +250,12 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
-	TLS          TLSConfig     `mapstructure:"tls"`
+	// ShutdownTimeout bounds draining in-flight requests and background work on shutdown
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	TLS             TLSConfig     `mapstructure:"tls"`
+	// Timezone shows the times of exports, reports and notifications, e.g.
+	// "Asia/Shanghai". Times are stored and returned by the API in UTC.
+	Timezone string `mapstructure:"timezone"`
 }
 
 // Validate server configuration
@@ -59,6 +263,215 @@ func (cfg *ServerConfig) Validate() error {
 	if cfg.Address == "" {
 		return fmt.Errorf("server address is required")
 	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+// Location returns the display timezone, UTC if unset or invalid
+func (cfg *ServerConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IngestConfig represents the metrics ingest queue configuration, reports
+// are acknowledged once queued and written to the database in batches
+type IngestConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	QueueSize     int           `mapstructure:"queue_size"`
+	Workers       int           `mapstructure:"workers"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// WALDir keeps queued reports on disk so they survive a restart, empty keeps them in memory
+	WALDir string `mapstructure:"wal_dir"`
+	// WALSyncInterval bounds how long written reports wait to be synced to
+	// disk, zero syncs each report before it is acknowledged. Reports
+	// acknowledged within the interval before a crash of the host may be lost.
+	WALSyncInterval time.Duration `mapstructure:"wal_sync_interval"`
+}
+
+// Validate ingest configuration
+func (cfg *IngestConfig) Validate() error {
+	if cfg.QueueSize <= 0 || cfg.Workers <= 0 || cfg.BatchSize <= 0 || cfg.FlushInterval <= 0 {
+		return fmt.Errorf("queue_size, workers, batch_size and flush_interval must be positive")
+	}
+	if cfg.WALSyncInterval < 0 {
+		return fmt.Errorf("wal_sync_interval must not be negative")
+	}
+	return nil
+}
+
+// Quota actions once an agent is over its storage quota
+const (
+	QuotaActionReject   = "reject"   // 507, reports are refused until data is pruned
+	QuotaActionThrottle = "throttle" // 429, agents retry after the check interval
+)
+
+// QuotaConfig represents per agent quotas, which keep a chatty agent from
+// filling the database. Agents over their hourly samples are throttled with
+// 429, agents over their stored rows or bytes are handled by Action, and
+// administrators are alerted once per agent and quota. Zero limits are not
+// enforced. Samples are counted per server replica.
+type QuotaConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	MaxRows           int64         `mapstructure:"max_rows"`             // Stored reports per agent
+	MaxBytes          int64         `mapstructure:"max_bytes"`            // Stored report size per agent
+	MaxSamplesPerHour int64         `mapstructure:"max_samples_per_hour"` // Reports ingested per agent within an hour
+	Action            string        `mapstructure:"action"`               // reject or throttle
+	CheckInterval     time.Duration `mapstructure:"check_interval"`       // How often stored rows and bytes are measured
+	Severity          string        `mapstructure:"severity"`             // Of the admin alert
+}
+
+// Validate quota configuration
+func (cfg *QuotaConfig) Validate() error {
+	if cfg.MaxRows < 0 || cfg.MaxBytes < 0 || cfg.MaxSamplesPerHour < 0 {
+		return fmt.Errorf("max_rows, max_bytes and max_samples_per_hour must not be negative")
+	}
+	if cfg.Action != QuotaActionReject && cfg.Action != QuotaActionThrottle {
+		return fmt.Errorf("invalid action: %s", cfg.Action)
+	}
+	if cfg.CheckInterval < 10*time.Second {
+		return fmt.Errorf("check_interval must be at least 10s")
+	}
+	if !slices.Contains(config.NotificationSeverities, cfg.Severity) {
+		return fmt.Errorf("invalid severity: %s", cfg.Severity)
+	}
+	return nil
+}
+
+// RemoteWriteConfig represents the Prometheus remote_write endpoint, samples
+// of the selected node_exporter network metrics are stored as reports of
+// the agent named by a label, e.g. for hosts where no agent can be installed
+type RemoteWriteConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	AgentLabel  string   `mapstructure:"agent_label"`  // Label naming the agent, its value without port is the agent ID
+	AgentPrefix string   `mapstructure:"agent_prefix"` // Prepended to agent IDs, e.g. to tell them from wameter agents
+	Metrics     []string `mapstructure:"metrics"`      // Metric names to store, defaults to all supported
+}
+
+// Validate remote write configuration
+func (cfg *RemoteWriteConfig) Validate() error {
+	if cfg.AgentLabel == "" {
+		return fmt.Errorf("agent_label is required")
+	}
+	for _, name := range cfg.Metrics {
+		if !remotewrite.Supported(name) {
+			return fmt.Errorf("unsupported metric: %s", name)
+		}
+	}
+	return nil
+}
+
+// ForwardConfig represents forwarding interface metrics of ingested reports
+// to a Graphite or StatsD endpoint. Metric names are the prefix, the values
+// of the forwarded agent tags, the agent ID, interface and metric, or the
+// prefix and metric with the rest as Graphite or DogStatsD tags when tagged.
+type ForwardConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Protocol  string        `mapstructure:"protocol"`   // graphite or statsd
+	Address   string        `mapstructure:"address"`    // host:port
+	Network   string        `mapstructure:"network"`    // tcp or udp, defaults to tcp for graphite and udp for statsd
+	Prefix    string        `mapstructure:"prefix"`     // Of the metric names
+	Metrics   []string      `mapstructure:"metrics"`    // Forwarded interface metrics, see types.InterfaceMetrics
+	Tags      []string      `mapstructure:"tags"`       // Forwarded agent tags, "key" or "key=name" to rename
+	Tagged    bool          `mapstructure:"tagged"`     // Send agent, interface and tags as tags rather than in the name
+	QueueSize int           `mapstructure:"queue_size"` // Reports waiting to be sent, newer ones are dropped when full
+	Timeout   time.Duration `mapstructure:"timeout"`    // Of connecting and writing
+}
+
+// Validate forwarding configuration
+func (cfg *ForwardConfig) Validate() error {
+	switch cfg.Protocol {
+	case "graphite", "statsd":
+	default:
+		return fmt.Errorf("unsupported protocol: %s", cfg.Protocol)
+	}
+	if cfg.Network != "tcp" && cfg.Network != "udp" {
+		return fmt.Errorf("unsupported network: %s", cfg.Network)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return fmt.Errorf("invalid address: %s", cfg.Address)
+	}
+	if len(cfg.Metrics) == 0 {
+		return fmt.Errorf("metrics are required")
+	}
+	for _, name := range cfg.Metrics {
+		if !slices.Contains(types.InterfaceMetrics, name) {
+			return fmt.Errorf("unsupported metric: %s", name)
+		}
+	}
+	for _, tag := range cfg.Tags {
+		if k, _, _ := strings.Cut(tag, "="); strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid tag: %q", tag)
+		}
+	}
+	if cfg.QueueSize <= 0 || cfg.Timeout <= 0 {
+		return fmt.Errorf("queue_size and timeout must be positive")
+	}
+	return nil
+}
+
+// ClusterConfig represents the configuration of replicas sharing a database,
+// scheduled jobs run on the replica holding the leader lease
+type ClusterConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	NodeID        string        `mapstructure:"node_id"` // Defaults to the hostname and a random suffix
+	LeaseTTL      time.Duration `mapstructure:"lease_ttl"`
+	RenewInterval time.Duration `mapstructure:"renew_interval"`
+}
+
+// Validate cluster configuration
+func (cfg *ClusterConfig) Validate() error {
+	if cfg.LeaseTTL <= 0 || cfg.RenewInterval <= 0 {
+		return fmt.Errorf("lease_ttl and renew_interval must be positive")
+	}
+	if cfg.RenewInterval >= cfg.LeaseTTL {
+		return fmt.Errorf("renew_interval must be shorter than lease_ttl")
+	}
+	return nil
+}
+
+// RedisConfig represents the Redis server sharing agent status among replicas
+type RedisConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Addr         string        `mapstructure:"addr"`
+	Username     string        `mapstructure:"username"`
+	Password     string        `mapstructure:"password"`
+	DB           int           `mapstructure:"db"`
+	KeyPrefix    string        `mapstructure:"key_prefix"`
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// Validate redis configuration
+func (cfg *RedisConfig) Validate() error {
+	if cfg.Addr == "" {
+		return fmt.Errorf("redis address is required")
+	}
+	return nil
+}
+
+// CacheConfig represents the cache of latest metrics and metrics summaries,
+// the entries of an agent are dropped when it reports new metrics
+type CacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Backend string        `mapstructure:"backend"` // memory or redis, redis uses the redis connection settings
+	TTL     time.Duration `mapstructure:"ttl"`
+}
+
+// Validate cache configuration
+func (cfg *CacheConfig) Validate() error {
+	if cfg.Backend != "memory" && cfg.Backend != "redis" {
+		return fmt.Errorf("unsupported cache backend: %s", cfg.Backend)
+	}
+	if cfg.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
 	return nil
 }
 
@@ -92,9 +505,24 @@ type APIConfig struct {
 	// CORS settings
 	CORS CORSConfig `mapstructure:"cors"`
 
+	// Logging of requests
+	AccessLog AccessLogConfig `mapstructure:"access_log"`
+
 	// Rate limiting
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
 
+	// Largest request body accepted by metric ingestion, in bytes
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+
+	// Largest request body accepted by metrics backfill, in bytes
+	MaxBackfillSize int64 `mapstructure:"max_backfill_size"`
+
+	// Bounds of the metrics reports accepted
+	MetricsLimits MetricsLimitsConfig `mapstructure:"metrics_limits"`
+
+	// Protocol negotiated with agents on registration
+	Protocol ProtocolConfig `mapstructure:"protocol"`
+
 	// Metrics
 	Metrics MetricsConfig `mapstructure:"metrics"`
 
@@ -109,6 +537,82 @@ func (cfg *APIConfig) Validate() error {
 			return fmt.Errorf("invalid auth config: %w", err)
 		}
 	}
+	if cfg.RateLimit.Enabled {
+		if err := cfg.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("invalid rate limit config: %w", err)
+		}
+	}
+	if cfg.CORS.Enabled {
+		if err := cfg.CORS.Validate(); err != nil {
+			return fmt.Errorf("invalid cors config: %w", err)
+		}
+	}
+	if err := cfg.AccessLog.Validate(); err != nil {
+		return fmt.Errorf("invalid access log config: %w", err)
+	}
+	if err := cfg.MetricsLimits.Validate(); err != nil {
+		return fmt.Errorf("invalid metrics limits config: %w", err)
+	}
+	if err := cfg.Protocol.Validate(); err != nil {
+		return fmt.Errorf("invalid protocol config: %w", err)
+	}
+	return nil
+}
+
+// MetricsLimitsConfig represents the bounds of the metrics reports accepted
+type MetricsLimitsConfig struct {
+	MaxInterfaces  int `mapstructure:"max_interfaces"`   // Interfaces per report
+	MaxAddresses   int `mapstructure:"max_addresses"`    // Addresses per interface and IP version
+	MaxRoutes      int `mapstructure:"max_routes"`       // Routes per report
+	MaxIPChanges   int `mapstructure:"max_ip_changes"`   // IP changes per report
+	MaxNameLength  int `mapstructure:"max_name_length"`  // Interface names
+	MaxStringField int `mapstructure:"max_string_field"` // Other interface strings, e.g. type or flags
+}
+
+// Validate metrics limits configuration
+func (cfg *MetricsLimitsConfig) Validate() error {
+	if cfg.MaxInterfaces < 0 || cfg.MaxAddresses < 0 || cfg.MaxRoutes < 0 ||
+		cfg.MaxIPChanges < 0 || cfg.MaxNameLength < 0 || cfg.MaxStringField < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// Limits returns the limits of the configuration
+func (cfg *MetricsLimitsConfig) Limits() *types.MetricsLimits {
+	return &types.MetricsLimits{
+		MaxInterfaces:  cfg.MaxInterfaces,
+		MaxAddresses:   cfg.MaxAddresses,
+		MaxRoutes:      cfg.MaxRoutes,
+		MaxIPChanges:   cfg.MaxIPChanges,
+		MaxNameLength:  cfg.MaxNameLength,
+		MaxStringField: cfg.MaxStringField,
+	}
+}
+
+// ProtocolConfig represents the protocol negotiated with agents on
+// registration
+type ProtocolConfig struct {
+	MinAPIVersion int      `mapstructure:"min_api_version"` // Older agents are refused, 0 accepts agents not negotiating
+	Features      []string `mapstructure:"features"`        // Features offered to agents
+	Encodings     []string `mapstructure:"encodings"`       // Report encodings offered, in order of preference
+}
+
+// Validate protocol configuration
+func (cfg *ProtocolConfig) Validate() error {
+	if cfg.MinAPIVersion < 0 || cfg.MinAPIVersion > types.AgentAPIVersion {
+		return fmt.Errorf("min api version must be between 0 and %d", types.AgentAPIVersion)
+	}
+	for _, feature := range cfg.Features {
+		if !slices.Contains(types.AgentFeatures, feature) {
+			return fmt.Errorf("unknown feature: %s", feature)
+		}
+	}
+	for _, encoding := range cfg.Encodings {
+		if compress.Identity(encoding) || !compress.Supported(encoding) {
+			return fmt.Errorf("unsupported encoding: %s", encoding)
+		}
+	}
 	return nil
 }
 
@@ -138,13 +642,15 @@ func (cfg *AuthConfig) Validate() error {
 	return nil
 }
 
-// CORSConfig represents the CORS configuration
+// CORSConfig represents the CORS configuration. Allowed origins are exact
+// origins, "*" for any, or patterns such as "https://*.example.com".
 type CORSConfig struct {
 	Enabled          bool     `mapstructure:"enabled"`
 	AllowedOrigins   []string `mapstructure:"allowed_origins"`
 	AllowedMethods   []string `mapstructure:"allowed_methods"`
 	AllowedHeaders   []string `mapstructure:"allowed_headers"`
-	MaxAge           int      `mapstructure:"max_age"`
+	ExposedHeaders   []string `mapstructure:"exposed_headers"` // Response headers readable by the browser
+	MaxAge           int      `mapstructure:"max_age"`         // Of preflight responses, in seconds
 	AllowCredentials bool     `mapstructure:"allow_credentials"`
 }
 
@@ -153,24 +659,69 @@ func (cfg *CORSConfig) Validate() error {
 	if len(cfg.AllowedOrigins) == 0 {
 		return fmt.Errorf("allowed origins list is required")
 	}
+	for _, origin := range cfg.AllowedOrigins {
+		if _, err := path.Match(origin, ""); err != nil {
+			return fmt.Errorf("invalid allowed origin %q: %w", origin, err)
+		}
+		if origin == "*" && cfg.AllowCredentials {
+			return fmt.Errorf("allow_credentials requires explicit allowed origins, not \"*\"")
+		}
+	}
+	if cfg.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative")
+	}
+	return nil
+}
+
+// Access log levels
+const (
+	AccessLogDebug = "debug"
+	AccessLogInfo  = "info"
+	AccessLogNone  = "none"
+)
+
+// AccessLogConfig represents the logging of requests. Server errors are
+// logged as errors and slow requests as warnings at any level but none.
+type AccessLogConfig struct {
+	Level         string        `mapstructure:"level"`          // debug, info or none
+	SkipPaths     []string      `mapstructure:"skip_paths"`     // Not logged, e.g. probes
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"` // Requests logged as slow, 5s by default
+}
+
+// Validate access log configuration
+func (cfg *AccessLogConfig) Validate() error {
+	switch cfg.Level {
+	case AccessLogDebug, AccessLogInfo, AccessLogNone:
+	default:
+		return fmt.Errorf("unsupported level: %s", cfg.Level)
+	}
+	if cfg.SlowThreshold < 0 {
+		return fmt.Errorf("slow_threshold must not be negative")
+	}
 	return nil
 }
 
-// RateLimitConfig represents the rate limiting configuration
+// RateLimitConfig represents the rate limiting configuration, requests are
+// limited per client IP and per API key
 type RateLimitConfig struct {
-	Enabled  bool          `mapstructure:"enabled"`
-	Requests int           `mapstructure:"requests"`
-	Window   time.Duration `mapstructure:"window"`
-	Strategy string        `mapstructure:"strategy"` // token, leaky, sliding
+	Enabled     bool          `mapstructure:"enabled"`
+	Requests    int           `mapstructure:"requests"`     // Per client IP and window
+	KeyRequests int           `mapstructure:"key_requests"` // Per API key and window
+	Window      time.Duration `mapstructure:"window"`
+	Burst       int           `mapstructure:"burst"`
+	Strategy    string        `mapstructure:"strategy"` // token, leaky
 }
 
 // Validate rate limiting configuration
 func (cfg *RateLimitConfig) Validate() error {
 	switch cfg.Strategy {
-	case "token", "leaky", "sliding":
+	case "token", "leaky":
 	default:
 		return fmt.Errorf("unsupported rate limit strategy: %s", cfg.Strategy)
 	}
+	if cfg.Requests <= 0 || cfg.KeyRequests <= 0 || cfg.Window <= 0 {
+		return fmt.Errorf("requests, key_requests and window must be positive")
+	}
 	return nil
 }
 
@@ -207,74 +758,1204 @@ func (cfg *DocsConfig) Validate() error {
 	return nil
 }
 
-// LoadConfig loads server configuration from file
-func LoadConfig(path string) (*Config, error) {
-	v := viper.New()
-	v.SetConfigFile(path)
-	v.SetConfigType("yaml")
+// AnalysisConfig represents the IP change analysis configuration
+type AnalysisConfig struct {
+	BaselineDays     int           `mapstructure:"baseline_days"`
+	Window           time.Duration `mapstructure:"window"`
+	AnomalyThreshold float64       `mapstructure:"anomaly_threshold"`
+	// NotifyOnlyAnomalies suppresses IP change notifications unless the change rate is anomalous
+	NotifyOnlyAnomalies bool `mapstructure:"notify_only_anomalies"`
+}
 
-	// Read config file
-	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
+// IPChangeConfig represents the notification of IP changes
+type IPChangeConfig struct {
+	Severity string `mapstructure:"severity"` // Of notifications outside expected windows, info by default
+	// ExpectedWindows are recurring windows in which IP changes are expected
+	ExpectedWindows []IPChangeWindowConfig `mapstructure:"expected_windows"`
+}
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+// IPChangeWindowConfig represents a recurring window in which IP changes
+// are expected
+type IPChangeWindowConfig struct {
+	Name       string        `mapstructure:"name"`
+	Schedule   string        `mapstructure:"schedule"`   // Cron expression of the start of the window
+	Duration   time.Duration `mapstructure:"duration"`   // Length of the window
+	Timezone   string        `mapstructure:"timezone"`   // IANA time zone of the schedule, defaults to server.timezone
+	Agents     []string      `mapstructure:"agents"`     // Agent ID patterns, e.g. "edge-*", empty for all agents
+	Interfaces []string      `mapstructure:"interfaces"` // Interface names, "external" for the external IP, empty for all
+	Severity   string        `mapstructure:"severity"`   // Of the notifications, "none" to suppress them, info by default
+}
+
+// Validate IP change configuration
+func (cfg *IPChangeConfig) Validate() error {
+	if cfg.Severity != "" && !slices.Contains(config.NotificationSeverities, cfg.Severity) {
+		return fmt.Errorf("invalid severity: %s", cfg.Severity)
 	}
+	return ValidateIPChangeWindows(cfg.Windows())
+}
 
-	// Set defaults
-	setDefaults(&cfg)
+// Windows returns the expected windows of the configuration
+func (cfg *IPChangeConfig) Windows() []types.IPChangeWindow {
+	windows := make([]types.IPChangeWindow, 0, len(cfg.ExpectedWindows))
+	for _, w := range cfg.ExpectedWindows {
+		window := types.IPChangeWindow{
+			Name:       w.Name,
+			Schedule:   w.Schedule,
+			Duration:   w.Duration,
+			Timezone:   w.Timezone,
+			Agents:     w.Agents,
+			Interfaces: w.Interfaces,
+			Severity:   w.Severity,
+		}
+		if window.Severity == "" {
+			window.Severity = "info"
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+// ValidateIPChangeWindows validates expected IP change windows
+func ValidateIPChangeWindows(windows []types.IPChangeWindow) error {
+	names := make(map[string]bool, len(windows))
+	for _, w := range windows {
+		if w.Name == "" {
+			return fmt.Errorf("window name is required")
+		}
+		if names[w.Name] {
+			return fmt.Errorf("duplicate window: %s", w.Name)
+		}
+		names[w.Name] = true
+		if _, err := cron.Parse(w.Schedule); err != nil {
+			return fmt.Errorf("window %q: invalid schedule: %w", w.Name, err)
+		}
+		if w.Duration <= 0 {
+			return fmt.Errorf("window %q: duration must be positive", w.Name)
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("window %q: invalid timezone: %w", w.Name, err)
+		}
+		for _, pattern := range w.Agents {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("window %q: invalid agent pattern %q", w.Name, pattern)
+			}
+		}
+		if w.Severity != types.IPChangeSeverityNone && !slices.Contains(config.NotificationSeverities, w.Severity) {
+			return fmt.Errorf("window %q: invalid severity: %s", w.Name, w.Severity)
+		}
 	}
+	return nil
+}
 
-	return &cfg, nil
+// AgentMonitorConfig represents the agent offline detection configuration,
+// an agent silent for longer than its offline threshold at MissedChecks
+// consecutive checks is reported offline
+type AgentMonitorConfig struct {
+	CheckInterval    time.Duration      `mapstructure:"check_interval"`
+	OfflineThreshold time.Duration      `mapstructure:"offline_threshold"`
+	MissedChecks     int                `mapstructure:"missed_checks"`
+	Groups           []AgentGroupConfig `mapstructure:"groups"`
+	// LateGrace extends the offline thresholds of all agents, so reports
+	// delayed by the collection jitter or phase of agents are still on time
+	LateGrace time.Duration `mapstructure:"late_grace"`
 }
 
-// setDefaults sets default values for configuration
-func setDefaults(cfg *Config) {
-	if cfg.Server.Address == "" {
-		cfg.Server.Address = ":8080"
-	}
+// AgentGroupConfig overrides offline detection for agents whose ID matches
+// one of its patterns, zero values keep the defaults
+type AgentGroupConfig struct {
+	Name             string        `mapstructure:"name"`
+	Agents           []string      `mapstructure:"agents"` // Agent ID patterns, e.g. "edge-*"
+	OfflineThreshold time.Duration `mapstructure:"offline_threshold"`
+	MissedChecks     int           `mapstructure:"missed_checks"`
+}
 
-	if cfg.Server.MetricsPath == "" {
-		cfg.Server.MetricsPath = "/metrics"
+// Validate agent monitoring configuration
+func (cfg *AgentMonitorConfig) Validate() error {
+	if cfg.CheckInterval < 0 || cfg.OfflineThreshold < 0 || cfg.MissedChecks < 0 || cfg.LateGrace < 0 {
+		return fmt.Errorf("check_interval, offline_threshold, missed_checks and late_grace must not be negative")
 	}
-
-	if cfg.Server.ReadTimeout == 0 {
-		cfg.Server.ReadTimeout = 30 * time.Second
+	for _, g := range cfg.Groups {
+		if len(g.Agents) == 0 {
+			return fmt.Errorf("group %q: agents are required", g.Name)
+		}
+		for _, pattern := range g.Agents {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("group %q: invalid agent pattern %q", g.Name, pattern)
+			}
+		}
+		if g.OfflineThreshold < 0 || g.MissedChecks < 0 {
+			return fmt.Errorf("group %q: offline_threshold and missed_checks must not be negative", g.Name)
+		}
 	}
+	return nil
+}
 
-	if cfg.Server.WriteTimeout == 0 {
-		cfg.Server.WriteTimeout = 30 * time.Second
+// Thresholds returns the offline threshold and missed checks of an agent,
+// taken from the first group matching its ID, the threshold includes the
+// late grace
+func (cfg *AgentMonitorConfig) Thresholds(agentID string) (time.Duration, int) {
+	threshold, missed := cfg.OfflineThreshold, cfg.MissedChecks
+	for _, g := range cfg.Groups {
+		if !g.matches(agentID) {
+			continue
+		}
+		if g.OfflineThreshold > 0 {
+			threshold = g.OfflineThreshold
+		}
+		if g.MissedChecks > 0 {
+			missed = g.MissedChecks
+		}
+		break
 	}
+	return threshold + cfg.LateGrace, missed
+}
 
-	if cfg.API.RateLimit.Window == 0 {
-		cfg.API.RateLimit.Window = time.Minute
+// matches reports whether agentID matches a pattern of the group
+func (g *AgentGroupConfig) matches(agentID string) bool {
+	for _, pattern := range g.Agents {
+		if ok, _ := path.Match(pattern, agentID); ok {
+			return true
+		}
 	}
+	return false
+}
 
-	if cfg.API.RateLimit.Requests == 0 {
-		cfg.API.RateLimit.Requests = 60
-	}
+// UtilizationConfig represents the high bandwidth utilization alert of
+// interfaces, by the busier direction. Interfaces with a known link speed
+// alert above Percent of it, others above Absolute.
+type UtilizationConfig struct {
+	Percent   float64                      `mapstructure:"percent"`  // Of the link speed
+	Absolute  float64                      `mapstructure:"absolute"` // Bytes/s
+	Overrides []UtilizationThresholdConfig `mapstructure:"overrides"`
+}
 
-	if cfg.API.CORS.MaxAge == 0 {
-		cfg.API.CORS.MaxAge = 86400
-	}
+// UtilizationThresholdConfig overrides the thresholds of interfaces whose
+// name matches one of its patterns or whose type is listed, zero values
+// keep the defaults
+type UtilizationThresholdConfig struct {
+	Interfaces []string `mapstructure:"interfaces"` // Name patterns, e.g. "eth*"
+	Types      []string `mapstructure:"types"`      // e.g. wireless, vpn
+	Percent    float64  `mapstructure:"percent"`
+	Absolute   float64  `mapstructure:"absolute"`
+}
 
-	// Set default allowed methods for CORS
-	if len(cfg.API.CORS.AllowedMethods) == 0 {
-		cfg.API.CORS.AllowedMethods = []string{
-			"GET", "POST", "PUT", "DELETE", "OPTIONS",
+// Validate utilization alert configuration
+func (cfg *UtilizationConfig) Validate() error {
+	if cfg.Percent < 0 || cfg.Percent > 100 || cfg.Absolute < 0 {
+		return fmt.Errorf("percent must be within 0 and 100 and absolute must not be negative")
+	}
+	for i, o := range cfg.Overrides {
+		if len(o.Interfaces) == 0 && len(o.Types) == 0 {
+			return fmt.Errorf("override %d: interfaces or types are required", i)
+		}
+		for _, pattern := range o.Interfaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("override %d: invalid interface pattern %q", i, pattern)
+			}
+		}
+		if o.Percent < 0 || o.Percent > 100 || o.Absolute < 0 {
+			return fmt.Errorf("override %d: percent must be within 0 and 100 and absolute must not be negative", i)
 		}
 	}
+	return nil
+}
 
-	// Set default allowed headers for CORS
-	if len(cfg.API.CORS.AllowedHeaders) == 0 {
-		cfg.API.CORS.AllowedHeaders = []string{
-			"Content-Type", "Authorization", "X-Request-ID",
+// NetworkErrorsConfig represents the network error alerts of interfaces.
+// Error counters are cumulative, so an interface is alerted when its
+// errors grow faster than Rate and at least MinErrors errors were counted
+// since its last alert, at most once per Cooldown.
+type NetworkErrorsConfig struct {
+	Rate      float64       `mapstructure:"rate"`       // Errors/s between reports, both directions
+	MinErrors uint64        `mapstructure:"min_errors"` // Errors since the last alert
+	Cooldown  time.Duration `mapstructure:"cooldown"`   // Between alerts of an interface
+}
+
+// Validate network error alert configuration
+func (cfg *NetworkErrorsConfig) Validate() error {
+	if cfg.Rate < 0 || cfg.Cooldown < 0 {
+		return fmt.Errorf("rate and cooldown must not be negative")
+	}
+	return nil
+}
+
+// Anomaly detection seasonality, baselines are learned per hour of the day
+// or of the week
+const (
+	SeasonalityNone   = "none"
+	SeasonalityDaily  = "daily"
+	SeasonalityWeekly = "weekly"
+)
+
+// AnomalyMetrics are the interface metrics anomaly detection learns, rates
+// of the error and drop counters are summed over both directions
+var AnomalyMetrics = []string{
+	"rx_bytes_rate", "tx_bytes_rate", "rx_packets_rate", "tx_packets_rate",
+	"errors_rate", "drops_rate",
+}
+
+// AnomalyConfig represents anomaly detection on interface rates, which
+// alerts on deviations from a baseline learned per interface (EWMA of the
+// mean and variance) instead of fixed thresholds. Baselines are kept in
+// memory and learned again after a restart.
+type AnomalyConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	Metrics     []string `mapstructure:"metrics"`     // See AnomalyMetrics
+	Interfaces  []string `mapstructure:"interfaces"`  // Name patterns, empty for all
+	Seasonality string   `mapstructure:"seasonality"` // none, daily or weekly
+	Timezone    string   `mapstructure:"timezone"`    // Of the seasonal hours, defaults to UTC
+	// Deviation from the baseline mean in standard deviations and relative
+	// to the mean, both must be exceeded
+	Sensitivity float64       `mapstructure:"sensitivity"`
+	MinChange   float64       `mapstructure:"min_change"`
+	Direction   string        `mapstructure:"direction"`   // up, down or both
+	Alpha       float64       `mapstructure:"alpha"`       // EWMA smoothing factor of each sample
+	WarmUp      time.Duration `mapstructure:"warm_up"`     // Learning before alerting, defaults to a season
+	MinSamples  int           `mapstructure:"min_samples"` // Samples of an hour baseline before alerting
+	Severity    string        `mapstructure:"severity"`
+	// Skip the fixed utilization and error alerts of interfaces whose
+	// baseline has warmed up
+	ReplaceThresholds bool `mapstructure:"replace_thresholds"`
+}
+
+// Validate anomaly detection configuration
+func (cfg *AnomalyConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	for _, m := range cfg.Metrics {
+		if !slices.Contains(AnomalyMetrics, m) {
+			return fmt.Errorf("unknown metric: %s", m)
+		}
+	}
+	for _, pattern := range cfg.Interfaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid interface pattern %q", pattern)
+		}
+	}
+	switch cfg.Seasonality {
+	case SeasonalityNone, SeasonalityDaily, SeasonalityWeekly:
+	default:
+		return fmt.Errorf("invalid seasonality: %s", cfg.Seasonality)
+	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	switch cfg.Direction {
+	case "up", "down", "both":
+	default:
+		return fmt.Errorf("invalid direction: %s", cfg.Direction)
+	}
+	if cfg.Sensitivity <= 0 || cfg.MinChange < 0 {
+		return fmt.Errorf("sensitivity must be positive and min_change must not be negative")
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha >= 1 {
+		return fmt.Errorf("alpha must be within 0 and 1")
+	}
+	if cfg.WarmUp < 0 || cfg.MinSamples < 0 {
+		return fmt.Errorf("warm_up and min_samples must not be negative")
+	}
+	if !slices.Contains(config.NotificationSeverities, cfg.Severity) {
+		return fmt.Errorf("invalid severity: %s", cfg.Severity)
+	}
+	return nil
+}
+
+// Matches reports whether anomaly detection covers an interface
+func (cfg *AnomalyConfig) Matches(name string) bool {
+	if len(cfg.Interfaces) == 0 {
+		return true
+	}
+	for _, pattern := range cfg.Interfaces {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Buckets returns the number of hourly baselines of the seasonality
+func (cfg *AnomalyConfig) Buckets() int {
+	switch cfg.Seasonality {
+	case SeasonalityDaily:
+		return 24
+	case SeasonalityWeekly:
+		return 7 * 24
+	}
+	return 1
+}
+
+// Thresholds returns the percent and absolute thresholds of an interface,
+// taken from the first override matching it
+func (cfg *UtilizationConfig) Thresholds(name, ifaceType string) (float64, float64) {
+	percent, absolute := cfg.Percent, cfg.Absolute
+	for _, o := range cfg.Overrides {
+		if !o.matches(name, ifaceType) {
+			continue
+		}
+		if o.Percent > 0 {
+			percent = o.Percent
+		}
+		if o.Absolute > 0 {
+			absolute = o.Absolute
+		}
+		break
+	}
+	return percent, absolute
+}
+
+// matches reports whether the interface matches the override
+func (o *UtilizationThresholdConfig) matches(name, ifaceType string) bool {
+	if slices.Contains(o.Types, ifaceType) {
+		return true
+	}
+	for _, pattern := range o.Interfaces {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveConfig represents the storage of metrics archives
+type ArchiveConfig struct {
+	Dir string   `mapstructure:"dir"` // Directory of file archives
+	S3  S3Config `mapstructure:"s3"`
+}
+
+// S3Config represents the S3 bucket of metrics archives, credentials are read
+// from the standard AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables
+type S3Config struct {
+	Bucket string `mapstructure:"bucket"`
+	Region string `mapstructure:"region"`
+	Prefix string `mapstructure:"prefix"`
+	// Endpoint of an S3 compatible service, objects are addressed path style
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// Validate archive configuration
+func (cfg *ArchiveConfig) Validate() error {
+	if cfg.S3.Endpoint != "" {
+		if u, err := url.Parse(cfg.S3.Endpoint); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid s3 endpoint: %s", cfg.S3.Endpoint)
+		}
+	}
+	return nil
+}
+
+// DecommissionConfig represents the retirement of agents, the data of a
+// retired agent is purged once the grace period has passed
+type DecommissionConfig struct {
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	// Archive stores the metrics of retired agents before they are purged, "file", "s3" or empty for none
+	Archive string `mapstructure:"archive"`
+}
+
+// Validate decommission configuration
+func (cfg *DecommissionConfig) Validate() error {
+	if cfg.GracePeriod < 0 {
+		return fmt.Errorf("grace_period must not be negative")
+	}
+	switch cfg.Archive {
+	case "", "file", "s3":
+	default:
+		return fmt.Errorf("unsupported archive storage: %s", cfg.Archive)
+	}
+	return nil
+}
+
+// DiscoveryConfig represents the discovery of expected agents
+type DiscoveryConfig struct {
+	Kubernetes KubernetesDiscoveryConfig `mapstructure:"kubernetes"`
+}
+
+// Validate discovery configuration
+func (cfg *DiscoveryConfig) Validate() error {
+	if err := cfg.Kubernetes.Validate(); err != nil {
+		return fmt.Errorf("kubernetes: %w", err)
+	}
+	return nil
+}
+
+// KubernetesDiscoveryConfig represents watching the Kubernetes API for agent
+// pods. Each pod is expected to run an agent, identified by the ID
+// annotation or else by its node name as hostname.
+type KubernetesDiscoveryConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	APIServer           string        `mapstructure:"api_server"`           // Defaults to the in-cluster API server
+	TokenFile           string        `mapstructure:"token_file"`           // Bearer token, re-read as it rotates
+	CAFile              string        `mapstructure:"ca_file"`              // CA of the API server
+	Namespace           string        `mapstructure:"namespace"`            // Empty for all namespaces
+	LabelSelector       string        `mapstructure:"label_selector"`       // Selects the agent pods
+	IDAnnotation        string        `mapstructure:"id_annotation"`        // Pod annotation holding the agent ID
+	TenantID            string        `mapstructure:"tenant_id"`            // Tenant of pre-registered agents
+	RegistrationTimeout time.Duration `mapstructure:"registration_timeout"` // Wait for an expected agent before it is missing
+	CleanupAfter        time.Duration `mapstructure:"cleanup_after"`        // Delay before agents of deleted pods are deleted
+	KeepDeleted         bool          `mapstructure:"keep_deleted"`         // Keep agents whose pods were deleted
+	ResyncInterval      time.Duration `mapstructure:"resync_interval"`      // Full relist of the pods
+}
+
+// Validate Kubernetes discovery configuration
+func (cfg *KubernetesDiscoveryConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.APIServer != "" {
+		if u, err := url.Parse(cfg.APIServer); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid api_server: %s", cfg.APIServer)
+		}
+	}
+	if cfg.RegistrationTimeout <= 0 || cfg.CleanupAfter <= 0 || cfg.ResyncInterval <= 0 {
+		return fmt.Errorf("registration_timeout, cleanup_after and resync_interval must be positive")
+	}
+	return nil
+}
+
+// InventoryConfig represents the agents expected to report, an expected
+// agent that never registers or disappears is alerted apart from offline
+// alerts. Expectations declared through the API are checked as well.
+type InventoryConfig struct {
+	Agents              []ExpectedAgentConfig `mapstructure:"agents"`
+	Counts              []ExpectedCountConfig `mapstructure:"counts"`
+	CheckInterval       time.Duration         `mapstructure:"check_interval"`
+	RegistrationTimeout time.Duration         `mapstructure:"registration_timeout"` // Wait for a newly expected agent to register
+	MissingAfter        time.Duration         `mapstructure:"missing_after"`        // Silence after which an agent has disappeared
+}
+
+// ExpectedAgentConfig represents an agent expected by ID
+type ExpectedAgentConfig struct {
+	ID       string `mapstructure:"id"`
+	Hostname string `mapstructure:"hostname"`
+}
+
+// ExpectedCountConfig represents a minimum number of reporting agents with
+// all of the tags
+type ExpectedCountConfig struct {
+	Name string   `mapstructure:"name"`
+	Tags []string `mapstructure:"tags"` // key=value, e.g. "site=fra1"
+	Min  int      `mapstructure:"min"`
+}
+
+// Validate inventory configuration
+func (cfg *InventoryConfig) Validate() error {
+	if cfg.CheckInterval < 0 || cfg.RegistrationTimeout < 0 || cfg.MissingAfter < 0 {
+		return fmt.Errorf("check_interval, registration_timeout and missing_after must not be negative")
+	}
+	return ValidateInventory(cfg.Inventory())
+}
+
+// Inventory returns the expectations of the configuration
+func (cfg *InventoryConfig) Inventory() *types.Inventory {
+	inv := &types.Inventory{}
+	for _, a := range cfg.Agents {
+		inv.Agents = append(inv.Agents, types.ExpectedAgent{ID: a.ID, Hostname: a.Hostname})
+	}
+	for _, c := range cfg.Counts {
+		tags := make(map[string]string, len(c.Tags))
+		for _, tag := range c.Tags {
+			k, v, _ := strings.Cut(tag, "=")
+			tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		inv.Counts = append(inv.Counts, types.ExpectedCount{Name: c.Name, Tags: tags, Min: c.Min})
+	}
+	return inv
+}
+
+// ValidateInventory validates expected agents and counts
+func ValidateInventory(inv *types.Inventory) error {
+	ids := make(map[string]bool)
+	for _, a := range inv.Agents {
+		if a.ID == "" {
+			return fmt.Errorf("agent id is required")
+		}
+		if ids[a.ID] {
+			return fmt.Errorf("duplicate expected agent: %s", a.ID)
+		}
+		ids[a.ID] = true
+	}
+	names := make(map[string]bool)
+	for _, c := range inv.Counts {
+		if c.Name == "" {
+			return fmt.Errorf("count name is required")
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate expected count: %s", c.Name)
+		}
+		names[c.Name] = true
+		if len(c.Tags) == 0 {
+			return fmt.Errorf("count %q: tags are required", c.Name)
 		}
+		for k := range c.Tags {
+			if k == "" {
+				return fmt.Errorf("count %q: tags must be key=value", c.Name)
+			}
+		}
+		if c.Min <= 0 {
+			return fmt.Errorf("count %q: min must be positive", c.Name)
+		}
+	}
+	return nil
+}
+
+// PrivacyConfig represents the redaction of IP addresses of agents before
+// they are stored or notified. Hashed addresses stay distinct, so address
+// changes are still detected, truncated ones only beyond the prefix.
+type PrivacyConfig struct {
+	IPAddresses string `mapstructure:"ip_addresses"` // "hash", "truncate" or empty to keep addresses
+	// HashSecret keys the hashes, so addresses cannot be recovered by hashing all of them
+	HashSecret string `mapstructure:"hash_secret"`
+	IPv4Prefix int    `mapstructure:"ipv4_prefix"` // Bits kept when truncating
+	IPv6Prefix int    `mapstructure:"ipv6_prefix"`
+}
+
+// Validate privacy configuration
+func (cfg *PrivacyConfig) Validate() error {
+	switch cfg.IPAddresses {
+	case "", "truncate":
+	case "hash":
+		if cfg.HashSecret == "" {
+			return fmt.Errorf("hash_secret is required to hash IP addresses")
+		}
+	default:
+		return fmt.Errorf("unsupported ip_addresses redaction: %s", cfg.IPAddresses)
+	}
+	if cfg.IPv4Prefix < 0 || cfg.IPv4Prefix > 32 {
+		return fmt.Errorf("ipv4_prefix must be between 0 and 32")
+	}
+	if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+		return fmt.Errorf("ipv6_prefix must be between 0 and 128")
+	}
+	return nil
+}
+
+// AlertRuleConfig represents a custom alert on the metrics reported by
+// agents, the condition is an HCL expression such as
+// network.interfaces["eth0"].stats.rx_rate > 50MB && agent.tags.env == "prod"
+// The alert is sent when the condition becomes true on an agent.
+type AlertRuleConfig struct {
+	Name      string            `mapstructure:"name"`
+	Condition string            `mapstructure:"condition"`
+	Message   string            `mapstructure:"message"`  // Template, e.g. "rx at ${format_rate(...)}"
+	Severity  string            `mapstructure:"severity"` // info, warning or critical
+	Labels    map[string]string `mapstructure:"labels"`   // Templates enriching the alert
+}
+
+// Validate alert rule configuration
+func (cfg *AlertRuleConfig) Validate() error {
+	if cfg.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if cfg.Condition == "" {
+		return fmt.Errorf("condition is required")
+	}
+	if !slices.Contains(config.NotificationSeverities, cfg.Severity) {
+		return fmt.Errorf("invalid severity: %s", cfg.Severity)
+	}
+	_, err := cfg.Compile()
+	return err
+}
+
+// Compile compiles the rule
+func (cfg *AlertRuleConfig) Compile() (*rules.Rule, error) {
+	return rules.Compile(cfg.Name, cfg.Severity, cfg.Condition, cfg.Message, cfg.Labels)
+}
+
+// ReportConfig represents a summary report sent through the notifiers on a
+// cron schedule, covering the fleet or the agents matching Agents
+type ReportConfig struct {
+	Name     string `mapstructure:"name"`
+	Schedule string `mapstructure:"schedule"` // Cron expression or descriptor such as @daily
+	Period   string `mapstructure:"period"`   // Range covered before each run, "daily" or "weekly"
+	Timezone string `mapstructure:"timezone"` // IANA time zone of the schedule, defaults to server.timezone
+	Tenant   string `mapstructure:"tenant"`   // Limits the report to a tenant, empty for all
+	// Agents are agent ID patterns, e.g. "edge-*", empty for all agents
+	Agents []string `mapstructure:"agents"`
+	// Notifiers limits delivery to these notifier types, empty for all enabled
+	Notifiers []string `mapstructure:"notifiers"`
+}
+
+// Validate report configuration
+func (cfg *ReportConfig) Validate() error {
+	if cfg.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := cron.Parse(cfg.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if cfg.Duration() == 0 {
+		return fmt.Errorf("unsupported period: %s", cfg.Period)
+	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	for _, pattern := range cfg.Agents {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid agent pattern %q", pattern)
+		}
+	}
+	return checkNotifiers(cfg.Notifiers)
+}
+
+// checkNotifiers reports unknown notifier types
+func checkNotifiers(names []string) error {
+	for _, name := range names {
+		switch name {
+		case "email", "telegram", "slack", "wechat", "dingtalk", "discord", "webhook", "feishu", "exec":
+		default:
+			return fmt.Errorf("unknown notifier: %s", name)
+		}
+	}
+	return nil
+}
+
+// HeartbeatConfig represents the periodic liveness event of the server. It
+// is pinged to a dead man's switch such as healthchecks.io, which alerts
+// when the pings stop, and may be sent to notifiers.
+type HeartbeatConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	URL      string        `mapstructure:"url"`      // Pinged while the server is healthy
+	FailURL  string        `mapstructure:"fail_url"` // Pinged while a critical check fails, the ping is skipped when empty
+	Method   string        `mapstructure:"method"`   // GET, or POST with the heartbeat as JSON
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// Notifiers are sent the heartbeat, none by default
+	Notifiers []string `mapstructure:"notifiers"`
+}
+
+// Validate heartbeat configuration
+func (cfg *HeartbeatConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Interval < 10*time.Second {
+		return fmt.Errorf("interval must be at least 10s")
+	}
+	if cfg.URL == "" && len(cfg.Notifiers) == 0 {
+		return fmt.Errorf("url or notifiers are required")
+	}
+	for _, endpoint := range []string{cfg.URL, cfg.FailURL} {
+		if endpoint == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url: %s", endpoint)
+		}
+	}
+	if cfg.Method != http.MethodGet && cfg.Method != http.MethodPost {
+		return fmt.Errorf("unsupported method: %s", cfg.Method)
+	}
+	return checkNotifiers(cfg.Notifiers)
+}
+
+// Periodic tasks of the server run by the job scheduler
+const (
+	JobCleanup         = "cleanup"              // Prunes data past its retention and purges retired agents
+	JobAgentMonitoring = "agent_monitoring"     // Offline checks and expired maintenances
+	JobReports         = "reports"              // Sends the scheduled reports that are due
+	JobInventoryCheck  = "inventory_check"      // Checks the expected inventory
+	JobHeartbeat       = "heartbeat"            // Sends the heartbeat of the server
+	JobQuotas          = "quotas"               // Measures the storage of agents for quotas
+	JobDiscovery       = "kubernetes_discovery" // Reconciles the agents of discovered pods
+)
+
+// Jobs lists the periodic tasks of the server
+var Jobs = []string{
+	JobCleanup, JobAgentMonitoring, JobReports, JobInventoryCheck, JobHeartbeat, JobQuotas, JobDiscovery,
+}
+
+// Overlap policies of jobs, for runs due while the previous run of the job
+// is still in progress
+const (
+	JobOverlapForbid  = "forbid"  // The due run is skipped
+	JobOverlapAllow   = "allow"   // The runs overlap
+	JobOverlapReplace = "replace" // The previous run is canceled
+)
+
+// SchedulerConfig represents the schedules of the periodic tasks of the
+// server, by job name. Jobs not listed run at the interval of their
+// settings, e.g. database.prune_interval for cleanup.
+type SchedulerConfig struct {
+	Jobs map[string]JobConfig `mapstructure:"jobs"`
+}
+
+// JobConfig represents the schedule of a periodic task
+type JobConfig struct {
+	Disabled bool          `mapstructure:"disabled"`
+	Schedule string        `mapstructure:"schedule"` // Cron expression or "@every <duration>", the interval of its settings by default
+	Timeout  time.Duration `mapstructure:"timeout"`  // Runs are canceled after it, none by default
+	Overlap  string        `mapstructure:"overlap"`  // forbid, allow or replace, forbid by default
+}
+
+// Validate scheduler configuration
+func (cfg *SchedulerConfig) Validate() error {
+	for name, job := range cfg.Jobs {
+		if !slices.Contains(Jobs, name) {
+			return fmt.Errorf("unknown job: %s", name)
+		}
+		if job.Schedule != "" {
+			if _, err := cron.Parse(job.Schedule); err != nil {
+				return fmt.Errorf("invalid schedule of job %s: %w", name, err)
+			}
+		}
+		if job.Timeout < 0 {
+			return fmt.Errorf("timeout of job %s must not be negative", name)
+		}
+		switch job.Overlap {
+		case "", JobOverlapForbid, JobOverlapAllow, JobOverlapReplace:
+		default:
+			return fmt.Errorf("invalid overlap policy of job %s: %s", name, job.Overlap)
+		}
+	}
+	return nil
+}
+
+// Job returns the schedule of a job, with the default overlap policy
+func (cfg *SchedulerConfig) Job(name string) JobConfig {
+	job := cfg.Jobs[name]
+	if job.Overlap == "" {
+		job.Overlap = JobOverlapForbid
+	}
+	return job
+}
+
+// Duration returns the range covered by the report, zero for an unsupported period
+func (cfg *ReportConfig) Duration() time.Duration {
+	switch cfg.Period {
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// Includes reports whether the report covers an agent
+func (cfg *ReportConfig) Includes(agentID string) bool {
+	if len(cfg.Agents) == 0 {
+		return true
+	}
+	for _, pattern := range cfg.Agents {
+		if ok, _ := path.Match(pattern, agentID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfig loads server configuration from file, overlaid by WAMETER_
+// environment variables and then by command line overrides
+func LoadConfig(path string, overrides config.Overrides) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+
+	// Read config file
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := config.Load(v, &cfg, overrides); err != nil {
+		return nil, err
+	}
+
+	// Set defaults
+	setDefaults(&cfg)
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// setDefaults sets default values for configuration
+func setDefaults(cfg *Config) {
+	if cfg.Log != nil {
+		cfg.Log.SetDefaults()
+	}
+
+	if cfg.Debug == nil {
+		cfg.Debug = &config.DebugConfig{}
+	}
+	cfg.Debug.SetDefaults("localhost:6060")
+
+	if cfg.Tracing == nil {
+		cfg.Tracing = &config.TracingConfig{}
+	}
+	cfg.Tracing.SetDefaults("wameter-server")
+
+	if cfg.HTTP == nil {
+		cfg.HTTP = config.HTTPClientDefaultConfig()
+	}
+	cfg.HTTP.SetDefaults()
+	if cfg.Notify != nil {
+		if cfg.Notify.HTTP == nil {
+			cfg.Notify.HTTP = cfg.HTTP
+		}
+		cfg.Notify.HTTP.SetDefaults()
+	}
+
+	if cfg.Server.Address == "" {
+		cfg.Server.Address = ":8080"
+	}
+
+	if cfg.Server.MetricsPath == "" {
+		cfg.Server.MetricsPath = "/metrics"
+	}
+
+	if cfg.Server.ReadTimeout == 0 {
+		cfg.Server.ReadTimeout = 30 * time.Second
+	}
+
+	if cfg.Server.WriteTimeout == 0 {
+		cfg.Server.WriteTimeout = 30 * time.Second
+	}
+
+	if cfg.Server.ShutdownTimeout == 0 {
+		cfg.Server.ShutdownTimeout = 30 * time.Second
+	}
+
+	if cfg.Server.Timezone == "" {
+		cfg.Server.Timezone = "UTC"
+	}
+	if cfg.Notify != nil && cfg.Notify.Timezone == "" {
+		cfg.Notify.Timezone = cfg.Server.Timezone
+	}
+
+	if cfg.Ingest.QueueSize == 0 {
+		cfg.Ingest.QueueSize = 10000
+	}
+
+	if cfg.Ingest.Workers == 0 {
+		cfg.Ingest.Workers = 4
+	}
+
+	if cfg.Ingest.BatchSize == 0 {
+		cfg.Ingest.BatchSize = 100
+	}
+
+	if cfg.Ingest.FlushInterval == 0 {
+		cfg.Ingest.FlushInterval = time.Second
+	}
+
+	if cfg.Quotas.Action == "" {
+		cfg.Quotas.Action = QuotaActionReject
+	}
+	if cfg.Quotas.CheckInterval == 0 {
+		cfg.Quotas.CheckInterval = 5 * time.Minute
+	}
+	if cfg.Quotas.Severity == "" {
+		cfg.Quotas.Severity = "warning"
+	}
+
+	if cfg.RemoteWrite.AgentLabel == "" {
+		cfg.RemoteWrite.AgentLabel = "instance"
+	}
+	if len(cfg.RemoteWrite.Metrics) == 0 {
+		cfg.RemoteWrite.Metrics = remotewrite.Metrics()
+	}
+
+	if cfg.Forward.Network == "" {
+		cfg.Forward.Network = "tcp"
+		if cfg.Forward.Protocol == "statsd" {
+			cfg.Forward.Network = "udp"
+		}
+	}
+	if cfg.Forward.Prefix == "" {
+		cfg.Forward.Prefix = "wameter"
+	}
+	if len(cfg.Forward.Metrics) == 0 {
+		cfg.Forward.Metrics = []string{"rx_bytes_rate", "tx_bytes_rate", "rx_errors", "tx_errors"}
+	}
+	if cfg.Forward.QueueSize == 0 {
+		cfg.Forward.QueueSize = 1000
+	}
+	if cfg.Forward.Timeout == 0 {
+		cfg.Forward.Timeout = 5 * time.Second
+	}
+
+	if cfg.Cluster.LeaseTTL == 0 {
+		cfg.Cluster.LeaseTTL = 15 * time.Second
+	}
+
+	if cfg.Cluster.RenewInterval == 0 {
+		cfg.Cluster.RenewInterval = 5 * time.Second
+	}
+
+	if cfg.Redis.KeyPrefix == "" {
+		cfg.Redis.KeyPrefix = "wameter:"
+	}
+
+	if cfg.Redis.DialTimeout == 0 {
+		cfg.Redis.DialTimeout = 5 * time.Second
+	}
+
+	if cfg.Redis.ReadTimeout == 0 {
+		cfg.Redis.ReadTimeout = 3 * time.Second
+	}
+
+	if cfg.Redis.WriteTimeout == 0 {
+		cfg.Redis.WriteTimeout = 3 * time.Second
+	}
+
+	if cfg.Cache.Backend == "" {
+		cfg.Cache.Backend = "memory"
+	}
+
+	if cfg.Cache.TTL == 0 {
+		cfg.Cache.TTL = 30 * time.Second
+	}
+
+	if cfg.API.RateLimit.Window == 0 {
+		cfg.API.RateLimit.Window = time.Minute
+	}
+
+	if cfg.API.RateLimit.Requests == 0 {
+		cfg.API.RateLimit.Requests = 60
+	}
+
+	if cfg.API.RateLimit.KeyRequests == 0 {
+		cfg.API.RateLimit.KeyRequests = cfg.API.RateLimit.Requests
+	}
+
+	if cfg.API.RateLimit.Burst == 0 {
+		cfg.API.RateLimit.Burst = cfg.API.RateLimit.Requests
+	}
+
+	if cfg.API.RateLimit.Strategy == "" {
+		cfg.API.RateLimit.Strategy = "token"
+	}
+
+	if cfg.API.MaxBodySize == 0 {
+		cfg.API.MaxBodySize = 1 << 20
+	}
+
+	if cfg.API.MaxBackfillSize == 0 {
+		cfg.API.MaxBackfillSize = 32 << 20
+	}
+
+	limits := &cfg.API.MetricsLimits
+	if limits.MaxInterfaces == 0 {
+		limits.MaxInterfaces = 256
+	}
+	if limits.MaxAddresses == 0 {
+		limits.MaxAddresses = 64
+	}
+	if limits.MaxRoutes == 0 {
+		limits.MaxRoutes = 1024
+	}
+	if limits.MaxIPChanges == 0 {
+		limits.MaxIPChanges = 256
+	}
+	if limits.MaxNameLength == 0 {
+		limits.MaxNameLength = 64
+	}
+	if limits.MaxStringField == 0 {
+		limits.MaxStringField = 256
+	}
+
+	if cfg.API.Protocol.Features == nil {
+		cfg.API.Protocol.Features = slices.Clone(types.AgentFeatures)
+	}
+	if cfg.API.Protocol.Encodings == nil {
+		cfg.API.Protocol.Encodings = []string{compress.Zstd, compress.Gzip}
+	}
+
+	if cfg.API.Docs.Path == "" {
+		cfg.API.Docs.Path = "/docs"
+	}
+
+	if cfg.API.Docs.Title == "" {
+		cfg.API.Docs.Title = "Wameter API"
+	}
+
+	if cfg.API.CORS.MaxAge == 0 {
+		cfg.API.CORS.MaxAge = 86400
+	}
+
+	// Set default allowed methods for CORS
+	if len(cfg.API.CORS.AllowedMethods) == 0 {
+		cfg.API.CORS.AllowedMethods = []string{
+			"GET", "POST", "PUT", "DELETE", "OPTIONS",
+		}
+	}
+
+	if cfg.IPInfo == nil {
+		cfg.IPInfo = &ipinfo.Config{}
+	}
+
+	if cfg.Utilization.Percent == 0 {
+		cfg.Utilization.Percent = 80
+	}
+
+	if cfg.Utilization.Absolute == 0 {
+		cfg.Utilization.Absolute = 100 * 1024 * 1024 // 100 MB/s
+	}
+
+	if cfg.IPChanges.Severity == "" {
+		cfg.IPChanges.Severity = "info"
+	}
+
+	if cfg.Analysis.BaselineDays == 0 {
+		cfg.Analysis.BaselineDays = 30
+	}
+
+	if cfg.Analysis.Window == 0 {
+		cfg.Analysis.Window = 24 * time.Hour
+	}
+
+	if cfg.Analysis.AnomalyThreshold == 0 {
+		cfg.Analysis.AnomalyThreshold = 3
+	}
+
+	if cfg.Monitor.CheckInterval == 0 {
+		cfg.Monitor.CheckInterval = time.Minute
+	}
+
+	if cfg.Monitor.OfflineThreshold == 0 {
+		cfg.Monitor.OfflineThreshold = 5 * time.Minute
+	}
+
+	if cfg.Monitor.MissedChecks == 0 {
+		cfg.Monitor.MissedChecks = 1
+	}
+
+	if cfg.Archive.Dir == "" {
+		cfg.Archive.Dir = "/var/lib/wameter/archives"
+	}
+
+	if cfg.Decommission.GracePeriod == 0 {
+		cfg.Decommission.GracePeriod = 7 * 24 * time.Hour
+	}
+
+	k8s := &cfg.Discovery.Kubernetes
+	if k8s.TokenFile == "" {
+		k8s.TokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	}
+	if k8s.CAFile == "" {
+		k8s.CAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	}
+	if k8s.LabelSelector == "" {
+		k8s.LabelSelector = "app.kubernetes.io/name=wameter-agent"
+	}
+	if k8s.IDAnnotation == "" {
+		k8s.IDAnnotation = "wameter.io/agent-id"
+	}
+	if k8s.RegistrationTimeout == 0 {
+		k8s.RegistrationTimeout = 5 * time.Minute
+	}
+	if k8s.CleanupAfter == 0 {
+		k8s.CleanupAfter = 15 * time.Minute
+	}
+	if k8s.ResyncInterval == 0 {
+		k8s.ResyncInterval = 5 * time.Minute
+	}
+
+	if cfg.NetErrors.Rate == 0 {
+		cfg.NetErrors.Rate = 1
+	}
+	if cfg.NetErrors.MinErrors == 0 {
+		cfg.NetErrors.MinErrors = 100
+	}
+	if cfg.NetErrors.Cooldown == 0 {
+		cfg.NetErrors.Cooldown = 15 * time.Minute
+	}
+
+	anomaly := &cfg.Anomaly
+	if len(anomaly.Metrics) == 0 {
+		anomaly.Metrics = []string{"rx_bytes_rate", "tx_bytes_rate", "errors_rate"}
+	}
+	if anomaly.Seasonality == "" {
+		anomaly.Seasonality = SeasonalityDaily
+	}
+	if anomaly.Timezone == "" {
+		anomaly.Timezone = "UTC"
+	}
+	if anomaly.Sensitivity == 0 {
+		anomaly.Sensitivity = 3
+	}
+	if anomaly.MinChange == 0 {
+		anomaly.MinChange = 0.5
+	}
+	if anomaly.Direction == "" {
+		anomaly.Direction = "up"
+	}
+	if anomaly.Alpha == 0 {
+		anomaly.Alpha = 0.05
+	}
+	if anomaly.WarmUp == 0 {
+		anomaly.WarmUp = time.Duration(anomaly.Buckets()) * time.Hour
+	}
+	if anomaly.MinSamples == 0 {
+		anomaly.MinSamples = 10
+	}
+	if anomaly.Severity == "" {
+		anomaly.Severity = "warning"
+	}
+
+	if cfg.Inventory.CheckInterval == 0 {
+		cfg.Inventory.CheckInterval = time.Minute
+	}
+	if cfg.Inventory.RegistrationTimeout == 0 {
+		cfg.Inventory.RegistrationTimeout = 15 * time.Minute
+	}
+	if cfg.Inventory.MissingAfter == 0 {
+		cfg.Inventory.MissingAfter = time.Hour
+	}
+
+	if cfg.Privacy.IPv4Prefix == 0 {
+		cfg.Privacy.IPv4Prefix = 24
+	}
+	if cfg.Privacy.IPv6Prefix == 0 {
+		cfg.Privacy.IPv6Prefix = 48
+	}
+
+	for i := range cfg.AlertRules {
+		if cfg.AlertRules[i].Severity == "" {
+			cfg.AlertRules[i].Severity = "warning"
+		}
+	}
+
+	if cfg.Heartbeat.Interval == 0 {
+		cfg.Heartbeat.Interval = 5 * time.Minute
+	}
+	if cfg.Heartbeat.Method == "" {
+		cfg.Heartbeat.Method = http.MethodGet
+	}
+	cfg.Heartbeat.Method = strings.ToUpper(cfg.Heartbeat.Method)
+	if cfg.Heartbeat.Timeout == 0 {
+		cfg.Heartbeat.Timeout = 10 * time.Second
+	}
+
+	for i := range cfg.Reports {
+		if cfg.Reports[i].Period == "" {
+			cfg.Reports[i].Period = "daily"
+		}
+		if cfg.Reports[i].Timezone == "" {
+			cfg.Reports[i].Timezone = cfg.Server.Timezone
+		}
+	}
+
+	// Set default allowed headers for CORS
+	if len(cfg.API.CORS.AllowedHeaders) == 0 {
+		cfg.API.CORS.AllowedHeaders = []string{
+			"Content-Type", "Authorization", "X-Request-ID",
+		}
+	}
+	if len(cfg.API.CORS.ExposedHeaders) == 0 {
+		cfg.API.CORS.ExposedHeaders = []string{
+			"X-Request-ID", "X-Timezone", "Retry-After", "Content-Disposition",
+		}
+	}
+
+	if cfg.API.AccessLog.Level == "" {
+		cfg.API.AccessLog.Level = AccessLogInfo
+	}
+	if cfg.API.AccessLog.SkipPaths == nil {
+		cfg.API.AccessLog.SkipPaths = []string{"/healthz", "/readyz"}
+	}
+	if cfg.API.AccessLog.SlowThreshold == 0 {
+		cfg.API.AccessLog.SlowThreshold = 5 * time.Second
 	}
 }
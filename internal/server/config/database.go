@@ -15,15 +15,18 @@ type DatabaseConfig struct {
 	QueryTimeout    time.Duration `mapstructure:"query_timeout"`
 
 	// Migration settings
-	AutoMigrate    bool   `mapstructure:"auto_migrate"`
-	MigrationsPath string `mapstructure:"migrations_path"`
-	RollbackSteps  int    `mapstructure:"rollback_steps,omitempty"`
-	TargetVersion  int    `mapstructure:"target_version,omitempty"`
+	AutoMigrate          bool          `mapstructure:"auto_migrate"`
+	MigrationsPath       string        `mapstructure:"migrations_path"`
+	MigrationLockTimeout time.Duration `mapstructure:"migration_lock_timeout"`
+	RollbackSteps        int           `mapstructure:"rollback_steps,omitempty"`
+	TargetVersion        int           `mapstructure:"target_version,omitempty"`
 
 	// Data retention settings
-	EnablePruning    bool          `mapstructure:"enable_pruning"`
-	MetricsRetention time.Duration `mapstructure:"metrics_retention"`
-	PruneInterval    time.Duration `mapstructure:"prune_interval"`
+	EnablePruning       bool          `mapstructure:"enable_pruning"`
+	MetricsRetention    time.Duration `mapstructure:"metrics_retention"`
+	AgentLogRetention   time.Duration `mapstructure:"agent_log_retention"`
+	AnnotationRetention time.Duration `mapstructure:"annotation_retention"`
+	PruneInterval       time.Duration `mapstructure:"prune_interval"`
 
 	// Query performance settings
 	MaxBatchSize   int           `mapstructure:"max_batch_size"`
@@ -61,12 +64,21 @@ func (c *DatabaseConfig) Validate() error {
 	if c.QueryTimeout == 0 {
 		c.QueryTimeout = 30 * time.Second
 	}
+	if c.MigrationLockTimeout == 0 {
+		c.MigrationLockTimeout = time.Minute
+	}
 	if c.PruneInterval == 0 {
 		c.PruneInterval = 24 * time.Hour
 	}
 	if c.MetricsRetention == 0 {
 		c.MetricsRetention = 30 * 24 * time.Hour // 30 days
 	}
+	if c.AgentLogRetention == 0 {
+		c.AgentLogRetention = 7 * 24 * time.Hour // 7 days
+	}
+	if c.AnnotationRetention == 0 {
+		c.AnnotationRetention = 90 * 24 * time.Hour // 90 days
+	}
 	if c.MaxBatchSize == 0 {
 		c.MaxBatchSize = 1000
 	}
@@ -3,10 +3,15 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 	"wameter/internal/database"
+	"wameter/internal/server/tenant"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
@@ -26,18 +31,23 @@ func NewAgentRepository(db database.Interface, logger *zap.Logger) AgentReposito
 	}
 }
 
+// agentColumns are the columns scanned by scanAgent
+const agentColumns = "id, tenant_id, hostname, version, status, tags, maintenance, protocol, last_seen, registered_at, updated_at"
+
 // Save saves or updates an agent
 func (r *agentRepository) Save(ctx context.Context, agent *types.AgentInfo) error {
 	query := `INSERT INTO agents (
-                id, hostname, version, status,
+                id, tenant_id, hostname, version, status, tags, protocol,
                 last_seen, registered_at, updated_at
-            ) VALUES (?, ?, ?, ?, ?, ?, ?)`
+            ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	if r.db.Driver() == "postgres" {
 		query += `ON CONFLICT (id) DO UPDATE SET
                 hostname = EXCLUDED.hostname,
                 version = EXCLUDED.version,
                 status = EXCLUDED.status,
+                tags = EXCLUDED.tags,
+                protocol = EXCLUDED.protocol,
                 last_seen = EXCLUDED.last_seen,
                 updated_at = EXCLUDED.updated_at`
 		// Convert placeholders for postgres
@@ -47,18 +57,29 @@ func (r *agentRepository) Save(ctx context.Context, agent *types.AgentInfo) erro
                 hostname = VALUES(hostname),
                 version = VALUES(version),
                 status = VALUES(status),
+                tags = VALUES(tags),
+                protocol = VALUES(protocol),
                 last_seen = VALUES(last_seen),
                 updated_at = VALUES(updated_at)`
-	} else if r.db.Driver() == "sqlite" {
-		query = `INSERT INTO agents (
-                id, hostname, version, status,
-                last_seen, registered_at, updated_at
-            ) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	}
+
+	// Agents keep the tenant they were registered to
+	if agent.TenantID == "" {
+		agent.TenantID = tenant.OrDefault(ctx)
+	}
+
+	tags, err := marshalTags(agent.Tags)
+	if err != nil {
+		return err
+	}
+	protocol, err := marshalProtocol(agent.Protocol)
+	if err != nil {
+		return err
 	}
 
 	result, err := r.db.ExecContext(ctx, query,
-		agent.ID, agent.Hostname, agent.Version,
-		agent.Status, agent.LastSeen, agent.RegisteredAt,
+		agent.ID, agent.TenantID, agent.Hostname, agent.Version,
+		agent.Status, tags, protocol, agent.LastSeen, agent.RegisteredAt,
 		agent.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to save agent: %w", err)
@@ -78,27 +99,17 @@ func (r *agentRepository) Save(ctx context.Context, agent *types.AgentInfo) erro
 
 // FindByID returns agent by ID
 func (r *agentRepository) FindByID(ctx context.Context, id string) (*types.AgentInfo, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
-        SELECT id, hostname, version, status,
-               last_seen, registered_at, updated_at
+        SELECT ` + agentColumns + `
         FROM agents
-        WHERE id = ?`
+        WHERE id = ?` + cond
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	var agent types.AgentInfo
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&agent.ID,
-		&agent.Hostname,
-		&agent.Version,
-		&agent.Status,
-		&agent.LastSeen,
-		&agent.RegisteredAt,
-		&agent.UpdatedAt,
-	)
-
+	agent, err := scanAgent(r.db.QueryRowContext(ctx, query, append([]any{id}, args...)...))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, types.ErrAgentNotFound
 	}
@@ -106,20 +117,34 @@ func (r *agentRepository) FindByID(ctx context.Context, id string) (*types.Agent
 		return nil, fmt.Errorf("failed to query agent: %w", err)
 	}
 
-	return &agent, nil
+	return agent, nil
 }
 
 // UpdateAgent updates an existing agent
 func (r *agentRepository) UpdateAgent(ctx context.Context, agent *types.AgentInfo) error {
+	tags, err := marshalTags(agent.Tags)
+	if err != nil {
+		return err
+	}
+	protocol, err := marshalProtocol(agent.Protocol)
+	if err != nil {
+		return err
+	}
+
+	cond, args := tenantCond(ctx, "tenant_id")
 	qb := database.NewQueryBuilder(r.db.Driver())
 	qb.Raw(
-		"UPDATE agents SET hostname = ?, version = ?, status = ?, last_seen = ?, updated_at = ? WHERE id = ?",
-		agent.Hostname,
-		agent.Version,
-		agent.Status,
-		agent.LastSeen,
-		time.Now(),
-		agent.ID,
+		"UPDATE agents SET hostname = ?, version = ?, status = ?, tags = ?, protocol = ?, last_seen = ?, updated_at = ? WHERE id = ?"+cond,
+		append([]any{
+			agent.Hostname,
+			agent.Version,
+			agent.Status,
+			tags,
+			protocol,
+			agent.LastSeen,
+			time.Now(),
+			agent.ID,
+		}, args...)...,
 	)
 
 	result, err := r.db.ExecContext(ctx, qb.SQL(), qb.Args()...)
@@ -139,20 +164,27 @@ func (r *agentRepository) UpdateAgent(ctx context.Context, agent *types.AgentInf
 	return nil
 }
 
-// UpdateStatus updates agent status
+// UpdateStatus updates agent status, last seen is only moved when the agent
+// is online so it keeps the time an offline agent was last heard from
 func (r *agentRepository) UpdateStatus(ctx context.Context, id string, status types.AgentStatus) error {
+	now := time.Now()
+	set, values := "status = ?, updated_at = ?", []any{status, now}
+	if status == types.AgentStatusOnline {
+		set, values = "status = ?, last_seen = ?, updated_at = ?", []any{status, now, now}
+	}
+
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         UPDATE agents
-        SET status = ?, last_seen = ?, updated_at = ?
-        WHERE id = ?`
+        SET ` + set + `
+        WHERE id = ?` + cond
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	now := time.Now()
 	result, err := r.db.ExecContext(ctx, query,
-		status, now, now, id)
+		append(append(values, id), args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to update agent status: %w", err)
 	}
@@ -169,12 +201,67 @@ func (r *agentRepository) UpdateStatus(ctx context.Context, id string, status ty
 	return nil
 }
 
+// SetMaintenance puts an agent into maintenance, nil ends it
+func (r *agentRepository) SetMaintenance(ctx context.Context, id string, m *types.AgentMaintenance) error {
+	var maintenance sql.NullString
+	if m != nil {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal agent maintenance: %w", err)
+		}
+		maintenance = sql.NullString{String: string(data), Valid: true}
+	}
+
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "UPDATE agents SET maintenance = ?, updated_at = ? WHERE id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, append([]any{maintenance, time.Now(), id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update agent maintenance: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return types.ErrAgentNotFound
+	}
+
+	return nil
+}
+
 // List returns all agents
 func (r *agentRepository) List(ctx context.Context) ([]*types.AgentInfo, error) {
+	return r.ListWithPagination(ctx, &types.AgentFilter{})
+}
+
+// ListWithPagination returns a page of the agents matching the filter
+func (r *agentRepository) ListWithPagination(ctx context.Context, filter *types.AgentFilter) ([]*types.AgentInfo, error) {
 	qb := database.NewQueryBuilder(r.db.Driver())
-	qb.Select("id, hostname, version, status, last_seen, registered_at, updated_at").
-		From("agents").
-		OrderBy("hostname")
+	qb.Select(agentColumns).
+		From("agents")
+	whereAgentFilter(ctx, qb, filter)
+
+	// Sort by ID last so pages are stable
+	sortBy, order := "hostname", "ASC"
+	if slices.Contains(types.AgentSortFields, filter.SortBy) {
+		sortBy = filter.SortBy
+	}
+	if filter.SortDesc {
+		order = "DESC"
+	}
+	orderBy := []string{sortBy + " " + order}
+	if sortBy != "id" {
+		orderBy = append(orderBy, "id "+order)
+	}
+	qb.OrderBy(orderBy...).
+		Limit(filter.Limit).
+		Offset(filter.Offset)
 
 	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
 	if err != nil {
@@ -187,16 +274,11 @@ func (r *agentRepository) List(ctx context.Context) ([]*types.AgentInfo, error)
 
 	var agents []*types.AgentInfo
 	for rows.Next() {
-		agent := &types.AgentInfo{}
-		err := rows.Scan(
-			&agent.ID,
-			&agent.Hostname,
-			&agent.Version,
-			&agent.Status,
-			&agent.LastSeen,
-			&agent.RegisteredAt,
-			&agent.UpdatedAt,
-		)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("context canceled while scanning agents: %w", err)
+		}
+
+		agent, err := scanAgent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent: %w", err)
 		}
@@ -210,52 +292,65 @@ func (r *agentRepository) List(ctx context.Context) ([]*types.AgentInfo, error)
 	return agents, nil
 }
 
-// ListWithPagination returns agents with pagination
-func (r *agentRepository) ListWithPagination(ctx context.Context, limit, offset int) ([]*types.AgentInfo, error) {
+// Count returns the number of agents matching the filter, limit and offset
+// are ignored
+func (r *agentRepository) Count(ctx context.Context, filter *types.AgentFilter) (int64, error) {
 	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select("COUNT(*)").
+		From("agents")
+	whereAgentFilter(ctx, qb, filter)
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, qb.SQL(), qb.Args()...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count agents: %w", err)
+	}
 
-	qb.Select("id, hostname, version, status, last_seen, registered_at, updated_at").
-		From("agents").
-		OrderBy("hostname").
-		Limit(limit).
-		Offset(offset)
+	return count, nil
+}
+
+// ListStatuses returns the status of the agents matching the filter by
+// agent ID, with the time of their latest report, in a single query. Sort,
+// limit and offset are ignored.
+func (r *agentRepository) ListStatuses(ctx context.Context, filter *types.AgentFilter) (map[string]*types.AgentStatusInfo, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select(
+		"id",
+		"status",
+		"last_seen",
+		// Served by the index of metrics on agent and time
+		"(SELECT MAX(m.timestamp) FROM metrics m WHERE m.agent_id = agents.id) as latest_sample",
+	).
+		From("agents")
+	whereAgentFilter(ctx, qb, filter)
 
 	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query agents: %w", err)
+		return nil, fmt.Errorf("failed to query agent statuses: %w", err)
 	}
 
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
-	var agents []*types.AgentInfo
+	statuses := make(map[string]*types.AgentStatusInfo)
 	for rows.Next() {
-		if err := ctx.Err(); err != nil {
-			return nil, fmt.Errorf("context canceled while scanning agents: %w", err)
+		var id string
+		var status types.AgentStatusInfo
+		var latestSample aggregateTime
+		if err := rows.Scan(&id, &status.Status, &status.LastSeen, &latestSample); err != nil {
+			return nil, fmt.Errorf("failed to scan agent status: %w", err)
 		}
-
-		agent := &types.AgentInfo{}
-		err := rows.Scan(
-			&agent.ID,
-			&agent.Hostname,
-			&agent.Version,
-			&agent.Status,
-			&agent.LastSeen,
-			&agent.RegisteredAt,
-			&agent.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan agent: %w", err)
+		if !latestSample.Time.IsZero() {
+			status.LatestSample = &latestSample.Time
 		}
-		agents = append(agents, agent)
+		statuses[id] = &status
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating agents: %w", err)
+		return nil, fmt.Errorf("error iterating agent statuses: %w", err)
 	}
 
-	return agents, nil
+	return statuses, nil
 }
 
 // Delete deletes an agent and all associated data
@@ -271,13 +366,24 @@ func (r *agentRepository) Delete(ctx context.Context, id string) error {
 			return err
 		}
 
+		// Delete associated diagnostics bundles
+		if err := r.deleteAgentDiagnostics(ctx, tx, id); err != nil {
+			return err
+		}
+
+		// Delete associated shipped logs
+		if err := r.deleteAgentLogs(ctx, tx, id); err != nil {
+			return err
+		}
+
 		// Delete the agent
-		query := "DELETE FROM agents WHERE id = ?"
+		cond, args := tenantCond(ctx, "tenant_id")
+		query := "DELETE FROM agents WHERE id = ?" + cond
 		if r.db.Driver() == "postgres" {
 			query = database.ConvertPlaceholders(query)
 		}
 
-		result, err := tx.ExecContext(ctx, query, id)
+		result, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
 		if err != nil {
 			return fmt.Errorf("failed to delete agent: %w", err)
 		}
@@ -341,51 +447,37 @@ func (r *agentRepository) GetAgentMetrics(ctx context.Context, id string) (*type
 	return metrics, nil
 }
 
-// getAgentNetworkStats retrieves network statistics for an agent
+// getAgentNetworkStats retrieves network statistics for an agent from the
+// hot columns of its reports. Bandwidth and errors are the growth of the
+// cumulative interface counters, the error rate is per report.
 func (r *agentRepository) getAgentNetworkStats(ctx context.Context, id string, metrics *types.AgentMetrics) error {
 	qb := database.NewQueryBuilder(r.db.Driver())
 
-	// Build query
-	var jsonQuery string
-	switch r.db.Driver() {
-	case "postgres":
-		jsonQuery = "data->'metrics'->'network'"
-	case "mysql":
-		jsonQuery = "JSON_EXTRACT(data, '$.metrics.network')"
-	case "sqlite":
-		jsonQuery = "json_extract(data, '$.metrics.network')"
-	default:
-		return fmt.Errorf("unsupported database driver: %s", r.db.Driver())
-	}
-
 	qb.Select(
-		fmt.Sprintf("COUNT(DISTINCT %s->'interfaces') as interface_count", jsonQuery),
-		fmt.Sprintf("SUM(CAST(%s->'total_bandwidth' AS BIGINT)) as total_bandwidth", jsonQuery),
-		fmt.Sprintf("AVG(CAST(%s->'error_rate' AS FLOAT)) as error_rate", jsonQuery),
+		"COUNT(*) as reports",
+		"COALESCE(MAX(interface_count), 0) as interface_count",
+		"COALESCE(MAX(total_rx_bytes) - MIN(total_rx_bytes) + MAX(total_tx_bytes) - MIN(total_tx_bytes), 0) as total_bandwidth",
+		"COALESCE(MAX(error_count) - MIN(error_count), 0) as errors",
 	).
 		From("metrics").
 		Where("agent_id = ?", id).
-		Where(fmt.Sprintf("%s IS NOT NULL", jsonQuery))
-
-	var totalBandwidth sql.NullInt64
-	var errorRate sql.NullFloat64
+		Where("has_network = TRUE")
+	whereTenant(ctx, qb, "tenant_id")
 
+	var reports, totalBandwidth, errorCount int64
 	err := r.db.QueryRowContext(ctx, qb.SQL(), qb.Args()...).Scan(
+		&reports,
 		&metrics.NetworkStats.InterfaceCount,
 		&totalBandwidth,
-		&errorRate,
+		&errorCount,
 	)
-
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("failed to get network stats: %w", err)
 	}
 
-	if totalBandwidth.Valid {
-		metrics.NetworkStats.TotalBandwidth = uint64(totalBandwidth.Int64)
-	}
-
-	if errorRate.Valid {
-		metrics.NetworkStats.ErrorRate = errorRate.Float64
+	metrics.NetworkStats.TotalBandwidth = uint64(max(totalBandwidth, 0))
+	if reports > 0 {
+		metrics.NetworkStats.ErrorRate = float64(max(errorCount, 0)) / float64(reports)
 	}
 
 	return nil
@@ -393,12 +485,13 @@ func (r *agentRepository) getAgentNetworkStats(ctx context.Context, id string, m
 
 // deleteAgentMetrics deletes all metrics for an agent
 func (r *agentRepository) deleteAgentMetrics(ctx context.Context, tx *sql.Tx, id string) error {
-	query := "DELETE FROM metrics WHERE agent_id = ?"
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM metrics WHERE agent_id = ?" + cond
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	_, err := tx.ExecContext(ctx, query, id)
+	_, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to delete agent metrics: %w", err)
 	}
@@ -408,15 +501,150 @@ func (r *agentRepository) deleteAgentMetrics(ctx context.Context, tx *sql.Tx, id
 
 // deleteAgentIPChanges deletes all IP changes for an agent
 func (r *agentRepository) deleteAgentIPChanges(ctx context.Context, tx *sql.Tx, id string) error {
-	query := "DELETE FROM ip_changes WHERE agent_id = ?"
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM ip_changes WHERE agent_id = ?" + cond
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	_, err := tx.ExecContext(ctx, query, id)
+	_, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to delete agent ip changes: %w", err)
 	}
 
 	return nil
 }
+
+// deleteAgentDiagnostics deletes the diagnostics bundles of an agent
+func (r *agentRepository) deleteAgentDiagnostics(ctx context.Context, tx *sql.Tx, id string) error {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM agent_diagnostics WHERE agent_id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	_, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent diagnostics: %w", err)
+	}
+
+	return nil
+}
+
+// deleteAgentLogs deletes the shipped logs of an agent
+func (r *agentRepository) deleteAgentLogs(ctx context.Context, tx *sql.Tx, id string) error {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM agent_logs WHERE agent_id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	_, err := tx.ExecContext(ctx, query, append([]any{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent logs: %w", err)
+	}
+
+	return nil
+}
+
+// whereAgentFilter adds the conditions of an agent filter to qb. Tags are
+// matched against their JSON encoding, which escapes quotes in keys and
+// values so a fragment cannot match across tags.
+func whereAgentFilter(ctx context.Context, qb *database.QueryBuilder, filter *types.AgentFilter) {
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "id")
+
+	if len(filter.Statuses) > 0 {
+		qb.Where("status IN (?)", filter.Statuses)
+	}
+
+	if filter.Hostname != "" {
+		qb.Where("LOWER(hostname) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.Hostname))+"%")
+	}
+
+	keys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		k, _ := json.Marshal(key)
+		fragment := string(k) + ":"
+		if value := filter.Tags[key]; value != "" {
+			v, _ := json.Marshal(value)
+			fragment += string(v)
+		}
+		qb.Where("tags LIKE ? ESCAPE '!'", "%"+escapeLike(fragment)+"%")
+	}
+}
+
+// escapeLike escapes the LIKE wildcards of s with '!'
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// marshalTags encodes agent tags for storage
+func marshalTags(tags map[string]string) (string, error) {
+	if tags == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal agent tags: %w", err)
+	}
+	return string(data), nil
+}
+
+// marshalProtocol encodes the protocol of an agent for storage, NULL
+// while none was negotiated
+func marshalProtocol(p *types.AgentProtocol) (sql.NullString, error) {
+	if p == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal agent protocol: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// scanAgent scans an agents row selected with agentColumns
+func scanAgent(row rowScanner) (*types.AgentInfo, error) {
+	var agent types.AgentInfo
+	var tags, maintenance, protocol sql.NullString
+	if err := row.Scan(
+		&agent.ID,
+		&agent.TenantID,
+		&agent.Hostname,
+		&agent.Version,
+		&agent.Status,
+		&tags,
+		&maintenance,
+		&protocol,
+		&agent.LastSeen,
+		&agent.RegisteredAt,
+		&agent.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if tags.String != "" && tags.String != "{}" {
+		if err := json.Unmarshal([]byte(tags.String), &agent.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal agent tags: %w", err)
+		}
+	}
+
+	if maintenance.String != "" {
+		if err := json.Unmarshal([]byte(maintenance.String), &agent.Maintenance); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal agent maintenance: %w", err)
+		}
+	}
+
+	if protocol.String != "" {
+		if err := json.Unmarshal([]byte(protocol.String), &agent.Protocol); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal agent protocol: %w", err)
+		}
+	}
+
+	return &agent, nil
+}
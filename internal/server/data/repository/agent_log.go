@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// agentLogRepository represents agent log repository implementation
+type agentLogRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewAgentLogRepository creates new agent log repository
+func NewAgentLogRepository(db database.Interface, logger *zap.Logger) AgentLogRepository {
+	return &agentLogRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SaveBatch saves log entries in one transaction
+func (r *agentLogRepository) SaveBatch(ctx context.Context, entries []*types.AgentLogEntry) error {
+	query := `
+        INSERT INTO agent_logs (
+            tenant_id, agent_id, timestamp, level, caller, message, fields, created_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	now := time.Now()
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+
+		defer func(stmt *sql.Stmt) {
+			_ = stmt.Close()
+		}(stmt)
+
+		for _, entry := range entries {
+			var fields []byte
+			if len(entry.Fields) > 0 {
+				if fields, err = json.Marshal(entry.Fields); err != nil {
+					return fmt.Errorf("failed to marshal log fields: %w", err)
+				}
+			}
+
+			if _, err := stmt.ExecContext(ctx,
+				entry.TenantID,
+				entry.AgentID,
+				entry.Timestamp,
+				entry.Level,
+				nullString(entry.Caller),
+				entry.Message,
+				fields,
+				now,
+			); err != nil {
+				return fmt.Errorf("failed to save agent log: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Query returns log entries matching the filter, newest first
+func (r *agentLogRepository) Query(ctx context.Context, filter *types.AgentLogFilter) ([]*types.AgentLogEntry, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+
+	qb.Select("id", "tenant_id", "agent_id", "timestamp", "level", "caller", "message", "fields")
+	qb.From("agent_logs")
+	qb.Where("agent_id = ?", filter.AgentID)
+	qb.Where("timestamp BETWEEN ? AND ?", filter.StartTime, filter.EndTime)
+
+	if len(filter.Levels) > 0 {
+		qb.Where("level IN (?)", filter.Levels)
+	}
+
+	if filter.Search != "" {
+		qb.Where("LOWER(message) LIKE ?", "%"+strings.ToLower(filter.Search)+"%")
+	}
+
+	whereTenant(ctx, qb, "tenant_id")
+
+	qb.OrderBy("timestamp DESC", "id DESC")
+	qb.Limit(filter.Limit)
+	qb.Offset(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent logs: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var entries []*types.AgentLogEntry
+	for rows.Next() {
+		var entry types.AgentLogEntry
+		var caller sql.NullString
+		var fields []byte
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.TenantID,
+			&entry.AgentID,
+			&entry.Timestamp,
+			&entry.Level,
+			&caller,
+			&entry.Message,
+			&fields,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent log: %w", err)
+		}
+
+		entry.Caller = caller.String
+		if len(fields) > 0 {
+			if err := json.Unmarshal(fields, &entry.Fields); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal log fields: %w", err)
+			}
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating agent logs: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DeleteBefore deletes log entries received before the given time
+func (r *agentLogRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	query := "DELETE FROM agent_logs WHERE created_at < ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent logs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	r.logger.Info("Deleted old agent logs",
+		zap.Int64("count", affected),
+		zap.Time("before", before))
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// alertRuleRepository represents alert rule repository implementation
+type alertRuleRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewAlertRuleRepository creates new alert rule repository
+func NewAlertRuleRepository(db database.Interface, logger *zap.Logger) AlertRuleRepository {
+	return &alertRuleRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// List returns the rules by name
+func (r *alertRuleRepository) List(ctx context.Context) ([]*types.AlertRule, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT data, updated_at FROM alert_rules ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	rules := []*types.AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alert rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Get returns a rule by name
+func (r *alertRuleRepository) Get(ctx context.Context, name string) (*types.AlertRule, error) {
+	query := "SELECT data, updated_at FROM alert_rules WHERE name = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	rule, err := scanAlertRule(r.db.QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrAlertRuleNotFound
+	}
+	return rule, err
+}
+
+// Save creates or replaces a rule by name
+func (r *alertRuleRepository) Save(ctx context.Context, rule *types.AlertRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert rule: %w", err)
+	}
+
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		query := "DELETE FROM alert_rules WHERE name = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, rule.Name); err != nil {
+			return fmt.Errorf("failed to delete previous alert rule: %w", err)
+		}
+
+		query = "INSERT INTO alert_rules (name, data, updated_at) VALUES (?, ?, ?)"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, rule.Name, string(data), time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to save alert rule %s: %w", rule.Name, err)
+		}
+
+		return nil
+	})
+}
+
+// Delete deletes a rule by name
+func (r *alertRuleRepository) Delete(ctx context.Context, name string) error {
+	query := "DELETE FROM alert_rules WHERE name = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+// scanAlertRule scans a rule from its data and update time
+func scanAlertRule(row interface{ Scan(...any) error }) (*types.AlertRule, error) {
+	var data string
+	var updatedAt time.Time
+	if err := row.Scan(&data, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+	}
+
+	var rule types.AlertRule
+	if err := json.Unmarshal([]byte(data), &rule); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal alert rule: %w", err)
+	}
+	rule.Source = types.AlertRuleSourceAPI
+	rule.UpdatedAt = &updatedAt
+
+	return &rule, nil
+}
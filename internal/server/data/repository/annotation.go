@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// annotationRepository represents annotation repository implementation
+type annotationRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewAnnotationRepository creates new annotation repository
+func NewAnnotationRepository(db database.Interface, logger *zap.Logger) AnnotationRepository {
+	return &annotationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves annotation and sets its ID
+func (r *annotationRepository) Save(ctx context.Context, a *types.Annotation) error {
+	query := `
+        INSERT INTO annotations (
+            tenant_id, type, agent_id, timestamp, end_time,
+            title, text, tags, created_by, created_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if a.TenantID == "" {
+		a.TenantID = tenant.OrDefault(ctx)
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+
+	tags, err := json.Marshal(a.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal annotation tags: %w", err)
+	}
+
+	var endTime sql.NullTime
+	if a.EndTime != nil {
+		endTime = sql.NullTime{Time: *a.EndTime, Valid: true}
+	}
+
+	args := []any{
+		a.TenantID,
+		a.Type,
+		nullString(a.AgentID),
+		a.Time,
+		endTime,
+		a.Title,
+		nullString(a.Text),
+		string(tags),
+		nullString(a.CreatedBy),
+		a.CreatedAt,
+	}
+
+	if r.db.Driver() == "postgres" {
+		err = r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query)+" RETURNING id", args...).Scan(&a.ID)
+		if err != nil {
+			return fmt.Errorf("failed to save annotation: %w", err)
+		}
+		return nil
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to save annotation: %w", err)
+	}
+	if a.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get annotation id: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns annotations matching the filter, newest first
+func (r *annotationRepository) Query(ctx context.Context, filter *types.AnnotationFilter) ([]*types.Annotation, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+
+	qb.Select("id", "tenant_id", "type", "agent_id", "timestamp", "end_time",
+		"title", "text", "tags", "created_by", "created_at")
+	qb.From("annotations")
+	qb.Where("timestamp <= ?", filter.EndTime)
+	qb.Where("COALESCE(end_time, timestamp) >= ?", filter.StartTime)
+
+	if len(filter.Types) > 0 {
+		qb.Where("type IN (?)", filter.Types)
+	}
+
+	if len(filter.AgentIDs) > 0 {
+		qb.Where("agent_id IN (?)", filter.AgentIDs)
+	}
+
+	for _, tag := range filter.Tags {
+		t, _ := json.Marshal(tag)
+		qb.Where("tags LIKE ? ESCAPE '!'", "%"+escapeLike(string(t))+"%")
+	}
+
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
+
+	qb.OrderBy("timestamp DESC", "id DESC")
+	qb.Limit(filter.Limit)
+	qb.Offset(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var annotations []*types.Annotation
+	for rows.Next() {
+		var a types.Annotation
+		var agentID, text, createdBy sql.NullString
+		var endTime sql.NullTime
+		var tags string
+
+		err := rows.Scan(
+			&a.ID,
+			&a.TenantID,
+			&a.Type,
+			&agentID,
+			&a.Time,
+			&endTime,
+			&a.Title,
+			&text,
+			&tags,
+			&createdBy,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan annotation: %w", err)
+		}
+
+		a.AgentID = agentID.String
+		a.Text = text.String
+		a.CreatedBy = createdBy.String
+		if endTime.Valid {
+			a.EndTime = &endTime.Time
+		}
+		if err := json.Unmarshal([]byte(tags), &a.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal annotation tags: %w", err)
+		}
+
+		annotations = append(annotations, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating annotations: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// DeleteBefore deletes annotations that ended before the given time
+func (r *annotationRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	query := "DELETE FROM annotations WHERE COALESCE(end_time, timestamp) < ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete annotations: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	r.logger.Info("Deleted old annotations",
+		zap.Int64("count", affected),
+		zap.Time("before", before))
+
+	return nil
+}
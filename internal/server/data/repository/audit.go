@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// auditRepository represents audit log repository implementation
+type auditRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewAuditRepository creates new audit log repository
+func NewAuditRepository(db database.Interface, logger *zap.Logger) AuditRepository {
+	return &auditRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves audit entry
+func (r *auditRepository) Save(ctx context.Context, entry *types.AuditEntry) error {
+	query := `
+        INSERT INTO audit_logs (
+            tenant_id, timestamp, actor, action, resource, method,
+            path, status, client_ip, payload_digest
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if entry.TenantID == "" {
+		entry.TenantID = tenant.OrDefault(ctx)
+	}
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.TenantID,
+		entry.Timestamp,
+		entry.Actor,
+		entry.Action,
+		nullString(entry.Resource),
+		entry.Method,
+		entry.Path,
+		entry.Status,
+		nullString(entry.ClientIP),
+		nullString(entry.PayloadDigest),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns audit entries matching the filter, newest first
+func (r *auditRepository) Query(ctx context.Context, filter *types.AuditFilter) ([]*types.AuditEntry, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+
+	qb.Select("id", "tenant_id", "timestamp", "actor", "action", "resource", "method",
+		"path", "status", "client_ip", "payload_digest")
+	qb.From("audit_logs")
+	qb.Where("timestamp BETWEEN ? AND ?", filter.StartTime, filter.EndTime)
+
+	if filter.Actor != "" {
+		qb.Where("actor = ?", filter.Actor)
+	}
+
+	if len(filter.Actions) > 0 {
+		qb.Where("action IN (?)", filter.Actions)
+	}
+
+	if filter.Resource != "" {
+		qb.Where("resource = ?", filter.Resource)
+	}
+
+	whereTenant(ctx, qb, "tenant_id")
+
+	qb.OrderBy("timestamp DESC", "id DESC")
+	qb.Limit(filter.Limit)
+	qb.Offset(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var entries []*types.AuditEntry
+	for rows.Next() {
+		var entry types.AuditEntry
+		var resource, clientIP, digest sql.NullString
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.TenantID,
+			&entry.Timestamp,
+			&entry.Actor,
+			&entry.Action,
+			&resource,
+			&entry.Method,
+			&entry.Path,
+			&entry.Status,
+			&clientIP,
+			&digest,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+
+		entry.Resource = resource.String
+		entry.ClientIP = clientIP.String
+		entry.PayloadDigest = digest.String
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
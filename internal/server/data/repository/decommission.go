@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// decommissionColumns are the columns of agent_decommissions in scan order
+const decommissionColumns = `agent_id, tenant_id, hostname, reason, archive, archive_location,
+        archive_error, retired_at, purge_after, archived_at, purged_at`
+
+// decommissionRepository represents agent decommission repository implementation
+type decommissionRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewDecommissionRepository creates new agent decommission repository
+func NewDecommissionRepository(db database.Interface, logger *zap.Logger) DecommissionRepository {
+	return &decommissionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves the decommission of an agent, replacing the record of an
+// earlier decommission of the same agent ID
+func (r *decommissionRepository) Save(ctx context.Context, d *types.AgentDecommission) error {
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		query := "DELETE FROM agent_decommissions WHERE agent_id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, d.AgentID); err != nil {
+			return fmt.Errorf("failed to delete previous decommission: %w", err)
+		}
+
+		query = `
+        INSERT INTO agent_decommissions (` + decommissionColumns + `)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query,
+			d.AgentID, d.TenantID, d.Hostname, d.Reason, d.Archive, d.ArchiveLocation,
+			d.ArchiveError, d.RetiredAt, d.PurgeAfter, nullTime(d.ArchivedAt), nullTime(d.PurgedAt),
+		); err != nil {
+			return fmt.Errorf("failed to save decommission: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// FindByAgent returns the decommission of an agent
+func (r *decommissionRepository) FindByAgent(ctx context.Context, agentID string) (*types.AgentDecommission, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "SELECT " + decommissionColumns + " FROM agent_decommissions WHERE agent_id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	d, err := scanDecommission(r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrAgentNotRetired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decommission: %w", err)
+	}
+
+	return d, nil
+}
+
+// UpdateArchive records the archive location or error of a decommission
+func (r *decommissionRepository) UpdateArchive(ctx context.Context, d *types.AgentDecommission) error {
+	query := `
+        UPDATE agent_decommissions
+        SET archive_location = ?, archive_error = ?, archived_at = ?
+        WHERE agent_id = ?`
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	return r.exec(ctx, query, d.ArchiveLocation, d.ArchiveError, nullTime(d.ArchivedAt), d.AgentID)
+}
+
+// MarkPurged records when the data of a retired agent was purged
+func (r *decommissionRepository) MarkPurged(ctx context.Context, agentID string, at time.Time) error {
+	query := "UPDATE agent_decommissions SET purged_at = ? WHERE agent_id = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	return r.exec(ctx, query, at, agentID)
+}
+
+// exec runs an update of a single decommission
+func (r *decommissionRepository) exec(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update decommission: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrAgentNotRetired
+	}
+
+	return nil
+}
+
+// Delete deletes the decommission of an agent
+func (r *decommissionRepository) Delete(ctx context.Context, agentID string) error {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM agent_decommissions WHERE agent_id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, append([]any{agentID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to delete decommission: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrAgentNotRetired
+	}
+
+	return nil
+}
+
+// ListDue returns the decommissions whose grace period ended by now and
+// whose agents are not purged yet
+func (r *decommissionRepository) ListDue(ctx context.Context, now time.Time) ([]*types.AgentDecommission, error) {
+	query := "SELECT " + decommissionColumns + ` FROM agent_decommissions
+        WHERE purged_at IS NULL AND purge_after <= ? ORDER BY purge_after`
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decommissions: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var due []*types.AgentDecommission
+	for rows.Next() {
+		d, err := scanDecommission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan decommission: %w", err)
+		}
+		due = append(due, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating decommissions: %w", err)
+	}
+
+	return due, nil
+}
+
+// scanDecommission scans an agent_decommissions row
+func scanDecommission(row rowScanner) (*types.AgentDecommission, error) {
+	var d types.AgentDecommission
+	var archivedAt, purgedAt sql.NullTime
+	if err := row.Scan(
+		&d.AgentID, &d.TenantID, &d.Hostname, &d.Reason, &d.Archive, &d.ArchiveLocation,
+		&d.ArchiveError, &d.RetiredAt, &d.PurgeAfter, &archivedAt, &purgedAt,
+	); err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		d.ArchivedAt = &archivedAt.Time
+	}
+	if purgedAt.Valid {
+		d.PurgedAt = &purgedAt.Time
+	}
+	return &d, nil
+}
+
+// nullTime converts an optional time to a nullable column value
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// diagnosticsColumns are the metadata columns of agent_diagnostics in scan order
+const diagnosticsColumns = "id, tenant_id, agent_id, command_id, size, created_at"
+
+// diagnosticsRepository represents agent diagnostics repository implementation
+type diagnosticsRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewDiagnosticsRepository creates new agent diagnostics repository
+func NewDiagnosticsRepository(db database.Interface, logger *zap.Logger) DiagnosticsRepository {
+	return &diagnosticsRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves a diagnostics bundle
+func (r *diagnosticsRepository) Save(ctx context.Context, d *types.Diagnostics, content []byte) error {
+	query := `
+        INSERT INTO agent_diagnostics (` + diagnosticsColumns + `, content)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query,
+		d.ID, d.TenantID, d.AgentID, d.CommandID, d.Size, d.CreatedAt, content,
+	); err != nil {
+		return fmt.Errorf("failed to save diagnostics: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the diagnostics bundles of an agent without their content,
+// newest first
+func (r *diagnosticsRepository) List(ctx context.Context, agentID string) ([]*types.Diagnostics, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select(diagnosticsColumns).
+		From("agent_diagnostics").
+		Where("agent_id = ?", agentID)
+	whereTenant(ctx, qb, "tenant_id")
+	qb.OrderBy("created_at DESC", "id DESC")
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query diagnostics: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	bundles := []*types.Diagnostics{}
+	for rows.Next() {
+		var d types.Diagnostics
+		if err := rows.Scan(&d.ID, &d.TenantID, &d.AgentID, &d.CommandID, &d.Size, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan diagnostics: %w", err)
+		}
+		bundles = append(bundles, &d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating diagnostics: %w", err)
+	}
+
+	return bundles, nil
+}
+
+// Get returns a diagnostics bundle of an agent with its content
+func (r *diagnosticsRepository) Get(ctx context.Context, agentID, id string) (*types.Diagnostics, []byte, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select(diagnosticsColumns, "content").
+		From("agent_diagnostics").
+		Where("agent_id = ?", agentID).
+		Where("id = ?", id)
+	whereTenant(ctx, qb, "tenant_id")
+
+	return r.get(ctx, qb)
+}
+
+// GetLatest returns the newest diagnostics bundle of an agent with its content
+func (r *diagnosticsRepository) GetLatest(ctx context.Context, agentID string) (*types.Diagnostics, []byte, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select(diagnosticsColumns, "content").
+		From("agent_diagnostics").
+		Where("agent_id = ?", agentID)
+	whereTenant(ctx, qb, "tenant_id")
+	qb.OrderBy("created_at DESC", "id DESC").Limit(1)
+
+	return r.get(ctx, qb)
+}
+
+// get scans a single bundle with its content
+func (r *diagnosticsRepository) get(ctx context.Context, qb *database.QueryBuilder) (*types.Diagnostics, []byte, error) {
+	var d types.Diagnostics
+	var content []byte
+	err := r.db.QueryRowContext(ctx, qb.SQL(), qb.Args()...).Scan(
+		&d.ID, &d.TenantID, &d.AgentID, &d.CommandID, &d.Size, &d.CreatedAt, &content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, types.ErrNoDiagnostics
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query diagnostics: %w", err)
+	}
+
+	return &d, content, nil
+}
+
+// Prune deletes all but the newest keep diagnostics bundles of an agent
+func (r *diagnosticsRepository) Prune(ctx context.Context, agentID string, keep int) error {
+	bundles, err := r.List(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if len(bundles) <= keep {
+		return nil
+	}
+
+	ids := make([]any, 0, len(bundles)-keep)
+	for _, d := range bundles[keep:] {
+		ids = append(ids, d.ID)
+	}
+
+	query := "DELETE FROM agent_diagnostics WHERE id IN (" + placeholders(len(ids)) + ")"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+	if _, err := r.db.ExecContext(ctx, query, ids...); err != nil {
+		return fmt.Errorf("failed to delete expired diagnostics: %w", err)
+	}
+
+	return nil
+}
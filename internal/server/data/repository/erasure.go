@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// erasureColumns are the columns of erasures in scan order
+const erasureColumns = `id, tenant_id, agent_id, hostname, agent_ids, reason, requested_by,
+        status, error, report, requested_at, completed_at`
+
+// erasedTables lists the tables holding data of an agent and their agent
+// column, in deletion order so rows referencing an agent go before it
+var erasedTables = []struct {
+	name   string
+	column string
+}{
+	{"metrics", "agent_id"},
+	{"ip_changes", "agent_id"},
+	{"agent_diagnostics", "agent_id"},
+	{"agent_logs", "agent_id"},
+	{"annotations", "agent_id"},
+	{"agent_decommissions", "agent_id"},
+	{"agents", "id"},
+}
+
+// erasureRepository represents erasure repository implementation
+type erasureRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewErasureRepository creates new erasure repository
+func NewErasureRepository(db database.Interface, logger *zap.Logger) ErasureRepository {
+	return &erasureRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves a new erasure
+func (r *erasureRepository) Save(ctx context.Context, e *types.Erasure) error {
+	agentIDs, report, err := marshalErasure(e)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO erasures (" + erasureColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+	if _, err := r.db.ExecContext(ctx, query,
+		e.ID, e.TenantID, e.AgentID, e.Hostname, agentIDs, e.Reason, e.RequestedBy,
+		e.Status, e.Error, report, e.RequestedAt, nullTime(e.CompletedAt),
+	); err != nil {
+		return fmt.Errorf("failed to save erasure: %w", err)
+	}
+
+	return nil
+}
+
+// Update records the outcome of an erasure
+func (r *erasureRepository) Update(ctx context.Context, e *types.Erasure) error {
+	_, report, err := marshalErasure(e)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE erasures SET status = ?, error = ?, report = ?, completed_at = ? WHERE id = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, e.Status, e.Error, report, nullTime(e.CompletedAt), e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update erasure: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrErasureNotFound
+	}
+
+	return nil
+}
+
+// FindByID returns an erasure
+func (r *erasureRepository) FindByID(ctx context.Context, id string) (*types.Erasure, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "SELECT " + erasureColumns + " FROM erasures WHERE id = ?" + cond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	e, err := scanErasure(r.db.QueryRowContext(ctx, query, append([]any{id}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrErasureNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query erasure: %w", err)
+	}
+
+	return e, nil
+}
+
+// List returns the erasures of the tenant of ctx, or of all tenants when
+// unscoped, newest first
+func (r *erasureRepository) List(ctx context.Context) ([]*types.Erasure, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "SELECT " + erasureColumns + " FROM erasures WHERE 1 = 1" + cond + " ORDER BY requested_at DESC"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query erasures: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	erasures := make([]*types.Erasure, 0)
+	for rows.Next() {
+		e, err := scanErasure(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan erasure: %w", err)
+		}
+		erasures = append(erasures, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating erasures: %w", err)
+	}
+
+	return erasures, nil
+}
+
+// FindAgents returns the IDs of the agents of an agent ID or hostname,
+// registered or retired and purged
+func (r *erasureRepository) FindAgents(ctx context.Context, agentID, hostname string) ([]string, error) {
+	column, value := "id", agentID
+	if agentID == "" {
+		column, value = "hostname", hostname
+	}
+	agentsCond, agentsArgs := tenantCond(ctx, "tenant_id")
+	retiredCond, retiredArgs := tenantCond(ctx, "tenant_id")
+	retiredColumn := column
+	if column == "id" {
+		retiredColumn = "agent_id"
+	}
+
+	query := "SELECT id FROM agents WHERE " + column + " = ?" + agentsCond +
+		" UNION SELECT agent_id FROM agent_decommissions WHERE " + retiredColumn + " = ?" + retiredCond
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	args := append(append([]any{value}, agentsArgs...), value)
+	rows, err := r.db.QueryContext(ctx, query, append(args, retiredArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agents: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan agent id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating agents: %w", err)
+	}
+
+	return ids, nil
+}
+
+// EraseAgent deletes all rows of an agent and the agent in one transaction,
+// it returns the rows deleted by table
+func (r *erasureRepository) EraseAgent(ctx context.Context, agentID string) (map[string]int64, error) {
+	deleted := make(map[string]int64, len(erasedTables))
+	err := r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		for _, table := range erasedTables {
+			cond, args := tenantCond(ctx, "tenant_id")
+			query := "DELETE FROM " + table.name + " WHERE " + table.column + " = ?" + cond
+			if r.db.Driver() == "postgres" {
+				query = database.ConvertPlaceholders(query)
+			}
+
+			result, err := tx.ExecContext(ctx, query, append([]any{agentID}, args...)...)
+			if err != nil {
+				return fmt.Errorf("failed to delete agent %s: %w", table.name, err)
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to get affected rows: %w", err)
+			}
+			deleted[table.name] = affected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// CountAgentData returns the rows of an agent left by table in any tenant,
+// tables without rows are left out
+func (r *erasureRepository) CountAgentData(ctx context.Context, agentID string) (map[string]int64, error) {
+	remaining := make(map[string]int64)
+	for _, table := range erasedTables {
+		query := "SELECT COUNT(*) FROM " + table.name + " WHERE " + table.column + " = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+
+		var count int64
+		if err := r.db.QueryRowContext(ctx, query, agentID).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count agent %s: %w", table.name, err)
+		}
+		if count > 0 {
+			remaining[table.name] = count
+		}
+	}
+
+	return remaining, nil
+}
+
+// marshalErasure encodes the JSON columns of an erasure
+func marshalErasure(e *types.Erasure) (string, sql.NullString, error) {
+	agentIDs, err := json.Marshal(e.AgentIDs)
+	if err != nil {
+		return "", sql.NullString{}, fmt.Errorf("failed to marshal erased agents: %w", err)
+	}
+	if e.Report == nil {
+		return string(agentIDs), sql.NullString{}, nil
+	}
+
+	report, err := json.Marshal(e.Report)
+	if err != nil {
+		return "", sql.NullString{}, fmt.Errorf("failed to marshal erasure report: %w", err)
+	}
+	return string(agentIDs), sql.NullString{String: string(report), Valid: true}, nil
+}
+
+// scanErasure scans an erasures row
+func scanErasure(row rowScanner) (*types.Erasure, error) {
+	var e types.Erasure
+	var agentIDs string
+	var report sql.NullString
+	var completedAt sql.NullTime
+	if err := row.Scan(
+		&e.ID, &e.TenantID, &e.AgentID, &e.Hostname, &agentIDs, &e.Reason, &e.RequestedBy,
+		&e.Status, &e.Error, &report, &e.RequestedAt, &completedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(agentIDs), &e.AgentIDs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal erased agents: %w", err)
+	}
+	if report.Valid {
+		e.Report = &types.ErasureReport{}
+		if err := json.Unmarshal([]byte(report.String), e.Report); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal erasure report: %w", err)
+		}
+	}
+	if completedAt.Valid {
+		e.CompletedAt = &completedAt.Time
+	}
+	return &e, nil
+}
@@ -12,8 +12,11 @@ type AgentRepository interface {
 	FindByID(ctx context.Context, id string) (*types.AgentInfo, error)
 	UpdateAgent(ctx context.Context, agent *types.AgentInfo) error
 	UpdateStatus(ctx context.Context, id string, status types.AgentStatus) error
+	SetMaintenance(ctx context.Context, id string, m *types.AgentMaintenance) error
 	List(ctx context.Context) ([]*types.AgentInfo, error)
-	ListWithPagination(ctx context.Context, limit, offset int) ([]*types.AgentInfo, error)
+	ListWithPagination(ctx context.Context, filter *types.AgentFilter) ([]*types.AgentInfo, error)
+	Count(ctx context.Context, filter *types.AgentFilter) (int64, error)
+	ListStatuses(ctx context.Context, filter *types.AgentFilter) (map[string]*types.AgentStatusInfo, error)
 	Delete(ctx context.Context, id string) error
 	GetAgentMetrics(ctx context.Context, id string) (*types.AgentMetrics, error)
 }
@@ -25,18 +28,125 @@ type IPChangeRepository interface {
 	DeleteBefore(ctx context.Context, before time.Time) error
 	GetChangeSummary(ctx context.Context, agentID string) (*types.IPChangeSummary, error)
 	GetInterfaceChanges(ctx context.Context, agentID, interfaceName string, since time.Time) ([]*types.IPChange, error)
+	Query(ctx context.Context, filter *types.IPChangeFilter) ([]*types.IPChange, error)
+	CountChanges(ctx context.Context, start, end time.Time) ([]*types.IPChangeCount, error)
+}
+
+// IPChangeWindowRepository defines storage operations of the expected IP
+// change windows declared through the API
+type IPChangeWindowRepository interface {
+	List(ctx context.Context) ([]types.IPChangeWindow, error)
+	Replace(ctx context.Context, windows []types.IPChangeWindow) error
+}
+
+// AlertRuleRepository defines storage operations of the alert rules
+// declared through the API
+type AlertRuleRepository interface {
+	List(ctx context.Context) ([]*types.AlertRule, error)
+	Get(ctx context.Context, name string) (*types.AlertRule, error)
+	Save(ctx context.Context, rule *types.AlertRule) error
+	Delete(ctx context.Context, name string) error
+}
+
+// AuditRepository defines audit log storage operations
+type AuditRepository interface {
+	Save(ctx context.Context, entry *types.AuditEntry) error
+	Query(ctx context.Context, filter *types.AuditFilter) ([]*types.AuditEntry, error)
+}
+
+// TenantRepository defines tenant and API key storage operations
+type TenantRepository interface {
+	Save(ctx context.Context, tenant *types.Tenant) error
+	FindByID(ctx context.Context, id string) (*types.Tenant, error)
+	List(ctx context.Context) ([]*types.Tenant, error)
+	Delete(ctx context.Context, id string) error
+	Usage(ctx context.Context) ([]*types.TenantUsage, error)
+	SaveKey(ctx context.Context, key *types.APIKey, hash string) error
+	FindKeyByHash(ctx context.Context, hash string) (*types.APIKey, error)
+	ListKeys(ctx context.Context, tenantID string) ([]*types.APIKey, error)
+	DeleteKey(ctx context.Context, tenantID, id string) error
+}
+
+// UserRepository defines user storage operations
+type UserRepository interface {
+	Save(ctx context.Context, user *types.User) error
+	FindByID(ctx context.Context, id string) (*types.User, error)
+	List(ctx context.Context, tenantID string) ([]*types.User, error)
+	Update(ctx context.Context, user *types.User) error
+	Delete(ctx context.Context, id string) error
+	ListKeys(ctx context.Context, userID string) ([]*types.APIKey, error)
+}
+
+// LeaseRepository defines leader lease operations
+type LeaseRepository interface {
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, name, holder string) error
+}
+
+// AgentLogRepository defines agent log storage operations
+type AgentLogRepository interface {
+	SaveBatch(ctx context.Context, entries []*types.AgentLogEntry) error
+	Query(ctx context.Context, filter *types.AgentLogFilter) ([]*types.AgentLogEntry, error)
+	DeleteBefore(ctx context.Context, before time.Time) error
+}
+
+// DecommissionRepository defines agent decommission storage operations
+type DecommissionRepository interface {
+	Save(ctx context.Context, d *types.AgentDecommission) error
+	FindByAgent(ctx context.Context, agentID string) (*types.AgentDecommission, error)
+	UpdateArchive(ctx context.Context, d *types.AgentDecommission) error
+	MarkPurged(ctx context.Context, agentID string, at time.Time) error
+	Delete(ctx context.Context, agentID string) error
+	ListDue(ctx context.Context, now time.Time) ([]*types.AgentDecommission, error)
+}
+
+// ErasureRepository defines data erasure storage operations
+type ErasureRepository interface {
+	Save(ctx context.Context, e *types.Erasure) error
+	Update(ctx context.Context, e *types.Erasure) error
+	FindByID(ctx context.Context, id string) (*types.Erasure, error)
+	List(ctx context.Context) ([]*types.Erasure, error)
+	FindAgents(ctx context.Context, agentID, hostname string) ([]string, error)
+	EraseAgent(ctx context.Context, agentID string) (map[string]int64, error)
+	CountAgentData(ctx context.Context, agentID string) (map[string]int64, error)
+}
+
+// DiagnosticsRepository defines agent diagnostics bundle storage operations
+type DiagnosticsRepository interface {
+	Save(ctx context.Context, d *types.Diagnostics, content []byte) error
+	List(ctx context.Context, agentID string) ([]*types.Diagnostics, error)
+	Get(ctx context.Context, agentID, id string) (*types.Diagnostics, []byte, error)
+	GetLatest(ctx context.Context, agentID string) (*types.Diagnostics, []byte, error)
+	Prune(ctx context.Context, agentID string, keep int) error
+}
+
+// InventoryRepository defines expected agent inventory storage operations
+type InventoryRepository interface {
+	Get(ctx context.Context) (*types.Inventory, error)
+	Save(ctx context.Context, inv *types.Inventory) error
+}
+
+// AnnotationRepository defines annotation storage operations
+type AnnotationRepository interface {
+	Save(ctx context.Context, a *types.Annotation) error
+	Query(ctx context.Context, filter *types.AnnotationFilter) ([]*types.Annotation, error)
+	DeleteBefore(ctx context.Context, before time.Time) error
 }
 
 // MetricsRepository defines metrics storage operations
 type MetricsRepository interface {
 	Save(ctx context.Context, data *types.MetricsData) error
-	BatchSave(ctx context.Context, metrics []*types.MetricsData) error
+	BatchSave(ctx context.Context, metrics []*types.MetricsData) ([]*types.MetricsData, error)
 	Query(ctx context.Context, params QueryParams) ([]*types.MetricsData, error)
+	Stream(ctx context.Context, params QueryParams, fn func(*types.MetricsData) error) error
 	GetLatest(ctx context.Context, agentID string) (*types.MetricsData, error)
 	DeleteBefore(ctx context.Context, before time.Time) error
 	GetMetricsByTimeRange(ctx context.Context, startTime, endTime time.Time) ([]*types.MetricsData, error)
 	GetMetricsSummary(ctx context.Context, agentID string) (*types.MetricsSummary, error)
 	PruneMetrics(ctx context.Context, before time.Time) error
+	GetInterfaceUsage(ctx context.Context, start, end time.Time) ([]*types.InterfaceUsage, error)
+	CountReportingSlots(ctx context.Context, start, end time.Time, slot time.Duration) (map[string]int64, error)
+	GetStorageByAgent(ctx context.Context) ([]*types.AgentStorage, error)
 }
 
 // QueryParams represents common query parameters
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// inventoryName is the row of the inventory declared through the API
+const inventoryName = "default"
+
+// inventoryRepository represents expected agent inventory repository implementation
+type inventoryRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewInventoryRepository creates new expected agent inventory repository
+func NewInventoryRepository(db database.Interface, logger *zap.Logger) InventoryRepository {
+	return &inventoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Get returns the inventory, empty when none was saved
+func (r *inventoryRepository) Get(ctx context.Context) (*types.Inventory, error) {
+	query := "SELECT data FROM agent_inventory WHERE name = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	var data string
+	err := r.db.QueryRowContext(ctx, query, inventoryName).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &types.Inventory{Agents: []types.ExpectedAgent{}, Counts: []types.ExpectedCount{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inventory: %w", err)
+	}
+
+	var inv types.Inventory
+	if err := json.Unmarshal([]byte(data), &inv); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory: %w", err)
+	}
+
+	return &inv, nil
+}
+
+// Save replaces the inventory
+func (r *inventoryRepository) Save(ctx context.Context, inv *types.Inventory) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory: %w", err)
+	}
+
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		query := "DELETE FROM agent_inventory WHERE name = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, inventoryName); err != nil {
+			return fmt.Errorf("failed to delete previous inventory: %w", err)
+		}
+
+		query = "INSERT INTO agent_inventory (name, data, updated_at) VALUES (?, ?, ?)"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, inventoryName, string(data), time.Now()); err != nil {
+			return fmt.Errorf("failed to save inventory: %w", err)
+		}
+
+		return nil
+	})
+}
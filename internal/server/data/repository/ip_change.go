@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"wameter/internal/database"
+	"wameter/internal/server/tenant"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
@@ -31,10 +33,10 @@ func NewIPChangeRepository(db database.Interface, logger *zap.Logger) IPChangeRe
 func (r *ipChangeRepository) Save(ctx context.Context, agentID string, change *types.IPChange) error {
 	query := `
         INSERT INTO ip_changes (
-            agent_id, interface_name, version,
+            agent_id, tenant_id, interface_name, version,
             is_external, old_addrs, new_addrs,
-            action, reason, timestamp, created_at
-        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+            action, reason, ip_context, expected_window, timestamp, created_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
@@ -50,8 +52,16 @@ func (r *ipChangeRepository) Save(ctx context.Context, agentID string, change *t
 		return fmt.Errorf("failed to marshal new addresses: %w", err)
 	}
 
+	var ipContext []byte
+	if change.Context != nil {
+		if ipContext, err = json.Marshal(change.Context); err != nil {
+			return fmt.Errorf("failed to marshal IP context: %w", err)
+		}
+	}
+
 	_, err = r.db.ExecContext(ctx, query,
 		agentID,
+		tenant.OrDefault(ctx),
 		change.InterfaceName,
 		change.Version,
 		change.IsExternal,
@@ -59,6 +69,8 @@ func (r *ipChangeRepository) Save(ctx context.Context, agentID string, change *t
 		newAddrs,
 		change.Action,
 		change.Reason,
+		ipContext,
+		change.ExpectedWindow,
 		change.Timestamp,
 		time.Now(),
 	)
@@ -72,19 +84,20 @@ func (r *ipChangeRepository) Save(ctx context.Context, agentID string, change *t
 
 // GetRecentChanges returns recent IP changes
 func (r *ipChangeRepository) GetRecentChanges(ctx context.Context, agentID string, since time.Time) ([]*types.IPChange, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT interface_name, version, is_external,
                old_addrs, new_addrs, action, reason,
-               timestamp, created_at
+               ip_context, expected_window, timestamp, created_at
         FROM ip_changes
-        WHERE agent_id = ? AND timestamp > ?
+        WHERE agent_id = ? AND timestamp > ?` + cond + `
         ORDER BY timestamp DESC`
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	rows, err := r.db.QueryContext(ctx, query, agentID, since)
+	rows, err := r.db.QueryContext(ctx, query, append([]any{agentID, since}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query IP changes: %w", err)
 	}
@@ -96,7 +109,7 @@ func (r *ipChangeRepository) GetRecentChanges(ctx context.Context, agentID strin
 	var changes []*types.IPChange
 	for rows.Next() {
 		var change types.IPChange
-		var oldAddrs, newAddrs []byte
+		var oldAddrs, newAddrs, ipContext []byte
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -107,6 +120,8 @@ func (r *ipChangeRepository) GetRecentChanges(ctx context.Context, agentID strin
 			&newAddrs,
 			&change.Action,
 			&change.Reason,
+			&ipContext,
+			&change.ExpectedWindow,
 			&change.Timestamp,
 			&createdAt,
 		)
@@ -122,6 +137,12 @@ func (r *ipChangeRepository) GetRecentChanges(ctx context.Context, agentID strin
 			return nil, fmt.Errorf("failed to unmarshal new addresses: %w", err)
 		}
 
+		if len(ipContext) > 0 {
+			if err := json.Unmarshal(ipContext, &change.Context); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal IP context: %w", err)
+			}
+		}
+
 		changes = append(changes, &change)
 	}
 
@@ -134,6 +155,7 @@ func (r *ipChangeRepository) GetRecentChanges(ctx context.Context, agentID strin
 
 // GetChangeSummary returns a summary of IP changes
 func (r *ipChangeRepository) GetChangeSummary(ctx context.Context, agentID string) (*types.IPChangeSummary, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT
             COUNT(*) as total_changes,
@@ -142,23 +164,26 @@ func (r *ipChangeRepository) GetChangeSummary(ctx context.Context, agentID strin
             MIN(timestamp) as first_change,
             MAX(timestamp) as last_change
         FROM ip_changes
-        WHERE agent_id = ?`
+        WHERE agent_id = ?` + cond
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
 	summary := &types.IPChangeSummary{}
-	err := r.db.QueryRowContext(ctx, query, agentID).Scan(
+	var firstChange, lastChange aggregateTime
+	err := r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...).Scan(
 		&summary.TotalChanges,
 		&summary.AffectedInterfaces,
 		&summary.ExternalChanges,
-		&summary.FirstChange,
-		&summary.LastChange,
+		&firstChange,
+		&lastChange,
 	)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, fmt.Errorf("failed to get IP change summary: %w", err)
 	}
+	summary.FirstChange = firstChange.Time
+	summary.LastChange = lastChange.Time
 
 	// Get change frequency statistics
 	if err := r.getChangeFrequencyStats(ctx, agentID, summary); err != nil {
@@ -172,13 +197,14 @@ func (r *ipChangeRepository) GetChangeSummary(ctx context.Context, agentID strin
 
 // getChangeFrequencyStats calculates IP change frequency statistics
 func (r *ipChangeRepository) getChangeFrequencyStats(ctx context.Context, agentID string, summary *types.IPChangeSummary) error {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         WITH daily_changes AS (
             SELECT
                 DATE(timestamp) as change_date,
                 COUNT(*) as changes
             FROM ip_changes
-            WHERE agent_id = ?
+            WHERE agent_id = ?` + cond + `
             GROUP BY DATE(timestamp)
         )
         SELECT
@@ -190,7 +216,7 @@ func (r *ipChangeRepository) getChangeFrequencyStats(ctx context.Context, agentI
 		query = database.ConvertPlaceholders(query)
 	}
 
-	return r.db.QueryRowContext(ctx, query, agentID).Scan(
+	return r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...).Scan(
 		&summary.AvgDailyChanges,
 		&summary.MaxDailyChanges,
 	)
@@ -198,12 +224,13 @@ func (r *ipChangeRepository) getChangeFrequencyStats(ctx context.Context, agentI
 
 // DeleteBefore deletes IP changes before the given time
 func (r *ipChangeRepository) DeleteBefore(ctx context.Context, before time.Time) error {
-	query := "DELETE FROM ip_changes WHERE timestamp < ?"
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM ip_changes WHERE timestamp < ?" + cond
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	result, err := r.db.ExecContext(ctx, query, before)
+	result, err := r.db.ExecContext(ctx, query, append([]any{before}, args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to delete IP changes: %w", err)
 	}
@@ -222,20 +249,21 @@ func (r *ipChangeRepository) DeleteBefore(ctx context.Context, before time.Time)
 
 // GetInterfaceChanges returns changes for a specific interface
 func (r *ipChangeRepository) GetInterfaceChanges(ctx context.Context, agentID, interfaceName string, since time.Time) ([]*types.IPChange, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT version, is_external, old_addrs, new_addrs,
-               action, reason, timestamp, created_at
+               action, reason, ip_context, expected_window, timestamp, created_at
         FROM ip_changes
         WHERE agent_id = ?
         AND interface_name = ?
-        AND timestamp > ?
+        AND timestamp > ?` + cond + `
         ORDER BY timestamp DESC`
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	rows, err := r.db.QueryContext(ctx, query, agentID, interfaceName, since)
+	rows, err := r.db.QueryContext(ctx, query, append([]any{agentID, interfaceName, since}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query interface changes: %w", err)
 	}
@@ -247,7 +275,7 @@ func (r *ipChangeRepository) GetInterfaceChanges(ctx context.Context, agentID, i
 	var changes []*types.IPChange
 	for rows.Next() {
 		var change types.IPChange
-		var oldAddrs, newAddrs []byte
+		var oldAddrs, newAddrs, ipContext []byte
 		var createdAt time.Time
 
 		err := rows.Scan(
@@ -257,6 +285,8 @@ func (r *ipChangeRepository) GetInterfaceChanges(ctx context.Context, agentID, i
 			&newAddrs,
 			&change.Action,
 			&change.Reason,
+			&ipContext,
+			&change.ExpectedWindow,
 			&change.Timestamp,
 			&createdAt,
 		)
@@ -274,6 +304,12 @@ func (r *ipChangeRepository) GetInterfaceChanges(ctx context.Context, agentID, i
 			return nil, fmt.Errorf("failed to unmarshal new addresses: %w", err)
 		}
 
+		if len(ipContext) > 0 {
+			if err := json.Unmarshal(ipContext, &change.Context); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal IP context: %w", err)
+			}
+		}
+
 		changes = append(changes, &change)
 	}
 
@@ -283,3 +319,184 @@ func (r *ipChangeRepository) GetInterfaceChanges(ctx context.Context, agentID, i
 
 	return changes, nil
 }
+
+// Query returns IP changes matching the filter, newest first
+func (r *ipChangeRepository) Query(ctx context.Context, filter *types.IPChangeFilter) ([]*types.IPChange, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+
+	qb.Select("agent_id", "interface_name", "version", "is_external",
+		"old_addrs", "new_addrs", "action", "reason", "ip_context", "expected_window", "timestamp")
+	qb.From("ip_changes")
+	qb.Where("timestamp BETWEEN ? AND ?", filter.StartTime, filter.EndTime)
+
+	if len(filter.AgentIDs) > 0 {
+		qb.Where("agent_id IN (?)", filter.AgentIDs)
+	}
+
+	if len(filter.Interfaces) > 0 {
+		qb.Where("interface_name IN (?)", filter.Interfaces)
+	}
+
+	if len(filter.Versions) > 0 {
+		qb.Where("version IN (?)", filter.Versions)
+	}
+
+	if len(filter.Actions) > 0 {
+		qb.Where("action IN (?)", filter.Actions)
+	}
+
+	if filter.IsExternal != nil {
+		qb.Where("is_external = ?", *filter.IsExternal)
+	}
+
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
+
+	qb.OrderBy("timestamp DESC", "id DESC")
+	qb.Limit(filter.Limit)
+	qb.Offset(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IP changes: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var changes []*types.IPChange
+	for rows.Next() {
+		var change types.IPChange
+		var interfaceName sql.NullString
+		var oldAddrs, newAddrs, ipContext []byte
+
+		err := rows.Scan(
+			&change.AgentID,
+			&interfaceName,
+			&change.Version,
+			&change.IsExternal,
+			&oldAddrs,
+			&newAddrs,
+			&change.Action,
+			&change.Reason,
+			&ipContext,
+			&change.ExpectedWindow,
+			&change.Timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan IP change: %w", err)
+		}
+
+		change.InterfaceName = interfaceName.String
+
+		if len(oldAddrs) > 0 {
+			if err := json.Unmarshal(oldAddrs, &change.OldAddrs); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal old addresses: %w", err)
+			}
+		}
+
+		if len(newAddrs) > 0 {
+			if err := json.Unmarshal(newAddrs, &change.NewAddrs); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal new addresses: %w", err)
+			}
+		}
+
+		if len(ipContext) > 0 {
+			if err := json.Unmarshal(ipContext, &change.Context); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal IP context: %w", err)
+			}
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating IP changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// placeholders returns n comma separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// aggregateTime scans time aggregates, which some drivers return as text
+type aggregateTime struct {
+	Time time.Time
+}
+
+// Scan implements sql.Scanner
+func (t *aggregateTime) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("unsupported time value type %T", src)
+	}
+}
+
+// parse parses the text formats used by sqlite and mysql
+func (t *aggregateTime) parse(s string) error {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02T15:04:05.999999999-07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		time.RFC3339Nano,
+	} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported time format: %s", s)
+}
+
+// CountChanges returns the number of IP changes of each agent interface
+// between start and end
+func (r *ipChangeRepository) CountChanges(ctx context.Context, start, end time.Time) ([]*types.IPChangeCount, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select("agent_id", "interface_name", "COUNT(*)").
+		From("ip_changes").
+		Where("timestamp BETWEEN ? AND ?", start, end)
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
+	qb.GroupBy("agent_id", "interface_name")
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count IP changes: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var counts []*types.IPChangeCount
+	for rows.Next() {
+		count := &types.IPChangeCount{}
+		var iface sql.NullString
+		if err := rows.Scan(&count.AgentID, &iface, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan IP change count: %w", err)
+		}
+		count.Interface = iface.String
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating IP change counts: %w", err)
+	}
+
+	return counts, nil
+}
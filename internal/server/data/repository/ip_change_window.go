@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// ipChangeWindowRepository represents expected IP change window repository implementation
+type ipChangeWindowRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewIPChangeWindowRepository creates new expected IP change window repository
+func NewIPChangeWindowRepository(db database.Interface, logger *zap.Logger) IPChangeWindowRepository {
+	return &ipChangeWindowRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// List returns the windows by name
+func (r *ipChangeWindowRepository) List(ctx context.Context) ([]types.IPChangeWindow, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT data FROM ip_change_windows ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IP change windows: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	windows := []types.IPChangeWindow{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan IP change window: %w", err)
+		}
+
+		var w types.IPChangeWindow
+		if err := json.Unmarshal([]byte(data), &w); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal IP change window: %w", err)
+		}
+		windows = append(windows, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating IP change windows: %w", err)
+	}
+
+	return windows, nil
+}
+
+// Replace replaces all windows
+func (r *ipChangeWindowRepository) Replace(ctx context.Context, windows []types.IPChangeWindow) error {
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM ip_change_windows"); err != nil {
+			return fmt.Errorf("failed to delete previous IP change windows: %w", err)
+		}
+
+		query := "INSERT INTO ip_change_windows (name, data, updated_at) VALUES (?, ?, ?)"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+
+		now := time.Now()
+		for _, w := range windows {
+			data, err := json.Marshal(w)
+			if err != nil {
+				return fmt.Errorf("failed to marshal IP change window: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, query, w.Name, string(data), now); err != nil {
+				return fmt.Errorf("failed to save IP change window %s: %w", w.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
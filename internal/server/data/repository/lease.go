@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/clock"
+	"wameter/internal/database"
+
+	"go.uber.org/zap"
+)
+
+// leaseRepository represents leader lease repository implementation
+type leaseRepository struct {
+	db     database.Interface
+	clock  clock.Clock
+	logger *zap.Logger
+}
+
+// NewLeaseRepository creates new lease repository, leases expire by clk
+func NewLeaseRepository(db database.Interface, clk clock.Clock, logger *zap.Logger) LeaseRepository {
+	return &leaseRepository{
+		db:     db,
+		clock:  clk,
+		logger: logger,
+	}
+}
+
+// Acquire takes or renews lease name for holder until ttl from now, it
+// reports false while another holder has an unexpired lease
+func (r *leaseRepository) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := r.clock.Now()
+	expiresAt := now.Add(ttl).UnixMilli()
+
+	query := `
+        UPDATE leader_leases SET holder = ?, expires_at = ?
+        WHERE name = ? AND (holder = ? OR expires_at < ?)`
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, holder, expiresAt, name, holder, now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// The lease is held by another replica or was never taken
+	query = "INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING"
+	switch r.db.Driver() {
+	case "mysql":
+		query = "INSERT IGNORE INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?)"
+	case "postgres":
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err = r.db.ExecContext(ctx, query, name, holder, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// MySQL counts changed rows only, so a renewal within the same
+	// millisecond updates nothing, the holder tells whether it is ours
+	query = "SELECT holder, expires_at FROM leader_leases WHERE name = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	var current string
+	var currentExpiresAt int64
+	if err := r.db.QueryRowContext(ctx, query, name).Scan(&current, &currentExpiresAt); err != nil {
+		return false, fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	return current == holder && currentExpiresAt >= expiresAt, nil
+}
+
+// Release expires lease name if it is held by holder, so another replica
+// can take it over without waiting for the ttl
+func (r *leaseRepository) Release(ctx context.Context, name, holder string) error {
+	query := "UPDATE leader_leases SET expires_at = 0 WHERE name = ? AND holder = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, name, holder); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+
+	return nil
+}
@@ -1,16 +1,22 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 	"wameter/internal/database"
+	"wameter/internal/server/tenant"
 	"wameter/internal/types"
 
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -28,83 +34,424 @@ func NewMetricsRepository(db database.Interface, logger *zap.Logger) MetricsRepo
 	}
 }
 
-// Save saves metrics
+// Save saves metrics, a report that is already stored returns
+// ErrDuplicateMetrics
 func (r *metricsRepository) Save(ctx context.Context, data *types.MetricsData) error {
-	query := `
-        INSERT INTO metrics (
-            agent_id, timestamp, collected_at,
-            reported_at, data, created_at
-        ) VALUES (?, ?, ?, ?, ?, ?)`
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
 
-	if r.db.Driver() == "postgres" {
-		query = database.ConvertPlaceholders(query)
+	rows, err := encodeMetrics(buf, []*types.MetricsData{data})
+	if err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(data)
+	result, err := r.db.ExecContext(ctx, insertMetricsQuery(r.db.Driver(), 1),
+		metricsArgs(ctx, rows, time.Now())...)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metrics data: %w", err)
+		return fmt.Errorf("failed to save metrics: %w", err)
 	}
 
-	_, err = r.db.ExecContext(ctx, query,
-		data.AgentID,
-		data.Timestamp,
-		data.CollectedAt,
-		data.ReportedAt,
-		jsonData,
-		time.Now(),
-	)
-
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to save metrics: %w", err)
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrDuplicateMetrics
 	}
 
 	return nil
 }
 
-// BatchSave saves multiple metrics
-func (r *metricsRepository) BatchSave(ctx context.Context, metrics []*types.MetricsData) error {
-	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
-		query := `
-            INSERT INTO metrics (
-                agent_id, timestamp, collected_at,
-                reported_at, data, created_at
-            ) VALUES (?, ?, ?, ?, ?, ?)`
+// BatchSave saves multiple metrics and returns those that were not already
+// stored. Reports are written with multi-row inserts, or COPY on postgres.
+func (r *metricsRepository) BatchSave(ctx context.Context, metrics []*types.MetricsData) ([]*types.MetricsData, error) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
 
-		if r.db.Driver() == "postgres" {
-			query = database.ConvertPlaceholders(query)
+	rows, err := encodeMetrics(buf, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []*types.MetricsData
+	err = r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		var inserted map[string]bool
+		var err error
+		switch r.db.Driver() {
+		case "postgres":
+			inserted, err = r.copyMetrics(ctx, tx, rows)
+		case "mysql":
+			inserted, err = r.insertMetricsMySQL(ctx, tx, rows)
+		default:
+			inserted, err = r.insertMetrics(ctx, tx, rows)
 		}
+		if err != nil {
+			return err
+		}
+
+		// Reports sent twice within the batch are returned once
+		for _, row := range rows {
+			if inserted[row.key] {
+				saved = append(saved, row.data)
+				delete(inserted, row.key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		stmt, err := tx.PrepareContext(ctx, query)
+	return saved, nil
+}
+
+// insertMetrics writes reports with multi-row inserts returning the keys of
+// the rows that were not already stored
+func (r *metricsRepository) insertMetrics(ctx context.Context, tx *sql.Tx, rows []metricsRow) (map[string]bool, error) {
+	now := time.Now()
+	inserted := make(map[string]bool, len(rows))
+
+	for _, chunk := range bulkChunks(rows) {
+		stmt, release, err := r.txStmt(ctx, tx, insertMetricsQuery(r.db.Driver(), len(chunk))+" RETURNING idempotency_key")
 		if err != nil {
-			return fmt.Errorf("failed to prepare statement: %w", err)
+			return nil, err
 		}
 
-		defer func(stmt *sql.Stmt) {
-			_ = stmt.Close()
-		}(stmt)
+		result, err := stmt.QueryContext(ctx, metricsArgs(ctx, chunk, now)...)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("failed to save metrics: %w", err)
+		}
+		err = scanKeys(result, inserted)
+		release()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		for _, m := range metrics {
-			jsonData, err := json.Marshal(m)
-			if err != nil {
-				return fmt.Errorf("failed to marshal metrics: %w", err)
-			}
+	return inserted, nil
+}
 
-			_, err = stmt.ExecContext(ctx,
-				m.AgentID,
-				m.Timestamp,
-				m.CollectedAt,
-				m.ReportedAt,
-				jsonData,
-				time.Now(),
-			)
-
-			if err != nil {
-				return fmt.Errorf("failed to save metrics: %w", err)
-			}
+// insertMetricsMySQL writes reports with multi-row inserts. MySQL cannot
+// return the inserted rows, so stored keys are looked up first.
+func (r *metricsRepository) insertMetricsMySQL(ctx context.Context, tx *sql.Tx, rows []metricsRow) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for _, chunk := range bulkChunks(rows) {
+		keys := make([]any, len(chunk))
+		for i, row := range chunk {
+			keys[i] = row.key
 		}
 
-		return nil
-	})
+		result, err := tx.QueryContext(ctx,
+			fmt.Sprintf("SELECT idempotency_key FROM metrics WHERE idempotency_key IN (%s)", placeholders(len(keys))),
+			keys...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stored metrics: %w", err)
+		}
+		if err := scanKeys(result, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []metricsRow
+	inserted := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if !existing[row.key] && !inserted[row.key] {
+			pending = append(pending, row)
+			inserted[row.key] = true
+		}
+	}
+
+	now := time.Now()
+	for _, chunk := range bulkChunks(pending) {
+		stmt, release, err := r.txStmt(ctx, tx, insertMetricsQuery(r.db.Driver(), len(chunk)))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = stmt.ExecContext(ctx, metricsArgs(ctx, chunk, now)...)
+		release()
+		if err != nil {
+			return nil, fmt.Errorf("failed to save metrics: %w", err)
+		}
+	}
+
+	return inserted, nil
+}
+
+// copyMetrics streams reports into a staging table with COPY and moves the
+// ones not already stored into metrics
+func (r *metricsRepository) copyMetrics(ctx context.Context, tx *sql.Tx, rows []metricsRow) (map[string]bool, error) {
+	columns := strings.Join(metricsColumns, ", ")
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TEMP TABLE metrics_staging ON COMMIT DROP AS SELECT %s FROM metrics WITH NO DATA", columns)); err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("metrics_staging", metricsColumns...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare copy: %w", err)
+	}
+
+	defer func(stmt *sql.Stmt) {
+		_ = stmt.Close()
+	}(stmt)
+
+	tenantID := tenant.OrDefault(ctx)
+	now := time.Now()
+	for _, row := range rows {
+		// COPY sends []byte as bytea, the jsonb column takes the text
+		if _, err := stmt.ExecContext(ctx, row.values(tenantID, now, string(row.json))...); err != nil {
+			return nil, fmt.Errorf("failed to copy metrics: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to copy metrics: %w", err)
+	}
+
+	result, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"INSERT INTO metrics (%s) SELECT %s FROM metrics_staging ON CONFLICT (idempotency_key) DO NOTHING RETURNING idempotency_key",
+		columns, columns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save metrics: %w", err)
+	}
+
+	inserted := make(map[string]bool, len(rows))
+	if err := scanKeys(result, inserted); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// txStmt returns query prepared for tx. With the statement cache enabled the
+// statement is prepared once and reused by later transactions, the returned
+// function releases it.
+func (r *metricsRepository) txStmt(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, func(), error) {
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+
+	// Uncached statements belong to this call only
+	if r.db.GetCachedStmt(query) != stmt {
+		_ = stmt.Close()
+		if stmt, err = tx.PrepareContext(ctx, query); err != nil {
+			return nil, nil, fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		return stmt, func() { _ = stmt.Close() }, nil
+	}
+
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() { _ = txStmt.Close() }, nil
+}
+
+// scanKeys adds the idempotency keys of result to keys and closes it
+func scanKeys(result *sql.Rows, keys map[string]bool) error {
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(result)
+
+	for result.Next() {
+		var key string
+		if err := result.Scan(&key); err != nil {
+			return fmt.Errorf("failed to scan idempotency key: %w", err)
+		}
+		keys[key] = true
+	}
+	return result.Err()
+}
+
+// metricsColumns are the columns written for a metrics report, in the order
+// of metricsRow.values
+var metricsColumns = []string{
+	"agent_id", "tenant_id", "timestamp", "collected_at",
+	"reported_at", "data", "idempotency_key", "created_at",
+	"has_network", "interface_count", "total_rx_bytes", "total_tx_bytes",
+	"error_count", "ip_change_count",
+}
+
+// insertMetricsQuery returns the metrics insert statement of driver for n
+// rows, rows whose idempotency key is already stored are skipped
+func insertMetricsQuery(driver string, n int) string {
+	values := strings.TrimSuffix(strings.Repeat("("+placeholders(len(metricsColumns))+"), ", n), ", ")
+	query := fmt.Sprintf("INSERT INTO metrics (%s) VALUES %s", strings.Join(metricsColumns, ", "), values)
+
+	switch driver {
+	case "mysql":
+		return query + " ON DUPLICATE KEY UPDATE idempotency_key = idempotency_key"
+	case "postgres":
+		return database.ConvertPlaceholders(query + " ON CONFLICT (idempotency_key) DO NOTHING")
+	default:
+		return query + " ON CONFLICT (idempotency_key) DO NOTHING"
+	}
+}
+
+// metricsArgs returns the insert arguments of rows
+func metricsArgs(ctx context.Context, rows []metricsRow, now time.Time) []any {
+	tenantID := tenant.OrDefault(ctx)
+	args := make([]any, 0, len(rows)*len(metricsColumns))
+	for _, row := range rows {
+		args = append(args, row.values(tenantID, now, row.json)...)
+	}
+	return args
+}
+
+// bulkChunkSizes are the row counts of multi-row inserts. Batches are split
+// into these sizes so the few distinct statements stay in the statement cache.
+var bulkChunkSizes = []int{256, 64, 16, 4, 1}
+
+// bulkChunks splits rows into chunks of bulkChunkSizes, largest first
+func bulkChunks(rows []metricsRow) [][]metricsRow {
+	var chunks [][]metricsRow
+	for _, size := range bulkChunkSizes {
+		for len(rows) >= size {
+			chunks = append(chunks, rows[:size])
+			rows = rows[size:]
+		}
+	}
+	return chunks
+}
+
+// metricsRow represents a metrics report encoded for storage
+type metricsRow struct {
+	data *types.MetricsData
+	json []byte
+	key  string
+	hot  hotFields
+}
+
+// values returns the values of metricsColumns for row, data is the value of
+// the data column
+func (row *metricsRow) values(tenantID string, now time.Time, data any) []any {
+	return []any{
+		row.data.AgentID,
+		tenantID,
+		row.data.Timestamp,
+		row.data.CollectedAt,
+		row.data.ReportedAt,
+		data,
+		row.key,
+		now,
+		row.hot.hasNetwork,
+		row.hot.interfaceCount,
+		row.hot.rxBytes,
+		row.hot.txBytes,
+		row.hot.errorCount,
+		row.hot.ipChangeCount,
+	}
+}
+
+// hotFields are values of a report kept in their own columns, so summaries
+// do not have to parse the data column
+type hotFields struct {
+	hasNetwork     bool
+	interfaceCount int
+	rxBytes        int64 // Sum of the interface byte counters
+	txBytes        int64
+	errorCount     int64 // Sum of the interface receive and transmit errors
+	ipChangeCount  int
+}
+
+// extractHotFields returns the hot fields of a report
+func extractHotFields(data *types.MetricsData) hotFields {
+	network := data.Metrics.Network
+	if network == nil {
+		return hotFields{}
+	}
+
+	hot := hotFields{
+		hasNetwork:     true,
+		interfaceCount: len(network.Interfaces),
+		ipChangeCount:  len(network.IPChanges),
+	}
+	for _, iface := range network.Interfaces {
+		if iface == nil || iface.Statistics == nil {
+			continue
+		}
+		hot.rxBytes += int64(iface.Statistics.RxBytes)
+		hot.txBytes += int64(iface.Statistics.TxBytes)
+		hot.errorCount += int64(iface.Statistics.RxErrors + iface.Statistics.TxErrors)
+	}
+	return hot
+}
+
+// storedMetrics is the stored form of a report, its metrics are encoded once
+// and shared with the idempotency key
+type storedMetrics struct {
+	*types.MetricsData
+	Metrics json.RawMessage `json:"metrics"`
+}
+
+// maxPooledBuffer is the largest encode buffer kept for reuse
+const maxPooledBuffer = 4 << 20
+
+// encodeBuffers holds buffers reused to encode metrics reports
+var encodeBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getEncodeBuffer returns an empty encode buffer
+func getEncodeBuffer() *bytes.Buffer {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putEncodeBuffer returns buf for reuse, the rows encoded into it must not be
+// used anymore
+func putEncodeBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		encodeBuffers.Put(buf)
+	}
+}
+
+// encodeMetrics encodes reports into buf. The returned rows reference buf
+// and are valid until it is reused.
+func encodeMetrics(buf *bytes.Buffer, metrics []*types.MetricsData) ([]metricsRow, error) {
+	enc := json.NewEncoder(buf)
+	rows := make([]metricsRow, len(metrics))
+
+	for i, m := range metrics {
+		start := buf.Len()
+		if err := enc.Encode(m.Metrics); err != nil {
+			return nil, fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		// Encode terminates values with a newline
+		payload := buf.Bytes()[start : buf.Len()-1]
+
+		start = buf.Len()
+		if err := enc.Encode(&storedMetrics{MetricsData: m, Metrics: payload}); err != nil {
+			return nil, fmt.Errorf("failed to marshal metrics data: %w", err)
+		}
+
+		rows[i] = metricsRow{
+			data: m,
+			json: buf.Bytes()[start : buf.Len()-1],
+			key:  idempotencyKey(m, payload),
+			hot:  extractHotFields(m),
+		}
+	}
+
+	return rows, nil
+}
+
+// idempotencyKey identifies a metrics report across resubmissions by its
+// agent, collection time and collected metrics, payload is the encoded
+// metrics. The report and receive times change on retries and are left out.
+func idempotencyKey(data *types.MetricsData, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(data.AgentID))
+	h.Write([]byte{0})
+	h.Write([]byte(data.CollectedAt.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{0})
+	h.Write(payload)
+
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // Query returns metrics based on query parameters
@@ -113,13 +460,7 @@ func (r *metricsRepository) Query(ctx context.Context, params QueryParams) ([]*t
 
 	qb.Select("data")
 	qb.From("metrics")
-	qb.Where("timestamp BETWEEN ? AND ?", params.StartTime, params.EndTime)
-
-	if len(params.AgentIDs) > 0 {
-		placeholders := strings.Repeat("?,", len(params.AgentIDs))
-		placeholders = placeholders[:len(placeholders)-1]
-		qb.Where(fmt.Sprintf("agent_id IN (%s)", placeholders), interfaceSlice(params.AgentIDs)...)
-	}
+	whereMetrics(ctx, qb, params)
 
 	if params.OrderBy != "" {
 		direction := "ASC"
@@ -168,21 +509,123 @@ func (r *metricsRepository) Query(ctx context.Context, params QueryParams) ([]*t
 	return results, nil
 }
 
-// interfaceSlice converts []string to []any
-func interfaceSlice(slice []string) []any {
-	is := make([]any, len(slice))
-	for i, v := range slice {
-		is[i] = v
+// streamPageSize is the number of reports Stream reads per query
+const streamPageSize = 1000
+
+// Stream calls fn for each report matching params in timestamp order, newest
+// first when Order is DESC. Reports are read in pages keyed on (timestamp, id)
+// so memory use does not grow with the range and no connection is held while
+// fn runs. Limit caps the number of reports, OrderBy and Offset are ignored.
+func (r *metricsRepository) Stream(ctx context.Context, params QueryParams, fn func(*types.MetricsData) error) error {
+	cmp, direction := ">", "ASC"
+	if strings.EqualFold(params.Order, "DESC") {
+		cmp, direction = "<", "DESC"
+	}
+
+	var lastTime time.Time
+	var lastID int64
+	streamed := 0
+	for {
+		size := streamPageSize
+		if params.Limit > 0 {
+			size = min(size, params.Limit-streamed)
+		}
+		if size <= 0 {
+			return nil
+		}
+
+		qb := database.NewQueryBuilder(r.db.Driver())
+		qb.Select("id", "timestamp", "data")
+		qb.From("metrics")
+		whereMetrics(ctx, qb, params)
+		if streamed > 0 {
+			qb.Where(fmt.Sprintf("(timestamp %s ? OR (timestamp = ? AND id %s ?))", cmp, cmp),
+				lastTime, lastTime, lastID)
+		}
+		qb.OrderBy("timestamp "+direction, "id "+direction)
+		qb.Limit(size)
+
+		page, err := r.queryPage(ctx, qb)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range page {
+			if err := fn(m.data); err != nil {
+				return err
+			}
+		}
+		if len(page) < size {
+			return nil
+		}
+
+		last := page[len(page)-1]
+		lastTime, lastID = last.timestamp, last.id
+		streamed += len(page)
 	}
-	return is
+}
+
+// pagedMetrics represents a report read by Stream with its page key
+type pagedMetrics struct {
+	id        int64
+	timestamp time.Time
+	data      *types.MetricsData
+}
+
+// queryPage reads one page of Stream, the rows are closed before the reports
+// are handed out
+func (r *metricsRepository) queryPage(ctx context.Context, qb *database.QueryBuilder) ([]pagedMetrics, error) {
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var page []pagedMetrics
+	for rows.Next() {
+		var m pagedMetrics
+		var jsonData []byte
+		if err := rows.Scan(&m.id, &m.timestamp, &jsonData); err != nil {
+			return nil, fmt.Errorf("failed to scan metrics: %w", err)
+		}
+
+		m.data = new(types.MetricsData)
+		if err := json.Unmarshal(jsonData, m.data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metrics: %w", err)
+		}
+
+		page = append(page, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating metrics: %w", err)
+	}
+
+	return page, nil
+}
+
+// whereMetrics adds the time range, agent and tenant conditions of params
+func whereMetrics(ctx context.Context, qb *database.QueryBuilder, params QueryParams) {
+	qb.Where("timestamp BETWEEN ? AND ?", params.StartTime, params.EndTime)
+
+	if len(params.AgentIDs) > 0 {
+		qb.Where("agent_id IN (?)", params.AgentIDs)
+	}
+
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
 }
 
 // GetLatest returns the latest metrics for the given agent
 func (r *metricsRepository) GetLatest(ctx context.Context, agentID string) (*types.MetricsData, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT data
         FROM metrics
-        WHERE agent_id = ?
+        WHERE agent_id = ?` + cond + `
         ORDER BY timestamp DESC
         LIMIT 1`
 
@@ -191,7 +634,7 @@ func (r *metricsRepository) GetLatest(ctx context.Context, agentID string) (*typ
 	}
 
 	var jsonData []byte
-	err := r.db.QueryRowContext(ctx, query, agentID).Scan(&jsonData)
+	err := r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...).Scan(&jsonData)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, types.ErrAgentNotFound
 	}
@@ -209,12 +652,13 @@ func (r *metricsRepository) GetLatest(ctx context.Context, agentID string) (*typ
 
 // DeleteBefore deletes metrics before the given time
 func (r *metricsRepository) DeleteBefore(ctx context.Context, before time.Time) error {
-	query := "DELETE FROM metrics WHERE timestamp < ?"
+	cond, args := tenantCond(ctx, "tenant_id")
+	query := "DELETE FROM metrics WHERE timestamp < ?" + cond
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	result, err := r.db.ExecContext(ctx, query, before)
+	result, err := r.db.ExecContext(ctx, query, append([]any{before}, args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to delete metrics: %w", err)
 	}
@@ -236,8 +680,9 @@ func (r *metricsRepository) GetMetricsByTimeRange(ctx context.Context, startTime
 	qb := database.NewQueryBuilder(r.db.Driver())
 	qb.Select("data").
 		From("metrics").
-		Where("timestamp BETWEEN ? AND ?", startTime, endTime).
-		OrderBy("timestamp DESC")
+		Where("timestamp BETWEEN ? AND ?", startTime, endTime)
+	whereTenant(ctx, qb, "tenant_id")
+	qb.OrderBy("timestamp DESC")
 
 	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
 	if err != nil {
@@ -273,20 +718,21 @@ func (r *metricsRepository) GetMetricsByTimeRange(ctx context.Context, startTime
 
 // GetMetricsSummary returns a summary of metrics for an agent
 func (r *metricsRepository) GetMetricsSummary(ctx context.Context, agentID string) (*types.MetricsSummary, error) {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT
             COUNT(*) as total_metrics,
             MIN(timestamp) as first_seen,
             MAX(timestamp) as last_seen
         FROM metrics
-        WHERE agent_id = ?`
+        WHERE agent_id = ?` + cond
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
 	summary := &types.MetricsSummary{}
-	err := r.db.QueryRowContext(ctx, query, agentID).Scan(
+	err := r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...).Scan(
 		&summary.TotalMetrics,
 		&summary.FirstSeen,
 		&summary.LastSeen,
@@ -305,36 +751,48 @@ func (r *metricsRepository) GetMetricsSummary(ctx context.Context, agentID strin
 	return summary, nil
 }
 
-// getNetworkMetricsSummary retrieves network-specific metrics summary
+// getNetworkMetricsSummary retrieves network-specific metrics summary from
+// the hot columns of the reports. Traffic and errors are the growth of the
+// cumulative interface counters over the stored reports.
 func (r *metricsRepository) getNetworkMetricsSummary(ctx context.Context, agentID string, summary *types.MetricsSummary) error {
+	cond, args := tenantCond(ctx, "tenant_id")
 	query := `
         SELECT
-            SUM(
-                CAST(data->'metrics'->'network'->>'total_traffic' AS BIGINT)
-            ) as total_traffic,
-            AVG(
-                CAST(data->'metrics'->'network'->>'utilization' AS FLOAT)
-            ) as avg_utilization,
-            COUNT(DISTINCT data->'metrics'->'network'->'ip_changes') as ip_changes
+            COUNT(*),
+            COALESCE(MAX(total_rx_bytes) - MIN(total_rx_bytes) + MAX(total_tx_bytes) - MIN(total_tx_bytes), 0),
+            COALESCE(MAX(error_count) - MIN(error_count), 0),
+            COALESCE(SUM(ip_change_count), 0)
         FROM metrics
-        WHERE agent_id = ?
-        AND data->'metrics'->>'network' IS NOT NULL`
+        WHERE agent_id = ? AND has_network = TRUE` + cond
 
 	if r.db.Driver() == "postgres" {
 		query = database.ConvertPlaceholders(query)
 	}
 
-	return r.db.QueryRowContext(ctx, query, agentID).Scan(
-		&summary.NetworkMetrics.TotalTraffic,
-		&summary.NetworkMetrics.AvgUtilization,
+	var reports, traffic, errorCount int64
+	err := r.db.QueryRowContext(ctx, query, append([]any{agentID}, args...)...).Scan(
+		&reports,
+		&traffic,
+		&errorCount,
 		&summary.NetworkMetrics.IPChanges,
 	)
+	if err != nil {
+		return err
+	}
+
+	summary.NetworkMetrics.TotalTraffic = uint64(max(traffic, 0))
+	if reports > 0 {
+		summary.NetworkMetrics.ErrorRate = float64(max(errorCount, 0)) / float64(reports)
+	}
+
+	return nil
 }
 
 // PruneMetrics deletes metrics older than the specified time
 func (r *metricsRepository) PruneMetrics(ctx context.Context, before time.Time) error {
 	qb := database.NewQueryBuilder(r.db.Driver())
-	qb.Raw("DELETE FROM metrics WHERE timestamp < ?", before)
+	cond, args := tenantCond(ctx, "tenant_id")
+	qb.Raw("DELETE FROM metrics WHERE timestamp < ?"+cond, append([]any{before}, args...)...)
 
 	result, err := r.db.ExecContext(ctx, qb.SQL(), qb.Args()...)
 	if err != nil {
@@ -352,3 +810,193 @@ func (r *metricsRepository) PruneMetrics(ctx context.Context, before time.Time)
 
 	return nil
 }
+
+// GetInterfaceUsage returns the traffic of each interface reporting
+// statistics between start and end, aggregated from the interfaces of the
+// stored metrics. Error and packet counts are the growth of the cumulative
+// counters during the range.
+func (r *metricsRepository) GetInterfaceUsage(ctx context.Context, start, end time.Time) ([]*types.InterfaceUsage, error) {
+	var from, iface, hasStats string
+	var stat func(field string) string
+	switch r.db.Driver() {
+	case "postgres":
+		interfaces := "m.data->'metrics'->'network'->'interfaces'"
+		from = "metrics m CROSS JOIN LATERAL jsonb_each(CASE WHEN jsonb_typeof(" + interfaces + ") = 'object' THEN " +
+			interfaces + " END) AS i(key, value)"
+		iface = "i.key"
+		hasStats = "jsonb_typeof(i.value->'statistics') = 'object'"
+		stat = func(field string) string {
+			return "COALESCE(CAST(i.value->'statistics'->>'" + field + "' AS DOUBLE PRECISION), 0)"
+		}
+	case "mysql":
+		path := "CONCAT('$.metrics.network.interfaces.\"', i.iface, '\".statistics"
+		from = "metrics m CROSS JOIN JSON_TABLE(JSON_KEYS(m.data, '$.metrics.network.interfaces'), " +
+			"'$[*]' COLUMNS (iface VARCHAR(255) PATH '$')) AS i"
+		iface = "i.iface"
+		hasStats = "JSON_TYPE(JSON_EXTRACT(m.data, " + path + "'))) = 'OBJECT'"
+		stat = func(field string) string {
+			return "COALESCE(CAST(JSON_EXTRACT(m.data, " + path + "." + field + "')) AS DOUBLE), 0)"
+		}
+	default:
+		// SQLite
+		from = "metrics m, json_each(m.data, '$.metrics.network.interfaces') AS i"
+		iface = "i.key"
+		hasStats = "i.type = 'object' AND json_type(i.value, '$.statistics') = 'object'"
+		stat = func(field string) string {
+			return "COALESCE(json_extract(i.value, '$.statistics." + field + "'), 0)"
+		}
+	}
+
+	// One sample per interface of each metrics row
+	samples := database.NewQueryBuilder(r.db.Driver())
+	samples.Select(
+		"m.agent_id AS agent_id",
+		iface+" AS iface",
+		stat("rx_bytes_rate")+" + "+stat("tx_bytes_rate")+" AS bytes_rate",
+		stat("rx_errors")+" + "+stat("tx_errors")+" AS errors",
+		stat("rx_packets")+" + "+stat("tx_packets")+" AS packets",
+		stat("rx_bytes")+" AS rx_bytes",
+		stat("tx_bytes")+" AS tx_bytes",
+	).
+		From(from).
+		Where("m.timestamp BETWEEN ? AND ?", start, end).
+		Where(hasStats)
+	whereTenant(ctx, samples, "m.tenant_id")
+	whereAgents(ctx, samples, "m.agent_id")
+
+	query := `
+        SELECT agent_id, iface, COUNT(*), AVG(bytes_rate),
+               MAX(errors) - MIN(errors), MAX(packets) - MIN(packets),
+               MAX(rx_bytes) - MIN(rx_bytes), MAX(tx_bytes) - MIN(tx_bytes)
+        FROM (` + samples.SQL() + `) s
+        GROUP BY agent_id, iface`
+
+	rows, err := r.db.QueryContext(ctx, query, samples.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query interface usage: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var usage []*types.InterfaceUsage
+	for rows.Next() {
+		u := &types.InterfaceUsage{}
+		var bandwidth, errs, packets, rxBytes, txBytes sql.NullFloat64
+		if err := rows.Scan(&u.AgentID, &u.Interface, &u.Samples, &bandwidth, &errs, &packets, &rxBytes, &txBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan interface usage: %w", err)
+		}
+		u.Bandwidth = bandwidth.Float64
+		u.Errors = uint64(errs.Float64)
+		u.Packets = uint64(packets.Float64)
+		u.RxBytes = uint64(rxBytes.Float64)
+		u.TxBytes = uint64(txBytes.Float64)
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating interface usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// CountReportingSlots counts per agent the slots of the given length, aligned
+// to the Unix epoch, in which it reported metrics
+func (r *metricsRepository) CountReportingSlots(ctx context.Context, start, end time.Time, slot time.Duration) (map[string]int64, error) {
+	seconds := int64(slot / time.Second)
+	if seconds <= 0 {
+		return nil, fmt.Errorf("invalid slot length: %s", slot)
+	}
+
+	var epoch string
+	switch r.db.Driver() {
+	case "postgres":
+		epoch = fmt.Sprintf("FLOOR(EXTRACT(EPOCH FROM timestamp) / %d)", seconds)
+	case "mysql":
+		epoch = fmt.Sprintf("FLOOR(UNIX_TIMESTAMP(timestamp) / %d)", seconds)
+	default:
+		// SQLite
+		epoch = fmt.Sprintf("CAST(strftime('%%s', timestamp) AS INTEGER) / %d", seconds)
+	}
+
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select("agent_id", "COUNT(DISTINCT "+epoch+")").
+		From("metrics").
+		Where("timestamp BETWEEN ? AND ?", start, end)
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
+	qb.GroupBy("agent_id")
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reporting slots: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	slots := make(map[string]int64)
+	for rows.Next() {
+		var agentID string
+		var count int64
+		if err := rows.Scan(&agentID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reporting slots: %w", err)
+		}
+		slots[agentID] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reporting slots: %w", err)
+	}
+
+	return slots, nil
+}
+
+// GetStorageByAgent returns the number and size of the reports stored per
+// agent, sizes are of the stored JSON and exclude indexes
+func (r *metricsRepository) GetStorageByAgent(ctx context.Context) ([]*types.AgentStorage, error) {
+	var size string
+	switch r.db.Driver() {
+	case "postgres":
+		size = "pg_column_size(data)"
+	case "mysql":
+		size = "LENGTH(data)"
+	default:
+		// SQLite
+		size = "LENGTH(CAST(data AS BLOB))"
+	}
+
+	qb := database.NewQueryBuilder(r.db.Driver())
+	qb.Select("agent_id", "COUNT(*)", "COALESCE(SUM("+size+"), 0)").
+		From("metrics")
+	whereTenant(ctx, qb, "tenant_id")
+	whereAgents(ctx, qb, "agent_id")
+	qb.GroupBy("agent_id")
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics storage: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var storage []*types.AgentStorage
+	for rows.Next() {
+		var s types.AgentStorage
+		if err := rows.Scan(&s.AgentID, &s.Rows, &s.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan metrics storage: %w", err)
+		}
+		storage = append(storage, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating metrics storage: %w", err)
+	}
+
+	return storage, nil
+}
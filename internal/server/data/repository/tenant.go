@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// tenantRepository represents tenant and API key repository implementation
+type tenantRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewTenantRepository creates new tenant repository
+func NewTenantRepository(db database.Interface, logger *zap.Logger) TenantRepository {
+	return &tenantRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves a new tenant
+func (r *tenantRepository) Save(ctx context.Context, t *types.Tenant) error {
+	if _, err := r.FindByID(ctx, t.ID); err == nil {
+		return types.ErrTenantExists
+	} else if !errors.Is(err, types.ErrTenantNotFound) {
+		return err
+	}
+
+	query := "INSERT INTO tenants (id, name, created_at) VALUES (?, ?, ?)"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, t.ID, t.Name, t.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save tenant: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns tenant by ID
+func (r *tenantRepository) FindByID(ctx context.Context, id string) (*types.Tenant, error) {
+	query := "SELECT id, name, created_at FROM tenants WHERE id = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	var t types.Tenant
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&t.ID, &t.Name, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenant: %w", err)
+	}
+
+	return &t, nil
+}
+
+// List returns all tenants
+func (r *tenantRepository) List(ctx context.Context) ([]*types.Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tenants []*types.Tenant
+	for rows.Next() {
+		var t types.Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenants: %w", err)
+	}
+
+	return tenants, nil
+}
+
+// Delete deletes a tenant with its users and API keys, tenants with agents
+// are kept
+func (r *tenantRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		query := "SELECT COUNT(*) FROM agents WHERE tenant_id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+
+		var agents int64
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&agents); err != nil {
+			return fmt.Errorf("failed to count tenant agents: %w", err)
+		}
+		if agents > 0 {
+			return types.ErrTenantInUse
+		}
+
+		query = "DELETE FROM api_keys WHERE tenant_id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete tenant api keys: %w", err)
+		}
+
+		query = "DELETE FROM users WHERE tenant_id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete tenant users: %w", err)
+		}
+
+		query = "DELETE FROM tenants WHERE id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete tenant: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if affected == 0 {
+			return types.ErrTenantNotFound
+		}
+
+		return nil
+	})
+}
+
+// Usage returns the resources held by each tenant
+func (r *tenantRepository) Usage(ctx context.Context) ([]*types.TenantUsage, error) {
+	tenants, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]*types.TenantUsage, len(tenants))
+	result := make([]*types.TenantUsage, 0, len(tenants))
+	for _, t := range tenants {
+		u := &types.TenantUsage{TenantID: t.ID}
+		usage[t.ID] = u
+		result = append(result, u)
+	}
+
+	counters := []struct {
+		query string
+		field func(*types.TenantUsage) *int64
+	}{
+		{"SELECT tenant_id, COUNT(*) FROM agents GROUP BY tenant_id",
+			func(u *types.TenantUsage) *int64 { return &u.Agents }},
+		{fmt.Sprintf("SELECT tenant_id, COUNT(*) FROM agents WHERE status = '%s' GROUP BY tenant_id", types.AgentStatusOnline),
+			func(u *types.TenantUsage) *int64 { return &u.OnlineAgents }},
+		{"SELECT tenant_id, COUNT(*) FROM metrics GROUP BY tenant_id",
+			func(u *types.TenantUsage) *int64 { return &u.Metrics }},
+		{"SELECT tenant_id, COUNT(*) FROM ip_changes GROUP BY tenant_id",
+			func(u *types.TenantUsage) *int64 { return &u.IPChanges }},
+		{"SELECT tenant_id, COUNT(*) FROM api_keys GROUP BY tenant_id",
+			func(u *types.TenantUsage) *int64 { return &u.APIKeys }},
+	}
+
+	for _, c := range counters {
+		if err := r.count(ctx, c.query, func(id string, n int64) {
+			if u, ok := usage[id]; ok {
+				*c.field(u) = n
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// count runs a per tenant count query
+func (r *tenantRepository) count(ctx context.Context, query string, fn func(id string, n int64)) error {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to count tenant usage: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var id string
+		var n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return fmt.Errorf("failed to scan tenant usage: %w", err)
+		}
+		fn(id, n)
+	}
+
+	return rows.Err()
+}
+
+// SaveKey saves a new API key, only the hash of the key is stored
+func (r *tenantRepository) SaveKey(ctx context.Context, key *types.APIKey, hash string) error {
+	query := `
+        INSERT INTO api_keys (id, tenant_id, user_id, name, key_hash, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)`
+
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, key.ID, key.TenantID, nullString(key.UserID), key.Name, hash, key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save api key: %w", err)
+	}
+
+	return nil
+}
+
+// FindKeyByHash returns the API key with the given hash
+func (r *tenantRepository) FindKeyByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	query := "SELECT id, tenant_id, user_id, name, created_at FROM api_keys WHERE key_hash = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListKeys returns the API keys of a tenant
+func (r *tenantRepository) ListKeys(ctx context.Context, tenantID string) ([]*types.APIKey, error) {
+	query := "SELECT id, tenant_id, user_id, name, created_at FROM api_keys WHERE tenant_id = ? ORDER BY created_at"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var keys []*types.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteKey deletes an API key of a tenant
+func (r *tenantRepository) DeleteKey(ctx context.Context, tenantID, id string) error {
+	query := "DELETE FROM api_keys WHERE tenant_id = ? AND id = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrInvalidAPIKey
+	}
+
+	return nil
+}
+
+// tenantCond returns a condition limiting a query to the tenant of ctx, it
+// is empty for unscoped contexts
+func tenantCond(ctx context.Context, column string) (string, []any) {
+	if id, ok := tenant.FromContext(ctx); ok {
+		return fmt.Sprintf(" AND %s = ?", column), []any{id}
+	}
+	return "", nil
+}
+
+// whereTenant limits a built query to the tenant of ctx
+func whereTenant(ctx context.Context, qb *database.QueryBuilder, column string) {
+	if id, ok := tenant.FromContext(ctx); ok {
+		qb.Where(column+" = ?", id)
+	}
+}
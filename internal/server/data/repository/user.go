@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/server/rbac"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// userRepository represents user repository implementation
+type userRepository struct {
+	db     database.Interface
+	logger *zap.Logger
+}
+
+// NewUserRepository creates new user repository
+func NewUserRepository(db database.Interface, logger *zap.Logger) UserRepository {
+	return &userRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save saves a new user
+func (r *userRepository) Save(ctx context.Context, user *types.User) error {
+	if _, err := r.FindByID(ctx, user.ID); err == nil {
+		return types.ErrUserExists
+	} else if !errors.Is(err, types.ErrUserNotFound) {
+		return err
+	}
+
+	agents, err := json.Marshal(user.Agents)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user agents: %w", err)
+	}
+
+	query := `
+        INSERT INTO users (id, tenant_id, name, role, agents, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query,
+		user.ID, user.TenantID, user.Name, user.Role, string(agents),
+		user.CreatedAt, user.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to save user: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns user by ID
+func (r *userRepository) FindByID(ctx context.Context, id string) (*types.User, error) {
+	query := `
+        SELECT id, tenant_id, name, role, agents, created_at, updated_at
+        FROM users WHERE id = ?`
+
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+
+	return user, nil
+}
+
+// List returns the users of a tenant, or of all tenants if tenantID is empty
+func (r *userRepository) List(ctx context.Context, tenantID string) ([]*types.User, error) {
+	qb := database.NewQueryBuilder(r.db.Driver())
+
+	qb.Select("id", "tenant_id", "name", "role", "agents", "created_at", "updated_at")
+	qb.From("users")
+
+	if tenantID != "" {
+		qb.Where("tenant_id = ?", tenantID)
+	}
+
+	qb.OrderBy("id")
+
+	rows, err := r.db.QueryContext(ctx, qb.SQL(), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var users []*types.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Update updates the name, role and agents of a user
+func (r *userRepository) Update(ctx context.Context, user *types.User) error {
+	agents, err := json.Marshal(user.Agents)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user agents: %w", err)
+	}
+
+	query := "UPDATE users SET name = ?, role = ?, agents = ?, updated_at = ? WHERE id = ?"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
+		user.Name, user.Role, string(agents), user.UpdatedAt, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return types.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a user and revokes its API keys
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithTransaction(ctx, func(tx *sql.Tx) error {
+		query := "DELETE FROM api_keys WHERE user_id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete user api keys: %w", err)
+		}
+
+		query = "DELETE FROM users WHERE id = ?"
+		if r.db.Driver() == "postgres" {
+			query = database.ConvertPlaceholders(query)
+		}
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if affected == 0 {
+			return types.ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// ListKeys returns the API keys issued to a user
+func (r *userRepository) ListKeys(ctx context.Context, userID string) ([]*types.APIKey, error) {
+	query := "SELECT id, tenant_id, user_id, name, created_at FROM api_keys WHERE user_id = ? ORDER BY created_at"
+	if r.db.Driver() == "postgres" {
+		query = database.ConvertPlaceholders(query)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var keys []*types.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans a users row
+func scanUser(row rowScanner) (*types.User, error) {
+	var user types.User
+	var agents string
+	if err := row.Scan(&user.ID, &user.TenantID, &user.Name, &user.Role, &agents,
+		&user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(agents), &user.Agents); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user agents: %w", err)
+	}
+	if user.Agents == nil {
+		user.Agents = []string{}
+	}
+	return &user, nil
+}
+
+// scanAPIKey scans an api_keys row without its hash
+func scanAPIKey(row rowScanner) (*types.APIKey, error) {
+	var key types.APIKey
+	var userID sql.NullString
+	if err := row.Scan(&key.ID, &key.TenantID, &userID, &key.Name, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	key.UserID = userID.String
+	return &key, nil
+}
+
+// whereAgents limits a built query to the agents the principal of ctx may
+// access
+func whereAgents(ctx context.Context, qb *database.QueryBuilder, column string) {
+	if agents := rbac.Agents(ctx); len(agents) > 0 {
+		qb.Where(column+" IN (?)", agents)
+	}
+}
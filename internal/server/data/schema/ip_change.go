@@ -23,6 +23,8 @@ func (IPChange) Fields() []ent.Field {
 		field.JSON("new_addrs", map[string]any{}).Optional(),
 		field.String("action"),
 		field.String("reason"),
+		field.JSON("ip_context", map[string]any{}).Optional(),
+		field.String("expected_window").Default(""),
 		field.Time("timestamp"),
 		field.Time("created_at"),
 	}
@@ -0,0 +1,277 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wameter/internal/server/config"
+
+	"go.uber.org/zap"
+)
+
+// retryDelay is the wait before the pods are listed again after a failure
+const retryDelay = 10 * time.Second
+
+// errExpired is returned when the watched resource version is too old
+var errExpired = errors.New("resource version expired")
+
+// Pod represents an agent pod scheduled to a node
+type Pod struct {
+	Namespace   string
+	Name        string
+	Node        string
+	Annotations map[string]string
+}
+
+// Key returns the namespace and name of the pod
+func (p *Pod) Key() string {
+	return p.Namespace + "/" + p.Name
+}
+
+// Kubernetes keeps the set of agent pods in sync with the Kubernetes API by
+// listing and then watching them
+type Kubernetes struct {
+	config  *config.KubernetesDiscoveryConfig
+	baseURL string
+	client  *http.Client
+	logger  *zap.Logger
+}
+
+// NewKubernetes creates new Kubernetes discovery, connecting to the
+// in-cluster API server unless one is configured
+func NewKubernetes(cfg *config.KubernetesDiscoveryConfig, logger *zap.Logger) (*Kubernetes, error) {
+	baseURL := cfg.APIServer
+	if baseURL == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("not running in a cluster, api_server is required")
+		}
+		baseURL = "https://" + net.JoinHostPort(host, port)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if pem, err := os.ReadFile(cfg.CAFile); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Kubernetes{
+		config:  cfg,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		// Watches are long-lived, they are bounded by timeoutSeconds instead
+		client: &http.Client{Transport: transport},
+		logger: logger,
+	}, nil
+}
+
+// Run keeps the agent pods in sync until ctx is done, sync is called with
+// all current pods after the initial list and after every change
+func (k *Kubernetes) Run(ctx context.Context, sync func(pods []Pod)) {
+	for ctx.Err() == nil {
+		pods, version, err := k.list(ctx)
+		if err != nil {
+			k.logger.Error("Failed to list agent pods", zap.Error(err))
+			sleep(ctx, retryDelay)
+			continue
+		}
+		sync(values(pods))
+
+		// Watch until the server closes the watch at the resync interval
+		err = k.watch(ctx, version, func(pod *Pod, running bool) {
+			if running {
+				pods[pod.Key()] = *pod
+			} else {
+				delete(pods, pod.Key())
+			}
+			sync(values(pods))
+		})
+		if err != nil && ctx.Err() == nil {
+			if !errors.Is(err, errExpired) {
+				k.logger.Warn("Agent pod watch failed", zap.Error(err))
+				sleep(ctx, retryDelay)
+			}
+		}
+	}
+}
+
+// podList represents the pod list of the API
+type podList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []podObject `json:"items"`
+}
+
+// podObject represents the fields of a pod used for discovery
+type podObject struct {
+	Metadata struct {
+		Name              string            `json:"name"`
+		Namespace         string            `json:"namespace"`
+		Annotations       map[string]string `json:"annotations"`
+		DeletionTimestamp *time.Time        `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+	// Code is set on error events
+	Code int `json:"code"`
+}
+
+// pod returns the pod, nil when it is not scheduled or is terminating
+func (o *podObject) pod() *Pod {
+	if o.Spec.NodeName == "" || o.Metadata.DeletionTimestamp != nil ||
+		o.Status.Phase == "Succeeded" || o.Status.Phase == "Failed" {
+		return nil
+	}
+	return &Pod{
+		Namespace:   o.Metadata.Namespace,
+		Name:        o.Metadata.Name,
+		Node:        o.Spec.NodeName,
+		Annotations: o.Metadata.Annotations,
+	}
+}
+
+// list lists the agent pods and returns the resource version to watch from
+func (k *Kubernetes) list(ctx context.Context) (map[string]Pod, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := k.get(ctx, url.Values{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var list podList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to decode pod list: %w", err)
+	}
+
+	pods := make(map[string]Pod, len(list.Items))
+	for i := range list.Items {
+		if pod := list.Items[i].pod(); pod != nil {
+			pods[pod.Key()] = *pod
+		}
+	}
+	return pods, list.Metadata.ResourceVersion, nil
+}
+
+// watch streams pod events from version, running is false for pods that
+// were deleted or no longer run an agent
+func (k *Kubernetes) watch(ctx context.Context, version string, fn func(pod *Pod, running bool)) error {
+	resp, err := k.get(ctx, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {version},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(k.config.ResyncInterval.Seconds()))},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event struct {
+			Type   string    `json:"type"`
+			Object podObject `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode watch event: %w", err)
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			pod := event.Object.pod()
+			if event.Type == "DELETED" || pod == nil {
+				fn(&Pod{Namespace: event.Object.Metadata.Namespace, Name: event.Object.Metadata.Name}, false)
+				continue
+			}
+			fn(pod, true)
+		case "ERROR":
+			if event.Object.Code == http.StatusGone {
+				return errExpired
+			}
+			return fmt.Errorf("watch error %d", event.Object.Code)
+		}
+	}
+}
+
+// get requests the agent pods with query
+func (k *Kubernetes) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	path := "/api/v1/pods"
+	if k.config.Namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(k.config.Namespace) + "/pods"
+	}
+	query.Set("labelSelector", k.config.LabelSelector)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// Projected service account tokens rotate, read it for every request
+	if token, err := os.ReadFile(k.config.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read token: %w", err)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusGone {
+			return nil, errExpired
+		}
+		return nil, fmt.Errorf("kubernetes API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// values returns the pods of m
+func values(m map[string]Pod) []Pod {
+	pods := make([]Pod, 0, len(m))
+	for _, pod := range m {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,276 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// maxPacketSize keeps StatsD datagrams below the common path MTU
+const maxPacketSize = 1432
+
+// nameReplacer replaces the separators of metric name components
+var nameReplacer = strings.NewReplacer(".", "_", " ", "_", ";", "_", ":", "_", "|", "_", "#", "_", ",", "_", "=", "_", "~", "_", "/", "_")
+
+// report represents a metrics report waiting to be forwarded
+type report struct {
+	data *types.MetricsData
+	tags map[string]string // Agent tags
+}
+
+// tag represents a forwarded agent tag
+type tag struct {
+	key  string // Of the agent
+	name string // Forwarded as
+}
+
+// Forwarder sends interface metrics of ingested reports to a Graphite or
+// StatsD endpoint from a background sender, reports are dropped instead of
+// delaying ingestion when the endpoint is slow or down
+type Forwarder struct {
+	config *config.ForwardConfig
+	logger *zap.Logger
+	tags   []tag
+	conn   net.Conn
+
+	queue   chan *report
+	closed  bool
+	mu      sync.Mutex
+	dropped atomic.Int64
+	wg      sync.WaitGroup
+}
+
+// New creates new forwarder
+func New(cfg *config.ForwardConfig, logger *zap.Logger) *Forwarder {
+	f := &Forwarder{
+		config: cfg,
+		logger: logger,
+		queue:  make(chan *report, cfg.QueueSize),
+	}
+	for _, t := range cfg.Tags {
+		key, name, ok := strings.Cut(t, "=")
+		key = strings.TrimSpace(key)
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			name = key
+		}
+		f.tags = append(f.tags, tag{key: key, name: name})
+	}
+	return f
+}
+
+// Start starts the sender
+func (f *Forwarder) Start() {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.send()
+	}()
+}
+
+// Stop stops accepting reports and waits for the queued ones to be sent
+// until ctx expires
+func (f *Forwarder) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timeout forwarding metrics: %w", ctx.Err())
+	}
+}
+
+// Forward queues the interface metrics of a report with the tags of its
+// agent, it does not block
+func (f *Forwarder) Forward(data *types.MetricsData, tags map[string]string) {
+	if data.Metrics.Network == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	select {
+	case f.queue <- &report{data: data, tags: tags}:
+	default:
+		// Warn on the first drop and then every 1000
+		if n := f.dropped.Add(1); n%1000 == 1 {
+			f.logger.Warn("Forward queue full, dropping reports",
+				zap.Int64("dropped", n))
+		}
+	}
+}
+
+// send writes the queued reports until the queue is closed
+func (f *Forwarder) send() {
+	defer func() {
+		if f.conn != nil {
+			_ = f.conn.Close()
+		}
+	}()
+
+	for r := range f.queue {
+		lines := f.encode(r)
+		if len(lines) == 0 {
+			continue
+		}
+		if err := f.write(lines); err != nil {
+			f.logger.Error("Failed to forward metrics",
+				zap.Error(err),
+				zap.String("address", f.config.Address),
+				zap.String("agent_id", r.data.AgentID))
+		}
+	}
+}
+
+// write sends lines, reconnecting once if the connection was lost
+func (f *Forwarder) write(lines [][]byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conn == nil {
+			f.conn, err = net.DialTimeout(f.config.Network, f.config.Address, f.config.Timeout)
+			if err != nil {
+				f.conn = nil
+				return fmt.Errorf("failed to connect: %w", err)
+			}
+		}
+		if err = f.writePackets(lines); err == nil {
+			return nil
+		}
+		_ = f.conn.Close()
+		f.conn = nil
+	}
+	return fmt.Errorf("failed to write: %w", err)
+}
+
+// writePackets writes lines as a stream over TCP, or in datagrams of at
+// most maxPacketSize over UDP
+func (f *Forwarder) writePackets(lines [][]byte) error {
+	if err := f.conn.SetWriteDeadline(time.Now().Add(f.config.Timeout)); err != nil {
+		return err
+	}
+
+	if f.config.Network != "udp" {
+		_, err := f.conn.Write(bytes.Join(lines, nil))
+		return err
+	}
+
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+len(line) > maxPacketSize {
+			if _, err := f.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		packet = append(packet, line...)
+	}
+	_, err := f.conn.Write(packet)
+	return err
+}
+
+// encode returns the lines of the selected metrics of each interface with statistics
+func (f *Forwarder) encode(r *report) [][]byte {
+	data := r.data
+	network := data.Metrics.Network
+
+	var lines [][]byte
+	for _, name := range slices.Sorted(maps.Keys(network.Interfaces)) {
+		iface := network.Interfaces[name]
+		if iface.Statistics == nil {
+			continue
+		}
+		for _, metric := range f.config.Metrics {
+			value, ok := iface.Statistics.Metric(metric)
+			if !ok {
+				continue
+			}
+			lines = append(lines, f.line(r, iface.Name, metric, value))
+		}
+	}
+	return lines
+}
+
+// line formats a metric value of an interface in the configured protocol
+func (f *Forwarder) line(r *report, iface, metric string, value float64) []byte {
+	agentID := sanitize(r.data.AgentID)
+
+	var name strings.Builder
+	name.WriteString(f.config.Prefix)
+	var tags [][2]string
+	if f.config.Tagged {
+		tags = append(tags, [2]string{"agent", agentID}, [2]string{"interface", sanitize(iface)})
+		if r.data.Hostname != "" {
+			tags = append(tags, [2]string{"host", sanitize(r.data.Hostname)})
+		}
+		for _, t := range f.tags {
+			if v := r.tags[t.key]; v != "" {
+				tags = append(tags, [2]string{sanitize(t.name), sanitize(v)})
+			}
+		}
+	} else {
+		for _, t := range f.tags {
+			v := r.tags[t.key]
+			if v == "" {
+				v = "none"
+			}
+			name.WriteString("." + sanitize(v))
+		}
+		name.WriteString("." + agentID + "." + sanitize(iface))
+	}
+	name.WriteString("." + metric)
+
+	v := strconv.FormatFloat(value, 'f', -1, 64)
+	var b strings.Builder
+	b.WriteString(name.String())
+	if f.config.Protocol == "statsd" {
+		b.WriteString(":" + v + "|g")
+		for i, t := range tags {
+			if i == 0 {
+				b.WriteString("|#")
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(t[0] + ":" + t[1])
+		}
+	} else {
+		for _, t := range tags {
+			b.WriteString(";" + t[0] + "=" + t[1])
+		}
+		b.WriteString(" " + v + " " + strconv.FormatInt(r.data.Timestamp.Unix(), 10))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// sanitize replaces the characters separating names and tags in Graphite
+// and StatsD
+func sanitize(s string) string {
+	return nameReplacer.Replace(s)
+}
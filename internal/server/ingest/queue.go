@@ -0,0 +1,314 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// retryBackoff is the first delay before retrying a failed batch write
+	retryBackoff = time.Second
+	// maxRetryBackoff caps the delay between batch write retries
+	maxRetryBackoff = 30 * time.Second
+)
+
+// Store writes a batch of metrics reports of a tenant to the database
+type Store func(ctx context.Context, tenantID string, batch []*types.MetricsData) error
+
+// Item represents a queued metrics report
+type Item struct {
+	TenantID string             `json:"tenant_id"`
+	Data     *types.MetricsData `json:"data"`
+	Enqueued time.Time          `json:"enqueued"`
+
+	segment *segment
+}
+
+// Queue represents a bounded metrics ingest queue drained by a pool of
+// workers writing batches to the database
+type Queue struct {
+	config *config.IngestConfig
+	logger *zap.Logger
+	store  Store
+	wal    *wal
+	replay []*Item
+
+	// mu orders write-ahead appends with queue sends and guards closed
+	mu     sync.Mutex
+	items  chan *Item
+	closed bool
+
+	stats struct {
+		enqueued atomic.Int64
+		stored   atomic.Int64
+		rejected atomic.Int64
+		retries  atomic.Int64
+		lag      atomic.Int64
+	}
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewQueue creates a new ingest queue, reports left in the write-ahead log by
+// a previous run are queued again once started
+func NewQueue(cfg *config.IngestConfig, store Store, logger *zap.Logger) (*Queue, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &Queue{
+		config: cfg,
+		logger: logger,
+		store:  store,
+		items:  make(chan *Item, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if cfg.WALDir != "" {
+		w, replay, err := openWAL(cfg.WALDir, cfg.WALSyncInterval, logger)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to open ingest wal: %w", err)
+		}
+		q.wal = w
+		q.replay = replay
+	}
+
+	return q, nil
+}
+
+// Start starts the workers and replays reports recovered from disk
+func (q *Queue) Start() {
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work()
+		}()
+	}
+
+	if len(q.replay) > 0 {
+		q.logger.Info("Replaying queued metrics reports", zap.Int("count", len(q.replay)))
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.replayItems()
+		}()
+	}
+}
+
+// Push queues a metrics report of a tenant, it fails with
+// ErrIngestQueueFull instead of blocking when the queue is full
+func (q *Queue) Push(tenantID string, data *types.MetricsData) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return types.ErrIngestClosed
+	}
+	// Only pushers send and they hold mu, so the queue cannot fill up after this check
+	if len(q.items) >= cap(q.items) {
+		q.stats.rejected.Add(1)
+		return types.ErrIngestQueueFull
+	}
+
+	item := &Item{TenantID: tenantID, Data: data, Enqueued: time.Now()}
+	if q.wal != nil {
+		if err := q.wal.append(item); err != nil {
+			return fmt.Errorf("failed to write ingest wal: %w", err)
+		}
+	}
+
+	q.items <- item
+	q.stats.enqueued.Add(1)
+	return nil
+}
+
+// Stats returns the queue depth, throughput counters and lag
+func (q *Queue) Stats() *types.IngestStats {
+	stats := &types.IngestStats{
+		Depth:    len(q.items),
+		Capacity: cap(q.items),
+		Workers:  q.config.Workers,
+		Enqueued: q.stats.enqueued.Load(),
+		Stored:   q.stats.stored.Load(),
+		Rejected: q.stats.rejected.Load(),
+		Retries:  q.stats.retries.Load(),
+		Lag:      float64(time.Duration(q.stats.lag.Load()).Microseconds()) / 1000,
+		WAL:      q.wal != nil,
+	}
+	if q.wal != nil {
+		stats.Segments = q.wal.segments()
+	}
+	return stats
+}
+
+// Stop stops accepting reports and waits for the workers to write the queued
+// ones. When ctx expires first pending writes are abandoned, with a
+// write-ahead log they are written after the next start.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		err = fmt.Errorf("timeout draining ingest queue: %w", ctx.Err())
+	}
+	q.cancel()
+
+	if q.wal != nil {
+		if cerr := q.wal.close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close ingest wal: %w", cerr)
+		}
+	}
+
+	return err
+}
+
+// replayItems queues the reports recovered from disk, waiting for room as
+// the workers drain the queue
+func (q *Queue) replayItems() {
+	for _, item := range q.replay {
+		for !q.requeue(item) {
+			select {
+			case <-q.ctx.Done():
+				return
+			case <-time.After(q.config.FlushInterval):
+			}
+		}
+	}
+	q.replay = nil
+}
+
+// requeue queues a recovered report without writing it to disk again
+func (q *Queue) requeue(item *Item) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		// Left on disk for the next start
+		return true
+	}
+	if len(q.items) >= cap(q.items) {
+		return false
+	}
+
+	q.items <- item
+	q.stats.enqueued.Add(1)
+	return true
+}
+
+// work collects batches of up to BatchSize reports, waiting at most
+// FlushInterval for a batch to fill, and writes them until the queue is
+// closed and drained
+func (q *Queue) work() {
+	for {
+		item, ok := <-q.items
+		if !ok {
+			return
+		}
+
+		batch := []*Item{item}
+		timer := time.NewTimer(q.config.FlushInterval)
+	collect:
+		for len(batch) < q.config.BatchSize {
+			select {
+			case item, ok := <-q.items:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, item)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		q.write(batch)
+	}
+}
+
+// write stores a batch grouped by tenant, retrying failed writes with
+// backoff until they succeed or the queue is stopped
+func (q *Queue) write(batch []*Item) {
+	var tenants []string
+	groups := make(map[string][]*Item)
+	for _, item := range batch {
+		if _, ok := groups[item.TenantID]; !ok {
+			tenants = append(tenants, item.TenantID)
+		}
+		groups[item.TenantID] = append(groups[item.TenantID], item)
+	}
+
+	for _, tenantID := range tenants {
+		items := groups[tenantID]
+		data := make([]*types.MetricsData, len(items))
+		for i, item := range items {
+			data[i] = item.Data
+		}
+
+		if !q.writeWithRetry(tenantID, data) {
+			return
+		}
+
+		q.stats.stored.Add(int64(len(items)))
+		q.stats.lag.Store(int64(time.Since(items[0].Enqueued)))
+		if q.wal != nil {
+			q.wal.commit(items)
+		}
+	}
+}
+
+// writeWithRetry stores data, it returns false when the queue was stopped
+// before the write succeeded
+func (q *Queue) writeWithRetry(tenantID string, data []*types.MetricsData) bool {
+	backoff := retryBackoff
+	for {
+		err := q.store(q.ctx, tenantID, data)
+		if err == nil {
+			return true
+		}
+		if q.ctx.Err() != nil {
+			q.logger.Warn("Abandoned metrics batch on shutdown",
+				zap.String("tenant_id", tenantID),
+				zap.Int("count", len(data)))
+			return false
+		}
+
+		q.stats.retries.Add(1)
+		q.logger.Error("Failed to write metrics batch, retrying",
+			zap.Error(err),
+			zap.String("tenant_id", tenantID),
+			zap.Int("count", len(data)),
+			zap.Duration("backoff", backoff))
+
+		select {
+		case <-q.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
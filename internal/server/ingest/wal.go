@@ -0,0 +1,302 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// segmentItems is the number of reports written to a segment before a new one is started
+	segmentItems = 1000
+	// segmentExt is the file extension of write-ahead log segments
+	segmentExt = ".wal"
+)
+
+// segment represents a write-ahead log file
+type segment struct {
+	id      uint64
+	path    string
+	written int
+	pending int
+	sealed  bool
+}
+
+// wal represents a write-ahead log of queued reports, one JSON line per
+// report. A segment is removed once it is sealed and all its reports are
+// stored, replaying the remaining ones is safe as stored reports are
+// deduplicated.
+//
+// With a zero sync interval each report is synced to disk before it is
+// acknowledged, so acknowledged reports survive a crash of the host.
+// Otherwise reports are synced at most the interval after they are written,
+// and those acknowledged within it may be lost. Segments are synced when
+// sealed, either way.
+type wal struct {
+	dir          string
+	syncInterval time.Duration
+	logger       *zap.Logger
+
+	mu      sync.Mutex
+	current *segment
+	file    *os.File
+	open    map[uint64]*segment
+	// dirty is set while written reports wait for the sync timer
+	dirty bool
+	timer *time.Timer
+}
+
+// openWAL opens the write-ahead log in dir and returns the reports of a
+// previous run that were not stored
+func openWAL(dir string, syncInterval time.Duration, logger *zap.Logger) (*wal, []*Item, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, nil, fmt.Errorf("failed to create wal directory: %w", err)
+	}
+
+	w := &wal{
+		dir:          dir,
+		syncInterval: syncInterval,
+		logger:       logger,
+		open:         make(map[uint64]*segment),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list wal segments: %w", err)
+	}
+
+	// Segment names are zero padded ids, so replay keeps the order reports were queued in
+	var replay []*Item
+	var last uint64
+	for _, path := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+		if err != nil {
+			logger.Warn("Skipping unknown file in wal directory", zap.String("path", path))
+			continue
+		}
+		last = max(last, id)
+
+		items, err := w.read(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		seg := &segment{id: id, path: path, written: len(items), pending: len(items), sealed: true}
+		if seg.pending == 0 {
+			w.remove(seg)
+			continue
+		}
+		for _, item := range items {
+			item.segment = seg
+		}
+		w.open[id] = seg
+		replay = append(replay, items...)
+	}
+
+	if err := w.create(last + 1); err != nil {
+		return nil, nil, err
+	}
+
+	return w, replay, nil
+}
+
+// read reads the reports of a segment, a truncated last line left by a
+// crash is skipped
+func (w *wal) read(path string) ([]*Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wal segment: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var items []*Item
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var item Item
+			if jerr := json.Unmarshal(line, &item); jerr != nil || item.Data == nil {
+				w.logger.Warn("Skipping corrupt wal entry",
+					zap.String("path", path),
+					zap.Int("entry", len(items)))
+			} else {
+				items = append(items, &item)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read wal segment: %w", err)
+		}
+	}
+}
+
+// create starts a new current segment
+func (w *wal) create(id uint64) error {
+	path := filepath.Join(w.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create wal segment: %w", err)
+	}
+
+	// The directory entry of the segment is synced with it
+	if err := syncDir(w.dir); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync wal directory: %w", err)
+	}
+
+	w.file = f
+	w.current = &segment{id: id, path: path}
+	w.open[id] = w.current
+	return nil
+}
+
+// append writes a report to the current segment
+func (w *wal) append(item *Item) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.current.written >= segmentItems {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if w.syncInterval == 0 {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync wal segment: %w", err)
+		}
+	} else if !w.dirty {
+		w.dirty = true
+		w.timer = time.AfterFunc(w.syncInterval, w.flush)
+	}
+
+	item.segment = w.current
+	w.current.written++
+	w.current.pending++
+	return nil
+}
+
+// flush syncs the reports written since the last sync
+func (w *wal) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sync(); err != nil {
+		w.logger.Warn("Failed to sync wal segment",
+			zap.Error(err),
+			zap.String("path", w.current.path))
+	}
+}
+
+// sync syncs the current segment if reports wait for the sync timer
+func (w *wal) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	w.timer.Stop()
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// commit marks stored reports, removing the segments left without pending ones
+func (w *wal) commit(items []*Item) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, item := range items {
+		seg := item.segment
+		if seg == nil {
+			continue
+		}
+		seg.pending--
+		if seg.sealed && seg.pending == 0 {
+			w.remove(seg)
+		}
+	}
+}
+
+// rotate seals the current segment and starts the next one
+func (w *wal) rotate() error {
+	if err := w.sync(); err != nil {
+		return fmt.Errorf("failed to sync wal segment: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close wal segment: %w", err)
+	}
+
+	seg := w.current
+	seg.sealed = true
+	if seg.pending == 0 {
+		w.remove(seg)
+	}
+
+	return w.create(seg.id + 1)
+}
+
+// remove deletes a segment file
+func (w *wal) remove(seg *segment) {
+	delete(w.open, seg.id)
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		w.logger.Warn("Failed to remove wal segment",
+			zap.Error(err),
+			zap.String("path", seg.path))
+	}
+}
+
+// segments returns the number of segments on disk
+func (w *wal) segments() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.open)
+}
+
+// close closes the current segment, it is removed when all its reports are stored
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	w.current.sealed = true
+	if w.current.pending == 0 {
+		w.remove(w.current)
+	}
+	return nil
+}
+
+// syncDir syncs a directory, so files created in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
@@ -0,0 +1,2 @@
+-- Drop ip_context column from ip_changes table
+ALTER TABLE ip_changes DROP COLUMN ip_context;
@@ -0,0 +1,2 @@
+-- Add ip_context column to ip_changes table
+ALTER TABLE ip_changes ADD COLUMN ip_context JSON;
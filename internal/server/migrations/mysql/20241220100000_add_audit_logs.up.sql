@@ -0,0 +1,16 @@
+-- Create audit_logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+  timestamp      DATETIME     NOT NULL,
+  actor          VARCHAR(64)  NOT NULL,
+  action         VARCHAR(64)  NOT NULL,
+  resource       VARCHAR(255),
+  method         VARCHAR(10)  NOT NULL,
+  path           VARCHAR(255) NOT NULL,
+  status         INT          NOT NULL,
+  client_ip      VARCHAR(64),
+  payload_digest VARCHAR(64),
+  INDEX idx_audit_logs_timestamp (timestamp),
+  INDEX idx_audit_logs_actor_time (actor, timestamp),
+  INDEX idx_audit_logs_action_time (action, timestamp)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
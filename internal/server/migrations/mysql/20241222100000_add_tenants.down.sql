@@ -0,0 +1,8 @@
+-- Drop tenant scoping
+ALTER TABLE audit_logs DROP INDEX idx_audit_logs_tenant_time, DROP COLUMN tenant_id;
+ALTER TABLE ip_changes DROP INDEX idx_ip_changes_tenant_time, DROP COLUMN tenant_id;
+ALTER TABLE metrics DROP INDEX idx_metrics_tenant_time, DROP COLUMN tenant_id;
+ALTER TABLE agents DROP INDEX idx_agents_tenant, DROP COLUMN tenant_id;
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,30 @@
+-- Create tenants table
+CREATE TABLE IF NOT EXISTS tenants (
+  id         VARCHAR(64)  PRIMARY KEY,
+  name       VARCHAR(255) NOT NULL,
+  created_at DATETIME     NOT NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+INSERT IGNORE INTO tenants (id, name, created_at) VALUES ('default', 'Default', CURRENT_TIMESTAMP);
+
+-- Create api_keys table
+CREATE TABLE IF NOT EXISTS api_keys (
+  id         VARCHAR(32)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  name       VARCHAR(255) NOT NULL,
+  key_hash   VARCHAR(64)  NOT NULL,
+  created_at DATETIME     NOT NULL,
+  UNIQUE INDEX idx_api_keys_hash (key_hash),
+  INDEX idx_api_keys_tenant (tenant_id),
+  FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Scope existing data to the default tenant
+ALTER TABLE agents ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+  ADD INDEX idx_agents_tenant (tenant_id);
+ALTER TABLE metrics ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+  ADD INDEX idx_metrics_tenant_time (tenant_id, timestamp);
+ALTER TABLE ip_changes ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+  ADD INDEX idx_ip_changes_tenant_time (tenant_id, timestamp);
+ALTER TABLE audit_logs ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+  ADD INDEX idx_audit_logs_tenant_time (tenant_id, timestamp);
@@ -0,0 +1,4 @@
+-- Drop users
+ALTER TABLE api_keys DROP INDEX idx_api_keys_user, DROP COLUMN user_id;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,16 @@
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+  id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  name       VARCHAR(255) NOT NULL,
+  role       VARCHAR(16)  NOT NULL,
+  agents     TEXT         NOT NULL,
+  created_at DATETIME     NOT NULL,
+  updated_at DATETIME     NOT NULL,
+  INDEX idx_users_tenant (tenant_id),
+  FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Bind API keys to users, keys without a user keep full tenant access
+ALTER TABLE api_keys ADD COLUMN user_id VARCHAR(64) NULL,
+  ADD INDEX idx_api_keys_user (user_id);
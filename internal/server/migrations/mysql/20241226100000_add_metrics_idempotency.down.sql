@@ -0,0 +1,2 @@
+-- Drop idempotency_key from metrics
+ALTER TABLE metrics DROP INDEX idx_metrics_idempotency, DROP COLUMN idempotency_key;
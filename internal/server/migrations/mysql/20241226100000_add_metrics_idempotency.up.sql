@@ -0,0 +1,3 @@
+-- Add idempotency_key to metrics, resubmitted reports share the key
+ALTER TABLE metrics ADD COLUMN idempotency_key VARCHAR(64) NULL,
+  ADD UNIQUE INDEX idx_metrics_idempotency (idempotency_key);
@@ -0,0 +1,2 @@
+-- Drop leader leases
+DROP TABLE IF EXISTS leader_leases;
@@ -0,0 +1,15 @@
+-- Create agent_decommissions table, rows are kept after the agent is purged
+CREATE TABLE IF NOT EXISTS agent_decommissions (
+  agent_id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id        VARCHAR(64)  NOT NULL,
+  hostname         VARCHAR(255) NOT NULL,
+  reason           TEXT         NOT NULL,
+  archive          VARCHAR(16)  NOT NULL DEFAULT '',
+  archive_location TEXT         NOT NULL,
+  archive_error    TEXT         NOT NULL,
+  retired_at       DATETIME     NOT NULL,
+  purge_after      DATETIME     NOT NULL,
+  archived_at      DATETIME     NULL,
+  purged_at        DATETIME     NULL,
+  INDEX idx_agent_decommissions_purge (purged_at, purge_after)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
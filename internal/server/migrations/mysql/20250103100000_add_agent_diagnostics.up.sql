@@ -0,0 +1,11 @@
+-- Create agent_diagnostics table holding support bundles uploaded by agents
+CREATE TABLE IF NOT EXISTS agent_diagnostics (
+  id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  agent_id   VARCHAR(64)  NOT NULL,
+  command_id VARCHAR(255) NOT NULL DEFAULT '',
+  size       BIGINT       NOT NULL,
+  content    LONGBLOB     NOT NULL,
+  created_at DATETIME     NOT NULL,
+  INDEX idx_agent_diagnostics_agent (agent_id, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
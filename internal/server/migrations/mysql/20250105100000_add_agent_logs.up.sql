@@ -0,0 +1,14 @@
+-- Create agent_logs table holding log entries shipped by agents
+CREATE TABLE IF NOT EXISTS agent_logs (
+  id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  agent_id   VARCHAR(64)  NOT NULL,
+  timestamp  DATETIME     NOT NULL,
+  level      VARCHAR(16)  NOT NULL,
+  caller     VARCHAR(255),
+  message    TEXT         NOT NULL,
+  fields     JSON,
+  created_at DATETIME     NOT NULL,
+  INDEX idx_agent_logs_agent_time (agent_id, timestamp),
+  INDEX idx_agent_logs_created_at (created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,2 @@
+-- Drop idx_metrics_time_id from metrics
+ALTER TABLE metrics DROP INDEX idx_metrics_time_id;
@@ -0,0 +1,2 @@
+-- Add idx_metrics_time_id to metrics, streamed queries page on (timestamp, id)
+ALTER TABLE metrics ADD INDEX idx_metrics_time_id (timestamp, id);
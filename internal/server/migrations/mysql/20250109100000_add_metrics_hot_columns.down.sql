@@ -0,0 +1,9 @@
+-- Drop hot field columns from metrics
+ALTER TABLE metrics
+  DROP INDEX idx_metrics_agent_network,
+  DROP COLUMN ip_change_count,
+  DROP COLUMN error_count,
+  DROP COLUMN total_tx_bytes,
+  DROP COLUMN total_rx_bytes,
+  DROP COLUMN interface_count,
+  DROP COLUMN has_network;
@@ -0,0 +1,32 @@
+-- Add columns holding hot fields of the metrics data, written on insert
+ALTER TABLE metrics
+  ADD COLUMN has_network BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN interface_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN total_rx_bytes BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN total_tx_bytes BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN error_count BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN ip_change_count INT NOT NULL DEFAULT 0,
+  ADD INDEX idx_metrics_agent_network (agent_id, has_network);
+
+-- Fill the columns of stored metrics
+UPDATE metrics m
+LEFT JOIN (
+  SELECT d.id,
+         SUM(s.rx_bytes) AS rx_bytes,
+         SUM(s.tx_bytes) AS tx_bytes,
+         SUM(COALESCE(s.rx_errors, 0) + COALESCE(s.tx_errors, 0)) AS errors
+  FROM metrics d,
+       JSON_TABLE(JSON_EXTRACT(d.data, '$.metrics.network.interfaces.*.statistics'), '$[*]' COLUMNS (
+         rx_bytes  BIGINT PATH '$.rx_bytes',
+         tx_bytes  BIGINT PATH '$.tx_bytes',
+         rx_errors BIGINT PATH '$.rx_errors',
+         tx_errors BIGINT PATH '$.tx_errors'
+       )) AS s
+  GROUP BY d.id
+) t ON t.id = m.id
+SET m.has_network     = COALESCE(JSON_TYPE(JSON_EXTRACT(m.data, '$.metrics.network')) = 'OBJECT', FALSE),
+    m.interface_count = COALESCE(JSON_LENGTH(m.data, '$.metrics.network.interfaces'), 0),
+    m.total_rx_bytes  = COALESCE(t.rx_bytes, 0),
+    m.total_tx_bytes  = COALESCE(t.tx_bytes, 0),
+    m.error_count     = COALESCE(t.errors, 0),
+    m.ip_change_count = COALESCE(JSON_LENGTH(m.data, '$.metrics.network.ip_changes'), 0);
@@ -0,0 +1,2 @@
+-- Drop agent maintenance
+ALTER TABLE agents DROP COLUMN maintenance;
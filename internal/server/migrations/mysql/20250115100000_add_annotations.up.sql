@@ -0,0 +1,16 @@
+-- Create annotations table holding events overlaid on dashboards
+CREATE TABLE IF NOT EXISTS annotations (
+  id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL DEFAULT 'default',
+  type       VARCHAR(32)  NOT NULL,
+  agent_id   VARCHAR(64),
+  timestamp  DATETIME     NOT NULL,
+  end_time   DATETIME,
+  title      VARCHAR(255) NOT NULL,
+  text       TEXT,
+  tags       TEXT         NOT NULL,
+  created_by VARCHAR(255),
+  created_at DATETIME     NOT NULL,
+  INDEX idx_annotations_tenant_time (tenant_id, timestamp),
+  INDEX idx_annotations_agent_time (agent_id, timestamp)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,2 @@
+-- Drop erasures table
+DROP TABLE IF EXISTS erasures;
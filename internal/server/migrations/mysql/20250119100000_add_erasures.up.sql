@@ -0,0 +1,16 @@
+-- Create erasures table, rows are kept as tombstones of the erased agents
+CREATE TABLE IF NOT EXISTS erasures (
+  id           VARCHAR(36)  PRIMARY KEY,
+  tenant_id    VARCHAR(64)  NOT NULL,
+  agent_id     VARCHAR(64)  NOT NULL DEFAULT '',
+  hostname     VARCHAR(255) NOT NULL DEFAULT '',
+  agent_ids    TEXT         NOT NULL,
+  reason       TEXT         NOT NULL,
+  requested_by VARCHAR(255) NOT NULL DEFAULT '',
+  status       VARCHAR(16)  NOT NULL,
+  error        TEXT         NOT NULL,
+  report       TEXT         NULL,
+  requested_at DATETIME     NOT NULL,
+  completed_at DATETIME     NULL,
+  INDEX idx_erasures_tenant_time (tenant_id, requested_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+-- Drop the expected window of IP changes
+ALTER TABLE ip_changes DROP COLUMN expected_window;
+
+-- Drop ip_change_windows table
+DROP TABLE IF EXISTS ip_change_windows;
@@ -0,0 +1,17 @@
+-- Create audit_logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id             BIGSERIAL PRIMARY KEY,
+  timestamp      TIMESTAMP    NOT NULL,
+  actor          VARCHAR(64)  NOT NULL,
+  action         VARCHAR(64)  NOT NULL,
+  resource       VARCHAR(255),
+  method         VARCHAR(10)  NOT NULL,
+  path           VARCHAR(255) NOT NULL,
+  status         INTEGER      NOT NULL,
+  client_ip      VARCHAR(64),
+  payload_digest VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp ON audit_logs (timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_time ON audit_logs (actor, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action_time ON audit_logs (action, timestamp);
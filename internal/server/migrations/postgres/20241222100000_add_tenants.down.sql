@@ -0,0 +1,8 @@
+-- Drop tenant scoping
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE ip_changes DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE metrics DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE agents DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,31 @@
+-- Create tenants table
+CREATE TABLE IF NOT EXISTS tenants (
+  id         VARCHAR(64)  PRIMARY KEY,
+  name       VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP    NOT NULL
+);
+
+INSERT INTO tenants (id, name, created_at) VALUES ('default', 'Default', CURRENT_TIMESTAMP)
+ON CONFLICT (id) DO NOTHING;
+
+-- Create api_keys table
+CREATE TABLE IF NOT EXISTS api_keys (
+  id         VARCHAR(32)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL REFERENCES tenants (id),
+  name       VARCHAR(255) NOT NULL,
+  key_hash   VARCHAR(64)  NOT NULL UNIQUE,
+  created_at TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant ON api_keys (tenant_id);
+
+-- Scope existing data to the default tenant
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE ip_changes ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_agents_tenant ON agents (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_metrics_tenant_time ON metrics (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_ip_changes_tenant_time ON ip_changes (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_time ON audit_logs (tenant_id, timestamp);
@@ -0,0 +1,4 @@
+-- Drop users
+ALTER TABLE api_keys DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,17 @@
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+  id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL REFERENCES tenants (id),
+  name       VARCHAR(255) NOT NULL,
+  role       VARCHAR(16)  NOT NULL,
+  agents     TEXT         NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP    NOT NULL,
+  updated_at TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users (tenant_id);
+
+-- Bind API keys to users, keys without a user keep full tenant access
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS user_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
@@ -0,0 +1,4 @@
+-- Add idempotency_key to metrics, resubmitted reports share the key
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metrics_idempotency ON metrics (idempotency_key);
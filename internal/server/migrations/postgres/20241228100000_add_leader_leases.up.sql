@@ -0,0 +1,6 @@
+-- Create leader leases, a replica runs scheduled jobs while it holds the lease
+CREATE TABLE IF NOT EXISTS leader_leases (
+  name       VARCHAR(64)  PRIMARY KEY,
+  holder     VARCHAR(255) NOT NULL,
+  expires_at BIGINT       NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Drop agent decommissions
+DROP TABLE IF EXISTS agent_decommissions;
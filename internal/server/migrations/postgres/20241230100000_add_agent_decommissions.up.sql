@@ -0,0 +1,16 @@
+-- Create agent_decommissions table, rows are kept after the agent is purged
+CREATE TABLE IF NOT EXISTS agent_decommissions (
+  agent_id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id        VARCHAR(64)  NOT NULL,
+  hostname         VARCHAR(255) NOT NULL,
+  reason           TEXT         NOT NULL DEFAULT '',
+  archive          VARCHAR(16)  NOT NULL DEFAULT '',
+  archive_location TEXT         NOT NULL DEFAULT '',
+  archive_error    TEXT         NOT NULL DEFAULT '',
+  retired_at       TIMESTAMP    NOT NULL,
+  purge_after      TIMESTAMP    NOT NULL,
+  archived_at      TIMESTAMP,
+  purged_at        TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_decommissions_purge ON agent_decommissions (purged_at, purge_after);
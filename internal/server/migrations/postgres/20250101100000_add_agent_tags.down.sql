@@ -0,0 +1,2 @@
+-- Drop agent tags
+ALTER TABLE agents DROP COLUMN IF EXISTS tags;
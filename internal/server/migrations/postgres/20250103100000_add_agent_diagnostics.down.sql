@@ -0,0 +1,2 @@
+-- Drop agent diagnostics
+DROP TABLE IF EXISTS agent_diagnostics;
@@ -0,0 +1,12 @@
+-- Create agent_diagnostics table holding support bundles uploaded by agents
+CREATE TABLE IF NOT EXISTS agent_diagnostics (
+  id         VARCHAR(64)  PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  agent_id   VARCHAR(64)  NOT NULL,
+  command_id VARCHAR(255) NOT NULL DEFAULT '',
+  size       BIGINT       NOT NULL,
+  content    BYTEA        NOT NULL,
+  created_at TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_diagnostics_agent ON agent_diagnostics (agent_id, created_at);
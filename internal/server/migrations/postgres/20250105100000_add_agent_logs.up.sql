@@ -0,0 +1,15 @@
+-- Create agent_logs table holding log entries shipped by agents
+CREATE TABLE IF NOT EXISTS agent_logs (
+  id         BIGSERIAL PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL,
+  agent_id   VARCHAR(64)  NOT NULL,
+  timestamp  TIMESTAMP    NOT NULL,
+  level      VARCHAR(16)  NOT NULL,
+  caller     VARCHAR(255),
+  message    TEXT         NOT NULL,
+  fields     JSONB,
+  created_at TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_logs_agent_time ON agent_logs (agent_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_agent_logs_created_at ON agent_logs (created_at);
@@ -0,0 +1,2 @@
+-- Drop idx_metrics_time_id from metrics
+DROP INDEX IF EXISTS idx_metrics_time_id;
@@ -0,0 +1,10 @@
+-- Drop hot field columns from metrics
+DROP INDEX IF EXISTS idx_metrics_agent_network;
+
+ALTER TABLE metrics
+  DROP COLUMN IF EXISTS ip_change_count,
+  DROP COLUMN IF EXISTS error_count,
+  DROP COLUMN IF EXISTS total_tx_bytes,
+  DROP COLUMN IF EXISTS total_rx_bytes,
+  DROP COLUMN IF EXISTS interface_count,
+  DROP COLUMN IF EXISTS has_network;
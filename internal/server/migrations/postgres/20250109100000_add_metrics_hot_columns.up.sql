@@ -0,0 +1,31 @@
+-- Add columns holding hot fields of the metrics data, written on insert
+ALTER TABLE metrics
+  ADD COLUMN IF NOT EXISTS has_network BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS interface_count INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS total_rx_bytes BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS total_tx_bytes BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS error_count BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS ip_change_count INTEGER NOT NULL DEFAULT 0;
+
+-- Fill the columns of stored metrics
+UPDATE metrics m SET
+  has_network     = COALESCE(jsonb_typeof(m.data->'metrics'->'network') = 'object', FALSE),
+  interface_count = s.interface_count,
+  total_rx_bytes  = s.rx_bytes,
+  total_tx_bytes  = s.tx_bytes,
+  error_count     = s.errors,
+  ip_change_count = CASE WHEN jsonb_typeof(m.data->'metrics'->'network'->'ip_changes') = 'array'
+                         THEN jsonb_array_length(m.data->'metrics'->'network'->'ip_changes') ELSE 0 END
+FROM metrics d
+CROSS JOIN LATERAL (
+  SELECT COUNT(*) AS interface_count,
+         COALESCE(SUM(CAST(i.value->'statistics'->>'rx_bytes' AS BIGINT)), 0) AS rx_bytes,
+         COALESCE(SUM(CAST(i.value->'statistics'->>'tx_bytes' AS BIGINT)), 0) AS tx_bytes,
+         COALESCE(SUM(COALESCE(CAST(i.value->'statistics'->>'rx_errors' AS BIGINT), 0) +
+                      COALESCE(CAST(i.value->'statistics'->>'tx_errors' AS BIGINT), 0)), 0) AS errors
+  FROM jsonb_each(CASE WHEN jsonb_typeof(d.data->'metrics'->'network'->'interfaces') = 'object'
+                       THEN d.data->'metrics'->'network'->'interfaces' END) AS i(key, value)
+) s
+WHERE d.id = m.id;
+
+CREATE INDEX IF NOT EXISTS idx_metrics_agent_network ON metrics (agent_id, has_network);
@@ -0,0 +1,2 @@
+-- Drop agent_inventory table
+DROP TABLE IF EXISTS agent_inventory;
@@ -0,0 +1,6 @@
+-- Create agent_inventory table holding the expected agents declared through the API
+CREATE TABLE IF NOT EXISTS agent_inventory (
+  name       VARCHAR(64) PRIMARY KEY,
+  data       TEXT        NOT NULL,
+  updated_at TIMESTAMP   NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Drop annotations table
+DROP TABLE IF EXISTS annotations;
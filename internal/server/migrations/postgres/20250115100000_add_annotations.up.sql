@@ -0,0 +1,17 @@
+-- Create annotations table holding events overlaid on dashboards
+CREATE TABLE IF NOT EXISTS annotations (
+  id         BIGSERIAL PRIMARY KEY,
+  tenant_id  VARCHAR(64)  NOT NULL DEFAULT 'default',
+  type       VARCHAR(32)  NOT NULL,
+  agent_id   VARCHAR(64),
+  timestamp  TIMESTAMP    NOT NULL,
+  end_time   TIMESTAMP,
+  title      VARCHAR(255) NOT NULL,
+  text       TEXT,
+  tags       TEXT         NOT NULL,
+  created_by VARCHAR(255),
+  created_at TIMESTAMP    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_tenant_time ON annotations (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_annotations_agent_time ON annotations (agent_id, timestamp);
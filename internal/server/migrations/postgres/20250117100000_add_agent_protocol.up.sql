@@ -0,0 +1,2 @@
+-- Add the protocol negotiated with agents on registration, stored as a JSON object
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS protocol TEXT NULL;
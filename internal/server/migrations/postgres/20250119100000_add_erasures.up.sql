@@ -0,0 +1,17 @@
+-- Create erasures table, rows are kept as tombstones of the erased agents
+CREATE TABLE IF NOT EXISTS erasures (
+  id           VARCHAR(36)  PRIMARY KEY,
+  tenant_id    VARCHAR(64)  NOT NULL,
+  agent_id     VARCHAR(64)  NOT NULL DEFAULT '',
+  hostname     VARCHAR(255) NOT NULL DEFAULT '',
+  agent_ids    TEXT         NOT NULL,
+  reason       TEXT         NOT NULL DEFAULT '',
+  requested_by VARCHAR(255) NOT NULL DEFAULT '',
+  status       VARCHAR(16)  NOT NULL,
+  error        TEXT         NOT NULL DEFAULT '',
+  report       TEXT,
+  requested_at TIMESTAMP    NOT NULL,
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_erasures_tenant_time ON erasures (tenant_id, requested_at);
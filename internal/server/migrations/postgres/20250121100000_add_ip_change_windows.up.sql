@@ -0,0 +1,9 @@
+-- Create ip_change_windows table holding the expected IP change windows declared through the API
+CREATE TABLE IF NOT EXISTS ip_change_windows (
+  name       VARCHAR(128) PRIMARY KEY,
+  data       TEXT         NOT NULL,
+  updated_at TIMESTAMP    NOT NULL
+);
+
+-- Add the expected window IP changes happened in
+ALTER TABLE ip_changes ADD COLUMN IF NOT EXISTS expected_window VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Drop alert_rules table
+DROP TABLE IF EXISTS alert_rules;
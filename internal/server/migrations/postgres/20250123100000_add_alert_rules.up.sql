@@ -0,0 +1,6 @@
+-- Create alert_rules table holding the alert rules declared through the API
+CREATE TABLE IF NOT EXISTS alert_rules (
+  name       VARCHAR(128) PRIMARY KEY,
+  data       TEXT         NOT NULL,
+  updated_at TIMESTAMP    NOT NULL
+);
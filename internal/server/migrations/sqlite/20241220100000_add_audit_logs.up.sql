@@ -0,0 +1,17 @@
+-- Create audit_logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id             INTEGER PRIMARY KEY AUTOINCREMENT,
+  timestamp      DATETIME NOT NULL,
+  actor          TEXT     NOT NULL,
+  action         TEXT     NOT NULL,
+  resource       TEXT,
+  method         TEXT     NOT NULL,
+  path           TEXT     NOT NULL,
+  status         INTEGER  NOT NULL,
+  client_ip      TEXT,
+  payload_digest TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp ON audit_logs (timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_time ON audit_logs (actor, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action_time ON audit_logs (action, timestamp);
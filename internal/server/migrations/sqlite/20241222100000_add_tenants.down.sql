@@ -0,0 +1,13 @@
+-- Drop tenant scoping
+DROP INDEX IF EXISTS idx_audit_logs_tenant_time;
+DROP INDEX IF EXISTS idx_ip_changes_tenant_time;
+DROP INDEX IF EXISTS idx_metrics_tenant_time;
+DROP INDEX IF EXISTS idx_agents_tenant;
+
+ALTER TABLE audit_logs DROP COLUMN tenant_id;
+ALTER TABLE ip_changes DROP COLUMN tenant_id;
+ALTER TABLE metrics DROP COLUMN tenant_id;
+ALTER TABLE agents DROP COLUMN tenant_id;
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS tenants;
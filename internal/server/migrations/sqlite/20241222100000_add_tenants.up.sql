@@ -0,0 +1,31 @@
+-- Create tenants table
+CREATE TABLE IF NOT EXISTS tenants (
+  id         TEXT     PRIMARY KEY,
+  name       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL
+);
+
+INSERT INTO tenants (id, name, created_at) VALUES ('default', 'Default', CURRENT_TIMESTAMP);
+
+-- Create api_keys table
+CREATE TABLE IF NOT EXISTS api_keys (
+  id         TEXT     PRIMARY KEY,
+  tenant_id  TEXT     NOT NULL,
+  name       TEXT     NOT NULL,
+  key_hash   TEXT     NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL,
+  FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant ON api_keys (tenant_id);
+
+-- Scope existing data to the default tenant
+ALTER TABLE agents ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE metrics ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE ip_changes ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE audit_logs ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_agents_tenant ON agents (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_metrics_tenant_time ON metrics (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_ip_changes_tenant_time ON ip_changes (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_time ON audit_logs (tenant_id, timestamp);
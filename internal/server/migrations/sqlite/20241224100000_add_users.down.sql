@@ -0,0 +1,6 @@
+-- Drop users
+DROP INDEX IF EXISTS idx_api_keys_user;
+
+ALTER TABLE api_keys DROP COLUMN user_id;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,18 @@
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+  id         TEXT     PRIMARY KEY,
+  tenant_id  TEXT     NOT NULL,
+  name       TEXT     NOT NULL,
+  role       TEXT     NOT NULL,
+  agents     TEXT     NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users (tenant_id);
+
+-- Bind API keys to users, keys without a user keep full tenant access
+ALTER TABLE api_keys ADD COLUMN user_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
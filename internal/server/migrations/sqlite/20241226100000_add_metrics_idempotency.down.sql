@@ -0,0 +1,4 @@
+-- Drop idempotency_key from metrics
+DROP INDEX IF EXISTS idx_metrics_idempotency;
+
+ALTER TABLE metrics DROP COLUMN idempotency_key;
@@ -0,0 +1,6 @@
+-- Create leader leases, a replica runs scheduled jobs while it holds the lease
+CREATE TABLE IF NOT EXISTS leader_leases (
+  name       TEXT    PRIMARY KEY,
+  holder     TEXT    NOT NULL,
+  expires_at INTEGER NOT NULL
+);
@@ -0,0 +1,16 @@
+-- Create agent_decommissions table, rows are kept after the agent is purged
+CREATE TABLE IF NOT EXISTS agent_decommissions (
+  agent_id         TEXT     PRIMARY KEY,
+  tenant_id        TEXT     NOT NULL,
+  hostname         TEXT     NOT NULL,
+  reason           TEXT     NOT NULL DEFAULT '',
+  archive          TEXT     NOT NULL DEFAULT '',
+  archive_location TEXT     NOT NULL DEFAULT '',
+  archive_error    TEXT     NOT NULL DEFAULT '',
+  retired_at       DATETIME NOT NULL,
+  purge_after      DATETIME NOT NULL,
+  archived_at      DATETIME,
+  purged_at        DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_decommissions_purge ON agent_decommissions (purged_at, purge_after);
@@ -0,0 +1,2 @@
+-- Add agent tags, stored as a JSON object
+ALTER TABLE agents ADD COLUMN tags TEXT NOT NULL DEFAULT '{}';
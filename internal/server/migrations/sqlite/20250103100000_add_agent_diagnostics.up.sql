@@ -0,0 +1,12 @@
+-- Create agent_diagnostics table holding support bundles uploaded by agents
+CREATE TABLE IF NOT EXISTS agent_diagnostics (
+  id         TEXT     PRIMARY KEY,
+  tenant_id  TEXT     NOT NULL,
+  agent_id   TEXT     NOT NULL,
+  command_id TEXT     NOT NULL DEFAULT '',
+  size       INTEGER  NOT NULL,
+  content    BLOB     NOT NULL,
+  created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_diagnostics_agent ON agent_diagnostics (agent_id, created_at);
@@ -0,0 +1,2 @@
+-- Drop agent logs
+DROP TABLE IF EXISTS agent_logs;
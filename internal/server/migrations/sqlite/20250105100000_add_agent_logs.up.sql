@@ -0,0 +1,15 @@
+-- Create agent_logs table holding log entries shipped by agents
+CREATE TABLE IF NOT EXISTS agent_logs (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant_id  TEXT     NOT NULL,
+  agent_id   TEXT     NOT NULL,
+  timestamp  DATETIME NOT NULL,
+  level      TEXT     NOT NULL,
+  caller     TEXT,
+  message    TEXT     NOT NULL,
+  fields     JSON,
+  created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_logs_agent_time ON agent_logs (agent_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_agent_logs_created_at ON agent_logs (created_at);
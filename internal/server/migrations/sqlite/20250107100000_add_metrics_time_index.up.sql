@@ -0,0 +1,2 @@
+-- Add idx_metrics_time_id to metrics, streamed queries page on (timestamp, id)
+CREATE INDEX IF NOT EXISTS idx_metrics_time_id ON metrics (timestamp, id);
@@ -0,0 +1,9 @@
+-- Drop hot field columns from metrics
+DROP INDEX IF EXISTS idx_metrics_agent_network;
+
+ALTER TABLE metrics DROP COLUMN ip_change_count;
+ALTER TABLE metrics DROP COLUMN error_count;
+ALTER TABLE metrics DROP COLUMN total_tx_bytes;
+ALTER TABLE metrics DROP COLUMN total_rx_bytes;
+ALTER TABLE metrics DROP COLUMN interface_count;
+ALTER TABLE metrics DROP COLUMN has_network;
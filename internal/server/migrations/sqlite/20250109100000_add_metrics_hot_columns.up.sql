@@ -0,0 +1,22 @@
+-- Add columns holding hot fields of the metrics data, written on insert
+ALTER TABLE metrics ADD COLUMN has_network BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE metrics ADD COLUMN interface_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE metrics ADD COLUMN total_rx_bytes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE metrics ADD COLUMN total_tx_bytes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE metrics ADD COLUMN error_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE metrics ADD COLUMN ip_change_count INTEGER NOT NULL DEFAULT 0;
+
+-- Fill the columns of stored metrics
+UPDATE metrics SET
+  has_network     = COALESCE(json_type(data, '$.metrics.network') = 'object', FALSE),
+  interface_count = (SELECT COUNT(*) FROM json_each(data, '$.metrics.network.interfaces')),
+  total_rx_bytes  = COALESCE((SELECT SUM(json_extract(value, '$.statistics.rx_bytes'))
+                              FROM json_each(data, '$.metrics.network.interfaces')), 0),
+  total_tx_bytes  = COALESCE((SELECT SUM(json_extract(value, '$.statistics.tx_bytes'))
+                              FROM json_each(data, '$.metrics.network.interfaces')), 0),
+  error_count     = COALESCE((SELECT SUM(COALESCE(json_extract(value, '$.statistics.rx_errors'), 0) +
+                                         COALESCE(json_extract(value, '$.statistics.tx_errors'), 0))
+                              FROM json_each(data, '$.metrics.network.interfaces')), 0),
+  ip_change_count = COALESCE(json_array_length(data, '$.metrics.network.ip_changes'), 0);
+
+CREATE INDEX IF NOT EXISTS idx_metrics_agent_network ON metrics (agent_id, has_network);
@@ -0,0 +1,2 @@
+-- Add agent maintenance, stored as a JSON object while the agent is in maintenance
+ALTER TABLE agents ADD COLUMN maintenance TEXT NULL;
@@ -0,0 +1,17 @@
+-- Create annotations table holding events overlaid on dashboards
+CREATE TABLE IF NOT EXISTS annotations (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant_id  TEXT     NOT NULL DEFAULT 'default',
+  type       TEXT     NOT NULL,
+  agent_id   TEXT,
+  timestamp  DATETIME NOT NULL,
+  end_time   DATETIME,
+  title      TEXT     NOT NULL,
+  text       TEXT,
+  tags       TEXT     NOT NULL,
+  created_by TEXT,
+  created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_tenant_time ON annotations (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_annotations_agent_time ON annotations (agent_id, timestamp);
@@ -0,0 +1,2 @@
+-- Drop the agent protocol
+ALTER TABLE agents DROP COLUMN protocol;
@@ -0,0 +1,2 @@
+-- Add the protocol negotiated with agents on registration, stored as a JSON object
+ALTER TABLE agents ADD COLUMN protocol TEXT NULL;
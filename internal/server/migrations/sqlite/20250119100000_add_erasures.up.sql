@@ -0,0 +1,17 @@
+-- Create erasures table, rows are kept as tombstones of the erased agents
+CREATE TABLE IF NOT EXISTS erasures (
+  id           TEXT     PRIMARY KEY,
+  tenant_id    TEXT     NOT NULL,
+  agent_id     TEXT     NOT NULL DEFAULT '',
+  hostname     TEXT     NOT NULL DEFAULT '',
+  agent_ids    TEXT     NOT NULL,
+  reason       TEXT     NOT NULL DEFAULT '',
+  requested_by TEXT     NOT NULL DEFAULT '',
+  status       TEXT     NOT NULL,
+  error        TEXT     NOT NULL DEFAULT '',
+  report       TEXT,
+  requested_at DATETIME NOT NULL,
+  completed_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_erasures_tenant_time ON erasures (tenant_id, requested_at);
@@ -0,0 +1,9 @@
+-- Create ip_change_windows table holding the expected IP change windows declared through the API
+CREATE TABLE IF NOT EXISTS ip_change_windows (
+  name       TEXT     PRIMARY KEY,
+  data       TEXT     NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+-- Add the expected window IP changes happened in
+ALTER TABLE ip_changes ADD COLUMN expected_window TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Create alert_rules table holding the alert rules declared through the API
+CREATE TABLE IF NOT EXISTS alert_rules (
+  name       TEXT     PRIMARY KEY,
+  data       TEXT     NOT NULL,
+  updated_at DATETIME NOT NULL
+);
@@ -2,6 +2,10 @@ package notify
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
+	"wameter/internal/clock"
 	"wameter/internal/config"
 	"wameter/internal/notify"
 	"wameter/internal/types"
@@ -9,70 +13,334 @@ import (
 	"go.uber.org/zap"
 )
 
-// Manager wraps the server notification manager for agent use
+// Manager wraps the notification manager for server use, the wrapped manager
+// is nil while notifications are disabled and is replaced on Reload
 type Manager struct {
 	notifier *notify.Manager
+	mu       sync.RWMutex
 	logger   *zap.Logger
+	// Delivery counts of notifiers replaced by reloads, guarded by mu
+	retired map[string]types.NotificationStats
+	// Agent tags lookup of routing rules and actions of Telegram buttons,
+	// kept across reloads
+	agentTags func(agentID string) map[string]string
+	tgActions notify.TelegramActions
+	// Clock of rate limits, kept across reloads
+	clock clock.Clock
 }
 
-// NewManager creates a new notification manager for agent
+// NewManager creates a new notification manager for server
 func NewManager(cfg *config.NotifyConfig, logger *zap.Logger) (*Manager, error) {
+	m := &Manager{
+		logger:  logger,
+		retired: make(map[string]types.NotificationStats),
+		clock:   clock.Real,
+	}
+
 	// Check if notifications are enabled
-	if !cfg.Enabled {
-		return nil, nil
+	if cfg == nil || !cfg.Enabled {
+		return m, nil
 	}
 
 	notifier, err := notify.NewManager(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
+	m.notifier = notifier
+
+	return m, nil
+}
+
+// Reload replaces the notification channels with ones built from cfg, pending
+// notifications of the previous channels are flushed before they stop
+func (m *Manager) Reload(cfg *config.NotifyConfig) error {
+	var notifier *notify.Manager
+	if cfg != nil && cfg.Enabled {
+		var err error
+		if notifier, err = notify.NewManager(cfg, m.logger); err != nil {
+			return fmt.Errorf("failed to initialize notifier: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	old := m.notifier
+	m.notifier = notifier
+	if notifier != nil {
+		notifier.SetAgentTags(m.agentTags)
+		notifier.SetTelegramActions(m.tgActions)
+		notifier.SetClock(m.clock)
+	}
+	m.mu.Unlock()
+
+	if old != nil {
+		err := old.Stop()
+
+		// Counted after the stop so flushed notifications are included
+		m.mu.Lock()
+		m.retire(old)
+		m.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("failed to stop previous notifier: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the delivery counts by notifier type since the server started,
+// including notifiers replaced by reloads
+func (m *Manager) Stats() map[string]*types.NotificationStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]*types.NotificationStats)
+	for t, s := range m.retired {
+		total := &types.NotificationStats{}
+		addStats(total, &s)
+		stats[t] = total
+	}
+	if m.notifier != nil {
+		for t, s := range m.notifier.Stats() {
+			total, ok := stats[string(t)]
+			if !ok {
+				total = &types.NotificationStats{}
+				stats[string(t)] = total
+			}
+			addStats(total, &s)
+		}
+	}
+	return stats
+}
+
+// retire adds the delivery counts of a replaced notifier to the totals, mu
+// must be held
+func (m *Manager) retire(notifier *notify.Manager) {
+	for t, s := range notifier.Stats() {
+		total := m.retired[string(t)]
+		addStats(&total, &s)
+		m.retired[string(t)] = total
+	}
+}
+
+// addStats adds delivery counts to a total
+func addStats(total, s *types.NotificationStats) {
+	total.Sent += s.Sent
+	total.Failed += s.Failed
+	total.RateLimited += s.RateLimited
+	total.Delayed += s.Delayed
+	total.Redirected += s.Redirected
+	for event, n := range s.Suppressed {
+		if total.Suppressed == nil {
+			total.Suppressed = make(map[string]int64)
+		}
+		total.Suppressed[event] += n
+	}
+}
+
+// SetAgentTags sets the lookup of agent tags used by routing rules
+func (m *Manager) SetAgentTags(fn func(agentID string) map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.agentTags = fn
+	if m.notifier != nil {
+		m.notifier.SetAgentTags(fn)
+	}
+}
+
+// SetTelegramActions sets the actions of Telegram alert buttons
+func (m *Manager) SetTelegramActions(actions notify.TelegramActions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tgActions = actions
+	if m.notifier != nil {
+		m.notifier.SetTelegramActions(actions)
+	}
+}
 
-	return &Manager{
-		notifier: notifier,
-		logger:   logger,
-	}, nil
+// SetClock sets the clock of notification rate limits
+func (m *Manager) SetClock(c clock.Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = c
+	if m.notifier != nil {
+		m.notifier.SetClock(c)
+	}
+}
+
+// HandleTelegramUpdate handles a Telegram update received by webhook
+func (m *Manager) HandleTelegramUpdate(ctx context.Context, secret string, body []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier == nil {
+		return types.ErrTelegramUpdates
+	}
+	return m.notifier.HandleTelegramUpdate(ctx, secret, body)
+}
+
+// Enabled returns whether notifications are enabled
+func (m *Manager) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.notifier != nil
 }
 
 // Stop stops the notification manager
 func (m *Manager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.notifier != nil {
-		return m.notifier.Stop()
+		err := m.notifier.Stop()
+		m.retire(m.notifier)
+		m.notifier = nil
+		return err
 	}
 	return nil
 }
 
+// UpdateConfig runs update while no notification is being sent
+func (m *Manager) UpdateConfig(update func()) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.UpdateConfig(update)
+		return
+	}
+	update()
+}
+
 // NotifyAgentOffline sends agent offline notification
 func (m *Manager) NotifyAgentOffline(agent *types.AgentInfo) {
-	m.notifier.NotifyAgentOffline(agent)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyAgentOffline(agent)
+	}
+}
+
+// NotifyAgentOnline sends agent recovery notification
+func (m *Manager) NotifyAgentOnline(agent *types.AgentInfo, downtime time.Duration) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyAgentOnline(agent, downtime)
+	}
+}
+
+// NotifyAgentMissing sends expected inventory alert
+func (m *Manager) NotifyAgentMissing(alert *types.MissingAgentAlert) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyAgentMissing(alert)
+	}
+}
+
+// NotifyRuleAlert sends custom alert rule alert
+func (m *Manager) NotifyRuleAlert(alert *types.RuleAlert) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyRuleAlert(alert)
+	}
 }
 
 // NotifyNetworkErrors sends network errors notification
 func (m *Manager) NotifyNetworkErrors(agentID string, iface *types.InterfaceInfo) {
-	m.notifier.NotifyNetworkErrors(agentID, iface)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyNetworkErrors(agentID, iface)
+	}
 }
 
 // NotifyHighNetworkUtilization sends high network utilization notification
 func (m *Manager) NotifyHighNetworkUtilization(agentID string, iface *types.InterfaceInfo) {
-	m.notifier.NotifyHighNetworkUtilization(agentID, iface)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyHighNetworkUtilization(agentID, iface)
+	}
 }
 
 // NotifyIPChange sends IP change notification
 func (m *Manager) NotifyIPChange(agent *types.AgentInfo, change *types.IPChange) {
-	m.notifier.NotifyIPChange(agent, change)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		m.notifier.NotifyIPChange(agent, change)
+	}
+}
+
+// NotifyReport sends a summary report through the named notifiers, or
+// through all enabled notifiers when none are named
+func (m *Manager) NotifyReport(report *types.Report, notifiers []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		notifierTypes := make([]notify.NotifierType, len(notifiers))
+		for i, name := range notifiers {
+			notifierTypes[i] = notify.NotifierType(name)
+		}
+		m.notifier.NotifyReport(report, notifierTypes...)
+	}
+}
+
+// NotifyHeartbeat sends a liveness event of the server through the named
+// notifiers, or through all enabled notifiers when none are named
+func (m *Manager) NotifyHeartbeat(hb *types.Heartbeat, notifiers []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.notifier != nil {
+		notifierTypes := make([]notify.NotifierType, len(notifiers))
+		for i, name := range notifiers {
+			notifierTypes[i] = notify.NotifierType(name)
+		}
+		m.notifier.NotifyHeartbeat(hb, notifierTypes...)
+	}
+}
+
+// Test sends a test alert through the named notifier, or through all enabled
+// notifiers when none is named, and returns their deliveries
+func (m *Manager) Test(ctx context.Context, alert *types.RuleAlert, channel string) ([]*types.NotificationTestResult, error) {
+	// Not held while waiting for deliveries, which would block reloads
+	m.mu.RLock()
+	notifier := m.notifier
+	m.mu.RUnlock()
+	if notifier == nil {
+		return nil, types.ErrNotifyDisabled
+	}
+
+	if channel == "" {
+		return notifier.Test(ctx, alert), nil
+	}
+	if !notifier.IsNotifierEnabled(notify.NotifierType(channel)) {
+		return nil, fmt.Errorf("%w: %s", types.ErrChannelNotEnabled, channel)
+	}
+	return notifier.Test(ctx, alert, notify.NotifierType(channel)), nil
 }
 
 // Check checks the health of the notification manager
 func (m *Manager) Check(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	if m.notifier != nil {
 		return m.notifier.Health(ctx)
 	}
 	return nil
 }
 
-// Close closes the notification manager
-func (m *Manager) Close() error {
+// QueueDepth returns the number of pending notifications and the queue capacity
+func (m *Manager) QueueDepth() (int, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	if m.notifier != nil {
-		return m.notifier.Stop()
+		return m.notifier.QueueDepth()
 	}
-	return nil
+	return 0, 0
+}
+
+// Close closes the notification manager
+func (m *Manager) Close() error {
+	return m.Stop()
 }
@@ -0,0 +1,173 @@
+// Package privacy redacts the IP addresses of agents and API clients before
+// they are stored or notified, for deployments with data protection
+// requirements.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/netip"
+	"strconv"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// Redaction modes
+const (
+	ModeHash     = "hash"
+	ModeTruncate = "truncate"
+)
+
+// HashPrefix starts hashed addresses, which are not valid addresses so they
+// are never redacted twice or taken for one
+const HashPrefix = "ip-"
+
+// Redactor redacts IP addresses, the zero mode keeps them
+type Redactor struct {
+	mode       string
+	key        []byte
+	ipv4Prefix int
+	ipv6Prefix int
+}
+
+// New creates a redactor from the privacy configuration
+func New(cfg *config.PrivacyConfig) *Redactor {
+	return &Redactor{
+		mode:       cfg.IPAddresses,
+		key:        []byte(cfg.HashSecret),
+		ipv4Prefix: cfg.IPv4Prefix,
+		ipv6Prefix: cfg.IPv6Prefix,
+	}
+}
+
+// Enabled reports whether addresses are redacted
+func (r *Redactor) Enabled() bool {
+	return r != nil && r.mode != ""
+}
+
+// Addr redacts an address, optionally with a prefix length which is kept.
+// Values that are not addresses, hashed ones included, are returned as is,
+// so redacting is idempotent. A hash is the same for an address wherever it
+// appears, so changes of addresses are still detected.
+func (r *Redactor) Addr(s string) string {
+	if !r.Enabled() || s == "" {
+		return s
+	}
+
+	bits := -1
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return s
+		}
+		addr, bits = prefix.Addr(), prefix.Bits()
+	}
+	addr = addr.WithZone("").Unmap()
+
+	var redacted string
+	switch r.mode {
+	case ModeHash:
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write(addr.AsSlice())
+		redacted = HashPrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+	case ModeTruncate:
+		keep := r.ipv4Prefix
+		if addr.Is6() {
+			keep = r.ipv6Prefix
+		}
+		prefix, _ := addr.Prefix(keep)
+		redacted = prefix.Addr().String()
+	default:
+		return s
+	}
+
+	if bits >= 0 {
+		redacted += "/" + strconv.Itoa(bits)
+	}
+	return redacted
+}
+
+// Addrs redacts addresses into a new slice
+func (r *Redactor) Addrs(addrs []string) []string {
+	if !r.Enabled() || addrs == nil {
+		return addrs
+	}
+	redacted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		redacted[i] = r.Addr(addr)
+	}
+	return redacted
+}
+
+// Metrics redacts the interface, external and gateway addresses and the
+// IP changes of a report. Route destinations are networks and kept.
+func (r *Redactor) Metrics(data *types.MetricsData) {
+	network := data.Metrics.Network
+	if !r.Enabled() || network == nil {
+		return
+	}
+
+	interfaces := make(map[string]*types.InterfaceInfo, len(network.Interfaces))
+	for name, iface := range network.Interfaces {
+		if iface != nil {
+			redacted := *iface
+			redacted.IPv4 = r.Addrs(iface.IPv4)
+			redacted.IPv6 = r.Addrs(iface.IPv6)
+			iface = &redacted
+		}
+		interfaces[name] = iface
+	}
+	network.Interfaces = interfaces
+
+	network.ExternalIP = r.Addr(network.ExternalIP)
+	network.ExternalIPs = r.addrMap(network.ExternalIPs)
+	network.Gateways = r.addrMap(network.Gateways)
+	if network.Routes != nil {
+		routes := make([]types.RouteInfo, len(network.Routes))
+		for i, route := range network.Routes {
+			route.Gateway = r.Addr(route.Gateway)
+			routes[i] = route
+		}
+		network.Routes = routes
+	}
+	if network.IPChanges != nil {
+		changes := make([]types.IPChange, len(network.IPChanges))
+		for i := range network.IPChanges {
+			changes[i] = network.IPChanges[i]
+			r.IPChange(&changes[i])
+		}
+		network.IPChanges = changes
+	}
+}
+
+// IPChange redacts the addresses of an IP change and of its context. The
+// reverse DNS names of the context are dropped, they often spell out the
+// address.
+func (r *Redactor) IPChange(change *types.IPChange) {
+	if !r.Enabled() {
+		return
+	}
+	change.OldAddrs = r.Addrs(change.OldAddrs)
+	change.NewAddrs = r.Addrs(change.NewAddrs)
+	if change.Context != nil {
+		ipContext := *change.Context
+		ipContext.IP = r.Addr(ipContext.IP)
+		ipContext.ReverseDNS = nil
+		change.Context = &ipContext
+	}
+}
+
+// addrMap redacts the addresses of a map by IP version into a new map
+func (r *Redactor) addrMap(addrs map[types.IPVersion]string) map[types.IPVersion]string {
+	if addrs == nil {
+		return nil
+	}
+	redacted := maps.Clone(addrs)
+	for version, addr := range redacted {
+		redacted[version] = r.Addr(addr)
+	}
+	return redacted
+}
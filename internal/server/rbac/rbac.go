@@ -0,0 +1,67 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"wameter/internal/types"
+)
+
+// contextKey is the context key of the request principal
+type contextKey struct{}
+
+// adminRoutes are routes outside /v1/admin that need the admin role
+var adminRoutes = map[string]bool{
+	"DELETE /v1/agents/:id":              true,
+	"POST /v1/agents/:id/decommission":   true,
+	"DELETE /v1/agents/:id/decommission": true,
+	"GET /v1/audit":                      true,
+}
+
+// readRoutes are POST routes that only read and need the viewer role
+var readRoutes = map[string]bool{
+	"POST /v1/grafana/search":      true,
+	"POST /v1/grafana/query":       true,
+	"POST /v1/grafana/annotations": true,
+}
+
+// WithPrincipal returns ctx carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *types.Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of ctx, requests without authentication
+// carry none and are not restricted
+func FromContext(ctx context.Context) (*types.Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*types.Principal)
+	return p, ok && p != nil
+}
+
+// AllowsAgent reports whether ctx may access agent id
+func AllowsAgent(ctx context.Context, id string) bool {
+	p, ok := FromContext(ctx)
+	return !ok || len(p.Agents) == 0 || slices.Contains(p.Agents, id)
+}
+
+// Agents returns the agents ctx is restricted to, it is empty when all
+// agents are allowed
+func Agents(ctx context.Context) []string {
+	if p, ok := FromContext(ctx); ok {
+		return p.Agents
+	}
+	return nil
+}
+
+// Required returns the role needed to call route, reads need a viewer,
+// writes an operator and administration an admin
+func Required(method, route string) types.Role {
+	switch {
+	case strings.HasPrefix(route, "/v1/admin/"), adminRoutes[method+" "+route]:
+		return types.RoleAdmin
+	case method == http.MethodGet, method == http.MethodHead, readRoutes[method+" "+route]:
+		return types.RoleViewer
+	default:
+		return types.RoleOperator
+	}
+}
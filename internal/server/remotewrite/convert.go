@@ -0,0 +1,195 @@
+package remotewrite
+
+import (
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+)
+
+// Version is the version of the agents reporting through remote write
+const Version = "remote_write"
+
+// metricSetter applies a sample of a metric to the interface it belongs to
+type metricSetter func(iface *types.InterfaceInfo, labels map[string]string, value float64)
+
+// setters maps the supported node_exporter network metrics to the interface fields
+var setters = map[string]metricSetter{
+	"node_network_receive_bytes_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.RxBytes = counter(v)
+	},
+	"node_network_transmit_bytes_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.TxBytes = counter(v)
+	},
+	"node_network_receive_packets_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.RxPackets = counter(v)
+	},
+	"node_network_transmit_packets_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.TxPackets = counter(v)
+	},
+	"node_network_receive_errs_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.RxErrors = counter(v)
+	},
+	"node_network_transmit_errs_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.TxErrors = counter(v)
+	},
+	"node_network_receive_drop_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.RxDropped = counter(v)
+	},
+	"node_network_transmit_drop_total": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.TxDropped = counter(v)
+	},
+	"node_network_up": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.IsUp = v == 1
+		iface.Status = "down"
+		if iface.Statistics.IsUp {
+			iface.Status = "up"
+		}
+	},
+	"node_network_carrier": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.HasCarrier = v == 1
+	},
+	"node_network_speed_bytes": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.Statistics.Speed = int64(v * 8 / 1000000) // Bytes/s to Mbps
+	},
+	"node_network_mtu_bytes": func(iface *types.InterfaceInfo, _ map[string]string, v float64) {
+		iface.MTU = int(v)
+	},
+	"node_network_info": func(iface *types.InterfaceInfo, labels map[string]string, _ float64) {
+		iface.MAC = labels["address"]
+		iface.Statistics.OperState = labels["operstate"]
+	},
+	"node_network_address_info": func(iface *types.InterfaceInfo, labels map[string]string, _ float64) {
+		ip := net.ParseIP(labels["address"])
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			iface.IPv4 = append(iface.IPv4, ip.String())
+		default:
+			iface.IPv6 = append(iface.IPv6, ip.String())
+		}
+	},
+}
+
+// Metrics returns the supported metric names
+func Metrics() []string {
+	names := make([]string, 0, len(setters))
+	for name := range setters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Supported returns true if samples of the metric can be stored
+func Supported(name string) bool {
+	_, ok := setters[name]
+	return ok
+}
+
+// Converter maps series of node_exporter network metrics to agent reports
+type Converter struct {
+	agentLabel  string
+	agentPrefix string
+	metrics     map[string]bool
+}
+
+// NewConverter creates new converter of the selected metrics, the agent of a
+// series is the value of agentLabel without its port
+func NewConverter(agentLabel, agentPrefix string, metrics []string) *Converter {
+	c := &Converter{
+		agentLabel:  agentLabel,
+		agentPrefix: agentPrefix,
+		metrics:     make(map[string]bool, len(metrics)),
+	}
+	for _, name := range metrics {
+		c.metrics[name] = true
+	}
+	return c
+}
+
+// reportKey identifies the report of an agent at a scrape
+type reportKey struct {
+	agentID   string
+	timestamp int64
+}
+
+// Convert returns the reports of the series, one per agent and sample
+// timestamp ordered by time. Series of other metrics, without the agent
+// label or device, and stale samples are skipped.
+func (c *Converter) Convert(series []TimeSeries) []*types.MetricsData {
+	reports := make(map[reportKey]*types.MetricsData)
+
+	for _, ts := range series {
+		name := ts.Labels["__name__"]
+		set, ok := setters[name]
+		if !ok || !c.metrics[name] {
+			continue
+		}
+		instance, device := ts.Labels[c.agentLabel], ts.Labels["device"]
+		if instance == "" || device == "" {
+			continue
+		}
+		hostname := instance
+		if host, _, err := net.SplitHostPort(instance); err == nil {
+			hostname = host
+		}
+		agentID := c.agentPrefix + hostname
+
+		for _, s := range ts.Samples {
+			if math.IsNaN(s.Value) {
+				continue
+			}
+			key := reportKey{agentID: agentID, timestamp: s.Timestamp}
+			data, ok := reports[key]
+			if !ok {
+				at := time.UnixMilli(s.Timestamp)
+				data = &types.MetricsData{
+					SchemaVersion: types.MetricsSchemaVersion,
+					AgentID:       agentID,
+					Hostname:      hostname,
+					Version:       Version,
+					Timestamp:     at,
+					CollectedAt:   at,
+				}
+				data.Metrics.Network = &types.NetworkState{Interfaces: make(map[string]*types.InterfaceInfo)}
+				reports[key] = data
+			}
+
+			iface, ok := data.Metrics.Network.Interfaces[device]
+			if !ok {
+				iface = &types.InterfaceInfo{
+					Name:       device,
+					Type:       string(utils.InterfaceTypeByName(device)),
+					UpdatedAt:  data.Timestamp,
+					Statistics: &types.InterfaceStats{CollectedAt: data.Timestamp},
+				}
+				data.Metrics.Network.Interfaces[device] = iface
+			}
+			set(iface, ts.Labels, s.Value)
+		}
+	}
+
+	result := make([]*types.MetricsData, 0, len(reports))
+	for _, data := range reports {
+		result = append(result, data)
+	}
+	slices.SortFunc(result, func(a, b *types.MetricsData) int {
+		if c := a.Timestamp.Compare(b.Timestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(a.AgentID, b.AgentID)
+	})
+	return result
+}
+
+// counter converts the float value of a counter
+func counter(v float64) uint64 {
+	if v < 0 {
+		return 0
+	}
+	return uint64(v)
+}
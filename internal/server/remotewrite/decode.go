@@ -0,0 +1,154 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxDecodedSize caps the uncompressed size of a request, as Prometheus does
+const maxDecodedSize = 32 << 20
+
+// TimeSeries represents a series of a remote write request
+type TimeSeries struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Sample represents a value of a series
+type Sample struct {
+	Value     float64
+	Timestamp int64 // Milliseconds since the epoch
+}
+
+// Decode decodes a snappy compressed remote write 1.0 request, the
+// prometheus.WriteRequest protobuf message. Metadata and exemplars are ignored.
+func Decode(body []byte) ([]TimeSeries, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	if size > maxDecodedSize {
+		return nil, fmt.Errorf("decompressed request exceeds %d bytes", maxDecodedSize)
+	}
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+
+	var series []TimeSeries
+	err = consumeFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 || typ != protowire.BytesType {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		ts, err := decodeTimeSeries(v)
+		if err != nil {
+			return 0, err
+		}
+		series = append(series, ts)
+		return n, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	return series, nil
+}
+
+// decodeTimeSeries decodes a prometheus.TimeSeries message
+func decodeTimeSeries(b []byte) (TimeSeries, error) {
+	ts := TimeSeries{Labels: make(map[string]string)}
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		if num == 1 {
+			name, value, err := decodeLabel(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid label: %w", err)
+			}
+			ts.Labels[name] = value
+		} else {
+			s, err := decodeSample(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid sample: %w", err)
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return n, nil
+	})
+	return ts, err
+}
+
+// decodeLabel decodes a prometheus.Label message
+func decodeLabel(b []byte) (name, value string, err error) {
+	err = consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeString(b)
+		if num == 1 {
+			name = v
+		} else {
+			value = v
+		}
+		return n, nil
+	})
+	return name, value, err
+}
+
+// decodeSample decodes a prometheus.Sample message
+func decodeSample(b []byte) (Sample, error) {
+	var s Sample
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			s.Timestamp = int64(v)
+			return n, nil
+		default:
+			return 0, nil
+		}
+	})
+	return s, err
+}
+
+// consumeFields calls fn with the value of each field of a message, fn
+// returns the length it consumed, 0 to skip the field, or a negative
+// protowire error code
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := fn(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Package requestid carries the ID of an API request through the context,
+// so the logs of the layers serving it can be correlated.
+package requestid
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Header is the header the request ID is read from and returned in
+const Header = "X-Request-ID"
+
+// maxLength bounds request IDs accepted from clients
+const maxLength = 128
+
+// contextKey is the context key of the request ID
+type contextKey struct{}
+
+// WithContext returns ctx carrying the request ID id
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, contexts of background tasks
+// carry none
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Logger returns logger with the request ID of ctx, if any
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id, ok := FromContext(ctx); ok {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// Valid reports whether a request ID sent by a client is kept, IDs are
+// logged so only short printable ASCII ones are
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
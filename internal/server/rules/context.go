@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"strconv"
+	"strings"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// units are the suffixes of size and rate literals in bytes, sizes are
+// binary like the sizes the server formats, bit rates decimal
+var units = map[string]float64{
+	"KB":   1 << 10,
+	"MB":   1 << 20,
+	"GB":   1 << 30,
+	"TB":   1 << 40,
+	"KiB":  1 << 10,
+	"MiB":  1 << 20,
+	"GiB":  1 << 30,
+	"TiB":  1 << 40,
+	"Kbps": 1e3 / 8,
+	"Mbps": 1e6 / 8,
+	"Gbps": 1e9 / 8,
+}
+
+// functions are the functions available to rules
+var functions = map[string]function.Function{
+	"abs":      stdlib.AbsoluteFunc,
+	"ceil":     stdlib.CeilFunc,
+	"floor":    stdlib.FloorFunc,
+	"max":      stdlib.MaxFunc,
+	"min":      stdlib.MinFunc,
+	"lower":    stdlib.LowerFunc,
+	"upper":    stdlib.UpperFunc,
+	"length":   stdlib.LengthFunc,
+	"contains": stdlib.ContainsFunc,
+	"lookup":   stdlib.LookupFunc,
+	"format":   stdlib.FormatFunc,
+	"try":      tryfunc.TryFunc,
+	"can":      tryfunc.CanFunc,
+	"format_bytes": function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "bytes", Type: cty.Number}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			f, _ := args[0].AsBigFloat().Float64()
+			return cty.StringVal(utils.FormatBytes(uint64(max(f, 0)))), nil
+		},
+	}),
+	"format_rate": function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "bytes_per_sec", Type: cty.Number}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			f, _ := args[0].AsBigFloat().Float64()
+			return cty.StringVal(utils.FormatBytesRate(f) + "/s"), nil
+		},
+	}),
+}
+
+// expandUnits replaces size literals such as 50MB with their value in
+// bytes, the lexer tells them apart from text in strings
+func expandUnits(src string) (string, error) {
+	tokens, diags := hclsyntax.LexExpression([]byte(src), "condition", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", diagsError(diags)
+	}
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i+1 < len(tokens); i++ {
+		num, unit := tokens[i], tokens[i+1]
+		if num.Type != hclsyntax.TokenNumberLit || unit.Type != hclsyntax.TokenIdent ||
+			num.Range.End.Byte != unit.Range.Start.Byte {
+			continue
+		}
+		factor, ok := units[string(unit.Bytes)]
+		if !ok {
+			continue
+		}
+		b.WriteString(src[last:num.Range.Start.Byte])
+		b.WriteString("(" + string(num.Bytes) + " * " + strconv.FormatFloat(factor, 'f', -1, 64) + ")")
+		last = unit.Range.End.Byte
+		i++
+	}
+	b.WriteString(src[last:])
+	return b.String(), nil
+}
+
+// metricsVariables returns the variables of metrics reported by an agent:
+// agent, with its tags, and network, with the interfaces by name
+func metricsVariables(data *types.MetricsData, tags map[string]string) map[string]cty.Value {
+	agentTags := make(map[string]cty.Value, len(tags))
+	for k, v := range tags {
+		agentTags[k] = cty.StringVal(v)
+	}
+
+	network := map[string]cty.Value{
+		"external_ip": cty.StringVal(""),
+		"interfaces":  cty.EmptyObjectVal,
+	}
+	if n := data.Metrics.Network; n != nil {
+		network["external_ip"] = cty.StringVal(n.ExternalIP)
+		ifaces := make(map[string]cty.Value, len(n.Interfaces))
+		for name, iface := range n.Interfaces {
+			ifaces[name] = interfaceValue(iface)
+		}
+		network["interfaces"] = cty.ObjectVal(ifaces)
+	}
+
+	return map[string]cty.Value{
+		"agent": cty.ObjectVal(map[string]cty.Value{
+			"id":       cty.StringVal(data.AgentID),
+			"hostname": cty.StringVal(data.Hostname),
+			"version":  cty.StringVal(data.Version),
+			"tags":     cty.ObjectVal(agentTags),
+		}),
+		"network": cty.ObjectVal(network),
+	}
+}
+
+// interfaceValue returns the value of an interface, stats is null when the
+// agent reported no statistics
+func interfaceValue(iface *types.InterfaceInfo) cty.Value {
+	stats := cty.NullVal(cty.DynamicPseudoType)
+	if s := iface.Statistics; s != nil {
+		stats = cty.ObjectVal(map[string]cty.Value{
+			"is_up":           cty.BoolVal(s.IsUp),
+			"oper_state":      cty.StringVal(s.OperState),
+			"speed":           cty.NumberIntVal(s.Speed),
+			"rx_bytes":        cty.NumberUIntVal(s.RxBytes),
+			"tx_bytes":        cty.NumberUIntVal(s.TxBytes),
+			"rx_packets":      cty.NumberUIntVal(s.RxPackets),
+			"tx_packets":      cty.NumberUIntVal(s.TxPackets),
+			"rx_errors":       cty.NumberUIntVal(s.RxErrors),
+			"tx_errors":       cty.NumberUIntVal(s.TxErrors),
+			"rx_dropped":      cty.NumberUIntVal(s.RxDropped),
+			"tx_dropped":      cty.NumberUIntVal(s.TxDropped),
+			"rx_rate":         cty.NumberFloatVal(s.RxBytesRate),
+			"tx_rate":         cty.NumberFloatVal(s.TxBytesRate),
+			"rx_packets_rate": cty.NumberFloatVal(s.RxPacketsRate),
+			"tx_packets_rate": cty.NumberFloatVal(s.TxPacketsRate),
+			"utilization":     cty.NumberFloatVal(s.Utilization()),
+			"counter_reset":   cty.BoolVal(s.CounterReset),
+		})
+	}
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"name":   cty.StringVal(iface.Name),
+		"type":   cty.StringVal(iface.Type),
+		"mac":    cty.StringVal(iface.MAC),
+		"mtu":    cty.NumberIntVal(int64(iface.MTU)),
+		"status": cty.StringVal(iface.Status),
+		"ipv4":   stringList(iface.IPv4),
+		"ipv6":   stringList(iface.IPv6),
+		"stats":  stats,
+	})
+}
+
+// stringList returns a list of strings, empty when there are none
+func stringList(values []string) cty.Value {
+	if len(values) == 0 {
+		return cty.ListValEmpty(cty.String)
+	}
+	list := make([]cty.Value, len(values))
+	for i, v := range values {
+		list[i] = cty.StringVal(v)
+	}
+	return cty.ListVal(list)
+}
@@ -0,0 +1,167 @@
+// Package rules evaluates custom alert rules, written as HCL expressions
+// over the metrics reported by agents
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"wameter/internal/types"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// Rule represents a compiled alert rule
+type Rule struct {
+	Name      string
+	Severity  string
+	condition hcl.Expression
+	message   hcl.Expression            // Nil for the default message
+	labels    map[string]hcl.Expression // Enrichment of the alert
+}
+
+// Result represents the alert of a firing rule
+type Result struct {
+	Message string
+	Labels  map[string]string
+}
+
+// Compile compiles a rule, the message and labels are templates such as
+// "rx ${format_rate(network.interfaces["eth0"].stats.rx_rate)}"
+func Compile(name, severity, condition, message string, labels map[string]string) (*Rule, error) {
+	cond, err := ParseExpression(condition)
+	if err == nil {
+		err = checkVariables(cond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition: %w", err)
+	}
+
+	r := &Rule{
+		Name:      name,
+		Severity:  severity,
+		condition: cond,
+		labels:    make(map[string]hcl.Expression, len(labels)),
+	}
+	if message != "" {
+		if r.message, err = ParseTemplate(message); err == nil {
+			err = checkVariables(r.message)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid message: %w", err)
+		}
+	}
+	for k, v := range labels {
+		if r.labels[k], err = ParseTemplate(v); err == nil {
+			err = checkVariables(r.labels[k])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid label %q: %w", k, err)
+		}
+	}
+	return r, nil
+}
+
+// ParseExpression parses an expression, size literals such as 50MB are
+// expanded to bytes
+func ParseExpression(src string) (hcl.Expression, error) {
+	src, err := expandUnits(src)
+	if err != nil {
+		return nil, err
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "condition", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diagsError(diags)
+	}
+	return expr, nil
+}
+
+// ParseTemplate parses a string template with ${...} interpolations
+func ParseTemplate(src string) (hcl.Expression, error) {
+	expr, diags := hclsyntax.ParseTemplate([]byte(src), "template", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diagsError(diags)
+	}
+	return expr, nil
+}
+
+// Eval evaluates the rule in ctx, it returns nil when the condition is
+// false or null. Errors such as indexing a missing interface are returned.
+func (r *Rule) Eval(ctx *hcl.EvalContext) (*Result, error) {
+	v, diags := r.condition.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diagsError(diags)
+	}
+	v, err := convert.Convert(v, cty.Bool)
+	if err != nil {
+		return nil, fmt.Errorf("condition is not a bool: %w", err)
+	}
+	if v.IsNull() || !v.IsKnown() || v.False() {
+		return nil, nil
+	}
+
+	res := &Result{Labels: make(map[string]string, len(r.labels))}
+	if r.message != nil {
+		if res.Message, err = evalString(r.message, ctx); err != nil {
+			return nil, fmt.Errorf("failed to render message: %w", err)
+		}
+	}
+	for k, expr := range r.labels {
+		if res.Labels[k], err = evalString(expr, ctx); err != nil {
+			return nil, fmt.Errorf("failed to render label %q: %w", k, err)
+		}
+	}
+	return res, nil
+}
+
+// NewContext returns the evaluation context of metrics reported by an
+// agent with tags
+func NewContext(data *types.MetricsData, tags map[string]string) *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: metricsVariables(data, tags),
+		Functions: functions,
+	}
+}
+
+// checkVariables reports references to unknown variables, which would
+// fail every evaluation
+func checkVariables(expr hcl.Expression) error {
+	for _, t := range expr.Variables() {
+		if root := t.RootName(); root != "agent" && root != "network" {
+			return fmt.Errorf("unknown variable %q, expected agent or network", root)
+		}
+	}
+	return nil
+}
+
+// evalString evaluates a template to a string
+func evalString(expr hcl.Expression, ctx *hcl.EvalContext) (string, error) {
+	v, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return "", diagsError(diags)
+	}
+	v, err := convert.Convert(v, cty.String)
+	if err != nil {
+		return "", err
+	}
+	if v.IsNull() || !v.IsKnown() {
+		return "", nil
+	}
+	return v.AsString(), nil
+}
+
+// diagsError returns the errors of diags as one error without the source
+// positions, which are not meaningful in a config value
+func diagsError(diags hcl.Diagnostics) error {
+	var msgs []string
+	for _, d := range diags.Errs() {
+		if d, ok := d.(*hcl.Diagnostic); ok && d.Detail != "" {
+			msgs = append(msgs, d.Summary+": "+d.Detail)
+			continue
+		}
+		msgs = append(msgs, d.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
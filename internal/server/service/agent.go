@@ -4,10 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 	"wameter/internal/agent/config"
+	"wameter/internal/server/agentstate"
+	"wameter/internal/server/rbac"
+	"wameter/internal/server/tenant"
+	"wameter/internal/tracing"
 	"wameter/internal/types"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -16,7 +23,8 @@ type AgentService interface {
 	RegisterAgent(ctx context.Context, agent *types.AgentInfo) error
 	UpdateAgent(ctx context.Context, agent *types.AgentInfo) error
 	GetAgent(ctx context.Context, agentID string) (*types.AgentInfo, error)
-	GetAgents(ctx context.Context) ([]*types.AgentInfo, error)
+	GetAgents(ctx context.Context, filter *types.AgentFilter) ([]*types.AgentInfo, int64, error)
+	GetAgentStatuses(ctx context.Context, filter *types.AgentFilter) (map[string]*types.AgentStatusInfo, error)
 	DeleteAgent(ctx context.Context, agentID string) error
 	UpdateAgentStatus(ctx context.Context, agentID string, status types.AgentStatus) error
 	GetAgentMetrics(ctx context.Context, agentID string) (*types.AgentMetrics, error)
@@ -32,6 +40,19 @@ func (s *Service) RegisterAgent(ctx context.Context, agent *types.AgentInfo) err
 	if agent.ID == "" || agent.Hostname == "" {
 		return fmt.Errorf("invalid agent info: missing required fields")
 	}
+	if !rbac.AllowsAgent(ctx, agent.ID) {
+		return types.ErrForbidden
+	}
+	if err := s.checkErasurePending(agent.ID); err != nil {
+		return err
+	}
+
+	// Agree on the protocol features to use with the agent
+	protocol, err := s.negotiateProtocol(agent.Capabilities)
+	if err != nil {
+		return err
+	}
+	agent.Capabilities = nil
 
 	// Add timeout if not set
 	if _, ok := ctx.Deadline(); !ok {
@@ -44,32 +65,49 @@ func (s *Service) RegisterAgent(ctx context.Context, agent *types.AgentInfo) err
 	s.agentsMu.Lock()
 	defer s.agentsMu.Unlock()
 
+	// Agent IDs are unique across tenants
+	if cached, ok := s.agents[agent.ID]; ok && !tenant.Allows(ctx, cached.TenantID) {
+		return types.ErrAgentExists
+	}
+
 	// Check if agent already exists
 	existing, err := s.agentRepo.FindByID(ctx, agent.ID)
 	if err != nil && !errors.Is(err, types.ErrAgentNotFound) {
 		return fmt.Errorf("failed to check existing agent: %w", err)
 	}
 
-	// Update existing agent
+	// Update existing agent, retired agents stay retired until reinstated
 	if existing != nil {
+		if existing.Status == types.AgentStatusRetired {
+			return types.ErrAgentRetired
+		}
+		prev := agentstate.State{Status: existing.Status, LastSeen: existing.LastSeen}
 		existing.Hostname = agent.Hostname
 		existing.Version = agent.Version
+		existing.Protocol = protocol
 		existing.Status = types.AgentStatusOnline
-		existing.LastSeen = time.Now()
-		existing.UpdatedAt = time.Now()
+		existing.LastSeen = s.clock.Now()
+		existing.UpdatedAt = s.clock.Now()
 
 		if err := s.agentRepo.UpdateAgent(ctx, existing); err != nil {
 			return fmt.Errorf("failed to update existing agent: %w", err)
 		}
+		agent.Protocol = protocol
 		s.agents[existing.ID] = existing
+		if shared, ok := s.shareAgentState(ctx, existing); ok {
+			prev = shared
+		}
+		s.notifyAgentRecovered(existing, prev)
 		return nil
 	}
 
-	// Create new agent
-	agent.RegisteredAt = time.Now()
-	agent.UpdatedAt = time.Now()
-	agent.LastSeen = time.Now()
+	// Create new agent in the tenant of the caller
+	agent.TenantID = tenant.OrDefault(ctx)
+	agent.RegisteredAt = s.clock.Now()
+	agent.UpdatedAt = s.clock.Now()
+	agent.LastSeen = s.clock.Now()
 	agent.Status = types.AgentStatusOnline
+	agent.Protocol = protocol
 
 	// Save in repository
 	if err := s.agentRepo.Save(ctx, agent); err != nil {
@@ -78,6 +116,7 @@ func (s *Service) RegisterAgent(ctx context.Context, agent *types.AgentInfo) err
 
 	// Update agent in memory
 	s.agents[agent.ID] = agent
+	s.shareAgentState(ctx, agent)
 	return nil
 }
 
@@ -89,13 +128,21 @@ func (s *Service) UpdateAgent(ctx context.Context, agent *types.AgentInfo) error
 
 	// Check if agent already exists
 	existing, err := s.agentRepo.FindByID(ctx, agent.ID)
-	if err != nil && !errors.Is(err, types.ErrAgentNotFound) {
+	if err != nil {
+		if errors.Is(err, types.ErrAgentNotFound) {
+			return err
+		}
 		return fmt.Errorf("failed to check existing agent: %w", err)
 	}
 
-	// If agent doesn't exist, fetch it from the repository
+	if existing.Status == types.AgentStatusRetired {
+		return types.ErrAgentRetired
+	}
+
+	agent.TenantID = existing.TenantID
 	agent.RegisteredAt = existing.RegisteredAt
-	agent.UpdatedAt = time.Now()
+	agent.Maintenance = existing.Maintenance
+	agent.UpdatedAt = s.clock.Now()
 
 	// Update in repository
 	if err := s.agentRepo.UpdateAgent(ctx, agent); err != nil {
@@ -104,6 +151,7 @@ func (s *Service) UpdateAgent(ctx context.Context, agent *types.AgentInfo) error
 
 	// Update internal state
 	s.agents[agent.ID] = agent
+	s.shareAgentState(ctx, agent)
 
 	return nil
 }
@@ -113,9 +161,50 @@ func (s *Service) GetAgent(ctx context.Context, agentID string) (*types.AgentInf
 	return s.agentRepo.FindByID(ctx, agentID)
 }
 
-// GetAgents returns all agents
-func (s *Service) GetAgents(ctx context.Context) ([]*types.AgentInfo, error) {
-	return s.agentRepo.List(ctx)
+// GetAgents returns a page of the agents matching the filter and the total
+// number of matching agents
+func (s *Service) GetAgents(ctx context.Context, filter *types.AgentFilter) ([]*types.AgentInfo, int64, error) {
+	total, err := s.agentRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	agents, err := s.agentRepo.ListWithPagination(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return agents, total, nil
+}
+
+// GetAgentStatuses returns the status of the agents matching the filter by
+// agent ID, from a single query. Agents held in memory are answered with
+// their status and last seen time there, which include those shared by other
+// replicas and are at least as recent as the stored ones.
+func (s *Service) GetAgentStatuses(ctx context.Context, filter *types.AgentFilter) (map[string]*types.AgentStatusInfo, error) {
+	statuses, err := s.agentRepo.ListStatuses(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	for id, status := range statuses {
+		agent, ok := s.agents[id]
+		if !ok || agent.LastSeen.Before(status.LastSeen) {
+			continue
+		}
+		status.Status = agent.Status
+		status.LastSeen = agent.LastSeen
+		// The stored status matched the filter, the current one may not
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, status.Status) {
+			delete(statuses, id)
+		}
+	}
+	return statuses, nil
 }
 
 // DeleteAgent deletes an agent
@@ -128,7 +217,7 @@ func (s *Service) DeleteAgent(ctx context.Context, agentID string) error {
 
 	// Check if agent is offline
 	if agent.Status == types.AgentStatusOnline {
-		return fmt.Errorf("cannot delete online agent")
+		return fmt.Errorf("cannot delete agent: %w", types.ErrAgentOnline)
 	}
 
 	// Delete from repository
@@ -136,43 +225,101 @@ func (s *Service) DeleteAgent(ctx context.Context, agentID string) error {
 		return fmt.Errorf("failed to delete agent: %w", err)
 	}
 
-	// Remove agent from memory state
-	s.agentsMu.Lock()
-	delete(s.agents, agentID)
-	s.agentsMu.Unlock()
+	s.forgetAgent(ctx, agentID)
 
-	s.logger.Info("Agent deleted",
+	s.log(ctx).Info("Agent deleted",
 		zap.String("id", agentID),
 		zap.String("hostname", agent.Hostname))
 
 	return nil
 }
 
+// agentScope returns ctx scoped to the tenant of a known agent, ErrForbidden
+// when the credential of ctx may not access the agent, ErrAgentNotFound
+// when the agent is registered to another tenant than ctx, or
+// ErrAgentRetired when the agent no longer accepts reports
+func (s *Service) agentScope(ctx context.Context, agentID string) (context.Context, error) {
+	if !rbac.AllowsAgent(ctx, agentID) {
+		return nil, types.ErrForbidden
+	}
+
+	s.agentsMu.RLock()
+	agent, ok := s.agents[agentID]
+	s.agentsMu.RUnlock()
+
+	if !ok {
+		return ctx, nil
+	}
+	if !tenant.Allows(ctx, agent.TenantID) {
+		return nil, types.ErrAgentNotFound
+	}
+	if agent.Status == types.AgentStatusRetired {
+		return nil, types.ErrAgentRetired
+	}
+	return tenant.WithContext(ctx, agent.TenantID), nil
+}
+
+// agentTags returns a copy of the tags of an agent, nil when it is unknown
+func (s *Service) agentTags(agentID string) map[string]string {
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	if agent, ok := s.agents[agentID]; ok {
+		return maps.Clone(agent.Tags)
+	}
+	return nil
+}
+
+// agentTenant returns the tenant of a known agent, the default tenant
+// otherwise
+func (s *Service) agentTenant(agentID string) string {
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	if agent, ok := s.agents[agentID]; ok && agent.TenantID != "" {
+		return agent.TenantID
+	}
+	return tenant.Default
+}
+
 // UpdateAgentStatus updates agent status
-func (s *Service) UpdateAgentStatus(ctx context.Context, agentID string, status types.AgentStatus) error {
+func (s *Service) UpdateAgentStatus(ctx context.Context, agentID string, status types.AgentStatus) (err error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateAgentStatus",
+		attribute.String("agent_id", agentID),
+		attribute.String("status", string(status)))
+	defer func() { tracing.End(span, err) }()
+
 	// Lock agent map
 	s.agentsMu.Lock()
 	defer s.agentsMu.Unlock()
 
 	// Check if agent exists
 	agent, exists := s.agents[agentID]
+	if exists && !tenant.Allows(ctx, agent.TenantID) {
+		return fmt.Errorf("%w: %s", types.ErrAgentNotFound, agentID)
+	}
 	if !exists {
 		// If agent doesn't exist, fetch it from the repository
 		var err error
 		agent, err = s.agentRepo.FindByID(ctx, agentID)
 		if err != nil {
 			if errors.Is(err, types.ErrAgentNotFound) {
-				return fmt.Errorf("agent not found: %s", agentID)
+				return fmt.Errorf("%w: %s", err, agentID)
 			}
 			return fmt.Errorf("failed to find agent: %w", err)
 		}
 	}
 
+	if agent.Status == types.AgentStatusRetired {
+		return fmt.Errorf("%w: %s", types.ErrAgentRetired, agentID)
+	}
+
 	// Update agent
+	prev := agentstate.State{Status: agent.Status, LastSeen: agent.LastSeen}
 	agent.Status = status
-	agent.UpdatedAt = time.Now()
+	agent.UpdatedAt = s.clock.Now()
 	if status == types.AgentStatusOnline {
-		agent.LastSeen = time.Now()
+		agent.LastSeen = s.clock.Now()
 	}
 
 	// Update status in repository
@@ -182,15 +329,50 @@ func (s *Service) UpdateAgentStatus(ctx context.Context, agentID string, status
 
 	// Update agent in memory
 	s.agents[agentID] = agent
+	if shared, ok := s.shareAgentState(ctx, agent); ok {
+		prev = shared
+	}
 
-	// Send notification if agent went offline
-	if status == types.AgentStatusOffline && s.notifier != nil && s.config.Notify.Enabled {
+	// Send notification if agent went offline or came back, unless in maintenance
+	if status == types.AgentStatusOffline && s.notifier.Enabled() && !agent.Maintenance.Active(s.clock.Now()) {
 		s.notifier.NotifyAgentOffline(agent)
 	}
+	s.notifyAgentRecovered(agent, prev)
+	if prev.Status != "" && prev.Status != status {
+		s.annotateAgentStatus(agent, prev.Status)
+	}
 
 	return nil
 }
 
+// forgetAgent removes a deleted agent from memory and the shared state
+func (s *Service) forgetAgent(ctx context.Context, agentID string) {
+	s.agentsMu.Lock()
+	delete(s.agents, agentID)
+	delete(s.missedChecks, agentID)
+	s.agentsRemovedAt = s.clock.Now()
+	s.agentsMu.Unlock()
+
+	s.rates.forget(agentID)
+	s.deltas.forget(agentID)
+	s.anomalies.forget(agentID)
+	s.netErrors.forget(agentID)
+	s.quotas.forget(agentID)
+	s.invalidateMetrics(ctx, agentID)
+
+	s.commandsMu.Lock()
+	delete(s.pulls, agentID)
+	s.commandsMu.Unlock()
+
+	if s.agentState != nil {
+		if err := s.agentState.Delete(ctx, agentID); err != nil {
+			s.logger.Error("Failed to delete shared agent state",
+				zap.Error(err),
+				zap.String("agent_id", agentID))
+		}
+	}
+}
+
 // GetAgentMetrics returns agent metrics
 func (s *Service) GetAgentMetrics(ctx context.Context, agentID string) (*types.AgentMetrics, error) {
 	// Get agent
@@ -214,7 +396,7 @@ func (s *Service) GetAgentMetrics(ctx context.Context, agentID string) (*types.A
 
 // StartAgentMonitoring starts a background task to monitor agent statuses
 func (s *Service) StartAgentMonitoring() {
-	ticker := time.NewTicker(time.Minute)
+	ticker := s.clock.NewTicker(s.agentMonitorConfig().CheckInterval)
 	defer ticker.Stop()
 
 	for {
@@ -253,70 +435,51 @@ func (s *Service) UpdateAgentConfig(ctx context.Context, agentID string, cfg *co
 		return fmt.Errorf("failed to send config update command: %w", err)
 	}
 
-	s.logger.Info("Agent configuration updated",
+	s.log(ctx).Info("Agent configuration updated",
 		zap.String("id", agentID),
 		zap.String("hostname", agent.Hostname))
 
 	return nil
 }
 
-// loadAgents loads existing agents into the service
+// loadAgents loads existing agents into the service, replacing the agents in
+// memory so agents deleted by other replicas are dropped
 func (s *Service) loadAgents() {
 	const batchSize = 100
 	offset := 0
-	total := 0
+	loaded := make(map[string]*types.AgentInfo)
 
 	for {
 		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
-		agents, err := s.agentRepo.ListWithPagination(ctx, batchSize, offset)
+		agents, err := s.agentRepo.ListWithPagination(ctx, &types.AgentFilter{
+			Limit:  batchSize,
+			Offset: offset,
+		})
 		cancel()
 		if err != nil {
+			// Keep the agents in memory rather than a partial list
 			s.logger.Error("Failed to load agents", zap.Error(err))
+			return
 		}
 
 		if len(agents) == 0 {
 			break
 		}
 
-		// Update memory state for each agent
-		s.agentsMu.Lock()
 		for _, agent := range agents {
 			if agent.ID == "" || agent.Hostname == "" {
 				s.logger.Warn("Skipping invalid agent", zap.String("id", agent.ID))
 				continue
 			}
-			s.agents[agent.ID] = agent
-			total++
+			loaded[agent.ID] = agent
 		}
-		s.agentsMu.Unlock()
 
 		offset += len(agents)
-
-		// s.logger.Debug("Loaded agents batch",
-		// 	zap.Int("batch_size", len(agents)),
-		// 	zap.Int("total_loaded", total))
-
-		// Check if context was canceled
-		if err := s.ctx.Err(); err != nil {
-			s.logger.Error("Agents loading canceled", zap.Error(err))
-		}
 	}
-}
 
-// startAgentMonitoring starts agent monitoring
-func (s *Service) startAgentMonitoring() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			s.logger.Info("Agent monitoring stopped")
-			return
-		case <-ticker.C:
-			s.checkAgentStatuses()
-		}
-	}
+	s.agentsMu.Lock()
+	s.agents = loaded
+	s.agentsMu.Unlock()
 }
 
 // // startAgentCacheRefresh starts agent cache refresh
@@ -341,33 +504,119 @@ func (s *Service) startAgentMonitoring() {
 // 	return s.loadAgents()
 // }
 
-// checkAgentStatuses checks agent statuses
+// shareAgentState records the status of an agent for other replicas and
+// returns the shared state it replaced, ok is false without one
+func (s *Service) shareAgentState(ctx context.Context, agent *types.AgentInfo) (agentstate.State, bool) {
+	if s.agentState == nil {
+		return agentstate.State{}, false
+	}
+	prev, err := s.agentState.Update(ctx, agent.ID, agent.Status, agent.LastSeen)
+	if err != nil {
+		s.logger.Error("Failed to share agent state",
+			zap.Error(err),
+			zap.String("agent_id", agent.ID))
+		return agentstate.State{}, false
+	}
+	// Agents without shared state yet fall back to the state in memory
+	return prev, prev.Status != ""
+}
+
+// notifyAgentRecovered sends a recovery notification when an agent reported
+// offline is back online, prev is its state before the update
+func (s *Service) notifyAgentRecovered(agent *types.AgentInfo, prev agentstate.State) {
+	if prev.Status != types.AgentStatusOffline || agent.Status != types.AgentStatusOnline || !s.notifier.Enabled() ||
+		agent.Maintenance.Active(s.clock.Now()) {
+		return
+	}
+
+	var downtime time.Duration
+	if !prev.LastSeen.IsZero() {
+		downtime = agent.LastSeen.Sub(prev.LastSeen)
+	}
+	s.notifier.NotifyAgentOnline(agent, downtime)
+}
+
+// syncAgentStates applies the status and last seen time recorded by other
+// replicas to the agents in memory
+func (s *Service) syncAgentStates() error {
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	states, err := s.agentState.States(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	for id, state := range states {
+		agent, ok := s.agents[id]
+		if !ok {
+			continue
+		}
+		agent.Status = state.Status
+		if state.LastSeen.After(agent.LastSeen) {
+			agent.LastSeen = state.LastSeen
+		}
+	}
+
+	return nil
+}
+
+// checkAgentStatuses reports online agents offline once they missed their
+// offline threshold at the configured number of consecutive checks
 func (s *Service) checkAgentStatuses() {
 	s.agentsMu.Lock()
 	defer s.agentsMu.Unlock()
 
-	now := time.Now()
-	offlineThreshold := 5 * time.Minute
+	now := s.clock.Now()
+	cfg := s.agentMonitorConfig()
 
 	for id, agent := range s.agents {
-		if agent.Status == types.AgentStatusOnline && now.Sub(agent.LastSeen) > offlineThreshold {
-			// Update agent status
-			agent.Status = types.AgentStatusOffline
-			agent.UpdatedAt = now
-			// Update agent status in repository
-			if err := s.agentRepo.UpdateStatus(context.Background(), id, types.AgentStatusOffline); err != nil {
-				s.logger.Error("Failed to update agent offline status",
+		threshold, missed := cfg.Thresholds(id)
+		if agent.Status != types.AgentStatusOnline || now.Sub(agent.LastSeen) <= threshold {
+			delete(s.missedChecks, id)
+			continue
+		}
+
+		s.missedChecks[id]++
+		if s.missedChecks[id] < missed {
+			continue
+		}
+		delete(s.missedChecks, id)
+
+		// With shared state only the replica flipping the shared status reports the agent
+		if s.agentState != nil {
+			marked, err := s.agentState.MarkOffline(s.ctx, id, agent.LastSeen)
+			if err != nil {
+				s.logger.Error("Failed to mark agent offline",
 					zap.Error(err),
 					zap.String("agent_id", id))
 				continue
 			}
+			if !marked {
+				continue
+			}
+		}
 
-			// Update agent in memory
-			s.agents[id] = agent
+		// Update agent status
+		agent.Status = types.AgentStatusOffline
+		agent.UpdatedAt = now
+		// Update agent status in repository
+		if err := s.agentRepo.UpdateStatus(context.Background(), id, types.AgentStatusOffline); err != nil {
+			s.logger.Error("Failed to update agent offline status",
+				zap.Error(err),
+				zap.String("agent_id", id))
+			continue
+		}
 
-			if s.notifier != nil {
-				s.notifier.NotifyAgentOffline(agent)
-			}
+		// Update agent in memory
+		s.agents[id] = agent
+		s.annotateAgentStatus(agent, types.AgentStatusOnline)
+
+		if s.notifier.Enabled() && !agent.Maintenance.Active(now) {
+			s.notifier.NotifyAgentOffline(agent)
 		}
 	}
 }
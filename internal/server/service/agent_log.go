@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxAgentLogMessage is the longest stored log message, longer ones are cut
+const maxAgentLogMessage = 8 << 10
+
+// AgentLogService represents agent log service interface
+type AgentLogService interface {
+	SaveAgentLogs(ctx context.Context, agentID string, entries []*types.AgentLogEntry) error
+	QueryAgentLogs(ctx context.Context, filter *types.AgentLogFilter) ([]*types.AgentLogEntry, error)
+}
+
+// _ implements AgentLogService
+var _ AgentLogService = (*Service)(nil)
+
+// SaveAgentLogs stores log entries shipped by an agent
+func (s *Service) SaveAgentLogs(ctx context.Context, agentID string, entries []*types.AgentLogEntry) (err error) {
+	ctx, span := tracing.Start(ctx, "service.SaveAgentLogs",
+		attribute.String("agent_id", agentID),
+		attribute.Int("entries", len(entries)))
+	defer func() { tracing.End(span, err) }()
+
+	agent, err := s.GetAgent(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	now := s.clock.Now()
+	for _, entry := range entries {
+		entry.TenantID = agent.TenantID
+		entry.AgentID = agent.ID
+		entry.Level = strings.ToLower(entry.Level)
+		if !slices.Contains(types.AgentLogLevels, entry.Level) {
+			entry.Level = "info"
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = now
+		}
+		if len(entry.Message) > maxAgentLogMessage {
+			entry.Message = entry.Message[:maxAgentLogMessage]
+		}
+	}
+
+	if err := s.agentLogRepo.SaveBatch(ctx, entries); err != nil {
+		return fmt.Errorf("failed to save agent logs: %w", err)
+	}
+
+	return nil
+}
+
+// QueryAgentLogs returns log entries of an agent matching the filter, newest
+// first
+func (s *Service) QueryAgentLogs(ctx context.Context, filter *types.AgentLogFilter) ([]*types.AgentLogEntry, error) {
+	if _, err := s.GetAgent(ctx, filter.AgentID); err != nil {
+		return nil, err
+	}
+
+	// Apply default values to filter
+	if filter.EndTime.IsZero() {
+		filter.EndTime = s.clock.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-24 * time.Hour)
+	}
+	if filter.StartTime.After(filter.EndTime) {
+		return nil, fmt.Errorf("start time must be before end time")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	entries, err := s.agentLogRepo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent logs: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/clock"
+	"wameter/internal/types"
+)
+
+// TestAgentOfflineDetection tests that silent agents go offline once past
+// the offline threshold for the missed checks, notified unless in maintenance
+func TestAgentOfflineDetection(t *testing.T) {
+	testCases := []struct {
+		name        string
+		silence     time.Duration // Before the first check
+		checks      int           // A minute apart
+		missed      int
+		maintenance bool
+		status      types.AgentStatus
+		notified    int
+	}{
+		{
+			name:    "Within threshold",
+			silence: 4 * time.Minute,
+			checks:  1,
+			status:  types.AgentStatusOnline,
+		},
+		{
+			name:     "Past threshold",
+			silence:  6 * time.Minute,
+			checks:   1,
+			status:   types.AgentStatusOffline,
+			notified: 1,
+		},
+		{
+			name:     "Checked again while offline",
+			silence:  6 * time.Minute,
+			checks:   3,
+			status:   types.AgentStatusOffline,
+			notified: 1,
+		},
+		{
+			name:    "Fewer checks than missed checks",
+			silence: 6 * time.Minute,
+			checks:  2,
+			missed:  3,
+			status:  types.AgentStatusOnline,
+		},
+		{
+			name:     "Missed checks",
+			silence:  6 * time.Minute,
+			checks:   3,
+			missed:   3,
+			status:   types.AgentStatusOffline,
+			notified: 1,
+		},
+		{
+			name:        "In maintenance",
+			silence:     6 * time.Minute,
+			checks:      1,
+			maintenance: true,
+			status:      types.AgentStatusOffline,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Now().Truncate(time.Second))
+			svc, events := newTestService(t, WithClock(fake), func(s *Service) {
+				s.config.Monitor.MissedChecks = tc.missed
+			})
+			ctx := context.Background()
+
+			if tc.maintenance {
+				_, err := svc.SetMaintenance(ctx, "agent-1", &types.MaintenanceRequest{
+					Reason:   "upgrade",
+					Duration: time.Hour,
+				})
+				require.NoError(t, err)
+			}
+
+			fake.Advance(tc.silence)
+			for i := 0; i < tc.checks; i++ {
+				if i > 0 {
+					fake.Advance(time.Minute)
+				}
+				svc.checkAgentStatuses()
+			}
+
+			agent, err := svc.GetAgent(ctx, "agent-1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, agent.Status)
+
+			require.NoError(t, svc.Stop(ctx))
+
+			events.mu.Lock()
+			defer events.mu.Unlock()
+			assert.Equal(t, tc.notified, events.counts["agent.offline"])
+		})
+	}
+}
+
+// TestGetAgentStatuses tests that the status of agents holds the time of
+// their latest report, and that filters apply
+func TestGetAgentStatuses(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	require.NoError(t, svc.RegisterAgent(ctx, &types.AgentInfo{
+		ID:       "agent-2",
+		Hostname: "host-2",
+	}))
+	at := time.Now().Add(-time.Minute).Truncate(time.Second).UTC()
+	require.NoError(t, svc.SaveMetrics(ctx, testReport(at, 0, 0)))
+
+	statuses, err := svc.GetAgentStatuses(ctx, &types.AgentFilter{})
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+
+	require.NotNil(t, statuses["agent-1"].LatestSample)
+	assert.True(t, at.Equal(*statuses["agent-1"].LatestSample))
+	assert.Equal(t, types.AgentStatusOnline, statuses["agent-1"].Status)
+	assert.Nil(t, statuses["agent-2"].LatestSample)
+
+	statuses, err = svc.GetAgentStatuses(ctx, &types.AgentFilter{Hostname: "HOST-2"})
+	require.NoError(t, err)
+	assert.Len(t, statuses, 1)
+	assert.Contains(t, statuses, "agent-2")
+}
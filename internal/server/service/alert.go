@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"wameter/internal/notify"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// _ implements notify.TelegramActions
+var _ notify.TelegramActions = (*Service)(nil)
+
+// AcknowledgeAlert records the acknowledgement of an alert notification as
+// an annotation of its agent
+func (s *Service) AcknowledgeAlert(ctx context.Context, ack *types.AlertAcknowledgement) error {
+	if _, err := s.agentScope(ctx, ack.AgentID); err != nil {
+		return err
+	}
+
+	// The first line of the notification is its headline
+	headline, _, _ := strings.Cut(ack.Summary, "\n")
+	title := fmt.Sprintf("%s acknowledged by %s", ack.Alert, ack.Actor)
+	if headline != "" {
+		title = fmt.Sprintf("%s acknowledged by %s", strings.TrimSpace(headline), ack.Actor)
+	}
+	if len(title) > 255 {
+		title = title[:255]
+	}
+
+	a := &types.Annotation{
+		Type:      types.AnnotationAcknowledgement,
+		AgentID:   ack.AgentID,
+		Time:      ack.Time,
+		Title:     title,
+		Text:      ack.Summary,
+		Tags:      []string{ack.Alert},
+		CreatedBy: ack.Actor,
+		CreatedAt: s.clock.Now(),
+	}
+	a.TenantID = s.agentTenant(ack.AgentID)
+	if err := s.annotationRepo.Save(ctx, a); err != nil {
+		return fmt.Errorf("failed to save acknowledgement: %w", err)
+	}
+
+	s.logger.Info("Alert acknowledged",
+		zap.String("agent_id", ack.AgentID),
+		zap.String("alert", ack.Alert),
+		zap.String("actor", ack.Actor))
+
+	return nil
+}
+
+// SilenceAgent puts an agent into maintenance for d, suppressing its offline
+// and threshold notifications
+func (s *Service) SilenceAgent(ctx context.Context, agentID string, d time.Duration, actor string) error {
+	_, err := s.SetMaintenance(ctx, agentID, &types.MaintenanceRequest{
+		Duration: d,
+		Reason:   "Silenced by " + actor,
+	})
+	return err
+}
+
+// HandleTelegramUpdate handles a Telegram update received by webhook, e.g.
+// a button press of an alert
+func (s *Service) HandleTelegramUpdate(ctx context.Context, secret string, body []byte) error {
+	return s.notifier.HandleTelegramUpdate(ctx, secret, body)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/server/rules"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// alertRuleRefresh is how long the rules declared through the API are
+// cached, changes made on other replicas apply after it
+const alertRuleRefresh = time.Minute
+
+// alertRuleSet caches the rules compiled from a configuration and the
+// rules declared through the API
+type alertRuleSet struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	declared []*types.AlertRule
+	loadedAt time.Time
+	rules    []*rules.Rule
+}
+
+// GetAlertRules returns the rules of the configuration followed by the
+// ones declared through the API
+func (s *Service) GetAlertRules(ctx context.Context) ([]*types.AlertRule, error) {
+	declared, err := s.alertRuleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.GetConfig()
+	result := make([]*types.AlertRule, 0, len(cfg.AlertRules)+len(declared))
+	names := make(map[string]bool, len(cfg.AlertRules))
+	for i := range cfg.AlertRules {
+		names[cfg.AlertRules[i].Name] = true
+		result = append(result, configAlertRule(&cfg.AlertRules[i]))
+	}
+	for _, r := range declared {
+		if !names[r.Name] {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// GetAlertRule returns a rule of the configuration or declared through the API
+func (s *Service) GetAlertRule(ctx context.Context, name string) (*types.AlertRule, error) {
+	if r := findConfigAlertRule(s.GetConfig(), name); r != nil {
+		return configAlertRule(r), nil
+	}
+	return s.alertRuleRepo.Get(ctx, name)
+}
+
+// ApplyAlertRule creates or replaces a rule declared through the API, rules
+// of the configuration can only be changed there
+func (s *Service) ApplyAlertRule(ctx context.Context, rule *types.AlertRule) (*types.AlertRule, error) {
+	if rule.Severity == "" {
+		rule.Severity = "warning"
+	}
+	cfg := config.AlertRuleConfig{
+		Name:      rule.Name,
+		Condition: rule.Condition,
+		Message:   rule.Message,
+		Severity:  rule.Severity,
+		Labels:    rule.Labels,
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrInvalidAlertRule, err)
+	}
+	if findConfigAlertRule(s.GetConfig(), rule.Name) != nil {
+		return nil, types.ErrAlertRuleConfigured
+	}
+
+	rule.Source = ""
+	rule.UpdatedAt = nil
+	if err := s.alertRuleRepo.Save(ctx, rule); err != nil {
+		return nil, err
+	}
+	s.invalidateAlertRules()
+
+	s.logger.Info("Alert rule applied", zap.String("rule", rule.Name))
+	return s.alertRuleRepo.Get(ctx, rule.Name)
+}
+
+// DeleteAlertRule deletes a rule declared through the API
+func (s *Service) DeleteAlertRule(ctx context.Context, name string) error {
+	if findConfigAlertRule(s.GetConfig(), name) != nil {
+		return types.ErrAlertRuleConfigured
+	}
+	if err := s.alertRuleRepo.Delete(ctx, name); err != nil {
+		return err
+	}
+	s.invalidateAlertRules()
+
+	s.logger.Info("Alert rule deleted", zap.String("rule", name))
+	return nil
+}
+
+// findConfigAlertRule returns the rule of the configuration with the name
+func findConfigAlertRule(cfg *config.Config, name string) *config.AlertRuleConfig {
+	for i := range cfg.AlertRules {
+		if cfg.AlertRules[i].Name == name {
+			return &cfg.AlertRules[i]
+		}
+	}
+	return nil
+}
+
+// configAlertRule converts a rule of the configuration
+func configAlertRule(r *config.AlertRuleConfig) *types.AlertRule {
+	return &types.AlertRule{
+		Name:      r.Name,
+		Condition: r.Condition,
+		Message:   r.Message,
+		Severity:  r.Severity,
+		Labels:    r.Labels,
+		Source:    types.AlertRuleSourceConfig,
+	}
+}
+
+// invalidateAlertRules makes the next evaluation load and compile the rules again
+func (s *Service) invalidateAlertRules() {
+	s.alertRules.mu.Lock()
+	s.alertRules.cfg = nil
+	s.alertRules.loadedAt = time.Time{}
+	s.alertRules.mu.Unlock()
+}
+
+// compiledAlertRules returns the alert rules of the current configuration
+// and the ones declared through the API, compiled again after the
+// configuration is reloaded or the declared rules change
+func (s *Service) compiledAlertRules() []*rules.Rule {
+	cfg := s.GetConfig()
+	now := s.clock.Now()
+
+	s.alertRules.mu.Lock()
+	defer s.alertRules.mu.Unlock()
+
+	stale := now.Sub(s.alertRules.loadedAt) >= alertRuleRefresh
+	if s.alertRules.cfg == cfg && !stale {
+		return s.alertRules.rules
+	}
+
+	if stale {
+		// Reports queued when stopping are still evaluated after s.ctx is canceled
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		declared, err := s.alertRuleRepo.List(ctx)
+		cancel()
+		if err != nil {
+			// Keep the rules loaded last, retried after the refresh period
+			s.logger.Error("Failed to load alert rules", zap.Error(err))
+		} else {
+			s.alertRules.declared = declared
+		}
+		s.alertRules.loadedAt = now
+	}
+
+	configured := make([]config.AlertRuleConfig, 0, len(cfg.AlertRules)+len(s.alertRules.declared))
+	configured = append(configured, cfg.AlertRules...)
+	for _, r := range s.alertRules.declared {
+		if findConfigAlertRule(cfg, r.Name) == nil {
+			configured = append(configured, config.AlertRuleConfig{
+				Name:      r.Name,
+				Condition: r.Condition,
+				Message:   r.Message,
+				Severity:  r.Severity,
+				Labels:    r.Labels,
+			})
+		}
+	}
+
+	compiled := make([]*rules.Rule, 0, len(configured))
+	for i := range configured {
+		r, err := configured[i].Compile()
+		if err != nil {
+			// Validated rules compile, this guards updates made without
+			// validation
+			s.logger.Error("Failed to compile alert rule",
+				zap.String("rule", configured[i].Name),
+				zap.Error(err))
+			continue
+		}
+		compiled = append(compiled, r)
+	}
+	s.alertRules.cfg = cfg
+	s.alertRules.rules = compiled
+	return compiled
+}
+
+// evaluateAlertRules evaluates the alert rules on metrics, alerting and
+// annotating the rules that start firing
+func (s *Service) evaluateAlertRules(data *types.MetricsData) {
+	compiled := s.compiledAlertRules()
+	if len(compiled) == 0 {
+		return
+	}
+
+	ctx := rules.NewContext(data, s.agentTags(data.AgentID))
+	for _, r := range compiled {
+		res, err := r.Eval(ctx)
+		if err != nil {
+			// Rules often refer to interfaces or tags only some agents have
+			s.logger.Debug("Failed to evaluate alert rule",
+				zap.String("rule", r.Name),
+				zap.String("agent_id", data.AgentID),
+				zap.Error(err))
+		}
+
+		if !s.ruleStarted(data.AgentID, r.Name, res != nil) {
+			continue
+		}
+
+		alert := &types.RuleAlert{
+			Rule:     r.Name,
+			Severity: r.Severity,
+			AgentID:  data.AgentID,
+			Hostname: data.Hostname,
+			Message:  res.Message,
+			Labels:   res.Labels,
+			Time:     data.Timestamp,
+		}
+		if alert.Message == "" {
+			alert.Message = fmt.Sprintf("Alert rule %s fired on %s", r.Name, data.AgentID)
+		}
+		if alert.Time.IsZero() {
+			alert.Time = s.clock.Now()
+		}
+
+		s.notifier.NotifyRuleAlert(alert)
+		s.annotateRuleAlert(alert)
+	}
+}
+
+// ruleStarted records whether a rule is firing on an agent and reports
+// whether it just started
+func (s *Service) ruleStarted(agentID, rule string, firing bool) bool {
+	key := agentID + "/rule/" + rule
+
+	s.firingMu.Lock()
+	defer s.firingMu.Unlock()
+
+	started := firing && !s.firing[key]
+	if firing {
+		s.firing[key] = true
+	} else {
+		delete(s.firing, key)
+	}
+	return started
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// TestDeclaredAlertRules tests that rules declared through the API are
+// listed after the configured ones and evaluated on reports
+func TestDeclaredAlertRules(t *testing.T) {
+	svc, events := newTestService(t, func(s *Service) {
+		s.config.AlertRules = []config.AlertRuleConfig{
+			{Name: "configured", Condition: `agent.id == "none"`, Severity: "info"},
+		}
+	})
+	ctx := context.Background()
+
+	_, err := svc.ApplyAlertRule(ctx, &types.AlertRule{Name: "bad", Condition: "unknown.value > 1"})
+	assert.ErrorIs(t, err, types.ErrInvalidAlertRule)
+	_, err = svc.ApplyAlertRule(ctx, &types.AlertRule{Name: "configured", Condition: "true"})
+	assert.ErrorIs(t, err, types.ErrAlertRuleConfigured)
+
+	// Compiled before the rule is declared, the cache is invalidated
+	assert.Len(t, svc.compiledAlertRules(), 1)
+
+	rule, err := svc.ApplyAlertRule(ctx, &types.AlertRule{Name: "agent-1", Condition: `agent.id == "agent-1"`})
+	require.NoError(t, err)
+	assert.Equal(t, "warning", rule.Severity)
+	assert.Equal(t, types.AlertRuleSourceAPI, rule.Source)
+	assert.NotNil(t, rule.UpdatedAt)
+
+	rules, err := svc.GetAlertRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "configured", rules[0].Name)
+	assert.Equal(t, types.AlertRuleSourceConfig, rules[0].Source)
+	assert.Equal(t, "agent-1", rules[1].Name)
+
+	// Rules are evaluated in the background until the service stops
+	require.NoError(t, svc.SaveMetrics(ctx, testReport(time.Now(), 0, 0)))
+	require.NoError(t, svc.Stop(ctx))
+
+	require.NoError(t, svc.DeleteAlertRule(ctx, "agent-1"))
+	assert.ErrorIs(t, svc.DeleteAlertRule(ctx, "agent-1"), types.ErrAlertRuleNotFound)
+	assert.ErrorIs(t, svc.DeleteAlertRule(ctx, "configured"), types.ErrAlertRuleConfigured)
+	_, err = svc.GetAlertRule(ctx, "agent-1")
+	assert.ErrorIs(t, err, types.ErrAlertRuleNotFound)
+	assert.Len(t, svc.compiledAlertRules(), 1)
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	assert.Equal(t, 1, events.counts["alert.rule"])
+}
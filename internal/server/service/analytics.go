@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"wameter/internal/types"
+)
+
+// AnalyticsService represents analytics service interface
+type AnalyticsService interface {
+	GetTop(ctx context.Context, query *types.TopQuery) (*types.TopResult, error)
+}
+
+// _ implements AnalyticsService
+var _ AnalyticsService = (*Service)(nil)
+
+// GetTop ranks agents or interfaces by a metric over the query range
+func (s *Service) GetTop(ctx context.Context, query *types.TopQuery) (*types.TopResult, error) {
+	var entries []*types.TopEntry
+	switch query.Metric {
+	case types.TopMetricBandwidth, types.TopMetricErrorRate:
+		usage, err := s.metricsRepo.GetInterfaceUsage(ctx, query.StartTime, query.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		entries = rankUsage(usage, query.Scope, query.Metric)
+	case types.TopMetricIPChanges:
+		counts, err := s.ipChangeRepo.CountChanges(ctx, query.StartTime, query.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		entries = rankIPChanges(counts, query.Scope)
+	default:
+		return nil, fmt.Errorf("unsupported metric: %s", query.Metric)
+	}
+
+	// Highest first, ties in a stable order
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		return a.Interface < b.Interface
+	})
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+
+	s.agentsMu.RLock()
+	for i, entry := range entries {
+		entry.Rank = i + 1
+		if agent, ok := s.agents[entry.AgentID]; ok {
+			entry.Hostname = agent.Hostname
+		}
+	}
+	s.agentsMu.RUnlock()
+
+	if entries == nil {
+		entries = []*types.TopEntry{}
+	}
+
+	return &types.TopResult{
+		Scope:     query.Scope,
+		Metric:    query.Metric,
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Entries:   entries,
+	}, nil
+}
+
+// rankUsage computes the bandwidth or error rate of interfaces, or of agents
+// from the sum of their interfaces. Error rates need packets to be counted.
+func rankUsage(usage []*types.InterfaceUsage, scope types.TopScope, metric types.TopMetric) []*types.TopEntry {
+	type total struct {
+		bandwidth       float64
+		errors, packets uint64
+	}
+
+	totals := make(map[[2]string]*total)
+	var keys [][2]string
+	for _, u := range usage {
+		key := [2]string{u.AgentID, u.Interface}
+		if scope == types.TopScopeAgents {
+			key[1] = ""
+		}
+		t, ok := totals[key]
+		if !ok {
+			t = &total{}
+			totals[key] = t
+			keys = append(keys, key)
+		}
+		t.bandwidth += u.Bandwidth
+		t.errors += u.Errors
+		t.packets += u.Packets
+	}
+
+	entries := make([]*types.TopEntry, 0, len(keys))
+	for _, key := range keys {
+		t := totals[key]
+		entry := &types.TopEntry{AgentID: key[0], Interface: key[1]}
+		switch metric {
+		case types.TopMetricBandwidth:
+			entry.Value = t.bandwidth
+		case types.TopMetricErrorRate:
+			if t.packets == 0 {
+				continue
+			}
+			entry.Value = float64(t.errors) / float64(t.packets)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// rankIPChanges counts IP changes of interfaces, or of agents including
+// external IP changes
+func rankIPChanges(counts []*types.IPChangeCount, scope types.TopScope) []*types.TopEntry {
+	totals := make(map[[2]string]int64)
+	var keys [][2]string
+	for _, c := range counts {
+		key := [2]string{c.AgentID, c.Interface}
+		if scope == types.TopScopeAgents {
+			key[1] = ""
+		} else if c.Interface == "" {
+			continue
+		}
+		if _, ok := totals[key]; !ok {
+			keys = append(keys, key)
+		}
+		totals[key] += c.Count
+	}
+
+	entries := make([]*types.TopEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, &types.TopEntry{
+			AgentID:   key[0],
+			Interface: key[1],
+			Value:     float64(totals[key]),
+		})
+	}
+	return entries
+}
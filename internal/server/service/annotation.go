@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"wameter/internal/server/rbac"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// AnnotationService represents annotation service interface
+type AnnotationService interface {
+	CreateAnnotation(ctx context.Context, a *types.Annotation) error
+	GetAnnotations(ctx context.Context, filter *types.AnnotationFilter) ([]*types.Annotation, error)
+}
+
+// _ implements AnnotationService
+var _ AnnotationService = (*Service)(nil)
+
+// CreateAnnotation stores an annotation created through the API, it is
+// global when it has no agent
+func (s *Service) CreateAnnotation(ctx context.Context, a *types.Annotation) error {
+	if a.Type == "" {
+		a.Type = types.AnnotationEvent
+	}
+	if a.Time.IsZero() {
+		a.Time = s.clock.Now()
+	}
+	if err := a.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidAnnotation, err)
+	}
+
+	// Agent restricted credentials may only annotate their agents
+	if !rbac.AllowsAgent(ctx, a.AgentID) {
+		return types.ErrForbidden
+	}
+	a.TenantID = ""
+	if a.AgentID != "" {
+		agent, err := s.GetAgent(ctx, a.AgentID)
+		if err != nil {
+			return err
+		}
+		a.TenantID = agent.TenantID
+	}
+
+	a.CreatedBy = ""
+	if p, ok := rbac.FromContext(ctx); ok {
+		a.CreatedBy = p.Actor
+	}
+	a.CreatedAt = s.clock.Now()
+
+	if err := s.annotationRepo.Save(ctx, a); err != nil {
+		return fmt.Errorf("failed to save annotation: %w", err)
+	}
+
+	s.logger.Info("Annotation created",
+		zap.Int64("id", a.ID),
+		zap.String("type", string(a.Type)),
+		zap.String("agent_id", a.AgentID))
+
+	return nil
+}
+
+// GetAnnotations returns the annotations matching the filter, of the last
+// 24 hours by default
+func (s *Service) GetAnnotations(ctx context.Context, filter *types.AnnotationFilter) ([]*types.Annotation, error) {
+	if filter.EndTime.IsZero() {
+		filter.EndTime = s.clock.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-24 * time.Hour)
+	}
+	if filter.StartTime.After(filter.EndTime) {
+		return nil, fmt.Errorf("%w: start time must be before end time", types.ErrInvalidAnnotation)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	annotations, err := s.annotationRepo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get annotations: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// alertTitles are the annotation titles of interface alerts
+var alertTitles = map[string]string{
+	"network_errors":   "Network errors",
+	"high_utilization": "High utilization",
+}
+
+// annotate stores an annotation of a tenant in the background, failures
+// are only logged
+func (s *Service) annotate(tenantID string, a *types.Annotation) {
+	a.TenantID = tenantID
+	s.goBackground(func() {
+		if err := s.annotationRepo.Save(s.ctx, a); err != nil {
+			s.logger.Warn("Failed to save annotation",
+				zap.Error(err),
+				zap.String("type", string(a.Type)),
+				zap.String("agent_id", a.AgentID))
+		}
+	})
+}
+
+// annotateIPChange annotates a tracked IP change
+func (s *Service) annotateIPChange(tenantID, agentID string, change *types.IPChange) {
+	text := fmt.Sprintf("%s: %s -> %s", change.Version,
+		strings.Join(change.OldAddrs, ", "), strings.Join(change.NewAddrs, ", "))
+	if change.Reason != "" {
+		text += " (" + change.Reason + ")"
+	}
+
+	tags := []string{string(change.Action), string(change.Version)}
+	if change.InterfaceName != "" {
+		tags = append(tags, change.InterfaceName)
+	}
+	if change.IsExternal {
+		tags = append(tags, "external")
+	}
+
+	s.annotate(tenantID, &types.Annotation{
+		Type:    types.AnnotationIPChange,
+		AgentID: agentID,
+		Time:    change.Timestamp,
+		Title:   fmt.Sprintf("IP %s on %s/%s", change.Action, agentID, change.InterfaceName),
+		Text:    text,
+		Tags:    tags,
+	})
+}
+
+// annotateAgentStatus annotates an agent status transition
+func (s *Service) annotateAgentStatus(agent *types.AgentInfo, from types.AgentStatus) {
+	s.annotate(agent.TenantID, &types.Annotation{
+		Type:    types.AnnotationAgentStatus,
+		AgentID: agent.ID,
+		Time:    agent.UpdatedAt,
+		Title:   fmt.Sprintf("Agent %s is %s", agent.ID, agent.Status),
+		Text:    fmt.Sprintf("%s was %s", agent.Hostname, from),
+		Tags:    []string{string(agent.Status)},
+	})
+}
+
+// annotateInterfaceAlert annotates an interface alert that started firing
+func (s *Service) annotateInterfaceAlert(data *types.MetricsData, iface *types.InterfaceInfo, alert string) {
+	s.annotate(s.agentTenant(data.AgentID), &types.Annotation{
+		Type:    types.AnnotationAlert,
+		AgentID: data.AgentID,
+		Time:    data.Timestamp,
+		Title:   fmt.Sprintf("%s on %s/%s", alertTitles[alert], data.AgentID, iface.Name),
+		Tags:    []string{alert, iface.Name},
+	})
+}
+
+// annotateRuleAlert annotates a custom alert rule starting to fire
+func (s *Service) annotateRuleAlert(alert *types.RuleAlert) {
+	s.annotate(s.agentTenant(alert.AgentID), &types.Annotation{
+		Type:    types.AnnotationAlert,
+		AgentID: alert.AgentID,
+		Time:    alert.Time,
+		Title:   fmt.Sprintf("Alert %s on %s", alert.Rule, alert.AgentID),
+		Text:    alert.Message,
+		Tags:    []string{"rule_alert", alert.Rule, alert.Severity},
+	})
+}
+
+// annotateMissingAgent annotates an expected inventory alert, the inventory
+// is not tenant scoped so it is annotated in the default tenant
+func (s *Service) annotateMissingAgent(alert *types.MissingAgentAlert) {
+	s.annotate(tenant.Default, &types.Annotation{
+		Type:    types.AnnotationAlert,
+		AgentID: alert.AgentID,
+		Time:    s.clock.Now(),
+		Title:   alert.Message(),
+		Tags:    []string{"agent_missing", string(alert.State)},
+	})
+}
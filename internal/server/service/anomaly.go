@@ -0,0 +1,265 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+)
+
+// anomalyIdleTimeout is how long baselines of interfaces that stopped
+// reporting are kept
+const anomalyIdleTimeout = 7 * 24 * time.Hour
+
+// anomalyDetector learns the baselines of interface metrics and reports
+// samples deviating from them
+type anomalyDetector struct {
+	baselines map[string]*anomalyBaseline // Agent ID/interface/metric
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// anomalyBaseline represents the baseline of a metric of an interface, one
+// EWMA per hour of the season
+type anomalyBaseline struct {
+	buckets []ewmaStats
+	since   time.Time // First sample
+	updated time.Time
+	// Previous sample of counter metrics, whose rates are learned
+	counter   uint64
+	counterAt time.Time
+}
+
+// ewmaStats represents an exponentially weighted mean and variance
+type ewmaStats struct {
+	mean     float64
+	variance float64
+	samples  int
+}
+
+// update adds a sample with smoothing factor alpha
+func (e *ewmaStats) update(x, alpha float64) {
+	if e.samples == 0 {
+		e.mean = x
+		e.samples = 1
+		return
+	}
+	diff := x - e.mean
+	incr := alpha * diff
+	e.mean += incr
+	e.variance = (1 - alpha) * (e.variance + diff*incr)
+	e.samples++
+}
+
+// anomalyResult represents a sample of a metric checked against its baseline
+type anomalyResult struct {
+	Metric    string
+	Value     float64
+	Baseline  float64
+	Deviation float64 // Standard deviations from the baseline mean
+	Warm      bool    // The baseline is learned, the sample was checked
+	Anomalous bool
+}
+
+// newAnomalyDetector creates new anomaly detector
+func newAnomalyDetector() *anomalyDetector {
+	return &anomalyDetector{baselines: make(map[string]*anomalyBaseline)}
+}
+
+// observe checks the metrics of an interface against their baselines, then
+// learns them. Metrics without a value, such as the rates of reset counters
+// or of a first counter sample, are not returned.
+func (d *anomalyDetector) observe(cfg *config.AnomalyConfig, agentID string, iface *types.InterfaceInfo, at time.Time) []anomalyResult {
+	stats := iface.Statistics
+	if stats == nil || stats.CounterReset {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	bucket := seasonBucket(at.In(loc), cfg.Buckets())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prune(at)
+
+	var results []anomalyResult
+	for _, metric := range cfg.Metrics {
+		key := agentID + "/" + iface.Name + "/" + metric
+		b := d.baselines[key]
+		if b == nil || len(b.buckets) != cfg.Buckets() {
+			b = &anomalyBaseline{buckets: make([]ewmaStats, cfg.Buckets()), since: at}
+			d.baselines[key] = b
+		}
+		b.updated = at
+
+		value, ok := b.value(metric, stats, at)
+		if !ok {
+			continue
+		}
+
+		e := &b.buckets[bucket]
+		res := anomalyResult{Metric: metric, Value: value, Baseline: e.mean}
+		res.Warm = e.samples >= cfg.MinSamples && at.Sub(b.since) >= cfg.WarmUp
+		if res.Warm {
+			res.Deviation, res.Anomalous = deviation(cfg, e, value)
+		}
+		e.update(value, cfg.Alpha)
+		results = append(results, res)
+	}
+	return results
+}
+
+// value returns the sample of a metric, counter metrics are turned into
+// rates against the previous sample
+func (b *anomalyBaseline) value(metric string, stats *types.InterfaceStats, at time.Time) (float64, bool) {
+	var counter uint64
+	switch metric {
+	case "errors_rate":
+		counter = stats.RxErrors + stats.TxErrors
+	case "drops_rate":
+		counter = stats.RxDropped + stats.TxDropped
+	default:
+		return stats.Metric(metric)
+	}
+
+	prev, prevAt := b.counter, b.counterAt
+	b.counter, b.counterAt = counter, at
+	secs := at.Sub(prevAt).Seconds()
+	if prevAt.IsZero() || counter < prev || secs <= 0 {
+		return 0, false
+	}
+	return float64(counter-prev) / secs, true
+}
+
+// deviation returns the deviation of x from a baseline in standard
+// deviations and whether it is anomalous. The standard deviation has a
+// floor so flat baselines, e.g. of errors, do not alert on any change.
+func deviation(cfg *config.AnomalyConfig, e *ewmaStats, x float64) (float64, bool) {
+	diff := x - e.mean
+	std := max(math.Sqrt(e.variance), 0.05*math.Abs(e.mean), 1)
+	z := diff / std
+
+	switch cfg.Direction {
+	case "up":
+		if diff <= 0 {
+			return z, false
+		}
+	case "down":
+		if diff >= 0 {
+			return z, false
+		}
+	}
+	return z, math.Abs(z) >= cfg.Sensitivity && math.Abs(diff) >= cfg.MinChange*math.Abs(e.mean)
+}
+
+// prune drops baselines of interfaces that stopped reporting, at most hourly
+func (d *anomalyDetector) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < time.Hour {
+		return
+	}
+	d.lastPrune = now
+	for key, b := range d.baselines {
+		if now.Sub(b.updated) > anomalyIdleTimeout {
+			delete(d.baselines, key)
+		}
+	}
+}
+
+// forget drops the baselines of an agent
+func (d *anomalyDetector) forget(agentID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key := range d.baselines {
+		if strings.HasPrefix(key, agentID+"/") {
+			delete(d.baselines, key)
+		}
+	}
+}
+
+// seasonBucket returns the hourly baseline of a time, hours of the week
+// start on Sunday
+func seasonBucket(t time.Time, buckets int) int {
+	switch buckets {
+	case 24:
+		return t.Hour()
+	case 7 * 24:
+		return int(t.Weekday())*24 + t.Hour()
+	}
+	return 0
+}
+
+// formatAnomalyValue formats a sample of a metric
+func formatAnomalyValue(metric string, v float64) string {
+	if strings.HasSuffix(metric, "_bytes_rate") {
+		return utils.FormatBytesRate(v) + "/s"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + "/s"
+}
+
+// detectAnomalies checks the interfaces of metrics against their
+// baselines, alerting anomalies when they start. It returns the interfaces
+// whose utilization and error baselines have warmed up.
+func (s *Service) detectAnomalies(data *types.MetricsData) (utilizationWarm, errorsWarm map[string]bool) {
+	cfg := s.GetConfig().Anomaly
+	if !cfg.Enabled || data.Metrics.Network == nil {
+		return nil, nil
+	}
+
+	at := data.CollectedAt
+	if at.IsZero() {
+		at = s.clock.Now()
+	}
+
+	utilizationWarm, errorsWarm = make(map[string]bool), make(map[string]bool)
+	for _, iface := range data.Metrics.Network.Interfaces {
+		name := iface.Name
+		if !cfg.Matches(name) {
+			continue
+		}
+		for _, res := range s.anomalies.observe(&cfg, data.AgentID, iface, at) {
+			if !res.Warm {
+				continue
+			}
+			switch res.Metric {
+			case "rx_bytes_rate", "tx_bytes_rate":
+				utilizationWarm[name] = true
+			case "errors_rate":
+				errorsWarm[name] = true
+			}
+
+			rule := "anomaly_" + res.Metric
+			if !s.ruleStarted(data.AgentID, name+"/"+rule, res.Anomalous) {
+				continue
+			}
+			alert := &types.RuleAlert{
+				Rule:     rule,
+				Severity: cfg.Severity,
+				AgentID:  data.AgentID,
+				Hostname: data.Hostname,
+				Message: fmt.Sprintf("%s of %s is %s against a baseline of %s (%.1f standard deviations)",
+					res.Metric, name, formatAnomalyValue(res.Metric, res.Value),
+					formatAnomalyValue(res.Metric, res.Baseline), res.Deviation),
+				Labels: map[string]string{
+					"interface": name,
+					"metric":    res.Metric,
+					"value":     strconv.FormatFloat(res.Value, 'f', 2, 64),
+					"baseline":  strconv.FormatFloat(res.Baseline, 'f', 2, 64),
+					"deviation": strconv.FormatFloat(res.Deviation, 'f', 1, 64),
+				},
+				Time: at,
+			}
+			s.notifier.NotifyRuleAlert(alert)
+			s.annotateRuleAlert(alert)
+		}
+	}
+	return utilizationWarm, errorsWarm
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+)
+
+// AuditService represents audit log service interface
+type AuditService interface {
+	RecordAudit(ctx context.Context, entry *types.AuditEntry) error
+	QueryAudit(ctx context.Context, filter *types.AuditFilter) ([]*types.AuditEntry, error)
+}
+
+// _ implements AuditService
+var _ AuditService = (*Service)(nil)
+
+// RecordAudit records an audit entry
+func (s *Service) RecordAudit(ctx context.Context, entry *types.AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = s.clock.Now()
+	}
+
+	if err := s.auditRepo.Save(ctx, entry); err != nil {
+		return fmt.Errorf("failed to save audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// QueryAudit returns audit entries matching the filter, newest first
+func (s *Service) QueryAudit(ctx context.Context, filter *types.AuditFilter) ([]*types.AuditEntry, error) {
+	// Apply default values to filter
+	if filter == nil {
+		filter = &types.AuditFilter{}
+	}
+
+	if filter.EndTime.IsZero() {
+		filter.EndTime = s.clock.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-7 * 24 * time.Hour)
+	}
+	if filter.StartTime.After(filter.EndTime) {
+		return nil, fmt.Errorf("start time must be before end time")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	entries, err := s.auditRepo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"wameter/internal/server/backup"
+	"wameter/internal/server/tenant"
+
+	"go.uber.org/zap"
+)
+
+// BackupService represents backup service interface
+type BackupService interface {
+	Backup(ctx context.Context, opts backup.Options) (io.ReadCloser, error)
+}
+
+// _ implements BackupService
+var _ BackupService = (*Service)(nil)
+
+// Backup streams an archive of the server data, it is written while read
+func (s *Service) Backup(ctx context.Context, opts backup.Options) (io.ReadCloser, error) {
+	// Backups cover all tenants, admin requests are scoped to the default one
+	ctx = tenant.WithContext(ctx, "")
+	cfg := s.GetConfig()
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		bw := bufio.NewWriter(pw)
+		res, err := backup.Export(ctx, s.db, cfg, bw, opts, s.logger)
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			s.logger.Error("Failed to back up server data", zap.Error(err))
+		} else {
+			s.logger.Info("Backed up server data",
+				zap.Int("agents", res.Agents),
+				zap.Int("metrics_count", res.Metrics),
+				zap.Int("ip_changes", res.IPChanges))
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// Cache keys of metrics reads, entries are per agent and dropped by
+// invalidateMetrics when the agent stores new metrics
+const (
+	cacheLatestMetrics  = "metrics:latest:"
+	cacheMetricsSummary = "metrics:summary:"
+)
+
+// cachedAgent reports whether reads of agentID may be served from the
+// cache. Entries are shared by all readers of the agent, so only agents of
+// the tenant of ctx are served, other reads go to the database.
+func (s *Service) cachedAgent(ctx context.Context, agentID string) bool {
+	if s.cache == nil {
+		return false
+	}
+
+	s.agentsMu.RLock()
+	agent, ok := s.agents[agentID]
+	s.agentsMu.RUnlock()
+
+	return ok && tenant.Allows(ctx, agent.TenantID)
+}
+
+// loadCached returns the cached value of key, or loads and caches it.
+// Cache failures fall back to load, errors of load are not cached.
+func loadCached[T any](ctx context.Context, s *Service, key string, load func() (*T, error)) (*T, error) {
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		s.logger.Warn("Failed to read cache", zap.Error(err), zap.String("key", key))
+	}
+	if ok {
+		value := new(T)
+		if err := json.Unmarshal(data, value); err == nil {
+			return value, nil
+		}
+		s.logger.Warn("Ignoring invalid cache entry", zap.String("key", key))
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(value); err == nil {
+		if err := s.cache.Set(ctx, key, data, s.config.Cache.TTL); err != nil {
+			s.logger.Warn("Failed to write cache", zap.Error(err), zap.String("key", key))
+		}
+	}
+	return value, nil
+}
+
+// invalidateMetrics drops the cached metrics reads of agents
+func (s *Service) invalidateMetrics(ctx context.Context, agentIDs ...string) {
+	if s.cache == nil || len(agentIDs) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, 2*len(agentIDs))
+	for _, id := range agentIDs {
+		keys = append(keys, cacheLatestMetrics+id, cacheMetricsSummary+id)
+	}
+	// Drop entries even when the request was canceled after the write
+	if err := s.cache.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		s.logger.Error("Failed to invalidate metrics cache",
+			zap.Error(err),
+			zap.Strings("agent_ids", agentIDs))
+	}
+}
+
+// metricsAgents returns the distinct agents of metrics
+func metricsAgents(metrics []*types.MetricsData) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, m := range metrics {
+		if !seen[m.AgentID] {
+			seen[m.AgentID] = true
+			ids = append(ids, m.AgentID)
+		}
+	}
+	return ids
+}
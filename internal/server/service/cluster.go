@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// leaderLease is the lease held by the replica running scheduled jobs
+const leaderLease = "scheduler"
+
+// resignTimeout bounds releasing the leader lease on shutdown
+const resignTimeout = 5 * time.Second
+
+// isLeader reports whether this replica runs scheduled jobs, a replica
+// without clustering always does
+func (s *Service) isLeader() bool {
+	if !s.config.Cluster.Enabled {
+		return true
+	}
+	return s.clock.Now().UnixNano() < s.leaderUntil.Load()
+}
+
+// startLeaderElection keeps trying to take or renew the leader lease
+func (s *Service) startLeaderElection() {
+	interval := s.config.Cluster.RenewInterval
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.registerWorker("leader_election", interval)
+	defer s.unregisterWorker("leader_election")
+
+	s.logger.Info("Leader election started", zap.String("node_id", s.nodeID))
+	s.campaign()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.resign()
+			s.logger.Info("Leader election stopped")
+			return
+		case <-ticker.C:
+			s.beat("leader_election")
+			s.campaign()
+		}
+	}
+}
+
+// campaign takes or renews the leader lease. Leadership is kept until the
+// lease would expire when it was last renewed, so a replica that cannot
+// reach the database steps down before another one may take over.
+func (s *Service) campaign() {
+	ctx, cancel := context.WithTimeout(s.ctx, s.config.Cluster.RenewInterval)
+	defer cancel()
+
+	wasLeader := s.isLeader()
+	start := s.clock.Now()
+
+	acquired, err := s.leaseRepo.Acquire(ctx, leaderLease, s.nodeID, s.config.Cluster.LeaseTTL)
+	switch {
+	case err != nil:
+		s.logger.Error("Failed to renew leader lease", zap.Error(err))
+	case acquired:
+		s.leaderUntil.Store(start.Add(s.config.Cluster.LeaseTTL).UnixNano())
+	default:
+		s.leaderUntil.Store(0)
+	}
+
+	if isLeader := s.isLeader(); isLeader != wasLeader {
+		if isLeader {
+			s.logger.Info("Acquired leadership", zap.String("node_id", s.nodeID))
+		} else {
+			s.logger.Warn("Lost leadership", zap.String("node_id", s.nodeID))
+		}
+	}
+}
+
+// resign releases the leader lease so another replica takes over at once
+func (s *Service) resign() {
+	if !s.isLeader() {
+		return
+	}
+	s.leaderUntil.Store(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), resignTimeout)
+	defer cancel()
+
+	if err := s.leaseRepo.Release(ctx, leaderLease, s.nodeID); err != nil {
+		s.logger.Error("Failed to release leader lease", zap.Error(err))
+		return
+	}
+	s.logger.Info("Released leadership", zap.String("node_id", s.nodeID))
+}
+
+// defaultNodeID returns the hostname with a random suffix, so replicas on
+// the same host do not share an identity
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "wameter"
+	}
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/clock"
+)
+
+// TestLeaderLease tests that the leader lease is renewed by its holder,
+// including within the same millisecond, and expires by the service clock
+func TestLeaderLease(t *testing.T) {
+	fake := clock.NewFake(time.Now().Truncate(time.Second))
+	svc, _ := newTestService(t, WithClock(fake))
+	ctx := context.Background()
+	const ttl = 10 * time.Second
+
+	acquire := func(holder string) bool {
+		acquired, err := svc.leaseRepo.Acquire(ctx, leaderLease, holder, ttl)
+		require.NoError(t, err)
+		return acquired
+	}
+
+	assert.True(t, acquire("node-a"))
+	assert.True(t, acquire("node-a"), "renewed at the same time")
+	assert.False(t, acquire("node-b"))
+
+	fake.Advance(ttl / 2)
+	assert.True(t, acquire("node-a"))
+	fake.Advance(ttl - time.Second)
+	assert.False(t, acquire("node-b"), "renewed lease not expired")
+
+	fake.Advance(2 * time.Second)
+	assert.True(t, acquire("node-b"))
+	assert.False(t, acquire("node-a"))
+
+	require.NoError(t, svc.leaseRepo.Release(ctx, leaderLease, "node-b"))
+	assert.True(t, acquire("node-a"))
+
+	require.NoError(t, svc.Stop(ctx))
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestCollection asks an agent to run its collectors at once and report
+// the data, the returned command completes once the report is stored
+func (s *Service) RequestCollection(ctx context.Context, agentID string) (string, error) {
+	cmd := types.Command{
+		ID:        fmt.Sprintf("%s-collect-%s", agentID, uuid.New().String()),
+		Type:      "collect",
+		Timeout:   time.Minute,
+		CreatedAt: s.clock.Now(),
+	}
+
+	// The command outlives the request, it is tracked until the report
+	if err := s.SendCommand(context.WithoutCancel(ctx), agentID, cmd); err != nil {
+		return "", err
+	}
+
+	return cmd.ID, nil
+}
+
+// completeCollection completes the collect command a stored report answers
+func (s *Service) completeCollection(ctx context.Context, data *types.MetricsData) {
+	if data.CommandID == "" {
+		return
+	}
+
+	result, _ := json.Marshal(struct {
+		CollectedAt time.Time `json:"collected_at"`
+	}{data.CollectedAt})
+	if err := s.HandleCommandResult(ctx, data.AgentID, types.CommandResult{
+		CommandID: data.CommandID,
+		AgentID:   data.AgentID,
+		Status:    types.CommandStatusComplete,
+		Result:    result,
+	}); err != nil {
+		s.logger.Debug("Collect command is not tracked",
+			zap.Error(err),
+			zap.String("command_id", data.CommandID))
+	}
+}
+
+// sendCollectRequest asks the agent to collect and report metrics for the
+// command
+func (s *Service) sendCollectRequest(ctx context.Context, agentID string, cmd types.Command) error {
+	type CollectArgs struct {
+		CommandID string `json:"command_id"`
+	}
+
+	message := struct {
+		Type    string `json:"type"`
+		Payload struct {
+			Args CollectArgs `json:"args"`
+		} `json:"payload"`
+	}{
+		Type: "collect",
+	}
+	message.Payload.Args.CommandID = cmd.ID
+
+	return s.deliverCommand(ctx, agentID, cmd.ID, message)
+}
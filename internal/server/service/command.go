@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"time"
 	"wameter/internal/agent/config"
+	"wameter/internal/server/rbac"
 	"wameter/internal/types"
 	"wameter/internal/version"
 
@@ -20,10 +22,12 @@ import (
 // CommandService represents command service interface
 type CommandService interface {
 	SendCommand(ctx context.Context, agentID string, cmd types.Command) error
-	GetCommandResult(ctx context.Context, commandID string) (*types.CommandResult, error)
+	GetCommandResult(ctx context.Context, commandID string, wait time.Duration) (*types.CommandResult, error)
 	GetPendingCommands(ctx context.Context, agentID string) ([]types.Command, error)
+	PullCommands(ctx context.Context, agentID string) ([]types.QueuedCommand, error)
 	CancelCommand(ctx context.Context, commandID string) error
 	GetCommandHistory(ctx context.Context, agentID string, limit int) ([]types.CommandHistory, error)
+	RequestCollection(ctx context.Context, agentID string) (string, error)
 }
 
 // _ implements CommandService
@@ -31,10 +35,14 @@ var _ CommandService = (*Service)(nil)
 
 // commandTracker tracks command execution
 type commandTracker struct {
+	agentID    string
 	command    types.Command
 	result     chan types.CommandResult
 	cancelFunc context.CancelFunc
 	timeout    time.Duration
+	// Closed once the command ended, with its result in final
+	done  chan struct{}
+	final types.CommandResult
 }
 
 // SendCommand sends a command to an agent
@@ -45,7 +53,7 @@ func (s *Service) SendCommand(ctx context.Context, agentID string, cmd types.Com
 		return err
 	}
 	if agent.Status != types.AgentStatusOnline {
-		return fmt.Errorf("agent is not online")
+		return types.ErrAgentOffline
 	}
 
 	// Generate command ID if not set
@@ -53,7 +61,7 @@ func (s *Service) SendCommand(ctx context.Context, agentID string, cmd types.Com
 		cmd.ID = fmt.Sprintf("%s-command-%s", agentID, uuid.New().String())
 	}
 	if cmd.CreatedAt.IsZero() {
-		cmd.CreatedAt = time.Now()
+		cmd.CreatedAt = s.clock.Now()
 	}
 
 	// Set default timeout if not specified
@@ -61,15 +69,18 @@ func (s *Service) SendCommand(ctx context.Context, agentID string, cmd types.Com
 		cmd.Timeout = 30 * time.Second
 	}
 
-	// Create command context with timeout
-	cmdCtx, cancel := context.WithTimeout(ctx, cmd.Timeout)
+	// Create command context with timeout, commands outlive the request
+	// sending them
+	cmdCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cmd.Timeout)
 
 	// Create command tracker
 	tracker := &commandTracker{
+		agentID:    agentID,
 		command:    cmd,
 		result:     make(chan types.CommandResult, 1),
 		cancelFunc: cancel,
 		timeout:    cmd.Timeout,
+		done:       make(chan struct{}),
 	}
 
 	// Store tracker
@@ -83,8 +94,12 @@ func (s *Service) SendCommand(ctx context.Context, agentID string, cmd types.Com
 	// Send command to agent
 	if err := s.sendCommandToAgent(cmdCtx, agentID, cmd); err != nil {
 		cancel()
-		return fmt.Errorf("failed to send command: %w", err)
+		err = fmt.Errorf("failed to send command: %w", err)
+		s.recordError(err)
+		s.recordCommand(func(c *types.CommandStats) { c.SendErrors++ })
+		return err
 	}
+	s.recordCommand(func(c *types.CommandStats) { c.Sent++ })
 
 	s.logger.Debug("Command sent",
 		zap.String("command_id", cmd.ID),
@@ -94,25 +109,85 @@ func (s *Service) SendCommand(ctx context.Context, agentID string, cmd types.Com
 	return nil
 }
 
-// GetCommandResult gets the result of a command
-func (s *Service) GetCommandResult(ctx context.Context, commandID string) (*types.CommandResult, error) {
+// GetCommandResult gets the result of a command, waiting up to wait for a
+// command still in progress to end. A command in progress after the wait is
+// answered with its current status, pending until its agent received it.
+func (s *Service) GetCommandResult(ctx context.Context, commandID string, wait time.Duration) (*types.CommandResult, error) {
 	s.commandsMu.RLock()
 	tracker, exists := s.commands[commandID]
 	s.commandsMu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("command not found")
+		// Ended commands are kept in the history of their agent
+		result, ok := s.commandHistoryResult(commandID)
+		if !ok || !rbac.AllowsAgent(ctx, result.AgentID) {
+			return nil, types.ErrCommandNotFound
+		}
+		return result, nil
+	}
+	if !rbac.AllowsAgent(ctx, tracker.agentID) {
+		return nil, types.ErrCommandNotFound
 	}
 
-	// Wait for result with context timeout
 	select {
-	case result := <-tracker.result:
+	case <-tracker.done:
+		result := tracker.final
 		return &result, nil
+	default:
+	}
+	if wait <= 0 {
+		return s.commandProgress(tracker), nil
+	}
+
+	timer := s.clock.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-tracker.done:
+		result := tracker.final
+		return &result, nil
+	case <-timer.C:
+		return s.commandProgress(tracker), nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
 
+// commandHistoryResult returns the result of an ended command
+func (s *Service) commandHistoryResult(commandID string) (*types.CommandResult, bool) {
+	s.commandsMu.RLock()
+	defer s.commandsMu.RUnlock()
+
+	for _, history := range s.history {
+		for i := range history {
+			if history[i].Command.ID == commandID {
+				result := history[i].Result
+				return &result, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// commandProgress returns the current status of a command in progress
+func (s *Service) commandProgress(tracker *commandTracker) *types.CommandResult {
+	s.commandsMu.RLock()
+	defer s.commandsMu.RUnlock()
+
+	status := types.CommandStatusRunning
+	if slices.ContainsFunc(s.pulls[tracker.agentID], func(q types.QueuedCommand) bool {
+		return q.CommandID == tracker.command.ID
+	}) {
+		status = types.CommandStatusPending
+	}
+	return &types.CommandResult{
+		CommandID: tracker.command.ID,
+		AgentID:   tracker.agentID,
+		Status:    status,
+		StartTime: tracker.command.CreatedAt,
+	}
+}
+
 // GetPendingCommands gets pending commands for an agent
 func (s *Service) GetPendingCommands(_ context.Context, agentID string) ([]types.Command, error) {
 	s.commandsMu.RLock()
@@ -150,7 +225,7 @@ func (s *Service) CancelCommand(_ context.Context, commandID string) error {
 	result := types.CommandResult{
 		CommandID: commandID,
 		Status:    types.CommandStatusCanceled,
-		EndTime:   time.Now(),
+		EndTime:   s.clock.Now(),
 	}
 	tracker.result <- result
 
@@ -181,7 +256,9 @@ func (s *Service) GetCommandHistory(_ context.Context, agentID string, limit int
 func (s *Service) monitorCommand(ctx context.Context, agentID string, cmd types.Command) {
 	defer s.cleanupCommand(cmd.ID)
 
+	s.commandsMu.RLock()
 	tracker := s.commands[cmd.ID]
+	s.commandsMu.RUnlock()
 	var result types.CommandResult
 
 	select {
@@ -194,7 +271,7 @@ func (s *Service) monitorCommand(ctx context.Context, agentID string, cmd types.
 				AgentID:   agentID,
 				Status:    types.CommandStatusTimedOut,
 				Error:     "command timed out",
-				EndTime:   time.Now(),
+				EndTime:   s.clock.Now(),
 			}
 		} else {
 			result = types.CommandResult{
@@ -202,13 +279,38 @@ func (s *Service) monitorCommand(ctx context.Context, agentID string, cmd types.
 				AgentID:   agentID,
 				Status:    types.CommandStatusCanceled,
 				Error:     "command canceled",
-				EndTime:   time.Now(),
+				EndTime:   s.clock.Now(),
 			}
 		}
 	}
 
-	// Update command history
+	// Release callers waiting for the result
+	if result.AgentID == "" {
+		result.AgentID = agentID
+	}
+	tracker.final = result
+	close(tracker.done)
+
+	s.recordCommand(func(c *types.CommandStats) {
+		switch result.Status {
+		case types.CommandStatusComplete:
+			c.Completed++
+		case types.CommandStatusFailed:
+			c.Failed++
+		case types.CommandStatusTimedOut:
+			c.TimedOut++
+		case types.CommandStatusCanceled:
+			c.Canceled++
+		}
+	})
+
+	// Update command history, unless the agent was erased meanwhile
 	s.commandsMu.Lock()
+	if s.erasedSince(agentID, cmd.CreatedAt) {
+		s.commandsMu.Unlock()
+		s.cleanupCommand(cmd.ID)
+		return
+	}
 	if _, exists := s.history[agentID]; !exists {
 		s.history[agentID] = make([]types.CommandHistory, 0)
 	}
@@ -232,11 +334,22 @@ func (s *Service) sendCommandToAgent(ctx context.Context, agentID string, cmd ty
 		return s.sendCollectorRestart(ctx, agentID, cmd)
 	case "agent_update":
 		return s.sendAgentUpdate(ctx, agentID, cmd)
+	case "diagnostics":
+		return s.sendDiagnosticsRequest(ctx, agentID, cmd)
+	case "collect":
+		return s.sendCollectRequest(ctx, agentID, cmd)
 	default:
 		return fmt.Errorf("unsupported command type: %s", cmd.Type)
 	}
 }
 
+// recordCommand updates the command counts
+func (s *Service) recordCommand(fn func(*types.CommandStats)) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	fn(&s.stats.commands)
+}
+
 // cleanupCommand removes command tracker after completion
 func (s *Service) cleanupCommand(commandID string) {
 	s.commandsMu.Lock()
@@ -246,6 +359,16 @@ func (s *Service) cleanupCommand(commandID string) {
 		tracker.cancelFunc()
 		delete(s.commands, commandID)
 	}
+
+	// Commands not polled in time are not run anymore
+	for agentID, queued := range s.pulls {
+		queued = slices.DeleteFunc(queued, func(q types.QueuedCommand) bool { return q.CommandID == commandID })
+		if len(queued) == 0 {
+			delete(s.pulls, agentID)
+		} else {
+			s.pulls[agentID] = queued
+		}
+	}
 }
 
 // sendConfigUpdate sends config update command
@@ -264,7 +387,7 @@ func (s *Service) sendConfigUpdate(ctx context.Context, agentID string, cmd type
 		Config: c,
 	}
 
-	return s.sendHTTPCommand(ctx, agentID, message)
+	return s.deliverCommand(ctx, agentID, cmd.ID, message)
 }
 
 // sendCollectorRestart sends collector restart command
@@ -294,7 +417,7 @@ func (s *Service) sendCollectorRestart(ctx context.Context, agentID string, cmd
 		Options: opts,
 	}
 
-	return s.sendHTTPCommand(ctx, agentID, message)
+	return s.deliverCommand(ctx, agentID, cmd.ID, message)
 }
 
 // sendAgentUpdate sends agent update command
@@ -324,11 +447,33 @@ func (s *Service) sendAgentUpdate(ctx context.Context, agentID string, cmd types
 		Options: opts,
 	}
 
-	return s.sendHTTPCommand(ctx, agentID, message)
+	return s.deliverCommand(ctx, agentID, cmd.ID, message)
 }
 
-// sendHTTPCommand sends command to agent via HTTP
-func (s *Service) sendHTTPCommand(ctx context.Context, agentID string, payload any) error {
+// sendDiagnosticsRequest asks the agent to upload a diagnostics bundle for
+// the command
+func (s *Service) sendDiagnosticsRequest(ctx context.Context, agentID string, cmd types.Command) error {
+	type DiagnosticsArgs struct {
+		CommandID string `json:"command_id"`
+	}
+
+	message := struct {
+		Type    string `json:"type"`
+		Payload struct {
+			Args DiagnosticsArgs `json:"args"`
+		} `json:"payload"`
+	}{
+		Type: "diagnostics",
+	}
+	message.Payload.Args.CommandID = cmd.ID
+
+	return s.deliverCommand(ctx, agentID, cmd.ID, message)
+}
+
+// deliverCommand sends a command message to an agent by the protocol
+// negotiated with it, queued for agents polling their commands and pushed
+// to others
+func (s *Service) deliverCommand(ctx context.Context, agentID, commandID string, payload any) error {
 	// Get agent
 	agent, err := s.GetAgent(ctx, agentID)
 	if err != nil {
@@ -341,6 +486,35 @@ func (s *Service) sendHTTPCommand(ctx context.Context, agentID string, payload a
 		return fmt.Errorf("failed to marshal command payload: %w", err)
 	}
 
+	if agent.Protocol.Has(types.FeaturePullCommands) {
+		s.commandsMu.Lock()
+		s.pulls[agentID] = append(s.pulls[agentID], types.QueuedCommand{CommandID: commandID, Message: data})
+		s.commandsMu.Unlock()
+		return nil
+	}
+	return s.sendHTTPCommand(ctx, agent, data)
+}
+
+// PullCommands returns the commands queued for an agent polling its
+// commands and removes them from the queue
+func (s *Service) PullCommands(ctx context.Context, agentID string) ([]types.QueuedCommand, error) {
+	if !rbac.AllowsAgent(ctx, agentID) {
+		return nil, types.ErrForbidden
+	}
+	if _, err := s.GetAgent(ctx, agentID); err != nil {
+		return nil, err
+	}
+
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+
+	queued := s.pulls[agentID]
+	delete(s.pulls, agentID)
+	return queued, nil
+}
+
+// sendHTTPCommand pushes a command message to an agent via HTTP
+func (s *Service) sendHTTPCommand(ctx context.Context, agent *types.AgentInfo, data []byte) error {
 	// Prepare URL
 	url := fmt.Sprintf("http://%s:%d/v1/command", agent.Hostname, agent.Port)
 
@@ -354,7 +528,7 @@ func (s *Service) sendHTTPCommand(ctx context.Context, agentID string, payload a
 	req.Header.Set("User-Agent", "wameter-server/"+version.GetInfo().Version)
 
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send command: %w", err)
 	}
@@ -385,7 +559,7 @@ func (s *Service) HandleCommandResult(_ context.Context, agentID string, result
 
 	// Apply default values to result
 	if result.EndTime.IsZero() {
-		result.EndTime = time.Now()
+		result.EndTime = s.clock.Now()
 	}
 
 	// Update command result
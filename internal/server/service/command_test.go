@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/types"
+)
+
+// TestGetCommandResultWait tests that the result of a command is waited for
+// up to the wait, commands in progress are answered with their status
+func TestGetCommandResultWait(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	require.NoError(t, svc.RegisterAgent(ctx, &types.AgentInfo{
+		ID:       "agent-2",
+		Hostname: "host-2",
+		Status:   types.AgentStatusOnline,
+		Capabilities: &types.AgentCapabilities{
+			APIVersion: types.AgentAPIVersion,
+			Features:   []string{types.FeaturePullCommands},
+		},
+	}))
+	require.NoError(t, svc.SendCommand(ctx, "agent-2", types.Command{
+		ID:      "cmd-1",
+		Type:    "collector_restart",
+		Timeout: time.Minute,
+	}))
+
+	result, err := svc.GetCommandResult(ctx, "cmd-1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, types.CommandStatusPending, result.Status)
+
+	_, err = svc.PullCommands(ctx, "agent-2")
+	require.NoError(t, err)
+	result, err = svc.GetCommandResult(ctx, "cmd-1", 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, types.CommandStatusRunning, result.Status)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = svc.HandleCommandResult(ctx, "agent-2", types.CommandResult{
+			CommandID: "cmd-1",
+			AgentID:   "agent-2",
+			Status:    types.CommandStatusComplete,
+		})
+	}()
+	result, err = svc.GetCommandResult(ctx, "cmd-1", 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, types.CommandStatusComplete, result.Status)
+
+	// Ended commands are answered from the history
+	require.Eventually(t, func() bool {
+		svc.commandsMu.RLock()
+		defer svc.commandsMu.RUnlock()
+		_, tracked := svc.commands["cmd-1"]
+		return !tracked
+	}, time.Second, 10*time.Millisecond)
+	result, err = svc.GetCommandResult(ctx, "cmd-1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, types.CommandStatusComplete, result.Status)
+
+	_, err = svc.GetCommandResult(ctx, "cmd-2", 0)
+	assert.ErrorIs(t, err, types.ErrCommandNotFound)
+}
+
+// TestRequestCollection tests that a collect command is queued for the agent
+// and completes once the report answering it is stored
+func TestRequestCollection(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	_, err := svc.RequestCollection(ctx, "agent-3")
+	assert.ErrorIs(t, err, types.ErrAgentNotFound)
+
+	require.NoError(t, svc.RegisterAgent(ctx, &types.AgentInfo{
+		ID:       "agent-2",
+		Hostname: "host-2",
+		Status:   types.AgentStatusOnline,
+		Capabilities: &types.AgentCapabilities{
+			APIVersion: types.AgentAPIVersion,
+			Features:   []string{types.FeaturePullCommands},
+		},
+	}))
+	commandID, err := svc.RequestCollection(ctx, "agent-2")
+	require.NoError(t, err)
+
+	queued, err := svc.PullCommands(ctx, "agent-2")
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, commandID, queued[0].CommandID)
+	assert.JSONEq(t, `{"type":"collect","payload":{"args":{"command_id":"`+commandID+`"}}}`, string(queued[0].Message))
+
+	report := testReport(time.Now().Add(-time.Second), 0, 100)
+	report.AgentID = "agent-2"
+	report.Hostname = "host-2"
+	report.CommandID = commandID
+	require.NoError(t, svc.SaveMetrics(ctx, report))
+
+	result, err := svc.GetCommandResult(ctx, commandID, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, types.CommandStatusComplete, result.Status)
+}
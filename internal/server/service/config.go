@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	commonCfg "wameter/internal/config"
+	"wameter/internal/logger"
 	"wameter/internal/server/config"
-	"wameter/internal/server/notify"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
@@ -15,8 +20,8 @@ import (
 // ConfigService represents configuration management service interface
 type ConfigService interface {
 	GetConfig() *config.Config
-	UpdateConfig(ctx context.Context, cfg *config.Config) error
-	ReloadConfig(ctx context.Context) error
+	UpdateConfig(ctx context.Context, cfg *config.Config) (*types.ConfigChange, error)
+	ReloadConfig(ctx context.Context) (*types.ConfigChange, error)
 	ValidateConfig(cfg *config.Config) error
 	GetConfigHistory(ctx context.Context) ([]types.ConfigChange, error)
 }
@@ -24,12 +29,34 @@ type ConfigService interface {
 // _ implements ConfigService
 var _ ConfigService = (*Service)(nil)
 
+// liveConfigPrefixes are the config sections applied without restarting
+var liveConfigPrefixes = []string{
+	"notify.",
+	"log.level",
+	"api.rate_limit.",
+	"api.metrics_limits.",
+	"api.protocol.",
+	"analysis.",
+	"agent_monitor.",
+	"reports.",
+	"alert_rules.",
+	"anomaly.",
+	"network_errors.",
+	"heartbeat.",
+	"quotas.",
+	"inventory.",
+	"remote_write.",
+}
+
 // configManager handles configuration management
 type configManager struct {
-	current *config.Config
-	history []types.ConfigChange
-	mu      sync.RWMutex
-	logger  *zap.Logger
+	current   *config.Config
+	history   []types.ConfigChange
+	loader    func() (*config.Config, error)
+	observers []func(*config.Config)
+	mu        sync.RWMutex
+	reloadMu  sync.Mutex
+	logger    *zap.Logger
 }
 
 // NewConfigManager creates new configuration manager
@@ -48,55 +75,109 @@ func (s *Service) GetConfig() *config.Config {
 	return s.configMgr.current
 }
 
-// UpdateConfig updates configuration
-func (s *Service) UpdateConfig(ctx context.Context, newCfg *config.Config) error {
+// SetConfigLoader sets how ReloadConfig loads the configuration
+func (s *Service) SetConfigLoader(loader func() (*config.Config, error)) {
+	s.configMgr.mu.Lock()
+	defer s.configMgr.mu.Unlock()
+	s.configMgr.loader = loader
+}
+
+// OnConfigReload registers fn to be called with the new configuration after
+// each applied update, components outside the service use it to pick up
+// live settings
+func (s *Service) OnConfigReload(fn func(*config.Config)) {
+	s.configMgr.mu.Lock()
+	defer s.configMgr.mu.Unlock()
+	s.configMgr.observers = append(s.configMgr.observers, fn)
+}
+
+// UpdateConfig applies the live sections of a new configuration, changes of
+// other sections are recorded as requiring a restart
+func (s *Service) UpdateConfig(ctx context.Context, newCfg *config.Config) (*types.ConfigChange, error) {
 	// First validate new configuration
 	if err := s.ValidateConfig(newCfg); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	s.configMgr.mu.Lock()
-	defer s.configMgr.mu.Unlock()
+	s.configMgr.reloadMu.Lock()
+	defer s.configMgr.reloadMu.Unlock()
 
 	// Detect changes
-	changes := detectConfigChanges(s.configMgr.current, newCfg)
-	if len(changes) == 0 {
-		return nil // No changes detected
+	change := &types.ConfigChange{
+		Timestamp: s.clock.Now(),
+		Changes:   detectConfigChanges(s.GetConfig(), newCfg),
 	}
-
-	// Create change record
-	change := types.ConfigChange{
-		Timestamp: time.Now(),
-		Changes:   changes,
+	if len(change.Changes) == 0 {
+		s.logger.Info("Configuration unchanged")
+		return change, nil
 	}
 
 	// Apply changes to components
-	if err := s.applyConfigChanges(ctx, newCfg, changes); err != nil {
-		return fmt.Errorf("failed to apply configuration changes: %w", err)
+	if err := s.applyConfigChanges(ctx, newCfg, change.Changes); err != nil {
+		return nil, fmt.Errorf("failed to apply configuration changes: %w", err)
 	}
 
 	// Update current configuration
+	s.configMgr.mu.Lock()
 	s.configMgr.current = newCfg
-	s.configMgr.history = append(s.configMgr.history, change)
+	s.configMgr.history = append(s.configMgr.history, *change)
+	observers := make([]func(*config.Config), len(s.configMgr.observers))
+	copy(observers, s.configMgr.observers)
+	s.configMgr.mu.Unlock()
 
+	for _, fn := range observers {
+		fn(newCfg)
+	}
+
+	for _, c := range change.Changes {
+		fields := []zap.Field{
+			zap.String("path", c.Path),
+			zap.Any("old", c.OldValue),
+			zap.Any("new", c.NewValue),
+		}
+		if c.Applied {
+			s.logger.Info("Configuration change applied", fields...)
+		} else {
+			s.logger.Warn("Configuration change requires restart", fields...)
+		}
+	}
 	s.logger.Info("Configuration updated",
-		zap.Int("changes", len(changes)))
+		zap.Int("changes", len(change.Changes)))
 
-	return nil
+	return change, nil
 }
 
-// ReloadConfig reloads configuration from file
-func (s *Service) ReloadConfig(ctx context.Context) error {
-	// Load configuration from file
-	newCfg, err := config.LoadConfig(s.configPath)
+// ReloadConfig reloads configuration from its source and applies it
+func (s *Service) ReloadConfig(ctx context.Context) (*types.ConfigChange, error) {
+	s.configMgr.mu.RLock()
+	loader := s.configMgr.loader
+	s.configMgr.mu.RUnlock()
+
+	if loader == nil {
+		if s.configPath == "" {
+			return nil, errors.New("configuration source unknown")
+		}
+		loader = func() (*config.Config, error) {
+			return config.LoadConfig(s.configPath, nil)
+		}
+	}
+
+	// Load configuration
+	newCfg, err := loader()
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 
 	// Update configuration
 	return s.UpdateConfig(ctx, newCfg)
 }
 
+// RotateSecrets runs update, which rewrites rotated secrets in the config in
+// place, while no notification is being sent
+func (s *Service) RotateSecrets(update func()) {
+	s.notifier.UpdateConfig(update)
+}
+
 // ValidateConfig validates configuration
 func (s *Service) ValidateConfig(cfg *config.Config) error {
 	if cfg == nil {
@@ -114,8 +195,10 @@ func (s *Service) ValidateConfig(cfg *config.Config) error {
 	}
 
 	// Validate notification configuration
-	if err := cfg.Notify.Validate(); err != nil {
-		return err
+	if cfg.Notify != nil {
+		if err := cfg.Notify.Validate(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -134,83 +217,90 @@ func (s *Service) GetConfigHistory(ctx context.Context) ([]types.ConfigChange, e
 
 // Internal helper functions
 
-// detectConfigChanges detects changes between configurations
+// detectConfigChanges detects changes between configurations, secret values are redacted
 func detectConfigChanges(old, new *config.Config) []types.ConfigModification {
-	var changes []types.ConfigModification
+	oldValues, newValues := commonCfg.Flatten(old, false), commonCfg.Flatten(new, false)
+	oldRedacted, newRedacted := commonCfg.Flatten(old, true), commonCfg.Flatten(new, true)
 
-	// Server changes
-	if old.Server.Address != new.Server.Address {
-		changes = append(changes, types.ConfigModification{
-			Path:     "server.address",
-			OldValue: old.Server.Address,
-			NewValue: new.Server.Address,
-		})
+	paths := make(map[string]bool, len(newValues))
+	for path := range oldValues {
+		paths[path] = true
 	}
-
-	// Database changes
-	if old.Database.MaxConnections != new.Database.MaxConnections {
-		changes = append(changes, types.ConfigModification{
-			Path:     "database.max_connections",
-			OldValue: old.Database.MaxConnections,
-			NewValue: new.Database.MaxConnections,
-		})
+	for path := range newValues {
+		paths[path] = true
 	}
 
-	// Notification changes
-	if old.Notify.Enabled != new.Notify.Enabled {
+	var changes []types.ConfigModification
+	for path := range paths {
+		if reflect.DeepEqual(oldValues[path], newValues[path]) {
+			continue
+		}
 		changes = append(changes, types.ConfigModification{
-			Path:     "notify.enabled",
-			OldValue: old.Notify.Enabled,
-			NewValue: new.Notify.Enabled,
+			Path:     path,
+			OldValue: oldRedacted[path],
+			NewValue: newRedacted[path],
+			Applied:  isLiveConfig(path),
 		})
 	}
 
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
 	return changes
 }
 
+// isLiveConfig reports whether a config path is applied without restarting
+func isLiveConfig(path string) bool {
+	for _, prefix := range liveConfigPrefixes {
+		if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, ".") {
+			return true
+		}
+	}
+	return false
+}
+
 // applyConfigChanges applies configuration changes to components
 func (s *Service) applyConfigChanges(_ context.Context, cfg *config.Config, changes []types.ConfigModification) error {
+	var notifyChanged, logLevelChanged bool
 	for _, change := range changes {
-		switch change.Path {
-		case "database.max_connections":
-			if err := s.updateDatabaseConnections(cfg.Database.MaxConnections); err != nil {
-				return err
-			}
-		case "notify.enabled":
-			if err := s.updateNotifierStatus(cfg.Notify.Enabled); err != nil {
-				return err
-			}
-			// Add more change handlers as needed
+		switch {
+		case strings.HasPrefix(change.Path, "notify.") || change.Path == "notify":
+			notifyChanged = true
+		case change.Path == "log.level":
+			logLevelChanged = true
 		}
 	}
 
-	return nil
-}
+	// Re-initialize notification channels
+	if notifyChanged {
+		if err := s.notifier.Reload(cfg.Notify); err != nil {
+			return err
+		}
+	}
+
+	// Change log level
+	if logLevelChanged && cfg.Log != nil {
+		logger.SetLevel(cfg.Log.Level)
+	}
+
+	// Rate limits, analysis, agent monitoring, report and inventory
+	// settings are read from the current configuration
 
-// updateDatabaseConnections updates database connection pool
-func (s *Service) updateDatabaseConnections(_ int) error {
-	// if s.db != nil {
-	// 	// Update database connection pool
-	// 	// s.db.SetMaxOpenConns(maxConns)
-	// }
 	return nil
 }
 
-// updateNotifierStatus updates notifier status
-func (s *Service) updateNotifierStatus(enabled bool) error {
-	if enabled && s.notifier == nil {
-		// Initialize notifier
-		notifier, err := notify.NewManager(s.configMgr.current.Notify, s.logger)
-		if err != nil {
-			return fmt.Errorf("failed to initialize notifier: %w", err)
-		}
-		s.notifier = notifier
-	} else if !enabled && s.notifier != nil {
-		// Stop notifier
-		if err := s.notifier.Stop(); err != nil {
-			return fmt.Errorf("failed to stop notifier: %w", err)
-		}
-		s.notifier = nil
+// agentMonitorConfig returns the agent monitoring configuration with defaults applied
+func (s *Service) agentMonitorConfig() config.AgentMonitorConfig {
+	cfg := s.GetConfig().Monitor
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Minute
 	}
-	return nil
+	if cfg.OfflineThreshold <= 0 {
+		cfg.OfflineThreshold = 5 * time.Minute
+	}
+	if cfg.MissedChecks <= 0 {
+		cfg.MissedChecks = 1
+	}
+	return cfg
 }
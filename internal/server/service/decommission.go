@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"wameter/internal/server/archive"
+	"wameter/internal/server/data/repository"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// DecommissionService represents agent decommissioning service interface
+type DecommissionService interface {
+	DecommissionAgent(ctx context.Context, agentID string, req *types.DecommissionRequest) (*types.AgentDecommission, error)
+	GetDecommission(ctx context.Context, agentID string) (*types.AgentDecommission, error)
+	CancelDecommission(ctx context.Context, agentID string) error
+}
+
+// _ implements DecommissionService
+var _ DecommissionService = (*Service)(nil)
+
+// DecommissionAgent retires an agent, it is no longer monitored and its
+// reports are rejected. Its metrics are archived in the background when
+// requested and its data is purged once the grace period has passed.
+func (s *Service) DecommissionAgent(ctx context.Context, agentID string, req *types.DecommissionRequest) (*types.AgentDecommission, error) {
+	agent, err := s.GetAgent(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent.Status == types.AgentStatusRetired {
+		return nil, types.ErrAgentRetired
+	}
+
+	cfg := s.GetConfig().Decommission
+	storage := req.Archive
+	switch storage {
+	case "":
+		storage = cfg.Archive
+	case "none":
+		storage = ""
+	}
+	grace := req.GracePeriod
+	if grace == 0 {
+		grace = cfg.GracePeriod
+	}
+
+	now := s.clock.Now()
+	d := &types.AgentDecommission{
+		AgentID:    agent.ID,
+		TenantID:   agent.TenantID,
+		Hostname:   agent.Hostname,
+		Reason:     req.Reason,
+		Archive:    storage,
+		RetiredAt:  now,
+		PurgeAfter: now.Add(grace),
+	}
+
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	if err := s.decommissionRepo.Save(ctx, d); err != nil {
+		return nil, fmt.Errorf("failed to save decommission: %w", err)
+	}
+	if err := s.agentRepo.UpdateStatus(ctx, agentID, types.AgentStatusRetired); err != nil {
+		if derr := s.decommissionRepo.Delete(ctx, agentID); derr != nil {
+			s.log(ctx).Error("Failed to roll back decommission",
+				zap.Error(derr),
+				zap.String("agent_id", agentID))
+		}
+		return nil, fmt.Errorf("failed to retire agent: %w", err)
+	}
+
+	// Retired agents are left out of offline checks, on other replicas too
+	agent.Status = types.AgentStatusRetired
+	agent.UpdatedAt = now
+	s.agents[agentID] = agent
+	delete(s.missedChecks, agentID)
+	s.shareAgentState(ctx, agent)
+
+	s.log(ctx).Info("Agent decommissioned",
+		zap.String("id", agentID),
+		zap.String("hostname", agent.Hostname),
+		zap.String("archive", storage),
+		zap.Time("purge_after", d.PurgeAfter))
+
+	if storage != "" {
+		archived := *d
+		s.goBackground(func() {
+			if err := s.archiveRetiredAgent(s.ctx, &archived); err != nil {
+				s.log(ctx).Error("Failed to archive retired agent, retrying before purge",
+					zap.Error(err),
+					zap.String("agent_id", agentID))
+			}
+		})
+	}
+
+	return d, nil
+}
+
+// GetDecommission returns the decommission of an agent
+func (s *Service) GetDecommission(ctx context.Context, agentID string) (*types.AgentDecommission, error) {
+	return s.decommissionRepo.FindByAgent(ctx, agentID)
+}
+
+// CancelDecommission reinstates a retired agent before it is purged, it is
+// offline until it reports again
+func (s *Service) CancelDecommission(ctx context.Context, agentID string) error {
+	d, err := s.decommissionRepo.FindByAgent(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if d.PurgedAt != nil {
+		return types.ErrAgentNotFound
+	}
+
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	if err := s.agentRepo.UpdateStatus(ctx, agentID, types.AgentStatusOffline); err != nil {
+		return fmt.Errorf("failed to reinstate agent: %w", err)
+	}
+	if err := s.decommissionRepo.Delete(ctx, agentID); err != nil {
+		return fmt.Errorf("failed to delete decommission: %w", err)
+	}
+
+	if agent, ok := s.agents[agentID]; ok {
+		agent.Status = types.AgentStatusOffline
+		agent.UpdatedAt = s.clock.Now()
+		s.shareAgentState(ctx, agent)
+	}
+
+	s.log(ctx).Info("Agent decommission canceled", zap.String("id", agentID))
+
+	return nil
+}
+
+// archiveRetiredAgent archives all metrics of a retired agent and records
+// where they were stored, or why archiving failed
+func (s *Service) archiveRetiredAgent(ctx context.Context, d *types.AgentDecommission) error {
+	ctx = tenant.WithContext(ctx, d.TenantID)
+
+	err := func() error {
+		store, err := archive.New(d.Archive, &s.GetConfig().Archive)
+		if err != nil {
+			return err
+		}
+
+		spool, count, err := s.spoolMetrics(ctx, repository.QueryParams{
+			AgentIDs: []string{d.AgentID},
+			EndTime:  s.clock.Now(),
+		}, true)
+		if err != nil {
+			return fmt.Errorf("failed to get metrics for archival: %w", err)
+		}
+		defer removeSpool(spool)
+
+		key := fmt.Sprintf("agents/%s/metrics-%s.json", d.AgentID, d.RetiredAt.UTC().Format("20060102T150405Z"))
+		location, err := s.archiveMetrics(ctx, store, key, spool, true)
+		if err != nil {
+			return err
+		}
+
+		now := s.clock.Now()
+		d.ArchiveLocation = location
+		d.ArchivedAt = &now
+		d.ArchiveError = ""
+
+		s.logger.Info("Archived metrics of retired agent",
+			zap.String("agent_id", d.AgentID),
+			zap.Int("metrics_count", count),
+			zap.String("location", location))
+		return nil
+	}()
+	if err != nil {
+		d.ArchiveError = err.Error()
+	}
+
+	if uerr := s.decommissionRepo.UpdateArchive(ctx, d); uerr != nil && err == nil {
+		err = fmt.Errorf("failed to record archive: %w", uerr)
+	}
+	return err
+}
+
+// purgeRetiredAgents deletes the data of retired agents whose grace period
+// has passed, agents whose metrics could not be archived are kept
+func (s *Service) purgeRetiredAgents(ctx context.Context) error {
+	due, err := s.decommissionRepo.ListDue(ctx, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list retired agents: %w", err)
+	}
+
+	for _, d := range due {
+		if d.Archive != "" && d.ArchivedAt == nil {
+			if err := s.archiveRetiredAgent(ctx, d); err != nil {
+				s.logger.Error("Failed to archive retired agent, purge postponed",
+					zap.Error(err),
+					zap.String("agent_id", d.AgentID))
+				continue
+			}
+		}
+
+		ctx := tenant.WithContext(ctx, d.TenantID)
+		if err := s.agentRepo.Delete(ctx, d.AgentID); err != nil && !errors.Is(err, types.ErrAgentNotFound) {
+			s.logger.Error("Failed to purge retired agent",
+				zap.Error(err),
+				zap.String("agent_id", d.AgentID))
+			continue
+		}
+		s.forgetAgent(ctx, d.AgentID)
+
+		if err := s.decommissionRepo.MarkPurged(ctx, d.AgentID, s.clock.Now()); err != nil {
+			s.logger.Error("Failed to record agent purge",
+				zap.Error(err),
+				zap.String("agent_id", d.AgentID))
+			continue
+		}
+
+		s.logger.Info("Retired agent purged",
+			zap.String("id", d.AgentID),
+			zap.String("hostname", d.Hostname))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// deltaTracker holds the network state of the last report of agents
+// sending delta reports, the base their next delta applies to. Agents are
+// tracked from their first delta, the state of others is not kept.
+type deltaTracker struct {
+	bases map[string]*deltaBase
+	mu    sync.Mutex
+}
+
+// deltaBase represents the network state of a report
+type deltaBase struct {
+	timestamp time.Time
+	state     json.RawMessage // Nil until a report with state arrives
+}
+
+// newDeltaTracker creates new delta tracker
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{bases: make(map[string]*deltaBase)}
+}
+
+// base returns the base held for an agent and starts tracking it
+func (t *deltaTracker) base(agentID string) deltaBase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.bases[agentID]
+	if !ok {
+		b = &deltaBase{}
+		t.bases[agentID] = b
+	}
+	return *b
+}
+
+// tracked reports whether an agent sends delta reports
+func (t *deltaTracker) tracked(agentID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.bases[agentID]
+	return ok
+}
+
+// set replaces the base of an agent
+func (t *deltaTracker) set(agentID string, timestamp time.Time, state json.RawMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bases[agentID] = &deltaBase{timestamp: timestamp, state: state}
+}
+
+// forget drops the base of an agent
+func (t *deltaTracker) forget(agentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.bases, agentID)
+}
+
+// applyDelta expands a delta report to a full one and keeps the state of
+// reports of agents sending deltas as the base of their next delta. A delta
+// whose base is neither held nor the latest stored report is rejected, the
+// agent then sends a full report.
+func (s *Service) applyDelta(ctx context.Context, data *types.MetricsData) error {
+	if data.Delta == nil {
+		if data.HasState() && s.deltas.tracked(data.AgentID) {
+			s.rememberDelta(data)
+		}
+		return nil
+	}
+
+	base := s.deltas.base(data.AgentID)
+	if base.state == nil || !base.timestamp.Equal(data.Delta.Base) {
+		// Restarted servers continue from the stored report, unless its
+		// addresses were redacted
+		if s.privacy.Enabled() {
+			return fmt.Errorf("%w: report of %s", types.ErrDeltaBaseMismatch, data.Delta.Base.Format(time.RFC3339Nano))
+		}
+		latest, err := s.metricsRepo.GetLatest(ctx, data.AgentID)
+		if err != nil || !latest.Timestamp.Equal(data.Delta.Base) || !latest.HasState() {
+			return fmt.Errorf("%w: report of %s", types.ErrDeltaBaseMismatch, data.Delta.Base.Format(time.RFC3339Nano))
+		}
+		if base.state, err = latest.Metrics.Network.State(); err != nil {
+			return fmt.Errorf("failed to encode delta base: %w", err)
+		}
+	}
+
+	network, err := types.ApplyNetworkDelta(base.state, data.Delta.Network)
+	if err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidMetrics, err)
+	}
+
+	// Interfaces left out of the delta are unchanged as of this report
+	var patch struct {
+		Interfaces map[string]json.RawMessage `json:"interfaces"`
+	}
+	_ = json.Unmarshal(data.Delta.Network, &patch)
+	for name, iface := range network.Interfaces {
+		if _, changed := patch.Interfaces[name]; changed || iface == nil {
+			continue
+		}
+		iface.UpdatedAt = data.CollectedAt
+		if iface.Statistics != nil {
+			iface.Statistics.CollectedAt = data.CollectedAt
+		}
+	}
+
+	data.Metrics.Network = network
+	data.Delta = nil
+	if err := data.ValidateSchema(s.metricsLimits()); err != nil {
+		return err
+	}
+	if data.HasState() {
+		s.rememberDelta(data)
+	}
+	return nil
+}
+
+// rememberDelta keeps the state of a report as the base of the next delta
+func (s *Service) rememberDelta(data *types.MetricsData) {
+	state, err := data.Metrics.Network.State()
+	if err != nil {
+		s.logger.Warn("Failed to encode delta base",
+			zap.String("agent_id", data.AgentID),
+			zap.Error(err))
+		s.deltas.forget(data.AgentID)
+		return
+	}
+	s.deltas.set(data.AgentID, data.Timestamp, state)
+}
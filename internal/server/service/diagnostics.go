@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxDiagnosticsPerAgent is the number of diagnostics bundles kept per agent
+const maxDiagnosticsPerAgent = 5
+
+// DiagnosticsService represents agent diagnostics service interface
+type DiagnosticsService interface {
+	RequestDiagnostics(ctx context.Context, agentID string) (string, error)
+	SaveDiagnostics(ctx context.Context, agentID, commandID string, content []byte) (*types.Diagnostics, error)
+	ListDiagnostics(ctx context.Context, agentID string) ([]*types.Diagnostics, error)
+	GetDiagnostics(ctx context.Context, agentID, id string) (*types.Diagnostics, []byte, error)
+}
+
+// _ implements DiagnosticsService
+var _ DiagnosticsService = (*Service)(nil)
+
+// RequestDiagnostics asks an agent to collect a diagnostics bundle and upload
+// it, the returned command completes once the bundle is stored
+func (s *Service) RequestDiagnostics(ctx context.Context, agentID string) (string, error) {
+	cmd := types.Command{
+		ID:        fmt.Sprintf("%s-diagnostics-%s", agentID, uuid.New().String()),
+		Type:      "diagnostics",
+		Timeout:   2 * time.Minute,
+		CreatedAt: s.clock.Now(),
+	}
+
+	// The command outlives the request, it is tracked until the upload
+	if err := s.SendCommand(context.WithoutCancel(ctx), agentID, cmd); err != nil {
+		return "", err
+	}
+
+	return cmd.ID, nil
+}
+
+// SaveDiagnostics stores a diagnostics bundle uploaded by an agent, only the
+// newest bundles of each agent are kept
+func (s *Service) SaveDiagnostics(ctx context.Context, agentID, commandID string, content []byte) (*types.Diagnostics, error) {
+	agent, err := s.GetAgent(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &types.Diagnostics{
+		ID:        uuid.New().String(),
+		TenantID:  agent.TenantID,
+		AgentID:   agent.ID,
+		CommandID: commandID,
+		Size:      int64(len(content)),
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.diagnosticsRepo.Save(ctx, d, content); err != nil {
+		return nil, err
+	}
+	if err := s.diagnosticsRepo.Prune(ctx, agentID, maxDiagnosticsPerAgent); err != nil {
+		s.logger.Error("Failed to prune diagnostics",
+			zap.Error(err),
+			zap.String("agent_id", agentID))
+	}
+
+	s.logger.Info("Diagnostics received",
+		zap.String("agent_id", agentID),
+		zap.String("id", d.ID),
+		zap.Int64("size", d.Size))
+
+	if commandID != "" {
+		result, _ := json.Marshal(d)
+		if err := s.HandleCommandResult(ctx, agentID, types.CommandResult{
+			CommandID: commandID,
+			AgentID:   agentID,
+			Status:    types.CommandStatusComplete,
+			Result:    result,
+			EndTime:   d.CreatedAt,
+		}); err != nil {
+			s.logger.Debug("Diagnostics command is not tracked",
+				zap.Error(err),
+				zap.String("command_id", commandID))
+		}
+	}
+
+	return d, nil
+}
+
+// ListDiagnostics returns the diagnostics bundles of an agent, newest first
+func (s *Service) ListDiagnostics(ctx context.Context, agentID string) ([]*types.Diagnostics, error) {
+	if _, err := s.GetAgent(ctx, agentID); err != nil {
+		return nil, err
+	}
+	return s.diagnosticsRepo.List(ctx, agentID)
+}
+
+// GetDiagnostics returns a diagnostics bundle of an agent with its content,
+// the newest one when id is empty
+func (s *Service) GetDiagnostics(ctx context.Context, agentID, id string) (*types.Diagnostics, []byte, error) {
+	if id == "" {
+		return s.diagnosticsRepo.GetLatest(ctx, agentID)
+	}
+	return s.diagnosticsRepo.Get(ctx, agentID, id)
+}
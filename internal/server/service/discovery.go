@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"maps"
+	"time"
+	"wameter/internal/server/discovery"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// Tags of agents expected from discovered pods
+const (
+	discoveryTag        = "discovery"
+	discoveryKubernetes = "kubernetes"
+)
+
+// discoveryInterval is how often expected agents are checked besides pod
+// changes, for registration timeouts and cleanups
+const discoveryInterval = time.Minute
+
+// startDiscovery watches the agent pods and reconciles the agents they
+// are expected to run, the kubernetes_discovery job reconciles them besides
+// pod changes
+func (s *Service) startDiscovery() {
+	s.logger.Info("Kubernetes discovery started",
+		zap.String("namespace", s.config.Discovery.Kubernetes.Namespace),
+		zap.String("label_selector", s.config.Discovery.Kubernetes.LabelSelector))
+
+	s.discovery.Run(s.ctx, s.setDiscoveredPods)
+
+	s.logger.Info("Kubernetes discovery stopped")
+}
+
+// setDiscoveredPods replaces the current agent pods
+func (s *Service) setDiscoveredPods(pods []discovery.Pod) {
+	s.discoveryMu.Lock()
+	s.discoveredPods = pods
+	s.podsSynced = true
+	s.discoveryMu.Unlock()
+
+	s.reconcileDiscoveredAgents()
+}
+
+// reconcileDiscoveredAgents pre-registers the agents of discovered pods,
+// marks those that did not register in time missing and deletes the
+// agents whose pods are gone. Only the leader changes agents.
+func (s *Service) reconcileDiscoveredAgents() {
+	if !s.isLeader() {
+		return
+	}
+
+	s.discoveryMu.Lock()
+	defer s.discoveryMu.Unlock()
+
+	// Without a pod list every agent would look deleted
+	if !s.podsSynced {
+		return
+	}
+
+	cfg := &s.config.Discovery.Kubernetes
+	now := s.clock.Now()
+	var remove []*types.AgentInfo
+
+	s.agentsMu.Lock()
+
+	// Agents that registered, by hostname
+	byHost := make(map[string]*types.AgentInfo)
+	for _, agent := range s.agents {
+		if isPlaceholder(agent) || agent.Status == types.AgentStatusRetired {
+			continue
+		}
+		if prev, ok := byHost[agent.Hostname]; !ok || agent.LastSeen.After(prev.LastSeen) {
+			byHost[agent.Hostname] = agent
+		}
+	}
+
+	expected := make(map[string]bool)
+	for _, pod := range s.discoveredPods {
+		id, agent := pod.Annotations[cfg.IDAnnotation], (*types.AgentInfo)(nil)
+		if id != "" {
+			agent = s.agents[id]
+		} else if agent = byHost[pod.Node]; agent == nil {
+			id = pod.Node
+			agent = s.agents[id]
+		}
+		if agent != nil {
+			id = agent.ID
+		}
+		expected[id] = true
+
+		if agent == nil {
+			s.preRegisterAgent(id, &pod, now)
+			continue
+		}
+		if agent.Status == types.AgentStatusRetired {
+			continue
+		}
+		s.tagDiscoveredAgent(agent, &pod)
+
+		if agent.Status == types.AgentStatusExpected && now.Sub(agent.RegisteredAt) > cfg.RegistrationTimeout {
+			if err := s.agentRepo.UpdateStatus(s.ctx, id, types.AgentStatusMissing); err != nil {
+				s.logger.Error("Failed to mark agent missing",
+					zap.Error(err),
+					zap.String("agent_id", id))
+				continue
+			}
+			agent.Status = types.AgentStatusMissing
+			agent.UpdatedAt = now
+			s.logger.Warn("Expected agent did not register",
+				zap.String("agent_id", id),
+				zap.String("node", pod.Node),
+				zap.String("pod", pod.Key()))
+		}
+	}
+
+	for id, agent := range s.agents {
+		if agent.Tags[discoveryTag] != discoveryKubernetes || expected[id] {
+			delete(s.podsGone, id)
+			continue
+		}
+		// Placeholders go with their pod or once their agent registered
+		if isPlaceholder(agent) {
+			remove = append(remove, agent)
+			continue
+		}
+		since, ok := s.podsGone[id]
+		if !ok {
+			s.podsGone[id] = now
+			continue
+		}
+		if cfg.KeepDeleted || agent.Status == types.AgentStatusOnline ||
+			agent.Status == types.AgentStatusRetired || now.Sub(since) < cfg.CleanupAfter {
+			continue
+		}
+		remove = append(remove, agent)
+	}
+
+	s.agentsMu.Unlock()
+
+	for _, agent := range remove {
+		ctx := tenant.WithContext(s.ctx, agent.TenantID)
+		if err := s.agentRepo.Delete(ctx, agent.ID); err != nil && !errors.Is(err, types.ErrAgentNotFound) {
+			s.logger.Error("Failed to delete agent of deleted pod",
+				zap.Error(err),
+				zap.String("agent_id", agent.ID))
+			continue
+		}
+		s.forgetAgent(ctx, agent.ID)
+		delete(s.podsGone, agent.ID)
+
+		s.logger.Info("Agent of deleted pod removed",
+			zap.String("id", agent.ID),
+			zap.String("hostname", agent.Hostname),
+			zap.String("pod", agent.Tags["k8s_namespace"]+"/"+agent.Tags["k8s_pod"]))
+	}
+}
+
+// preRegisterAgent records the agent expected to run in pod, it is
+// expected until it registers. Guarded by agentsMu.
+func (s *Service) preRegisterAgent(id string, pod *discovery.Pod, now time.Time) {
+	tenantID := s.config.Discovery.Kubernetes.TenantID
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+
+	agent := &types.AgentInfo{
+		ID:           id,
+		TenantID:     tenantID,
+		Hostname:     pod.Node,
+		Status:       types.AgentStatusExpected,
+		Tags:         podTags(nil, pod),
+		RegisteredAt: now,
+		UpdatedAt:    now,
+	}
+	if err := s.agentRepo.Save(s.ctx, agent); err != nil {
+		s.logger.Error("Failed to pre-register agent",
+			zap.Error(err),
+			zap.String("agent_id", id))
+		return
+	}
+	s.agents[id] = agent
+
+	s.logger.Info("Agent pre-registered from pod",
+		zap.String("id", id),
+		zap.String("node", pod.Node),
+		zap.String("pod", pod.Key()))
+}
+
+// tagDiscoveredAgent records the pod of an agent in its tags. Guarded by
+// agentsMu.
+func (s *Service) tagDiscoveredAgent(agent *types.AgentInfo, pod *discovery.Pod) {
+	tags := podTags(agent.Tags, pod)
+	if maps.Equal(tags, agent.Tags) {
+		return
+	}
+
+	updated := *agent
+	updated.Tags = tags
+	updated.UpdatedAt = s.clock.Now()
+	if err := s.agentRepo.UpdateAgent(tenant.WithContext(s.ctx, agent.TenantID), &updated); err != nil {
+		s.logger.Error("Failed to tag discovered agent",
+			zap.Error(err),
+			zap.String("agent_id", agent.ID))
+		return
+	}
+	*agent = updated
+}
+
+// podTags returns tags with those identifying pod
+func podTags(tags map[string]string, pod *discovery.Pod) map[string]string {
+	res := maps.Clone(tags)
+	if res == nil {
+		res = make(map[string]string)
+	}
+	res[discoveryTag] = discoveryKubernetes
+	res["k8s_namespace"] = pod.Namespace
+	res["k8s_pod"] = pod.Name
+	res["k8s_node"] = pod.Node
+	return res
+}
+
+// isPlaceholder returns true for a pre-registered agent that never reported
+func isPlaceholder(agent *types.AgentInfo) bool {
+	return agent.Tags[discoveryTag] == discoveryKubernetes && agent.LastSeen.IsZero()
+}
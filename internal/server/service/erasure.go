@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+	"wameter/internal/server/archive"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErasureService represents data erasure service interface
+type ErasureService interface {
+	EraseData(ctx context.Context, req *types.ErasureRequest) (*types.Erasure, error)
+	GetErasure(ctx context.Context, id string) (*types.Erasure, error)
+	ListErasures(ctx context.Context) ([]*types.Erasure, error)
+}
+
+// _ implements ErasureService
+var _ ErasureService = (*Service)(nil)
+
+// tombstone represents the erasure of an agent, its reports up to the
+// erasure are rejected, and all of them while the erasure runs
+type tombstone struct {
+	at      time.Time
+	pending bool
+}
+
+// EraseData erases all data of an agent, or of every agent of a hostname.
+// The agents are forgotten at once and their reports rejected, their data
+// is deleted in the background and the returned erasure completed with a
+// report of what was deleted. Metrics archived in bulk are not rewritten,
+// only the archives of decommissioned agents are deleted.
+func (s *Service) EraseData(ctx context.Context, req *types.ErasureRequest) (*types.Erasure, error) {
+	req.AgentID = strings.TrimSpace(req.AgentID)
+	req.Hostname = strings.TrimSpace(req.Hostname)
+	if (req.AgentID == "") == (req.Hostname == "") {
+		return nil, fmt.Errorf("%w: either agent_id or hostname is required", types.ErrInvalidErasure)
+	}
+
+	agentIDs, err := s.erasureRepo.FindAgents(ctx, req.AgentID, req.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	if len(agentIDs) == 0 {
+		return nil, types.ErrAgentNotFound
+	}
+
+	e := &types.Erasure{
+		ID:          uuid.New().String(),
+		TenantID:    tenant.OrDefault(ctx),
+		AgentID:     req.AgentID,
+		Hostname:    req.Hostname,
+		AgentIDs:    agentIDs,
+		Reason:      req.Reason,
+		RequestedBy: req.RequestedBy,
+		Status:      types.ErasurePending,
+		RequestedAt: s.clock.Now(),
+	}
+
+	// Tombstones first, so no report of the agents is stored from here on
+	s.tombstonesMu.Lock()
+	for _, id := range agentIDs {
+		if s.tombstones[id].pending {
+			s.tombstonesMu.Unlock()
+			return nil, fmt.Errorf("%w: %s", types.ErrErasurePending, id)
+		}
+	}
+	for _, id := range agentIDs {
+		s.tombstones[id] = tombstone{at: e.RequestedAt, pending: true}
+	}
+	s.tombstonesMu.Unlock()
+
+	if err := s.erasureRepo.Save(ctx, e); err != nil {
+		s.tombstonesMu.Lock()
+		for _, id := range agentIDs {
+			delete(s.tombstones, id)
+		}
+		s.tombstonesMu.Unlock()
+		return nil, err
+	}
+
+	for _, id := range agentIDs {
+		s.forgetAgent(ctx, id)
+	}
+
+	s.log(ctx).Info("Erasing agent data",
+		zap.String("erasure_id", e.ID),
+		zap.Strings("agent_ids", agentIDs),
+		zap.String("requested_by", e.RequestedBy))
+
+	erasure := *e
+	s.goBackground(func() {
+		s.runErasure(tenant.WithContext(s.ctx, erasure.TenantID), &erasure)
+	})
+
+	return e, nil
+}
+
+// GetErasure returns an erasure
+func (s *Service) GetErasure(ctx context.Context, id string) (*types.Erasure, error) {
+	return s.erasureRepo.FindByID(ctx, id)
+}
+
+// ListErasures returns the erasures, newest first
+func (s *Service) ListErasures(ctx context.Context) ([]*types.Erasure, error) {
+	return s.erasureRepo.List(ctx)
+}
+
+// runErasure deletes the data of the agents of an erasure, verifies none is
+// left and records the outcome with an audit entry
+func (s *Service) runErasure(ctx context.Context, e *types.Erasure) {
+	report := &types.ErasureReport{
+		Deleted:   make(map[string]int64),
+		Remaining: make(map[string]int64),
+	}
+
+	var errs []error
+	for _, id := range e.AgentIDs {
+		report.Commands += s.eraseCommands(id)
+
+		// Archives are located through the decommission deleted with the agent
+		if location, err := s.eraseArchive(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", id, err))
+		} else if location != "" {
+			report.Archives = append(report.Archives, location)
+		}
+
+		deleted, err := s.erasureRepo.EraseAgent(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", id, err))
+			continue
+		}
+		for table, n := range deleted {
+			report.Deleted[table] += n
+		}
+		s.forgetAgent(ctx, id)
+
+		remaining, err := s.erasureRepo.CountAgentData(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", id, err))
+			continue
+		}
+		for table, n := range remaining {
+			report.Remaining[table] += n
+		}
+	}
+
+	report.Verified = len(errs) == 0 && len(report.Remaining) == 0
+	if len(report.Remaining) > 0 {
+		errs = append(errs, fmt.Errorf("data left after erasure in %d tables", len(report.Remaining)))
+	} else {
+		report.Remaining = nil
+	}
+
+	now := s.clock.Now()
+	e.Report = report
+	e.CompletedAt = &now
+	e.Status = types.ErasureCompleted
+	if err := errors.Join(errs...); err != nil {
+		e.Status = types.ErasureFailed
+		e.Error = err.Error()
+	}
+
+	if err := s.erasureRepo.Update(ctx, e); err != nil {
+		s.logger.Error("Failed to record erasure",
+			zap.Error(err),
+			zap.String("erasure_id", e.ID))
+	}
+
+	// Failed erasures are requested again, completed ones keep rejecting old reports
+	s.tombstonesMu.Lock()
+	for _, id := range e.AgentIDs {
+		if e.Status == types.ErasureCompleted {
+			s.tombstones[id] = tombstone{at: e.RequestedAt}
+		} else {
+			delete(s.tombstones, id)
+		}
+	}
+	s.tombstonesMu.Unlock()
+
+	status := http.StatusOK
+	if e.Status != types.ErasureCompleted {
+		status = http.StatusInternalServerError
+	}
+	if err := s.RecordAudit(ctx, &types.AuditEntry{
+		TenantID: e.TenantID,
+		Actor:    e.RequestedBy,
+		Action:   "data.erased",
+		Resource: e.ID,
+		Status:   status,
+	}); err != nil {
+		s.logger.Error("Failed to record erasure audit entry",
+			zap.Error(err),
+			zap.String("erasure_id", e.ID))
+	}
+
+	if e.Status == types.ErasureCompleted {
+		s.logger.Info("Agent data erased",
+			zap.String("erasure_id", e.ID),
+			zap.Strings("agent_ids", e.AgentIDs),
+			zap.Any("deleted", report.Deleted),
+			zap.Int("archives", len(report.Archives)))
+		return
+	}
+	s.logger.Error("Failed to erase agent data",
+		zap.String("erasure_id", e.ID),
+		zap.Strings("agent_ids", e.AgentIDs),
+		zap.String("error", e.Error))
+}
+
+// eraseArchive deletes the metrics archived when an agent was retired and
+// returns where they were, empty when the agent has no archive
+func (s *Service) eraseArchive(ctx context.Context, agentID string) (string, error) {
+	d, err := s.decommissionRepo.FindByAgent(ctx, agentID)
+	if errors.Is(err, types.ErrAgentNotRetired) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if d.ArchiveLocation == "" {
+		return "", nil
+	}
+
+	store, err := archive.New(d.Archive, &s.GetConfig().Archive)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Delete(ctx, d.ArchiveLocation); err != nil {
+		return "", err
+	}
+	return d.ArchiveLocation, nil
+}
+
+// eraseCommands cancels and forgets the commands of an agent, in flight,
+// queued for polling or in its history, and returns how many there were
+func (s *Service) eraseCommands(agentID string) int {
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+
+	var erased int
+	for id, tracker := range s.commands {
+		if tracker.agentID == agentID {
+			tracker.cancelFunc()
+			delete(s.commands, id)
+			erased++
+		}
+	}
+	erased += len(s.history[agentID])
+	delete(s.history, agentID)
+	delete(s.pulls, agentID)
+	return erased
+}
+
+// checkTombstone rejects the reports of erased agents up to the erasure,
+// and all their reports while it runs
+func (s *Service) checkTombstone(data *types.MetricsData) error {
+	if s.erasedSince(data.AgentID, data.Timestamp) {
+		return fmt.Errorf("%w: %s", types.ErrAgentErased, data.AgentID)
+	}
+	return nil
+}
+
+// erasedSince reports whether an agent was erased at or after t, or is
+// being erased
+func (s *Service) erasedSince(agentID string, t time.Time) bool {
+	s.tombstonesMu.RLock()
+	defer s.tombstonesMu.RUnlock()
+
+	ts, ok := s.tombstones[agentID]
+	return ok && (ts.pending || !t.After(ts.at))
+}
+
+// checkErasurePending refuses registering an agent whose data is being erased
+func (s *Service) checkErasurePending(agentID string) error {
+	s.tombstonesMu.RLock()
+	defer s.tombstonesMu.RUnlock()
+
+	if s.tombstones[agentID].pending {
+		return types.ErrErasurePending
+	}
+	return nil
+}
+
+// loadErasures loads the tombstones of erased agents and resumes the
+// erasures interrupted by a restart
+func (s *Service) loadErasures() {
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	erasures, err := s.erasureRepo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load erasures", zap.Error(err))
+		return
+	}
+
+	tombstones := make(map[string]tombstone)
+	var pending []*types.Erasure
+	for _, e := range erasures {
+		switch e.Status {
+		case types.ErasurePending:
+			pending = append(pending, e)
+		case types.ErasureCompleted:
+		default:
+			continue
+		}
+		for _, id := range e.AgentIDs {
+			if t, ok := tombstones[id]; !ok || e.RequestedAt.After(t.at) {
+				tombstones[id] = tombstone{at: e.RequestedAt, pending: e.Status == types.ErasurePending}
+			}
+		}
+	}
+
+	s.tombstonesMu.Lock()
+	maps.Copy(s.tombstones, tombstones)
+	s.tombstonesMu.Unlock()
+
+	for _, e := range pending {
+		s.logger.Info("Resuming erasure", zap.String("erasure_id", e.ID))
+		s.goBackground(func() {
+			s.runErasure(tenant.WithContext(s.ctx, e.TenantID), e)
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"wameter/internal/server/data/repository"
+	"wameter/internal/types"
+)
+
+// grafanaAnnotationLimit caps the annotations returned by a query
+const grafanaAnnotationLimit = 1000
+
+// GrafanaService represents Grafana JSON datasource service interface
+type GrafanaService interface {
+	SearchGrafanaTargets(ctx context.Context, search *types.GrafanaSearch) ([]string, error)
+	QueryGrafana(ctx context.Context, query *types.GrafanaQuery) ([]*types.GrafanaSeries, error)
+	GetGrafanaAnnotations(ctx context.Context, query *types.GrafanaAnnotationQuery) ([]*types.GrafanaAnnotation, error)
+}
+
+// _ implements GrafanaService
+var _ GrafanaService = (*Service)(nil)
+
+// grafanaPattern represents a parsed agent/interface/metric target
+type grafanaPattern struct {
+	target *types.GrafanaTarget
+	agent  string
+	iface  string
+	metric string
+}
+
+// match reports whether the series of an interface metric matches the pattern
+func (p *grafanaPattern) match(agentID, iface, metric string) bool {
+	return globMatch(p.agent, agentID) && globMatch(p.iface, iface) && globMatch(p.metric, metric)
+}
+
+// grafanaBucket accumulates the values of a series within a step
+type grafanaBucket struct {
+	sum   float64
+	count int
+}
+
+// SearchGrafanaTargets returns the agent/interface/metric targets of the
+// interfaces last reported by the accessible agents
+func (s *Service) SearchGrafanaTargets(ctx context.Context, search *types.GrafanaSearch) ([]string, error) {
+	agents, _, err := s.GetAgents(ctx, &types.AgentFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agents: %w", err)
+	}
+
+	needle := strings.ToLower(search.Target)
+	var targets []string
+	for _, agent := range agents {
+		latest, err := s.GetLatestMetrics(ctx, agent.ID)
+		if err != nil || latest == nil || latest.Metrics.Network == nil {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(latest.Metrics.Network.Interfaces)) {
+			if latest.Metrics.Network.Interfaces[name].Statistics == nil {
+				continue
+			}
+			for _, metric := range types.InterfaceMetrics {
+				target := agent.ID + "/" + name + "/" + metric
+				if strings.Contains(strings.ToLower(target), needle) {
+					targets = append(targets, target)
+				}
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// QueryGrafana returns the series matching the targets of the query,
+// averaged into steps of the larger of the query interval and the range
+// divided by the maximum number of datapoints
+func (s *Service) QueryGrafana(ctx context.Context, query *types.GrafanaQuery) ([]*types.GrafanaSeries, error) {
+	var patterns []*grafanaPattern
+	for _, target := range query.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		p, err := parseGrafanaTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return []*types.GrafanaSeries{}, nil
+	}
+
+	agentIDs, err := s.grafanaAgents(ctx, func(id string) bool {
+		return slices.ContainsFunc(patterns, func(p *grafanaPattern) bool {
+			return globMatch(p.agent, id)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(agentIDs) == 0 {
+		return []*types.GrafanaSeries{}, nil
+	}
+
+	step := query.IntervalMs
+	if query.MaxDataPoints > 0 {
+		step = max(step, query.Range.To.Sub(query.Range.From).Milliseconds()/int64(query.MaxDataPoints))
+	}
+	step = max(step, 1)
+
+	// Buckets of each series in the order of the targets
+	series := make(map[*grafanaPattern]map[string]map[int64]*grafanaBucket)
+	err = s.metricsRepo.Stream(ctx, repository.QueryParams{
+		AgentIDs:  agentIDs,
+		StartTime: query.Range.From,
+		EndTime:   query.Range.To,
+	}, func(data *types.MetricsData) error {
+		if data.Metrics.Network == nil {
+			return nil
+		}
+		at := data.Timestamp.UnixMilli() / step * step
+		for _, iface := range data.Metrics.Network.Interfaces {
+			if iface.Statistics == nil {
+				continue
+			}
+			for _, p := range patterns {
+				for _, metric := range types.InterfaceMetrics {
+					if !p.match(data.AgentID, iface.Name, metric) {
+						continue
+					}
+					value, ok := iface.Statistics.Metric(metric)
+					if !ok {
+						continue
+					}
+					if series[p] == nil {
+						series[p] = make(map[string]map[int64]*grafanaBucket)
+					}
+					name := data.AgentID + "/" + iface.Name + "/" + metric
+					if series[p][name] == nil {
+						series[p][name] = make(map[int64]*grafanaBucket)
+					}
+					b, ok := series[p][name][at]
+					if !ok {
+						b = &grafanaBucket{}
+						series[p][name][at] = b
+					}
+					b.sum += value
+					b.count++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+
+	result := []*types.GrafanaSeries{}
+	for _, p := range patterns {
+		for _, name := range slices.Sorted(maps.Keys(series[p])) {
+			buckets := series[p][name]
+			points := make([][2]float64, 0, len(buckets))
+			for _, at := range slices.Sorted(maps.Keys(buckets)) {
+				b := buckets[at]
+				points = append(points, [2]float64{b.sum / float64(b.count), float64(at)})
+			}
+			result = append(result, &types.GrafanaSeries{
+				Target:     name,
+				RefID:      p.target.RefID,
+				Datapoints: points,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// GetGrafanaAnnotations returns the stored events of the queried types and
+// tags, or the IP changes or alert periods of the accessible agents matching the
+// annotation query within its range
+func (s *Service) GetGrafanaAnnotations(ctx context.Context, query *types.GrafanaAnnotationQuery) ([]*types.GrafanaAnnotation, error) {
+	fields := strings.Fields(query.Annotation.Query)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: annotation query is empty", types.ErrInvalidGrafanaQuery)
+	}
+	source := types.GrafanaAnnotationSource(fields[0])
+	if source == types.GrafanaAnnotationEvents {
+		return s.eventAnnotations(ctx, query, fields[1:])
+	}
+
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("%w: annotation query %q", types.ErrInvalidGrafanaQuery, query.Annotation.Query)
+	}
+	pattern := "*"
+	if len(fields) == 2 {
+		pattern = fields[1]
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: agent pattern %s", types.ErrInvalidGrafanaQuery, pattern)
+	}
+
+	agentIDs, err := s.grafanaAgents(ctx, func(id string) bool {
+		return globMatch(pattern, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []*types.GrafanaAnnotation
+	switch source {
+	case types.GrafanaAnnotationIPChanges:
+		if len(agentIDs) > 0 {
+			annotations, err = s.ipChangeAnnotations(ctx, agentIDs, query.Range)
+		}
+	case types.GrafanaAnnotationAlerts:
+		if len(agentIDs) > 0 {
+			annotations, err = s.alertAnnotations(ctx, agentIDs, query.Range)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported annotation source %s", types.ErrInvalidGrafanaQuery, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*types.GrafanaAnnotation, 0, len(annotations))
+	for _, a := range annotations {
+		a.Annotation = query.Annotation
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// eventAnnotations returns the stored annotations of any of the type=<type>
+// arguments having all other arguments as tags
+func (s *Service) eventAnnotations(ctx context.Context, query *types.GrafanaAnnotationQuery, args []string) ([]*types.GrafanaAnnotation, error) {
+	filter := &types.AnnotationFilter{
+		StartTime: query.Range.From,
+		EndTime:   query.Range.To,
+		Limit:     grafanaAnnotationLimit,
+	}
+	for _, arg := range args {
+		if t, ok := strings.CutPrefix(arg, "type="); ok {
+			filter.Types = append(filter.Types, types.AnnotationType(t))
+		} else {
+			filter.Tags = append(filter.Tags, arg)
+		}
+	}
+
+	events, err := s.annotationRepo.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get annotations: %w", err)
+	}
+
+	annotations := make([]*types.GrafanaAnnotation, 0, len(events))
+	for _, event := range events {
+		a := &types.GrafanaAnnotation{
+			Annotation: query.Annotation,
+			Time:       event.Time.UnixMilli(),
+			Title:      event.Title,
+			Text:       event.Text,
+			Tags:       append([]string{string(event.Type)}, event.Tags...),
+		}
+		if event.AgentID != "" {
+			a.Tags = append(a.Tags, event.AgentID)
+		}
+		if event.EndTime != nil {
+			a.TimeEnd = event.EndTime.UnixMilli()
+			a.IsRegion = true
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, nil
+}
+
+// ipChangeAnnotations returns an event per IP change of the agents
+func (s *Service) ipChangeAnnotations(ctx context.Context, agentIDs []string, r types.GrafanaRange) ([]*types.GrafanaAnnotation, error) {
+	changes, err := s.ipChangeRepo.Query(ctx, &types.IPChangeFilter{
+		AgentIDs:  agentIDs,
+		StartTime: r.From,
+		EndTime:   r.To,
+		Limit:     grafanaAnnotationLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IP changes: %w", err)
+	}
+
+	annotations := make([]*types.GrafanaAnnotation, 0, len(changes))
+	for _, change := range changes {
+		text := fmt.Sprintf("%s %s: %s -> %s", change.Action, change.Version,
+			strings.Join(change.OldAddrs, ", "), strings.Join(change.NewAddrs, ", "))
+		if change.Reason != "" {
+			text += " (" + change.Reason + ")"
+		}
+		tags := []string{"ip_change", string(change.Action), string(change.Version), change.AgentID, change.InterfaceName}
+		if change.IsExternal {
+			tags = append(tags, "external")
+		}
+		annotations = append(annotations, &types.GrafanaAnnotation{
+			Time:  change.Timestamp.UnixMilli(),
+			Title: fmt.Sprintf("IP change on %s/%s", change.AgentID, change.InterfaceName),
+			Text:  text,
+			Tags:  tags,
+		})
+	}
+	return annotations, nil
+}
+
+// alertAnnotations returns a region per period an interface of the agents
+// exceeded the error or utilization alert threshold, derived from the
+// stored reports with the current thresholds
+func (s *Service) alertAnnotations(ctx context.Context, agentIDs []string, r types.GrafanaRange) ([]*types.GrafanaAnnotation, error) {
+	type alertKey struct {
+		agentID, iface, alert string
+	}
+	active := make(map[alertKey]*types.GrafanaAnnotation)
+	var annotations []*types.GrafanaAnnotation
+	errorsCfg := s.GetConfig().NetErrors
+	netErrors := newNetworkErrorTracker()
+
+	err := s.metricsRepo.Stream(ctx, repository.QueryParams{
+		AgentIDs:  agentIDs,
+		StartTime: r.From,
+		EndTime:   r.To,
+	}, func(data *types.MetricsData) error {
+		if data.Metrics.Network == nil {
+			return nil
+		}
+		at := data.Timestamp.UnixMilli()
+		for _, iface := range data.Metrics.Network.Interfaces {
+			if iface.Statistics == nil {
+				continue
+			}
+			errorsHigh, _ := netErrors.check(&errorsCfg, data.AgentID+"/"+iface.Name, iface.Statistics, data.Timestamp)
+			for _, alert := range []struct {
+				name   string
+				firing bool
+			}{
+				{"network_errors", errorsHigh},
+				{"high_utilization", s.highUtilization(iface)},
+			} {
+				name, firing := alert.name, alert.firing
+				key := alertKey{agentID: data.AgentID, iface: iface.Name, alert: name}
+				a, ok := active[key]
+				switch {
+				case firing && ok:
+					a.TimeEnd = at
+				case firing && len(annotations) < grafanaAnnotationLimit:
+					a = &types.GrafanaAnnotation{
+						Time:     at,
+						TimeEnd:  at,
+						IsRegion: true,
+						Title:    fmt.Sprintf("%s on %s/%s", strings.ReplaceAll(name, "_", " "), data.AgentID, iface.Name),
+						Tags:     []string{"alert", name, data.AgentID, iface.Name},
+					}
+					active[key] = a
+					annotations = append(annotations, a)
+				case !firing && ok:
+					a.TimeEnd = at
+					delete(active, key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// grafanaAgents returns the IDs of the accessible agents accepted by match
+func (s *Service) grafanaAgents(ctx context.Context, match func(id string) bool) ([]string, error) {
+	agents, _, err := s.GetAgents(ctx, &types.AgentFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agents: %w", err)
+	}
+
+	var ids []string
+	for _, agent := range agents {
+		if match(agent.ID) {
+			ids = append(ids, agent.ID)
+		}
+	}
+	return ids, nil
+}
+
+// parseGrafanaTarget parses an agent/interface/metric target, a missing
+// metric or interface matches all
+func parseGrafanaTarget(target *types.GrafanaTarget) (*grafanaPattern, error) {
+	if target.Type != "" && target.Type != "timeserie" {
+		return nil, fmt.Errorf("%w: unsupported target type %s", types.ErrInvalidGrafanaQuery, target.Type)
+	}
+
+	segments := strings.Split(target.Target, "/")
+	if len(segments) > 3 {
+		return nil, fmt.Errorf("%w: target %q, expected agent/interface/metric", types.ErrInvalidGrafanaQuery, target.Target)
+	}
+	for len(segments) < 3 {
+		segments = append(segments, "*")
+	}
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("%w: target %q: %v", types.ErrInvalidGrafanaQuery, target.Target, err)
+		}
+	}
+
+	return &grafanaPattern{
+		target: target,
+		agent:  segments[0],
+		iface:  segments[1],
+		metric: segments[2],
+	}, nil
+}
+
+// globMatch reports whether name matches a validated glob pattern
+func globMatch(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"runtime"
 	"time"
+	"wameter/internal/profiling"
 	"wameter/internal/types"
 	"wameter/internal/version"
 
@@ -17,12 +18,13 @@ import (
 type HealthService interface {
 	HealthCheck(ctx context.Context) *types.HealthStatus
 	GetServiceMetrics(ctx context.Context) *types.ServiceMetrics
+	GetServiceStats(ctx context.Context) *types.ServiceStats
 	GetComponentStatus(ctx context.Context) map[string]*types.ComponentStatus
 }
 
 // StartHealthCheck starts periodic health checking
 func (s *Service) StartHealthCheck(ctx context.Context) {
-	ticker := time.NewTicker(time.Minute)
+	ticker := s.clock.NewTicker(time.Minute)
 	go func() {
 		for {
 			select {
@@ -50,10 +52,11 @@ func (s *Service) StopHealthCheck() {
 func (s *Service) HealthCheck(ctx context.Context) *types.HealthStatus {
 	status := &types.HealthStatus{
 		Healthy:   true,
-		Timestamp: time.Now(),
+		Timestamp: s.clock.Now().UTC(),
 		Version:   version.GetInfo().Version,
-		StartTime: s.startTime,
-		Uptime:    time.Since(s.startTime),
+		StartTime: s.startTime.UTC(),
+		Uptime:    s.clock.Since(s.startTime),
+		Timezone:  s.GetConfig().Server.Location().String(),
 	}
 
 	// Check database health
@@ -63,31 +66,31 @@ func (s *Service) HealthCheck(ctx context.Context) *types.HealthStatus {
 			Name:      "database",
 			Status:    "unhealthy",
 			Error:     err.Error(),
-			LastCheck: time.Now(),
+			LastCheck: s.clock.Now(),
 		})
 	} else {
 		status.Details = append(status.Details, types.ComponentStatus{
 			Name:      "database",
 			Status:    "healthy",
-			LastCheck: time.Now(),
+			LastCheck: s.clock.Now(),
 		})
 	}
 
 	// Check notification service
-	if s.notifier != nil {
+	if s.notifier.Enabled() {
 		if err := s.notifier.Check(ctx); err != nil {
 			status.Healthy = false
 			status.Details = append(status.Details, types.ComponentStatus{
 				Name:      "notifier",
 				Status:    "unhealthy",
 				Error:     err.Error(),
-				LastCheck: time.Now(),
+				LastCheck: s.clock.Now(),
 			})
 		} else {
 			status.Details = append(status.Details, types.ComponentStatus{
 				Name:      "notifier",
 				Status:    "healthy",
-				LastCheck: time.Now(),
+				LastCheck: s.clock.Now(),
 			})
 		}
 	}
@@ -106,7 +109,7 @@ func (s *Service) HealthCheck(ctx context.Context) *types.HealthStatus {
 		Name:      "agent_monitoring",
 		Status:    "healthy",
 		Message:   fmt.Sprintf("Active agents: %d", activeAgents),
-		LastCheck: time.Now(),
+		LastCheck: s.clock.Now(),
 	})
 
 	return status
@@ -114,9 +117,6 @@ func (s *Service) HealthCheck(ctx context.Context) *types.HealthStatus {
 
 // GetServiceMetrics returns service metrics
 func (s *Service) GetServiceMetrics(_ context.Context) *types.ServiceMetrics {
-	s.statsMu.RLock()
-	defer s.statsMu.RUnlock()
-
 	metrics := &types.ServiceMetrics{
 		StartTime:  s.startTime,
 		SystemInfo: s.collectSystemStats(),
@@ -148,9 +148,53 @@ func (s *Service) GetServiceMetrics(_ context.Context) *types.ServiceMetrics {
 	metrics.LastErrorTime = s.stats.lastErrorTime
 	s.statsMu.RUnlock()
 
+	for _, n := range s.notifier.Stats() {
+		metrics.Notifications += n.Sent
+	}
+
+	metrics.Ingest = s.GetIngestStats()
+
 	return metrics
 }
 
+// GetServiceStats returns the service counters since the server started
+func (s *Service) GetServiceStats(_ context.Context) *types.ServiceStats {
+	stats := &types.ServiceStats{
+		StartTime:     s.startTime,
+		Uptime:        s.clock.Since(s.startTime),
+		Notifications: s.notifier.Stats(),
+		Ingest:        s.GetIngestStats(),
+	}
+
+	if dbStats := s.getDatabaseStats(); dbStats != nil {
+		stats.Database = *dbStats
+	}
+
+	s.agentsMu.RLock()
+	stats.TotalAgents = len(s.agents)
+	for _, agent := range s.agents {
+		if agent.Status == types.AgentStatusOnline {
+			stats.ActiveAgents++
+		}
+	}
+	s.agentsMu.RUnlock()
+
+	s.statsMu.RLock()
+	stats.MetricsProcessed = s.stats.metricsProcessed
+	stats.IPChanges = s.stats.ipChanges
+	stats.ErrorCount = s.stats.errorCount
+	stats.LastError = s.stats.lastError
+	stats.LastErrorTime = s.stats.lastErrorTime
+	stats.Commands = s.stats.commands
+	s.statsMu.RUnlock()
+
+	s.commandsMu.RLock()
+	stats.Commands.Pending = len(s.commands)
+	s.commandsMu.RUnlock()
+
+	return stats
+}
+
 // GetComponentStatus returns detailed component status
 func (s *Service) GetComponentStatus(ctx context.Context) map[string]*types.ComponentStatus {
 	statuses := make(map[string]*types.ComponentStatus)
@@ -158,7 +202,7 @@ func (s *Service) GetComponentStatus(ctx context.Context) map[string]*types.Comp
 	// Check database
 	dbStatus := &types.ComponentStatus{
 		Name:      "database",
-		LastCheck: time.Now(),
+		LastCheck: s.clock.Now(),
 	}
 	if err := s.checkDatabaseHealth(ctx); err != nil {
 		dbStatus.Status = "unhealthy"
@@ -169,10 +213,10 @@ func (s *Service) GetComponentStatus(ctx context.Context) map[string]*types.Comp
 	statuses["database"] = dbStatus
 
 	// Check notifier
-	if s.notifier != nil {
+	if s.notifier.Enabled() {
 		notifierStatus := &types.ComponentStatus{
 			Name:      "notifier",
-			LastCheck: time.Now(),
+			LastCheck: s.clock.Now(),
 		}
 		if err := s.notifier.Check(ctx); err != nil {
 			notifierStatus.Status = "unhealthy"
@@ -186,7 +230,7 @@ func (s *Service) GetComponentStatus(ctx context.Context) map[string]*types.Comp
 	// Check agent monitoring
 	monitoringStatus := &types.ComponentStatus{
 		Name:      "agent_monitoring",
-		LastCheck: time.Now(),
+		LastCheck: s.clock.Now(),
 	}
 	s.agentsMu.RLock()
 	activeAgents := 0
@@ -205,7 +249,7 @@ func (s *Service) GetComponentStatus(ctx context.Context) map[string]*types.Comp
 	systemStatus := &types.ComponentStatus{
 		Name:      "system",
 		Status:    "healthy",
-		LastCheck: time.Now(),
+		LastCheck: s.clock.Now(),
 		Message: fmt.Sprintf("Goroutines: %d, Memory: %dMB, GC: %d",
 			sysStats.NumGoroutine,
 			sysStats.MemStats.Alloc/1024/1024,
@@ -230,6 +274,9 @@ func (s *Service) collectSystemStats() *types.SystemStats {
 	// Get CPU usage (simplified)
 	stats.CPUUsage = 0.0 // TODO: Implement CPU usage calculation
 
+	// Heap and GC pause details
+	stats.Runtime = profiling.ReadRuntimeStats()
+
 	return stats
 }
 
@@ -249,6 +296,9 @@ func (s *Service) getDatabaseStats() *types.DatabaseStats {
 		ErrorCount:      stats.QueryErrors,
 		SlowQueries:     stats.SlowQueries,
 		AvgQueryTime:    stats.AvgQueryTime,
+		WaitDuration:    stats.WaitDuration,
+		CacheHits:       stats.CacheHits,
+		CacheMisses:     stats.CacheMisses,
 	}
 }
 
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+	"wameter/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// sendHeartbeat pings the dead man's switch and notifies the heartbeat.
+// While a critical check fails the failure URL is pinged instead, or
+// nothing so the switch alerts once its grace period passes.
+func (s *Service) sendHeartbeat(ctx context.Context, cfg *config.HeartbeatConfig) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	hb := s.heartbeat()
+	status := s.Liveness(ctx)
+	if !status.OK() {
+		var failed []string
+		for name, check := range status.Checks {
+			if check.Critical && check.Status == probeFail {
+				failed = append(failed, name)
+			}
+		}
+		s.logger.Warn("Server unhealthy, heartbeat withheld",
+			zap.Strings("failed_checks", failed))
+
+		if cfg.FailURL != "" {
+			if err := s.pingHeartbeat(ctx, cfg, cfg.FailURL, hb); err != nil {
+				s.logger.Error("Failed to ping heartbeat failure URL", zap.Error(err))
+			}
+		}
+		return
+	}
+
+	if cfg.URL != "" {
+		if err := s.pingHeartbeat(ctx, cfg, cfg.URL, hb); err != nil {
+			s.logger.Error("Failed to ping heartbeat URL", zap.Error(err))
+		}
+	}
+	if len(cfg.Notifiers) > 0 {
+		s.notifier.NotifyHeartbeat(hb, cfg.Notifiers)
+	}
+
+	s.logger.Debug("Heartbeat sent",
+		zap.Int("agents_online", hb.AgentsOnline),
+		zap.Int("agents_total", hb.AgentsTotal))
+}
+
+// heartbeat returns the current heartbeat of the server
+func (s *Service) heartbeat() *types.Heartbeat {
+	now := s.clock.Now()
+	hb := &types.Heartbeat{
+		NodeID:    s.nodeID,
+		Version:   version.GetInfo().Version,
+		StartTime: s.startTime,
+		Uptime:    now.Sub(s.startTime),
+		Timestamp: now,
+	}
+
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+	hb.AgentsTotal = len(s.agents)
+	for _, agent := range s.agents {
+		if agent.Status == types.AgentStatusOnline {
+			hb.AgentsOnline++
+		}
+	}
+	return hb
+}
+
+// pingHeartbeat pings a dead man's switch URL, POST requests carry the
+// heartbeat as JSON
+func (s *Service) pingHeartbeat(ctx context.Context, cfg *config.HeartbeatConfig, url string, hb *types.Heartbeat) error {
+	var body io.Reader
+	if cfg.Method == http.MethodPost {
+		data, err := json.Marshal(hb)
+		if err != nil {
+			return fmt.Errorf("failed to marshal heartbeat: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "wameter-server/"+hb.Version)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send ping: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ping failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
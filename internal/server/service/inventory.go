@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// inventoryTracker holds the state of the expected agents and counts
+// between checks
+type inventoryTracker struct {
+	mu     sync.Mutex
+	agents map[string]*inventoryEntry // Agent ID -> state
+	counts map[string]*inventoryEntry // Count name -> state
+	status *types.InventoryStatus
+	// Last inventory declared through the API, kept when it cannot be read
+	declared *types.Inventory
+}
+
+// inventoryEntry represents the state of an expected agent or count
+type inventoryEntry struct {
+	state    types.InventoryState
+	since    time.Time // When the state was entered
+	expected time.Time // When it was first expected
+	seen     bool      // The agent registered since it was expected
+	alerted  bool
+}
+
+// newInventoryTracker creates new inventory tracker
+func newInventoryTracker() *inventoryTracker {
+	return &inventoryTracker{
+		agents:   make(map[string]*inventoryEntry),
+		counts:   make(map[string]*inventoryEntry),
+		declared: &types.Inventory{},
+	}
+}
+
+// GetInventory returns the inventory declared through the API
+func (s *Service) GetInventory(ctx context.Context) (*types.Inventory, error) {
+	return s.inventoryRepo.Get(ctx)
+}
+
+// SetInventory replaces the inventory declared through the API, it is
+// checked besides the inventory of the configuration
+func (s *Service) SetInventory(ctx context.Context, inv *types.Inventory) error {
+	if err := config.ValidateInventory(inv); err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidInventory, err)
+	}
+	if err := s.inventoryRepo.Save(ctx, inv); err != nil {
+		return err
+	}
+
+	s.logger.Info("Expected inventory updated",
+		zap.Int("agents", len(inv.Agents)),
+		zap.Int("counts", len(inv.Counts)))
+
+	s.checkInventory(s.clock.Now())
+	return nil
+}
+
+// GetInventoryStatus returns the state of the expected inventory at the last check
+func (s *Service) GetInventoryStatus(_ context.Context) *types.InventoryStatus {
+	s.inventory.mu.Lock()
+	defer s.inventory.mu.Unlock()
+
+	if s.inventory.status == nil {
+		return &types.InventoryStatus{Agents: []types.ExpectedAgentStatus{}, Counts: []types.ExpectedCountStatus{}}
+	}
+	return s.inventory.status
+}
+
+// expectedInventory returns the inventory of the configuration merged with
+// the one declared through the API, configured entries take precedence
+func (s *Service) expectedInventory() *types.Inventory {
+	inv := s.GetConfig().Inventory.Inventory()
+
+	declared, err := s.inventoryRepo.Get(s.ctx)
+	if err != nil {
+		s.logger.Error("Failed to load expected inventory", zap.Error(err))
+		declared = s.inventory.declared
+	}
+	s.inventory.declared = declared
+
+	ids := make(map[string]bool, len(inv.Agents))
+	for _, a := range inv.Agents {
+		ids[a.ID] = true
+	}
+	for _, a := range declared.Agents {
+		if !ids[a.ID] {
+			ids[a.ID] = true
+			inv.Agents = append(inv.Agents, a)
+		}
+	}
+
+	names := make(map[string]bool, len(inv.Counts))
+	for _, c := range inv.Counts {
+		names[c.Name] = true
+	}
+	for _, c := range declared.Counts {
+		if !names[c.Name] {
+			names[c.Name] = true
+			inv.Counts = append(inv.Counts, c)
+		}
+	}
+
+	return inv
+}
+
+// checkInventory updates the state of the expected agents and counts, the
+// leader alerts those that never registered, disappeared or fell short
+func (s *Service) checkInventory(now time.Time) {
+	cfg := s.GetConfig().Inventory
+
+	s.inventory.mu.Lock()
+	defer s.inventory.mu.Unlock()
+
+	inv := s.expectedInventory()
+	status := &types.InventoryStatus{
+		CheckedAt: now,
+		Agents:    make([]types.ExpectedAgentStatus, 0, len(inv.Agents)),
+		Counts:    make([]types.ExpectedCountStatus, 0, len(inv.Counts)),
+	}
+	var alerts []*types.MissingAgentAlert
+
+	// Agents reporting within missing_after
+	reporting := func(agent *types.AgentInfo) bool {
+		return agent != nil && !agent.LastSeen.IsZero() &&
+			agent.Status != types.AgentStatusRetired && now.Sub(agent.LastSeen) <= cfg.MissingAfter
+	}
+
+	s.agentsMu.RLock()
+
+	agents := make(map[string]*inventoryEntry, len(inv.Agents))
+	for _, expected := range inv.Agents {
+		e := s.inventory.agents[expected.ID]
+		if e == nil {
+			e = &inventoryEntry{expected: now}
+		}
+		agents[expected.ID] = e
+
+		agent := s.agents[expected.ID]
+		registered := agent != nil && !agent.LastSeen.IsZero()
+		var state types.InventoryState
+		switch {
+		case reporting(agent):
+			state = types.InventoryOK
+			e.seen = true
+		case registered || e.seen:
+			state = types.InventoryDisappeared
+		case now.Sub(e.expected) < cfg.RegistrationTimeout:
+			state = types.InventoryPending
+		default:
+			state = types.InventoryNotRegistered
+		}
+		e.transition(state, now)
+
+		st := types.ExpectedAgentStatus{ExpectedAgent: expected, State: state, Since: e.since}
+		if agent != nil {
+			st.Status = agent.Status
+			st.LastSeen = agent.LastSeen
+			if st.Hostname == "" {
+				st.Hostname = agent.Hostname
+			}
+		}
+		status.Agents = append(status.Agents, st)
+
+		// Agents in maintenance are alerted once it ended
+		if e.missing() && !e.alerted && !(agent != nil && agent.Maintenance.Active(now)) {
+			alerts = append(alerts, &types.MissingAgentAlert{
+				State:    state,
+				AgentID:  expected.ID,
+				Hostname: st.Hostname,
+				LastSeen: st.LastSeen,
+				Since:    e.since,
+			})
+		}
+	}
+
+	counts := make(map[string]*inventoryEntry, len(inv.Counts))
+	for _, expected := range inv.Counts {
+		e := s.inventory.counts[expected.Name]
+		if e == nil {
+			e = &inventoryEntry{expected: now}
+		}
+		counts[expected.Name] = e
+
+		actual := 0
+		for _, agent := range s.agents {
+			if expected.Matches(agent) && reporting(agent) {
+				actual++
+			}
+		}
+		var state types.InventoryState
+		switch {
+		case actual >= expected.Min:
+			state = types.InventoryOK
+		case now.Sub(e.expected) < cfg.RegistrationTimeout:
+			state = types.InventoryPending
+		default:
+			state = types.InventoryShortfall
+		}
+		e.transition(state, now)
+
+		status.Counts = append(status.Counts, types.ExpectedCountStatus{
+			ExpectedCount: expected,
+			State:         state,
+			Actual:        actual,
+			Since:         e.since,
+		})
+
+		if e.missing() && !e.alerted {
+			alerts = append(alerts, &types.MissingAgentAlert{
+				State:    state,
+				Count:    expected.Name,
+				Tags:     maps.Clone(expected.Tags),
+				Expected: expected.Min,
+				Actual:   actual,
+				Since:    e.since,
+			})
+		}
+	}
+
+	s.agentsMu.RUnlock()
+
+	s.inventory.agents = agents
+	s.inventory.counts = counts
+	s.inventory.status = status
+
+	// Replicas track the inventory for the status, only the leader alerts
+	if !s.isLeader() {
+		return
+	}
+	for _, alert := range alerts {
+		s.logger.Warn(alert.Message(),
+			zap.String("state", string(alert.State)),
+			zap.String("agent_id", alert.AgentID),
+			zap.String("count", alert.Count))
+		s.notifier.NotifyAgentMissing(alert)
+		s.annotateMissingAgent(alert)
+		if alert.Count != "" {
+			counts[alert.Count].alerted = true
+		} else {
+			agents[alert.AgentID].alerted = true
+		}
+	}
+}
+
+// transition moves the entry to state, an entry back to ok can alert again
+func (e *inventoryEntry) transition(state types.InventoryState, now time.Time) {
+	if e.state == state {
+		return
+	}
+	e.state = state
+	e.since = now
+	if state == types.InventoryOK || state == types.InventoryPending {
+		e.alerted = false
+	}
+}
+
+// missing returns true if the entry is in an alerted state
+func (e *inventoryEntry) missing() bool {
+	switch e.state {
+	case types.InventoryNotRegistered, types.InventoryDisappeared, types.InventoryShortfall:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// ipBaselineTTL is how long the IP change baseline of an agent is reused
+// before its change history is analyzed again
+const ipBaselineTTL = time.Hour
+
+// ipBaselineTracker caches the IP change baselines of agents, so a burst of
+// changes is scored without analyzing the change history for each of them
+type ipBaselineTracker struct {
+	baselines map[string]*ipBaseline
+	mu        sync.Mutex
+}
+
+// ipBaseline represents the baseline of an agent and its changes since
+type ipBaseline struct {
+	anomaly *types.IPChangeAnomaly
+	recent  []time.Time // Changes of the latest window
+	expires time.Time
+}
+
+// newIPBaselineTracker creates new IP baseline tracker
+func newIPBaselineTracker() *ipBaselineTracker {
+	return &ipBaselineTracker{baselines: make(map[string]*ipBaseline)}
+}
+
+// score adds a change to the cached baseline of an agent and scores the
+// recent change rate against it, false when no baseline is cached
+func (t *ipBaselineTracker) score(agentID string, at, now time.Time, cfg config.AnalysisConfig) (*types.IPChangeAnomaly, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.baselines[agentID]
+	if !ok || !now.Before(b.expires) || b.anomaly.Window != cfg.Window.String() {
+		return nil, false
+	}
+
+	recentStart := now.Add(-cfg.Window)
+	if !at.Before(recentStart) {
+		b.recent = append(b.recent, at)
+	}
+	recent := b.recent[:0]
+	for _, ts := range b.recent {
+		if !ts.Before(recentStart) {
+			recent = append(recent, ts)
+		}
+	}
+	b.recent = recent
+
+	anomaly := *b.anomaly
+	anomaly.RecentRate = float64(len(recent))
+	anomaly.Threshold = cfg.AnomalyThreshold
+	if anomaly.BaselineWindows > 0 {
+		anomaly.Score = round2((anomaly.RecentRate - anomaly.BaselineRate) / math.Max(anomaly.BaselineStd, 1))
+		anomaly.Anomalous = anomaly.Score >= cfg.AnomalyThreshold
+	}
+	return &anomaly, true
+}
+
+// store caches the baseline of an agent from the changes it was analyzed from
+func (t *ipBaselineTracker) store(agentID string, anomaly *types.IPChangeAnomaly, changes []*types.IPChange, now time.Time, cfg config.AnalysisConfig) {
+	recentStart := now.Add(-cfg.Window)
+	var recent []time.Time
+	for _, change := range changes {
+		if !change.Timestamp.Before(recentStart) {
+			recent = append(recent, change.Timestamp)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Drop the expired baselines, e.g. of removed agents
+	for id, b := range t.baselines {
+		if !now.Before(b.expires) {
+			delete(t.baselines, id)
+		}
+	}
+	t.baselines[agentID] = &ipBaseline{
+		anomaly: anomaly,
+		recent:  recent,
+		expires: now.Add(ipBaselineTTL),
+	}
+}
+
+// changeAnomaly scores the change rate of an agent after a change, the
+// change history is analyzed only when no baseline is cached
+func (s *Service) changeAnomaly(ctx context.Context, agentID string, change *types.IPChange) (*types.IPChangeAnomaly, error) {
+	cfg := s.analysisConfig()
+	now := s.clock.Now()
+	if anomaly, ok := s.ipBaselines.score(agentID, change.Timestamp, now, cfg); ok {
+		return anomaly, nil
+	}
+
+	// The change is stored, so the analysis counts it
+	stats, changes, err := s.analyzeChangePatterns(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	s.ipBaselines.store(agentID, stats.Anomaly, changes, now, cfg)
+	return stats.Anomaly, nil
+}
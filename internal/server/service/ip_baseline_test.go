@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/clock"
+	"wameter/internal/types"
+)
+
+// TestNotifyOnlyAnomalies tests that IP changes of agents without a baseline
+// are notified, and that a burst is scored against the cached baseline
+func TestNotifyOnlyAnomalies(t *testing.T) {
+	fake := clock.NewFake(time.Now().Truncate(time.Second))
+	svc, events := newTestService(t, WithClock(fake), func(s *Service) {
+		s.config.Analysis.NotifyOnlyAnomalies = true
+	})
+	ctx := context.Background()
+
+	track := func() {
+		require.NoError(t, svc.TrackIPChange(ctx, "agent-1", &types.IPChange{
+			InterfaceName: "eth0",
+			Version:       types.IPv4,
+			Action:        types.IPChangeActionUpdate,
+			OldAddrs:      []string{"192.0.2.1"},
+			NewAddrs:      []string{"192.0.2.2"},
+		}))
+	}
+
+	// Registered an hour ago, no baseline
+	fake.Advance(time.Hour)
+	track()
+
+	// One change in two days of baseline, a burst in the latest day
+	fake.Advance(3*24*time.Hour - time.Minute)
+	for i := 0; i < 4; i++ {
+		track()
+	}
+
+	svc.ipBaselines.mu.Lock()
+	baseline := svc.ipBaselines.baselines["agent-1"]
+	require.NotNil(t, baseline)
+	assert.Equal(t, 2, baseline.anomaly.BaselineWindows)
+	assert.Len(t, baseline.recent, 4)
+	svc.ipBaselines.mu.Unlock()
+
+	require.NoError(t, svc.Stop(ctx))
+
+	// The first change and the last of the burst, scored 3.5 against 0.5±0.5
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	assert.Equal(t, 2, events.counts["ip.change"])
+}
@@ -3,7 +3,11 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/server/tenant"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
@@ -17,6 +21,8 @@ type IPChangeService interface {
 	GetInterfaceChanges(ctx context.Context, agentID, interfaceName string, since time.Time) ([]*types.IPChange, error)
 	AnalyzeChangePatterns(ctx context.Context, agentID string) (*types.IPChangeStats, error)
 	CleanupOldChanges(ctx context.Context, before time.Time) error
+	GetIPChangeWindows(ctx context.Context) ([]types.IPChangeWindow, error)
+	SetIPChangeWindows(ctx context.Context, windows []types.IPChangeWindow) error
 }
 
 // _ implements IPChangeService
@@ -37,24 +43,25 @@ func (s *Service) TrackIPChange(ctx context.Context, agentID string, change *typ
 
 	// Set timestamp if not set
 	if change.Timestamp.IsZero() {
-		change.Timestamp = time.Now()
+		change.Timestamp = s.clock.Now()
 	}
 
-	// Save the change
-	if err := s.ipChangeRepo.Save(ctx, agentID, change); err != nil {
-		return fmt.Errorf("failed to save IP change: %w", err)
-	}
+	// Reverse DNS and WHOIS context of external IPs is looked up in the
+	// background, the change is recorded once it is known
+	s.lookupIPContext(change)
+	s.privacy.IPChange(change)
 
-	// Send notification
-	if s.notifier != nil {
-		s.notifier.NotifyIPChange(agent, change)
+	if err := s.withIPContext(ctx, change, func(ctx context.Context, change *types.IPChange) error {
+		return s.recordIPChange(ctx, agent.TenantID, agent, change)
+	}); err != nil {
+		return err
 	}
 
 	s.recordMetric(func(m *types.ServiceMetrics) {
 		m.IPChanges++
 	})
 
-	s.logger.Info("IP change tracked",
+	s.log(ctx).Info("IP change tracked",
 		zap.String("agent_id", agentID),
 		zap.String("interface", change.InterfaceName),
 		zap.String("action", string(change.Action)),
@@ -63,28 +70,42 @@ func (s *Service) TrackIPChange(ctx context.Context, agentID string, change *typ
 	return nil
 }
 
-// GetIPChanges retrieves IP changes based on filter
+// GetIPChanges retrieves IP changes based on filter, across all agents if agentID is empty
 func (s *Service) GetIPChanges(ctx context.Context, agentID string, filter *types.IPChangeFilter) ([]*types.IPChange, error) {
 	// Apply default values to filter
 	if filter == nil {
-		filter = &types.IPChangeFilter{
-			StartTime: time.Now().Add(-24 * time.Hour),
-			EndTime:   time.Now(),
-		}
+		filter = &types.IPChangeFilter{}
 	}
 
 	if filter.EndTime.IsZero() {
-		filter.EndTime = time.Now()
+		filter.EndTime = s.clock.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-24 * time.Hour)
+	}
+	if filter.StartTime.After(filter.EndTime) {
+		return nil, fmt.Errorf("start time must be before end time")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	if agentID != "" {
+		// Verify agent exists
+		if _, err := s.GetAgent(ctx, agentID); err != nil {
+			return nil, err
+		}
+		filter.AgentIDs = []string{agentID}
 	}
 
 	// Get changes from repository
-	changes, err := s.ipChangeRepo.GetRecentChanges(ctx, agentID, filter.StartTime)
+	changes, err := s.ipChangeRepo.Query(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get IP changes: %w", err)
 	}
 
-	// Apply filtering
-	return filterIPChanges(changes, filter), nil
+	return changes, nil
 }
 
 // GetIPChangeSummary returns a summary of IP changes
@@ -124,50 +145,101 @@ func (s *Service) GetInterfaceChanges(ctx context.Context, agentID, interfaceNam
 	return changes, nil
 }
 
-// AnalyzeChangePatterns analyzes IP change patterns
+// AnalyzeChangePatterns analyzes IP change patterns against the configured baseline
 func (s *Service) AnalyzeChangePatterns(ctx context.Context, agentID string) (*types.IPChangeStats, error) {
-	// Get recent changes for analysis
-	changes, err := s.ipChangeRepo.GetRecentChanges(ctx, agentID, time.Now().Add(-30*24*time.Hour))
+	stats, _, err := s.analyzeChangePatterns(ctx, agentID)
+	return stats, err
+}
+
+// analyzeChangePatterns analyzes IP change patterns and returns the analyzed changes, oldest first
+func (s *Service) analyzeChangePatterns(ctx context.Context, agentID string) (*types.IPChangeStats, []*types.IPChange, error) {
+	agent, err := s.GetAgent(ctx, agentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get changes for analysis: %w", err)
+		return nil, nil, err
 	}
 
+	cfg := s.analysisConfig()
+	end := s.clock.Now()
+	start := end.AddDate(0, 0, -cfg.BaselineDays)
+
+	// Get changes for analysis
+	changes, err := s.ipChangeRepo.GetRecentChanges(ctx, agentID, start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get changes for analysis: %w", err)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Timestamp.Before(changes[j].Timestamp)
+	})
+
 	stats := &types.IPChangeStats{
 		TotalChanges: int64(len(changes)),
+		PeriodStart:  start,
+		PeriodEnd:    end,
 	}
 
-	if len(changes) > 0 {
-		// Calculate change frequencies
-		stats.ChangesPerDay = float64(len(changes)) / 30
-		stats.ChangesPerWeek = stats.ChangesPerDay * 7
-		stats.ChangesPerMonth = float64(len(changes))
-
-		// Analyze patterns
-		timeMap := make(map[int]int) // hour -> count
-		dayMap := make(map[int]int)  // day -> count
-		var totalInterval float64
-		lastTime := changes[0].Timestamp
-
-		for _, change := range changes {
-			// Track hourly distribution
-			timeMap[change.Timestamp.Hour()]++
-			// Track daily distribution
-			dayMap[int(change.Timestamp.Weekday())]++
-			// Calculate interval
-			if !lastTime.Equal(change.Timestamp) {
-				interval := change.Timestamp.Sub(lastTime).Hours()
-				totalInterval += interval
-				lastTime = change.Timestamp
+	// Calculate change frequencies
+	stats.ChangesPerDay = float64(len(changes)) / float64(cfg.BaselineDays)
+	stats.ChangesPerWeek = stats.ChangesPerDay * 7
+	stats.ChangesPerMonth = stats.ChangesPerDay * 30
+
+	// Analyze time of day, intervals and lease durations
+	interfaces := make(map[string]*types.InterfaceChangeStats)
+	lastChange := make(map[string]time.Time)
+	leaseTotal := make(map[string]float64)
+	leaseCount := make(map[string]int)
+	var totalInterval float64
+
+	for i, change := range changes {
+		ts := change.Timestamp.UTC()
+		stats.HourlyDistribution[ts.Hour()]++
+		stats.DailyDistribution[ts.Weekday()]++
+
+		if i > 0 {
+			totalInterval += change.Timestamp.Sub(changes[i-1].Timestamp).Hours()
+		}
+
+		key := fmt.Sprintf("%s/%s/%t", change.InterfaceName, change.Version, change.IsExternal)
+		iface, ok := interfaces[key]
+		if !ok {
+			iface = &types.InterfaceChangeStats{
+				InterfaceName: change.InterfaceName,
+				Version:       change.Version,
+				IsExternal:    change.IsExternal,
 			}
+			interfaces[key] = iface
+			stats.Interfaces = append(stats.Interfaces, iface)
+		}
+		iface.TotalChanges++
+		iface.LastChange = change.Timestamp
+
+		// An address is held from one change until the next one
+		if last, ok := lastChange[key]; ok {
+			leaseTotal[key] += change.Timestamp.Sub(last).Hours()
+			leaseCount[key]++
 		}
+		lastChange[key] = change.Timestamp
+	}
 
-		// Find most active periods
-		stats.MostActiveHour = findMostActive(timeMap)
-		stats.MostActiveDay = findMostActive(dayMap)
+	for key, iface := range interfaces {
+		if leaseCount[key] > 0 {
+			iface.AverageLeaseDuration = leaseTotal[key] / float64(leaseCount[key])
+		}
+	}
+
+	if len(changes) > 1 {
 		stats.AverageInterval = totalInterval / float64(len(changes)-1)
 	}
+	stats.MostActiveHour = findMostActive(stats.HourlyDistribution[:])
+	stats.MostActiveDay = findMostActive(stats.DailyDistribution[:])
 
-	return stats, nil
+	// Baseline only covers the time the agent has been registered
+	baselineStart := start
+	if agent.RegisteredAt.After(baselineStart) {
+		baselineStart = agent.RegisteredAt
+	}
+	stats.Anomaly = scoreChangeAnomaly(changes, baselineStart, end, cfg)
+
+	return stats, changes, nil
 }
 
 // CleanupOldChanges removes old IP change records
@@ -182,6 +254,49 @@ func (s *Service) CleanupOldChanges(ctx context.Context, before time.Time) error
 	return nil
 }
 
+// notifyIPChange sends an IP change notification, subject to the anomaly rule
+// and the severity of its expected window
+func (s *Service) notifyIPChange(ctx context.Context, agent *types.AgentInfo, change *types.IPChange) {
+	if change.Severity == types.IPChangeSeverityNone {
+		s.logger.Debug("IP change inside expected window, skipping notification",
+			zap.String("agent_id", agent.ID),
+			zap.String("window", change.ExpectedWindow))
+		return
+	}
+	if s.GetConfig().Analysis.NotifyOnlyAnomalies {
+		anomaly, err := s.changeAnomaly(ctx, agent.ID, change)
+		if err != nil {
+			s.logger.Warn("Failed to analyze IP change patterns, sending notification",
+				zap.Error(err),
+				zap.String("agent_id", agent.ID))
+		} else if anomaly.BaselineWindows > 0 && !anomaly.Anomalous {
+			// Agents without a baseline are notified, nothing is known to be expected of them
+			s.logger.Debug("IP change rate within baseline, skipping notification",
+				zap.String("agent_id", agent.ID),
+				zap.Float64("score", anomaly.Score))
+			return
+		}
+	}
+
+	s.notifier.NotifyIPChange(agent, change)
+}
+
+// recordIPChange classifies, saves, annotates and notifies an IP change of
+// an agent of a tenant
+func (s *Service) recordIPChange(ctx context.Context, tenantID string, agent *types.AgentInfo, change *types.IPChange) error {
+	s.classifyIPChange(ctx, agent.ID, change)
+
+	if err := s.ipChangeRepo.Save(tenant.WithContext(ctx, tenantID), agent.ID, change); err != nil {
+		return fmt.Errorf("failed to save IP change: %w", err)
+	}
+	s.annotateIPChange(tenantID, agent.ID, change)
+
+	if s.notifier.Enabled() {
+		s.notifyIPChange(ctx, agent, change)
+	}
+	return nil
+}
+
 // validateIPChange validates IP change data
 func validateIPChange(change *types.IPChange) error {
 	if change.Version == "" {
@@ -196,81 +311,81 @@ func validateIPChange(change *types.IPChange) error {
 	return nil
 }
 
-// filterIPChanges filters IP changes
-func filterIPChanges(changes []*types.IPChange, filter *types.IPChangeFilter) []*types.IPChange {
-	if filter == nil {
-		return changes
+// analysisConfig returns the analysis configuration with defaults applied
+func (s *Service) analysisConfig() config.AnalysisConfig {
+	cfg := s.GetConfig().Analysis
+	if cfg.BaselineDays <= 0 {
+		cfg.BaselineDays = 30
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 24 * time.Hour
+	}
+	if cfg.AnomalyThreshold <= 0 {
+		cfg.AnomalyThreshold = 3
 	}
+	return cfg
+}
 
-	var filtered []*types.IPChange
-	for _, change := range changes {
-		// Apply filters
-		if !change.Timestamp.Before(filter.StartTime) &&
-			!change.Timestamp.After(filter.EndTime) &&
-			matchesFilter(change, filter) {
-			filtered = append(filtered, change)
-		}
+// scoreChangeAnomaly compares the change count of the latest window with
+// the counts of the preceding windows and returns the deviation in standard deviations
+func scoreChangeAnomaly(changes []*types.IPChange, start, end time.Time, cfg config.AnalysisConfig) *types.IPChangeAnomaly {
+	anomaly := &types.IPChangeAnomaly{
+		Window:    cfg.Window.String(),
+		Threshold: cfg.AnomalyThreshold,
 	}
 
-	return filtered
-}
+	recentStart := end.Add(-cfg.Window)
+	windows := int(recentStart.Sub(start) / cfg.Window)
 
-// matchesFilter checks if IP change matches filter
-func matchesFilter(change *types.IPChange, filter *types.IPChangeFilter) bool {
-	// Check interface filter
-	if len(filter.Interfaces) > 0 {
-		found := false
-		for _, iface := range filter.Interfaces {
-			if change.InterfaceName == iface {
-				found = true
-				break
-			}
+	counts := make([]float64, max(windows, 0))
+	for _, change := range changes {
+		if !change.Timestamp.Before(recentStart) {
+			anomaly.RecentRate++
+			continue
 		}
-		if !found {
-			return false
+		i := int(recentStart.Sub(change.Timestamp) / cfg.Window)
+		if i < windows {
+			counts[i]++
 		}
 	}
 
-	// Check version filter
-	if len(filter.Versions) > 0 {
-		found := false
-		for _, version := range filter.Versions {
-			if change.Version == version {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	// Not enough history to build a baseline
+	if windows < 1 {
+		return anomaly
 	}
+	anomaly.BaselineWindows = windows
 
-	// Check action filter
-	if len(filter.Actions) > 0 {
-		found := false
-		for _, action := range filter.Actions {
-			if string(change.Action) == action {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	var sum float64
+	for _, c := range counts {
+		sum += c
 	}
+	mean := sum / float64(windows)
 
-	// Check external filter
-	if filter.IsExternal != nil && change.IsExternal != *filter.IsExternal {
-		return false
+	var variance float64
+	for _, c := range counts {
+		variance += (c - mean) * (c - mean)
 	}
+	std := math.Sqrt(variance / float64(windows))
+
+	anomaly.BaselineRate = round2(mean)
+	anomaly.BaselineStd = round2(std)
+	// Use a floor of one change so a quiet baseline does not make every change anomalous
+	anomaly.Score = round2((anomaly.RecentRate - mean) / math.Max(std, 1))
+	anomaly.Anomalous = anomaly.Score >= cfg.AnomalyThreshold
+
+	return anomaly
+}
 
-	return true
+// round2 rounds a value to two decimal places
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
 }
 
 // findMostActive finds the most active period
-func findMostActive(countMap map[int]int) int {
-	var maxCount, maxKey int
-	for key, count := range countMap {
+func findMostActive(counts []int64) int {
+	var maxCount int64
+	var maxKey int
+	for key, count := range counts {
 		if count > maxCount {
 			maxCount = count
 			maxKey = key
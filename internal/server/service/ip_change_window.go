@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+	"wameter/internal/cron"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// ipWindowExternal matches external IP changes in the interfaces of a window
+const ipWindowExternal = "external"
+
+// GetIPChangeWindows returns the expected IP change windows declared
+// through the API
+func (s *Service) GetIPChangeWindows(ctx context.Context) ([]types.IPChangeWindow, error) {
+	return s.ipWindowRepo.List(ctx)
+}
+
+// SetIPChangeWindows replaces the expected IP change windows declared
+// through the API, they apply besides the windows of the configuration
+func (s *Service) SetIPChangeWindows(ctx context.Context, windows []types.IPChangeWindow) error {
+	for i := range windows {
+		if windows[i].Severity == "" {
+			windows[i].Severity = "info"
+		}
+	}
+	if err := config.ValidateIPChangeWindows(windows); err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidIPWindow, err)
+	}
+	if err := s.ipWindowRepo.Replace(ctx, windows); err != nil {
+		return err
+	}
+
+	s.ipWindowsMu.Lock()
+	s.ipWindows = windows
+	s.ipWindowsMu.Unlock()
+
+	s.logger.Info("Expected IP change windows updated", zap.Int("windows", len(windows)))
+	return nil
+}
+
+// expectedIPChangeWindows returns the windows of the configuration merged
+// with the ones declared through the API, configured windows take precedence
+func (s *Service) expectedIPChangeWindows(ctx context.Context) []types.IPChangeWindow {
+	cfg := s.GetConfig().IPChanges
+	windows := cfg.Windows()
+
+	s.ipWindowsMu.Lock()
+	defer s.ipWindowsMu.Unlock()
+
+	declared, err := s.ipWindowRepo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load expected IP change windows", zap.Error(err))
+		declared = s.ipWindows
+	}
+	s.ipWindows = declared
+
+	names := make(map[string]bool, len(windows))
+	for _, w := range windows {
+		names[w.Name] = true
+	}
+	for _, w := range declared {
+		if !names[w.Name] {
+			names[w.Name] = true
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// classifyIPChange sets the severity of the notification of an IP change,
+// the one of the first expected window it falls in or the configured one
+func (s *Service) classifyIPChange(ctx context.Context, agentID string, change *types.IPChange) {
+	cfg := s.GetConfig()
+	change.Severity = cfg.IPChanges.Severity
+
+	at := change.Timestamp
+	if at.IsZero() {
+		at = s.clock.Now()
+	}
+	for _, w := range s.expectedIPChangeWindows(ctx) {
+		if ipWindowCovers(&w, agentID, change, at, cfg.Server.Location()) {
+			change.ExpectedWindow = w.Name
+			change.Severity = w.Severity
+			return
+		}
+	}
+}
+
+// ipWindowCovers reports whether an IP change of the agent at the given
+// time falls in the window
+func ipWindowCovers(w *types.IPChangeWindow, agentID string, change *types.IPChange, at time.Time, loc *time.Location) bool {
+	if len(w.Agents) > 0 && !slices.ContainsFunc(w.Agents, func(pattern string) bool {
+		ok, _ := path.Match(pattern, agentID)
+		return ok
+	}) {
+		return false
+	}
+
+	iface := change.InterfaceName
+	if change.IsExternal {
+		iface = ipWindowExternal
+	}
+	if len(w.Interfaces) > 0 && !slices.Contains(w.Interfaces, iface) {
+		return false
+	}
+
+	schedule, err := cron.Parse(w.Schedule)
+	if err != nil {
+		return false
+	}
+	if w.Timezone != "" {
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false
+		}
+	}
+
+	// The window covers the change if it started at most its duration before
+	start := schedule.Next(at.In(loc).Add(-w.Duration))
+	return !start.IsZero() && !start.After(at)
+}
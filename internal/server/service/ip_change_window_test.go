@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/types"
+)
+
+// TestExpectedIPChangeWindows tests that IP changes inside an expected
+// window are recorded with it and not notified when it suppresses them
+func TestExpectedIPChangeWindows(t *testing.T) {
+	svc, events := newTestService(t)
+	ctx := context.Background()
+
+	err := svc.SetIPChangeWindows(ctx, []types.IPChangeWindow{{Name: "nightly", Schedule: "0 3 * * *"}})
+	assert.ErrorIs(t, err, types.ErrInvalidIPWindow)
+
+	require.NoError(t, svc.SetIPChangeWindows(ctx, []types.IPChangeWindow{{
+		Name:       "nightly",
+		Schedule:   "0 3 * * *",
+		Duration:   15 * time.Minute,
+		Timezone:   "UTC",
+		Interfaces: []string{"ppp0"},
+		Severity:   types.IPChangeSeverityNone,
+	}}))
+
+	day := time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	testCases := []struct {
+		name     string
+		at       time.Time
+		iface    string
+		window   string
+		severity string
+	}{
+		{name: "Inside window", at: day.Add(3*time.Hour + 5*time.Minute), iface: "ppp0", window: "nightly", severity: types.IPChangeSeverityNone},
+		{name: "After window", at: day.Add(3*time.Hour + 20*time.Minute), iface: "ppp0", severity: "info"},
+		{name: "Other interface", at: day.Add(3*time.Hour + 5*time.Minute), iface: "eth0", severity: "info"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			change := &types.IPChange{
+				InterfaceName: tc.iface,
+				Version:       types.IPv4,
+				Action:        types.IPChangeActionUpdate,
+				OldAddrs:      []string{"192.0.2.1"},
+				NewAddrs:      []string{"192.0.2.2"},
+				Timestamp:     tc.at,
+			}
+			require.NoError(t, svc.TrackIPChange(ctx, "agent-1", change))
+			assert.Equal(t, tc.window, change.ExpectedWindow)
+			assert.Equal(t, tc.severity, change.Severity)
+		})
+	}
+
+	changes, err := svc.GetIPChanges(ctx, "agent-1", &types.IPChangeFilter{StartTime: day})
+	require.NoError(t, err)
+	windows := make(map[string]int)
+	for _, c := range changes {
+		windows[c.ExpectedWindow]++
+	}
+	assert.Equal(t, map[string]int{"nightly": 1, "": 2}, windows)
+
+	require.NoError(t, svc.Stop(ctx))
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	assert.Equal(t, 2, events.counts["ip.change"])
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxIPContextLookups bounds the lookups in progress, further new
+	// external IPs are recorded without context
+	maxIPContextLookups = 64
+	// ipContextRetention is how long a lookup waits for its IP change, e.g.
+	// for a report queued for ingest
+	ipContextRetention = 10 * time.Minute
+)
+
+// ipContextTracker holds the reverse DNS and WHOIS lookups of new external
+// IPs, run in the background so they do not hold up ingest. Lookups are
+// keyed by the stored address, redacted when privacy is enabled, as they
+// start before the address is redacted.
+type ipContextTracker struct {
+	mu      sync.Mutex
+	lookups map[string]*ipContextLookup
+}
+
+// ipContextLookup represents a lookup, result is set when done is closed
+type ipContextLookup struct {
+	done      chan struct{}
+	result    *types.IPContext
+	startedAt time.Time
+}
+
+// newIPContextTracker creates new IP context tracker
+func newIPContextTracker() *ipContextTracker {
+	return &ipContextTracker{lookups: make(map[string]*ipContextLookup)}
+}
+
+// needsIPContext reports whether the context of an IP change is looked up
+func (s *Service) needsIPContext(change *types.IPChange) bool {
+	return s.ipInfo != nil && change.IsExternal && change.Context == nil && len(change.NewAddrs) > 0
+}
+
+// lookupIPContext starts looking up the context of a new external IP, it
+// is called with the address before redaction
+func (s *Service) lookupIPContext(change *types.IPChange) {
+	if !s.needsIPContext(change) {
+		return
+	}
+	addr := change.NewAddrs[0]
+	key := s.privacy.Addr(addr)
+	now := s.clock.Now()
+
+	t := s.ipContexts
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Drop lookups whose IP change never came, e.g. of duplicate reports
+	for k, l := range t.lookups {
+		if now.Sub(l.startedAt) > ipContextRetention {
+			delete(t.lookups, k)
+		}
+	}
+	if _, ok := t.lookups[key]; ok {
+		return
+	}
+	if len(t.lookups) >= maxIPContextLookups {
+		s.logger.Warn("Too many IP context lookups in progress, recording IP change without context",
+			zap.Int("lookups", len(t.lookups)))
+		return
+	}
+
+	lookup := &ipContextLookup{done: make(chan struct{}), startedAt: now}
+	t.lookups[key] = lookup
+	started := s.goBackground(func() {
+		defer close(lookup.done)
+		// Addresses are redacted in the context as in the change
+		redacted := &types.IPChange{Context: s.ipInfo.Lookup(s.ctx, addr)}
+		s.privacy.IPChange(redacted)
+		lookup.result = redacted.Context
+	})
+	if !started {
+		close(lookup.done)
+	}
+}
+
+// takeIPContextLookup removes and returns the lookup of the new address of
+// a stored IP change, nil if none is in progress
+func (s *Service) takeIPContextLookup(change *types.IPChange) *ipContextLookup {
+	if !s.needsIPContext(change) {
+		return nil
+	}
+
+	t := s.ipContexts
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lookup := t.lookups[change.NewAddrs[0]]
+	delete(t.lookups, change.NewAddrs[0])
+	return lookup
+}
+
+// withIPContext records an IP change, at once without a lookup in progress,
+// or in the background once the context of its new address is looked up.
+// Errors of background records are logged.
+func (s *Service) withIPContext(ctx context.Context, change *types.IPChange, record func(ctx context.Context, change *types.IPChange) error) error {
+	lookup := s.takeIPContextLookup(change)
+	if lookup == nil {
+		return record(ctx, change)
+	}
+
+	// The record outlives the request or ingest batch
+	ctx = context.WithoutCancel(ctx)
+	wait := func() error {
+		// Lookups end at the resolver timeout, canceled ones when stopping
+		// with what was looked up
+		<-lookup.done
+		change.Context = lookup.result
+		return record(ctx, change)
+	}
+
+	if !s.goBackground(func() {
+		if err := wait(); err != nil {
+			s.logger.Error("Failed to record IP change",
+				zap.Error(err),
+				zap.String("interface", change.InterfaceName))
+		}
+	}) {
+		// Stopping, the lookup is canceled
+		return wait()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/ipinfo"
+	"wameter/internal/server/config"
+	"wameter/internal/server/privacy"
+	"wameter/internal/types"
+)
+
+// TestIPContextLookup tests that the context of new external IPs is looked
+// up in the background and stored with the change, redacted as its address
+func TestIPContextLookup(t *testing.T) {
+	testCases := []struct {
+		name    string
+		privacy string
+		save    func(t *testing.T, svc *Service, change types.IPChange)
+	}{
+		{
+			name: "Tracked",
+			save: func(t *testing.T, svc *Service, change types.IPChange) {
+				require.NoError(t, svc.TrackIPChange(context.Background(), "agent-1", &change))
+			},
+		},
+		{
+			name: "Reported",
+			save: func(t *testing.T, svc *Service, change types.IPChange) {
+				report := testReport(change.Timestamp, 0, 0)
+				report.Metrics.Network.IPChanges = []types.IPChange{change}
+				require.NoError(t, svc.SaveMetrics(context.Background(), report))
+			},
+		},
+		{
+			name:    "Reported with privacy",
+			privacy: "truncate",
+			save: func(t *testing.T, svc *Service, change types.IPChange) {
+				report := testReport(change.Timestamp, 0, 0)
+				report.Metrics.Network.IPChanges = []types.IPChange{change}
+				require.NoError(t, svc.SaveMetrics(context.Background(), report))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, events := newTestService(t, func(s *Service) {
+				// Neither looked up in DNS nor WHOIS, the context has the address
+				s.config.IPInfo = &ipinfo.Config{Enabled: true}
+				s.privacy = privacy.New(&config.PrivacyConfig{IPAddresses: tc.privacy, IPv4Prefix: 24})
+			})
+			ctx := context.Background()
+			start := time.Now().Add(-time.Minute)
+
+			tc.save(t, svc, types.IPChange{
+				InterfaceName: "eth0",
+				Version:       types.IPv4,
+				IsExternal:    true,
+				Action:        types.IPChangeActionUpdate,
+				OldAddrs:      []string{"198.51.100.1"},
+				NewAddrs:      []string{"203.0.113.7"},
+				Timestamp:     time.Now(),
+			})
+
+			// Recorded in the background once looked up
+			require.NoError(t, svc.Stop(ctx))
+
+			changes, err := svc.GetIPChanges(ctx, "agent-1", &types.IPChangeFilter{StartTime: start})
+			require.NoError(t, err)
+			require.Len(t, changes, 1)
+			require.NotNil(t, changes[0].Context)
+			assert.Equal(t, changes[0].NewAddrs[0], changes[0].Context.IP)
+			assert.Empty(t, svc.ipContexts.lookups)
+
+			events.mu.Lock()
+			defer events.mu.Unlock()
+			assert.Equal(t, 1, events.counts["ip.change"])
+		})
+	}
+}
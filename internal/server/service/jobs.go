@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"wameter/internal/cron"
+	"wameter/internal/server/config"
+)
+
+// registerJobs registers the periodic tasks of the server with their
+// default schedules, which follow the intervals of their settings
+func (s *Service) registerJobs() {
+	s.jobs.add(&job{
+		name:       config.JobCleanup,
+		leaderOnly: true,
+		schedule:   func() string { return every(s.GetConfig().Database.PruneInterval) },
+		run:        s.cleanup,
+	})
+	s.jobs.add(&job{
+		name:     config.JobAgentMonitoring,
+		schedule: func() string { return every(s.agentMonitorConfig().CheckInterval) },
+		run:      s.monitorAgents,
+	})
+	s.jobs.add(&job{
+		name:     config.JobReports,
+		schedule: func() string { return "* * * * *" },
+		run:      s.reportsRunner(),
+	})
+	s.jobs.add(&job{
+		name:     config.JobInventoryCheck,
+		schedule: func() string { return every(s.GetConfig().Inventory.CheckInterval) },
+		run: func(_ context.Context) error {
+			s.checkInventory(s.clock.Now())
+			return nil
+		},
+	})
+	// Replicas share one heartbeat, taken over by the next leader
+	s.jobs.add(&job{
+		name:       config.JobHeartbeat,
+		leaderOnly: true,
+		schedule:   func() string { return every(s.GetConfig().Heartbeat.Interval) },
+		run: func(ctx context.Context) error {
+			if cfg := s.GetConfig().Heartbeat; cfg.Enabled {
+				s.sendHeartbeat(ctx, &cfg)
+			}
+			return nil
+		},
+	})
+	// Storage quotas apply from the start
+	s.jobs.add(&job{
+		name:     config.JobQuotas,
+		atStart:  true,
+		schedule: func() string { return every(s.GetConfig().Quotas.CheckInterval) },
+		run: func(ctx context.Context) error {
+			if cfg := s.GetConfig().Quotas; cfg.Enabled {
+				s.measureQuotas(ctx, &cfg)
+			}
+			return nil
+		},
+	})
+	if s.discovery != nil {
+		s.jobs.add(&job{
+			name:     config.JobDiscovery,
+			schedule: func() string { return every(discoveryInterval) },
+			run: func(_ context.Context) error {
+				s.reconcileDiscoveredAgents()
+				return nil
+			},
+		})
+	}
+}
+
+// monitorAgents refreshes the agents other replicas recorded and, on the
+// leader, marks the agents that stopped reporting offline
+func (s *Service) monitorAgents(_ context.Context) error {
+	// Agents report to any replica, refresh what the others recorded
+	if s.config.Cluster.Enabled {
+		s.loadAgents()
+	}
+	if s.agentState != nil {
+		if err := s.syncAgentStates(); err != nil {
+			// Skip offline checks rather than judge agents by reports of this replica only
+			return fmt.Errorf("failed to sync shared agent state: %w", err)
+		}
+	}
+	if s.isLeader() {
+		s.endExpiredMaintenance(s.clock.Now())
+		s.checkAgentStatuses()
+	}
+	return nil
+}
+
+// reportsRunner returns the run of the reports job, which sends the
+// configured reports when their schedule is due. Only the leader sends them
+// but every replica keeps track of the schedules so a new leader does not
+// catch up on runs.
+func (s *Service) reportsRunner() func(ctx context.Context) error {
+	var mu sync.Mutex
+	// Next runs by report, keyed by its name, schedule and time zone so
+	// reloaded schedules are recomputed
+	next := make(map[string]time.Time)
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := s.clock.Now()
+		reports := s.GetConfig().Reports
+		seen := make(map[string]bool, len(reports))
+		for i := range reports {
+			cfg := &reports[i]
+			key := cfg.Name + "\x00" + cfg.Schedule + "\x00" + cfg.Timezone
+			seen[key] = true
+
+			schedule, err := cron.Parse(cfg.Schedule)
+			if err != nil {
+				continue
+			}
+			loc, err := time.LoadLocation(cfg.Timezone)
+			if err != nil {
+				continue
+			}
+
+			due, ok := next[key]
+			if !ok {
+				next[key] = schedule.Next(now.In(loc))
+				continue
+			}
+			if due.IsZero() || now.Before(due) {
+				continue
+			}
+			next[key] = schedule.Next(now.In(loc))
+
+			if s.isLeader() {
+				s.sendReport(ctx, cfg, due)
+			}
+		}
+
+		for key := range next {
+			if !seen[key] {
+				delete(next, key)
+			}
+		}
+		return nil
+	}
+}
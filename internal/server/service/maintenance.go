@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// SetMaintenance puts an agent into maintenance, offline and threshold
+// notifications of the agent are suppressed until it ends. Changing the
+// maintenance of an agent already in maintenance keeps its start.
+func (s *Service) SetMaintenance(ctx context.Context, agentID string, req *types.MaintenanceRequest) (*types.AgentMaintenance, error) {
+	now := s.clock.Now()
+	m := &types.AgentMaintenance{Reason: req.Reason, Since: now}
+	switch {
+	case req.Until != nil:
+		if !req.Until.After(now) {
+			return nil, fmt.Errorf("%w: until must be in the future", types.ErrInvalidMaintenance)
+		}
+		until := *req.Until
+		m.Until = &until
+	case req.Duration > 0:
+		until := now.Add(req.Duration)
+		m.Until = &until
+	}
+
+	ctx, err := s.agentScope(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	agent := s.agents[agentID]
+	if agent != nil && agent.Maintenance.Active(now) {
+		m.Since = agent.Maintenance.Since
+	}
+
+	if err := s.agentRepo.SetMaintenance(ctx, agentID, m); err != nil {
+		return nil, err
+	}
+	if agent != nil {
+		agent.Maintenance = m
+	}
+
+	fields := []zap.Field{
+		zap.String("agent_id", agentID),
+		zap.String("reason", m.Reason),
+	}
+	if m.Until != nil {
+		fields = append(fields, zap.Time("until", *m.Until))
+	}
+	s.log(ctx).Info("Agent maintenance started", fields...)
+
+	return m, nil
+}
+
+// EndMaintenance ends the maintenance of an agent
+func (s *Service) EndMaintenance(ctx context.Context, agentID string) error {
+	ctx, err := s.agentScope(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	agent := s.agents[agentID]
+	if agent != nil && agent.Maintenance == nil {
+		return types.ErrNotInMaintenance
+	}
+
+	if err := s.agentRepo.SetMaintenance(ctx, agentID, nil); err != nil {
+		return err
+	}
+	if agent != nil {
+		agent.Maintenance = nil
+		s.notifyOfflineAfterMaintenance(agent)
+	}
+
+	s.log(ctx).Info("Agent maintenance ended", zap.String("agent_id", agentID))
+
+	return nil
+}
+
+// endExpiredMaintenance ends the maintenance of agents past its end time
+func (s *Service) endExpiredMaintenance(now time.Time) {
+	s.agentsMu.Lock()
+	defer s.agentsMu.Unlock()
+
+	for id, agent := range s.agents {
+		if agent.Maintenance == nil || agent.Maintenance.Active(now) {
+			continue
+		}
+		if err := s.agentRepo.SetMaintenance(context.Background(), id, nil); err != nil {
+			s.logger.Error("Failed to end agent maintenance",
+				zap.Error(err),
+				zap.String("agent_id", id))
+			continue
+		}
+		agent.Maintenance = nil
+		s.notifyOfflineAfterMaintenance(agent)
+
+		s.logger.Info("Agent maintenance expired", zap.String("agent_id", id))
+	}
+}
+
+// notifyOfflineAfterMaintenance reports an agent still offline when its
+// maintenance ends, its offline notification was suppressed
+func (s *Service) notifyOfflineAfterMaintenance(agent *types.AgentInfo) {
+	if agent.Status == types.AgentStatusOffline && s.notifier.Enabled() {
+		s.notifier.NotifyAgentOffline(agent)
+	}
+}
+
+// inMaintenance returns true if notifications of an agent are suppressed
+func (s *Service) inMaintenance(agentID string) bool {
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	agent, ok := s.agents[agentID]
+	return ok && agent.Maintenance.Active(s.clock.Now())
+}
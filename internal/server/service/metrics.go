@@ -1,15 +1,24 @@
 package service
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"slices"
 	"time"
+	"wameter/internal/server/archive"
 	"wameter/internal/server/data/repository"
+	"wameter/internal/server/tenant"
+	"wameter/internal/tracing"
 	"wameter/internal/types"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -17,17 +26,22 @@ import (
 type MetricsService interface {
 	SaveMetrics(ctx context.Context, data *types.MetricsData) error
 	BatchSave(ctx context.Context, metrics []*types.MetricsData) error
+	BackfillMetrics(ctx context.Context, metrics []*types.MetricsData) (*types.MetricsBackfillResult, error)
 	GetMetrics(ctx context.Context, query MetricsQuery) ([]*types.MetricsData, error)
 	GetLatestMetrics(ctx context.Context, agentID string) (*types.MetricsData, error)
 	GetMetricsSummary(ctx context.Context, agentID string) (*types.MetricsSummary, error)
-	ExportMetrics(ctx context.Context, format string, filter types.MetricsFilter) (io.Reader, error)
+	ExportMetrics(ctx context.Context, format string, filter types.MetricsFilter) (io.ReadCloser, error)
 	ArchiveMetrics(ctx context.Context, opts types.MetricsArchiveOptions) error
 	DeleteMetrics(ctx context.Context, before time.Time) error
+	GetIngestStats() *types.IngestStats
 }
 
 // _ implements MetricsService
 var _ MetricsService = (*Service)(nil)
 
+// backfillClockSkew is how far ahead of the server clock backfilled entries may be
+const backfillClockSkew = 5 * time.Minute
+
 // MetricsQuery represents a query for metrics
 type MetricsQuery struct {
 	AgentIDs  []string  `json:"agent_ids,omitempty"`
@@ -36,58 +50,356 @@ type MetricsQuery struct {
 	Limit     int       `json:"limit,omitempty"`
 }
 
-// SaveMetrics saves metrics data
-func (s *Service) SaveMetrics(ctx context.Context, data *types.MetricsData) error {
+// SaveMetrics saves metrics data, with the ingest queue enabled it returns
+// once the report is queued and the write happens in the background
+func (s *Service) SaveMetrics(ctx context.Context, data *types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "service.SaveMetrics",
+		attribute.String("agent_id", data.AgentID),
+		attribute.Bool("queued", s.ingest != nil))
+	defer func() { tracing.End(span, err) }()
+
+	if err := data.ValidateSchema(s.metricsLimits()); err != nil {
+		return err
+	}
+	data.ToUTC()
+
+	// Reports of erased agents up to their erasure are not stored again
+	if err := s.checkTombstone(data); err != nil {
+		return err
+	}
+
+	// Store under the tenant of the agent, reports for agents of other tenants are rejected
+	ctx, err = s.agentScope(ctx, data.AgentID)
+	if err != nil {
+		return err
+	}
+
+	// Deltas of agents in low-bandwidth mode are expanded to full reports
+	if err := s.applyDelta(ctx, data); err != nil {
+		return err
+	}
+
+	// Agents over their quotas are throttled or rejected
+	if err := s.checkQuotas([]*types.MetricsData{data}, true); err != nil {
+		return err
+	}
+
+	// Rates follow the counters of consecutive reports
+	s.rates.apply(data)
+
+	// Addresses are redacted before anything is stored or notified
+	s.redactMetrics(data)
+
+	if s.ingest != nil {
+		return s.ingest.Push(tenant.OrDefault(ctx), data)
+	}
+
 	// Update agent status
 	if err := s.UpdateAgentStatus(ctx, data.AgentID, types.AgentStatusOnline); err != nil {
-		s.logger.Error("Failed to update agent status",
+		s.log(ctx).Error("Failed to update agent status",
 			zap.Error(err),
 			zap.String("agent_id", data.AgentID))
 	}
 
-	// Save metrics
+	// Save metrics, resubmitted reports are acknowledged without processing them again
 	if err := s.metricsRepo.Save(ctx, data); err != nil {
+		if errors.Is(err, types.ErrDuplicateMetrics) {
+			s.log(ctx).Debug("Ignoring duplicate metrics report",
+				zap.String("agent_id", data.AgentID),
+				zap.Time("collected_at", data.CollectedAt))
+			return nil
+		}
 		return fmt.Errorf("failed to save metrics: %w", err)
 	}
+	s.invalidateMetrics(ctx, data.AgentID)
+
+	s.processSavedMetrics(ctx, []*types.MetricsData{data})
+
+	return nil
+}
+
+// GetIngestStats returns the state of the ingest queue, nil when it is disabled
+func (s *Service) GetIngestStats() *types.IngestStats {
+	if s.ingest == nil {
+		return nil
+	}
+	return s.ingest.Stats()
+}
+
+// storeMetrics writes a batch of queued reports of a tenant, it is called by
+// the ingest workers
+func (s *Service) storeMetrics(ctx context.Context, tenantID string, batch []*types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "service.storeMetrics",
+		attribute.String("tenant_id", tenantID),
+		attribute.Int("batch_size", len(batch)))
+	defer func() { tracing.End(span, err) }()
+
+	ctx = tenant.WithContext(ctx, tenantID)
+
+	// Reports queued before an erasure of their agent are dropped
+	kept := make([]*types.MetricsData, 0, len(batch))
+	for _, data := range batch {
+		if s.checkTombstone(data) == nil {
+			kept = append(kept, data)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	batch = kept
+
+	// Save metrics, resubmitted reports are skipped
+	saved, err := s.metricsRepo.BatchSave(ctx, batch)
+	if err != nil {
+		err = fmt.Errorf("failed to save metrics batch: %w", err)
+		s.recordError(err)
+		return err
+	}
 
-	if data.Metrics.Network != nil {
-		s.processNetworkMetrics(ctx, data)
+	// Update agent status once per reporting agent
+	seen := make(map[string]bool)
+	for _, data := range batch {
+		if seen[data.AgentID] {
+			continue
+		}
+		seen[data.AgentID] = true
+		if err := s.UpdateAgentStatus(ctx, data.AgentID, types.AgentStatusOnline); err != nil {
+			s.logger.Error("Failed to update agent status",
+				zap.Error(err),
+				zap.String("agent_id", data.AgentID))
+		}
+	}
+
+	s.invalidateMetrics(ctx, metricsAgents(saved)...)
+	s.processSavedMetrics(ctx, saved)
+
+	return nil
+}
+
+// processSavedMetrics tracks IP changes of newly stored reports, then
+// queues them for the alert stage
+func (s *Service) processSavedMetrics(ctx context.Context, saved []*types.MetricsData) {
+	if len(saved) == 0 {
+		return
+	}
+
+	for _, data := range saved {
+		s.completeCollection(ctx, data)
+		if data.Metrics.Network != nil {
+			s.processNetworkMetrics(ctx, data)
+			if s.forwarder != nil {
+				s.forwarder.Forward(data, s.agentTags(data.AgentID))
+			}
+		}
 	}
 
 	s.recordMetric(func(m *types.ServiceMetrics) {
-		m.MetricsProcessed++
+		m.MetricsProcessed += int64(len(saved))
 	})
 
 	// Process metrics for notifications
-	go s.processMetricsAlerts(data)
+	s.queueAlerts(saved)
+}
 
-	return nil
+// queueAlerts queues stored reports for the alert stage. Alerts of each
+// report are evaluated once, by a single background pass in the order the
+// reports were stored, so alert state such as de-bounced error counters
+// follows the reports of each agent in sequence.
+func (s *Service) queueAlerts(saved []*types.MetricsData) {
+	s.alertsMu.Lock()
+	s.alertQueue = append(s.alertQueue, saved...)
+	start := !s.alerting
+	s.alerting = true
+	s.alertsMu.Unlock()
+
+	if start {
+		s.goBackground(s.evaluateQueuedAlerts)
+	}
+}
+
+// evaluateQueuedAlerts evaluates the alerts of queued reports until the
+// queue is empty
+func (s *Service) evaluateQueuedAlerts() {
+	for {
+		s.alertsMu.Lock()
+		queued := s.alertQueue
+		s.alertQueue = nil
+		if len(queued) == 0 {
+			s.alerting = false
+			s.alertsMu.Unlock()
+			return
+		}
+		s.alertsMu.Unlock()
+
+		for _, data := range queued {
+			s.processMetricsAlerts(data)
+		}
+	}
 }
 
 // BatchSave saves multiple metrics entries
-func (s *Service) BatchSave(ctx context.Context, metrics []*types.MetricsData) error {
+func (s *Service) BatchSave(ctx context.Context, metrics []*types.MetricsData) (err error) {
+	ctx, span := tracing.Start(ctx, "service.BatchSave", attribute.Int("batch_size", len(metrics)))
+	defer func() { tracing.End(span, err) }()
+
 	// First validate all metrics
-	for _, m := range metrics {
-		if m.AgentID == "" || m.Timestamp.IsZero() {
-			return fmt.Errorf("invalid metrics data: missing required fields")
+	limits := s.metricsLimits()
+	for i, m := range metrics {
+		if err := m.ValidateSchema(limits); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		m.ToUTC()
+		if m.Delta != nil {
+			return fmt.Errorf("%w: entry %d: delta reports are sent one at a time", types.ErrInvalidMetrics, i)
+		}
+		if err := s.checkTombstone(m); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
 		}
+		if _, err := s.agentScope(ctx, m.AgentID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkQuotas(metrics, true); err != nil {
+		return err
 	}
 
-	// Save metrics in transaction
-	if err := s.metricsRepo.BatchSave(ctx, metrics); err != nil {
+	s.rates.applyAll(metrics)
+	for _, m := range metrics {
+		s.redactMetrics(m)
+	}
+
+	// Save metrics in transaction, entries already stored are skipped
+	saved, err := s.metricsRepo.BatchSave(ctx, metrics)
+	if err != nil {
 		return fmt.Errorf("failed to save metrics batch: %w", err)
 	}
+	s.invalidateMetrics(ctx, metricsAgents(saved)...)
+	s.processSavedMetrics(ctx, saved)
 
-	// Process metrics in background
-	go func() {
-		for _, m := range metrics {
-			s.processMetricsAlerts(m)
+	return nil
+}
+
+// BackfillMetrics imports historical metrics, e.g. from an agent spool or
+// another system. Entries are written in chunks of max_batch_size without
+// marking agents online or raising alerts, entries already stored are skipped.
+func (s *Service) BackfillMetrics(ctx context.Context, metrics []*types.MetricsData) (_ *types.MetricsBackfillResult, err error) {
+	ctx, span := tracing.Start(ctx, "service.BackfillMetrics", attribute.Int("batch_size", len(metrics)))
+	defer func() { tracing.End(span, err) }()
+
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("%w: no metrics to backfill", types.ErrInvalidMetrics)
+	}
+
+	// Validate all entries and group them by the tenant of their agent
+	now := s.clock.Now()
+	limits := s.metricsLimits()
+	var tenants []string
+	groups := make(map[string][]*types.MetricsData)
+	for i, m := range metrics {
+		if err := s.validateBackfill(m, now); err != nil {
+			return nil, fmt.Errorf("%w: entry %d: %v", types.ErrInvalidMetrics, i, err)
 		}
-	}()
+		if err := m.ValidateSchema(limits); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		m.ToUTC()
+		if m.Delta != nil {
+			return nil, fmt.Errorf("%w: entry %d: delta reports cannot be backfilled", types.ErrInvalidMetrics, i)
+		}
+		if err := s.checkTombstone(m); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+
+		// Metrics reference their agent, so it has to be registered first
+		scoped, err := s.agentScope(ctx, m.AgentID)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		s.agentsMu.RLock()
+		_, known := s.agents[m.AgentID]
+		s.agentsMu.RUnlock()
+		if !known {
+			return nil, fmt.Errorf("entry %d: %w: %s", i, types.ErrAgentNotFound, m.AgentID)
+		}
+		tenantID := tenant.OrDefault(scoped)
+		if _, ok := groups[tenantID]; !ok {
+			tenants = append(tenants, tenantID)
+		}
+		groups[tenantID] = append(groups[tenantID], m)
+	}
+
+	// History does not count towards the hourly samples, only storage
+	if err := s.checkQuotas(metrics, false); err != nil {
+		return nil, err
+	}
+
+	// Rates of historical reports follow the counters within the backfill,
+	// independent of the live reports
+	newRateTracker().applyAll(metrics)
+	for _, m := range metrics {
+		s.redactMetrics(m)
+	}
+
+	chunkSize := s.config.Database.MaxBatchSize
+	if chunkSize <= 0 {
+		chunkSize = len(metrics)
+	}
+
+	result := &types.MetricsBackfillResult{Received: len(metrics)}
+	for _, tenantID := range tenants {
+		tenantCtx := tenant.WithContext(ctx, tenantID)
+		for chunk := range slices.Chunk(groups[tenantID], chunkSize) {
+			saved, err := s.metricsRepo.BatchSave(tenantCtx, chunk)
+			if err != nil {
+				return result, fmt.Errorf("failed to save metrics batch: %w", err)
+			}
+			s.invalidateMetrics(ctx, metricsAgents(saved)...)
+			result.Stored += len(saved)
+			result.Duplicates += len(chunk) - len(saved)
+		}
+	}
+
+	s.log(ctx).Info("Metrics backfilled",
+		zap.Int("received", result.Received),
+		zap.Int("stored", result.Stored),
+		zap.Int("duplicates", result.Duplicates))
+
+	return result, nil
+}
+
+// validateBackfill validates a historical metrics entry, entries from the
+// future or past the retention period are rejected
+func (s *Service) validateBackfill(m *types.MetricsData, now time.Time) error {
+	if m == nil || m.AgentID == "" {
+		return fmt.Errorf("agent_id is required")
+	}
+	if m.Timestamp.IsZero() {
+		return fmt.Errorf("timestamp is required")
+	}
+	if m.Timestamp.After(now.Add(backfillClockSkew)) {
+		return fmt.Errorf("timestamp %s is in the future", m.Timestamp.Format(time.RFC3339))
+	}
+	if s.config.Database.EnablePruning && s.config.Database.MetricsRetention > 0 &&
+		m.Timestamp.Before(now.Add(-s.config.Database.MetricsRetention)) {
+		return fmt.Errorf("timestamp %s is older than the metrics retention", m.Timestamp.Format(time.RFC3339))
+	}
+
+	if m.CollectedAt.IsZero() {
+		m.CollectedAt = m.Timestamp
+	}
+	if m.ReportedAt.IsZero() {
+		m.ReportedAt = now
+	}
 
 	return nil
 }
 
+// metricsLimits returns the bounds of the metrics reports accepted
+func (s *Service) metricsLimits() *types.MetricsLimits {
+	return s.GetConfig().API.MetricsLimits.Limits()
+}
+
 // GetMetrics retrieves metrics based on query parameters
 func (s *Service) GetMetrics(ctx context.Context, query MetricsQuery) ([]*types.MetricsData, error) {
 	// Validate time range
@@ -112,115 +424,164 @@ func (s *Service) GetMetrics(ctx context.Context, query MetricsQuery) ([]*types.
 
 // GetLatestMetrics returns the latest metrics for an agent
 func (s *Service) GetLatestMetrics(ctx context.Context, agentID string) (*types.MetricsData, error) {
-	// Get latest metrics
-	metrics, err := s.metricsRepo.GetLatest(ctx, agentID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get latest metrics: %w", err)
+	load := func() (*types.MetricsData, error) {
+		metrics, err := s.metricsRepo.GetLatest(ctx, agentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest metrics: %w", err)
+		}
+		return metrics, nil
+	}
+
+	if !s.cachedAgent(ctx, agentID) {
+		return load()
 	}
-	return metrics, nil
+	return loadCached(ctx, s, cacheLatestMetrics+agentID, load)
 }
 
-// ExportMetrics exports metrics in specified format
-func (s *Service) ExportMetrics(ctx context.Context, format string, filter types.MetricsFilter) (io.Reader, error) {
-	// Get metrics based on filter
-	metrics, err := s.metricsRepo.Query(ctx, repository.QueryParams{
+// ExportMetrics exports metrics in specified format. The reports are
+// streamed from the database while the returned reader is consumed, closing
+// it stops the export.
+func (s *Service) ExportMetrics(ctx context.Context, format string, filter types.MetricsFilter) (io.ReadCloser, error) {
+	params := repository.QueryParams{
 		AgentIDs:  filter.AgentIDs,
 		StartTime: filter.StartTime,
 		EndTime:   filter.EndTime,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to query metrics: %w", err)
 	}
 
+	var write func(w io.Writer) error
 	switch format {
 	case "json":
-		return s.exportMetricsJSON(metrics)
+		write = func(w io.Writer) error {
+			_, err := s.writeMetricsJSON(ctx, w, params)
+			return err
+		}
 	case "csv":
-		return s.exportMetricsCSV(metrics)
+		write = func(w io.Writer) error {
+			return s.writeMetricsCSV(ctx, w, params)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported export format: %s", format)
 	}
-}
 
-// exportMetricsJSON exports metrics as JSON
-func (s *Service) exportMetricsJSON(metrics []*types.MetricsData) (io.Reader, error) {
 	pr, pw := io.Pipe()
 
 	go func() {
-		encoder := json.NewEncoder(pw)
-		err := encoder.Encode(metrics)
-		if err != nil {
-			_ = pw.CloseWithError(err)
-			return
+		bw := bufio.NewWriter(pw)
+		err := write(bw)
+		if err == nil {
+			err = bw.Flush()
 		}
-		_ = pw.Close()
+		_ = pw.CloseWithError(err)
 	}()
 
 	return pr, nil
 }
 
-// exportMetricsCSV exports metrics as CSV
-func (s *Service) exportMetricsCSV(metrics []*types.MetricsData) (io.Reader, error) {
-	pr, pw := io.Pipe()
+// writeMetricsJSON streams the reports matching params to w as a JSON array
+// and returns their number
+func (s *Service) writeMetricsJSON(ctx context.Context, w io.Writer, params repository.QueryParams) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
 
-	go func() {
-		writer := csv.NewWriter(pw)
-		defer writer.Flush()
-
-		// Write header
-		header := []string{
-			"AgentID",
-			"Timestamp",
-			"CollectedAt",
-			"ReportedAt",
-			"MetricType",
-			"Value",
-		}
-		if err := writer.Write(header); err != nil {
-			_ = pw.CloseWithError(err)
-			return
+	count := 0
+	err := s.metricsRepo.Stream(ctx, params, func(m *types.MetricsData) error {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
 		}
+		count++
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "]\n")
+	return count, err
+}
+
+// writeMetricsCSV streams the reports matching params to w as CSV
+func (s *Service) writeMetricsCSV(ctx context.Context, w io.Writer, params repository.QueryParams) error {
+	writer := csv.NewWriter(w)
+	loc := s.GetConfig().Server.Location()
+
+	// Write header
+	header := []string{
+		"AgentID",
+		"Timestamp",
+		"CollectedAt",
+		"ReportedAt",
+		"MetricType",
+		"Value",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
 
-		// Write metrics data
-		for _, m := range metrics {
-			// Write network metrics
-			if m.Metrics.Network != nil {
-				for name, iface := range m.Metrics.Network.Interfaces {
-					row := []string{
-						m.AgentID,
-						m.Timestamp.Format(time.RFC3339),
-						m.CollectedAt.Format(time.RFC3339),
-						m.ReportedAt.Format(time.RFC3339),
-						"network_interface",
-						fmt.Sprintf("%s:%s", name, iface.Status),
-					}
-					if err := writer.Write(row); err != nil {
-						_ = pw.CloseWithError(err)
-						return
-					}
-				}
+	// Write metrics data
+	err := s.metricsRepo.Stream(ctx, params, func(m *types.MetricsData) error {
+		// Write network metrics
+		if m.Metrics.Network == nil {
+			return nil
+		}
+		for name, iface := range m.Metrics.Network.Interfaces {
+			row := []string{
+				m.AgentID,
+				m.Timestamp.In(loc).Format(time.RFC3339),
+				m.CollectedAt.In(loc).Format(time.RFC3339),
+				m.ReportedAt.In(loc).Format(time.RFC3339),
+				"network_interface",
+				fmt.Sprintf("%s:%s", name, iface.Status),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
 			}
 		}
-		_ = pw.Close()
-	}()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	return pr, nil
+	writer.Flush()
+	return writer.Error()
 }
 
 // GetMetricsSummary returns a metrics summary for an agent
 func (s *Service) GetMetricsSummary(ctx context.Context, agentID string) (*types.MetricsSummary, error) {
-	// Verify agent exists
-	if _, err := s.agentRepo.FindByID(ctx, agentID); err != nil {
-		return nil, fmt.Errorf("failed to find agent: %w", err)
+	load := func() (*types.MetricsSummary, error) {
+		// Verify agent exists
+		if _, err := s.agentRepo.FindByID(ctx, agentID); err != nil {
+			return nil, fmt.Errorf("failed to find agent: %w", err)
+		}
+
+		// Get metrics summary from repository
+		summary, err := s.metricsRepo.GetMetricsSummary(ctx, agentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metrics summary: %w", err)
+		}
+		return summary, nil
 	}
 
-	// Get metrics summary from repository
-	summary, err := s.metricsRepo.GetMetricsSummary(ctx, agentID)
+	var summary *types.MetricsSummary
+	var err error
+	if s.cachedAgent(ctx, agentID) {
+		summary, err = loadCached(ctx, s, cacheMetricsSummary+agentID, load)
+	} else {
+		summary, err = load()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get metrics summary: %w", err)
+		return nil, err
 	}
 
-	// Get current agent status
+	// Get current agent status, it is not cached
 	s.agentsMu.RLock()
 	if agent, exists := s.agents[agentID]; exists {
 		summary.LastSeen = agent.LastSeen
@@ -233,26 +594,31 @@ func (s *Service) GetMetricsSummary(ctx context.Context, agentID string) (*types
 
 // ArchiveMetrics archives old metrics
 func (s *Service) ArchiveMetrics(ctx context.Context, opts types.MetricsArchiveOptions) error {
+	// Archive metrics to the requested storage
+	store, err := archive.New(opts.StorageType, &s.GetConfig().Archive)
+	if err != nil {
+		return err
+	}
+
 	// Get metrics to archive
-	metrics, err := s.metricsRepo.Query(ctx, repository.QueryParams{
+	spool, count, err := s.spoolMetrics(ctx, repository.QueryParams{
 		EndTime: opts.Before,
-	})
+	}, opts.Compress)
 	if err != nil {
 		return fmt.Errorf("failed to get metrics for archival: %w", err)
 	}
+	defer removeSpool(spool)
 
-	// Archive metrics based on storage type
-	switch opts.StorageType {
-	case "s3":
-		if err := s.archiveToS3(ctx, metrics, opts); err != nil {
-			return fmt.Errorf("failed to archive to S3: %w", err)
-		}
-	case "file":
-		if err := s.archiveToFile(ctx, metrics, opts); err != nil {
-			return fmt.Errorf("failed to archive to file: %w", err)
+	if count > 0 {
+		key := fmt.Sprintf("metrics/%s/metrics-%s.json", s.clock.Now().Format("2006-01-02"),
+			opts.Before.Format("2006-01-02"))
+		location, err := s.archiveMetrics(ctx, store, key, spool, opts.Compress)
+		if err != nil {
+			return fmt.Errorf("failed to archive to %s: %w", opts.StorageType, err)
 		}
-	default:
-		return fmt.Errorf("unsupported storage type: %s", opts.StorageType)
+		s.logger.Info("Archived metrics",
+			zap.Int("metrics_count", count),
+			zap.String("location", location))
 	}
 
 	// Delete archived metrics if requested
@@ -265,105 +631,60 @@ func (s *Service) ArchiveMetrics(ctx context.Context, opts types.MetricsArchiveO
 	return nil
 }
 
-// archiveToS3 archives metrics to S3
-func (s *Service) archiveToS3(ctx context.Context, metrics []*types.MetricsData, opts types.MetricsArchiveOptions) error {
-	if len(metrics) == 0 {
-		return nil
-	}
-
-	// Prepare archive file
-	archiveData, err := s.prepareArchiveData(metrics, opts.Compress)
-	if err != nil {
-		return fmt.Errorf("failed to prepare archive data: %w", err)
+// archiveMetrics stores the spooled archive under key, ".gz" is appended to
+// compressed archives
+func (s *Service) archiveMetrics(ctx context.Context, store archive.Store, key string, spool *os.File, compress bool) (string, error) {
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read archive data: %w", err)
 	}
-
-	// Generate archive key
-	timeStr := time.Now().Format("2006-01-02")
-	archiveKey := fmt.Sprintf("metrics/%s/metrics-%s.json", timeStr,
-		opts.Before.Format("2006-01-02"))
-	if opts.Compress {
-		archiveKey += ".gz"
-	}
-
-	// Upload to S3
-	if err := s.uploadToS3(ctx, archiveKey, archiveData); err != nil {
-		return fmt.Errorf("failed to upload archive to S3: %w", err)
+	if compress {
+		key += ".gz"
 	}
-
-	s.logger.Info("Archived metrics to S3",
-		zap.Int("metrics_count", len(metrics)),
-		zap.String("archive_key", archiveKey))
-
-	return nil
+	return store.Put(ctx, key, spool)
 }
 
-// archiveToFile archives metrics to local file
-func (s *Service) archiveToFile(_ context.Context, metrics []*types.MetricsData, opts types.MetricsArchiveOptions) error {
-	if len(metrics) == 0 {
-		return nil
-	}
-
-	// Prepare archive data
-	archiveData, err := s.prepareArchiveData(metrics, opts.Compress)
+// spoolMetrics streams the reports matching params as a JSON array to a
+// temporary file, gzipped when compress is set, so archives of any size are
+// never held in memory. It returns the file and the number of reports, the
+// file is released with removeSpool.
+func (s *Service) spoolMetrics(ctx context.Context, params repository.QueryParams, compress bool) (*os.File, int, error) {
+	spool, err := os.CreateTemp("", "wameter-archive-*")
 	if err != nil {
-		return fmt.Errorf("failed to prepare archive data: %w", err)
-	}
-
-	// Generate archive path
-	timeStr := time.Now().Format("2006-01-02")
-	archivePath := fmt.Sprintf("/var/lib/wameter/archives/metrics-%s.json", timeStr)
-	if opts.Compress {
-		archivePath += ".gz"
-	}
-
-	// Write to file
-	if err := s.writeArchiveFile(archivePath, archiveData); err != nil {
-		return fmt.Errorf("failed to write archive file: %w", err)
+		return nil, 0, fmt.Errorf("failed to create archive file: %w", err)
 	}
 
-	s.logger.Info("Archived metrics to file",
-		zap.Int("metrics_count", len(metrics)),
-		zap.String("archive_path", archivePath))
-
-	return nil
-}
-
-// prepareArchiveData prepares metrics data for archiving
-func (s *Service) prepareArchiveData(metrics []*types.MetricsData, compress bool) ([]byte, error) {
-	// Marshal metrics to JSON
-	data, err := json.Marshal(metrics)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal metrics: %w", err)
-	}
+	count, err := func() (int, error) {
+		bw := bufio.NewWriter(spool)
+		if !compress {
+			count, err := s.writeMetricsJSON(ctx, bw, params)
+			if err != nil {
+				return count, err
+			}
+			return count, bw.Flush()
+		}
 
-	// Compress if requested
-	if compress {
-		compressed, err := s.compressData(data)
+		zw := gzip.NewWriter(bw)
+		count, err := s.writeMetricsJSON(ctx, zw, params)
 		if err != nil {
-			return nil, fmt.Errorf("failed to compress data: %w", err)
+			return count, err
+		}
+		if err := zw.Close(); err != nil {
+			return count, fmt.Errorf("failed to compress data: %w", err)
 		}
-		return compressed, nil
+		return count, bw.Flush()
+	}()
+	if err != nil {
+		removeSpool(spool)
+		return nil, 0, err
 	}
 
-	return data, nil
-}
-
-// uploadToS3 uploads data to S3
-func (s *Service) uploadToS3(ctx context.Context, key string, data []byte) error {
-	// TODO: Implement S3 upload
-	return fmt.Errorf("S3 upload not implemented")
+	return spool, count, nil
 }
 
-// writeArchiveFile writes archive data to file
-func (s *Service) writeArchiveFile(path string, data []byte) error {
-	// TODO: Implement file writing with proper permissions and error handling
-	return fmt.Errorf("file archive not implemented")
-}
-
-// compressData compresses byte data
-func (s *Service) compressData(data []byte) ([]byte, error) {
-	// TODO: Implement data compression
-	return nil, fmt.Errorf("data compression not implemented")
+// removeSpool closes and deletes a file of spoolMetrics
+func removeSpool(spool *os.File) {
+	_ = spool.Close()
+	_ = os.Remove(spool.Name())
 }
 
 // DeleteMetrics deletes metrics before specified time
@@ -379,71 +700,128 @@ func (s *Service) DeleteMetrics(ctx context.Context, before time.Time) error {
 
 // processNetworkMetrics processes network metrics
 func (s *Service) processNetworkMetrics(ctx context.Context, data *types.MetricsData) {
+	ctx, span := tracing.Start(ctx, "service.processNetworkMetrics")
+	defer span.End()
+
 	network := data.Metrics.Network
 
 	// Handle IP changes
-	if len(network.IPChanges) > 0 {
-		for _, change := range network.IPChanges {
-			if err := s.ipChangeRepo.Save(ctx, data.AgentID, &change); err != nil {
-				s.logger.Error("Failed to save IP change",
-					zap.Error(err),
-					zap.String("agent_id", data.AgentID),
-					zap.String("interface", change.InterfaceName))
-				continue
-			}
-
-			// Send notification
-			if s.notifier != nil && s.config.Notify.Enabled {
-				agent := &types.AgentInfo{
-					ID:       data.AgentID,
-					Hostname: data.Hostname,
-					Status:   types.AgentStatusOnline,
-				}
-				s.notifier.NotifyIPChange(agent, &change)
-			}
-		}
+	tenantID := tenant.OrDefault(ctx)
+	agent := &types.AgentInfo{
+		ID:       data.AgentID,
+		Hostname: data.Hostname,
+		Status:   types.AgentStatusOnline,
 	}
-
-	// Check interface statistics
-	for _, iface := range network.Interfaces {
-		if iface.Statistics == nil {
-			continue
+	for _, change := range network.IPChanges {
+		// Redacted reports started their lookups before redaction
+		if !s.privacy.Enabled() {
+			s.lookupIPContext(&change)
 		}
-
-		// Error rates
-		totalErrors := iface.Statistics.RxErrors + iface.Statistics.TxErrors
-		if totalErrors > 100 && s.notifier != nil {
-			s.notifier.NotifyNetworkErrors(data.AgentID, iface)
+		if err := s.withIPContext(ctx, &change, func(ctx context.Context, change *types.IPChange) error {
+			return s.recordIPChange(ctx, tenantID, agent, change)
+		}); err != nil {
+			s.logger.Error("Failed to record IP change",
+				zap.Error(err),
+				zap.String("agent_id", data.AgentID),
+				zap.String("interface", change.InterfaceName))
 		}
+	}
+}
 
-		// High utilization
-		if (iface.Statistics.RxBytesRate+iface.Statistics.TxBytesRate) > 100*1024*1024 && s.notifier != nil {
-			s.notifier.NotifyHighNetworkUtilization(data.AgentID, iface)
-		}
+// redactMetrics redacts the addresses of a report as configured for
+// privacy. Lookups of external IP changes start first, which needs their
+// addresses.
+func (s *Service) redactMetrics(data *types.MetricsData) {
+	if !s.privacy.Enabled() || data.Metrics.Network == nil {
+		return
 	}
+	for i := range data.Metrics.Network.IPChanges {
+		s.lookupIPContext(&data.Metrics.Network.IPChanges[i])
+	}
+	s.privacy.Metrics(data)
 }
 
-// processMetricsAlerts processes metrics for alerts
+// processMetricsAlerts processes metrics for alerts, annotating them when
+// they start
 func (s *Service) processMetricsAlerts(data *types.MetricsData) {
+	if s.inMaintenance(data.AgentID) {
+		return
+	}
+	s.evaluateAlertRules(data)
+
 	if data.Metrics.Network == nil {
 		return
 	}
+
+	// Learned baselines replace the fixed thresholds once warmed up
+	utilizationWarm, errorsWarm := s.detectAnomalies(data)
+	if !s.GetConfig().Anomaly.ReplaceThresholds {
+		utilizationWarm, errorsWarm = nil, nil
+	}
+
+	errorsCfg := s.GetConfig().NetErrors
+	at := data.CollectedAt
+	if at.IsZero() {
+		at = s.clock.Now()
+	}
+
 	// Process network metrics
 	for _, iface := range data.Metrics.Network.Interfaces {
 		if iface.Statistics == nil {
 			continue
 		}
 
-		// Check for high error rates
-		totalErrors := iface.Statistics.RxErrors + iface.Statistics.TxErrors
-		if totalErrors > 100 {
+		// Check for high error rates, counters are cumulative so alerts are
+		// de-bounced by the errors since the last one
+		errorsHigh, errorsNotify := s.netErrors.check(&errorsCfg, data.AgentID+"/"+iface.Name, iface.Statistics, at)
+		if errorsWarm[iface.Name] {
+			errorsHigh, errorsNotify = false, false
+		}
+		if errorsNotify {
 			s.notifier.NotifyNetworkErrors(data.AgentID, iface)
 		}
+		if s.interfaceAlertStarted(data.AgentID, iface.Name, "network_errors", errorsHigh) {
+			s.annotateInterfaceAlert(data, iface, "network_errors")
+		}
 
-		// Check for high utilization
-		if iface.Statistics.RxBytesRate > 100*1024*1024 || // 100 MB/s
-			iface.Statistics.TxBytesRate > 100*1024*1024 {
+		// Check for high utilization, notified once per period it is high
+		utilizationHigh := !utilizationWarm[iface.Name] && s.highUtilization(iface)
+		if s.interfaceAlertStarted(data.AgentID, iface.Name, "high_utilization", utilizationHigh) {
 			s.notifier.NotifyHighNetworkUtilization(data.AgentID, iface)
+			s.annotateInterfaceAlert(data, iface, "high_utilization")
 		}
 	}
 }
+
+// interfaceAlertStarted records whether an alert is firing on an interface
+// and reports whether it just started
+func (s *Service) interfaceAlertStarted(agentID, iface, alert string, firing bool) bool {
+	key := agentID + "/" + iface + "/" + alert
+
+	s.firingMu.Lock()
+	defer s.firingMu.Unlock()
+
+	started := firing && !s.firing[key]
+	if firing {
+		s.firing[key] = true
+	} else {
+		delete(s.firing, key)
+	}
+	return started
+}
+
+// highUtilization reports whether the busier direction of an interface
+// exceeds its threshold, relative to the link speed when it is known
+func (s *Service) highUtilization(iface *types.InterfaceInfo) bool {
+	// Rates of reset counters are unknown
+	if iface.Statistics.CounterReset {
+		return false
+	}
+
+	cfg := s.GetConfig().Utilization
+	percent, absolute := cfg.Thresholds(iface.Name, iface.Type)
+	if iface.Statistics.Speed > 0 {
+		return iface.Statistics.Utilization() > percent
+	}
+	return max(iface.Statistics.RxBytesRate, iface.Statistics.TxBytesRate) > absolute
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"wameter/internal/database"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// testConfig is the configuration of the alert tests, notifications are
+// sent to a webhook
+const testConfig = `
+database:
+  driver: sqlite
+  dsn: %s
+  auto_migrate: true
+  migrations_path: ../migrations
+notify:
+  enabled: true
+  retry_delay: 1s
+  rate_limit:
+    interval: 1m
+    max_events: 100
+  webhook:
+    enabled: true
+    url: %s
+    max_retries: 1
+network_errors:
+  rate: 1
+  min_errors: 100
+  cooldown: 15m
+utilization:
+  absolute: 1048576
+agent_monitor:
+  check_interval: 24h # Agent checks are run by the tests
+`
+
+// webhookEvents counts the notifications received by a webhook by event
+type webhookEvents struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (e *webhookEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	e.counts[r.Header.Get("X-Wameter-Event")]++
+	e.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// newTestService returns a service on a temporary database notifying a
+// webhook, with agent-1 registered
+func newTestService(t *testing.T, opts ...Option) (*Service, *webhookEvents) {
+	events := &webhookEvents{counts: make(map[string]int)}
+	srv := httptest.NewServer(events)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.yaml")
+	yaml := []byte(fmt.Sprintf(testConfig, filepath.Join(dir, "data.db"), srv.URL))
+	require.NoError(t, os.WriteFile(path, yaml, 0o600))
+
+	cfg, err := config.LoadConfig(path, nil)
+	require.NoError(t, err)
+
+	logger := zaptest.NewLogger(t)
+	db, err := database.New(&cfg.Database, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	svc, err := NewService(cfg, db, logger, opts...)
+	require.NoError(t, err)
+	require.NoError(t, svc.RegisterAgent(context.Background(), &types.AgentInfo{
+		ID:       "agent-1",
+		Hostname: "host-1",
+	}))
+	return svc, events
+}
+
+// testReport returns a report of an interface with cumulative error and
+// received bytes counters
+func testReport(at time.Time, errors, rxBytes uint64) *types.MetricsData {
+	return &types.MetricsData{
+		AgentID:     "agent-1",
+		Hostname:    "host-1",
+		Timestamp:   at,
+		CollectedAt: at,
+		Metrics: struct {
+			Network *types.NetworkState `json:"network,omitempty"`
+		}{
+			Network: &types.NetworkState{
+				Interfaces: map[string]*types.InterfaceInfo{
+					"eth0": {
+						Name: "eth0",
+						Statistics: &types.InterfaceStats{
+							IsUp:     true,
+							RxErrors: errors,
+							RxBytes:  rxBytes,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// TestMetricsAlertsExactlyOnce tests that each occurrence of an alert
+// condition is notified once, whichever way the reports are stored
+func TestMetricsAlertsExactlyOnce(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		name string
+		save func(t *testing.T, svc *Service, reports []*types.MetricsData)
+	}{
+		{
+			name: "Single reports",
+			save: func(t *testing.T, svc *Service, reports []*types.MetricsData) {
+				for _, r := range reports {
+					require.NoError(t, svc.SaveMetrics(context.Background(), r))
+				}
+			},
+		},
+		{
+			name: "Batch",
+			save: func(t *testing.T, svc *Service, reports []*types.MetricsData) {
+				require.NoError(t, svc.BatchSave(context.Background(), reports))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, events := newTestService(t)
+
+			// Errors grow by 600/min for 10 minutes, one occurrence within the
+			// cool-down, then stop; 2 MB/s are received in two separate periods
+			var reports []*types.MetricsData
+			errors, rxBytes := uint64(1000), uint64(0)
+			for i := 0; i < 20; i++ {
+				if i >= 2 && i < 12 {
+					errors += 600
+				}
+				if (i >= 3 && i < 6) || (i >= 10 && i < 14) {
+					rxBytes += 60 * 2 << 20
+				}
+				reports = append(reports, testReport(start.Add(time.Duration(i)*time.Minute), errors, rxBytes))
+			}
+
+			tc.save(t, svc, reports)
+
+			// Resubmitted reports are not notified again
+			tc.save(t, svc, reports[:5])
+
+			require.NoError(t, svc.Stop(context.Background()))
+
+			events.mu.Lock()
+			defer events.mu.Unlock()
+			assert.Equal(t, 1, events.counts["network.errors"])
+			assert.Equal(t, 2, events.counts["network.high_utilization"])
+		})
+	}
+}
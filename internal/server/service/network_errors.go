@@ -0,0 +1,72 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// networkErrorTracker de-bounces the network error alerts of interfaces,
+// whose error counters are cumulative
+type networkErrorTracker struct {
+	states map[string]*networkErrorState // Agent ID/interface
+	mu     sync.Mutex
+}
+
+// networkErrorState represents the error counters of an interface
+type networkErrorState struct {
+	prev       uint64 // Errors of the previous report
+	prevAt     time.Time
+	notified   uint64 // Errors at the last alert
+	notifiedAt time.Time
+}
+
+// newNetworkErrorTracker creates new network error tracker
+func newNetworkErrorTracker() *networkErrorTracker {
+	return &networkErrorTracker{states: make(map[string]*networkErrorState)}
+}
+
+// check records the errors of an interface reported at a time. It reports
+// whether the errors grow faster than the configured rate, and whether to
+// alert, which also needs enough errors since the last alert and its
+// cool-down to have passed. The first report and reset counters only set
+// the reference.
+func (t *networkErrorTracker) check(cfg *config.NetworkErrorsConfig, key string, stats *types.InterfaceStats, at time.Time) (high, notify bool) {
+	total := stats.RxErrors + stats.TxErrors
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := t.states[key]
+	if st == nil || total < st.prev || stats.CounterReset {
+		t.states[key] = &networkErrorState{prev: total, prevAt: at, notified: total}
+		return false, false
+	}
+	if !at.After(st.prevAt) {
+		return false, false
+	}
+
+	delta := total - st.prev
+	rate := float64(delta) / at.Sub(st.prevAt).Seconds()
+	st.prev, st.prevAt = total, at
+	high = delta > 0 && rate >= cfg.Rate
+
+	if high && total-st.notified >= cfg.MinErrors && at.Sub(st.notifiedAt) >= cfg.Cooldown {
+		st.notified, st.notifiedAt = total, at
+		notify = true
+	}
+	return high, notify
+}
+
+// forget drops the counters of an agent
+func (t *networkErrorTracker) forget(agentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.states {
+		if strings.HasPrefix(key, agentID+"/") {
+			delete(t.states, key)
+		}
+	}
+}
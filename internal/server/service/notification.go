@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// notificationTestTimeout bounds the wait for deliveries of test notifications
+const notificationTestTimeout = 30 * time.Second
+
+// NotificationService represents notification service interface
+type NotificationService interface {
+	TestNotification(ctx context.Context, channel, actor string) ([]*types.NotificationTestResult, error)
+}
+
+// _ implements NotificationService
+var _ NotificationService = (*Service)(nil)
+
+// TestNotification sends a test alert through a notification channel, or
+// through all enabled channels when none is given, and returns the
+// delivery of each channel
+func (s *Service) TestNotification(ctx context.Context, channel, actor string) ([]*types.NotificationTestResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, notificationTestTimeout)
+	defer cancel()
+
+	message := "Test notification, the channel is configured correctly"
+	if actor != "" {
+		message = fmt.Sprintf("Test notification requested by %s, the channel is configured correctly", actor)
+	}
+	alert := &types.RuleAlert{
+		Rule:     "notification_test",
+		Severity: "info",
+		AgentID:  "test",
+		Hostname: s.nodeID,
+		Message:  message,
+		Labels:   map[string]string{"test": "true"},
+		Time:     s.clock.Now(),
+	}
+
+	results, err := s.notifier.Test(ctx, alert, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		s.log(ctx).Info("Test notification delivered",
+			zap.String("channel", r.Channel),
+			zap.String("status", string(r.Status)),
+			zap.String("error", r.Error))
+	}
+	return results, nil
+}
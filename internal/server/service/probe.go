@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wameter/internal/types"
+)
+
+// Probe check statuses
+const (
+	probeOK   = "ok"
+	probeWarn = "warn"
+	probeFail = "fail"
+)
+
+const (
+	// probeTimeout bounds each dependency check
+	probeTimeout = 3 * time.Second
+	// workerGrace is added to the expected interval before a worker is considered stalled
+	workerGrace = 30 * time.Second
+	// queueHighWatermark is the notification queue usage ratio considered saturated
+	queueHighWatermark = 0.9
+)
+
+// ProbeService represents liveness and readiness probe service interface
+type ProbeService interface {
+	Liveness(ctx context.Context) *types.ProbeStatus
+	Readiness(ctx context.Context) *types.ProbeStatus
+	SetReady(ready bool)
+	IsReady() bool
+}
+
+// _ implements ProbeService
+var _ ProbeService = (*Service)(nil)
+
+// workerHeartbeat tracks the liveness of a background worker
+type workerHeartbeat struct {
+	interval time.Duration
+	lastBeat time.Time
+}
+
+// registerWorker registers a background worker expected to beat every interval
+func (s *Service) registerWorker(name string, interval time.Duration) {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+	s.workers[name] = &workerHeartbeat{interval: interval, lastBeat: s.clock.Now()}
+}
+
+// unregisterWorker removes a stopped background worker
+func (s *Service) unregisterWorker(name string) {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+	delete(s.workers, name)
+}
+
+// beat records a heartbeat of a background worker
+func (s *Service) beat(name string) {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+	if w, ok := s.workers[name]; ok {
+		w.lastBeat = s.clock.Now()
+	}
+}
+
+// SetReady marks whether the service accepts traffic
+func (s *Service) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// IsReady returns whether the service accepts traffic
+func (s *Service) IsReady() bool {
+	return s.ready.Load() && s.ctx.Err() == nil
+}
+
+// Liveness checks whether the server is alive and its dependencies are healthy
+func (s *Service) Liveness(ctx context.Context) *types.ProbeStatus {
+	return s.probe(ctx)
+}
+
+// Readiness checks whether the server is alive and ready to accept traffic
+func (s *Service) Readiness(ctx context.Context) *types.ProbeStatus {
+	status := s.probe(ctx)
+	status.Checks["startup"] = s.checkStartup()
+	status.Evaluate()
+	return status
+}
+
+// probe runs the dependency checks shared by liveness and readiness
+func (s *Service) probe(ctx context.Context) *types.ProbeStatus {
+	status := &types.ProbeStatus{
+		Ready:     s.IsReady(),
+		Checks:    make(map[string]*types.ProbeCheck),
+		Timestamp: s.clock.Now(),
+	}
+
+	status.Checks["database"] = s.runCheck(ctx, true, s.checkDatabaseHealth)
+	if s.notifier.Enabled() {
+		// External notifier outages degrade the server but must not take it out of rotation
+		status.Checks["notifier"] = s.runCheck(ctx, false, s.notifier.Check)
+		status.Checks["notify_queue"] = s.checkNotifyQueue()
+	}
+	if s.ingest != nil {
+		status.Checks["ingest_queue"] = s.checkIngestQueue()
+	}
+	if s.agentState != nil {
+		// Offline checks pause while Redis is down, the replica keeps serving
+		status.Checks["agent_state"] = s.runCheck(ctx, false, s.agentState.Ping)
+	}
+	if s.config.Cluster.Enabled {
+		status.Checks["leader"] = s.checkLeader()
+	}
+	status.Checks["workers"] = s.checkWorkers()
+
+	status.Evaluate()
+	return status
+}
+
+// runCheck runs a dependency check with a timeout and records its latency
+func (s *Service) runCheck(ctx context.Context, critical bool, fn func(context.Context) error) *types.ProbeCheck {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+
+	check := &types.ProbeCheck{
+		Status:   probeOK,
+		Latency:  float64(time.Since(start).Microseconds()) / 1000,
+		Critical: critical,
+	}
+	if err != nil {
+		check.Status = probeFail
+		if !critical {
+			check.Status = probeWarn
+		}
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+// checkNotifyQueue checks the pending notification queue depth
+func (s *Service) checkNotifyQueue() *types.ProbeCheck {
+	depth, capacity := s.notifier.QueueDepth()
+	check := &types.ProbeCheck{
+		Status:   probeOK,
+		Message:  fmt.Sprintf("%d/%d pending", depth, capacity),
+		Critical: true,
+	}
+	if capacity > 0 && float64(depth) >= float64(capacity)*queueHighWatermark {
+		check.Status = probeFail
+		check.Error = "notification queue saturated"
+	}
+	return check
+}
+
+// checkIngestQueue checks the metrics ingest queue depth, a saturated queue
+// rejects reports until the database catches up
+func (s *Service) checkIngestQueue() *types.ProbeCheck {
+	stats := s.ingest.Stats()
+	check := &types.ProbeCheck{
+		Status:   probeOK,
+		Message:  fmt.Sprintf("%d/%d queued, lag %.0fms", stats.Depth, stats.Capacity, stats.Lag),
+		Critical: false,
+	}
+	if stats.Capacity > 0 && float64(stats.Depth) >= float64(stats.Capacity)*queueHighWatermark {
+		check.Status = probeWarn
+		check.Error = "ingest queue saturated"
+	}
+	return check
+}
+
+// checkLeader reports whether this replica runs scheduled jobs
+func (s *Service) checkLeader() *types.ProbeCheck {
+	role := "follower"
+	if s.isLeader() {
+		role = "leader"
+	}
+	return &types.ProbeCheck{
+		Status:  probeOK,
+		Message: fmt.Sprintf("%s (node %s)", role, s.nodeID),
+	}
+}
+
+// checkWorkers checks that background workers are still beating
+func (s *Service) checkWorkers() *types.ProbeCheck {
+	s.workersMu.RLock()
+	defer s.workersMu.RUnlock()
+
+	check := &types.ProbeCheck{
+		Status:   probeOK,
+		Message:  fmt.Sprintf("%d running", len(s.workers)),
+		Critical: true,
+	}
+
+	var stalled []string
+	now := s.clock.Now()
+	for name, w := range s.workers {
+		if now.Sub(w.lastBeat) > 2*w.interval+workerGrace {
+			stalled = append(stalled, fmt.Sprintf("%s (last beat %s ago)", name, now.Sub(w.lastBeat).Round(time.Second)))
+		}
+	}
+	if len(stalled) > 0 {
+		check.Status = probeFail
+		check.Error = fmt.Sprintf("stalled workers: %v", stalled)
+	}
+
+	return check
+}
+
+// checkStartup checks that startup has completed and shutdown has not begun
+func (s *Service) checkStartup() *types.ProbeCheck {
+	check := &types.ProbeCheck{
+		Status:   probeOK,
+		Critical: true,
+	}
+	switch {
+	case s.ctx.Err() != nil:
+		check.Status = probeFail
+		check.Error = "shutting down"
+	case !s.ready.Load():
+		check.Status = probeFail
+		check.Error = "starting up"
+	}
+	return check
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"wameter/internal/types"
+)
+
+// negotiateProtocol returns the protocol to use with an agent, the features
+// offered that the agent supports. Agents sending no capabilities predate
+// negotiation and get none, they are refused once a minimum API version is
+// required.
+func (s *Service) negotiateProtocol(caps *types.AgentCapabilities) (*types.AgentProtocol, error) {
+	cfg := &s.GetConfig().API.Protocol
+
+	version := 0
+	if caps != nil {
+		version = min(caps.APIVersion, types.AgentAPIVersion)
+	}
+	if version < cfg.MinAPIVersion {
+		return nil, fmt.Errorf("%w: version %d, at least %d required",
+			types.ErrAgentUnsupported, version, cfg.MinAPIVersion)
+	}
+	if version <= 0 {
+		return nil, nil
+	}
+
+	p := &types.AgentProtocol{
+		APIVersion:   version,
+		Features:     []string{},
+		NegotiatedAt: s.clock.Now(),
+	}
+	for _, feature := range cfg.Features {
+		if !slices.Contains(caps.Features, feature) {
+			continue
+		}
+		if feature == types.FeatureCompression {
+			if p.Encoding = negotiateEncoding(caps.Encodings, cfg.Encodings); p.Encoding == "" {
+				continue
+			}
+		}
+		p.Features = append(p.Features, feature)
+	}
+	return p, nil
+}
+
+// negotiateEncoding returns the encoding the agent prefers most of those
+// offered, empty when none is
+func negotiateEncoding(preferred, offered []string) string {
+	for _, encoding := range preferred {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if slices.Contains(offered, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
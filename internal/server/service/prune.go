@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"wameter/internal/database"
+	"wameter/internal/types"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// PruneService represents database prune service interface
+type PruneService interface {
+	Prune(ctx context.Context, req *types.PruneRequest) (*types.Prune, error)
+	GetPrune(ctx context.Context) (*types.Prune, error)
+}
+
+// _ implements PruneService
+var _ PruneService = (*Service)(nil)
+
+// Prune deletes the metrics older than the cutoff of the request in the
+// background, the returned prune is updated with its progress. A dry run
+// only counts the rows that would be deleted. One prune runs at a time.
+func (s *Service) Prune(ctx context.Context, req *types.PruneRequest) (*types.Prune, error) {
+	now := s.clock.Now()
+	if req.Before.IsZero() {
+		return nil, fmt.Errorf("%w: before is required", types.ErrInvalidPrune)
+	}
+	if req.Before.After(now) {
+		return nil, fmt.Errorf("%w: before must not be in the future", types.ErrInvalidPrune)
+	}
+
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	if !req.DryRun && s.lastPrune != nil && s.lastPrune.Status == types.PruneRunning {
+		return nil, types.ErrPruneRunning
+	}
+
+	rows, err := s.db.CountCleanup(ctx, req.Before)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &types.Prune{
+		ID:          uuid.New().String(),
+		Before:      req.Before,
+		DryRun:      req.DryRun,
+		RequestedBy: req.RequestedBy,
+		Status:      types.PruneRunning,
+		Rows:        rows,
+		StartedAt:   now,
+	}
+	if req.DryRun {
+		p.Status = types.PruneCompleted
+		p.CompletedAt = &now
+		return p, nil
+	}
+	s.lastPrune = p
+
+	s.log(ctx).Info("Prune started",
+		zap.String("id", p.ID),
+		zap.Time("before", p.Before),
+		zap.Int64("rows", rows),
+		zap.String("requested_by", p.RequestedBy))
+
+	s.goBackground(func() {
+		ctx := database.WithCleanupProgress(s.ctx, func(deleted int64) {
+			s.pruneMu.Lock()
+			p.Deleted = deleted
+			s.pruneMu.Unlock()
+		})
+		s.finishPrune(p, s.db.Cleanup(ctx, p.Before))
+	})
+
+	cp := *p
+	return &cp, nil
+}
+
+// finishPrune records the outcome of a prune
+func (s *Service) finishPrune(p *types.Prune, err error) {
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	now := s.clock.Now()
+	p.CompletedAt = &now
+	if err != nil {
+		p.Status = types.PruneFailed
+		p.Error = err.Error()
+		s.logger.Error("Prune failed",
+			zap.Error(err),
+			zap.String("id", p.ID),
+			zap.Int64("deleted", p.Deleted))
+		return
+	}
+
+	p.Status = types.PruneCompleted
+	s.logger.Info("Prune completed",
+		zap.String("id", p.ID),
+		zap.Int64("deleted", p.Deleted),
+		zap.Duration("duration", now.Sub(p.StartedAt)))
+}
+
+// GetPrune returns the last prune with its progress
+func (s *Service) GetPrune(_ context.Context) (*types.Prune, error) {
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	if s.lastPrune == nil {
+		return nil, types.ErrPruneNotFound
+	}
+	p := *s.lastPrune
+	return &p, nil
+}
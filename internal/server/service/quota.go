@@ -0,0 +1,339 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// QuotaService represents agent quota service interface
+type QuotaService interface {
+	GetQuotas(ctx context.Context) []*types.AgentQuota
+}
+
+// _ implements QuotaService
+var _ QuotaService = (*Service)(nil)
+
+// quotaTracker counts the reports agents sent within the last hour and
+// holds their measured storage, which grows with the reports admitted
+// until it is measured again
+type quotaTracker struct {
+	samples    map[string]*hourWindow
+	storage    map[string]*types.AgentStorage
+	measuredAt time.Time
+	mu         sync.Mutex
+}
+
+// hourWindow counts events per minute of the last hour
+type hourWindow struct {
+	counts  [60]int64
+	minutes [60]int64 // Unix minute of each count
+}
+
+// newQuotaTracker creates new quota tracker
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{
+		samples: make(map[string]*hourWindow),
+		storage: make(map[string]*types.AgentStorage),
+	}
+}
+
+// total returns the events within the hour before now
+func (w *hourWindow) total(now time.Time) int64 {
+	minute := now.Unix() / 60
+	var n int64
+	for i := range w.counts {
+		if minute-w.minutes[i] < 60 {
+			n += w.counts[i]
+		}
+	}
+	return n
+}
+
+// add counts n events at now
+func (w *hourWindow) add(now time.Time, n int64) {
+	minute := now.Unix() / 60
+	i := minute % 60
+	if w.minutes[i] != minute {
+		w.minutes[i], w.counts[i] = minute, 0
+	}
+	w.counts[i] += n
+}
+
+// retryAfter returns how long until n more events fit into limit, as the
+// oldest minutes leave the window
+func (w *hourWindow) retryAfter(now time.Time, n, limit int64) time.Duration {
+	minute := now.Unix() / 60
+	total := w.total(now)
+	for m := minute - 59; m <= minute; m++ {
+		if i := m % 60; w.minutes[i] == m {
+			total -= w.counts[i]
+		}
+		if total+n <= limit {
+			return time.Unix((m+60)*60, 0).Sub(now)
+		}
+	}
+	return time.Hour
+}
+
+// admit checks the reports of agents, counted per agent, against their
+// quotas and counts them when all fit. Only reports admitted as hourly
+// count towards the hourly samples, backfilled history is limited by
+// storage alone.
+func (t *quotaTracker) admit(cfg *config.QuotaConfig, counts map[string]int64, hourly bool, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for agentID, n := range counts {
+		if err := t.check(cfg, agentID, n, hourly, now); err != nil {
+			return err
+		}
+	}
+
+	for agentID, n := range counts {
+		st := t.storage[agentID]
+		if st == nil {
+			st = &types.AgentStorage{AgentID: agentID}
+			t.storage[agentID] = st
+		}
+		st.Rows += n
+
+		if hourly {
+			w := t.samples[agentID]
+			if w == nil {
+				w = &hourWindow{}
+				t.samples[agentID] = w
+			}
+			w.add(now, n)
+		}
+	}
+	return nil
+}
+
+// check checks n reports of an agent against its quotas
+func (t *quotaTracker) check(cfg *config.QuotaConfig, agentID string, n int64, hourly bool, now time.Time) error {
+	if st := t.storage[agentID]; st != nil {
+		quota, limit := "", int64(0)
+		switch {
+		case cfg.MaxRows > 0 && st.Rows+n > cfg.MaxRows:
+			quota, limit = types.QuotaRows, cfg.MaxRows
+		case cfg.MaxBytes > 0 && st.Bytes >= cfg.MaxBytes:
+			quota, limit = types.QuotaBytes, cfg.MaxBytes
+		}
+		if quota != "" {
+			err := &types.QuotaError{AgentID: agentID, Quota: quota, Limit: limit, Err: types.ErrQuotaExceeded}
+			if cfg.Action == config.QuotaActionThrottle {
+				err.Err, err.RetryAfter = types.ErrIngestThrottled, cfg.CheckInterval
+			}
+			return err
+		}
+	}
+
+	if hourly && cfg.MaxSamplesPerHour > 0 {
+		w := t.samples[agentID]
+		if w == nil {
+			w = &hourWindow{}
+		}
+		if w.total(now)+n > cfg.MaxSamplesPerHour {
+			return &types.QuotaError{
+				AgentID:    agentID,
+				Quota:      types.QuotaSamples,
+				Limit:      cfg.MaxSamplesPerHour,
+				RetryAfter: w.retryAfter(now, n, cfg.MaxSamplesPerHour),
+				Err:        types.ErrIngestThrottled,
+			}
+		}
+	}
+	return nil
+}
+
+// setStorage replaces the storage of agents with a measurement
+func (t *quotaTracker) setStorage(storage []*types.AgentStorage, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.storage = make(map[string]*types.AgentStorage, len(storage))
+	for _, st := range storage {
+		t.storage[st.AgentID] = st
+	}
+	t.measuredAt = now
+}
+
+// usage returns the quota usage of agents with stored or recent reports
+func (t *quotaTracker) usage(cfg *config.QuotaConfig, now time.Time) []*types.AgentQuota {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quotas := make(map[string]*types.AgentQuota)
+	get := func(agentID string) *types.AgentQuota {
+		q := quotas[agentID]
+		if q == nil {
+			q = &types.AgentQuota{AgentID: agentID, MeasuredAt: t.measuredAt}
+			quotas[agentID] = q
+		}
+		return q
+	}
+	for agentID, st := range t.storage {
+		q := get(agentID)
+		q.Rows, q.Bytes = st.Rows, st.Bytes
+	}
+	for agentID, w := range t.samples {
+		if n := w.total(now); n > 0 {
+			get(agentID).SamplesHour = n
+		}
+	}
+
+	result := make([]*types.AgentQuota, 0, len(quotas))
+	for _, q := range quotas {
+		if cfg.MaxRows > 0 && q.Rows >= cfg.MaxRows {
+			q.Exceeded = append(q.Exceeded, types.QuotaRows)
+		}
+		if cfg.MaxBytes > 0 && q.Bytes >= cfg.MaxBytes {
+			q.Exceeded = append(q.Exceeded, types.QuotaBytes)
+		}
+		if cfg.MaxSamplesPerHour > 0 && q.SamplesHour >= cfg.MaxSamplesPerHour {
+			q.Exceeded = append(q.Exceeded, types.QuotaSamples)
+		}
+		result = append(result, q)
+	}
+	slices.SortFunc(result, func(a, b *types.AgentQuota) int {
+		return cmp.Compare(a.AgentID, b.AgentID)
+	})
+	return result
+}
+
+// forget drops the counters of an agent
+func (t *quotaTracker) forget(agentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.samples, agentID)
+	delete(t.storage, agentID)
+}
+
+// checkQuotas admits reports against the quotas of their agents, alerting
+// administrators when an agent goes over one
+func (s *Service) checkQuotas(metrics []*types.MetricsData, hourly bool) error {
+	cfg := s.GetConfig().Quotas
+	if !cfg.Enabled {
+		return nil
+	}
+
+	counts := make(map[string]int64)
+	for _, m := range metrics {
+		counts[m.AgentID]++
+	}
+
+	err := s.quotas.admit(&cfg, counts, hourly, s.clock.Now())
+	var qerr *types.QuotaError
+	if errors.As(err, &qerr) {
+		s.alertQuota(&cfg, qerr)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if hourly {
+		for agentID := range counts {
+			s.ruleStarted(agentID, "quota_"+types.QuotaSamples, false)
+		}
+	}
+	return nil
+}
+
+// alertQuota alerts administrators when an agent goes over a quota
+func (s *Service) alertQuota(cfg *config.QuotaConfig, qerr *types.QuotaError) {
+	if !s.ruleStarted(qerr.AgentID, "quota_"+qerr.Quota, true) {
+		return
+	}
+
+	limit := strconv.FormatInt(qerr.Limit, 10)
+	if qerr.Quota == types.QuotaBytes {
+		limit = utils.FormatBytes(uint64(qerr.Limit))
+	}
+	action := "rejected until data is pruned"
+	if errors.Is(qerr, types.ErrIngestThrottled) {
+		action = "throttled"
+	}
+
+	s.agentsMu.RLock()
+	var hostname string
+	if agent, ok := s.agents[qerr.AgentID]; ok {
+		hostname = agent.Hostname
+	}
+	s.agentsMu.RUnlock()
+
+	alert := &types.RuleAlert{
+		Rule:     "quota_exceeded",
+		Severity: cfg.Severity,
+		AgentID:  qerr.AgentID,
+		Hostname: hostname,
+		Message: fmt.Sprintf("Agent %s is over its %s quota of %s, its metrics are %s",
+			qerr.AgentID, qerr.Quota, limit, action),
+		Labels: map[string]string{
+			"quota":  qerr.Quota,
+			"limit":  strconv.FormatInt(qerr.Limit, 10),
+			"action": cfg.Action,
+		},
+		Time: s.clock.Now(),
+	}
+
+	s.logger.Warn("Agent over quota",
+		zap.String("agent_id", qerr.AgentID),
+		zap.String("quota", qerr.Quota),
+		zap.Int64("limit", qerr.Limit))
+
+	s.notifier.NotifyRuleAlert(alert)
+	s.annotateRuleAlert(alert)
+}
+
+// measureQuotas measures the storage of agents, alerting the agents that
+// went over a storage quota and clearing those back under it
+func (s *Service) measureQuotas(ctx context.Context, cfg *config.QuotaConfig) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	storage, err := s.metricsRepo.GetStorageByAgent(ctx)
+	if err != nil {
+		s.logger.Error("Failed to measure agent storage", zap.Error(err))
+		return
+	}
+	s.quotas.setStorage(storage, s.clock.Now())
+
+	for _, st := range storage {
+		for _, q := range []struct {
+			quota string
+			used  int64
+			limit int64
+		}{
+			{types.QuotaRows, st.Rows, cfg.MaxRows},
+			{types.QuotaBytes, st.Bytes, cfg.MaxBytes},
+		} {
+			if q.limit <= 0 || q.used < q.limit {
+				s.ruleStarted(st.AgentID, "quota_"+q.quota, false)
+				continue
+			}
+			qerr := &types.QuotaError{AgentID: st.AgentID, Quota: q.quota, Limit: q.limit, Err: types.ErrQuotaExceeded}
+			if cfg.Action == config.QuotaActionThrottle {
+				qerr.Err = types.ErrIngestThrottled
+			}
+			s.alertQuota(cfg, qerr)
+		}
+	}
+}
+
+// GetQuotas returns the quota usage of the agents
+func (s *Service) GetQuotas(_ context.Context) []*types.AgentQuota {
+	cfg := s.GetConfig().Quotas
+	return s.quotas.usage(&cfg, s.clock.Now())
+}
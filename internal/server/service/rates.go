@@ -0,0 +1,82 @@
+package service
+
+import (
+	"slices"
+	"sync"
+	"wameter/internal/types"
+	"wameter/internal/utils"
+)
+
+// rateTracker computes interface rates from the counters of consecutive
+// reports, so rates do not depend on the sampling of the agent and survive
+// its restarts
+type rateTracker struct {
+	samples map[string]map[string]*types.InterfaceStats // Agent ID, interface
+	mu      sync.Mutex
+}
+
+// newRateTracker creates new rate tracker
+func newRateTracker() *rateTracker {
+	return &rateTracker{samples: make(map[string]map[string]*types.InterfaceStats)}
+}
+
+// applyAll applies reports in the order they were collected
+func (t *rateTracker) applyAll(metrics []*types.MetricsData) {
+	sorted := slices.SortedStableFunc(slices.Values(metrics), func(a, b *types.MetricsData) int {
+		return a.CollectedAt.Compare(b.CollectedAt)
+	})
+	for _, data := range sorted {
+		t.apply(data)
+	}
+}
+
+// apply sets the deltas and rates of the interfaces of data from the
+// previous report of the agent. Interfaces without a previous sample keep
+// the rates of the agent, those whose counters were reset are marked and
+// have no rates. Reports older than the previous one are left unchanged.
+func (t *rateTracker) apply(data *types.MetricsData) {
+	if data.Metrics.Network == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.samples[data.AgentID]
+	if !ok {
+		prev = make(map[string]*types.InterfaceStats)
+		t.samples[data.AgentID] = prev
+	}
+
+	for name, iface := range data.Metrics.Network.Interfaces {
+		stats := iface.Statistics
+		if stats == nil {
+			continue
+		}
+		if stats.CollectedAt.IsZero() {
+			stats.CollectedAt = data.CollectedAt
+		}
+
+		last, ok := prev[name]
+		if ok && !stats.CollectedAt.After(last.CollectedAt) {
+			continue
+		}
+		prev[name] = &types.InterfaceStats{
+			RxBytes:     stats.RxBytes,
+			TxBytes:     stats.TxBytes,
+			RxPackets:   stats.RxPackets,
+			TxPackets:   stats.TxPackets,
+			CollectedAt: stats.CollectedAt,
+		}
+		if ok {
+			utils.SetInterfaceRates(stats, last)
+		}
+	}
+}
+
+// forget drops the samples of an agent
+func (t *rateTracker) forget(agentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.samples, agentID)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"wameter/internal/server/remotewrite"
+	"wameter/internal/tracing"
+	"wameter/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// WriteRemoteMetrics stores the series of a Prometheus remote write request
+// as reports of the agents they belong to, returning the number of reports
+// stored. Agents are registered on their first report, reports of retired
+// agents are dropped.
+func (s *Service) WriteRemoteMetrics(ctx context.Context, series []remotewrite.TimeSeries) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "service.WriteRemoteMetrics", attribute.Int("series", len(series)))
+	defer func() { tracing.End(span, err) }()
+
+	cfg := s.GetConfig().RemoteWrite
+	if !cfg.Enabled {
+		return 0, types.ErrRemoteWriteDisabled
+	}
+
+	reports := remotewrite.NewConverter(cfg.AgentLabel, cfg.AgentPrefix, cfg.Metrics).Convert(series)
+
+	checked := make(map[string]bool)
+	retired := make(map[string]bool)
+	stored := 0
+	for _, data := range reports {
+		if !checked[data.AgentID] {
+			checked[data.AgentID] = true
+			if err := s.registerRemoteAgent(ctx, data); err != nil {
+				if !errors.Is(err, types.ErrAgentRetired) {
+					return stored, err
+				}
+				retired[data.AgentID] = true
+			}
+		}
+		if retired[data.AgentID] {
+			continue
+		}
+
+		data.ReportedAt = s.clock.Now()
+		if err := s.SaveMetrics(ctx, data); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+
+	for id := range retired {
+		s.logger.Debug("Dropping remote write samples of retired agent", zap.String("agent_id", id))
+	}
+
+	return stored, nil
+}
+
+// registerRemoteAgent registers the agent of a remote write report unless it
+// is known already
+func (s *Service) registerRemoteAgent(ctx context.Context, data *types.MetricsData) error {
+	s.agentsMu.RLock()
+	agent, ok := s.agents[data.AgentID]
+	s.agentsMu.RUnlock()
+
+	if ok {
+		if agent.Status == types.AgentStatusRetired {
+			return types.ErrAgentRetired
+		}
+		return nil
+	}
+
+	if err := s.RegisterAgent(ctx, &types.AgentInfo{
+		ID:       data.AgentID,
+		Hostname: data.Hostname,
+		Version:  data.Version,
+		Tags:     map[string]string{"source": remotewrite.Version},
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("Registered remote write agent",
+		zap.String("agent_id", data.AgentID),
+		zap.String("hostname", data.Hostname))
+
+	return nil
+}
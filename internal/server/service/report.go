@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"wameter/internal/server/config"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// maxReportAlerts is the number of alerts listed in a report
+const maxReportAlerts = 10
+
+// ReportService represents summary report service interface
+type ReportService interface {
+	GenerateReport(ctx context.Context, cfg *config.ReportConfig, end time.Time) (*types.Report, error)
+}
+
+// _ implements ReportService
+var _ ReportService = (*Service)(nil)
+
+// GenerateReport summarizes the period of the report ending at end from
+// stored metrics and IP changes. Availability is the share of offline
+// thresholds, since the period start or registration, an agent reported in.
+func (s *Service) GenerateReport(ctx context.Context, cfg *config.ReportConfig, end time.Time) (*types.Report, error) {
+	if cfg.Tenant != "" {
+		ctx = tenant.WithContext(ctx, cfg.Tenant)
+	}
+	start := end.Add(-cfg.Duration())
+
+	agents, err := s.agentRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	report := &types.Report{
+		Name:        cfg.Name,
+		Period:      cfg.Period,
+		StartTime:   start.UTC(),
+		EndTime:     end.UTC(),
+		GeneratedAt: s.clock.Now().UTC(),
+		Agents:      []*types.AgentReport{},
+		TopAlerts:   []*types.ReportAlert{},
+	}
+
+	agentReports := make(map[string]*types.AgentReport)
+	for _, agent := range agents {
+		if agent.Status == types.AgentStatusRetired || !agent.RegisteredAt.Before(end) || !cfg.Includes(agent.ID) {
+			continue
+		}
+		ar := &types.AgentReport{
+			AgentID:  agent.ID,
+			Hostname: agent.Hostname,
+			Status:   agent.Status,
+		}
+		agentReports[agent.ID] = ar
+		report.Agents = append(report.Agents, ar)
+	}
+	sort.Slice(report.Agents, func(i, j int) bool {
+		return report.Agents[i].AgentID < report.Agents[j].AgentID
+	})
+
+	var alerts []*types.ReportAlert
+
+	usage, err := s.metricsRepo.GetInterfaceUsage(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range usage {
+		ar, ok := agentReports[u.AgentID]
+		if !ok {
+			continue
+		}
+		ar.RxBytes += u.RxBytes
+		ar.TxBytes += u.TxBytes
+		if u.Errors > 0 {
+			alerts = append(alerts, &types.ReportAlert{
+				Type:      types.ReportAlertNetworkErrors,
+				AgentID:   u.AgentID,
+				Hostname:  ar.Hostname,
+				Interface: u.Interface,
+				Count:     int64(u.Errors),
+			})
+		}
+	}
+
+	changes, err := s.ipChangeRepo.CountChanges(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		ar, ok := agentReports[c.AgentID]
+		if !ok {
+			continue
+		}
+		ar.IPChanges += c.Count
+		alerts = append(alerts, &types.ReportAlert{
+			Type:      types.ReportAlertIPChange,
+			AgentID:   c.AgentID,
+			Hostname:  ar.Hostname,
+			Interface: c.Interface,
+			Count:     c.Count,
+		})
+	}
+
+	offline, err := s.reportAvailability(ctx, agents, agentReports, start, end)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, offline...)
+
+	// Most frequent first, ties in a stable order
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Interface < b.Interface
+	})
+	if len(alerts) > maxReportAlerts {
+		alerts = alerts[:maxReportAlerts]
+	}
+	report.TopAlerts = append(report.TopAlerts, alerts...)
+
+	report.Totals.Agents = len(report.Agents)
+	for _, ar := range report.Agents {
+		report.Totals.RxBytes += ar.RxBytes
+		report.Totals.TxBytes += ar.TxBytes
+		report.Totals.IPChanges += ar.IPChanges
+		report.Totals.Availability += ar.Availability
+	}
+	if len(report.Agents) > 0 {
+		report.Totals.Availability /= float64(len(report.Agents))
+	}
+
+	return report, nil
+}
+
+// reportAvailability sets the availability of the reported agents, with one
+// query per distinct offline threshold, and returns an offline alert for
+// each agent that missed a threshold
+func (s *Service) reportAvailability(ctx context.Context, agents []*types.AgentInfo, agentReports map[string]*types.AgentReport,
+	start, end time.Time) ([]*types.ReportAlert, error) {
+	monitor := s.agentMonitorConfig()
+
+	bySlot := make(map[time.Duration][]*types.AgentInfo)
+	for _, agent := range agents {
+		if _, ok := agentReports[agent.ID]; !ok {
+			continue
+		}
+		threshold, _ := monitor.Thresholds(agent.ID)
+		slot := threshold.Truncate(time.Second)
+		if slot < time.Second {
+			slot = time.Second
+		}
+		bySlot[slot] = append(bySlot[slot], agent)
+	}
+
+	var alerts []*types.ReportAlert
+	for slot, group := range bySlot {
+		reported, err := s.metricsRepo.CountReportingSlots(ctx, start, end, slot)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, agent := range group {
+			from := start
+			if agent.RegisteredAt.After(from) {
+				from = agent.RegisteredAt
+			}
+			expected := int64(math.Ceil(float64(end.Sub(from)) / float64(slot)))
+			if expected <= 0 {
+				continue
+			}
+
+			ar := agentReports[agent.ID]
+			ar.Availability = math.Min(100, float64(reported[agent.ID])*100/float64(expected))
+			if missed := expected - reported[agent.ID]; missed > 0 {
+				alerts = append(alerts, &types.ReportAlert{
+					Type:     types.ReportAlertAgentOffline,
+					AgentID:  agent.ID,
+					Hostname: agent.Hostname,
+					Count:    missed,
+				})
+			}
+		}
+	}
+
+	return alerts, nil
+}
+
+// sendReport generates a report for the period ending at end and sends it
+// through its notifiers
+func (s *Service) sendReport(ctx context.Context, cfg *config.ReportConfig, end time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	report, err := s.GenerateReport(ctx, cfg, end)
+	if err != nil {
+		s.logger.Error("Failed to generate report",
+			zap.Error(err),
+			zap.String("report", cfg.Name))
+		return
+	}
+
+	s.notifier.NotifyReport(report, cfg.Notifiers)
+
+	s.logger.Info("Report sent",
+		zap.String("report", cfg.Name),
+		zap.Int("agents", report.Totals.Agents),
+		zap.Time("start_time", report.StartTime),
+		zap.Time("end_time", report.EndTime))
+}
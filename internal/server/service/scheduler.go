@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"wameter/internal/cron"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// schedulerTick is how often the scheduler checks for due jobs, the finest
+// resolution of @every schedules
+const schedulerTick = time.Second
+
+// JobService represents job scheduler service interface
+type JobService interface {
+	GetJobs(ctx context.Context) []*types.JobStatus
+	RunJob(ctx context.Context, name string) (*types.JobStatus, error)
+}
+
+// _ implements JobService
+var _ JobService = (*Service)(nil)
+
+// job represents a periodic task run by the scheduler
+type job struct {
+	name string
+	// Only the leader runs it on schedule
+	leaderOnly bool
+	// Run when the scheduler starts rather than after the first interval
+	atStart bool
+	// Default schedule, from the settings of the task
+	schedule func() string
+	run      func(ctx context.Context) error
+
+	// State, guarded by the mutex of the scheduler
+	spec     string // Schedule next was computed from
+	next     time.Time
+	runID    uint64
+	cancels  map[uint64]context.CancelFunc // Of the runs in progress
+	last     *types.JobRun
+	runs     int64
+	failures int64
+	skipped  int64
+}
+
+// jobScheduler runs the periodic tasks of the server
+type jobScheduler struct {
+	jobs  []*job
+	byKey map[string]*job
+	mu    sync.Mutex
+}
+
+// newJobScheduler creates a scheduler without jobs
+func newJobScheduler() *jobScheduler {
+	return &jobScheduler{byKey: make(map[string]*job)}
+}
+
+// add registers a job
+func (js *jobScheduler) add(j *job) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j.cancels = make(map[uint64]context.CancelFunc)
+	js.jobs = append(js.jobs, j)
+	js.byKey[j.name] = j
+}
+
+// every returns the schedule of a fixed interval
+func every(interval time.Duration) string {
+	return "@every " + interval.String()
+}
+
+// startScheduler runs the jobs when they are due. Schedules follow
+// configuration reloads, cron expressions are read in the time zone of the
+// server.
+func (s *Service) startScheduler() {
+	ticker := s.clock.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	s.registerWorker("scheduler", schedulerTick)
+	defer s.unregisterWorker("scheduler")
+
+	s.scheduleJobs(s.clock.Now())
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.logger.Info("Job scheduler stopped")
+			return
+		case <-ticker.C:
+			s.beat("scheduler")
+			s.scheduleJobs(s.clock.Now())
+		}
+	}
+}
+
+// scheduleJobs starts the jobs due at now and computes their next run
+func (s *Service) scheduleJobs(now time.Time) {
+	cfg := s.GetConfig()
+	now = now.In(cfg.Server.Location())
+
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	for _, j := range s.jobs.jobs {
+		jc := cfg.Scheduler.Job(j.name)
+		if jc.Disabled {
+			j.spec, j.next = "", time.Time{}
+			continue
+		}
+
+		spec := s.jobSchedule(j, &jc)
+		if spec != j.spec {
+			schedule, err := cron.Parse(spec)
+			if err != nil {
+				s.logger.Error("Invalid job schedule", zap.String("job", j.name), zap.String("schedule", spec), zap.Error(err))
+				j.spec, j.next = spec, time.Time{}
+				continue
+			}
+			first := j.spec == "" && j.runs == 0 && j.atStart
+			j.spec, j.next = spec, schedule.Next(now)
+			if first {
+				j.next = now
+			}
+		}
+		if j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+
+		if schedule, err := cron.Parse(spec); err == nil {
+			j.next = schedule.Next(now)
+		}
+		if j.leaderOnly && !s.isLeader() {
+			continue
+		}
+		if err := s.startJob(j, &jc, types.JobTriggerSchedule); err != nil {
+			s.logger.Debug("Skipped job run", zap.String("job", j.name), zap.Error(err))
+		}
+	}
+}
+
+// jobSchedule returns the schedule of a job, the configured one or that of
+// its settings
+func (s *Service) jobSchedule(j *job, jc *config.JobConfig) string {
+	if jc.Schedule != "" {
+		return jc.Schedule
+	}
+	return j.schedule()
+}
+
+// startJob starts a run of a job by its overlap policy. Guarded by the mutex
+// of the scheduler.
+func (s *Service) startJob(j *job, jc *config.JobConfig, trigger types.JobTrigger) error {
+	if len(j.cancels) > 0 {
+		switch jc.Overlap {
+		case config.JobOverlapAllow:
+		case config.JobOverlapReplace:
+			for _, cancel := range j.cancels {
+				cancel()
+			}
+		default:
+			j.skipped++
+			return types.ErrJobRunning
+		}
+	}
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if jc.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, jc.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(s.ctx)
+	}
+	j.runID++
+	id := j.runID
+	j.cancels[id] = cancel
+
+	run := &types.JobRun{
+		Trigger:   trigger,
+		Status:    types.JobRunRunning,
+		StartedAt: s.clock.Now(),
+	}
+	j.last = run
+	j.runs++
+
+	s.goBackground(func() {
+		err := j.run(ctx)
+		s.finishJob(ctx, j, id, run, err)
+	})
+	return nil
+}
+
+// finishJob records the outcome of a run of a job
+func (s *Service) finishJob(ctx context.Context, j *job, id uint64, run *types.JobRun, err error) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	// Runs are recorded in place, a run replaced meanwhile is not the last run anymore
+	run.FinishedAt = s.clock.Now()
+	run.Duration = run.FinishedAt.Sub(run.StartedAt)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Status = types.JobRunTimedOut
+	case ctx.Err() != nil:
+		run.Status = types.JobRunCanceled
+	case err != nil:
+		run.Status = types.JobRunFailed
+	default:
+		run.Status = types.JobRunOK
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if cancel, ok := j.cancels[id]; ok {
+		cancel()
+		delete(j.cancels, id)
+	}
+
+	if run.Status == types.JobRunOK {
+		return
+	}
+	j.failures++
+	s.logger.Warn("Job run did not complete",
+		zap.String("job", j.name),
+		zap.String("status", string(run.Status)),
+		zap.Duration("duration", run.Duration),
+		zap.Error(err))
+}
+
+// GetJobs returns the state of the periodic tasks of the server
+func (s *Service) GetJobs(_ context.Context) []*types.JobStatus {
+	cfg := s.GetConfig()
+
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	statuses := make([]*types.JobStatus, 0, len(s.jobs.jobs))
+	for _, j := range s.jobs.jobs {
+		statuses = append(statuses, s.jobStatus(j, cfg))
+	}
+	return statuses
+}
+
+// RunJob starts a run of a job now, by its overlap policy. Jobs run on the
+// leader only are refused on other replicas.
+func (s *Service) RunJob(ctx context.Context, name string) (*types.JobStatus, error) {
+	cfg := s.GetConfig()
+
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	j, ok := s.jobs.byKey[name]
+	if !ok {
+		return nil, types.ErrJobNotFound
+	}
+	jc := cfg.Scheduler.Job(name)
+	if jc.Disabled {
+		return nil, types.ErrJobDisabled
+	}
+	if j.leaderOnly && !s.isLeader() {
+		return nil, types.ErrNotLeader
+	}
+	if err := s.startJob(j, &jc, types.JobTriggerManual); err != nil {
+		return nil, fmt.Errorf("failed to run job %s: %w", name, err)
+	}
+
+	s.log(ctx).Info("Job run started manually", zap.String("job", name))
+	return s.jobStatus(j, cfg), nil
+}
+
+// jobStatus returns the state of a job. Guarded by the mutex of the
+// scheduler.
+func (s *Service) jobStatus(j *job, cfg *config.Config) *types.JobStatus {
+	jc := cfg.Scheduler.Job(j.name)
+	status := &types.JobStatus{
+		Name:       j.name,
+		Schedule:   s.jobSchedule(j, &jc),
+		Enabled:    !jc.Disabled,
+		Timeout:    jc.Timeout,
+		Overlap:    jc.Overlap,
+		LeaderOnly: j.leaderOnly,
+		Running:    len(j.cancels),
+		Runs:       j.runs,
+		Failures:   j.failures,
+		Skipped:    j.skipped,
+	}
+	if !j.next.IsZero() {
+		next := j.next
+		status.NextRun = &next
+	}
+	if j.last != nil {
+		last := *j.last
+		status.LastRun = &last
+	}
+	return status
+}
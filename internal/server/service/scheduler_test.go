@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/clock"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// TestRunJob tests that a job run manually is skipped while a run is in
+// progress and refused once the job is disabled
+func TestRunJob(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	release := make(chan struct{})
+	svc.jobs.add(&job{
+		name:     "test",
+		schedule: func() string { return "@every 1h" },
+		run: func(ctx context.Context) error {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	})
+
+	status, err := svc.RunJob(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Running)
+	require.NotNil(t, status.LastRun)
+	assert.Equal(t, types.JobTriggerManual, status.LastRun.Trigger)
+	assert.Equal(t, types.JobRunRunning, status.LastRun.Status)
+
+	_, err = svc.RunJob(ctx, "test")
+	assert.ErrorIs(t, err, types.ErrJobRunning)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		for _, s := range svc.GetJobs(ctx) {
+			if s.Name == "test" {
+				return s.Running == 0 && s.LastRun.Status == types.JobRunOK && s.Runs == 1 && s.Skipped == 1
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = svc.RunJob(ctx, "unknown")
+	assert.ErrorIs(t, err, types.ErrJobNotFound)
+
+	svc.configMgr.mu.Lock()
+	cfg := *svc.configMgr.current
+	cfg.Scheduler.Jobs = map[string]config.JobConfig{"test": {Disabled: true}}
+	svc.configMgr.current = &cfg
+	svc.configMgr.mu.Unlock()
+
+	_, err = svc.RunJob(ctx, "test")
+	assert.ErrorIs(t, err, types.ErrJobDisabled)
+}
+
+// TestScheduleJobs tests that jobs run when their schedule is due
+func TestScheduleJobs(t *testing.T) {
+	fake := clock.NewFake(time.Now().Truncate(time.Second))
+	svc, _ := newTestService(t, WithClock(fake))
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	runs := make(chan struct{}, 10)
+	svc.jobs.add(&job{
+		name:     "test",
+		schedule: func() string { return "@every 1h" },
+		run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+
+	now := fake.Now()
+	svc.scheduleJobs(now)
+	svc.scheduleJobs(now.Add(30 * time.Minute))
+	assert.Empty(t, runs)
+
+	svc.scheduleJobs(now.Add(time.Hour))
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run when due")
+	}
+
+	for _, s := range svc.GetJobs(ctx) {
+		if s.Name == "test" {
+			require.NotNil(t, s.NextRun)
+			assert.WithinDuration(t, now.Add(2*time.Hour), *s.NextRun, time.Second)
+		}
+	}
+}
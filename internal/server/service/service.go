@@ -2,12 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
+	"wameter/internal/clock"
 	"wameter/internal/database"
+	"wameter/internal/ipinfo"
+	"wameter/internal/server/agentstate"
+	"wameter/internal/server/cache"
 	"wameter/internal/server/config"
 	"wameter/internal/server/data/repository"
+	"wameter/internal/server/discovery"
+	"wameter/internal/server/forward"
+	"wameter/internal/server/ingest"
 	"wameter/internal/server/notify"
+	"wameter/internal/server/privacy"
+	"wameter/internal/server/requestid"
 	"wameter/internal/types"
 
 	"go.uber.org/zap"
@@ -16,6 +29,7 @@ import (
 // Service represents the server service
 type Service struct {
 	startTime time.Time
+	clock     clock.Clock // Time of time-based behaviour, replaced by tests
 	// Core components
 	config     *config.Config
 	logger     *zap.Logger
@@ -23,17 +37,68 @@ type Service struct {
 	db         database.Interface
 
 	// Repositories
-	agentRepo    repository.AgentRepository
-	metricsRepo  repository.MetricsRepository
-	ipChangeRepo repository.IPChangeRepository
+	agentRepo        repository.AgentRepository
+	metricsRepo      repository.MetricsRepository
+	ipChangeRepo     repository.IPChangeRepository
+	auditRepo        repository.AuditRepository
+	tenantRepo       repository.TenantRepository
+	userRepo         repository.UserRepository
+	leaseRepo        repository.LeaseRepository
+	decommissionRepo repository.DecommissionRepository
+	diagnosticsRepo  repository.DiagnosticsRepository
+	agentLogRepo     repository.AgentLogRepository
+	inventoryRepo    repository.InventoryRepository
+	annotationRepo   repository.AnnotationRepository
+	erasureRepo      repository.ErasureRepository
+	ipWindowRepo     repository.IPChangeWindowRepository
+	alertRuleRepo    repository.AlertRuleRepository
 
 	// Support services
 	configMgr *configManager
 	notifier  *notify.Manager
+	ipInfo    *ipinfo.Resolver
+	ingest    *ingest.Queue
+	forwarder *forward.Forwarder
 
-	// Command management
+	// Agent status shared among replicas, nil keeps it in memory only
+	agentState agentstate.Store
+
+	// Cache of latest metrics and summaries, nil when disabled
+	cache cache.Store
+
+	// Redaction of IP addresses before storage and notifications
+	privacy *privacy.Redactor
+
+	// Interface rates computed from consecutive reports
+	rates *rateTracker
+
+	// Network state the next delta report of an agent applies to
+	deltas *deltaTracker
+
+	// Agent pods watched in Kubernetes, nil when discovery is disabled
+	discovery      *discovery.Kubernetes
+	discoveredPods []discovery.Pod
+	podsSynced     bool
+	podsGone       map[string]time.Time // Agent ID -> first seen without pod
+	discoveryMu    sync.Mutex
+
+	// Expected agents and counts, alerted when missing
+	inventory *inventoryTracker
+
+	// Last expected IP change windows declared through the API, kept when
+	// they cannot be read
+	ipWindows   []types.IPChangeWindow
+	ipWindowsMu sync.Mutex
+	// IP change baselines of agents, scoring changes notified only when anomalous
+	ipBaselines *ipBaselineTracker
+	// Context lookups of new external IPs in progress
+	ipContexts *ipContextTracker
+
+	// Command management, client sends commands to agents
+	client   *http.Client
 	commands map[string]*commandTracker
 	history  map[string][]types.CommandHistory
+	pulls    map[string][]types.QueuedCommand // Commands awaiting agents polling them, guarded by commandsMu
 
 	// State management
 	stats struct {
@@ -43,78 +108,252 @@ type Service struct {
 		errorCount       int64
 		lastError        string
 		lastErrorTime    time.Time
+		commands         types.CommandStats
 	}
-	statsMu    sync.RWMutex
-	agents     map[string]*types.AgentInfo
-	agentsMu   sync.RWMutex
-	commandsMu sync.RWMutex
+	statsMu  sync.RWMutex
+	agents   map[string]*types.AgentInfo
+	agentsMu sync.RWMutex
+	// Consecutive offline checks missed by agents, guarded by agentsMu
+	missedChecks map[string]int
+	commandsMu   sync.RWMutex
+	// Erased agents, their reports up to the erasure are rejected
+	tombstones   map[string]tombstone
+	tombstonesMu sync.RWMutex
+	// When an agent was last removed from agents, guarded by agentsMu
+	agentsRemovedAt time.Time
+
+	// Stored reports awaiting alert evaluation, and whether a pass runs
+	alertQueue []*types.MetricsData
+	alerting   bool
+	alertsMu   sync.Mutex
+	// Interface and rule alerts firing, to annotate when they start
+	firing   map[string]bool
+	firingMu sync.Mutex
+	// Alert rules compiled from the current configuration
+	alertRules alertRuleSet
+	// Learned baselines of interface metrics
+	anomalies *anomalyDetector
+	// Error counters of interfaces at their last alerts
+	netErrors *networkErrorTracker
+
+	// Reports per hour and storage of agents, limited by their quotas
+	quotas *quotaTracker
+
+	// Leadership of scheduled jobs among replicas
+	nodeID      string
+	leaderUntil atomic.Int64
+
+	// Readiness and background worker liveness
+	ready     atomic.Bool
+	workers   map[string]*workerHeartbeat
+	workersMu sync.RWMutex
+
+	// Periodic tasks, run by their schedules
+	jobs *jobScheduler
+
+	// Last prune requested through the API, guarded by pruneMu
+	lastPrune *types.Prune
+	pruneMu   sync.Mutex
+
+	// Background goroutines awaited on shutdown, stopping and wg.Add are guarded by wgMu
+	wg       sync.WaitGroup
+	wgMu     sync.Mutex
+	stopping bool
 
 	// Context management
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
+// Option configures a service
+type Option func(*Service)
+
+// WithClock makes a service tell the time with c, e.g. a fake clock tests
+// advance
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) {
+		s.clock = c
+	}
+}
+
 // NewService creates new service instance
-func NewService(cfg *config.Config, db database.Interface, logger *zap.Logger) (*Service, error) {
+func NewService(cfg *config.Config, db database.Interface, logger *zap.Logger, opts ...Option) (*Service, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	svc := &Service{
-		startTime: time.Now(),
-		config:    cfg,
-		logger:    logger,
-		db:        db,
-		agents:    make(map[string]*types.AgentInfo),
-		commands:  make(map[string]*commandTracker),
-		history:   make(map[string][]types.CommandHistory),
-		ctx:       ctx,
-		cancel:    cancel,
+		clock:        clock.Real,
+		config:       cfg,
+		logger:       logger,
+		db:           db,
+		agents:       make(map[string]*types.AgentInfo),
+		missedChecks: make(map[string]int),
+		tombstones:   make(map[string]tombstone),
+		firing:       make(map[string]bool),
+		commands:     make(map[string]*commandTracker),
+		history:      make(map[string][]types.CommandHistory),
+		pulls:        make(map[string][]types.QueuedCommand),
+		workers:      make(map[string]*workerHeartbeat),
+		jobs:         newJobScheduler(),
+		configMgr:    NewConfigManager(cfg, logger),
+		privacy:      privacy.New(&cfg.Privacy),
+		rates:        newRateTracker(),
+		deltas:       newDeltaTracker(),
+		anomalies:    newAnomalyDetector(),
+		netErrors:    newNetworkErrorTracker(),
+		quotas:       newQuotaTracker(),
+		podsGone:     make(map[string]time.Time),
+		inventory:    newInventoryTracker(),
+		ipBaselines:  newIPBaselineTracker(),
+		ipContexts:   newIPContextTracker(),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	for _, opt := range opts {
+		opt(svc)
 	}
+	svc.startTime = svc.clock.Now()
+
+	// Initialize the client of agent commands
+	client, err := cfg.HTTP.Client(nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize HTTP client: %w", err)
+	}
+	svc.client = client
 
 	// Initialize repositories
 	svc.initializeRepositories()
 
+	svc.nodeID = cfg.Cluster.NodeID
+	if svc.nodeID == "" {
+		svc.nodeID = defaultNodeID()
+	}
+
 	// Initialize notifications
 	svc.initializeNotifications()
 
+	// Initialize shared agent state
+	if cfg.Redis.Enabled {
+		state, err := agentstate.NewRedis(&cfg.Redis)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to initialize agent state: %w", err)
+		}
+		svc.agentState = state
+	}
+
+	// Initialize the metrics cache
+	if cfg.Cache.Enabled {
+		store, err := cache.New(&cfg.Cache, &cfg.Redis)
+		if err != nil {
+			cancel()
+			if svc.agentState != nil {
+				_ = svc.agentState.Close()
+			}
+			return nil, fmt.Errorf("failed to initialize metrics cache: %w", err)
+		}
+		svc.cache = store
+	}
+
+	// Initialize the metrics ingest queue
+	if cfg.Ingest.Enabled {
+		queue, err := ingest.NewQueue(&cfg.Ingest, svc.storeMetrics, logger)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to initialize ingest queue: %w", err)
+		}
+		svc.ingest = queue
+	}
+
+	// Initialize forwarding to Graphite or StatsD
+	if cfg.Forward.Enabled {
+		svc.forwarder = forward.New(&cfg.Forward, logger)
+	}
+
+	// Initialize IP context lookups
+	if cfg.IPInfo != nil && cfg.IPInfo.Enabled {
+		svc.ipInfo = ipinfo.NewResolver(cfg.IPInfo, logger)
+	}
+
+	// Initialize the discovery of agent pods
+	if cfg.Discovery.Kubernetes.Enabled {
+		k, err := discovery.NewKubernetes(&cfg.Discovery.Kubernetes, logger)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to initialize kubernetes discovery: %w", err)
+		}
+		svc.discovery = k
+	}
+
 	// Load existing agents
 	svc.loadAgents()
 
+	// Load erased agents and resume interrupted erasures
+	svc.loadErasures()
+
 	// Start background tasks
 	svc.startBackgroundTasks()
 
 	return svc, nil
 }
 
-// Stop stops all service components
-func (s *Service) Stop() error {
+// Stop stops all service components and waits for background work to finish,
+// the database is owned by the caller and is left open
+func (s *Service) Stop(ctx context.Context) error {
+	// Stop accepting traffic before tearing down components
+	s.SetReady(false)
+
+	// Write queued metrics while post-processing can still run
+	if s.ingest != nil {
+		if err := s.ingest.Stop(ctx); err != nil {
+			s.logger.Error("Failed to drain ingest queue", zap.Error(err))
+		}
+	}
+
+	// Send the metrics of the last reports
+	if s.forwarder != nil {
+		if err := s.forwarder.Stop(ctx); err != nil {
+			s.logger.Error("Failed to stop metrics forwarder", zap.Error(err))
+		}
+	}
+
 	// Cancel context first to stop all operations
 	s.cancel()
 
-	// Create channel for shutdown completion
+	// No background work starts once stopping, so Wait does not race with Add
+	s.wgMu.Lock()
+	s.stopping = true
+	s.wgMu.Unlock()
+
+	// Wait for background tasks so no notifications are queued after the notifier stops
 	done := make(chan struct{})
 	go func() {
-		// Stop notification manager
-		if s.notifier != nil {
-			if err := s.notifier.Stop(); err != nil {
-				s.logger.Error("Failed to stop notifier", zap.Error(err))
-			}
-		}
-		// Close database connection
-		if s.db != nil {
-			if err := s.db.Close(); err != nil {
-				s.logger.Error("Failed to close database", zap.Error(err))
-			}
-		}
-
+		s.wg.Wait()
 		close(done)
 	}()
 
-	// Wait for shutdown with timeout
 	select {
 	case <-done:
-		s.logger.Info("All cleanup tasks completed")
-	case <-s.ctx.Done():
-		s.logger.Warn("Cleanup tasks timed out")
+		s.logger.Info("Background tasks stopped")
+	case <-ctx.Done():
+		return fmt.Errorf("timeout waiting for background tasks: %w", ctx.Err())
+	}
+
+	// Flush pending notifications
+	if err := s.notifier.Stop(); err != nil {
+		return fmt.Errorf("failed to stop notifier: %w", err)
+	}
+
+	if s.agentState != nil {
+		if err := s.agentState.Close(); err != nil {
+			return fmt.Errorf("failed to close agent state: %w", err)
+		}
+	}
+
+	if s.cache != nil {
+		if err := s.cache.Close(); err != nil {
+			return fmt.Errorf("failed to close metrics cache: %w", err)
+		}
 	}
 
 	return nil
@@ -128,48 +367,114 @@ func (s *Service) initializeRepositories() {
 	s.metricsRepo = repository.NewMetricsRepository(s.db, s.logger)
 	// Agent IP changes
 	s.ipChangeRepo = repository.NewIPChangeRepository(s.db, s.logger)
+	// Audit log
+	s.auditRepo = repository.NewAuditRepository(s.db, s.logger)
+	// Tenants and API keys
+	s.tenantRepo = repository.NewTenantRepository(s.db, s.logger)
+	// Users and their roles
+	s.userRepo = repository.NewUserRepository(s.db, s.logger)
+	// Leader leases
+	s.leaseRepo = repository.NewLeaseRepository(s.db, s.clock, s.logger)
+	// Agent decommissions
+	s.decommissionRepo = repository.NewDecommissionRepository(s.db, s.logger)
+	// Agent diagnostics bundles
+	s.diagnosticsRepo = repository.NewDiagnosticsRepository(s.db, s.logger)
+
+	// Logs shipped by agents
+	s.agentLogRepo = repository.NewAgentLogRepository(s.db, s.logger)
+	// Expected agents declared through the API
+	s.inventoryRepo = repository.NewInventoryRepository(s.db, s.logger)
+	// Events overlaid on dashboards
+	s.annotationRepo = repository.NewAnnotationRepository(s.db, s.logger)
+
+	// Initialize erasure repository
+	s.erasureRepo = repository.NewErasureRepository(s.db, s.logger)
+	// Expected IP change windows declared through the API
+	s.ipWindowRepo = repository.NewIPChangeWindowRepository(s.db, s.logger)
+	// Alert rules declared through the API
+	s.alertRuleRepo = repository.NewAlertRuleRepository(s.db, s.logger)
 }
 
 // initializeNotifications initializes notifications
 func (s *Service) initializeNotifications() {
-	// Initialize notification manager
-	if s.config.Notify.Enabled {
-		notifier, err := notify.NewManager(s.config.Notify, s.logger)
-		if err != nil {
-			s.cancel()
-			s.logger.Fatal("Failed to initialize notification manager", zap.Error(err))
-		}
-		s.notifier = notifier
+	// Initialize notification manager, it stays in place while disabled so it can be reloaded
+	notifier, err := notify.NewManager(s.config.Notify, s.logger)
+	if err != nil {
+		s.cancel()
+		s.logger.Fatal("Failed to initialize notification manager", zap.Error(err))
 	}
+	// Email routes match agent tags of interface alerts, which carry only the agent ID
+	notifier.SetAgentTags(s.agentTags)
+	// Telegram alert buttons acknowledge alerts and silence agents
+	notifier.SetTelegramActions(s)
+	notifier.SetClock(s.clock)
+	s.notifier = notifier
 }
 
 // startBackgroundTasks starts all background tasks
 func (s *Service) startBackgroundTasks() {
-	// Start agent monitoring
-	go s.startAgentMonitoring()
-	// Start cleanup task
-	go s.startCleanupTask()
+	// Start leader election, scheduled jobs below only run on the leader
+	if s.config.Cluster.Enabled {
+		s.goBackground(s.startLeaderElection)
+	}
+	// Start periodic tasks
+	s.registerJobs()
+	s.goBackground(s.startScheduler)
+	// Start agent pod discovery
+	if s.discovery != nil {
+		s.goBackground(s.startDiscovery)
+	}
+	// Start forwarding before ingest workers process reports
+	if s.forwarder != nil {
+		s.forwarder.Start()
+	}
+	// Start ingest workers
+	if s.ingest != nil {
+		s.ingest.Start()
+	}
 
 	// Add other background tasks as needed
 }
 
-// startCleanupTask starts the cleanup task
-func (s *Service) startCleanupTask() {
-	ticker := time.NewTicker(s.config.Database.PruneInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			s.logger.Info("Cleanup task stopped")
-			return
-		case <-ticker.C:
-			cutoff := time.Now().Add(-s.config.Database.MetricsRetention)
-			if err := s.db.Cleanup(context.Background(), cutoff); err != nil {
-				s.logger.Error("Failed to cleanup old metrics", zap.Error(err))
-			}
-		}
+// log returns the logger of a call, with the request ID of ctx when it
+// serves an API request
+func (s *Service) log(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, s.logger)
+}
+
+// goBackground runs fn in a goroutine awaited by Stop, it is a no-op once
+// stopping. It reports whether fn was started.
+func (s *Service) goBackground(fn func()) bool {
+	s.wgMu.Lock()
+	defer s.wgMu.Unlock()
+	if s.stopping {
+		return false
 	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// cleanup deletes the data past its retention and purges retired agents
+func (s *Service) cleanup(ctx context.Context) error {
+	now := s.clock.Now()
+	var errs []error
+	if err := s.db.Cleanup(ctx, now.Add(-s.config.Database.MetricsRetention)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to cleanup old metrics: %w", err))
+	}
+	if err := s.agentLogRepo.DeleteBefore(ctx, now.Add(-s.config.Database.AgentLogRetention)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to cleanup old agent logs: %w", err))
+	}
+	if err := s.annotationRepo.DeleteBefore(ctx, now.Add(-s.config.Database.AnnotationRetention)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to cleanup old annotations: %w", err))
+	}
+	if err := s.purgeRetiredAgents(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 // recordMetric records service metrics
@@ -191,3 +496,13 @@ func (s *Service) recordMetric(fn func(*types.ServiceMetrics)) {
 	s.stats.notifications = metrics.Notifications
 	s.stats.errorCount = metrics.ErrorCount
 }
+
+// recordError counts a failed operation and keeps it as the last error
+func (s *Service) recordError(err error) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	s.stats.errorCount++
+	s.stats.lastError = err.Error()
+	s.stats.lastErrorTime = s.clock.Now()
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wameter/internal/clock"
+	"wameter/internal/types"
+)
+
+// TestCleanupRetention tests that the cleanup deletes the metrics past the
+// metrics retention as time passes
+func TestCleanupRetention(t *testing.T) {
+	testCases := []struct {
+		name    string
+		ages    []time.Duration // Of the reports when the clock is advanced
+		advance time.Duration
+		remain  int
+	}{
+		{
+			name:    "Within retention",
+			ages:    []time.Duration{0, 24 * time.Hour},
+			advance: 28 * 24 * time.Hour,
+			remain:  2,
+		},
+		{
+			name:    "Partly past retention",
+			ages:    []time.Duration{0, 24 * time.Hour, 5 * 24 * time.Hour},
+			advance: 28 * 24 * time.Hour,
+			remain:  2,
+		},
+		{
+			name:    "All past retention",
+			ages:    []time.Duration{0, 24 * time.Hour},
+			advance: 31 * 24 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Now().Truncate(time.Second))
+			svc, _ := newTestService(t, WithClock(fake))
+			ctx := context.Background()
+
+			for _, age := range tc.ages {
+				require.NoError(t, svc.SaveMetrics(ctx, testReport(fake.Now().Add(-age), 0, 0)))
+			}
+
+			fake.Advance(tc.advance)
+			require.NoError(t, svc.cleanup(ctx))
+
+			var remain int
+			require.NoError(t, svc.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics").Scan(&remain))
+			assert.Equal(t, tc.remain, remain)
+			require.NoError(t, svc.Stop(ctx))
+		})
+	}
+}
+
+// TestPrune tests that a dry run counts the metrics older than the cutoff
+// and a prune deletes them in the background
+func TestPrune(t *testing.T) {
+	fake := clock.NewFake(time.Now().Truncate(time.Second))
+	svc, _ := newTestService(t, WithClock(fake))
+	ctx := context.Background()
+	t.Cleanup(func() { _ = svc.Stop(ctx) })
+
+	for _, age := range []time.Duration{time.Hour, 2 * time.Hour, 48 * time.Hour} {
+		require.NoError(t, svc.SaveMetrics(ctx, testReport(fake.Now().Add(-age), 0, 0)))
+	}
+
+	_, err := svc.GetPrune(ctx)
+	assert.ErrorIs(t, err, types.ErrPruneNotFound)
+	_, err = svc.Prune(ctx, &types.PruneRequest{Before: fake.Now().Add(time.Hour)})
+	assert.ErrorIs(t, err, types.ErrInvalidPrune)
+
+	before := fake.Now().Add(-90 * time.Minute)
+	p, err := svc.Prune(ctx, &types.PruneRequest{Before: before, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, types.PruneCompleted, p.Status)
+	assert.Equal(t, int64(2), p.Rows)
+	assert.Zero(t, p.Deleted)
+
+	p, err = svc.Prune(ctx, &types.PruneRequest{Before: before})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), p.Rows)
+	require.Eventually(t, func() bool {
+		p, err := svc.GetPrune(ctx)
+		return err == nil && p.Status == types.PruneCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	p, err = svc.GetPrune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), p.Deleted)
+
+	var remain int
+	require.NoError(t, svc.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics").Scan(&remain))
+	assert.Equal(t, 1, remain)
+}
+
+// TestEraseData tests that an erasure deletes all data of the agents of an
+// agent ID or hostname, leaves other agents alone and rejects their stale
+// reports afterwards
+func TestEraseData(t *testing.T) {
+	testCases := []struct {
+		name   string
+		req    types.ErasureRequest
+		erased []string
+		kept   []string
+	}{
+		{
+			name:   "By agent ID",
+			req:    types.ErasureRequest{AgentID: "agent-1"},
+			erased: []string{"agent-1"},
+			kept:   []string{"agent-2", "agent-3"},
+		},
+		{
+			name:   "By hostname",
+			req:    types.ErasureRequest{Hostname: "host-1"},
+			erased: []string{"agent-1", "agent-2"},
+			kept:   []string{"agent-3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Now().Truncate(time.Second))
+			svc, _ := newTestService(t, WithClock(fake))
+			ctx := context.Background()
+
+			for _, agent := range []*types.AgentInfo{
+				{ID: "agent-2", Hostname: "host-1"},
+				{ID: "agent-3", Hostname: "host-3"},
+			} {
+				require.NoError(t, svc.RegisterAgent(ctx, agent))
+			}
+			reports := make(map[string]*types.MetricsData)
+			for _, id := range []string{"agent-1", "agent-2", "agent-3"} {
+				for i := range 3 {
+					report := testReport(fake.Now().Add(-time.Duration(i)*time.Minute), 0, 0)
+					report.AgentID = id
+					require.NoError(t, svc.SaveMetrics(ctx, report))
+					reports[id] = report
+				}
+			}
+
+			e, err := svc.EraseData(ctx, &tc.req)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.erased, e.AgentIDs)
+
+			require.Eventually(t, func() bool {
+				e, err = svc.GetErasure(ctx, e.ID)
+				return err == nil && e.Status != types.ErasurePending
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Equal(t, types.ErasureCompleted, e.Status, e.Error)
+			require.NotNil(t, e.Report)
+			assert.True(t, e.Report.Verified)
+			assert.Equal(t, int64(3*len(tc.erased)), e.Report.Deleted["metrics"])
+			assert.Equal(t, int64(len(tc.erased)), e.Report.Deleted["agents"])
+
+			for _, id := range tc.erased {
+				var count int
+				require.NoError(t, svc.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics WHERE agent_id = ?", id).Scan(&count))
+				assert.Zero(t, count, id)
+				_, err := svc.GetAgent(ctx, id)
+				assert.ErrorIs(t, err, types.ErrAgentNotFound, id)
+				assert.ErrorIs(t, svc.SaveMetrics(ctx, reports[id]), types.ErrAgentErased, id)
+			}
+			for _, id := range tc.kept {
+				var count int
+				require.NoError(t, svc.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metrics WHERE agent_id = ?", id).Scan(&count))
+				assert.Equal(t, 3, count, id)
+			}
+			require.NoError(t, svc.Stop(ctx))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// TenantService represents tenant and API key management service interface
+type TenantService interface {
+	CreateTenant(ctx context.Context, t *types.Tenant) error
+	GetTenants(ctx context.Context) ([]*types.Tenant, error)
+	DeleteTenant(ctx context.Context, id string) error
+	GetTenantUsage(ctx context.Context) ([]*types.TenantUsage, error)
+	CreateAPIKey(ctx context.Context, tenantID, name string) (*types.APIKey, error)
+	GetAPIKeys(ctx context.Context, tenantID string) ([]*types.APIKey, error)
+	DeleteAPIKey(ctx context.Context, tenantID, id string) error
+	Authenticate(ctx context.Context, key string) (*types.Principal, error)
+}
+
+// _ implements TenantService
+var _ TenantService = (*Service)(nil)
+
+// apiKeyPrefix marks generated API keys
+const apiKeyPrefix = "wmk_"
+
+// slugPattern restricts tenant and user IDs to lowercase slugs
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// CreateTenant creates a new tenant
+func (s *Service) CreateTenant(ctx context.Context, t *types.Tenant) error {
+	if !slugPattern.MatchString(t.ID) {
+		return fmt.Errorf("invalid tenant id: must be a lowercase slug of at most 63 characters")
+	}
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	t.CreatedAt = s.clock.Now()
+
+	if err := s.tenantRepo.Save(ctx, t); err != nil {
+		return err
+	}
+
+	s.log(ctx).Info("Tenant created", zap.String("tenant_id", t.ID))
+	return nil
+}
+
+// GetTenants returns all tenants
+func (s *Service) GetTenants(ctx context.Context) ([]*types.Tenant, error) {
+	return s.tenantRepo.List(ctx)
+}
+
+// DeleteTenant deletes a tenant without agents along with its API keys
+func (s *Service) DeleteTenant(ctx context.Context, id string) error {
+	if id == tenant.Default {
+		return fmt.Errorf("default tenant cannot be deleted")
+	}
+
+	if err := s.tenantRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.log(ctx).Info("Tenant deleted", zap.String("tenant_id", id))
+	return nil
+}
+
+// GetTenantUsage returns the resources held by each tenant
+func (s *Service) GetTenantUsage(ctx context.Context) ([]*types.TenantUsage, error) {
+	return s.tenantRepo.Usage(ctx)
+}
+
+// CreateAPIKey creates an API key bound to a tenant, the returned key is the
+// only copy of the secret
+func (s *Service) CreateAPIKey(ctx context.Context, tenantID, name string) (*types.APIKey, error) {
+	if _, err := s.tenantRepo.FindByID(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	return s.issueAPIKey(ctx, tenantID, "", name)
+}
+
+// issueAPIKey generates and stores an API key of a tenant and optionally a user
+func (s *Service) issueAPIKey(ctx context.Context, tenantID, userID, name string) (*types.APIKey, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &types.APIKey{
+		ID:        id,
+		TenantID:  tenantID,
+		UserID:    userID,
+		Name:      name,
+		Key:       apiKeyPrefix + secret,
+		CreatedAt: s.clock.Now(),
+	}
+	if key.Name == "" {
+		key.Name = id
+	}
+
+	if err := s.tenantRepo.SaveKey(ctx, key, hashAPIKey(key.Key)); err != nil {
+		return nil, err
+	}
+
+	s.log(ctx).Info("API key created",
+		zap.String("tenant_id", tenantID),
+		zap.String("user_id", userID),
+		zap.String("key_id", id))
+
+	return key, nil
+}
+
+// GetAPIKeys returns the API keys of a tenant without their secrets
+func (s *Service) GetAPIKeys(ctx context.Context, tenantID string) ([]*types.APIKey, error) {
+	if _, err := s.tenantRepo.FindByID(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	return s.tenantRepo.ListKeys(ctx, tenantID)
+}
+
+// DeleteAPIKey revokes an API key of a tenant
+func (s *Service) DeleteAPIKey(ctx context.Context, tenantID, id string) error {
+	if err := s.tenantRepo.DeleteKey(ctx, tenantID, id); err != nil {
+		return err
+	}
+
+	s.log(ctx).Info("API key revoked",
+		zap.String("tenant_id", tenantID),
+		zap.String("key_id", id))
+
+	return nil
+}
+
+// Authenticate resolves an API key to the principal it acts as. Keys issued
+// to a user carry the role of the user, other keys administer their tenant.
+func (s *Service) Authenticate(ctx context.Context, key string) (*types.Principal, error) {
+	if key == "" {
+		return nil, types.ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.tenantRepo.FindKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidAPIKey) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to authenticate api key: %w", err)
+	}
+
+	principal := &types.Principal{
+		Actor:    "key:" + apiKey.ID,
+		TenantID: apiKey.TenantID,
+		Role:     types.RoleAdmin,
+	}
+	if apiKey.UserID != "" {
+		user, err := s.userRepo.FindByID(ctx, apiKey.UserID)
+		if err != nil {
+			if errors.Is(err, types.ErrUserNotFound) {
+				return nil, types.ErrInvalidAPIKey
+			}
+			return nil, fmt.Errorf("failed to authenticate api key: %w", err)
+		}
+		principal.Actor = "user:" + user.ID
+		principal.Role = user.Role
+		principal.Agents = user.Agents
+	}
+
+	return principal, nil
+}
+
+// hashAPIKey returns the stored form of an API key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+
+	"go.uber.org/zap"
+)
+
+// UserService represents user and role management service interface
+type UserService interface {
+	CreateUser(ctx context.Context, user *types.User) error
+	GetUsers(ctx context.Context, tenantID string) ([]*types.User, error)
+	GetUser(ctx context.Context, id string) (*types.User, error)
+	UpdateUser(ctx context.Context, user *types.User) error
+	DeleteUser(ctx context.Context, id string) error
+	CreateUserAPIKey(ctx context.Context, userID, name string) (*types.APIKey, error)
+	GetUserAPIKeys(ctx context.Context, userID string) ([]*types.APIKey, error)
+}
+
+// _ implements UserService
+var _ UserService = (*Service)(nil)
+
+// CreateUser creates a new user, users without a tenant join the default tenant
+func (s *Service) CreateUser(ctx context.Context, user *types.User) error {
+	if !slugPattern.MatchString(user.ID) {
+		return fmt.Errorf("invalid user id: must be a lowercase slug of at most 63 characters")
+	}
+	if user.TenantID == "" {
+		user.TenantID = tenant.Default
+	}
+	if user.Name == "" {
+		user.Name = user.ID
+	}
+	if err := validateUser(user); err != nil {
+		return err
+	}
+
+	if _, err := s.tenantRepo.FindByID(ctx, user.TenantID); err != nil {
+		return err
+	}
+
+	user.CreatedAt = s.clock.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	if err := s.userRepo.Save(ctx, user); err != nil {
+		return err
+	}
+
+	s.logger.Info("User created",
+		zap.String("user_id", user.ID),
+		zap.String("tenant_id", user.TenantID),
+		zap.String("role", string(user.Role)))
+
+	return nil
+}
+
+// GetUsers returns the users of a tenant, or of all tenants if tenantID is empty
+func (s *Service) GetUsers(ctx context.Context, tenantID string) ([]*types.User, error) {
+	return s.userRepo.List(ctx, tenantID)
+}
+
+// GetUser returns user by ID
+func (s *Service) GetUser(ctx context.Context, id string) (*types.User, error) {
+	return s.userRepo.FindByID(ctx, id)
+}
+
+// UpdateUser updates the name, role and agents of a user, changes apply to
+// the next request made with its API keys
+func (s *Service) UpdateUser(ctx context.Context, user *types.User) error {
+	if err := validateUser(user); err != nil {
+		return err
+	}
+
+	user.UpdatedAt = s.clock.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	s.logger.Info("User updated",
+		zap.String("user_id", user.ID),
+		zap.String("role", string(user.Role)))
+
+	return nil
+}
+
+// DeleteUser deletes a user and revokes its API keys
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("User deleted", zap.String("user_id", id))
+	return nil
+}
+
+// CreateUserAPIKey creates an API key acting as a user
+func (s *Service) CreateUserAPIKey(ctx context.Context, userID, name string) (*types.APIKey, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueAPIKey(ctx, user.TenantID, user.ID, name)
+}
+
+// GetUserAPIKeys returns the API keys of a user without their secrets
+func (s *Service) GetUserAPIKeys(ctx context.Context, userID string) ([]*types.APIKey, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.userRepo.ListKeys(ctx, userID)
+}
+
+// validateUser validates the role and agents of a user
+func validateUser(user *types.User) error {
+	if !user.Role.Valid() {
+		return fmt.Errorf("invalid role %q: must be one of viewer, operator, admin", user.Role)
+	}
+
+	if user.Agents == nil {
+		user.Agents = []string{}
+	}
+	for _, id := range user.Agents {
+		if id == "" {
+			return fmt.Errorf("invalid agents: agent id is empty")
+		}
+	}
+	slices.Sort(user.Agents)
+	user.Agents = slices.Compact(user.Agents)
+
+	return nil
+}
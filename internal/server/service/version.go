@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+	"wameter/internal/server/tenant"
+	"wameter/internal/types"
+)
+
+// AgentsVersion returns a version of the agents visible to ctx listed by
+// filter and when they last changed, from the agents held in memory, so
+// unchanged agents are answered without querying them. Replicas of a cluster
+// refresh their agents periodically only, they report no version.
+func (s *Service) AgentsVersion(ctx context.Context, filter *types.AgentFilter) (string, time.Time, bool) {
+	if s.config.Cluster.Enabled {
+		return "", time.Time{}, false
+	}
+
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	ids := make([]string, 0, len(s.agents))
+	for id, agent := range s.agents {
+		if tenant.Allows(ctx, agent.TenantID) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	if err := enc.Encode(filter); err != nil {
+		return "", time.Time{}, false
+	}
+	modified := s.agentsRemovedAt
+	for _, id := range ids {
+		agent := s.agents[id]
+		if err := enc.Encode(agent); err != nil {
+			return "", time.Time{}, false
+		}
+		modified = latest(modified, AgentModified(agent))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), modified, true
+}
+
+// AgentVersion returns a version of an agent and when it last changed, like
+// AgentsVersion. Unknown agents report no version.
+func (s *Service) AgentVersion(ctx context.Context, agentID string) (string, time.Time, bool) {
+	if s.config.Cluster.Enabled {
+		return "", time.Time{}, false
+	}
+
+	s.agentsMu.RLock()
+	defer s.agentsMu.RUnlock()
+
+	agent, ok := s.agents[agentID]
+	if !ok || !tenant.Allows(ctx, agent.TenantID) {
+		return "", time.Time{}, false
+	}
+	version, err := Version(agent)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return version, AgentModified(agent), true
+}
+
+// Version returns a version of v, which changes with any of its fields
+func Version(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// AgentModified returns when an agent last changed, the latest of its times
+func AgentModified(agent *types.AgentInfo) time.Time {
+	return latest(agent.RegisteredAt, agent.UpdatedAt, agent.LastSeen)
+}
+
+// MetricsModified returns when a report last changed, the latest of its
+// times
+func MetricsModified(data *types.MetricsData) time.Time {
+	return latest(data.Timestamp, data.CollectedAt, data.ReportedAt)
+}
+
+// latest returns the latest of times
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, u := range times {
+		if u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
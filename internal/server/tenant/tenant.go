@@ -0,0 +1,36 @@
+package tenant
+
+import "context"
+
+// Default is the tenant of data created without tenant scope, credentials of
+// the default tenant administer the server
+const Default = "default"
+
+// contextKey is the context key of the request tenant
+type contextKey struct{}
+
+// WithContext returns ctx scoped to tenant id
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to, unscoped contexts such as
+// those of background tasks see all tenants
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// OrDefault returns the tenant of ctx, or the default tenant when unscoped
+func OrDefault(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
+
+// Allows reports whether ctx may access data of tenant id
+func Allows(ctx context.Context, id string) bool {
+	scope, ok := FromContext(ctx)
+	return !ok || scope == id
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"fmt"
+)
+
+// Config represents the OpenTelemetry tracing configuration. Spans are
+// exported with OTLP over HTTP.
+type Config struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint"` // Collector address, host:port
+	Insecure    bool              `mapstructure:"insecure"` // Plain HTTP instead of HTTPS
+	Headers     map[string]string `mapstructure:"headers"`
+	ServiceName string            `mapstructure:"service_name"`
+	SampleRatio float64           `mapstructure:"sample_ratio"` // Share of new traces recorded, 0 to 1
+}
+
+// SetDefaults sets default values if not specified
+func (cfg *Config) SetDefaults(serviceName string) *Config {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "localhost:4318"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = serviceName
+	}
+	if cfg.SampleRatio == 0 {
+		cfg.SampleRatio = 1
+	}
+	return cfg
+}
+
+// Validate validates the tracing configuration
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be between 0 and 1")
+	}
+
+	return nil
+}
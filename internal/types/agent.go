@@ -4,14 +4,64 @@ import "time"
 
 // AgentInfo represents agent information
 type AgentInfo struct {
-	ID           string      `json:"id"`
-	Hostname     string      `json:"hostname"`
-	Port         int         `json:"port"`
-	Version      string      `json:"version"`
+	ID           string             `json:"id"`
+	TenantID     string             `json:"tenant_id,omitempty"`
+	Hostname     string             `json:"hostname"`
+	Port         int                `json:"port"`
+	Version      string             `json:"version"`
+	Status       AgentStatus        `json:"status"`
+	Tags         map[string]string  `json:"tags,omitempty"`
+	Maintenance  *AgentMaintenance  `json:"maintenance,omitempty"`
+	Capabilities *AgentCapabilities `json:"capabilities,omitempty"` // Sent on registration, not stored
+	Protocol     *AgentProtocol     `json:"protocol,omitempty"`     // Negotiated on registration
+	LastSeen     time.Time          `json:"last_seen"`
+	RegisteredAt time.Time          `json:"registered_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	// Stalls per collector of the latest report, not stored
+	CollectorStalls map[string]int64 `json:"collector_stalls,omitempty"`
+}
+
+// AgentMaintenance represents an agent in maintenance, its offline and
+// threshold notifications are suppressed while its metrics are still stored
+type AgentMaintenance struct {
+	Reason string     `json:"reason,omitempty"`
+	Since  time.Time  `json:"since"`
+	Until  *time.Time `json:"until,omitempty"` // Empty until ended through the API
+}
+
+// Active returns true if m is in effect at now
+func (m *AgentMaintenance) Active(now time.Time) bool {
+	return m != nil && (m.Until == nil || now.Before(*m.Until))
+}
+
+// MaintenanceRequest represents putting an agent into maintenance, it ends
+// at Until, after Duration, or when ended through the API if neither is set
+type MaintenanceRequest struct {
+	Reason   string        `json:"reason"`
+	Until    *time.Time    `json:"until"`
+	Duration time.Duration `json:"duration" binding:"min=0"`
+}
+
+// AgentSortFields lists the fields agents can be sorted by
+var AgentSortFields = []string{"hostname", "id", "status", "version", "last_seen", "registered_at", "updated_at"}
+
+// AgentFilter represents filtering, sorting and paging options for agents
+type AgentFilter struct {
+	Statuses []AgentStatus     `json:"statuses,omitempty"`
+	Hostname string            `json:"hostname,omitempty"` // Case-insensitive hostname substring
+	Tags     map[string]string `json:"tags,omitempty"`     // Required tags, an empty value matches any value
+	SortBy   string            `json:"sort_by,omitempty"`  // One of AgentSortFields, hostname by default
+	SortDesc bool              `json:"sort_desc,omitempty"`
+	Limit    int               `json:"limit,omitempty"`
+	Offset   int               `json:"offset,omitempty"`
+}
+
+// AgentStatusInfo represents the status of an agent in the status of the
+// fleet
+type AgentStatusInfo struct {
 	Status       AgentStatus `json:"status"`
 	LastSeen     time.Time   `json:"last_seen"`
-	RegisteredAt time.Time   `json:"registered_at"`
-	UpdatedAt    time.Time   `json:"updated_at"`
+	LatestSample *time.Time  `json:"latest_sample_ts"` // Of the latest stored report, null without reports
 }
 
 // AgentStatus represents the current status of an agent
@@ -21,8 +71,39 @@ const (
 	AgentStatusOnline  AgentStatus = "online"
 	AgentStatusOffline AgentStatus = "offline"
 	AgentStatusError   AgentStatus = "error"
+	// AgentStatusRetired marks a decommissioned agent, it is not monitored and its reports are rejected
+	AgentStatusRetired AgentStatus = "retired"
+	// AgentStatusExpected marks an agent discovered before it registered
+	AgentStatusExpected AgentStatus = "expected"
+	// AgentStatusMissing marks an expected agent that did not register in time
+	AgentStatusMissing AgentStatus = "missing"
 )
 
+// AgentDecommission represents the retirement of an agent, its metrics are
+// archived when requested and its data is purged once PurgeAfter has passed
+type AgentDecommission struct {
+	AgentID         string     `json:"agent_id"`
+	TenantID        string     `json:"tenant_id,omitempty"`
+	Hostname        string     `json:"hostname"`
+	Reason          string     `json:"reason,omitempty"`
+	Archive         string     `json:"archive,omitempty"` // Archive storage, "file" or "s3"
+	ArchiveLocation string     `json:"archive_location,omitempty"`
+	ArchiveError    string     `json:"archive_error,omitempty"`
+	RetiredAt       time.Time  `json:"retired_at"`
+	PurgeAfter      time.Time  `json:"purge_after"`
+	ArchivedAt      *time.Time `json:"archived_at,omitempty"`
+	PurgedAt        *time.Time `json:"purged_at,omitempty"`
+}
+
+// DecommissionRequest represents the options of an agent decommission, zero
+// values use the configured defaults
+type DecommissionRequest struct {
+	Reason string `json:"reason"`
+	// Archive overrides the configured archive storage, "file", "s3" or "none"
+	Archive     string        `json:"archive" binding:"omitempty,oneof=file s3 none"`
+	GracePeriod time.Duration `json:"grace_period" binding:"min=0"`
+}
+
 // AgentMetrics represents agent metrics
 type AgentMetrics struct {
 	CurrentStatus     string    `json:"current_status"`
@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+// AgentLogLevels are the agent log levels from least to most severe
+var AgentLogLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// AgentLogEntry represents a log entry shipped by an agent
+type AgentLogEntry struct {
+	ID        int64          `json:"id"`
+	TenantID  string         `json:"tenant_id,omitempty"`
+	AgentID   string         `json:"agent_id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level"`
+	Caller    string         `json:"caller,omitempty"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// AgentLogBatch represents a batch of log entries shipped by an agent
+type AgentLogBatch struct {
+	Entries []*AgentLogEntry `json:"entries"`
+}
+
+// AgentLogFilter represents filtering options for agent log entries
+type AgentLogFilter struct {
+	AgentID   string    `json:"agent_id"`
+	Levels    []string  `json:"levels,omitempty"`
+	Search    string    `json:"search,omitempty"` // Case-insensitive substring of the message
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Limit     int       `json:"limit,omitempty"`
+	Offset    int       `json:"offset,omitempty"`
+}
+
+// AgentLogLevelsFrom returns the levels at least as severe as level, or nil if
+// level is unknown
+func AgentLogLevelsFrom(level string) []string {
+	for i, l := range AgentLogLevels {
+		if l == level {
+			return AgentLogLevels[i:]
+		}
+	}
+	return nil
+}
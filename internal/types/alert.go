@@ -0,0 +1,57 @@
+package types
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AlertAcknowledgement represents an alert notification acknowledged by a
+// user, e.g. with a chat button
+type AlertAcknowledgement struct {
+	AgentID string    `json:"agent_id"`
+	Alert   string    `json:"alert"`             // Notification type, e.g. agent_offline
+	Summary string    `json:"summary,omitempty"` // Text of the acknowledged notification
+	Actor   string    `json:"actor"`
+	Time    time.Time `json:"time"`
+}
+
+// RuleAlert represents a custom alert rule, or an anomaly of an interface
+// metric, firing on the metrics of an agent
+type RuleAlert struct {
+	Rule     string            `json:"rule"`
+	Severity string            `json:"severity"` // info, warning or critical
+	AgentID  string            `json:"agent_id"`
+	Hostname string            `json:"hostname,omitempty"`
+	Message  string            `json:"message"`
+	Labels   map[string]string `json:"labels,omitempty"` // Enrichment rendered by the rule
+	Time     time.Time         `json:"time"`
+}
+
+// LabelList returns the labels as sorted key=value pairs
+func (a *RuleAlert) LabelList() string {
+	pairs := make([]string, 0, len(a.Labels))
+	for _, k := range slices.Sorted(maps.Keys(a.Labels)) {
+		pairs = append(pairs, k+"="+a.Labels[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Alert rule sources
+const (
+	AlertRuleSourceConfig = "config"
+	AlertRuleSourceAPI    = "api"
+)
+
+// AlertRule represents a custom alert rule, an HCL condition evaluated on
+// the metrics of agents
+type AlertRule struct {
+	Name      string            `json:"name"`
+	Condition string            `json:"condition"`
+	Message   string            `json:"message,omitempty"`    // Template, e.g. "rx at ${format_rate(...)}"
+	Severity  string            `json:"severity"`             // info, warning or critical
+	Labels    map[string]string `json:"labels,omitempty"`     // Templates enriching the alert
+	Source    string            `json:"source,omitempty"`     // config or api, set by the server
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"` // Of rules declared through the API
+}
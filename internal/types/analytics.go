@@ -0,0 +1,70 @@
+package types
+
+import "time"
+
+// TopScope represents what top-N analytics rank
+type TopScope string
+
+const (
+	TopScopeAgents     TopScope = "agents"
+	TopScopeInterfaces TopScope = "interfaces"
+)
+
+// TopMetric represents the metric top-N analytics rank by
+type TopMetric string
+
+const (
+	// TopMetricBandwidth ranks by average received and transmitted bytes per second
+	TopMetricBandwidth TopMetric = "bandwidth"
+	// TopMetricErrorRate ranks by errors per packet over the window
+	TopMetricErrorRate TopMetric = "error_rate"
+	// TopMetricIPChanges ranks by the number of IP changes, external changes only count for agents
+	TopMetricIPChanges TopMetric = "ip_changes"
+)
+
+// TopQuery represents a top-N analytics query
+type TopQuery struct {
+	Scope     TopScope  `json:"scope"`
+	Metric    TopMetric `json:"metric"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Limit     int       `json:"limit"`
+}
+
+// TopEntry represents a ranked agent or interface
+type TopEntry struct {
+	Rank      int     `json:"rank"`
+	AgentID   string  `json:"agent_id"`
+	Hostname  string  `json:"hostname,omitempty"`
+	Interface string  `json:"interface,omitempty"`
+	Value     float64 `json:"value"`
+}
+
+// TopResult represents the result of a top-N analytics query
+type TopResult struct {
+	Scope     TopScope    `json:"scope"`
+	Metric    TopMetric   `json:"metric"`
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	Entries   []*TopEntry `json:"entries"`
+}
+
+// InterfaceUsage represents the traffic of an interface over a time range
+type InterfaceUsage struct {
+	AgentID   string  `json:"agent_id"`
+	Interface string  `json:"interface"`
+	Samples   int64   `json:"samples"`
+	Bandwidth float64 `json:"bandwidth"` // Average bytes per second, both directions
+	Errors    uint64  `json:"errors"`    // Errors counted during the range
+	Packets   uint64  `json:"packets"`   // Packets counted during the range
+	RxBytes   uint64  `json:"rx_bytes"`  // Bytes received during the range
+	TxBytes   uint64  `json:"tx_bytes"`  // Bytes transmitted during the range
+}
+
+// IPChangeCount represents the number of IP changes of an interface, the
+// interface is empty for external IP changes
+type IPChangeCount struct {
+	AgentID   string `json:"agent_id"`
+	Interface string `json:"interface,omitempty"`
+	Count     int64  `json:"count"`
+}
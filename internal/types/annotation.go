@@ -0,0 +1,85 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// AnnotationType represents the kind of event an annotation marks
+type AnnotationType string
+
+const (
+	// AnnotationIPChange marks an IP change of an agent interface
+	AnnotationIPChange AnnotationType = "ip_change"
+	// AnnotationAgentStatus marks an agent going offline or coming back
+	AnnotationAgentStatus AnnotationType = "agent_status"
+	// AnnotationAlert marks an interface, inventory or rule alert starting
+	AnnotationAlert AnnotationType = "alert"
+	// AnnotationDeployment marks a deployment, created through the API
+	AnnotationDeployment AnnotationType = "deployment"
+	// AnnotationEvent is the type of other annotations created through the API
+	AnnotationEvent AnnotationType = "event"
+	// AnnotationAcknowledgement marks an alert acknowledged from a chat
+	AnnotationAcknowledgement AnnotationType = "acknowledgement"
+)
+
+// Patterns of annotation types and tags, tags are listed space separated in
+// Grafana annotation queries
+var (
+	annotationTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,31}$`)
+	annotationTagPattern  = regexp.MustCompile(`^\S{1,64}$`)
+)
+
+// Annotation represents an event, or a period with an end time, overlaid
+// on dashboards. Global annotations have no agent.
+type Annotation struct {
+	ID        int64          `json:"id"`
+	TenantID  string         `json:"tenant_id"`
+	Type      AnnotationType `json:"type"`
+	AgentID   string         `json:"agent_id,omitempty"`
+	Time      time.Time      `json:"time"`
+	EndTime   *time.Time     `json:"end_time,omitempty"`
+	Title     string         `json:"title"`
+	Text      string         `json:"text,omitempty"`
+	Tags      []string       `json:"tags"`
+	CreatedBy string         `json:"created_by,omitempty"` // Actor of annotations created through the API
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Validate validates an annotation created through the API
+func (a *Annotation) Validate() error {
+	if !annotationTypePattern.MatchString(string(a.Type)) {
+		return fmt.Errorf("invalid type: %q", a.Type)
+	}
+	if a.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if len(a.Title) > 255 {
+		return fmt.Errorf("title exceeds 255 characters")
+	}
+	if a.EndTime != nil && a.EndTime.Before(a.Time) {
+		return fmt.Errorf("end_time must not be before time")
+	}
+	if len(a.Tags) > 20 {
+		return fmt.Errorf("at most 20 tags are allowed")
+	}
+	for _, tag := range a.Tags {
+		if !annotationTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag: %q", tag)
+		}
+	}
+	return nil
+}
+
+// AnnotationFilter represents filtering options for annotations, the range
+// matches annotations overlapping it
+type AnnotationFilter struct {
+	Types     []AnnotationType `json:"types,omitempty"`
+	AgentIDs  []string         `json:"agent_ids,omitempty"`
+	Tags      []string         `json:"tags,omitempty"` // Required tags
+	StartTime time.Time        `json:"start_time"`
+	EndTime   time.Time        `json:"end_time"`
+	Limit     int              `json:"limit,omitempty"`
+	Offset    int              `json:"offset,omitempty"`
+}
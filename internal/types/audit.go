@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// AuditEntry represents a recorded mutating API call
+type AuditEntry struct {
+	ID            int64     `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	Timestamp     time.Time `json:"timestamp"`
+	Actor         string    `json:"actor"`
+	Action        string    `json:"action"`
+	Resource      string    `json:"resource,omitempty"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Status        int       `json:"status"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+	PayloadDigest string    `json:"payload_digest,omitempty"`
+}
+
+// AuditFilter represents filtering options for audit entries
+type AuditFilter struct {
+	Actor     string    `json:"actor,omitempty"`
+	Actions   []string  `json:"actions,omitempty"`
+	Resource  string    `json:"resource,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Limit     int       `json:"limit,omitempty"`
+	Offset    int       `json:"offset,omitempty"`
+}
@@ -32,6 +32,13 @@ type CommandHistory struct {
 	Duration time.Duration `json:"duration"`
 }
 
+// QueuedCommand represents a command queued for an agent polling its
+// commands, Message is what is pushed to other agents
+type QueuedCommand struct {
+	CommandID string          `json:"command_id"`
+	Message   json.RawMessage `json:"message"`
+}
+
 // CommandStatus represents command execution status
 type CommandStatus string
 
@@ -43,3 +50,13 @@ const (
 	CommandStatusCanceled CommandStatus = "canceled"
 	CommandStatusTimedOut CommandStatus = "timed_out"
 )
+
+// Finished reports whether a command of the status has ended
+func (s CommandStatus) Finished() bool {
+	switch s {
+	case CommandStatusComplete, CommandStatusFailed, CommandStatusCanceled, CommandStatusTimedOut:
+		return true
+	default:
+		return false
+	}
+}
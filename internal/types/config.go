@@ -14,4 +14,5 @@ type ConfigModification struct {
 	Path     string `json:"path"`
 	OldValue any    `json:"old_value,omitempty"`
 	NewValue any    `json:"new_value"`
+	Applied  bool   `json:"applied"` // false if the change requires a restart
 }
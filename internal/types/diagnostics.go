@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// Diagnostics represents a support bundle uploaded by an agent, a gzipped
+// tar archive with its recent logs, redacted config, collector states,
+// goroutine dump and version info
+type Diagnostics struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	AgentID   string    `json:"agent_id"`
+	CommandID string    `json:"command_id,omitempty"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
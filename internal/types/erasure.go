@@ -0,0 +1,49 @@
+package types
+
+import "time"
+
+// ErasureStatus represents the state of a data erasure
+type ErasureStatus string
+
+// Erasure statuses
+const (
+	ErasurePending   ErasureStatus = "pending"
+	ErasureCompleted ErasureStatus = "completed"
+	ErasureFailed    ErasureStatus = "failed"
+)
+
+// ErasureRequest represents a request to erase all data of an agent, or of
+// every agent that reported a hostname
+type ErasureRequest struct {
+	AgentID     string `json:"agent_id"`
+	Hostname    string `json:"hostname"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"-"` // Actor of the request
+}
+
+// Erasure represents the erasure of the data of agents. The record is kept
+// as tombstone, reports of the erased agents up to the erasure are rejected.
+type Erasure struct {
+	ID          string         `json:"id"`
+	TenantID    string         `json:"tenant_id,omitempty"`
+	AgentID     string         `json:"agent_id,omitempty"`
+	Hostname    string         `json:"hostname,omitempty"`
+	AgentIDs    []string       `json:"agent_ids"` // Agents erased
+	Reason      string         `json:"reason,omitempty"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	Status      ErasureStatus  `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	Report      *ErasureReport `json:"report,omitempty"` // Once completed or failed
+	RequestedAt time.Time      `json:"requested_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// ErasureReport represents the data deleted by an erasure and what was
+// found left when verifying it
+type ErasureReport struct {
+	Deleted   map[string]int64 `json:"deleted"`             // Rows by table
+	Commands  int              `json:"commands"`            // Commands in flight, queued or in history
+	Archives  []string         `json:"archives,omitempty"`  // Locations of deleted archives
+	Remaining map[string]int64 `json:"remaining,omitempty"` // Rows by table found after deletion
+	Verified  bool             `json:"verified"`            // Whether no data was found left
+}
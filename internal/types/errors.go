@@ -3,6 +3,64 @@ package types
 import "errors"
 
 var (
-	ErrAgentNotFound = errors.New("agent not found")
-	ErrInvalidDriver = errors.New("invalid database driver")
+	ErrAgentNotFound       = errors.New("agent not found")
+	ErrAgentOnline         = errors.New("agent is online")
+	ErrAgentOffline        = errors.New("agent is not online")
+	ErrAgentExists         = errors.New("agent already exists")
+	ErrAgentRetired        = errors.New("agent is retired")
+	ErrAgentNotRetired     = errors.New("agent is not retired")
+	ErrAgentUnsupported    = errors.New("agent api version is not supported")
+	ErrAgentErased         = errors.New("agent data was erased")
+	ErrErasurePending      = errors.New("agent data is being erased")
+	ErrErasureNotFound     = errors.New("erasure not found")
+	ErrInvalidErasure      = errors.New("invalid erasure")
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrTenantExists        = errors.New("tenant already exists")
+	ErrTenantInUse         = errors.New("tenant has agents")
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserExists          = errors.New("user already exists")
+	ErrForbidden           = errors.New("forbidden")
+	ErrDuplicateMetrics    = errors.New("metrics already stored")
+	ErrInvalidMetrics      = errors.New("invalid metrics")
+	ErrDeltaBaseMismatch   = errors.New("delta base not held")
+	ErrIngestQueueFull     = errors.New("ingest queue full")
+	ErrIngestClosed        = errors.New("ingest queue closed")
+	ErrIngestThrottled     = errors.New("ingest throttled")
+	ErrQuotaExceeded       = errors.New("storage quota exceeded")
+	ErrRemoteWriteDisabled = errors.New("remote write is disabled")
+	ErrInvalidDriver       = errors.New("invalid database driver")
+	ErrNoDiagnostics       = errors.New("diagnostics not found")
+	ErrInvalidInventory    = errors.New("invalid inventory")
+	ErrInvalidMaintenance  = errors.New("invalid maintenance")
+	ErrNotInMaintenance    = errors.New("agent is not in maintenance")
+	ErrInvalidGrafanaQuery = errors.New("invalid grafana query")
+	ErrInvalidAnnotation   = errors.New("invalid annotation")
+	ErrTelegramUpdates     = errors.New("telegram updates are not received by webhook")
+	ErrCommandNotFound     = errors.New("command not found")
+	ErrJobNotFound         = errors.New("job not found")
+	ErrJobRunning          = errors.New("job is already running")
+	ErrJobDisabled         = errors.New("job is disabled")
+	ErrNotLeader           = errors.New("job runs on the leader")
+	ErrPruneRunning        = errors.New("prune is already running")
+	ErrPruneNotFound       = errors.New("no prune has run")
+	ErrInvalidPrune        = errors.New("invalid prune")
+	ErrInvalidIPWindow     = errors.New("invalid ip change window")
+	ErrAlertRuleNotFound   = errors.New("alert rule not found")
+	ErrInvalidAlertRule    = errors.New("invalid alert rule")
+	ErrAlertRuleConfigured = errors.New("alert rule is defined in the configuration")
+	ErrNotifyDisabled      = errors.New("notifications are disabled")
+	ErrChannelNotEnabled   = errors.New("notification channel is not enabled")
+)
+
+// General API errors, of requests rather than of a resource
+var (
+	ErrValidation      = errors.New("validation failed")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrNotFound        = errors.New("not found")
+	ErrRateLimited     = errors.New("rate limit exceeded")
+	ErrPayloadTooLarge = errors.New("request body too large")
+	ErrTimeout         = errors.New("request timeout")
+	ErrUnavailable     = errors.New("service unavailable")
+	ErrInternal        = errors.New("internal server error")
 )
@@ -0,0 +1,80 @@
+package types
+
+import "time"
+
+// GrafanaRange represents the time range of a Grafana JSON datasource request
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaSearch represents a Grafana JSON datasource metric search
+type GrafanaSearch struct {
+	Target string `json:"target"` // Substring of the returned targets, all targets if empty
+}
+
+// GrafanaTarget represents a queried series pattern, agent/interface/metric
+// where each segment may be a glob, e.g. web-*/eth0/rx_bytes_rate
+type GrafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId,omitempty"`
+	Type   string `json:"type,omitempty"` // Only timeserie is supported
+	Hide   bool   `json:"hide,omitempty"`
+}
+
+// GrafanaQuery represents a Grafana JSON datasource time series query
+type GrafanaQuery struct {
+	Range         GrafanaRange     `json:"range"`
+	IntervalMs    int64            `json:"intervalMs,omitempty"`
+	MaxDataPoints int              `json:"maxDataPoints,omitempty"`
+	Targets       []*GrafanaTarget `json:"targets"`
+}
+
+// GrafanaSeries represents a queried series, the datapoints are
+// [value, unix milliseconds] pairs ordered by time
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaAnnotationSource represents what annotations are overlaid
+type GrafanaAnnotationSource string
+
+const (
+	// GrafanaAnnotationIPChanges overlays the tracked IP changes
+	GrafanaAnnotationIPChanges GrafanaAnnotationSource = "ip_changes"
+	// GrafanaAnnotationAlerts overlays the periods interfaces exceeded the
+	// error or utilization alert thresholds
+	GrafanaAnnotationAlerts GrafanaAnnotationSource = "alerts"
+	// GrafanaAnnotationEvents overlays the stored annotations, the query
+	// lists the required types and tags, e.g. "events type=deployment prod"
+	GrafanaAnnotationEvents GrafanaAnnotationSource = "events"
+)
+
+// GrafanaAnnotationSpec represents the annotation definition of a Grafana
+// dashboard, the query is a source optionally followed by an agent glob,
+// e.g. "ip_changes web-*", or by types and tags for events
+type GrafanaAnnotationSpec struct {
+	Name   string `json:"name,omitempty"`
+	Query  string `json:"query"`
+	Enable bool   `json:"enable,omitempty"`
+}
+
+// GrafanaAnnotationQuery represents a Grafana JSON datasource annotation query
+type GrafanaAnnotationQuery struct {
+	Range      GrafanaRange           `json:"range"`
+	Annotation *GrafanaAnnotationSpec `json:"annotation"`
+}
+
+// GrafanaAnnotation represents an event or, with an end time, a region
+// overlaid on Grafana panels, times are unix milliseconds
+type GrafanaAnnotation struct {
+	Annotation *GrafanaAnnotationSpec `json:"annotation,omitempty"`
+	Time       int64                  `json:"time"`
+	TimeEnd    int64                  `json:"timeEnd,omitempty"`
+	IsRegion   bool                   `json:"isRegion,omitempty"`
+	Title      string                 `json:"title"`
+	Text       string                 `json:"text,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+}
@@ -12,9 +12,55 @@ type HealthStatus struct {
 	Version   string            `json:"version"`
 	StartTime time.Time         `json:"start_time"`
 	Uptime    time.Duration     `json:"uptime"`
+	Timezone  string            `json:"timezone"` // Display timezone of the server, times are in UTC
 	Details   []ComponentStatus `json:"details,omitempty"`
 }
 
+// Heartbeat represents the periodic liveness event of a server, a missing
+// heartbeat reveals a server that stopped working
+type Heartbeat struct {
+	NodeID       string        `json:"node_id"`
+	Version      string        `json:"version"`
+	StartTime    time.Time     `json:"start_time"`
+	Uptime       time.Duration `json:"uptime"`
+	AgentsOnline int           `json:"agents_online"`
+	AgentsTotal  int           `json:"agents_total"`
+	Timestamp    time.Time     `json:"timestamp"`
+}
+
+// ProbeStatus represents the result of a liveness or readiness probe
+type ProbeStatus struct {
+	Status    string                 `json:"status"` // ok, fail
+	Ready     bool                   `json:"ready"`
+	Checks    map[string]*ProbeCheck `json:"checks"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// ProbeCheck represents the result of a single dependency check
+type ProbeCheck struct {
+	Status   string  `json:"status"` // ok, warn, fail
+	Message  string  `json:"message,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Latency  float64 `json:"latency_ms"`
+	Critical bool    `json:"critical"`
+}
+
+// Evaluate sets the overall status from the critical checks
+func (p *ProbeStatus) Evaluate() {
+	p.Status = "ok"
+	for _, check := range p.Checks {
+		if check.Critical && check.Status == "fail" {
+			p.Status = "fail"
+			return
+		}
+	}
+}
+
+// OK reports whether all critical checks passed
+func (p *ProbeStatus) OK() bool {
+	return p.Status == "ok"
+}
+
 // ComponentStatus represents individual component status
 type ComponentStatus struct {
 	Name      string    `json:"name"`
@@ -37,6 +83,60 @@ type ServiceMetrics struct {
 	ErrorCount       int64         `json:"error_count"`
 	LastError        string        `json:"last_error,omitempty"`
 	LastErrorTime    time.Time     `json:"last_error_time,omitempty"`
+	Ingest           *IngestStats  `json:"ingest,omitempty"`
+}
+
+// ServiceStats represents the service counters, they start at zero on every
+// server restart
+type ServiceStats struct {
+	StartTime        time.Time                     `json:"start_time"`
+	Uptime           time.Duration                 `json:"uptime"`
+	ActiveAgents     int                           `json:"active_agents"`
+	TotalAgents      int                           `json:"total_agents"`
+	MetricsProcessed int64                         `json:"metrics_processed"`
+	IPChanges        int64                         `json:"ip_changes"`
+	ErrorCount       int64                         `json:"error_count"`
+	LastError        string                        `json:"last_error,omitempty"`
+	LastErrorTime    time.Time                     `json:"last_error_time,omitempty"`
+	Notifications    map[string]*NotificationStats `json:"notifications"` // By notifier type
+	Commands         CommandStats                  `json:"commands"`
+	Database         DatabaseStats                 `json:"database"`
+	Ingest           *IngestStats                  `json:"ingest,omitempty"`
+}
+
+// NotificationStats represents the delivery counts of a notifier
+type NotificationStats struct {
+	Sent        int64            `json:"sent"`
+	Failed      int64            `json:"failed"`
+	RateLimited int64            `json:"rate_limited"`         // Dropped by rate limits
+	Delayed     int64            `json:"delayed"`              // Queued by rate limits for a token
+	Redirected  int64            `json:"redirected"`           // Sent through the fallback while failed over
+	Suppressed  map[string]int64 `json:"suppressed,omitempty"` // Dropped by rate limits by event type
+}
+
+// CommandStats represents the counts of commands sent to agents
+type CommandStats struct {
+	Sent       int64 `json:"sent"`
+	SendErrors int64 `json:"send_errors"` // Commands the agent could not be reached for
+	Completed  int64 `json:"completed"`
+	Failed     int64 `json:"failed"`
+	TimedOut   int64 `json:"timed_out"`
+	Canceled   int64 `json:"canceled"`
+	Pending    int   `json:"pending"`
+}
+
+// IngestStats represents the state of the metrics ingest queue
+type IngestStats struct {
+	Depth    int     `json:"depth"`
+	Capacity int     `json:"capacity"`
+	Workers  int     `json:"workers"`
+	Enqueued int64   `json:"enqueued"`
+	Stored   int64   `json:"stored"`
+	Rejected int64   `json:"rejected"`
+	Retries  int64   `json:"retries"`
+	Lag      float64 `json:"lag_ms"` // Time the last stored batch waited in the queue
+	WAL      bool    `json:"wal"`
+	Segments int     `json:"wal_segments,omitempty"`
 }
 
 // SystemStats represents system statistics
@@ -49,6 +149,23 @@ type SystemStats struct {
 	LastGC       time.Time        `json:"last_gc"`
 	CPUUsage     float64          `json:"cpu_usage"`
 	DiskUsage    float64          `json:"disk_usage"`
+	Runtime      *RuntimeStats    `json:"runtime"`
+}
+
+// RuntimeStats represents goroutine, heap and garbage collection statistics
+type RuntimeStats struct {
+	Goroutines    int             `json:"goroutines"`
+	HeapAlloc     uint64          `json:"heap_alloc"`
+	HeapInuse     uint64          `json:"heap_inuse"`
+	HeapSys       uint64          `json:"heap_sys"`
+	HeapObjects   uint64          `json:"heap_objects"`
+	NextGC        uint64          `json:"next_gc"`
+	NumGC         uint32          `json:"num_gc"`
+	LastGC        time.Time       `json:"last_gc"`
+	GCPauseTotal  time.Duration   `json:"gc_pause_total"`
+	GCPauseMax    time.Duration   `json:"gc_pause_max"` // Longest of the recent pauses
+	GCPauses      []time.Duration `json:"gc_pauses"`    // Recent pauses, newest first
+	GCCPUFraction float64         `json:"gc_cpu_fraction"`
 }
 
 // DatabaseStats represents database statistics
@@ -61,4 +178,7 @@ type DatabaseStats struct {
 	ErrorCount      int64         `json:"error_count"`
 	SlowQueries     int64         `json:"slow_queries"`
 	AvgQueryTime    time.Duration `json:"avg_query_time"`
+	WaitDuration    time.Duration `json:"wait_duration"`
+	CacheHits       int64         `json:"cache_hits"`
+	CacheMisses     int64         `json:"cache_misses"`
 }
@@ -0,0 +1,125 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// InventoryState represents whether an expected agent or count is met
+type InventoryState string
+
+const (
+	InventoryOK            InventoryState = "ok"
+	InventoryPending       InventoryState = "pending"        // Not registered yet, within the registration timeout
+	InventoryNotRegistered InventoryState = "not_registered" // Never registered
+	InventoryDisappeared   InventoryState = "disappeared"    // Registered, then deleted or silent beyond missing_after
+	InventoryShortfall     InventoryState = "shortfall"      // Fewer reporting agents with the tags than expected
+)
+
+// Inventory represents the agents administrators expect to report
+type Inventory struct {
+	Agents []ExpectedAgent `json:"agents"`
+	Counts []ExpectedCount `json:"counts"`
+}
+
+// ExpectedAgent represents an agent expected by ID
+type ExpectedAgent struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname,omitempty"` // Shown in alerts until the agent registers
+}
+
+// ExpectedCount represents a minimum number of reporting agents with tags
+type ExpectedCount struct {
+	Name string            `json:"name"`
+	Tags map[string]string `json:"tags"`
+	Min  int               `json:"min"`
+}
+
+// Matches returns true if agent has all tags of the count
+func (c *ExpectedCount) Matches(agent *AgentInfo) bool {
+	for k, v := range c.Tags {
+		if agent.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// InventoryStatus represents the state of the expected inventory at the
+// last check
+type InventoryStatus struct {
+	CheckedAt time.Time             `json:"checked_at"`
+	Agents    []ExpectedAgentStatus `json:"agents"`
+	Counts    []ExpectedCountStatus `json:"counts"`
+}
+
+// ExpectedAgentStatus represents the state of an expected agent
+type ExpectedAgentStatus struct {
+	ExpectedAgent
+	State    InventoryState `json:"state"`
+	Status   AgentStatus    `json:"status,omitempty"` // Status of the agent when registered
+	LastSeen time.Time      `json:"last_seen,omitempty"`
+	Since    time.Time      `json:"since"` // When the agent entered the state
+}
+
+// ExpectedCountStatus represents the state of an expected count
+type ExpectedCountStatus struct {
+	ExpectedCount
+	State  InventoryState `json:"state"`
+	Actual int            `json:"actual"`
+	Since  time.Time      `json:"since"`
+}
+
+// MissingAgentAlert represents an expected agent that never registered or
+// disappeared, or a count of agents that fell short
+type MissingAgentAlert struct {
+	State    InventoryState    `json:"state"`
+	AgentID  string            `json:"agent_id,omitempty"`
+	Hostname string            `json:"hostname,omitempty"`
+	LastSeen time.Time         `json:"last_seen,omitempty"`
+	Count    string            `json:"count,omitempty"` // Name of the expected count
+	Tags     map[string]string `json:"tags,omitempty"`
+	Expected int               `json:"expected,omitempty"`
+	Actual   int               `json:"actual,omitempty"`
+	Since    time.Time         `json:"since"`
+}
+
+// Subject returns the agent or count the alert is about
+func (a *MissingAgentAlert) Subject() string {
+	if a.State == InventoryShortfall {
+		return a.Count
+	}
+	if a.Hostname != "" && a.Hostname != a.AgentID {
+		return fmt.Sprintf("%s (%s)", a.AgentID, a.Hostname)
+	}
+	return a.AgentID
+}
+
+// Message returns a one line description of the alert
+func (a *MissingAgentAlert) Message() string {
+	switch a.State {
+	case InventoryNotRegistered:
+		return fmt.Sprintf("Expected agent %s has never registered", a.Subject())
+	case InventoryDisappeared:
+		if a.LastSeen.IsZero() {
+			return fmt.Sprintf("Expected agent %s has disappeared", a.Subject())
+		}
+		return fmt.Sprintf("Expected agent %s has disappeared, last seen %s", a.Subject(), a.LastSeen.Format(time.RFC3339))
+	case InventoryShortfall:
+		return fmt.Sprintf("Only %d of %d expected agents with tags %s are reporting", a.Actual, a.Expected, a.TagList())
+	default:
+		return fmt.Sprintf("Expected agent %s is %s", a.Subject(), a.State)
+	}
+}
+
+// TagList returns the tags as sorted key=value pairs
+func (a *MissingAgentAlert) TagList() string {
+	pairs := make([]string, 0, len(a.Tags))
+	for _, k := range slices.Sorted(maps.Keys(a.Tags)) {
+		pairs = append(pairs, k+"="+a.Tags[k])
+	}
+	return strings.Join(pairs, ",")
+}
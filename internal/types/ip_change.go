@@ -24,8 +24,21 @@ type IPChangeSummary struct {
 	} `json:"changes_by_action"`
 }
 
+// IPContext represents reverse DNS and WHOIS context of an IP address
+type IPContext struct {
+	IP         string    `json:"ip"`
+	ReverseDNS []string  `json:"reverse_dns,omitempty"`
+	NetName    string    `json:"net_name,omitempty"`
+	Org        string    `json:"org,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	ASN        string    `json:"asn,omitempty"`
+	Source     string    `json:"source,omitempty"` // WHOIS server
+	LookedUpAt time.Time `json:"looked_up_at"`
+}
+
 // IPChangeFilter represents filtering options for IP changes
 type IPChangeFilter struct {
+	AgentIDs   []string    `json:"agent_ids,omitempty"`
 	StartTime  time.Time   `json:"start_time"`
 	EndTime    time.Time   `json:"end_time"`
 	Interfaces []string    `json:"interfaces,omitempty"`
@@ -38,11 +51,39 @@ type IPChangeFilter struct {
 
 // IPChangeStats represents IP change statistics
 type IPChangeStats struct {
-	TotalChanges    int64   `json:"total_changes"`
-	ChangesPerDay   float64 `json:"changes_per_day"`
-	ChangesPerWeek  float64 `json:"changes_per_week"`
-	ChangesPerMonth float64 `json:"changes_per_month"`
-	MostActiveHour  int     `json:"most_active_hour"`
-	MostActiveDay   int     `json:"most_active_day"`
-	AverageInterval float64 `json:"average_interval"` // in hours
+	TotalChanges       int64                   `json:"total_changes"`
+	ChangesPerDay      float64                 `json:"changes_per_day"`
+	ChangesPerWeek     float64                 `json:"changes_per_week"`
+	ChangesPerMonth    float64                 `json:"changes_per_month"`
+	MostActiveHour     int                     `json:"most_active_hour"`
+	MostActiveDay      int                     `json:"most_active_day"`
+	AverageInterval    float64                 `json:"average_interval"` // in hours
+	HourlyDistribution [24]int64               `json:"hourly_distribution"`
+	DailyDistribution  [7]int64                `json:"daily_distribution"` // Sunday first
+	Interfaces         []*InterfaceChangeStats `json:"interfaces,omitempty"`
+	Anomaly            *IPChangeAnomaly        `json:"anomaly,omitempty"`
+	PeriodStart        time.Time               `json:"period_start"`
+	PeriodEnd          time.Time               `json:"period_end"`
+}
+
+// InterfaceChangeStats represents IP change statistics of an interface
+type InterfaceChangeStats struct {
+	InterfaceName        string    `json:"interface_name,omitempty"`
+	Version              IPVersion `json:"version"`
+	IsExternal           bool      `json:"is_external"`
+	TotalChanges         int64     `json:"total_changes"`
+	AverageLeaseDuration float64   `json:"average_lease_duration"` // in hours
+	LastChange           time.Time `json:"last_change"`
+}
+
+// IPChangeAnomaly represents the deviation of the recent change rate from baseline
+type IPChangeAnomaly struct {
+	Window          string  `json:"window"`
+	RecentRate      float64 `json:"recent_rate"`   // changes per window
+	BaselineRate    float64 `json:"baseline_rate"` // mean changes per window
+	BaselineStd     float64 `json:"baseline_std"`
+	BaselineWindows int     `json:"baseline_windows"` // 0 without enough history
+	Score           float64 `json:"score"`
+	Threshold       float64 `json:"threshold"`
+	Anomalous       bool    `json:"anomalous"`
 }
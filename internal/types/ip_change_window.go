@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// IPChangeSeverityNone suppresses the notification of IP changes, they are
+// still recorded
+const IPChangeSeverityNone = "none"
+
+// IPChangeWindow represents a recurring window in which IP changes are
+// expected, e.g. the nightly reconnect of a PPPoE link. Changes inside it
+// are recorded with the window and notified at its severity.
+type IPChangeWindow struct {
+	Name       string        `json:"name"`
+	Schedule   string        `json:"schedule"`             // Cron expression of the start of the window
+	Duration   time.Duration `json:"duration"`             // Length of the window
+	Timezone   string        `json:"timezone,omitempty"`   // Of the schedule, server time zone when empty
+	Agents     []string      `json:"agents,omitempty"`     // Agent ID patterns, all agents when empty
+	Interfaces []string      `json:"interfaces,omitempty"` // Interface names, "external" for the external IP, all when empty
+	Severity   string        `json:"severity"`             // Of the notifications, "none" to suppress them
+}
@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+// JobStatus represents the state of a periodic task of the server
+type JobStatus struct {
+	Name       string        `json:"name"`
+	Schedule   string        `json:"schedule"`
+	Enabled    bool          `json:"enabled"`
+	Timeout    time.Duration `json:"timeout,omitempty"`
+	Overlap    string        `json:"overlap"`
+	LeaderOnly bool          `json:"leader_only"` // Scheduled runs are skipped on other replicas
+	Running    int           `json:"running"`     // Runs in progress
+	NextRun    *time.Time    `json:"next_run,omitempty"`
+	LastRun    *JobRun       `json:"last_run,omitempty"`
+	Runs       int64         `json:"runs"`
+	Failures   int64         `json:"failures"`
+	Skipped    int64         `json:"skipped"` // Runs skipped while the previous one was in progress
+}
+
+// JobRun represents a run of a periodic task
+type JobRun struct {
+	Trigger    JobTrigger    `json:"trigger"`
+	Status     JobRunStatus  `json:"status"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// JobTrigger represents what started a job run
+type JobTrigger string
+
+const (
+	JobTriggerSchedule JobTrigger = "schedule"
+	JobTriggerManual   JobTrigger = "manual"
+)
+
+// JobRunStatus represents the outcome of a job run
+type JobRunStatus string
+
+const (
+	JobRunRunning  JobRunStatus = "running"
+	JobRunOK       JobRunStatus = "ok"
+	JobRunFailed   JobRunStatus = "failed"
+	JobRunTimedOut JobRunStatus = "timed_out"
+	JobRunCanceled JobRunStatus = "canceled" // Replaced by the next run or stopped with the server
+)
@@ -53,3 +53,10 @@ type MetricsArchiveOptions struct {
 	Compress    bool      `json:"compress"`
 	DeleteAfter bool      `json:"delete_after"`
 }
+
+// MetricsBackfillResult represents the outcome of a historical metrics import
+type MetricsBackfillResult struct {
+	Received   int `json:"received"`
+	Stored     int `json:"stored"`
+	Duplicates int `json:"duplicates"`
+}
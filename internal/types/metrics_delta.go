@@ -0,0 +1,170 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// MetricsDelta represents the network state of a report encoded against the
+// previous report of the agent, as sent by agents in low-bandwidth mode.
+// Only changed fields are sent, interfaces changed in their timestamps alone
+// are left out.
+type MetricsDelta struct {
+	Base    time.Time       `json:"base"`    // Timestamp of the report the patch applies to
+	Network json.RawMessage `json:"network"` // JSON merge patch (RFC 7386) of the network state
+}
+
+// deltaVolatile are the interface fields changing with every collection
+var deltaVolatile = map[string]bool{
+	"updated_at":      true,
+	"collected_at":    true,
+	"sample_interval": true,
+}
+
+// State returns the encoded network state deltas apply to. IP changes are
+// events of a single report, so they are not part of the state.
+func (n *NetworkState) State() (json.RawMessage, error) {
+	state := *n
+	state.IPChanges = nil
+	return json.Marshal(&state)
+}
+
+// HasState reports whether a report carries the network state, reports of
+// IP changes alone do not
+func (m *MetricsData) HasState() bool {
+	return m.Metrics.Network != nil && len(m.Metrics.Network.Interfaces) > 0
+}
+
+// NetworkDelta returns the merge patch turning the encoded state base into
+// network, see NetworkState.State
+func NetworkDelta(base json.RawMessage, network *NetworkState) (json.RawMessage, error) {
+	from, err := decodeObject(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode delta base: %w", err)
+	}
+	encoded, err := json.Marshal(network)
+	if err != nil {
+		return nil, err
+	}
+	to, err := decodeObject(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := diffObjects(from, to)
+	if interfaces, ok := patch["interfaces"].(map[string]any); ok {
+		for name, p := range interfaces {
+			if obj, ok := p.(map[string]any); ok && volatileOnly(obj) {
+				delete(interfaces, name)
+			}
+		}
+		if len(interfaces) == 0 {
+			delete(patch, "interfaces")
+		}
+	}
+	return json.Marshal(patch)
+}
+
+// ApplyNetworkDelta applies a merge patch to the encoded state base
+func ApplyNetworkDelta(base, patch json.RawMessage) (*NetworkState, error) {
+	from, err := decodeObject(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode delta base: %w", err)
+	}
+	p, err := decodeObject(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode delta: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(from, p))
+	if err != nil {
+		return nil, err
+	}
+	var network NetworkState
+	if err := json.Unmarshal(merged, &network); err != nil {
+		return nil, fmt.Errorf("failed to decode patched network state: %w", err)
+	}
+	return &network, nil
+}
+
+// decodeObject decodes a JSON object, numbers are kept as json.Number so
+// counters beyond the precision of float64 survive
+func decodeObject(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	return obj, nil
+}
+
+// diffObjects returns the merge patch turning from into to
+func diffObjects(from, to map[string]any) map[string]any {
+	patch := make(map[string]any)
+	for k, tv := range to {
+		fv, ok := from[k]
+		if !ok {
+			patch[k] = tv
+			continue
+		}
+		fobj, fok := fv.(map[string]any)
+		tobj, tok := tv.(map[string]any)
+		if fok && tok {
+			if sub := diffObjects(fobj, tobj); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(fv, tv) {
+			patch[k] = tv
+		}
+	}
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+// mergePatch applies a merge patch to target as of RFC 7386
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// volatileOnly reports whether an interface patch changes volatile fields
+// alone
+func volatileOnly(patch map[string]any) bool {
+	for k, v := range patch {
+		if deltaVolatile[k] {
+			continue
+		}
+		if obj, ok := v.(map[string]any); ok && k == "statistics" && volatileOnly(obj) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// MetricsSchemaVersion is the version of the metrics report schema written
+// by this build. Reports without a version are of version 1.
+//
+// Version 2 adds schema_version, names every interface and reports the
+// external addresses per IP version in external_ips. Version 3 adds delta
+// reports of agents in low-bandwidth mode.
+const MetricsSchemaVersion = 3
+
+// maxAgentIDLength is the size of the agent ID columns
+const maxAgentIDLength = 64
+
+// maxHostnameLength is the size of the hostname columns
+const maxHostnameLength = 255
+
+// MetricsLimits bounds the reports accepted by the server, zero limits are
+// not enforced
+type MetricsLimits struct {
+	MaxInterfaces  int // Interfaces per report
+	MaxAddresses   int // Addresses per interface and IP version
+	MaxRoutes      int
+	MaxIPChanges   int
+	MaxNameLength  int // Interface names
+	MaxStringField int // Other strings of an interface, e.g. type or flags
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reports of older schema
+// versions are upgraded to the current one
+func (m *MetricsData) UnmarshalJSON(data []byte) error {
+	type plain MetricsData
+	if err := json.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+	m.upgrade()
+	return nil
+}
+
+// upgrade converts a report to the current schema version. Reports of newer
+// agents are read as the current version, schema changes are additive so
+// only the fields unknown to this build are dropped.
+func (m *MetricsData) upgrade() {
+	if m.SchemaVersion >= MetricsSchemaVersion {
+		m.SchemaVersion = MetricsSchemaVersion
+		return
+	}
+	if m.SchemaVersion >= 2 {
+		// Version 2 to 3 only adds fields
+		m.SchemaVersion = MetricsSchemaVersion
+		return
+	}
+
+	// Version 1 to 2
+	if network := m.Metrics.Network; network != nil {
+		for name, iface := range network.Interfaces {
+			if iface != nil && iface.Name == "" {
+				iface.Name = name
+			}
+		}
+		if len(network.ExternalIPs) == 0 && network.ExternalIP != "" {
+			version := IPv4
+			if strings.Contains(network.ExternalIP, ":") {
+				version = IPv6
+			}
+			network.ExternalIPs = map[IPVersion]string{version: network.ExternalIP}
+		}
+	}
+	m.SchemaVersion = MetricsSchemaVersion
+}
+
+// ValidateSchema checks a report against the schema and the limits, it
+// returns an error wrapping ErrInvalidMetrics naming the offending field
+func (m *MetricsData) ValidateSchema(limits *MetricsLimits) error {
+	if err := m.validateSchema(limits); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetrics, err)
+	}
+	return nil
+}
+
+// validateSchema checks a report against the schema and the limits
+func (m *MetricsData) validateSchema(limits *MetricsLimits) error {
+	switch {
+	case m.AgentID == "":
+		return fmt.Errorf("agent_id is required")
+	case len(m.AgentID) > maxAgentIDLength:
+		return fmt.Errorf("agent_id exceeds %d characters", maxAgentIDLength)
+	case len(m.Hostname) > maxHostnameLength:
+		return fmt.Errorf("hostname exceeds %d characters", maxHostnameLength)
+	case m.Timestamp.IsZero():
+		return fmt.Errorf("timestamp is required")
+	case m.Delta != nil && m.Metrics.Network != nil:
+		return fmt.Errorf("delta reports carry no metrics.network")
+	case m.Delta != nil && (m.Delta.Base.IsZero() || len(m.Delta.Network) == 0):
+		return fmt.Errorf("delta.base and delta.network are required")
+	}
+
+	network := m.Metrics.Network
+	if network == nil {
+		return nil
+	}
+
+	if exceeds(len(network.Interfaces), limits.MaxInterfaces) {
+		return fmt.Errorf("metrics.network.interfaces has %d entries, at most %d are accepted",
+			len(network.Interfaces), limits.MaxInterfaces)
+	}
+	for name, iface := range network.Interfaces {
+		if err := iface.validateSchema(name, limits); err != nil {
+			return fmt.Errorf("metrics.network.interfaces.%s: %v", truncate(name, limits.MaxNameLength), err)
+		}
+	}
+
+	if exceeds(len(network.Routes), limits.MaxRoutes) {
+		return fmt.Errorf("metrics.network.routes has %d entries, at most %d are accepted",
+			len(network.Routes), limits.MaxRoutes)
+	}
+	if exceeds(len(network.IPChanges), limits.MaxIPChanges) {
+		return fmt.Errorf("metrics.network.ip_changes has %d entries, at most %d are accepted",
+			len(network.IPChanges), limits.MaxIPChanges)
+	}
+	if network.ExternalIP != "" && !validAddress(network.ExternalIP) {
+		return fmt.Errorf("metrics.network.external_ip is not an IP address")
+	}
+	for version, ip := range network.ExternalIPs {
+		if version != IPv4 && version != IPv6 {
+			return fmt.Errorf("metrics.network.external_ips has unknown IP version %q", version)
+		}
+		if !validAddress(ip) {
+			return fmt.Errorf("metrics.network.external_ips.%s is not an IP address", version)
+		}
+	}
+	return nil
+}
+
+// validateSchema checks an interface reported under name
+func (i *InterfaceInfo) validateSchema(name string, limits *MetricsLimits) error {
+	switch {
+	case i == nil:
+		return fmt.Errorf("interface is empty")
+	case name == "":
+		return fmt.Errorf("name is required")
+	case exceeds(len(name), limits.MaxNameLength):
+		return fmt.Errorf("name exceeds %d characters", limits.MaxNameLength)
+	case i.Name != "" && i.Name != name:
+		return fmt.Errorf("name %q does not match its key", truncate(i.Name, limits.MaxNameLength))
+	case i.MTU < 0:
+		return fmt.Errorf("mtu must not be negative")
+	}
+
+	for field, value := range map[string]string{"type": i.Type, "mac": i.MAC, "flags": i.Flags, "status": i.Status} {
+		if exceeds(len(value), limits.MaxStringField) {
+			return fmt.Errorf("%s exceeds %d characters", field, limits.MaxStringField)
+		}
+	}
+
+	for field, addrs := range map[string][]string{"ipv4": i.IPv4, "ipv6": i.IPv6} {
+		if exceeds(len(addrs), limits.MaxAddresses) {
+			return fmt.Errorf("%s has %d addresses, at most %d are accepted", field, len(addrs), limits.MaxAddresses)
+		}
+		for _, addr := range addrs {
+			if !validAddress(addr) {
+				return fmt.Errorf("%s has an invalid address", field)
+			}
+		}
+	}
+	return nil
+}
+
+// validAddress reports whether s is an IP address, optionally with a
+// prefix length as reported for interfaces
+func validAddress(s string) bool {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
+
+// exceeds reports whether n is over a limit, zero limits are not enforced
+func exceeds(n, limit int) bool {
+	return limit > 0 && n > limit
+}
+
+// truncate shortens s to n bytes for error messages
+func truncate(s string, n int) string {
+	if n > 0 && len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
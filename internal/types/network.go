@@ -25,10 +25,16 @@ const (
 	IPChangeActionAdd    IPChangeAction = "add"
 	IPChangeActionUpdate IPChangeAction = "update"
 	IPChangeActionRemove IPChangeAction = "remove"
+
+	// IPChangeActionFlapping marks an interface or external IP as flapping
+	IPChangeActionFlapping IPChangeAction = "flapping"
+	// IPChangeActionStable marks the recovery of a flapping interface or external IP
+	IPChangeActionStable IPChangeAction = "stable"
 )
 
 // IPChange represents a detected IP address change
 type IPChange struct {
+	AgentID       string         `json:"agent_id,omitempty"`
 	InterfaceName string         `json:"interface_name,omitempty"`
 	Version       IPVersion      `json:"version"`
 	OldAddrs      []string       `json:"old_addrs"`
@@ -37,6 +43,11 @@ type IPChange struct {
 	Timestamp     time.Time      `json:"timestamp"`
 	Action        IPChangeAction `json:"action"`
 	Reason        string         `json:"reason,omitempty"`
+	Context       *IPContext     `json:"context,omitempty"`
+	// Expected window the change happened in, if any
+	ExpectedWindow string `json:"expected_window,omitempty"`
+	// Severity of the notification, set when it is sent
+	Severity string `json:"severity,omitempty"`
 }
 
 // IPAddress represents a parsed IP address
@@ -57,8 +68,41 @@ type IPState struct {
 // NetworkState represents the current state of network interfaces
 type NetworkState struct {
 	Interfaces map[string]*InterfaceInfo `json:"interfaces" validate:"required,dive"`
-	ExternalIP string                    `json:"external_ip,omitempty" validate:"omitempty,ip"`
+	ExternalIP string                    `json:"external_ip,omitempty" validate:"omitempty,ip"` // IPv4, or IPv6 without IPv4
 	IPChanges  []IPChange                `json:"ip_changes,omitempty"`
+	Gateways   map[IPVersion]string      `json:"gateways,omitempty"`
+	Routes     []RouteInfo               `json:"routes,omitempty"`
+
+	// External addresses detected independently per version
+	ExternalIPs map[IPVersion]string `json:"external_ips,omitempty"`
+	// Health of the external IP providers
+	ExternalProviders []ExternalProviderStats `json:"external_providers,omitempty"`
+}
+
+// ExternalProviderStats represents the health of an external IP provider
+type ExternalProviderStats struct {
+	URL          string     `json:"url"`
+	Version      IPVersion  `json:"version"`
+	Checks       int64      `json:"checks"`
+	Failures     int64      `json:"failures"`
+	SuccessRate  float64    `json:"success_rate"`
+	Latency      float64    `json:"latency_ms"` // Moving average of successful queries
+	LastError    string     `json:"last_error,omitempty"`
+	DemotedUntil *time.Time `json:"demoted_until,omitempty"`
+}
+
+// RouteInfo represents a route table entry
+type RouteInfo struct {
+	Interface   string    `json:"interface"`
+	Version     IPVersion `json:"version"`
+	Destination string    `json:"destination"`
+	Gateway     string    `json:"gateway,omitempty"`
+	Metric      int       `json:"metric"`
+}
+
+// IsDefault checks if the route is a default route
+func (r *RouteInfo) IsDefault() bool {
+	return r.Destination == "0.0.0.0/0" || r.Destination == "::/0"
 }
 
 // Validate performs validation of NetworkState
@@ -134,21 +178,93 @@ type InterfaceStats struct {
 	RxPacketsRate float64 `json:"rx_packets_rate"`
 	TxPacketsRate float64 `json:"tx_packets_rate"`
 
+	// Counter increases since the previous sample and the seconds between
+	// both
+	RxBytesDelta   uint64  `json:"rx_bytes_delta,omitempty"`
+	TxBytesDelta   uint64  `json:"tx_bytes_delta,omitempty"`
+	RxPacketsDelta uint64  `json:"rx_packets_delta,omitempty"`
+	TxPacketsDelta uint64  `json:"tx_packets_delta,omitempty"`
+	SampleInterval float64 `json:"sample_interval,omitempty"`
+
+	// Counter changes since the previous sample, rates of a reset are 0
+	CounterWrapped bool `json:"counter_wrapped,omitempty"`
+	CounterReset   bool `json:"counter_reset,omitempty"`
+	// Counter32 is set when the counters are known to wrap at 32 bits
+	Counter32 bool `json:"counter_32bit,omitempty"`
+
 	// Timestamp
 	CollectedAt time.Time `json:"collected_at"`
 }
 
+// Utilization returns the utilization of the link speed in percent by the
+// busier direction, links are full duplex. It is 0 when the speed is unknown.
+func (s *InterfaceStats) Utilization() float64 {
+	if s.Speed <= 0 {
+		return 0
+	}
+	maxRate := float64(s.Speed) * 1000000 / 8 // Mbps to bytes/s
+	return max(s.RxBytesRate, s.TxBytesRate) / maxRate * 100
+}
+
+// InterfaceMetrics are the metrics of interface statistics exposed to other
+// systems, see InterfaceStats.Metric
+var InterfaceMetrics = []string{
+	"rx_bytes_rate", "tx_bytes_rate", "rx_packets_rate", "tx_packets_rate",
+	"rx_errors", "tx_errors", "rx_dropped", "tx_dropped",
+	"utilization", "is_up",
+}
+
+// Metric returns the value of one of InterfaceMetrics, false for an unknown
+// metric or the utilization of a link of unknown speed
+func (s *InterfaceStats) Metric(name string) (float64, bool) {
+	switch name {
+	case "rx_bytes_rate":
+		return s.RxBytesRate, true
+	case "tx_bytes_rate":
+		return s.TxBytesRate, true
+	case "rx_packets_rate":
+		return s.RxPacketsRate, true
+	case "tx_packets_rate":
+		return s.TxPacketsRate, true
+	case "rx_errors":
+		return float64(s.RxErrors), true
+	case "tx_errors":
+		return float64(s.TxErrors), true
+	case "rx_dropped":
+		return float64(s.RxDropped), true
+	case "tx_dropped":
+		return float64(s.TxDropped), true
+	case "utilization":
+		return s.Utilization(), s.Speed > 0
+	case "is_up":
+		if s.IsUp {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
 // MetricsData represents collected metrics data
 type MetricsData struct {
-	AgentID     string    `json:"agent_id"`
-	Hostname    string    `json:"hostname"`
-	Version     string    `json:"version"`
-	Timestamp   time.Time `json:"timestamp"`
-	CollectedAt time.Time `json:"collected_at"`
-	ReportedAt  time.Time `json:"reported_at"`
-	Metrics     struct {
+	SchemaVersion int       `json:"schema_version,omitempty"` // Of the report, see MetricsSchemaVersion
+	AgentID       string    `json:"agent_id"`
+	Hostname      string    `json:"hostname"`
+	Version       string    `json:"version"`
+	Timestamp     time.Time `json:"timestamp"`
+	CollectedAt   time.Time `json:"collected_at"`
+	ReportedAt    time.Time `json:"reported_at"`
+	Metrics       struct {
 		Network *NetworkState `json:"network,omitempty"`
 	} `json:"metrics"`
+	// Network state encoded against the previous report, instead of
+	// metrics.network, see MetricsDelta
+	Delta *MetricsDelta `json:"delta,omitempty"`
+	// Collections canceled as stalled per collector since the agent started
+	CollectorStalls map[string]int64 `json:"collector_stalls,omitempty"`
+	// Collect command the report answers, empty for scheduled reports
+	CommandID string `json:"command_id,omitempty"`
 }
 
 // ToJSON converts MetricsData to JSON
@@ -160,3 +276,28 @@ func (m *MetricsData) ToJSON() ([]byte, error) {
 func (m *MetricsData) FromJSON(data []byte) error {
 	return json.Unmarshal(data, m)
 }
+
+// ToUTC converts the times of a report to UTC, agents report them in their
+// local timezone
+func (m *MetricsData) ToUTC() {
+	m.Timestamp = m.Timestamp.UTC()
+	m.CollectedAt = m.CollectedAt.UTC()
+	m.ReportedAt = m.ReportedAt.UTC()
+
+	network := m.Metrics.Network
+	if network == nil {
+		return
+	}
+	for _, iface := range network.Interfaces {
+		if iface == nil {
+			continue
+		}
+		iface.UpdatedAt = iface.UpdatedAt.UTC()
+		if iface.Statistics != nil {
+			iface.Statistics.CollectedAt = iface.Statistics.CollectedAt.UTC()
+		}
+	}
+	for i := range network.IPChanges {
+		network.IPChanges[i].Timestamp = network.IPChanges[i].Timestamp.UTC()
+	}
+}
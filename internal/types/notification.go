@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// NotificationTestStatus represents the outcome of a test notification
+type NotificationTestStatus string
+
+// Test notification statuses
+const (
+	NotificationTestSent        NotificationTestStatus = "sent"
+	NotificationTestFailed      NotificationTestStatus = "failed"
+	NotificationTestRateLimited NotificationTestStatus = "rate_limited"
+	NotificationTestTimeout     NotificationTestStatus = "timeout" // Not delivered before the request ended
+)
+
+// NotificationTestRequest represents a request to send a test notification
+type NotificationTestRequest struct {
+	Channel string `json:"channel,omitempty"` // All enabled channels when empty
+}
+
+// NotificationTestResult represents the delivery of a test notification
+// through a channel
+type NotificationTestResult struct {
+	Channel  string                 `json:"channel"`
+	Status   NotificationTestStatus `json:"status"`
+	Error    string                 `json:"error,omitempty"`
+	Duration time.Duration          `json:"duration"` // Of the delivery, once dequeued
+}
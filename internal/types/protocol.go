@@ -0,0 +1,44 @@
+package types
+
+import (
+	"slices"
+	"time"
+)
+
+// AgentAPIVersion is the version of the agent API of this build, raised
+// when agents and servers of different versions stop understanding each other
+const AgentAPIVersion = 1
+
+// Protocol features agents and servers negotiate on registration
+const (
+	FeatureBatching     = "batching"      // Reports sent in batches to /v1/metrics/batch
+	FeatureCompression  = "compression"   // Compressed report bodies, see AgentProtocol.Encoding
+	FeatureDelta        = "delta"         // Delta reports of low-bandwidth mode
+	FeaturePullCommands = "pull_commands" // Commands polled by the agent instead of pushed to it
+)
+
+// AgentFeatures lists the protocol features known to this build
+var AgentFeatures = []string{FeatureBatching, FeatureCompression, FeatureDelta, FeaturePullCommands}
+
+// AgentCapabilities represents the API version and protocol features an
+// agent supports, sent on registration
+type AgentCapabilities struct {
+	APIVersion int      `json:"api_version"`
+	Features   []string `json:"features,omitempty"`
+	Encodings  []string `json:"encodings,omitempty"` // Report encodings, in order of preference
+}
+
+// AgentProtocol represents the protocol negotiated with an agent, the
+// features both sides support. Agents registered before negotiation was
+// introduced have none.
+type AgentProtocol struct {
+	APIVersion   int       `json:"api_version"`
+	Features     []string  `json:"features"`
+	Encoding     string    `json:"encoding,omitempty"` // Report encoding when compression is negotiated
+	NegotiatedAt time.Time `json:"negotiated_at"`
+}
+
+// Has reports whether a feature was negotiated, nil protocols have none
+func (p *AgentProtocol) Has(feature string) bool {
+	return p != nil && slices.Contains(p.Features, feature)
+}
@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// PruneStatus represents the state of a prune
+type PruneStatus string
+
+// Prune statuses
+const (
+	PruneRunning   PruneStatus = "running"
+	PruneCompleted PruneStatus = "completed"
+	PruneFailed    PruneStatus = "failed"
+)
+
+// PruneRequest represents a request to delete the metrics older than a cutoff
+type PruneRequest struct {
+	Before      time.Time `json:"before"`
+	DryRun      bool      `json:"dry_run"` // Only count the rows that would be deleted
+	RequestedBy string    `json:"-"`       // Actor of the request
+}
+
+// Prune represents a run of the database cleanup with a cutoff
+type Prune struct {
+	ID          string      `json:"id"`
+	Before      time.Time   `json:"before"`
+	DryRun      bool        `json:"dry_run"`
+	RequestedBy string      `json:"requested_by,omitempty"`
+	Status      PruneStatus `json:"status"`
+	Rows        int64       `json:"rows"`    // Rows older than the cutoff when it started
+	Deleted     int64       `json:"deleted"` // Rows deleted so far
+	Error       string      `json:"error,omitempty"`
+	StartedAt   time.Time   `json:"started_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
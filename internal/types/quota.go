@@ -0,0 +1,50 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Agent quotas
+const (
+	QuotaRows    = "rows"    // Stored reports
+	QuotaBytes   = "bytes"   // Stored report size
+	QuotaSamples = "samples" // Reports ingested per hour
+)
+
+// AgentStorage represents the metrics stored for an agent
+type AgentStorage struct {
+	AgentID string `json:"agent_id"`
+	Rows    int64  `json:"rows"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// AgentQuota represents the quota usage of an agent
+type AgentQuota struct {
+	AgentID     string    `json:"agent_id"`
+	Rows        int64     `json:"rows"`
+	Bytes       int64     `json:"bytes"`
+	SamplesHour int64     `json:"samples_hour"`       // Reports ingested within the last hour
+	Exceeded    []string  `json:"exceeded,omitempty"` // Quotas the agent is over
+	MeasuredAt  time.Time `json:"measured_at"`        // Of the storage, rows admitted since are added
+}
+
+// QuotaError represents ingestion refused by a quota of an agent, it
+// matches ErrIngestThrottled or ErrQuotaExceeded
+type QuotaError struct {
+	AgentID    string
+	Quota      string
+	Limit      int64
+	RetryAfter time.Duration // Zero when ingestion is rejected until data is pruned
+	Err        error
+}
+
+// Error implements error
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: agent %s is over its %s quota of %d", e.Err, e.AgentID, e.Quota, e.Limit)
+}
+
+// Unwrap returns the sentinel error
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
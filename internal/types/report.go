@@ -0,0 +1,56 @@
+package types
+
+import "time"
+
+// ReportAlertType represents the kind of a report alert
+type ReportAlertType string
+
+const (
+	// ReportAlertIPChange counts IP changes of an interface, or external IP changes
+	ReportAlertIPChange ReportAlertType = "ip_change"
+	// ReportAlertNetworkErrors counts errors of an interface
+	ReportAlertNetworkErrors ReportAlertType = "network_errors"
+	// ReportAlertAgentOffline counts the offline thresholds an agent did not report in
+	ReportAlertAgentOffline ReportAlertType = "agent_offline"
+)
+
+// Report represents a summary of the fleet, or of some agents, over a period
+type Report struct {
+	Name        string         `json:"name"`
+	Period      string         `json:"period"`
+	StartTime   time.Time      `json:"start_time"`
+	EndTime     time.Time      `json:"end_time"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Totals      ReportTotals   `json:"totals"`
+	Agents      []*AgentReport `json:"agents"`
+	TopAlerts   []*ReportAlert `json:"top_alerts"`
+}
+
+// ReportTotals represents the totals of a report
+type ReportTotals struct {
+	Agents       int     `json:"agents"`
+	RxBytes      uint64  `json:"rx_bytes"`
+	TxBytes      uint64  `json:"tx_bytes"`
+	Availability float64 `json:"availability"` // Average of the agents, in percent
+	IPChanges    int64   `json:"ip_changes"`
+}
+
+// AgentReport represents the summary of an agent over a report period
+type AgentReport struct {
+	AgentID      string      `json:"agent_id"`
+	Hostname     string      `json:"hostname"`
+	Status       AgentStatus `json:"status"`
+	RxBytes      uint64      `json:"rx_bytes"`
+	TxBytes      uint64      `json:"tx_bytes"`
+	Availability float64     `json:"availability"` // Share of the period the agent reported in, in percent
+	IPChanges    int64       `json:"ip_changes"`
+}
+
+// ReportAlert represents an alert condition counted over a report period
+type ReportAlert struct {
+	Type      ReportAlertType `json:"type"`
+	AgentID   string          `json:"agent_id"`
+	Hostname  string          `json:"hostname"`
+	Interface string          `json:"interface,omitempty"`
+	Count     int64           `json:"count"`
+}
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// Tenant represents a namespace owning agents, their data and API keys
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey represents an API credential bound to a tenant and optionally to a
+// user, the key itself is only returned when it is created
+type APIKey struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	Key       string    `json:"key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantUsage represents the resources held by a tenant
+type TenantUsage struct {
+	TenantID     string `json:"tenant_id"`
+	Agents       int64  `json:"agents"`
+	OnlineAgents int64  `json:"online_agents"`
+	Metrics      int64  `json:"metrics"`
+	IPChanges    int64  `json:"ip_changes"`
+	APIKeys      int64  `json:"api_keys"`
+}
@@ -0,0 +1,50 @@
+package types
+
+import "time"
+
+// Role represents the access level of a credential
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// roleRank orders roles, each role includes the ones below it
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Includes reports whether r grants the access of required
+func (r Role) Includes(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// User represents a role assignment within a tenant, API keys issued to the
+// user act with its role. An empty agent list grants access to all agents.
+type User struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	Agents    []string  `json:"agents"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Principal represents an authenticated credential
+type Principal struct {
+	Actor    string
+	TenantID string
+	Role     Role
+	Agents   []string
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// sysRoot and procRoot are where sysfs and procfs are read from, the
+	// host mounts when monitoring the host from a container
+	sysRoot  = "/sys"
+	procRoot = "/proc"
+
+	inContainer     bool
+	inContainerOnce sync.Once
+)
+
+// SetHostPaths sets the sysfs and procfs mounts network data is read from,
+// empty values keep the current ones
+func SetHostPaths(sys, proc string) {
+	if sys != "" {
+		sysRoot = sys
+	}
+	if proc != "" {
+		procRoot = proc
+	}
+}
+
+// SysClassNetPath returns the path of a file of an interface in sysfs
+func SysClassNetPath(ifaceName string, elem ...string) string {
+	return filepath.Join(append([]string{sysRoot, "class", "net", ifaceName}, elem...)...)
+}
+
+// ProcNetPath returns the path of a file in /proc/net. With a host procfs
+// mount the files of PID 1 are read, as /proc/net resolves to the network
+// namespace of the reading process.
+func ProcNetPath(name string) string {
+	if procRoot != "/proc" {
+		return filepath.Join(procRoot, "1", "net", name)
+	}
+	return filepath.Join(procRoot, "net", name)
+}
+
+// InContainer returns true when running inside a container
+func InContainer() bool {
+	inContainerOnce.Do(func() {
+		inContainer = detectContainer()
+	})
+	return inContainer
+}
+
+// detectContainer checks the markers left by container runtimes
+func detectContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" || os.Getenv("container") != "" {
+		return true
+	}
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+		if strings.Contains(string(data), runtime) {
+			return true
+		}
+	}
+	return false
+}
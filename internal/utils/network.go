@@ -2,9 +2,11 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -62,7 +64,7 @@ func ReadNetworkStat(ifaceName, statName string) (uint64, error) {
 		return 0, fmt.Errorf("network statistics are only supported on Linux")
 	}
 
-	path := filepath.Join("/sys/class/net", ifaceName, "statistics", statName)
+	path := SysClassNetPath(ifaceName, "statistics", statName)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read network stat %s for interface %s: %w",
@@ -81,18 +83,14 @@ func ReadNetworkStat(ifaceName, statName string) (uint64, error) {
 
 // GetInterfaceType determines the type of network interface
 func GetInterfaceType(ifaceName string) InterfaceType {
-	name := strings.ToLower(ifaceName)
-
 	// Check common prefixes for different types
-	for prefix, ifaceType := range interfaceTypePrefixes {
-		if strings.HasPrefix(name, prefix) {
-			return ifaceType
-		}
+	if ifaceType, ok := interfaceTypeByPrefix(ifaceName); ok {
+		return ifaceType
 	}
 
 	// Check if it's a wireless interface (on Linux)
 	if IsLinux() {
-		if _, err := os.Stat(filepath.Join("/sys/class/net", ifaceName, "wireless")); err == nil {
+		if _, err := os.Stat(SysClassNetPath(ifaceName, "wireless")); err == nil {
 			return InterfaceTypeWireless
 		}
 	}
@@ -115,6 +113,18 @@ const (
 	InterfaceTypeVPN       InterfaceType = "vpn"
 )
 
+// InterfaceTypes are the types GetInterfaceType detects
+var InterfaceTypes = []InterfaceType{
+	InterfaceTypeEthernet,
+	InterfaceTypeWireless,
+	InterfaceTypeVirtual,
+	InterfaceTypeBridge,
+	InterfaceTypeTunnel,
+	InterfaceTypeBonding,
+	InterfaceTypeContainer,
+	InterfaceTypeVPN,
+}
+
 // interfaceTypePrefixes maps interface name prefixes to their types
 var interfaceTypePrefixes = map[string]InterfaceType{
 	"eth":    InterfaceTypeEthernet,
@@ -138,6 +148,26 @@ var interfaceTypePrefixes = map[string]InterfaceType{
 	"vmbr":   InterfaceTypeBridge, // Proxmox bridge
 }
 
+// InterfaceTypeByName determines the type of network interface by its name
+// alone, for interfaces of other hosts
+func InterfaceTypeByName(ifaceName string) InterfaceType {
+	if ifaceType, ok := interfaceTypeByPrefix(ifaceName); ok {
+		return ifaceType
+	}
+	return InterfaceTypeEthernet
+}
+
+// interfaceTypeByPrefix returns the type of the name prefix of an interface
+func interfaceTypeByPrefix(ifaceName string) (InterfaceType, bool) {
+	name := strings.ToLower(ifaceName)
+	for prefix, ifaceType := range interfaceTypePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return ifaceType, true
+		}
+	}
+	return "", false
+}
+
 // IsPhysicalInterface checks if the interface is physical
 func IsPhysicalInterface(name string, flags net.Flags) bool {
 	ifaceType := GetInterfaceType(name)
@@ -182,6 +212,81 @@ func GetInterfaceStats(name string) (*types.InterfaceStats, error) {
 	return stats, nil
 }
 
+// CounterChange represents how an interface counter changed between samples
+type CounterChange int
+
+const (
+	CounterIncreased CounterChange = iota // Unchanged or grew
+	CounterWrapped                        // Wrapped around 32 bits
+	CounterReset                          // Reset, e.g. by an interface reset or reboot
+)
+
+// CounterDelta returns the increase of a counter between two samples. A
+// smaller value is taken as a wrap when the counter is known to be 32-bit
+// and the increase fits in half its range, otherwise the counter was reset
+// and the increase is unknown.
+func CounterDelta(prev, cur uint64, counter32 bool) (uint64, CounterChange) {
+	if cur >= prev {
+		return cur - prev, CounterIncreased
+	}
+	if counter32 && prev <= math.MaxUint32 {
+		if delta := math.MaxUint32 - prev + cur + 1; delta < 1<<31 {
+			return delta, CounterWrapped
+		}
+	}
+	return 0, CounterReset
+}
+
+// maxBytesDelta returns the most bytes a link of speed Mbps can carry in
+// interval seconds, with a margin for jitter of the sample times, or 0
+// when the speed is unknown
+func maxBytesDelta(speed int64, interval float64) uint64 {
+	if speed <= 0 {
+		return 0
+	}
+	return uint64(float64(speed) * 1000000 / 8 * interval * 1.1)
+}
+
+// SetInterfaceRates sets the deltas and rates of cur from the counters of
+// the previous sample prev. Samples with a reset counter are marked and
+// have no rates, as the traffic since the previous sample is unknown.
+func SetInterfaceRates(cur, prev *types.InterfaceStats) {
+	interval := cur.CollectedAt.Sub(prev.CollectedAt).Seconds()
+	if interval <= 0 {
+		return
+	}
+
+	rxBytes, rxBytesChange := CounterDelta(prev.RxBytes, cur.RxBytes, cur.Counter32)
+	txBytes, txBytesChange := CounterDelta(prev.TxBytes, cur.TxBytes, cur.Counter32)
+	rxPackets, rxPacketsChange := CounterDelta(prev.RxPackets, cur.RxPackets, cur.Counter32)
+	txPackets, txPacketsChange := CounterDelta(prev.TxPackets, cur.TxPackets, cur.Counter32)
+	changes := []CounterChange{rxBytesChange, txBytesChange, rxPacketsChange, txPacketsChange}
+
+	// More bytes than the link can carry mean the counters were reset
+	if limit := maxBytesDelta(cur.Speed, interval); limit > 0 && (rxBytes > limit || txBytes > limit) {
+		changes = append(changes, CounterReset)
+	}
+
+	if slices.Contains(changes, CounterReset) {
+		cur.CounterReset = true
+		cur.RxBytesDelta, cur.TxBytesDelta, cur.RxPacketsDelta, cur.TxPacketsDelta = 0, 0, 0, 0
+		cur.RxBytesRate, cur.TxBytesRate, cur.RxPacketsRate, cur.TxPacketsRate = 0, 0, 0, 0
+		cur.SampleInterval = 0
+		return
+	}
+
+	cur.CounterWrapped = slices.Contains(changes, CounterWrapped)
+	cur.RxBytesDelta = rxBytes
+	cur.TxBytesDelta = txBytes
+	cur.RxPacketsDelta = rxPackets
+	cur.TxPacketsDelta = txPackets
+	cur.SampleInterval = interval
+	cur.RxBytesRate = float64(rxBytes) / interval
+	cur.TxBytesRate = float64(txBytes) / interval
+	cur.RxPacketsRate = float64(rxPackets) / interval
+	cur.TxPacketsRate = float64(txPackets) / interval
+}
+
 // IsFileExists checks if a file exists
 func IsFileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -223,7 +328,7 @@ func getOperState(name string) string {
 		return ""
 	}
 
-	data, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/operstate", name))
+	data, err := os.ReadFile(SysClassNetPath(name, "operstate"))
 	if err != nil {
 		return ""
 	}
@@ -235,7 +340,7 @@ func getInterfaceSpeed(name string) int64 {
 		return 0
 	}
 
-	data, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/speed", name))
+	data, err := os.ReadFile(SysClassNetPath(name, "speed"))
 	if err != nil {
 		return 0
 	}
@@ -252,7 +357,7 @@ func hasCarrier(name string) bool {
 		return false
 	}
 
-	data, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/carrier", name))
+	data, err := os.ReadFile(SysClassNetPath(name, "carrier"))
 	if err != nil {
 		return false
 	}
@@ -265,7 +370,9 @@ func hasCarrier(name string) bool {
 }
 
 func getLinuxStats(name string, stats *types.InterfaceStats) error {
-	statsDir := fmt.Sprintf("/sys/class/net/%s/statistics", name)
+	statsDir := SysClassNetPath(name, "statistics")
+	// The kernel counters are unsigned longs, 32-bit on 32-bit systems
+	stats.Counter32 = strconv.IntSize == 32
 
 	// Read statistics files
 	statFiles := map[string]*uint64{
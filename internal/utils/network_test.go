@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"wameter/internal/types"
+)
+
+// TestCounterDelta tests that only 32-bit counters are taken as wrapped
+func TestCounterDelta(t *testing.T) {
+	testCases := []struct {
+		name      string
+		prev      uint64
+		cur       uint64
+		counter32 bool
+		delta     uint64
+		change    CounterChange
+	}{
+		{name: "Increase", prev: 100, cur: 250, delta: 150, change: CounterIncreased},
+		{name: "Unchanged", prev: 100, cur: 100, change: CounterIncreased},
+		{name: "32-bit wrap", prev: math.MaxUint32 - 99, cur: 50, counter32: true, delta: 150, change: CounterWrapped},
+		{name: "32-bit reset", prev: 1 << 20, cur: 50, counter32: true, change: CounterReset},
+		{name: "64-bit reset below 2^32", prev: math.MaxUint32 - 99, cur: 50, change: CounterReset},
+		{name: "64-bit reset", prev: 1 << 40, cur: 50, change: CounterReset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delta, change := CounterDelta(tc.prev, tc.cur, tc.counter32)
+			assert.Equal(t, tc.delta, delta)
+			assert.Equal(t, tc.change, change)
+		})
+	}
+}
+
+// TestSetInterfaceRates tests that samples with counters reset or grown past
+// what the link can carry have no rates
+func TestSetInterfaceRates(t *testing.T) {
+	at := time.Now()
+	testCases := []struct {
+		name      string
+		prev      uint64
+		cur       uint64
+		counter32 bool
+		speed     int64
+		rate      float64
+		wrapped   bool
+		reset     bool
+	}{
+		{name: "Increase", prev: 1000, cur: 11000, speed: 1000, rate: 1000},
+		{name: "Unknown speed", prev: 1000, cur: 1 << 40, rate: float64(1<<40-1000) / 10},
+		{name: "32-bit wrap", prev: math.MaxUint32 - 999, cur: 9000, counter32: true, speed: 1000, rate: 1000, wrapped: true},
+		{name: "32-bit wrap past link speed", prev: 3 << 30, cur: 1000, counter32: true, speed: 100, reset: true},
+		{name: "64-bit reset below 2^32", prev: math.MaxUint32 - 999, cur: 9000, speed: 1000, reset: true},
+		{name: "Increase past link speed", prev: 1000, cur: 1 << 40, speed: 1000, reset: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prev := &types.InterfaceStats{RxBytes: tc.prev, CollectedAt: at}
+			cur := &types.InterfaceStats{
+				RxBytes:     tc.cur,
+				Speed:       tc.speed,
+				Counter32:   tc.counter32,
+				CollectedAt: at.Add(10 * time.Second),
+			}
+			SetInterfaceRates(cur, prev)
+			assert.Equal(t, tc.rate, cur.RxBytesRate)
+			assert.Equal(t, tc.wrapped, cur.CounterWrapped)
+			assert.Equal(t, tc.reset, cur.CounterReset)
+		})
+	}
+}
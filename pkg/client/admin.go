@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"wameter/internal/types"
+)
+
+// Reload reloads the server configuration
+func (c *Client) Reload(ctx context.Context) (*types.ConfigChange, error) {
+	var change types.ConfigChange
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/reload", nil, nil, &change); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetConfigHistory returns the configuration change history
+func (c *Client) GetConfigHistory(ctx context.Context) ([]types.ConfigChange, error) {
+	var history []types.ConfigChange
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/config/history", nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetIngestStats returns the depth and lag of the metrics ingest queue
+func (c *Client) GetIngestStats(ctx context.Context) (*types.IngestStats, error) {
+	var stats types.IngestStats
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/ingest", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetServerMetrics returns the server metrics
+func (c *Client) GetServerMetrics(ctx context.Context) (*types.ServiceMetrics, error) {
+	var metrics types.ServiceMetrics
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/metrics", nil, nil, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// GetQuotas returns the usage of agents against their quotas
+func (c *Client) GetQuotas(ctx context.Context) ([]*types.AgentQuota, error) {
+	var quotas []*types.AgentQuota
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/quotas", nil, nil, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// GetStats returns the server counters since the last restart
+func (c *Client) GetStats(ctx context.Context) (*types.ServiceStats, error) {
+	var stats types.ServiceStats
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetInventory returns the expected agents and counts declared through the API
+func (c *Client) GetInventory(ctx context.Context) (*types.Inventory, error) {
+	var inventory types.Inventory
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/inventory", nil, nil, &inventory); err != nil {
+		return nil, err
+	}
+	return &inventory, nil
+}
+
+// SetInventory replaces the expected agents and counts declared through the API
+func (c *Client) SetInventory(ctx context.Context, inventory *types.Inventory) (*types.Inventory, error) {
+	var result types.Inventory
+	if err := c.do(ctx, http.MethodPut, "/v1/admin/inventory", nil, inventory, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetInventoryStatus returns the state of the expected agents and counts
+func (c *Client) GetInventoryStatus(ctx context.Context) (*types.InventoryStatus, error) {
+	var status types.InventoryStatus
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/inventory/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListAlertRules returns the alert rules of the configuration and the ones
+// declared through the API
+func (c *Client) ListAlertRules(ctx context.Context) ([]*types.AlertRule, error) {
+	var rules []*types.AlertRule
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/alert-rules", nil, nil, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetAlertRule returns an alert rule
+func (c *Client) GetAlertRule(ctx context.Context, name string) (*types.AlertRule, error) {
+	var rule types.AlertRule
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/alert-rules/"+url.PathEscape(name), nil, nil, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ApplyAlertRule creates or replaces an alert rule declared through the API
+func (c *Client) ApplyAlertRule(ctx context.Context, rule *types.AlertRule) (*types.AlertRule, error) {
+	var result types.AlertRule
+	if err := c.do(ctx, http.MethodPut, "/v1/admin/alert-rules/"+url.PathEscape(rule.Name), nil, rule, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteAlertRule deletes an alert rule declared through the API
+func (c *Client) DeleteAlertRule(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/admin/alert-rules/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// CreateErasure erases all data of an agent or of the agents of a hostname
+func (c *Client) CreateErasure(ctx context.Context, req *types.ErasureRequest) (*types.Erasure, error) {
+	var erasure types.Erasure
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/erasures", nil, req, &erasure); err != nil {
+		return nil, err
+	}
+	return &erasure, nil
+}
+
+// ListErasures returns the erasures, newest first
+func (c *Client) ListErasures(ctx context.Context) ([]*types.Erasure, error) {
+	var erasures []*types.Erasure
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/erasures", nil, nil, &erasures); err != nil {
+		return nil, err
+	}
+	return erasures, nil
+}
+
+// GetErasure returns an erasure and its report
+func (c *Client) GetErasure(ctx context.Context, id string) (*types.Erasure, error) {
+	var erasure types.Erasure
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/erasures/"+url.PathEscape(id), nil, nil, &erasure); err != nil {
+		return nil, err
+	}
+	return &erasure, nil
+}
+
+// ListTenants returns the tenants
+func (c *Client) ListTenants(ctx context.Context) ([]*types.Tenant, error) {
+	var tenants []*types.Tenant
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/tenants", nil, nil, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// CreateTenant creates a tenant
+func (c *Client) CreateTenant(ctx context.Context, tenant *types.Tenant) (*types.Tenant, error) {
+	var result types.Tenant
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/tenants", nil, tenant, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteTenant deletes a tenant without agents
+func (c *Client) DeleteTenant(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/admin/tenants/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListTenantKeys returns the API keys of a tenant
+func (c *Client) ListTenantKeys(ctx context.Context, tenantID string) ([]*types.APIKey, error) {
+	var keys []*types.APIKey
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/tenants/"+url.PathEscape(tenantID)+"/keys", nil, nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateTenantKey creates an API key of a tenant, the key is only returned once
+func (c *Client) CreateTenantKey(ctx context.Context, tenantID, name string) (*types.APIKey, error) {
+	var key types.APIKey
+	req := map[string]string{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/tenants/"+url.PathEscape(tenantID)+"/keys", nil, req, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeTenantKey revokes an API key of a tenant
+func (c *Client) RevokeTenantKey(ctx context.Context, tenantID, keyID string) error {
+	path := "/v1/admin/tenants/" + url.PathEscape(tenantID) + "/keys/" + url.PathEscape(keyID)
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// GetUsage returns the resource usage per tenant
+func (c *Client) GetUsage(ctx context.Context) ([]*types.TenantUsage, error) {
+	var usage []*types.TenantUsage
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/usage", nil, nil, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// UserUpdate represents a user update, empty fields are left unchanged
+type UserUpdate struct {
+	Name   string     `json:"name,omitempty"`
+	Role   types.Role `json:"role,omitempty"`
+	Agents *[]string  `json:"agents,omitempty"`
+}
+
+// ListUsers returns the users, of a tenant unless tenantID is empty
+func (c *Client) ListUsers(ctx context.Context, tenantID string) ([]*types.User, error) {
+	var query url.Values
+	if tenantID != "" {
+		query = url.Values{"tenant_id": {tenantID}}
+	}
+
+	var users []*types.User
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/users", query, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// CreateUser creates a user
+func (c *Client) CreateUser(ctx context.Context, user *types.User) (*types.User, error) {
+	var result types.User
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/users", nil, user, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetUser returns a user
+func (c *Client) GetUser(ctx context.Context, id string) (*types.User, error) {
+	var user types.User
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/users/"+url.PathEscape(id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser changes the name, role or agents of a user
+func (c *Client) UpdateUser(ctx context.Context, id string, update *UserUpdate) (*types.User, error) {
+	var user types.User
+	if err := c.do(ctx, http.MethodPut, "/v1/admin/users/"+url.PathEscape(id), nil, update, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes a user and revokes its API keys
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/admin/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListUserKeys returns the API keys of a user
+func (c *Client) ListUserKeys(ctx context.Context, userID string) ([]*types.APIKey, error) {
+	var keys []*types.APIKey
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/users/"+url.PathEscape(userID)+"/keys", nil, nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateUserKey creates an API key acting as a user, the key is only returned once
+func (c *Client) CreateUserKey(ctx context.Context, userID, name string) (*types.APIKey, error) {
+	var key types.APIKey
+	req := map[string]string{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/users/"+url.PathEscape(userID)+"/keys", nil, req, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// AuditPage represents a page of audit log entries
+type AuditPage struct {
+	Entries []*types.AuditEntry `json:"entries"`
+	Limit   int                 `json:"limit"`
+	Offset  int                 `json:"offset"`
+	HasMore bool                `json:"has_more"`
+}
+
+// ListAuditEntries returns a page of the audit log, query holds the filter
+// and paging parameters
+func (c *Client) ListAuditEntries(ctx context.Context, query url.Values) (*AuditPage, error) {
+	var page AuditPage
+	if err := c.do(ctx, http.MethodGet, "/v1/audit", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// AnnotationPage represents a page of annotations
+type AnnotationPage struct {
+	Annotations []*types.Annotation `json:"annotations"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+	HasMore     bool                `json:"has_more"`
+}
+
+// ListAnnotations returns a page of annotations, query holds the time
+// range, filter and paging parameters
+func (c *Client) ListAnnotations(ctx context.Context, query url.Values) (*AnnotationPage, error) {
+	var page AnnotationPage
+	if err := c.do(ctx, http.MethodGet, "/v1/annotations", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// CreateAnnotation creates an annotation, global without an agent ID
+func (c *Client) CreateAnnotation(ctx context.Context, annotation *types.Annotation) (*types.Annotation, error) {
+	var result types.Annotation
+	if err := c.do(ctx, http.MethodPost, "/v1/annotations", nil, annotation, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,623 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"wameter/internal/types"
+	"wameter/internal/version"
+)
+
+// Config represents the API client configuration
+type Config struct {
+	Server             string        `mapstructure:"server" json:"server"`
+	Token              string        `mapstructure:"token" json:"token,omitempty"`
+	Timeout            time.Duration `mapstructure:"timeout" json:"timeout,omitempty"`
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify" json:"insecure_skip_verify,omitempty"`
+}
+
+// Client is a client of the server API
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// APIError represents an error returned by the server
+type APIError struct {
+	Status    int
+	Code      string // Machine-readable code, e.g. agent_not_found
+	Message   string
+	RequestID string
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("server returned %d: %s (request %s)", e.Status, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+}
+
+// envelope represents the standard API response
+type envelope struct {
+	Code      int             `json:"code"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	RequestID string          `json:"request_id"`
+}
+
+// NewClient creates new API client
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("server address is required")
+	}
+
+	server := strings.TrimSuffix(cfg.Server, "/")
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	if _, err := url.Parse(server); err != nil {
+		return nil, fmt.Errorf("invalid server address: %w", err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Client{
+		baseURL: server,
+		token:   cfg.Token,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+	}, nil
+}
+
+// Health returns the server health status
+func (c *Client) Health(ctx context.Context) (map[string]any, error) {
+	var health map[string]any
+	err := c.do(ctx, http.MethodGet, "/v1/health", nil, nil, &health)
+	return health, err
+}
+
+// AgentPage represents a page of agents
+type AgentPage struct {
+	Agents  []*types.AgentInfo `json:"agents"`
+	Total   int64              `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	HasMore bool               `json:"has_more"`
+}
+
+// ListAgents returns a page of agents, query holds the filter, sort and
+// paging parameters
+func (c *Client) ListAgents(ctx context.Context, query url.Values) (*AgentPage, error) {
+	var page AgentPage
+	if err := c.do(ctx, http.MethodGet, "/v1/agents", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAgentStatuses returns the status of agents by ID, query holds the
+// filter parameters
+func (c *Client) GetAgentStatuses(ctx context.Context, query url.Values) (map[string]*types.AgentStatusInfo, error) {
+	var statuses map[string]*types.AgentStatusInfo
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/status", query, nil, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetAgent returns an agent
+func (c *Client) GetAgent(ctx context.Context, id string) (*types.AgentInfo, error) {
+	var agent types.AgentInfo
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(id), nil, nil, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// UpdateAgent updates the hostname, version, status or port of an agent
+func (c *Client) UpdateAgent(ctx context.Context, id string, update *AgentUpdate) (*types.AgentInfo, error) {
+	var agent types.AgentInfo
+	if err := c.do(ctx, http.MethodPut, "/v1/agents/"+url.PathEscape(id), nil, update, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// DeleteAgent deletes an offline agent
+func (c *Client) DeleteAgent(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/agents/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Decommission retires an agent and schedules its purge
+func (c *Client) Decommission(ctx context.Context, id string, req *types.DecommissionRequest) (*types.AgentDecommission, error) {
+	var d types.AgentDecommission
+	if err := c.do(ctx, http.MethodPost, "/v1/agents/"+url.PathEscape(id)+"/decommission", nil, req, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetDecommission returns the decommission of an agent
+func (c *Client) GetDecommission(ctx context.Context, id string) (*types.AgentDecommission, error) {
+	var d types.AgentDecommission
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(id)+"/decommission", nil, nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Reinstate reinstates a retired agent before it is purged
+func (c *Client) Reinstate(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/agents/"+url.PathEscape(id)+"/decommission", nil, nil, nil)
+}
+
+// SetMaintenance puts an agent into maintenance
+func (c *Client) SetMaintenance(ctx context.Context, id string, req *types.MaintenanceRequest) (*types.AgentMaintenance, error) {
+	var m types.AgentMaintenance
+	if err := c.do(ctx, http.MethodPut, "/v1/agents/"+url.PathEscape(id)+"/maintenance", nil, req, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// EndMaintenance ends the maintenance of an agent
+func (c *Client) EndMaintenance(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/agents/"+url.PathEscape(id)+"/maintenance", nil, nil, nil)
+}
+
+// GetAgentMetrics returns the collection metrics of an agent
+func (c *Client) GetAgentMetrics(ctx context.Context, id string) (*types.AgentMetrics, error) {
+	var metrics types.AgentMetrics
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(id)+"/metrics", nil, nil, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// GetLatestMetrics returns the latest metrics of an agent
+func (c *Client) GetLatestMetrics(ctx context.Context, agentID string) (*types.MetricsData, error) {
+	var data types.MetricsData
+	query := url.Values{"agent_id": {agentID}}
+	if err := c.do(ctx, http.MethodGet, "/v1/metrics/latest", query, nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetOpenAPISpec returns the raw OpenAPI specification of the server
+func (c *Client) GetOpenAPISpec(ctx context.Context) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/v1/openapi.json", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, decodeError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// GetMetrics returns metrics in the given time range
+func (c *Client) GetMetrics(ctx context.Context, agentIDs []string, start, end time.Time, limit int) ([]*types.MetricsData, error) {
+	query := url.Values{
+		"start_time": {start.Format(time.RFC3339)},
+		"end_time":   {end.Format(time.RFC3339)},
+		"agent_ids":  agentIDs,
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+
+	var metrics []*types.MetricsData
+	err := c.do(ctx, http.MethodGet, "/v1/metrics", query, nil, &metrics)
+	return metrics, err
+}
+
+// ExportMetrics streams exported metrics to w
+func (c *Client) ExportMetrics(ctx context.Context, w io.Writer, format string, agentIDs []string, start, end time.Time) error {
+	query := url.Values{
+		"format":     {format},
+		"start_time": {start.Format(time.RFC3339)},
+		"end_time":   {end.Format(time.RFC3339)},
+		"agent_ids":  agentIDs,
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/v1/metrics/export", query, nil)
+	if err != nil {
+		return err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	return nil
+}
+
+// Backup streams a backup archive of the server data to w, metrics and IP
+// changes are limited to the range of start and end unless they are zero
+func (c *Client) Backup(ctx context.Context, w io.Writer, start, end time.Time) error {
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start_time", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		query.Set("end_time", end.Format(time.RFC3339))
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/v1/admin/backup", query, nil)
+	if err != nil {
+		return err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	return nil
+}
+
+// GetJobs returns the schedules and last runs of the periodic server tasks
+func (c *Client) GetJobs(ctx context.Context) ([]*types.JobStatus, error) {
+	var jobs []*types.JobStatus
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/jobs", nil, nil, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// RunJob starts a run of a periodic server task now
+func (c *Client) RunJob(ctx context.Context, name string) (*types.JobStatus, error) {
+	var status types.JobStatus
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/jobs/"+url.PathEscape(name)+"/run", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Prune deletes the metrics older than before on the server, in the
+// background unless dryRun only counts the rows that would be deleted
+func (c *Client) Prune(ctx context.Context, before time.Time, dryRun bool) (*types.Prune, error) {
+	req := types.PruneRequest{Before: before, DryRun: dryRun}
+
+	var p types.Prune
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/prune", nil, req, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPrune returns the status and progress of the last prune
+func (c *Client) GetPrune(ctx context.Context) (*types.Prune, error) {
+	var p types.Prune
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/prune", nil, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// TestNotification sends a test notification through a channel, or through
+// all enabled channels when channel is empty, and returns their deliveries
+func (c *Client) TestNotification(ctx context.Context, channel string) ([]*types.NotificationTestResult, error) {
+	req := types.NotificationTestRequest{Channel: channel}
+
+	var results []*types.NotificationTestResult
+	if err := c.do(ctx, http.MethodPost, "/v1/admin/notify/test", nil, req, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SendCommand sends a command to an agent and returns the command ID
+func (c *Client) SendCommand(ctx context.Context, agentID, cmdType string, payload json.RawMessage, timeout time.Duration) (string, error) {
+	body := map[string]any{
+		"type": cmdType,
+	}
+	if len(payload) > 0 {
+		body["payload"] = payload
+	}
+	if timeout > 0 {
+		body["timeout"] = timeout
+	}
+
+	var result struct {
+		CommandID string `json:"command_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/agents/"+url.PathEscape(agentID)+"/command", nil, body, &result); err != nil {
+		return "", err
+	}
+	return result.CommandID, nil
+}
+
+// GetCommandResult returns the result of a command, waiting up to wait for
+// it to end. Commands still in progress are returned with their current
+// status, the wait is bounded by the timeout of the client.
+func (c *Client) GetCommandResult(ctx context.Context, commandID string, wait time.Duration) (*types.CommandResult, error) {
+	var query url.Values
+	if wait > 0 {
+		query = url.Values{"wait": {wait.String()}}
+	}
+
+	var result types.CommandResult
+	if err := c.do(ctx, http.MethodGet, "/v1/commands/"+url.PathEscape(commandID)+"/result", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RequestDiagnostics asks an agent to upload a diagnostics bundle and returns
+// the command ID
+func (c *Client) RequestDiagnostics(ctx context.Context, agentID string) (string, error) {
+	var result struct {
+		CommandID string `json:"command_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/agents/"+url.PathEscape(agentID)+"/diagnostics", nil, nil, &result); err != nil {
+		return "", err
+	}
+	return result.CommandID, nil
+}
+
+// Collect asks an agent to collect and report metrics at once and returns
+// the command ID, which completes once the report is stored
+func (c *Client) Collect(ctx context.Context, agentID string) (string, error) {
+	var result struct {
+		CommandID string `json:"command_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/agents/"+url.PathEscape(agentID)+"/collect", nil, nil, &result); err != nil {
+		return "", err
+	}
+	return result.CommandID, nil
+}
+
+// DownloadDiagnostics writes a diagnostics bundle of an agent to w, the newest
+// one if bundle is empty
+func (c *Client) DownloadDiagnostics(ctx context.Context, w io.Writer, agentID, bundle string) error {
+	var query url.Values
+	if bundle != "" {
+		query = url.Values{"bundle": {bundle}}
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(agentID)+"/diagnostics", query, nil)
+	if err != nil {
+		return err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read diagnostics: %w", err)
+	}
+	return nil
+}
+
+// ListDiagnostics returns the diagnostics bundles of an agent
+func (c *Client) ListDiagnostics(ctx context.Context, agentID string) ([]*types.Diagnostics, error) {
+	var bundles []*types.Diagnostics
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(agentID)+"/diagnostics/bundles", nil, nil, &bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// AgentUpdate represents an agent update, empty fields are left unchanged
+type AgentUpdate struct {
+	Hostname string            `json:"hostname,omitempty"`
+	Version  string            `json:"version,omitempty"`
+	Status   types.AgentStatus `json:"status,omitempty"`
+	Port     int               `json:"port,omitempty"`
+}
+
+// IPChangePage represents a page of IP changes
+type IPChangePage struct {
+	Changes []*types.IPChange `json:"changes"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+	HasMore bool              `json:"has_more"`
+}
+
+// ListIPChanges returns IP changes, of all agents if agentID is empty
+func (c *Client) ListIPChanges(ctx context.Context, agentID string, query url.Values) (*IPChangePage, error) {
+	path := "/v1/ip-changes"
+	if agentID != "" {
+		path = "/v1/agents/" + url.PathEscape(agentID) + "/ip-changes"
+	}
+
+	var page IPChangePage
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// AgentLogPage represents a page of agent log entries
+type AgentLogPage struct {
+	Entries []*types.AgentLogEntry `json:"entries"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// ListAgentLogs returns the log entries shipped by an agent
+func (c *Client) ListAgentLogs(ctx context.Context, agentID string, query url.Values) (*AgentLogPage, error) {
+	var page AgentLogPage
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(agentID)+"/logs", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetIPChangeSummary returns the IP change summary of an agent
+func (c *Client) GetIPChangeSummary(ctx context.Context, agentID string) (*types.IPChangeSummary, error) {
+	var summary types.IPChangeSummary
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(agentID)+"/ip-changes/summary", nil, nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetIPChangeStats returns the IP change patterns of an agent
+func (c *Client) GetIPChangeStats(ctx context.Context, agentID string) (*types.IPChangeStats, error) {
+	var stats types.IPChangeStats
+	if err := c.do(ctx, http.MethodGet, "/v1/agents/"+url.PathEscape(agentID)+"/ip-changes/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetIPChangeWindows returns the expected IP change windows declared through the API
+func (c *Client) GetIPChangeWindows(ctx context.Context) ([]types.IPChangeWindow, error) {
+	var windows []types.IPChangeWindow
+	if err := c.do(ctx, http.MethodGet, "/v1/admin/ip-change-windows", nil, nil, &windows); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// SetIPChangeWindows replaces the expected IP change windows declared through the API
+func (c *Client) SetIPChangeWindows(ctx context.Context, windows []types.IPChangeWindow) ([]types.IPChangeWindow, error) {
+	var result []types.IPChangeWindow
+	if err := c.do(ctx, http.MethodPut, "/v1/admin/ip-change-windows", nil, windows, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTop ranks agents or interfaces, query holds the metric and time range
+func (c *Client) GetTop(ctx context.Context, scope types.TopScope, query url.Values) (*types.TopResult, error) {
+	var result types.TopResult
+	if err := c.do(ctx, http.MethodGet, "/v1/analytics/top/"+string(scope), query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends a request and decodes the response data into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("failed to decode response data: %w", err)
+	}
+	return nil
+}
+
+// send builds and sends a request
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "wameterctl/"+version.GetInfo().Version)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// decodeError converts an error response to APIError
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{
+		Status:  resp.StatusCode,
+		Message: http.StatusText(resp.StatusCode),
+	}
+
+	// Errors are problem+json documents
+	var problem struct {
+		Code      string `json:"code"`
+		Detail    string `json:"detail"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&problem); err == nil {
+		if problem.Detail != "" {
+			apiErr.Message = problem.Detail
+		}
+		apiErr.Code = problem.Code
+		apiErr.RequestID = problem.RequestID
+	}
+
+	return apiErr
+}
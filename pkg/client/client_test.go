@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	av1 "wameter/internal/server/api/v1"
+	"wameter/internal/server/config"
+	"wameter/internal/types"
+)
+
+// serverOnly lists the operations called by agents and integrations rather
+// than by clients of the API
+var serverOnly = map[string]string{
+	"POST /v1/agents":                 "agent registration",
+	"POST /v1/agents/{id}/heartbeat":  "agent heartbeat",
+	"POST /v1/agents/{id}/logs":       "agent log shipping",
+	"PUT /v1/agents/{id}/diagnostics": "agent diagnostics upload",
+	"GET /v1/agents/{id}/commands":    "agent command polling",
+	"POST /v1/metrics":                "agent report",
+	"POST /v1/metrics/backfill":       "agent backfill",
+	"POST /v1/metrics/remote_write":   "Prometheus remote_write",
+	"GET /v1/grafana":                 "Grafana datasource",
+	"POST /v1/grafana/search":         "Grafana datasource",
+	"POST /v1/grafana/query":          "Grafana datasource",
+	"POST /v1/grafana/annotations":    "Grafana datasource",
+	"POST /v1/telegram/webhook":       "Telegram updates",
+}
+
+// pathParam matches a path parameter of the specification
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestClientCoversSpec tests that every operation of the OpenAPI
+// specification has a client method sending its method and path, and that
+// no client method sends an undocumented request
+func TestClientCoversSpec(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.MetricsPath = "/metrics"
+	spec := av1.NewAPI(cfg, nil, zaptest.NewLogger(t)).OpenAPISpec()
+
+	type operation struct {
+		name    string
+		pattern *regexp.Regexp
+		params  int
+	}
+	var operations []operation
+	for path, methods := range spec.Paths {
+		quoted := regexp.QuoteMeta(pathParam.ReplaceAllString(path, "\x00"))
+		pattern := regexp.MustCompile("^" + strings.ReplaceAll(quoted, "\x00", `[^/]+`) + "$")
+		for method := range methods {
+			operations = append(operations, operation{
+				name:    strings.ToUpper(method) + " " + path,
+				pattern: pattern,
+				params:  strings.Count(path, "{"),
+			})
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"code":0,"message":"ok"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(&Config{Server: srv.URL})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+	calls := []func() error{
+		func() error { _, err := c.Health(ctx); return err },
+		func() error { _, err := c.GetOpenAPISpec(ctx); return err },
+		func() error { _, err := c.ListAgents(ctx, nil); return err },
+		func() error { _, err := c.GetAgentStatuses(ctx, nil); return err },
+		func() error { _, err := c.GetAgent(ctx, "a"); return err },
+		func() error { _, err := c.UpdateAgent(ctx, "a", &AgentUpdate{}); return err },
+		func() error { return c.DeleteAgent(ctx, "a") },
+		func() error { _, err := c.GetAgentMetrics(ctx, "a"); return err },
+		func() error { _, err := c.Decommission(ctx, "a", &types.DecommissionRequest{}); return err },
+		func() error { _, err := c.GetDecommission(ctx, "a"); return err },
+		func() error { return c.Reinstate(ctx, "a") },
+		func() error { _, err := c.SetMaintenance(ctx, "a", &types.MaintenanceRequest{}); return err },
+		func() error { return c.EndMaintenance(ctx, "a") },
+		func() error { _, err := c.RequestDiagnostics(ctx, "a"); return err },
+		func() error { return c.DownloadDiagnostics(ctx, io.Discard, "a", "") },
+		func() error { _, err := c.ListDiagnostics(ctx, "a"); return err },
+		func() error { _, err := c.Collect(ctx, "a"); return err },
+		func() error { _, err := c.ListAgentLogs(ctx, "a", nil); return err },
+		func() error { _, err := c.SendCommand(ctx, "a", "reload", nil, 0); return err },
+		func() error { _, err := c.GetCommandResult(ctx, "c", 0); return err },
+		func() error { _, err := c.GetMetrics(ctx, nil, now, now, 0); return err },
+		func() error { _, err := c.GetLatestMetrics(ctx, "a"); return err },
+		func() error { return c.ExportMetrics(ctx, io.Discard, "json", nil, now, now) },
+		func() error { _, err := c.ListIPChanges(ctx, "", nil); return err },
+		func() error { _, err := c.ListIPChanges(ctx, "a", nil); return err },
+		func() error { _, err := c.GetIPChangeSummary(ctx, "a"); return err },
+		func() error { _, err := c.GetIPChangeStats(ctx, "a"); return err },
+		func() error { _, err := c.GetIPChangeWindows(ctx); return err },
+		func() error { _, err := c.SetIPChangeWindows(ctx, nil); return err },
+		func() error { _, err := c.GetTop(ctx, types.TopScopeAgents, nil); return err },
+		func() error { _, err := c.GetTop(ctx, types.TopScopeInterfaces, nil); return err },
+		func() error { _, err := c.Reload(ctx); return err },
+		func() error { return c.Backup(ctx, io.Discard, time.Time{}, time.Time{}) },
+		func() error { _, err := c.GetConfigHistory(ctx); return err },
+		func() error { _, err := c.GetIngestStats(ctx); return err },
+		func() error { _, err := c.GetJobs(ctx); return err },
+		func() error { _, err := c.RunJob(ctx, "cleanup"); return err },
+		func() error { _, err := c.GetServerMetrics(ctx); return err },
+		func() error { _, err := c.TestNotification(ctx, ""); return err },
+		func() error { _, err := c.Prune(ctx, now, true); return err },
+		func() error { _, err := c.GetPrune(ctx); return err },
+		func() error { _, err := c.GetQuotas(ctx); return err },
+		func() error { _, err := c.GetStats(ctx); return err },
+		func() error { _, err := c.GetInventory(ctx); return err },
+		func() error { _, err := c.SetInventory(ctx, &types.Inventory{}); return err },
+		func() error { _, err := c.GetInventoryStatus(ctx); return err },
+		func() error { _, err := c.ListAlertRules(ctx); return err },
+		func() error { _, err := c.GetAlertRule(ctx, "r"); return err },
+		func() error { _, err := c.ApplyAlertRule(ctx, &types.AlertRule{Name: "r"}); return err },
+		func() error { return c.DeleteAlertRule(ctx, "r") },
+		func() error { _, err := c.CreateErasure(ctx, &types.ErasureRequest{}); return err },
+		func() error { _, err := c.ListErasures(ctx); return err },
+		func() error { _, err := c.GetErasure(ctx, "e"); return err },
+		func() error { _, err := c.ListTenants(ctx); return err },
+		func() error { _, err := c.CreateTenant(ctx, &types.Tenant{}); return err },
+		func() error { return c.DeleteTenant(ctx, "t") },
+		func() error { _, err := c.ListTenantKeys(ctx, "t"); return err },
+		func() error { _, err := c.CreateTenantKey(ctx, "t", "ci"); return err },
+		func() error { return c.RevokeTenantKey(ctx, "t", "k") },
+		func() error { _, err := c.GetUsage(ctx); return err },
+		func() error { _, err := c.ListUsers(ctx, ""); return err },
+		func() error { _, err := c.CreateUser(ctx, &types.User{}); return err },
+		func() error { _, err := c.GetUser(ctx, "u"); return err },
+		func() error { _, err := c.UpdateUser(ctx, "u", &UserUpdate{}); return err },
+		func() error { return c.DeleteUser(ctx, "u") },
+		func() error { _, err := c.ListUserKeys(ctx, "u"); return err },
+		func() error { _, err := c.CreateUserKey(ctx, "u", "ci"); return err },
+		func() error { _, err := c.ListAuditEntries(ctx, nil); return err },
+		func() error { _, err := c.ListAnnotations(ctx, nil); return err },
+		func() error { _, err := c.CreateAnnotation(ctx, &types.Annotation{}); return err },
+	}
+	for _, call := range calls {
+		require.NoError(t, call())
+	}
+
+	covered := make(map[string]bool)
+	for _, req := range requests {
+		// The operation with the fewest parameters matches, e.g. /agents/status before /agents/{id}
+		method, path, _ := strings.Cut(req, " ")
+		var matched *operation
+		for i, op := range operations {
+			if strings.HasPrefix(op.name, method+" ") && op.pattern.MatchString(path) &&
+				(matched == nil || op.params < matched.params) {
+				matched = &operations[i]
+			}
+		}
+		if assert.NotNil(t, matched, "client request %s is not in the specification", req) {
+			covered[matched.name] = true
+		}
+	}
+
+	for _, op := range operations {
+		if _, ok := serverOnly[op.name]; ok {
+			assert.False(t, covered[op.name], "operation %s is listed as server only", op.name)
+			continue
+		}
+		assert.True(t, covered[op.name], "operation %s has no client method", op.name)
+	}
+}